@@ -39,6 +39,10 @@ type LogClient struct {
 	STR      trillian.SignedLogRoot
 	MaxTries int
 	pubKey   gocrypto.PublicKey
+	// AcceptLegacySTH makes UpdateSTR also accept roots signed before STH versions
+	// were introduced. It is meant for the rollout of a log upgrade only, until the
+	// log signer has re-signed its roots.
+	AcceptLegacySTH bool
 }
 
 // New returns a new LogClient.
@@ -109,6 +113,21 @@ func (c *LogClient) waitForSTRUpdate(ctx context.Context, attempts int) error {
 	}
 }
 
+// verifySTH checks the signature on str as a crypto.STHVersion1 STH, falling back to
+// crypto.STHVersionLegacy if AcceptLegacySTH is set.
+func (c *LogClient) verifySTH(str *trillian.SignedLogRoot) error {
+	sth := crypto.STHFromRoot(*str)
+	err := crypto.VerifySTH(c.pubKey, sth, str.Signature, crypto.STHVersion1)
+	if err == nil || !c.AcceptLegacySTH {
+		return err
+	}
+	sth.Version = crypto.STHVersionLegacy
+	if crypto.VerifySTH(c.pubKey, sth, str.Signature, crypto.STHVersionLegacy) == nil {
+		return nil
+	}
+	return err
+}
+
 // UpdateSTR retrieves the current SignedLogRoot and verifies it.
 func (c *LogClient) UpdateSTR(ctx context.Context) error {
 	req := &trillian.GetLatestSignedLogRootRequest{
@@ -121,8 +140,7 @@ func (c *LogClient) UpdateSTR(ctx context.Context) error {
 	str := resp.SignedLogRoot
 
 	// Verify SignedLogRoot signature.
-	hash := crypto.HashLogRoot(*str)
-	if err := crypto.Verify(c.pubKey, hash, str.Signature); err != nil {
+	if err := c.verifySTH(str); err != nil {
 		return err
 	}
 
@@ -19,7 +19,6 @@ import (
 
 	context "golang.org/x/net/context"
 
-	google_protobuf "github.com/golang/protobuf/ptypes/empty"
 	"github.com/google/trillian"
 	"google.golang.org/grpc"
 )
@@ -32,7 +31,7 @@ type MockLogClient struct {
 }
 
 // QueueLeaf forwards requests.
-func (c *MockLogClient) QueueLeaf(ctx context.Context, in *trillian.QueueLeafRequest, opts ...grpc.CallOption) (*google_protobuf.Empty, error) {
+func (c *MockLogClient) QueueLeaf(ctx context.Context, in *trillian.QueueLeafRequest, opts ...grpc.CallOption) (*trillian.QueueLeafResponse, error) {
 	return c.c.QueueLeaf(ctx, in)
 }
 
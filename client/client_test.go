@@ -16,12 +16,18 @@ package client
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
 	"testing"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/sigpb"
 	"github.com/google/trillian/testonly"
 	"github.com/google/trillian/testonly/integration"
 )
@@ -108,3 +114,53 @@ func TestUpdateSTR(t *testing.T) {
 		t.Errorf("Tree size after add Leaf: %v, want > %v", got, want)
 	}
 }
+
+func TestVerifySTHLegacy(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	keyManager, err := crypto.NewFromPrivateKey(key)
+	if err != nil {
+		t.Fatalf("NewFromPrivateKey()=(_,%v), want (_,nil)", err)
+	}
+	signer := crypto.NewSignerFromPrivateKeyManager(keyManager)
+	root := trillian.SignedLogRoot{TreeSize: 7, RootHash: []byte("root"), TimestampNanos: 1500000000000000000}
+	v1Sig, err := signer.Sign(crypto.HashVersionedLogRoot(string(crypto.STHVersion1), root))
+	if err != nil {
+		t.Fatalf("Sign(v1)=(_,%v), want (_,nil)", err)
+	}
+	legacySig, err := signer.Sign(crypto.HashLogRoot(root))
+	if err != nil {
+		t.Fatalf("Sign(legacy)=(_,%v), want (_,nil)", err)
+	}
+	otherSig, err := signer.Sign(crypto.HashLogRoot(trillian.SignedLogRoot{TreeSize: 8, RootHash: []byte("root")}))
+	if err != nil {
+		t.Fatalf("Sign(other)=(_,%v), want (_,nil)", err)
+	}
+
+	for _, test := range []struct {
+		desc         string
+		acceptLegacy bool
+		sig          *sigpb.DigitallySigned
+		wantErr      bool
+	}{
+		{desc: "v1", sig: v1Sig},
+		{desc: "v1 accepting legacy", acceptLegacy: true, sig: v1Sig},
+		{desc: "legacy", sig: legacySig, wantErr: true},
+		{desc: "legacy accepting legacy", acceptLegacy: true, sig: legacySig},
+		{desc: "other root accepting legacy", acceptLegacy: true, sig: otherSig, wantErr: true},
+	} {
+		str := root
+		str.Signature = test.sig
+		client := New(0, nil, testonly.Hasher, key.Public())
+		client.AcceptLegacySTH = test.acceptLegacy
+		err := client.verifySTH(&str)
+		if got := err != nil; got != test.wantErr {
+			t.Errorf("%v: verifySTH()=%v, want error: %v", test.desc, err, test.wantErr)
+		}
+		if err != nil && !errors.Is(err, crypto.ErrVerifyFailed) {
+			t.Errorf("%v: verifySTH()=%v, want %v", test.desc, err, crypto.ErrVerifyFailed)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package crypto
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/trillian/crypto/sigpb"
+)
+
+// ParseSignatureAlgorithm returns the signature algorithm with the given name,
+// e.g. "ECDSA" or "rsa_pss". Names are matched case-insensitively.
+func ParseSignatureAlgorithm(name string) (sigpb.DigitallySigned_SignatureAlgorithm, error) {
+	v, ok := sigpb.DigitallySigned_SignatureAlgorithm_value[strings.ToUpper(name)]
+	if !ok {
+		return sigpb.DigitallySigned_ANONYMOUS, fmt.Errorf("unknown signature algorithm: %q", name)
+	}
+	return sigpb.DigitallySigned_SignatureAlgorithm(v), nil
+}
+
+// ParseHashAlgorithm returns the hash algorithm with the given name, e.g.
+// "SHA256" or "sha512". Names are matched case-insensitively.
+func ParseHashAlgorithm(name string) (sigpb.DigitallySigned_HashAlgorithm, error) {
+	v, ok := sigpb.DigitallySigned_HashAlgorithm_value[strings.ToUpper(name)]
+	if !ok {
+		return sigpb.DigitallySigned_NONE, fmt.Errorf("unknown hash algorithm: %q", name)
+	}
+	return sigpb.DigitallySigned_HashAlgorithm(v), nil
+}
+
+// FormatSignatureAlgorithm returns the name of alg, as accepted by
+// ParseSignatureAlgorithm. Unknown values are formatted as their number.
+func FormatSignatureAlgorithm(alg sigpb.DigitallySigned_SignatureAlgorithm) string {
+	return alg.String()
+}
+
+// FormatHashAlgorithm returns the name of alg, as accepted by ParseHashAlgorithm.
+// Unknown values are formatted as their number.
+func FormatHashAlgorithm(alg sigpb.DigitallySigned_HashAlgorithm) string {
+	return alg.String()
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package crypto
+
+import (
+	"testing"
+
+	"github.com/google/trillian/crypto/sigpb"
+)
+
+func TestParseSignatureAlgorithm(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		want    sigpb.DigitallySigned_SignatureAlgorithm
+		wantErr bool
+	}{
+		{name: "ECDSA", want: sigpb.DigitallySigned_ECDSA},
+		{name: "ecdsa", want: sigpb.DigitallySigned_ECDSA},
+		{name: "RSA", want: sigpb.DigitallySigned_RSA},
+		{name: "Rsa_Pss", want: sigpb.DigitallySigned_RSA_PSS},
+		{name: "ed25519", want: sigpb.DigitallySigned_ED25519},
+		{name: "ANONYMOUS", want: sigpb.DigitallySigned_ANONYMOUS},
+		{name: "", wantErr: true},
+		{name: "DSA", wantErr: true},
+		{name: " ECDSA", wantErr: true},
+		{name: "3", wantErr: true},
+	} {
+		got, err := ParseSignatureAlgorithm(test.name)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("ParseSignatureAlgorithm(%q)=_,%v, want err? %v", test.name, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseSignatureAlgorithm(%q)=%v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseHashAlgorithm(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		want    sigpb.DigitallySigned_HashAlgorithm
+		wantErr bool
+	}{
+		{name: "SHA256", want: sigpb.DigitallySigned_SHA256},
+		{name: "sha256", want: sigpb.DigitallySigned_SHA256},
+		{name: "Sha384", want: sigpb.DigitallySigned_SHA384},
+		{name: "SHA512", want: sigpb.DigitallySigned_SHA512},
+		{name: "sha3_256", want: sigpb.DigitallySigned_SHA3_256},
+		{name: "none", want: sigpb.DigitallySigned_NONE},
+		{name: "", wantErr: true},
+		{name: "SHA-256", wantErr: true},
+		{name: "MD5", wantErr: true},
+	} {
+		got, err := ParseHashAlgorithm(test.name)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("ParseHashAlgorithm(%q)=_,%v, want err? %v", test.name, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseHashAlgorithm(%q)=%v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFormatAlgorithmsRoundTrip(t *testing.T) {
+	for v := range sigpb.DigitallySigned_SignatureAlgorithm_name {
+		alg := sigpb.DigitallySigned_SignatureAlgorithm(v)
+		got, err := ParseSignatureAlgorithm(FormatSignatureAlgorithm(alg))
+		if err != nil || got != alg {
+			t.Errorf("ParseSignatureAlgorithm(FormatSignatureAlgorithm(%v))=%v,%v, want %v,nil", alg, got, err, alg)
+		}
+	}
+	for v := range sigpb.DigitallySigned_HashAlgorithm_name {
+		alg := sigpb.DigitallySigned_HashAlgorithm(v)
+		got, err := ParseHashAlgorithm(FormatHashAlgorithm(alg))
+		if err != nil || got != alg {
+			t.Errorf("ParseHashAlgorithm(FormatHashAlgorithm(%v))=%v,%v, want %v,nil", alg, got, err, alg)
+		}
+	}
+
+	if got, want := FormatSignatureAlgorithm(sigpb.DigitallySigned_SignatureAlgorithm(99)), "99"; got != want {
+		t.Errorf("FormatSignatureAlgorithm(99)=%q, want %q", got, want)
+	}
+	if got, want := FormatHashAlgorithm(sigpb.DigitallySigned_HashAlgorithm(99)), "99"; got != want {
+		t.Errorf("FormatHashAlgorithm(99)=%q, want %q", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cryptotest provides deterministic fake signers and verifiers for use
+// in the unit tests of code that signs or verifies data, where real keys and
+// signatures would only add noise.
+package cryptotest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/sigpb"
+)
+
+// fakeSignaturePrefix starts every signature made by a FakeSigner, so that they
+// are easy to recognize in test output.
+const fakeSignaturePrefix = "FAKE-SIGNATURE:"
+
+// Verifier is the interface implemented by the verifiers in this package.
+type Verifier interface {
+	Verify(data []byte, sig *sigpb.DigitallySigned) error
+	VerifyObject(obj interface{}, sig *sigpb.DigitallySigned) error
+}
+
+// FakeSignature returns the signature that a FakeSigner with the given name
+// produces over data.
+func FakeSignature(name string, data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write(data)
+	return h.Sum([]byte(fakeSignaturePrefix))
+}
+
+// FakeSigner produces deterministic signatures that are accepted only by a
+// FakeVerifier with the same Name. It has the same signing methods as
+// crypto.Signer.
+type FakeSigner struct {
+	// Name distinguishes the signatures of different fake signers.
+	Name string
+}
+
+// Sign returns a fake signature over data.
+func (s FakeSigner) Sign(data []byte) (*sigpb.DigitallySigned, error) {
+	return &sigpb.DigitallySigned{
+		SignatureAlgorithm: sigpb.DigitallySigned_ANONYMOUS,
+		HashAlgorithm:      sigpb.DigitallySigned_SHA256,
+		Signature:          FakeSignature(s.Name, data),
+	}, nil
+}
+
+// SignObject returns a fake signature over the JSON encoding of obj.
+func (s FakeSigner) SignObject(obj interface{}) (*sigpb.DigitallySigned, error) {
+	j, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	return s.Sign(j)
+}
+
+// FakeVerifier accepts exactly the signatures made by the FakeSigner with the
+// same Name, and returns crypto.ErrVerifyFailed for anything else.
+type FakeVerifier struct {
+	// Name must match the Name of the FakeSigner whose signatures are accepted.
+	Name string
+}
+
+// Verify checks that sig is the fake signature over data.
+func (v FakeVerifier) Verify(data []byte, sig *sigpb.DigitallySigned) error {
+	if sig == nil || sig.SignatureAlgorithm != sigpb.DigitallySigned_ANONYMOUS ||
+		sig.HashAlgorithm != sigpb.DigitallySigned_SHA256 ||
+		!bytes.Equal(sig.Signature, FakeSignature(v.Name, data)) {
+		return crypto.ErrVerifyFailed
+	}
+	return nil
+}
+
+// VerifyObject checks that sig is the fake signature over the JSON encoding of obj.
+func (v FakeVerifier) VerifyObject(obj interface{}, sig *sigpb.DigitallySigned) error {
+	j, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return v.Verify(j, sig)
+}
+
+// FailingVerifier rejects every signature with crypto.ErrVerifyFailed.
+type FailingVerifier struct{}
+
+// Verify always returns crypto.ErrVerifyFailed.
+func (FailingVerifier) Verify([]byte, *sigpb.DigitallySigned) error {
+	return crypto.ErrVerifyFailed
+}
+
+// VerifyObject always returns crypto.ErrVerifyFailed.
+func (FailingVerifier) VerifyObject(interface{}, *sigpb.DigitallySigned) error {
+	return crypto.ErrVerifyFailed
+}
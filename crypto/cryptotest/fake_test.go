@@ -0,0 +1,90 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cryptotest
+
+import (
+	"testing"
+
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/sigpb"
+)
+
+var (
+	_ Verifier = FakeVerifier{}
+	_ Verifier = FailingVerifier{}
+)
+
+func TestFakeRoundTrip(t *testing.T) {
+	signer := FakeSigner{Name: "a"}
+	data := []byte("foo")
+	sig, err := signer.Sign(data)
+	if err != nil {
+		t.Fatalf("Sign()=(_,%v), want (_,nil)", err)
+	}
+	again, err := signer.Sign(data)
+	if err != nil {
+		t.Fatalf("Sign()=(_,%v), want (_,nil)", err)
+	}
+	if got, want := again.String(), sig.String(); got != want {
+		t.Errorf("Sign() not deterministic: %v, then %v", want, got)
+	}
+
+	for _, test := range []struct {
+		desc    string
+		v       Verifier
+		data    []byte
+		sig     *sigpb.DigitallySigned
+		wantErr bool
+	}{
+		{desc: "match", v: FakeVerifier{Name: "a"}, data: data, sig: sig},
+		{desc: "other name", v: FakeVerifier{Name: "b"}, data: data, sig: sig, wantErr: true},
+		{desc: "other data", v: FakeVerifier{Name: "a"}, data: []byte("bar"), sig: sig, wantErr: true},
+		{desc: "nil sig", v: FakeVerifier{Name: "a"}, data: data, wantErr: true},
+		{desc: "other algorithm", v: FakeVerifier{Name: "a"}, data: data, sig: &sigpb.DigitallySigned{
+			SignatureAlgorithm: sigpb.DigitallySigned_ECDSA,
+			HashAlgorithm:      sig.HashAlgorithm,
+			Signature:          sig.Signature,
+		}, wantErr: true},
+		{desc: "failing", v: FailingVerifier{}, data: data, sig: sig, wantErr: true},
+	} {
+		err := test.v.Verify(test.data, test.sig)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%v: Verify()=%v, want err? %v", test.desc, err, test.wantErr)
+		}
+		if err != nil && err != crypto.ErrVerifyFailed {
+			t.Errorf("%v: Verify()=%v, want %v", test.desc, err, crypto.ErrVerifyFailed)
+		}
+	}
+}
+
+func TestFakeObjectRoundTrip(t *testing.T) {
+	obj := struct{ A, B int }{A: 1, B: 2}
+	sig, err := FakeSigner{Name: "a"}.SignObject(obj)
+	if err != nil {
+		t.Fatalf("SignObject()=(_,%v), want (_,nil)", err)
+	}
+	if err := (FakeVerifier{Name: "a"}).VerifyObject(obj, sig); err != nil {
+		t.Errorf("VerifyObject()=%v, want nil", err)
+	}
+	if err := (FakeVerifier{Name: "a"}).VerifyObject(struct{ A, B int }{A: 1, B: 3}, sig); err != crypto.ErrVerifyFailed {
+		t.Errorf("VerifyObject(changed)=%v, want %v", err, crypto.ErrVerifyFailed)
+	}
+	if err := (FailingVerifier{}).VerifyObject(obj, sig); err != crypto.ErrVerifyFailed {
+		t.Errorf("FailingVerifier.VerifyObject()=%v, want %v", err, crypto.ErrVerifyFailed)
+	}
+	if _, err := (FakeSigner{}).SignObject(func() {}); err == nil {
+		t.Error("SignObject(func)=(_,nil), want error")
+	}
+}
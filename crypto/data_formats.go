@@ -31,6 +31,7 @@ const (
 	mapKeyRootHash       string = "RootHash"
 	mapKeyTimestampNanos string = "TimestampNanos"
 	mapKeyTreeSize       string = "TreeSize"
+	mapKeyVersion        string = "Version"
 )
 
 // HashLogRoot hashes SignedLogRoot objects using ObjectHash with
@@ -49,3 +50,17 @@ func HashLogRoot(root trillian.SignedLogRoot) []byte {
 	hash := objecthash.ObjectHash(rootMap)
 	return hash[:]
 }
+
+// HashVersionedLogRoot hashes SignedLogRoot objects like HashLogRoot, with version
+// added under the "Version" key. The hash of a root therefore differs between
+// versions, and from the HashLogRoot of the root.
+func HashVersionedLogRoot(version string, root trillian.SignedLogRoot) []byte {
+	rootMap := map[string]string{
+		mapKeyRootHash:       base64.StdEncoding.EncodeToString(root.RootHash),
+		mapKeyTimestampNanos: strconv.FormatInt(root.TimestampNanos, 10),
+		mapKeyTreeSize:       strconv.FormatInt(root.TreeSize, 10),
+		mapKeyVersion:        version}
+
+	hash := objecthash.ObjectHash(rootMap)
+	return hash[:]
+}
@@ -15,6 +15,7 @@
 package crypto
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/google/trillian"
@@ -64,3 +65,17 @@ func TestHashLogRoot(t *testing.T) {
 	}
 
 }
+
+func TestHashVersionedLogRoot(t *testing.T) {
+	root := trillian.SignedLogRoot{TimestampNanos: 2267709, RootHash: []byte("Islington"), TreeSize: 2}
+	v1 := HashVersionedLogRoot("v1", root)
+	if got := HashVersionedLogRoot("v1", root); !bytes.Equal(got, v1) {
+		t.Errorf("HashVersionedLogRoot(v1)=%x, then %x, want equal", v1, got)
+	}
+	if v2 := HashVersionedLogRoot("v2", root); bytes.Equal(v2, v1) {
+		t.Errorf("HashVersionedLogRoot(v2)=%x, want different from v1", v2)
+	}
+	if unversioned := HashLogRoot(root); bytes.Equal(unversioned, v1) {
+		t.Errorf("HashLogRoot()=%x, want different from HashVersionedLogRoot(v1)", unversioned)
+	}
+}
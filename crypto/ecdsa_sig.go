@@ -0,0 +1,72 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypto
+
+import (
+	"crypto/elliptic"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+)
+
+// ecdsaSignature is the ASN.1 structure of a DER encoded ECDSA signature.
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// ecdsaRawSize returns the size of each of R and S in the raw encoding of a signature
+// made on curve.
+func ecdsaRawSize(curve elliptic.Curve) int {
+	return (curve.Params().BitSize + 7) / 8
+}
+
+// ECDSASigToDER converts an ECDSA signature in the raw form used by JWS (RFC 7518
+// section 3.4), R and S as big-endian integers of the curve's size concatenated, to
+// the ASN.1 DER form that Verify expects.
+func ECDSASigToDER(raw []byte, curve elliptic.Curve) ([]byte, error) {
+	size := ecdsaRawSize(curve)
+	if got, want := len(raw), 2*size; got != want {
+		return nil, fmt.Errorf("raw ECDSA signature is %d bytes, want %d for %s", got, want, curve.Params().Name)
+	}
+	return asn1.Marshal(ecdsaSignature{
+		R: new(big.Int).SetBytes(raw[:size]),
+		S: new(big.Int).SetBytes(raw[size:]),
+	})
+}
+
+// ECDSASigToRaw converts an ASN.1 DER encoded ECDSA signature to the raw form used
+// by JWS, for a signature made on curve. It is the inverse of ECDSASigToDER.
+func ECDSASigToRaw(der []byte, curve elliptic.Curve) ([]byte, error) {
+	var sig ecdsaSignature
+	rest, err := asn1.Unmarshal(der, &sig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ECDSA signature: %v", err)
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%d bytes of trailing data after ECDSA signature", len(rest))
+	}
+	size := ecdsaRawSize(curve)
+	for _, v := range []*big.Int{sig.R, sig.S} {
+		if v.Sign() < 0 || len(v.Bytes()) > size {
+			return nil, fmt.Errorf("ECDSA signature value does not fit in %d bytes for %s", size, curve.Params().Name)
+		}
+	}
+	// Each value is left-padded with zeros to the curve's size.
+	raw := make([]byte, 2*size)
+	r, s := sig.R.Bytes(), sig.S.Bytes()
+	copy(raw[size-len(r):size], r)
+	copy(raw[2*size-len(s):], s)
+	return raw, nil
+}
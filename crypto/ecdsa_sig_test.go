@@ -0,0 +1,143 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"testing"
+
+	"github.com/google/trillian/crypto/sigpb"
+)
+
+// signASN1 returns an ASN.1 DER encoded ECDSA signature of digest by key, like the
+// ecdsa.SignASN1 of newer Go versions.
+func signASN1(key *ecdsa.PrivateKey, digest []byte) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(ecdsaSignature{R: r, S: s})
+}
+
+func TestECDSASigRoundTrip(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatalf("ecdsa.GenerateKey()=%v", err)
+		}
+		digest := make([]byte, 32)
+		der, err := signASN1(key, digest)
+		if err != nil {
+			t.Fatalf("signASN1()=%v", err)
+		}
+		name := curve.Params().Name
+		raw, err := ECDSASigToRaw(der, curve)
+		if err != nil {
+			t.Fatalf("%v: ECDSASigToRaw()=%v", name, err)
+		}
+		if got, want := len(raw), 2*((curve.Params().BitSize+7)/8); got != want {
+			t.Errorf("%v: len(ECDSASigToRaw())=%d, want %d", name, got, want)
+		}
+		back, err := ECDSASigToDER(raw, curve)
+		if err != nil {
+			t.Fatalf("%v: ECDSASigToDER()=%v", name, err)
+		}
+		if !bytes.Equal(back, der) {
+			t.Errorf("%v: ECDSASigToDER(ECDSASigToRaw(%x))=%x, want original", name, der, back)
+		}
+	}
+}
+
+func TestECDSASigErrors(t *testing.T) {
+	curve := elliptic.P256()
+	for _, raw := range [][]byte{nil, make([]byte, 63), make([]byte, 65), make([]byte, 96)} {
+		if _, err := ECDSASigToDER(raw, curve); err == nil {
+			t.Errorf("ECDSASigToDER(%d bytes)=nil, want error", len(raw))
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	der, err := signASN1(key, make([]byte, 32))
+	if err != nil {
+		t.Fatalf("signASN1()=%v", err)
+	}
+	for _, test := range []struct {
+		desc string
+		der  []byte
+	}{
+		{desc: "empty", der: []byte{}},
+		{desc: "not ASN.1", der: []byte("signature")},
+		{desc: "trailing data", der: append(append([]byte{}, der...), 0)},
+		{desc: "too big for curve", der: der},
+	} {
+		if _, err := ECDSASigToRaw(test.der, curve); err == nil {
+			t.Errorf("%v: ECDSASigToRaw()=nil, want error", test.desc)
+		}
+	}
+}
+
+func TestVerifyRawECDSA(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	km, err := NewFromPrivateKey(key)
+	if err != nil {
+		t.Fatalf("NewFromPrivateKey()=%v", err)
+	}
+	msg := []byte("foo")
+	signed, err := NewSignerFromPrivateKeyManager(km).Sign(msg)
+	if err != nil {
+		t.Fatalf("Sign()=(_,%v), want (_,nil)", err)
+	}
+	raw, err := ECDSASigToRaw(signed.Signature, key.Curve)
+	if err != nil {
+		t.Fatalf("ECDSASigToRaw()=%v", err)
+	}
+	corrupt := append([]byte{}, raw...)
+	corrupt[len(corrupt)-1] ^= 1
+	withSig := func(b []byte) *sigpb.DigitallySigned {
+		return &sigpb.DigitallySigned{
+			SignatureAlgorithm: signed.SignatureAlgorithm,
+			HashAlgorithm:      signed.HashAlgorithm,
+			Signature:          b,
+		}
+	}
+
+	for _, test := range []struct {
+		desc    string
+		sig     []byte
+		opts    VerifyOptions
+		wantErr bool
+	}{
+		{desc: "DER", sig: signed.Signature},
+		{desc: "DER allow raw", sig: signed.Signature, opts: VerifyOptions{AllowRawECDSA: true}},
+		{desc: "raw", sig: raw, wantErr: true},
+		{desc: "raw allow raw", sig: raw, opts: VerifyOptions{AllowRawECDSA: true}},
+		{desc: "corrupt raw", sig: corrupt, opts: VerifyOptions{AllowRawECDSA: true}, wantErr: true},
+	} {
+		err := VerifyWithOptions(key.Public(), msg, withSig(test.sig), test.opts)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%v: VerifyWithOptions()=%v, want err? %v", test.desc, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypto
+
+import (
+	"crypto"
+	// Register SHA-1 with crypto.Hash; it is only used for leaf identity hashes.
+	_ "crypto/sha1"
+	"fmt"
+
+	"github.com/google/trillian/crypto/sigpb"
+)
+
+// LeafIdentityHasher returns the hash used to compute leaf identity hashes for a
+// tree with the given identity hash algorithm. NONE selects the default, SHA-256.
+// Besides the algorithms accepted for signatures, SHA-1 may be chosen so that a
+// log can match the identity hashes of a legacy system.
+func LeafIdentityHasher(alg sigpb.DigitallySigned_HashAlgorithm) (crypto.Hash, error) {
+	switch alg {
+	case sigpb.DigitallySigned_NONE:
+		return crypto.SHA256, nil
+	case sigpb.DigitallySigned_SHA1:
+		return crypto.SHA1, nil
+	}
+	h, err := hashFor(alg)
+	if err != nil {
+		return crypto.Hash(0), fmt.Errorf("leaf identity: %w", err)
+	}
+	return h, nil
+}
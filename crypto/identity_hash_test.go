@@ -0,0 +1,58 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypto
+
+import (
+	"crypto"
+	"errors"
+	"testing"
+
+	"github.com/google/trillian/crypto/sigpb"
+)
+
+func TestLeafIdentityHasher(t *testing.T) {
+	for _, test := range []struct {
+		alg     sigpb.DigitallySigned_HashAlgorithm
+		want    crypto.Hash
+		wantErr bool
+	}{
+		{alg: sigpb.DigitallySigned_NONE, want: crypto.SHA256},
+		{alg: sigpb.DigitallySigned_SHA1, want: crypto.SHA1},
+		{alg: sigpb.DigitallySigned_SHA256, want: crypto.SHA256},
+		{alg: sigpb.DigitallySigned_SHA512, want: crypto.SHA512},
+		{alg: sigpb.DigitallySigned_HashAlgorithm(99), wantErr: true},
+	} {
+		got, err := LeafIdentityHasher(test.alg)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("LeafIdentityHasher(%v)=%v,%v, want err? %v", test.alg, got, err, test.wantErr)
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, ErrUnsupportedAlgorithm) {
+				t.Errorf("LeafIdentityHasher(%v)=%v, want ErrUnsupportedAlgorithm", test.alg, err)
+			}
+			continue
+		}
+		if got != test.want {
+			t.Errorf("LeafIdentityHasher(%v)=%v, want %v", test.alg, got, test.want)
+		}
+	}
+}
+
+func TestSHA1NotAcceptedForSignatures(t *testing.T) {
+	if _, err := hashFor(sigpb.DigitallySigned_SHA1); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Errorf("hashFor(SHA1)=%v, want ErrUnsupportedAlgorithm", err)
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypto
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+)
+
+// KeyID returns an identifier for a public key: the SHA-256 hash of its DER encoded
+// SubjectPublicKeyInfo. The same key always has the same ID, however it was encoded
+// when it was loaded.
+func KeyID(pub crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %v", err)
+	}
+	id := sha256.Sum256(der)
+	return id[:], nil
+}
+
+// KeyIDHex returns the KeyID of a public key as a hex encoded string, for use in logs.
+func KeyIDHex(pub crypto.PublicKey) (string, error) {
+	id, err := KeyID(pub)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"testing"
+
+	"github.com/google/trillian/testonly"
+)
+
+func TestKeyID(t *testing.T) {
+	pemKey, err := PublicKeyFromPEM(testonly.DemoPublicKey)
+	if err != nil {
+		t.Fatalf("PublicKeyFromPEM()=%v", err)
+	}
+	block, _ := pem.Decode([]byte(testonly.DemoPublicKey))
+	if block == nil {
+		t.Fatal("pem.Decode() failed on DemoPublicKey")
+	}
+	derKey, err := PublicKeyFromDER(block.Bytes)
+	if err != nil {
+		t.Fatalf("PublicKeyFromDER()=%v", err)
+	}
+	want := sha256.Sum256(block.Bytes)
+
+	// The ID is the SPKI hash, whichever encoding the key was loaded from.
+	for _, test := range []struct {
+		desc string
+		key  interface{}
+	}{
+		{"PEM", pemKey},
+		{"DER", derKey},
+	} {
+		id, err := KeyID(test.key)
+		if err != nil {
+			t.Errorf("%v: KeyID()=(_,%v), want (_,nil)", test.desc, err)
+			continue
+		}
+		if !bytes.Equal(id, want[:]) {
+			t.Errorf("%v: KeyID()=%x, want %x", test.desc, id, want)
+		}
+		idHex, err := KeyIDHex(test.key)
+		if err != nil {
+			t.Errorf("%v: KeyIDHex()=(_,%v), want (_,nil)", test.desc, err)
+			continue
+		}
+		if got, want := idHex, hex.EncodeToString(want[:]); got != want {
+			t.Errorf("%v: KeyIDHex()=%v, want %v", test.desc, got, want)
+		}
+	}
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	otherID, err := KeyID(other.Public())
+	if err != nil {
+		t.Fatalf("KeyID()=(_,%v), want (_,nil)", err)
+	}
+	if bytes.Equal(otherID, want[:]) {
+		t.Errorf("KeyID() of distinct keys are both %x", otherID)
+	}
+
+	if _, err := KeyID("not a key"); err == nil {
+		t.Error("KeyID() of unsupported key=(_,nil), want error")
+	}
+}
@@ -17,6 +17,7 @@ package crypto
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -60,11 +61,50 @@ func NewFromPrivateKey(key crypto.PrivateKey) (PrivateKeyManager, error) {
 			Signer:             key,
 			signatureAlgorithm: sigpb.DigitallySigned_RSA,
 		}, nil
+	case ed25519.PrivateKey:
+		return &localSigner{
+			Signer:             key,
+			signatureAlgorithm: sigpb.DigitallySigned_ED25519,
+		}, nil
 	default:
 		return nil, fmt.Errorf("unsupported key type: %T", key)
 	}
 }
 
+// NewFromSigner creates a PrivateKeyManager from any crypto.Signer, such as a handle to a
+// key held in an HSM or a cloud KMS, so that the private key itself is never needed. The
+// signature algorithm is chosen to match the signer's public key.
+//
+// The signer will be called with a digest and crypto.SignerOpts naming the hash used (or
+// *rsa.PSSOptions for RSA_PSS). ECDSA signers must return ASN.1 DER encoded signatures, as
+// ecdsa.PrivateKey does, RSA signers PKCS #1 v1.5 signatures and Ed25519 signers raw
+// signatures over the message passed, which for Ed25519 is not hashed.
+func NewFromSigner(signer crypto.Signer) (PrivateKeyManager, error) {
+	sigAlgo, err := signatureAlgorithm(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &localSigner{
+		Signer:             signer,
+		signatureAlgorithm: sigAlgo,
+	}, nil
+}
+
+// signatureAlgorithm returns the algorithm used for signatures made with the private key
+// corresponding to pub.
+func signatureAlgorithm(pub crypto.PublicKey) (sigpb.DigitallySigned_SignatureAlgorithm, error) {
+	switch pub.(type) {
+	case *ecdsa.PublicKey:
+		return sigpb.DigitallySigned_ECDSA, nil
+	case *rsa.PublicKey:
+		return sigpb.DigitallySigned_RSA, nil
+	case ed25519.PublicKey:
+		return sigpb.DigitallySigned_ED25519, nil
+	default:
+		return sigpb.DigitallySigned_ANONYMOUS, fmt.Errorf("unsupported key type: %T", pub)
+	}
+}
+
 func parsePrivateKey(key []byte) (crypto.PrivateKey, error) {
 	key1, err1 := x509.ParsePKCS1PrivateKey(key)
 	if err1 == nil {
@@ -81,21 +121,65 @@ func parsePrivateKey(key []byte) (crypto.PrivateKey, error) {
 	return nil, fmt.Errorf("could not parse private key as PKCS1: %v, PKCS8: %v, or SEC1: %v", err1, err2, err3)
 }
 
-// NewFromPrivatePEM returns key manager for a PEM object which may be password protected.
-func NewFromPrivatePEM(pemBlock, password string) (PrivateKeyManager, error) {
+// decodePrivatePEM decodes the single PEM block in pemBlock, decrypting it with password
+// if that is not empty, and returns the block and its DER contents.
+func decodePrivatePEM(pemBlock, password string) (*pem.Block, []byte, error) {
 	block, rest := pem.Decode([]byte(pemBlock))
+	if block == nil {
+		return nil, nil, errors.New("no PEM block found")
+	}
 	if len(rest) > 0 {
-		return nil, errors.New("extra data found after PEM decoding")
+		return nil, nil, errors.New("extra data found after PEM decoding")
 	}
 
 	der := block.Bytes
 	if password != "" {
 		pwdDer, err := x509.DecryptPEMBlock(block, []byte(password))
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		der = pwdDer
 	}
+	return block, der, nil
+}
+
+// PrivateKeyFromPEM parses the PEM encoded private key in pemBlock, which may be a
+// PKCS #8 "PRIVATE KEY", a PKCS #1 "RSA PRIVATE KEY" or a SEC 1 "EC PRIVATE KEY"
+// block. If password is not empty the block is decrypted with it first, as for
+// legacy encrypted PEM. The key is returned as a crypto.Signer.
+func PrivateKeyFromPEM(pemBlock, password string) (crypto.Signer, error) {
+	block, der, err := decodePrivatePEM(pemBlock, password)
+	if err != nil {
+		return nil, err
+	}
+
+	var key crypto.PrivateKey
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(der)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(der)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(der)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %v", block.Type, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type: %T", key)
+	}
+	return signer, nil
+}
+
+// NewFromPrivatePEM returns key manager for a PEM object which may be password protected.
+func NewFromPrivatePEM(pemBlock, password string) (PrivateKeyManager, error) {
+	_, der, err := decodePrivatePEM(pemBlock, password)
+	if err != nil {
+		return nil, err
+	}
 
 	key, err := parsePrivateKey(der)
 	if err != nil {
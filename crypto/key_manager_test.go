@@ -17,14 +17,21 @@ package crypto
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
 	"encoding/asn1"
 	"encoding/pem"
 	"fmt"
+	"io"
 	"math/big"
 	"math/rand"
+	"reflect"
 	"testing"
 
+	"github.com/google/trillian/crypto/sigpb"
 	"github.com/google/trillian/testonly"
 )
 
@@ -73,3 +80,157 @@ func TestLoadDemoECDSAKeyAndSign(t *testing.T) {
 		t.Fatal("Signature did not verify on round trip test")
 	}
 }
+
+// remoteSigner stands in for a key held in an HSM or KMS. It exposes only the
+// crypto.Signer interface and records how it was called.
+type remoteSigner struct {
+	key    crypto.Signer
+	digest []byte
+	opts   crypto.SignerOpts
+}
+
+func (r *remoteSigner) Public() crypto.PublicKey {
+	return r.key.Public()
+}
+
+func (r *remoteSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	r.digest, r.opts = digest, opts
+	return r.key.Sign(rand, digest, opts)
+}
+
+func TestNewFromSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	remote := &remoteSigner{key: key}
+
+	km, err := NewFromSigner(remote)
+	if err != nil {
+		t.Fatalf("NewFromSigner()=(_,%v), want (_,nil)", err)
+	}
+	if got, want := km.SignatureAlgorithm(), sigpb.DigitallySigned_ECDSA; got != want {
+		t.Errorf("SignatureAlgorithm()=%v, want %v", got, want)
+	}
+
+	sig, err := NewSignerFromPrivateKeyManager(km).Sign([]byte("message"))
+	if err != nil {
+		t.Fatalf("Sign()=(_,%v), want (_,nil)", err)
+	}
+	if remote.opts == nil || remote.opts.HashFunc() != crypto.SHA256 {
+		t.Errorf("remote Sign() called with opts %v, want SHA256", remote.opts)
+	}
+	if got, want := len(remote.digest), crypto.SHA256.Size(); got != want {
+		t.Errorf("remote Sign() called with %d byte digest, want %d", got, want)
+	}
+	if err := Verify(key.Public(), []byte("message"), sig); err != nil {
+		t.Errorf("Verify()=%v, want nil", err)
+	}
+}
+
+func TestNewFromSignerUnsupportedKey(t *testing.T) {
+	if _, err := NewFromSigner(&remoteSigner{key: unsupportedSigner{}}); err == nil {
+		t.Errorf("NewFromSigner()=(_,nil), want (_,err) for an unsupported key type")
+	}
+}
+
+type unsupportedSigner struct{}
+
+func (unsupportedSigner) Public() crypto.PublicKey {
+	return "not a key"
+}
+
+func (unsupportedSigner) Sign(io.Reader, []byte, crypto.SignerOpts) ([]byte, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func TestPrivateKeyFromPEM(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	rsaKey, err := rsa.GenerateKey(cryptorand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(cryptorand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	sec1, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey()=(_,%v), want (_,nil)", err)
+	}
+	pkcs8 := func(key crypto.PrivateKey) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatalf("MarshalPKCS8PrivateKey()=(_,%v), want (_,nil)", err)
+		}
+		return der
+	}
+	demoPub, err := PublicKeyFromPEM(testonly.DemoPublicKey)
+	if err != nil {
+		t.Fatalf("PublicKeyFromPEM()=(_,%v), want (_,nil)", err)
+	}
+
+	for _, test := range []struct {
+		desc      string
+		blockType string
+		der       []byte
+		password  string
+		pem       string
+		pub       crypto.PublicKey
+	}{
+		{desc: "PKCS8 ECDSA", blockType: "PRIVATE KEY", der: pkcs8(ecKey), pub: ecKey.Public()},
+		{desc: "PKCS8 RSA", blockType: "PRIVATE KEY", der: pkcs8(rsaKey), pub: rsaKey.Public()},
+		{desc: "PKCS8 Ed25519", blockType: "PRIVATE KEY", der: pkcs8(edKey), pub: edKey.Public()},
+		{desc: "PKCS1 RSA", blockType: "RSA PRIVATE KEY", der: x509.MarshalPKCS1PrivateKey(rsaKey), pub: rsaKey.Public()},
+		{desc: "SEC1 EC", blockType: "EC PRIVATE KEY", der: sec1, pub: ecKey.Public()},
+		{desc: "encrypted SEC1 EC", pem: testonly.DemoPrivateKey, password: testonly.DemoPrivateKeyPass, pub: demoPub},
+	} {
+		pemBlock := test.pem
+		if pemBlock == "" {
+			pemBlock = string(pem.EncodeToMemory(&pem.Block{Type: test.blockType, Bytes: test.der}))
+		}
+		signer, err := PrivateKeyFromPEM(pemBlock, test.password)
+		if err != nil {
+			t.Errorf("%s: PrivateKeyFromPEM()=(_,%v), want (_,nil)", test.desc, err)
+			continue
+		}
+		if pub := signer.Public(); !reflect.DeepEqual(pub, test.pub) {
+			t.Errorf("%s: PrivateKeyFromPEM().Public()=%v, want %v", test.desc, signer.Public(), test.pub)
+		}
+	}
+}
+
+func TestPrivateKeyFromPEMErrors(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	sec1, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey()=(_,%v), want (_,nil)", err)
+	}
+	encode := func(blockType string, der []byte) string {
+		return string(pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}))
+	}
+
+	for _, test := range []struct {
+		desc     string
+		pem      string
+		password string
+	}{
+		{desc: "wrong password", pem: testonly.DemoPrivateKey, password: "wrong"},
+		{desc: "missing password", pem: testonly.DemoPrivateKey},
+		{desc: "no PEM block", pem: "not a key"},
+		{desc: "trailing data", pem: encode("EC PRIVATE KEY", sec1) + "extra"},
+		{desc: "wrong block type for contents", pem: encode("RSA PRIVATE KEY", sec1)},
+		{desc: "public key", pem: testonly.DemoPublicKey},
+		{desc: "unknown block type", pem: encode("DSA PRIVATE KEY", sec1)},
+	} {
+		if _, err := PrivateKeyFromPEM(test.pem, test.password); err == nil {
+			t.Errorf("%s: PrivateKeyFromPEM()=(_,nil), want (_,err)", test.desc)
+		}
+	}
+}
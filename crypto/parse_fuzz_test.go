@@ -0,0 +1,96 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Fuzz tests need Go 1.18, newer than the Go version the rest of the tree builds with.
+
+//go:build go1.18
+// +build go1.18
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/google/trillian/testonly"
+)
+
+// fuzzSeedDERs returns DER encoded public keys of each supported type, with truncated
+// and some malformed copies of them, to seed the fuzzers.
+func fuzzSeedDERs(f *testing.F) [][]byte {
+	block, _ := pem.Decode([]byte(testonly.DemoPublicKey))
+	if block == nil {
+		f.Fatal("pem.Decode() failed on DemoPublicKey")
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		f.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		f.Fatalf("ed25519.GenerateKey()=%v", err)
+	}
+
+	ders := [][]byte{block.Bytes}
+	for _, pub := range []interface{}{ecdsaKey.Public(), edPub} {
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			f.Fatalf("MarshalPKIXPublicKey()=%v", err)
+		}
+		ders = append(ders, der)
+	}
+	for _, der := range ders {
+		ders = append(ders, der[:len(der)/2], der[:len(der)-1])
+	}
+	return append(ders, nil, []byte("not a key"))
+}
+
+// checkParseResult fails the test unless exactly one of pub and err is nil.
+func checkParseResult(t *testing.T, name string, pub interface{}, err error) {
+	t.Helper()
+	if (pub == nil) == (err == nil) {
+		t.Errorf("%s()=(%v, %v), want exactly one of them nil", name, pub, err)
+	}
+}
+
+func FuzzPublicKeyFromPEM(f *testing.F) {
+	for _, der := range fuzzSeedDERs(f) {
+		p := pem.EncodeToMemory(&pem.Block{Type: pemPublicKeyType, Bytes: der})
+		f.Add(p)
+		f.Add(p[:len(p)/2])
+	}
+	f.Add([]byte(testonly.DemoPublicKey + "\ntrailing"))
+	f.Add([]byte("-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pub, err := PublicKeyFromPEM(string(data))
+		checkParseResult(t, "PublicKeyFromPEM", pub, err)
+	})
+}
+
+func FuzzPublicKeyFromDER(f *testing.F) {
+	for _, der := range fuzzSeedDERs(f) {
+		f.Add(der)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pub, err := PublicKeyFromDER(data)
+		checkParseResult(t, "PublicKeyFromDER", pub, err)
+	})
+}
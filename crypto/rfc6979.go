@@ -0,0 +1,101 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+)
+
+// signECDSADeterministic signs digest with priv using a nonce derived from the key and
+// digest as described in RFC 6979, so that signing the same digest with the same key
+// always gives the same signature. hash is the hash function that produced digest and
+// is also used for the nonce derivation. The signature is ASN.1 encoded, as produced by
+// ecdsa.PrivateKey.Sign.
+func signECDSADeterministic(priv *ecdsa.PrivateKey, hash crypto.Hash, digest []byte) ([]byte, error) {
+	if !hash.Available() {
+		return nil, errors.New("deterministic ECDSA requires a hash")
+	}
+	n := priv.Curve.Params().N
+	qlen := n.BitLen()
+	rlen := (qlen + 7) / 8
+
+	// bits2int and int2octets are as defined in RFC 6979 section 2.3.
+	bits2int := func(b []byte) *big.Int {
+		v := new(big.Int).SetBytes(b)
+		if excess := len(b)*8 - qlen; excess > 0 {
+			v.Rsh(v, uint(excess))
+		}
+		return v
+	}
+	int2octets := func(v *big.Int) []byte {
+		out := make([]byte, rlen)
+		b := v.Bytes()
+		copy(out[rlen-len(b):], b)
+		return out
+	}
+	mac := func(key []byte, data ...[]byte) []byte {
+		m := hmac.New(hash.New, key)
+		for _, d := range data {
+			m.Write(d)
+		}
+		return m.Sum(nil)
+	}
+
+	e := bits2int(digest)
+	h1 := new(big.Int).Mod(e, n)
+	x := int2octets(priv.D)
+	h := int2octets(h1)
+
+	// Section 3.2, steps b to g.
+	v := make([]byte, hash.Size())
+	for i := range v {
+		v[i] = 0x01
+	}
+	k := make([]byte, hash.Size())
+	k = mac(k, v, []byte{0x00}, x, h)
+	v = mac(k, v)
+	k = mac(k, v, []byte{0x01}, x, h)
+	v = mac(k, v)
+
+	// Step h: generate candidate nonces until one gives a valid signature.
+	for {
+		var t []byte
+		for len(t) < rlen {
+			v = mac(k, v)
+			t = append(t, v...)
+		}
+		nonce := bits2int(t[:rlen])
+		if nonce.Sign() > 0 && nonce.Cmp(n) < 0 {
+			r, _ := priv.Curve.ScalarBaseMult(int2octets(nonce))
+			r.Mod(r, n)
+			if r.Sign() != 0 {
+				s := new(big.Int).Mul(r, priv.D)
+				s.Add(s, e)
+				s.Mul(s, new(big.Int).ModInverse(nonce, n))
+				s.Mod(s, n)
+				if s.Sign() != 0 {
+					return asn1.Marshal(struct{ R, S *big.Int }{r, s})
+				}
+			}
+		}
+		k = mac(k, v, []byte{0x00})
+		v = mac(k, v)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian/crypto/sigpb"
+)
+
+func mustHexInt(t *testing.T, s string) *big.Int {
+	t.Helper()
+	v, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		t.Fatalf("invalid hex integer %q", s)
+	}
+	return v
+}
+
+// TestSignECDSADeterministicVector checks the P-256 with SHA-256 example from RFC 6979 A.2.5.
+func TestSignECDSADeterministicVector(t *testing.T) {
+	priv := &ecdsa.PrivateKey{D: mustHexInt(t, "C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721")}
+	priv.Curve = elliptic.P256()
+	priv.X, priv.Y = priv.Curve.ScalarBaseMult(priv.D.Bytes())
+
+	digest := sha256.Sum256([]byte("sample"))
+	sig, err := signECDSADeterministic(priv, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("signECDSADeterministic()=(_,%v), want (_,nil)", err)
+	}
+	var got struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(sig, &got); err != nil {
+		t.Fatalf("asn1.Unmarshal(sig)=%v, want nil", err)
+	}
+	if want := mustHexInt(t, "EFD48B2AACB6A8FD1140DD9CD45E81D69D2C877B56AAF991C34D0EA84EAF3716"); got.R.Cmp(want) != 0 {
+		t.Errorf("signECDSADeterministic() r=%X, want %X", got.R, want)
+	}
+	if want := mustHexInt(t, "F7CB1C942D657C41D436C7A1B6E29F65F3E900DBB9AFF4064DC4AB2F843ACDA8"); got.S.Cmp(want) != 0 {
+		t.Errorf("signECDSADeterministic() s=%X, want %X", got.S, want)
+	}
+}
+
+func TestSignerDeterministic(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	km, err := NewFromPrivateKey(key)
+	if err != nil {
+		t.Fatalf("NewFromPrivateKey()=(_,%v), want (_,nil)", err)
+	}
+	signer := NewSignerFromPrivateKeyManager(km)
+	signer.SetDeterministic(true)
+
+	sig1, err := signer.Sign([]byte(message))
+	if err != nil {
+		t.Fatalf("Sign()=(_,%v), want (_,nil)", err)
+	}
+	sig2, err := signer.Sign([]byte(message))
+	if err != nil {
+		t.Fatalf("Sign()=(_,%v), want (_,nil)", err)
+	}
+	if !bytes.Equal(sig1.Signature, sig2.Signature) {
+		t.Errorf("Sign() gave %s then %s, want identical signatures", hex.EncodeToString(sig1.Signature), hex.EncodeToString(sig2.Signature))
+	}
+	if err := Verify(key.Public(), []byte(message), sig1); err != nil {
+		t.Errorf("Verify(Sign())=%v, want nil", err)
+	}
+
+	other, err := signer.Sign([]byte("other"))
+	if err != nil {
+		t.Fatalf("Sign()=(_,%v), want (_,nil)", err)
+	}
+	if bytes.Equal(sig1.Signature, other.Signature) {
+		t.Errorf("Sign() gave the same signature for different messages")
+	}
+}
+
+func TestSignerDeterministicRequiresECDSA(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	signer := NewSigner(sigpb.DigitallySigned_ECDSA, NewMockPrivateKeyManager(ctrl))
+	signer.SetDeterministic(true)
+	if _, err := signer.Sign([]byte(message)); err == nil {
+		t.Errorf("Sign()=(_,nil), want (_,err) for a signer without an ECDSA private key")
+	}
+}
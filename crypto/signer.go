@@ -16,15 +16,20 @@ package crypto
 
 import (
 	"crypto"
+	"crypto/ecdsa"
 	"crypto/rand"
-	"encoding/json"
+	"crypto/rsa"
+	"errors"
+	"fmt"
 
-	"github.com/benlaurie/objecthash/go/objecthash"
 	"github.com/google/trillian/crypto/sigpb"
 )
 
 var sigpbHashLookup = map[crypto.Hash]sigpb.DigitallySigned_HashAlgorithm{
-	crypto.SHA256: sigpb.DigitallySigned_SHA256,
+	crypto.SHA256:   sigpb.DigitallySigned_SHA256,
+	crypto.SHA384:   sigpb.DigitallySigned_SHA384,
+	crypto.SHA512:   sigpb.DigitallySigned_SHA512,
+	crypto.SHA3_256: sigpb.DigitallySigned_SHA3_256,
 }
 
 // Signer is responsible for signing log-related data and producing the appropriate
@@ -33,32 +38,106 @@ type Signer struct {
 	hash         crypto.Hash
 	signer       crypto.Signer
 	sigAlgorithm sigpb.DigitallySigned_SignatureAlgorithm
+	// deterministic selects RFC 6979 nonces for ECDSA signatures.
+	deterministic bool
 }
 
 // NewSigner creates a new Signer wrapping up a hasher and a signer. For the moment
-// we only support SHA256 hashing and either ECDSA or RSA signing but this is not enforced
-// here.
+// we only support SHA256 hashing with ECDSA or RSA signing but this is not enforced
+// here. Ed25519 signers sign the data directly, without hashing it first, and RSA_PSS
+// signers use RSASSA-PSS with a salt as long as the hash.
 func NewSigner(sigAlgo sigpb.DigitallySigned_SignatureAlgorithm, signer crypto.Signer) *Signer {
+	hash := crypto.SHA256
+	if sigAlgo == sigpb.DigitallySigned_ED25519 {
+		hash = crypto.Hash(0)
+	}
 	return &Signer{
-		hash:         crypto.SHA256,
+		hash:         hash,
 		signer:       signer,
 		sigAlgorithm: sigAlgo,
 	}
 }
 
+// NewSignerWithHash creates a new Signer that hashes data with hash before signing it
+// with signer. The signature algorithm is chosen to match the type of signer's public
+// key. Ed25519 keys sign data directly, so hash must be zero for them.
+func NewSignerWithHash(signer crypto.Signer, hash crypto.Hash) (*Signer, error) {
+	sigAlgo, err := signatureAlgorithm(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	if sigAlgo == sigpb.DigitallySigned_ED25519 {
+		if hash != crypto.Hash(0) {
+			return nil, fmt.Errorf("Ed25519 keys can't be used with hash %v", hash)
+		}
+		return &Signer{signer: signer, sigAlgorithm: sigAlgo}, nil
+	}
+	if _, ok := sigpbHashLookup[hash]; !ok {
+		return nil, fmt.Errorf("unsupported hash: %v", hash)
+	}
+	return &Signer{
+		hash:         hash,
+		signer:       signer,
+		sigAlgorithm: sigAlgo,
+	}, nil
+}
+
+// NewSignerFromPEM creates a Signer for the unencrypted, PEM encoded private key in
+// pemBlock, which may hold a PKCS #1 RSA key, a SEC 1 EC key or a PKCS #8 RSA, ECDSA
+// or Ed25519 key. RSA and ECDSA keys sign SHA256 digests, and Ed25519 keys sign data
+// directly.
+func NewSignerFromPEM(pemBlock string) (*Signer, error) {
+	key, err := NewFromPrivatePEM(pemBlock, "")
+	if err != nil {
+		return nil, err
+	}
+	hash := crypto.SHA256
+	if key.SignatureAlgorithm() == sigpb.DigitallySigned_ED25519 {
+		hash = crypto.Hash(0)
+	}
+	return NewSignerWithHash(key, hash)
+}
+
+// SetDeterministic controls whether ECDSA signatures use a nonce derived from the key and
+// message as described in RFC 6979, rather than a random one. Deterministic signatures of
+// the same data with the same key are identical. This requires the Signer to hold an
+// in-memory *ecdsa.PrivateKey, otherwise Sign will fail.
+func (s *Signer) SetDeterministic(deterministic bool) {
+	s.deterministic = deterministic
+}
+
 // NewSignerFromPrivateKeyManager creates a new Signer wrapping up a hasher and a private key.
 // For the moment, we only support SHA256 hashing and either ECDSA or RSA signing but this is not enforced here.
 func NewSignerFromPrivateKeyManager(key PrivateKeyManager) *Signer {
 	return NewSigner(key.SignatureAlgorithm(), key)
 }
 
-// Sign obtains a signature after first hashing the input data.
+// Sign obtains a signature after first hashing the input data. If the Signer
+// has no hash (as for Ed25519) the input data is signed as is.
 func (s *Signer) Sign(data []byte) (*sigpb.DigitallySigned, error) {
-	h := s.hash.New()
-	h.Write(data)
-	digest := h.Sum(nil)
+	digest := data
+	if s.hash != crypto.Hash(0) {
+		h := s.hash.New()
+		h.Write(data)
+		digest = h.Sum(nil)
+	}
 
-	sig, err := s.signer.Sign(rand.Reader, digest, s.hash)
+	var opts crypto.SignerOpts = s.hash
+	if s.sigAlgorithm == sigpb.DigitallySigned_RSA_PSS {
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: s.hash}
+	}
+
+	var sig []byte
+	var err error
+	if s.deterministic {
+		key, ok := ecdsaPrivateKey(s.signer)
+		if !ok {
+			return nil, errors.New("deterministic signing requires an ECDSA private key")
+		}
+		sig, err = signECDSADeterministic(key, s.hash, digest)
+	} else {
+		sig, err = s.signer.Sign(rand.Reader, digest, opts)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -70,12 +149,22 @@ func (s *Signer) Sign(data []byte) (*sigpb.DigitallySigned, error) {
 	}, nil
 }
 
+// ecdsaPrivateKey returns the ECDSA private key held by signer, if there is one.
+func ecdsaPrivateKey(signer crypto.Signer) (*ecdsa.PrivateKey, bool) {
+	switch k := signer.(type) {
+	case *ecdsa.PrivateKey:
+		return k, true
+	case *localSigner:
+		return ecdsaPrivateKey(k.Signer)
+	}
+	return nil, false
+}
+
 // SignObject signs the requested object using ObjectHash.
 func (s *Signer) SignObject(obj interface{}) (*sigpb.DigitallySigned, error) {
-	j, err := json.Marshal(obj)
+	hash, err := objectHashJSON(obj)
 	if err != nil {
 		return nil, err
 	}
-	hash := objecthash.CommonJSONHash(string(j))
-	return s.Sign(hash[:])
+	return s.Sign(hash)
 }
@@ -16,8 +16,16 @@ package crypto
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -121,3 +129,181 @@ func TestSignLogRootSignerFails(t *testing.T) {
 
 	testonly.EnsureErrorContains(t, err, "signfail")
 }
+
+func TestNewSignerWithHash(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+
+	for _, test := range []struct {
+		desc     string
+		key      crypto.Signer
+		hash     crypto.Hash
+		wantHash sigpb.DigitallySigned_HashAlgorithm
+		wantAlgo sigpb.DigitallySigned_SignatureAlgorithm
+	}{
+		{desc: "ECDSA", key: ecKey, hash: crypto.SHA384, wantHash: sigpb.DigitallySigned_SHA384, wantAlgo: sigpb.DigitallySigned_ECDSA},
+		{desc: "RSA", key: rsaKey, hash: crypto.SHA512, wantHash: sigpb.DigitallySigned_SHA512, wantAlgo: sigpb.DigitallySigned_RSA},
+		{desc: "ECDSA SHA3", key: ecKey, hash: crypto.SHA3_256, wantHash: sigpb.DigitallySigned_SHA3_256, wantAlgo: sigpb.DigitallySigned_ECDSA},
+		{desc: "RSA SHA3", key: rsaKey, hash: crypto.SHA3_256, wantHash: sigpb.DigitallySigned_SHA3_256, wantAlgo: sigpb.DigitallySigned_RSA},
+		{desc: "Ed25519", key: edKey, wantHash: sigpb.DigitallySigned_NONE, wantAlgo: sigpb.DigitallySigned_ED25519},
+	} {
+		signer, err := NewSignerWithHash(test.key, test.hash)
+		if err != nil {
+			t.Errorf("%s: NewSignerWithHash()=(_,%v), want (_,nil)", test.desc, err)
+			continue
+		}
+
+		sig, err := signer.Sign([]byte(message))
+		if err != nil {
+			t.Errorf("%s: Sign()=(_,%v), want (_,nil)", test.desc, err)
+			continue
+		}
+		if got := sig.HashAlgorithm; got != test.wantHash {
+			t.Errorf("%s: Sign().HashAlgorithm=%v, want %v", test.desc, got, test.wantHash)
+		}
+		if got := sig.SignatureAlgorithm; got != test.wantAlgo {
+			t.Errorf("%s: Sign().SignatureAlgorithm=%v, want %v", test.desc, got, test.wantAlgo)
+		}
+		if err := Verify(test.key.Public(), []byte(message), sig); err != nil {
+			t.Errorf("%s: Verify(Sign())=%v, want nil", test.desc, err)
+		}
+
+		obj := map[string]interface{}{"size": 2, "root": "Islington"}
+		objSig, err := signer.SignObject(obj)
+		if err != nil {
+			t.Errorf("%s: SignObject()=(_,%v), want (_,nil)", test.desc, err)
+			continue
+		}
+		if err := VerifyObject(test.key.Public(), obj, objSig); err != nil {
+			t.Errorf("%s: VerifyObject(SignObject())=%v, want nil", test.desc, err)
+		}
+	}
+}
+
+func TestNewSignerWithHashErrors(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+
+	for _, test := range []struct {
+		desc string
+		key  crypto.Signer
+		hash crypto.Hash
+	}{
+		{desc: "unsupported hash", key: ecKey, hash: crypto.MD5},
+		{desc: "no hash", key: ecKey},
+		{desc: "Ed25519 with hash", key: edKey, hash: crypto.SHA256},
+	} {
+		if _, err := NewSignerWithHash(test.key, test.hash); err == nil {
+			t.Errorf("%s: NewSignerWithHash()=(_,nil), want (_,err)", test.desc)
+		}
+	}
+}
+
+func TestNewSignerFromPEM(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	encode := func(blockType string, der []byte, err error) string {
+		if err != nil {
+			t.Fatalf("%s: marshal failed: %v", blockType, err)
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}))
+	}
+	pkcs8 := func(key crypto.PrivateKey) string {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		return encode("PRIVATE KEY", der, err)
+	}
+	sec1, err := x509.MarshalECPrivateKey(ecKey)
+	ecPEM := encode("EC PRIVATE KEY", sec1, err)
+
+	for _, test := range []struct {
+		desc     string
+		pem      string
+		pub      crypto.PublicKey
+		wantHash sigpb.DigitallySigned_HashAlgorithm
+		wantAlgo sigpb.DigitallySigned_SignatureAlgorithm
+	}{
+		{desc: "PKCS1 RSA", pem: encode("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey), nil), pub: rsaKey.Public(), wantHash: sigpb.DigitallySigned_SHA256, wantAlgo: sigpb.DigitallySigned_RSA},
+		{desc: "PKCS8 RSA", pem: pkcs8(rsaKey), pub: rsaKey.Public(), wantHash: sigpb.DigitallySigned_SHA256, wantAlgo: sigpb.DigitallySigned_RSA},
+		{desc: "SEC1 ECDSA", pem: ecPEM, pub: ecKey.Public(), wantHash: sigpb.DigitallySigned_SHA256, wantAlgo: sigpb.DigitallySigned_ECDSA},
+		{desc: "PKCS8 ECDSA", pem: pkcs8(ecKey), pub: ecKey.Public(), wantHash: sigpb.DigitallySigned_SHA256, wantAlgo: sigpb.DigitallySigned_ECDSA},
+		{desc: "PKCS8 Ed25519", pem: pkcs8(edKey), pub: edPub, wantHash: sigpb.DigitallySigned_NONE, wantAlgo: sigpb.DigitallySigned_ED25519},
+	} {
+		signer, err := NewSignerFromPEM(test.pem)
+		if err != nil {
+			t.Errorf("%s: NewSignerFromPEM()=(_,%v), want (_,nil)", test.desc, err)
+			continue
+		}
+		sig, err := signer.Sign([]byte(message))
+		if err != nil {
+			t.Errorf("%s: Sign()=(_,%v), want (_,nil)", test.desc, err)
+			continue
+		}
+		if got := sig.HashAlgorithm; got != test.wantHash {
+			t.Errorf("%s: Sign().HashAlgorithm=%v, want %v", test.desc, got, test.wantHash)
+		}
+		if got := sig.SignatureAlgorithm; got != test.wantAlgo {
+			t.Errorf("%s: Sign().SignatureAlgorithm=%v, want %v", test.desc, got, test.wantAlgo)
+		}
+		if err := Verify(test.pub, []byte(message), sig); err != nil {
+			t.Errorf("%s: Verify(Sign())=%v, want nil", test.desc, err)
+		}
+	}
+}
+
+func TestNewSignerFromPEMErrors(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	der, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey()=(_,%v), want (_,nil)", err)
+	}
+	valid := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	pubDER, err := x509.MarshalPKIXPublicKey(ecKey.Public())
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey()=(_,%v), want (_,nil)", err)
+	}
+
+	for _, test := range []struct {
+		desc string
+		pem  string
+	}{
+		{desc: "empty", pem: ""},
+		{desc: "not PEM", pem: "not a key"},
+		{desc: "trailing data", pem: valid + "extra"},
+		{desc: "public key", pem: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))},
+		{desc: "corrupt key", pem: string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der[:len(der)/2]}))},
+		{desc: "whitespace only", pem: strings.Repeat(" ", 8)},
+	} {
+		if _, err := NewSignerFromPEM(test.pem); err == nil {
+			t.Errorf("%s: NewSignerFromPEM()=(_,nil), want (_,err)", test.desc)
+		}
+	}
+}
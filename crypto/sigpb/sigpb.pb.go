@@ -38,17 +38,36 @@ type DigitallySigned_HashAlgorithm int32
 const (
 	// No hash algorithm is used.
 	DigitallySigned_NONE DigitallySigned_HashAlgorithm = 0
+	// SHA1 is used. It is only accepted for leaf identity hashes, where a log
+	// must match the identity hashing of a legacy system; it is never accepted
+	// for signatures.
+	DigitallySigned_SHA1 DigitallySigned_HashAlgorithm = 2
 	// SHA256 is used.
 	DigitallySigned_SHA256 DigitallySigned_HashAlgorithm = 4
+	// SHA384 is used.
+	DigitallySigned_SHA384 DigitallySigned_HashAlgorithm = 5
+	// SHA512 is used.
+	DigitallySigned_SHA512 DigitallySigned_HashAlgorithm = 6
+	// SHA3-256 is used. This uses a value from the private use range as TLS 1.2
+	// does not define one for SHA3.
+	DigitallySigned_SHA3_256 DigitallySigned_HashAlgorithm = 224
 )
 
 var DigitallySigned_HashAlgorithm_name = map[int32]string{
-	0: "NONE",
-	4: "SHA256",
+	0:   "NONE",
+	2:   "SHA1",
+	4:   "SHA256",
+	5:   "SHA384",
+	6:   "SHA512",
+	224: "SHA3_256",
 }
 var DigitallySigned_HashAlgorithm_value = map[string]int32{
-	"NONE":   0,
-	"SHA256": 4,
+	"NONE":     0,
+	"SHA1":     2,
+	"SHA256":   4,
+	"SHA384":   5,
+	"SHA512":   6,
+	"SHA3_256": 224,
 }
 
 func (x DigitallySigned_HashAlgorithm) String() string {
@@ -72,17 +91,27 @@ const (
 	DigitallySigned_RSA DigitallySigned_SignatureAlgorithm = 1
 	// ECDSA signature scheme.
 	DigitallySigned_ECDSA DigitallySigned_SignatureAlgorithm = 3
+	// Ed25519 signature scheme, from RFC 8422. The message is signed directly
+	// so no hash algorithm is used.
+	DigitallySigned_ED25519 DigitallySigned_SignatureAlgorithm = 7
+	// RSASSA-PSS signature scheme, from RFC 8017. This uses a value from the
+	// private use range as TLS 1.2 does not define one for PSS.
+	DigitallySigned_RSA_PSS DigitallySigned_SignatureAlgorithm = 224
 )
 
 var DigitallySigned_SignatureAlgorithm_name = map[int32]string{
-	0: "ANONYMOUS",
-	1: "RSA",
-	3: "ECDSA",
+	0:   "ANONYMOUS",
+	1:   "RSA",
+	3:   "ECDSA",
+	7:   "ED25519",
+	224: "RSA_PSS",
 }
 var DigitallySigned_SignatureAlgorithm_value = map[string]int32{
 	"ANONYMOUS": 0,
 	"RSA":       1,
 	"ECDSA":     3,
+	"ED25519":   7,
+	"RSA_PSS":   224,
 }
 
 func (x DigitallySigned_SignatureAlgorithm) String() string {
@@ -137,20 +166,23 @@ func init() {
 func init() { proto.RegisterFile("sigpb.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 226 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xe2, 0xe2, 0x2e, 0xce, 0x4c, 0x2f,
-	0x48, 0xd2, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x05, 0x73, 0x94, 0x76, 0x30, 0x71, 0xf1,
-	0xbb, 0x64, 0xa6, 0x67, 0x96, 0x24, 0xe6, 0xe4, 0x54, 0x06, 0x67, 0xa6, 0xe7, 0xa5, 0xa6, 0x08,
-	0x79, 0x73, 0xf1, 0x65, 0x24, 0x16, 0x67, 0xc4, 0x27, 0xe6, 0xa4, 0xe7, 0x17, 0x65, 0x96, 0x64,
-	0xe4, 0x4a, 0x30, 0x2a, 0x30, 0x6a, 0xf0, 0x19, 0xa9, 0xe8, 0x41, 0x0c, 0x40, 0x53, 0xaf, 0xe7,
-	0x91, 0x58, 0x9c, 0xe1, 0x08, 0x53, 0x1b, 0xc4, 0x9b, 0x81, 0xcc, 0x15, 0x8a, 0xe2, 0x12, 0x2e,
-	0xce, 0x4c, 0xcf, 0x4b, 0x2c, 0x29, 0x2d, 0x4a, 0x45, 0x32, 0x91, 0x09, 0x6c, 0xa2, 0x26, 0x0e,
-	0x13, 0x83, 0x61, 0x3a, 0x10, 0xc6, 0x0a, 0x15, 0x63, 0x88, 0x09, 0xc9, 0x70, 0x71, 0xc2, 0x45,
-	0x25, 0x98, 0x15, 0x18, 0x35, 0x78, 0x82, 0x10, 0x02, 0x4a, 0xaa, 0x5c, 0xbc, 0x28, 0x2e, 0x13,
-	0xe2, 0xe0, 0x62, 0xf1, 0xf3, 0xf7, 0x73, 0x15, 0x60, 0x10, 0xe2, 0xe2, 0x62, 0x0b, 0xf6, 0x70,
-	0x34, 0x32, 0x35, 0x13, 0x60, 0x51, 0x32, 0xe7, 0x12, 0xc2, 0xb4, 0x4e, 0x88, 0x97, 0x8b, 0xd3,
-	0xd1, 0xcf, 0xdf, 0x2f, 0xd2, 0xd7, 0x3f, 0x34, 0x58, 0x80, 0x41, 0x88, 0x9d, 0x8b, 0x39, 0x28,
-	0xd8, 0x51, 0x80, 0x51, 0x88, 0x93, 0x8b, 0xd5, 0xd5, 0xd9, 0x25, 0xd8, 0x51, 0x80, 0x39, 0x89,
-	0x0d, 0x1c, 0x90, 0xc6, 0x80, 0x00, 0x00, 0x00, 0xff, 0xff, 0x6f, 0x3a, 0x4c, 0x9a, 0x57, 0x01,
-	0x00, 0x00,
+	// 279 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x91, 0x31, 0x4b, 0xc3, 0x40,
+	0x18, 0x86, 0x7b, 0x4d, 0x9b, 0x34, 0x5f, 0x9b, 0x7a, 0x7c, 0x2e, 0x1d, 0x1c, 0x4a, 0x70, 0xa8,
+	0x4b, 0x20, 0xa9, 0x11, 0x1d, 0x0f, 0x13, 0x08, 0x88, 0x89, 0xe4, 0x50, 0xd0, 0x25, 0xa4, 0x58,
+	0x92, 0x83, 0xd8, 0x94, 0x5c, 0x1c, 0xdc, 0xfd, 0xa1, 0xfd, 0x29, 0x92, 0xd8, 0xda, 0x6a, 0x71,
+	0x7b, 0xbf, 0x87, 0xf7, 0x1e, 0x5e, 0x38, 0x18, 0x4a, 0x91, 0xad, 0x17, 0xd6, 0xba, 0x2a, 0xeb,
+	0x12, 0xfb, 0xed, 0x61, 0x7e, 0x2a, 0x70, 0xe2, 0x89, 0x4c, 0xd4, 0x69, 0x51, 0x7c, 0x70, 0x91,
+	0xad, 0x96, 0xaf, 0x78, 0x07, 0xe3, 0x3c, 0x95, 0x79, 0x92, 0x16, 0x59, 0x59, 0x89, 0x3a, 0x7f,
+	0x9b, 0x90, 0x29, 0x99, 0x8d, 0x9d, 0x73, 0xeb, 0x5b, 0xf0, 0xa7, 0x6f, 0x05, 0xa9, 0xcc, 0xd9,
+	0xae, 0x1b, 0x1b, 0xf9, 0xe1, 0x89, 0x2f, 0x70, 0x2a, 0x45, 0xb6, 0x4a, 0xeb, 0xf7, 0x6a, 0x79,
+	0x60, 0xec, 0xb6, 0xc6, 0x8b, 0x7f, 0x8c, 0x7c, 0xf7, 0x62, 0xaf, 0x45, 0x79, 0xc4, 0xf0, 0x0c,
+	0xf4, 0x1f, 0x3a, 0x51, 0xa6, 0x64, 0x36, 0x8a, 0xf7, 0xc0, 0x7c, 0x02, 0xe3, 0xd7, 0x32, 0x1c,
+	0x40, 0x2f, 0x8c, 0x42, 0x9f, 0x76, 0x9a, 0xc4, 0x03, 0x66, 0xd3, 0x2e, 0x02, 0xa8, 0x3c, 0x60,
+	0x8e, 0x7b, 0x45, 0x7b, 0xdb, 0x3c, 0xbf, 0xbe, 0xa4, 0xfd, 0x6d, 0x76, 0x6d, 0x87, 0xaa, 0x68,
+	0xc0, 0xa0, 0xe1, 0x49, 0xd3, 0xda, 0x10, 0x33, 0x06, 0x3c, 0xde, 0x87, 0x06, 0xe8, 0x2c, 0x8c,
+	0xc2, 0xe7, 0xfb, 0xe8, 0x91, 0xd3, 0x0e, 0x6a, 0xa0, 0xc4, 0x9c, 0x51, 0x82, 0x3a, 0xf4, 0xfd,
+	0x5b, 0x8f, 0x33, 0xaa, 0xe0, 0x10, 0x34, 0xdf, 0x73, 0x5c, 0xd7, 0xbe, 0xa1, 0x1a, 0x8e, 0x40,
+	0x8b, 0x39, 0x4b, 0x1e, 0x38, 0xa7, 0x1b, 0xb2, 0x50, 0xdb, 0x4f, 0x99, 0x7f, 0x0d, 0x00, 0x96,
+	0x8c, 0x31, 0x64, 0xa3, 0x01, 0x00, 0x00,
 }
@@ -0,0 +1,84 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package crypto
+
+import (
+	gocrypto "crypto"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/sigpb"
+)
+
+// STHVersion identifies the format of an STH. It is part of the data signed for the
+// STH, so a signature over an STH of one version never verifies as another version.
+type STHVersion string
+
+// STHVersion1 is the version of the STHs signed by the sequencer.
+const STHVersion1 STHVersion = "trillian/log/sth/v1"
+
+// STHVersionLegacy stands for the unversioned STHs signed before STHVersion1, whose
+// signatures cover the HashLogRoot of the root. Verifiers should only ask for it while a
+// log still serves roots signed before the upgrade; running the log signer once with
+// --resign replaces those roots with STHVersion1 ones.
+const STHVersionLegacy STHVersion = "trillian/log/sth/legacy"
+
+// ErrSTHVersionMismatch is returned by VerifySTH for an STH that does not have the
+// expected version.
+var ErrSTHVersionMismatch = errors.New("STH version mismatch")
+
+// STH is the canonical form of a signed tree head: the fields of a log root that its
+// signature covers. Its TimestampNanos is set by the sequencer's time source when the
+// root is created, so that verifiers can tell how fresh the root is.
+type STH struct {
+	Version        STHVersion
+	TreeSize       int64
+	RootHash       []byte
+	TimestampNanos int64
+}
+
+// STHFromRoot returns the STH for root, which has version STHVersion1.
+func STHFromRoot(root trillian.SignedLogRoot) STH {
+	return STH{Version: STHVersion1, TreeSize: root.TreeSize, RootHash: root.RootHash, TimestampNanos: root.TimestampNanos}
+}
+
+// Time returns the time at which the STH was created.
+func (s STH) Time() time.Time {
+	return time.Unix(0, s.TimestampNanos)
+}
+
+// SignedData returns the data that is signed for the STH, which is the ObjectHash of
+// its fields and version computed by HashVersionedLogRoot. For STHVersionLegacy it is
+// the HashLogRoot of the fields alone.
+func (s STH) SignedData() []byte {
+	root := trillian.SignedLogRoot{TreeSize: s.TreeSize, RootHash: s.RootHash, TimestampNanos: s.TimestampNanos}
+	if s.Version == STHVersionLegacy {
+		return HashLogRoot(root)
+	}
+	return HashVersionedLogRoot(string(s.Version), root)
+}
+
+// VerifySTH checks that sth has the given version and that sig is a valid signature over
+// it by the private key for pub. An STH without the expected version is rejected with
+// ErrSTHVersionMismatch; since the version is signed, relabelling an STH of another
+// version fails verification as well. Only callers that pass STHVersionLegacy accept
+// unversioned STHs.
+func VerifySTH(pub gocrypto.PublicKey, sth STH, sig *sigpb.DigitallySigned, version STHVersion) error {
+	if version == "" || sth.Version != version {
+		return fmt.Errorf("%w: got %q, want %q", ErrSTHVersionMismatch, sth.Version, version)
+	}
+	return Verify(pub, sth.SignedData(), sig)
+}
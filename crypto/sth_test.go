@@ -0,0 +1,86 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/sigpb"
+)
+
+func TestVerifySTHVersion(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	keyManager, err := NewFromPrivateKey(key)
+	if err != nil {
+		t.Fatalf("NewFromPrivateKey()=(_,%v), want (_,nil)", err)
+	}
+	signer := NewSignerFromPrivateKeyManager(keyManager)
+	const v2 = STHVersion("trillian/log/sth/v2")
+
+	v1STH := STHFromRoot(trillian.SignedLogRoot{TreeSize: 7, RootHash: []byte("root"), TimestampNanos: 1500000000000000000})
+	v1Sig, err := signer.Sign(v1STH.SignedData())
+	if err != nil {
+		t.Fatalf("Sign(v1)=(_,%v), want (_,nil)", err)
+	}
+	v2STH := v1STH
+	v2STH.Version = v2
+	v2Sig, err := signer.Sign(v2STH.SignedData())
+	if err != nil {
+		t.Fatalf("Sign(v2)=(_,%v), want (_,nil)", err)
+	}
+	// Roots signed before STHVersion1 have signatures over the unversioned HashLogRoot.
+	legacySTH := v1STH
+	legacySTH.Version = STHVersionLegacy
+	legacySig, err := signer.Sign(HashLogRoot(trillian.SignedLogRoot{TreeSize: 7, RootHash: []byte("root"), TimestampNanos: 1500000000000000000}))
+	if err != nil {
+		t.Fatalf("Sign(legacy)=(_,%v), want (_,nil)", err)
+	}
+
+	for _, test := range []struct {
+		desc    string
+		sth     STH
+		sig     *sigpb.DigitallySigned
+		version STHVersion
+		wantErr error
+	}{
+		{desc: "v1 as v1", sth: v1STH, sig: v1Sig, version: STHVersion1},
+		{desc: "v2 as v2", sth: v2STH, sig: v2Sig, version: v2},
+		{desc: "v1 as v2", sth: v1STH, sig: v1Sig, version: v2, wantErr: ErrSTHVersionMismatch},
+		{desc: "v2 as v1", sth: v2STH, sig: v2Sig, version: STHVersion1, wantErr: ErrSTHVersionMismatch},
+		// Relabelling the STH does not help, as the version is signed.
+		{desc: "v1 signature relabelled v2", sth: v2STH, sig: v1Sig, version: v2, wantErr: ErrVerifyFailed},
+		{desc: "v2 signature relabelled v1", sth: v1STH, sig: v2Sig, version: STHVersion1, wantErr: ErrVerifyFailed},
+		{desc: "legacy as legacy", sth: legacySTH, sig: legacySig, version: STHVersionLegacy},
+		{desc: "legacy as v1", sth: v1STH, sig: legacySig, version: STHVersion1, wantErr: ErrVerifyFailed},
+		{desc: "v1 signature relabelled legacy", sth: legacySTH, sig: v1Sig, version: STHVersionLegacy, wantErr: ErrVerifyFailed},
+		{desc: "no expected version", sth: STH{TreeSize: 7, RootHash: []byte("root")}, sig: v1Sig, wantErr: ErrSTHVersionMismatch},
+	} {
+		err := VerifySTH(key.Public(), test.sth, test.sig, test.version)
+		if test.wantErr == nil {
+			if err != nil {
+				t.Errorf("%v: VerifySTH()=%v, want nil", test.desc, err)
+			}
+		} else if !errors.Is(err, test.wantErr) {
+			t.Errorf("%v: VerifySTH()=%v, want %v", test.desc, err, test.wantErr)
+		}
+	}
+}
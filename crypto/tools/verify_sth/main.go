@@ -0,0 +1,104 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// verify_sth checks the signature on a log's signed tree head, without needing
+// to talk to the log or write any code.
+//
+// The STH file holds a SignedLogRoot in JSON form, and the signature file holds
+// a DigitallySigned in JSON form. If the STH's own signature field is set it is
+// only used when no signature file is given. --legacy_sth verifies the unversioned
+// STHs that logs signed before STH versions were introduced.
+package main
+
+import (
+	gocrypto "crypto"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	log "github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/sigpb"
+)
+
+var (
+	publicKeyFlag = flag.String("public_key", "", "File holding the log's public key in PEM format")
+	sthFlag       = flag.String("sth", "", "File holding the signed tree head as JSON")
+	signatureFlag = flag.String("signature", "", "File holding the signature as JSON; if empty the STH's own signature is used")
+	legacySTHFlag = flag.Bool("legacy_sth", false, "Verify the STH as one signed before STH versions were introduced")
+)
+
+// run loads the key, STH and signature files and verifies the signature over the STH.
+func run(keyFile, sthFile, sigFile string, legacy bool) (*trillian.SignedLogRoot, error) {
+	pub, err := crypto.PublicKeyFromFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	sthJSON, err := ioutil.ReadFile(sthFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read STH: %v", err)
+	}
+	var sigJSON []byte
+	if sigFile != "" {
+		if sigJSON, err = ioutil.ReadFile(sigFile); err != nil {
+			return nil, fmt.Errorf("failed to read signature: %v", err)
+		}
+	}
+	return verifySTH(pub, sthJSON, sigJSON, legacy)
+}
+
+// verifySTH verifies the signature in sigJSON, or the STH's own signature if
+// sigJSON is empty, over the STH in sthJSON. If legacy is set the STH is verified
+// as a crypto.STHVersionLegacy one rather than a crypto.STHVersion1 one.
+func verifySTH(pub gocrypto.PublicKey, sthJSON, sigJSON []byte, legacy bool) (*trillian.SignedLogRoot, error) {
+	var root trillian.SignedLogRoot
+	if err := json.Unmarshal(sthJSON, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse STH: %v", err)
+	}
+	sig := root.Signature
+	if len(sigJSON) > 0 {
+		sig = &sigpb.DigitallySigned{}
+		if err := json.Unmarshal(sigJSON, sig); err != nil {
+			return nil, fmt.Errorf("failed to parse signature: %v", err)
+		}
+	}
+	if sig == nil {
+		return nil, errors.New("no signature given and STH is unsigned")
+	}
+	sth, version := crypto.STHFromRoot(root), crypto.STHVersion1
+	if legacy {
+		version = crypto.STHVersionLegacy
+		sth.Version = version
+	}
+	if err := crypto.VerifySTH(pub, sth, sig, version); err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+func main() {
+	flag.Parse()
+	if *publicKeyFlag == "" || *sthFlag == "" {
+		log.Exit("--public_key and --sth must be set")
+	}
+
+	root, err := run(*publicKeyFlag, *sthFlag, *signatureFlag, *legacySTHFlag)
+	if err != nil {
+		fmt.Printf("FAILED: %v\n", err)
+		log.Exitf("Failed to verify STH: %v", err)
+	}
+	fmt.Printf("OK: log %d tree size %d root hash %x\n", root.LogId, root.TreeSize, root.RootHash)
+}
@@ -0,0 +1,118 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/testonly"
+)
+
+func TestRun(t *testing.T) {
+	km, err := crypto.NewFromPrivatePEM(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("NewFromPrivatePEM()=%v", err)
+	}
+	signer := crypto.NewSignerFromPrivateKeyManager(km)
+
+	root := trillian.SignedLogRoot{
+		LogId:          6962,
+		TimestampNanos: 1500000000000000000,
+		TreeSize:       42,
+		RootHash:       []byte("01234567890123456789012345678901"),
+	}
+	sig, err := signer.Sign(crypto.HashVersionedLogRoot(string(crypto.STHVersion1), root))
+	if err != nil {
+		t.Fatalf("Sign()=_,%v, want nil", err)
+	}
+	legacySig, err := signer.Sign(crypto.HashLogRoot(root))
+	if err != nil {
+		t.Fatalf("Sign(legacy)=_,%v, want nil", err)
+	}
+	signedRoot := root
+	signedRoot.Signature = sig
+	tampered := root
+	tampered.TreeSize++
+
+	dir, err := ioutil.TempDir("", "verify_sth")
+	if err != nil {
+		t.Fatalf("TempDir()=_,%v, want nil", err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name string, contents []byte) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, contents, 0644); err != nil {
+			t.Fatalf("WriteFile(%v)=%v, want nil", path, err)
+		}
+		return path
+	}
+	writeJSON := func(name string, v interface{}) string {
+		j, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("json.Marshal()=_,%v, want nil", err)
+		}
+		return write(name, j)
+	}
+
+	keyFile := write("key.pem", []byte(testonly.DemoPublicKey))
+	sthFile := writeJSON("sth.json", root)
+	signedSTHFile := writeJSON("signed_sth.json", signedRoot)
+	tamperedFile := writeJSON("tampered.json", tampered)
+	sigFile := writeJSON("sig.json", sig)
+	legacySigFile := writeJSON("legacy_sig.json", legacySig)
+	garbageFile := write("garbage.json", []byte("not JSON"))
+
+	for _, test := range []struct {
+		desc    string
+		key     string
+		sth     string
+		sig     string
+		legacy  bool
+		wantErr error
+	}{
+		{desc: "good", key: keyFile, sth: sthFile, sig: sigFile},
+		{desc: "embedded signature", key: keyFile, sth: signedSTHFile},
+		{desc: "tampered", key: keyFile, sth: tamperedFile, sig: sigFile, wantErr: crypto.ErrVerifyFailed},
+		{desc: "unsigned", key: keyFile, sth: sthFile, wantErr: errAny},
+		{desc: "bad STH", key: keyFile, sth: garbageFile, sig: sigFile, wantErr: errAny},
+		{desc: "bad signature", key: keyFile, sth: sthFile, sig: garbageFile, wantErr: errAny},
+		{desc: "bad key", key: garbageFile, sth: sthFile, sig: sigFile, wantErr: errAny},
+		{desc: "legacy", key: keyFile, sth: sthFile, sig: legacySigFile, legacy: true},
+		{desc: "legacy without --legacy_sth", key: keyFile, sth: sthFile, sig: legacySigFile, wantErr: crypto.ErrVerifyFailed},
+		{desc: "v1 with --legacy_sth", key: keyFile, sth: sthFile, sig: sigFile, legacy: true, wantErr: crypto.ErrVerifyFailed},
+		{desc: "missing STH", key: keyFile, sth: filepath.Join(dir, "missing"), sig: sigFile, wantErr: errAny},
+	} {
+		got, err := run(test.key, test.sth, test.sig, test.legacy)
+		switch {
+		case test.wantErr == nil && err != nil:
+			t.Errorf("%v: run()=_,%v, want nil", test.desc, err)
+		case test.wantErr == errAny && err == nil:
+			t.Errorf("%v: run()=_,nil, want error", test.desc)
+		case test.wantErr != nil && test.wantErr != errAny && !errors.Is(err, test.wantErr):
+			t.Errorf("%v: run()=_,%v, want %v", test.desc, err, test.wantErr)
+		case err == nil && got.TreeSize != root.TreeSize:
+			t.Errorf("%v: run().TreeSize=%d, want %d", test.desc, got.TreeSize, root.TreeSize)
+		}
+	}
+}
+
+// errAny marks test cases where any error is expected.
+var errAny = errors.New("any error")
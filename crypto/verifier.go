@@ -15,50 +15,202 @@
 package crypto
 
 import (
+	"bytes"
+	"context"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rsa"
+	"crypto/subtle"
+	// Register the SHA-2 hashes with crypto.Hash so they can be used by Verify.
+	_ "crypto/sha256"
+	_ "crypto/sha512"
 	"crypto/x509"
 	"encoding/asn1"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math/big"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/benlaurie/objecthash/go/objecthash"
 	"github.com/google/trillian/crypto/sigpb"
+	// Register SHA3-256 with crypto.Hash so it can be used by Verify.
+	_ "golang.org/x/crypto/sha3"
+)
+
+// DefaultMinRSAKeyBits is the smallest RSA modulus, in bits, that is accepted when
+// verifying signatures unless VerifyOptions specifies otherwise.
+const DefaultMinRSAKeyBits = 2048
+
+// RedactedPrefix starts the string that replaces a redacted value in an object passed to
+// VerifyRedactedObject. Following objecthash's redaction scheme, it is followed by the 64
+// lower case hex digits of the value's ObjectHash, for example
+// "**REDACTED**73a0d3e4..." for a 32 byte hash starting 0x73a0d3e4.
+const RedactedPrefix = "**REDACTED**"
+
+// PEM block types for the objects that contain public keys.
+const (
+	pemPublicKeyType   = "PUBLIC KEY"
+	pemCertificateType = "CERTIFICATE"
 )
 
 var (
-	errVerify = errors.New("signature verification failed")
+	// ErrUnsupportedAlgorithm is returned when a signature uses a hash or signature
+	// algorithm, or a key type, that the verifier does not support.
+	ErrUnsupportedAlgorithm = errors.New("unsupported algorithm")
+	// ErrVerifyFailed is returned when a signature does not verify.
+	ErrVerifyFailed = errors.New("signature verification failed")
+	// ErrAlgorithmMismatch is returned when a signature's algorithm does not match
+	// the type of the public key.
+	ErrAlgorithmMismatch = errors.New("signature algorithm does not match public key")
+	// ErrRSAKeyTooSmall is returned when an RSA public key's modulus is shorter
+	// than the minimum allowed size.
+	ErrRSAKeyTooSmall = errors.New("RSA public key too small")
+	// ErrCurveNotAllowed is returned when an ECDSA public key uses an elliptic
+	// curve that is not allowed.
+	ErrCurveNotAllowed = errors.New("ECDSA curve not allowed")
+	// ErrDigestSize is returned when a pre-computed digest is not the size produced
+	// by the signature's hash algorithm.
+	ErrDigestSize = errors.New("digest has wrong size for hash algorithm")
+	// ErrCertChain is returned when a signing certificate cannot be parsed or does
+	// not chain to a trusted root.
+	ErrCertChain = errors.New("certificate chain verification failed")
+
+	// DefaultAllowedCurves are the elliptic curves that ECDSA public keys may use
+	// unless VerifyOptions specifies otherwise.
+	DefaultAllowedCurves = []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()}
 
 	cryptoHashLookup = map[sigpb.DigitallySigned_HashAlgorithm]crypto.Hash{
-		sigpb.DigitallySigned_SHA256: crypto.SHA256,
+		sigpb.DigitallySigned_SHA256:   crypto.SHA256,
+		sigpb.DigitallySigned_SHA384:   crypto.SHA384,
+		sigpb.DigitallySigned_SHA512:   crypto.SHA512,
+		sigpb.DigitallySigned_SHA3_256: crypto.SHA3_256,
 	}
 )
 
 // PublicKeyFromFile returns the public key contained in the keyFile in PEM format.
 func PublicKeyFromFile(keyFile string) (crypto.PublicKey, error) {
-	pemData, err := ioutil.ReadFile(keyFile)
+	f, err := os.Open(keyFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read: %s. %v", keyFile, err)
 	}
+	defer f.Close()
+	return PublicKeyFromReader(f)
+}
+
+// PublicKeyFromReader returns the public key contained in PEM format in the data read from r.
+func PublicKeyFromReader(r io.Reader) (crypto.PublicKey, error) {
+	pemData, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %v", err)
+	}
 	return PublicKeyFromPEM(string(pemData))
 }
 
-// PublicKeyFromPEM converts a PEM object into a crypto.PublicKey
+// PublicKeyFromPEM converts a PEM object into a crypto.PublicKey. The PEM object may
+// either hold a public key or an X.509 certificate, in which case the certificate's
+// public key is returned. It is an error for the data to hold more than one key or to
+// continue after the PEM object.
 func PublicKeyFromPEM(pemEncodedKey string) (crypto.PublicKey, error) {
-	publicBlock, rest := pem.Decode([]byte(pemEncodedKey))
-	if publicBlock == nil {
-		return nil, errors.New("could not decode PEM for public key")
+	keys, rest, err := publicKeysFromPEM(pemEncodedKey)
+	if err != nil {
+		return nil, err
 	}
 	if len(rest) > 0 {
 		return nil, errors.New("extra data found after PEM key decoded")
 	}
+	if len(keys) != 1 {
+		return nil, fmt.Errorf("found %d public keys in PEM data, want 1", len(keys))
+	}
+	return keys[0], nil
+}
+
+// PublicKeysFromPEM returns all the public keys held in a series of PEM objects.
+// Blocks holding X.509 certificates yield the certificate's public key, blocks
+// of any other type than public keys or certificates are skipped, as is any
+// text around the PEM blocks. An error is returned if a public key or
+// certificate block cannot be parsed, or if no keys are found.
+func PublicKeysFromPEM(pemEncodedKeys string) ([]crypto.PublicKey, error) {
+	keys, _, err := publicKeysFromPEM(pemEncodedKeys)
+	return keys, err
+}
+
+// publicKeysFromPEM returns the public keys held in pemEncodedKeys, as described by
+// PublicKeysFromPEM, and the data that follows the last PEM block.
+func publicKeysFromPEM(pemEncodedKeys string) ([]crypto.PublicKey, []byte, error) {
+	var keys []crypto.PublicKey
+	rest := []byte(pemEncodedKeys)
+	for {
+		block, next := pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		rest = next
+		if block.Type != pemPublicKeyType && block.Type != pemCertificateType {
+			continue
+		}
+		key, err := publicKeyFromBlock(block)
+		if err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, nil, errors.New("no public keys found in PEM data")
+	}
+	return keys, rest, nil
+}
+
+// publicKeyFromBlock returns the public key held in a PEM block. Certificate blocks
+// yield the certificate's public key and all others are parsed as a public key.
+func publicKeyFromBlock(block *pem.Block) (crypto.PublicKey, error) {
+	if block.Type == pemCertificateType {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse certificate: %v", err)
+		}
+		return cert.PublicKey, nil
+	}
+
+	return PublicKeyFromDER(block.Bytes)
+}
+
+// CertificateFromPEM converts a PEM object holding an X.509 certificate into an
+// x509.Certificate.
+func CertificateFromPEM(pemEncodedCert string) (*x509.Certificate, error) {
+	certBlock, rest := pem.Decode([]byte(pemEncodedCert))
+	if certBlock == nil {
+		return nil, errors.New("could not decode PEM for certificate")
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("extra data found after PEM certificate decoded")
+	}
+	if certBlock.Type != pemCertificateType {
+		return nil, fmt.Errorf("unexpected PEM block type %q, want %q", certBlock.Type, pemCertificateType)
+	}
+
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse certificate: %v", err)
+	}
 
-	parsedKey, err := x509.ParsePKIXPublicKey(publicBlock.Bytes)
+	return cert, nil
+}
+
+// PublicKeyFromDER converts a DER encoded SubjectPublicKeyInfo into a crypto.PublicKey.
+func PublicKeyFromDER(der []byte) (crypto.PublicKey, error) {
+	parsedKey, err := x509.ParsePKIXPublicKey(der)
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse public key: %v", err)
 	}
@@ -68,67 +220,723 @@ func PublicKeyFromPEM(pemEncodedKey string) (crypto.PublicKey, error) {
 
 // VerifyObject verifies the output of Signer.SignObject.
 func VerifyObject(pub crypto.PublicKey, obj interface{}, sig *sigpb.DigitallySigned) error {
+	return VerifyObjectWith(pub, obj, sig, objectHashJSON)
+}
+
+// VerifyObjectWithCertChain verifies the output of Signer.SignObject made with the key
+// of a signing certificate, once the certificate has been checked to chain to one of
+// roots. leafCertPEM holds the signing certificate, optionally followed by the
+// intermediate certificates needed to build the chain. Errors from building the chain
+// wrap ErrCertChain, and are distinct from those of signature verification.
+func VerifyObjectWithCertChain(roots *x509.CertPool, leafCertPEM string, obj interface{}, sig *sigpb.DigitallySigned) error {
+	certs, err := certificatesFromPEM(leafCertPEM)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCertChain, err)
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if _, err := certs[0].Verify(opts); err != nil {
+		return fmt.Errorf("%w: %v", ErrCertChain, err)
+	}
+	return VerifyObject(certs[0].PublicKey, obj, sig)
+}
+
+// certificatesFromPEM parses the one or more PEM encoded X.509 certificates in pemCerts.
+func certificatesFromPEM(pemCerts string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(pemCerts)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != pemCertificateType {
+			return nil, fmt.Errorf("unexpected PEM block type %q, want %q", block.Type, pemCertificateType)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("could not decode PEM for certificate")
+	}
+	return certs, nil
+}
+
+// VerifyObjectWith verifies a signature over an object, where canon converts the
+// object into the canonical bytes that were signed.
+func VerifyObjectWith(pub crypto.PublicKey, obj interface{}, sig *sigpb.DigitallySigned, canon func(interface{}) ([]byte, error)) error {
+	data, err := canon(obj)
+	if err != nil {
+		return err
+	}
+
+	return Verify(pub, data, sig)
+}
+
+// VerifyJSON verifies the output of Signer.SignObject for an object whose JSON
+// encoding the caller already holds. jsonBytes must be valid JSON; it is hashed
+// directly with the ObjectHash common JSON scheme rather than being decoded into
+// an object and marshalled again.
+func VerifyJSON(pub crypto.PublicKey, jsonBytes []byte, sig *sigpb.DigitallySigned) error {
+	hash, err := objectHashJSONBytes(jsonBytes)
+	if err != nil {
+		return err
+	}
+	return Verify(pub, hash, sig)
+}
+
+// VerifyObjectStream verifies the output of Signer.SignObject for an object whose
+// JSON encoding is read from r, e.g. the read end of an io.Pipe fed by a
+// json.Encoder. The JSON is decoded as it is read, so neither it nor a second copy
+// of it as a string is held in memory, and the result is identical to VerifyObject
+// for the same object. r must hold exactly one valid JSON value.
+func VerifyObjectStream(pub crypto.PublicKey, r io.Reader, sig *sigpb.DigitallySigned) error {
+	hash, err := objectHashJSONReader(r)
+	if err != nil {
+		return err
+	}
+	return Verify(pub, hash, sig)
+}
+
+// Redact returns the string that replaces v, a value such as a field of an object signed
+// by Signer.SignObject, in a redacted copy of the object.
+func Redact(v interface{}) (string, error) {
+	hash, err := objectHashJSON(v)
+	if err != nil {
+		return "", err
+	}
+	return RedactedPrefix + hex.EncodeToString(hash), nil
+}
+
+// VerifyRedactedObject verifies the output of Signer.SignObject over an object of which
+// obj is a copy with zero or more values redacted. A value, at any depth, is redacted by
+// replacing it with the string returned for it by Redact, and the redacted copy has the
+// same ObjectHash as the original. Object keys cannot be redacted. A string that starts
+// with RedactedPrefix but is not followed by a hash in hex is an error, rather than being
+// hashed as an ordinary string.
+func VerifyRedactedObject(pub crypto.PublicKey, obj interface{}, sig *sigpb.DigitallySigned) error {
 	j, err := json.Marshal(obj)
 	if err != nil {
 		return err
 	}
+	var v interface{}
+	if err := json.Unmarshal(j, &v); err != nil {
+		return err
+	}
+	if err := checkRedactions(v); err != nil {
+		return err
+	}
+	hash := objecthash.ObjectHash(v)
+	return Verify(pub, hash[:], sig)
+}
+
+// checkRedactions returns an error if v, decoded from JSON, contains a malformed redacted
+// value or a redacted object key.
+func checkRedactions(v interface{}) error {
+	switch v := v.(type) {
+	case string:
+		if !strings.HasPrefix(v, RedactedPrefix) {
+			return nil
+		}
+		digits := v[len(RedactedPrefix):]
+		hash, err := hex.DecodeString(digits)
+		if err != nil || len(hash) != crypto.SHA256.Size() || strings.ToLower(digits) != digits {
+			return fmt.Errorf("malformed redacted value %q", v)
+		}
+	case []interface{}:
+		for _, e := range v {
+			if err := checkRedactions(e); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for k, e := range v {
+			if strings.HasPrefix(k, RedactedPrefix) {
+				return fmt.Errorf("redacted object key %q", k)
+			}
+			if err := checkRedactions(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// objectHashJSON returns the ObjectHash of the JSON encoding of obj, as signed by
+// Signer.SignObject.
+func objectHashJSON(obj interface{}) ([]byte, error) {
+	j, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	return objectHashJSONBytes(j)
+}
+
+// objectHashJSONReader returns the ObjectHash of the single JSON value read from
+// r, decoding it the same way as CommonJSONHash.
+func objectHashJSONReader(r io.Reader) ([]byte, error) {
+	dec := json.NewDecoder(r)
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid JSON: data after top-level value")
+	}
+	hash := objecthash.ObjectHash(v)
+	return hash[:], nil
+}
+
+// objectHashJSONBytes returns the ObjectHash of the JSON in j. CommonJSONHash
+// panics on malformed input, so j is checked first.
+func objectHashJSONBytes(j []byte) ([]byte, error) {
+	if !json.Valid(j) {
+		return nil, errors.New("invalid JSON")
+	}
 	hash := objecthash.CommonJSONHash(string(j))
+	return hash[:], nil
+}
 
-	return Verify(pub, hash[:], sig)
+// VerifyOptions holds optional, stricter checks that can be applied when verifying
+// signatures. The zero value performs no additional checks.
+type VerifyOptions struct {
+	// RequireLowS rejects ECDSA signatures whose S value is greater than half
+	// the curve order. For any valid signature (R, S) the pair (R, N-S) is also
+	// valid, so this is needed when signatures must be unique, e.g. because
+	// they are compared for equality.
+	RequireLowS bool
+
+	// MinRSAKeyBits is the smallest RSA modulus size, in bits, that signatures
+	// will be verified against. If zero, DefaultMinRSAKeyBits is used.
+	MinRSAKeyBits int
+
+	// AllowedCurves are the elliptic curves that ECDSA public keys may use. If
+	// nil, DefaultAllowedCurves is used.
+	AllowedCurves []elliptic.Curve
+
+	// AllowRawECDSA accepts ECDSA signatures in the raw form produced by JWS
+	// clients, as described for ECDSASigToDER, as well as ASN.1 DER. A signature
+	// of the raw size that does not verify in that form is tried as DER.
+	AllowRawECDSA bool
+}
+
+// minRSAKeyBits returns the minimum RSA modulus size that opts allows.
+func (opts VerifyOptions) minRSAKeyBits() int {
+	if opts.MinRSAKeyBits == 0 {
+		return DefaultMinRSAKeyBits
+	}
+	return opts.MinRSAKeyBits
+}
+
+// curveAllowed reports whether opts allows ECDSA keys on the given curve.
+func (opts VerifyOptions) curveAllowed(curve elliptic.Curve) bool {
+	allowed := opts.AllowedCurves
+	if allowed == nil {
+		allowed = DefaultAllowedCurves
+	}
+	for _, c := range allowed {
+		if c == curve {
+			return true
+		}
+	}
+	return false
 }
 
 // Verify cryptographically verifies the output of Signer.
 func Verify(pub crypto.PublicKey, data []byte, sig *sigpb.DigitallySigned) error {
-	sigAlgo := sig.SignatureAlgorithm
+	return VerifyWithOptions(pub, data, sig, VerifyOptions{})
+}
 
-	// Recompute digest
-	hasher, ok := cryptoHashLookup[sig.HashAlgorithm]
-	if !ok {
-		return fmt.Errorf("unsupported hash algorithm %v", hasher)
+// VerifyContext cryptographically verifies the output of Signer, unless ctx has
+// already been cancelled or has expired, in which case the context's error is returned.
+func VerifyContext(ctx context.Context, pub crypto.PublicKey, data []byte, sig *sigpb.DigitallySigned) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	h := hasher.New()
-	h.Write(data)
-	digest := h.Sum(nil)
+	return Verify(pub, data, sig)
+}
+
+// VerifyWithOptions cryptographically verifies the output of Signer, applying
+// any additional checks requested by opts.
+func VerifyWithOptions(pub crypto.PublicKey, data []byte, sig *sigpb.DigitallySigned, opts VerifyOptions) error {
+	return verifyStream(pub, bytes.NewReader(data), sig, opts)
+}
+
+// VerifyAny cryptographically verifies the output of Signer against each of pubs in
+// turn, for example the old and new keys of a log whose key is being rotated, and
+// returns the index of the first key that verifies it. Keys whose type does not match
+// the signature's algorithm are passed over. If no key verifies the signature, -1 is
+// returned with the error from the first key whose type did match, which is usually
+// ErrVerifyFailed, or with ErrAlgorithmMismatch if none did.
+func VerifyAny(pubs []crypto.PublicKey, data []byte, sig *sigpb.DigitallySigned) (int, error) {
+	var firstErr error
+	for i, pub := range pubs {
+		err := Verify(pub, data, sig)
+		if err == nil {
+			return i, nil
+		}
+		if firstErr == nil && !errors.Is(err, ErrAlgorithmMismatch) {
+			firstErr = fmt.Errorf("key %d: %w", i, err)
+		}
+	}
+	if firstErr != nil {
+		return -1, firstErr
+	}
+	if len(pubs) == 0 {
+		return -1, fmt.Errorf("%w: no keys", ErrVerifyFailed)
+	}
+	return -1, ErrAlgorithmMismatch
+}
 
-	// Verify signature algo type
+// VerifyMulti cryptographically verifies each of sigs over data, for example the
+// signatures made with different algorithms over a single STH, using the key in pubs
+// for the signature's SignatureAlgorithm. It succeeds only if every signature
+// verifies. Otherwise the error names the index of the first signature that does not,
+// and wraps ErrUnsupportedAlgorithm if pubs holds no key for its algorithm, or the
+// error from Verify.
+func VerifyMulti(pubs map[sigpb.DigitallySigned_SignatureAlgorithm]crypto.PublicKey, data []byte, sigs []*sigpb.DigitallySigned) error {
+	if len(sigs) == 0 {
+		return fmt.Errorf("%w: no signatures", ErrVerifyFailed)
+	}
+	for i, sig := range sigs {
+		if err := checkSignature(sig); err != nil {
+			return fmt.Errorf("signature %d: %w", i, err)
+		}
+		pub, ok := pubs[sig.SignatureAlgorithm]
+		if !ok {
+			return fmt.Errorf("signature %d: %w: no key for %v", i, ErrUnsupportedAlgorithm, sig.SignatureAlgorithm)
+		}
+		if err := Verify(pub, data, sig); err != nil {
+			return fmt.Errorf("signature %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// VerifyWithHash cryptographically verifies the output of Signer, computing the
+// digest with h rather than the hash named by the signature's HashAlgorithm. It is
+// an escape hatch for signatures made with hash functions that are linked into the
+// binary but not yet supported by Verify. ErrUnsupportedAlgorithm is returned if h
+// is not available, or if the key is Ed25519, which does not sign a digest.
+func VerifyWithHash(pub crypto.PublicKey, data []byte, sig *sigpb.DigitallySigned, h crypto.Hash) error {
+	if err := checkSignature(sig); err != nil {
+		return err
+	}
+	if !h.Available() {
+		return fmt.Errorf("%w: hash function %v not linked into binary", ErrUnsupportedAlgorithm, h)
+	}
+	sigAlgo, err := checkPublicKey(pub, VerifyOptions{})
+	if err != nil {
+		return err
+	}
+	if !algorithmMatchesKey(sig.SignatureAlgorithm, sigAlgo) {
+		return ErrAlgorithmMismatch
+	}
+	if sigAlgo == sigpb.DigitallySigned_ED25519 {
+		return fmt.Errorf("%w: Ed25519 signatures are not made over a digest", ErrUnsupportedAlgorithm)
+	}
+	hasher := h.New()
+	hasher.Write(data)
+	return verifyHashed(pub, sigAlgo, hasher.Sum(nil), h, sig, VerifyOptions{})
+}
+
+// VerifyStream cryptographically verifies the output of Signer over the data read
+// from r. The data is hashed as it is read so it need not be held in memory, except
+// for Ed25519 signatures which are computed over the whole message.
+func VerifyStream(pub crypto.PublicKey, r io.Reader, sig *sigpb.DigitallySigned) error {
+	return verifyStream(pub, r, sig, VerifyOptions{})
+}
+
+func verifyStream(pub crypto.PublicKey, r io.Reader, sig *sigpb.DigitallySigned, opts VerifyOptions) error {
+	if err := checkSignature(sig); err != nil {
+		return err
+	}
+	sigAlgo, err := checkPublicKey(pub, opts)
+	if err != nil {
+		return err
+	}
+	return verifySignature(pub, sigAlgo, r, sig, opts)
+}
+
+// VerifyDigest cryptographically verifies a signature over a digest that was computed
+// elsewhere, e.g. a signature made by an HSM over a pre-hashed message. The digest is
+// not hashed again, but must be the size produced by the signature's hash algorithm.
+// Ed25519 signatures are made over the message itself, so cannot be verified this way.
+func VerifyDigest(pub crypto.PublicKey, digest []byte, sig *sigpb.DigitallySigned) error {
+	if err := checkSignature(sig); err != nil {
+		return err
+	}
+	sigAlgo, err := checkPublicKey(pub, VerifyOptions{})
+	if err != nil {
+		return err
+	}
+	if !algorithmMatchesKey(sig.SignatureAlgorithm, sigAlgo) {
+		return ErrAlgorithmMismatch
+	}
+	if sigAlgo == sigpb.DigitallySigned_ED25519 {
+		return fmt.Errorf("%w: Ed25519 signatures cannot be verified over a digest", ErrUnsupportedAlgorithm)
+	}
+	return verifyDigest(pub, sigAlgo, digest, sig, VerifyOptions{})
+}
+
+// Verifier verifies signatures against a single public key. The checks on the key
+// itself are done once, when the Verifier is created, so it is cheaper than calling
+// Verify repeatedly with the same key.
+type Verifier struct {
+	pub     crypto.PublicKey
+	sigAlgo sigpb.DigitallySigned_SignatureAlgorithm
+	opts    VerifyOptions
+}
+
+// NewVerifier creates a Verifier for the given public key. An error is returned if
+// the key's type is not supported.
+func NewVerifier(pub crypto.PublicKey) (*Verifier, error) {
+	return NewVerifierWithOptions(pub, VerifyOptions{})
+}
+
+// NewVerifierWithOptions creates a Verifier for the given public key that applies
+// the additional checks requested by opts. An error is returned if the key's type
+// is not supported or the key is not allowed by opts.
+func NewVerifierWithOptions(pub crypto.PublicKey, opts VerifyOptions) (*Verifier, error) {
+	sigAlgo, err := checkPublicKey(pub, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{pub: pub, sigAlgo: sigAlgo, opts: opts}, nil
+}
+
+// Verify cryptographically verifies the output of Signer against the Verifier's key.
+func (v *Verifier) Verify(data []byte, sig *sigpb.DigitallySigned) error {
+	if err := checkSignature(sig); err != nil {
+		return err
+	}
+	return verifySignature(v.pub, v.sigAlgo, bytes.NewReader(data), sig, v.opts)
+}
+
+// VerifyContext cryptographically verifies the output of Signer against the
+// Verifier's key, unless ctx has already been cancelled or has expired, in which
+// case the context's error is returned.
+func (v *Verifier) VerifyContext(ctx context.Context, data []byte, sig *sigpb.DigitallySigned) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return v.Verify(data, sig)
+}
+
+// VerifyItem is a single signature, and the data it covers, to be checked by VerifyBatch.
+type VerifyItem struct {
+	Data []byte
+	Sig  *sigpb.DigitallySigned
+}
+
+// VerifyBatch cryptographically verifies many outputs of Signer made with the same
+// key. The returned slice holds the result of verifying each item, in the same
+// order as items, with nil for the signatures that verified.
+func VerifyBatch(pub crypto.PublicKey, items []VerifyItem) []error {
+	return VerifyBatchContext(context.Background(), pub, items)
+}
+
+// VerifyBatchContext is VerifyBatch, but stops verifying items once ctx is cancelled
+// or expires. Items that were not verified have the context's error as their result.
+func VerifyBatchContext(ctx context.Context, pub crypto.PublicKey, items []VerifyItem) []error {
+	errs := make([]error, len(items))
+	v, err := NewVerifier(pub)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	v.verifyBatch(ctx, items, errs)
+	return errs
+}
+
+// VerifyBatch cryptographically verifies many outputs of Signer against the
+// Verifier's key. The returned slice holds the result of verifying each item, in
+// the same order as items, with nil for the signatures that verified.
+func (v *Verifier) VerifyBatch(items []VerifyItem) []error {
+	return v.VerifyBatchContext(context.Background(), items)
+}
+
+// VerifyBatchContext is VerifyBatch, but stops verifying items once ctx is cancelled
+// or expires. Items that were not verified have the context's error as their result.
+func (v *Verifier) VerifyBatchContext(ctx context.Context, items []VerifyItem) []error {
+	errs := make([]error, len(items))
+	v.verifyBatch(ctx, items, errs)
+	return errs
+}
+
+// verifyBatch verifies items using a worker per CPU, storing the results in errs.
+func (v *Verifier) verifyBatch(ctx context.Context, items []VerifyItem, errs []error) {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				errs[i] = v.VerifyContext(ctx, items[i].Data, items[i].Sig)
+			}
+		}()
+	}
+dispatch:
+	for i := range items {
+		select {
+		case indices <- i:
+		case <-ctx.Done():
+			for ; i < len(items); i++ {
+				errs[i] = ctx.Err()
+			}
+			break dispatch
+		}
+	}
+	close(indices)
+	wg.Wait()
+}
+
+// SupportedAlgorithms returns the signature algorithms that can be used with pub,
+// and the hash algorithms that may accompany them, for building the header of a
+// DigitallySigned that Verify will accept. An error is returned if pub is not a
+// supported key.
+func SupportedAlgorithms(pub crypto.PublicKey) ([]sigpb.DigitallySigned_SignatureAlgorithm, []sigpb.DigitallySigned_HashAlgorithm, error) {
+	sigAlgo, err := checkPublicKey(pub, VerifyOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	switch sigAlgo {
+	case sigpb.DigitallySigned_ED25519:
+		return []sigpb.DigitallySigned_SignatureAlgorithm{sigAlgo}, []sigpb.DigitallySigned_HashAlgorithm{sigpb.DigitallySigned_NONE}, nil
+	case sigpb.DigitallySigned_RSA:
+		return []sigpb.DigitallySigned_SignatureAlgorithm{sigAlgo, sigpb.DigitallySigned_RSA_PSS}, supportedHashes(), nil
+	default:
+		return []sigpb.DigitallySigned_SignatureAlgorithm{sigAlgo}, supportedHashes(), nil
+	}
+}
+
+// supportedHashes returns the hash algorithms in cryptoHashLookup, in ascending order.
+func supportedHashes() []sigpb.DigitallySigned_HashAlgorithm {
+	hashes := make([]sigpb.DigitallySigned_HashAlgorithm, 0, len(cryptoHashLookup))
+	for alg := range cryptoHashLookup {
+		hashes = append(hashes, alg)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	return hashes
+}
+
+// checkSignature rejects signatures that are missing or hold no signature bytes,
+// as may be decoded from malformed input, before any other work is done.
+func checkSignature(sig *sigpb.DigitallySigned) error {
+	if sig == nil {
+		return fmt.Errorf("%w: nil signature", ErrVerifyFailed)
+	}
+	if len(sig.Signature) == 0 {
+		return fmt.Errorf("%w: empty signature", ErrVerifyFailed)
+	}
+	return nil
+}
+
+// checkPublicKey checks that pub is a supported key allowed by opts, and returns
+// the signature algorithm that must be used with it.
+func checkPublicKey(pub crypto.PublicKey, opts VerifyOptions) (sigpb.DigitallySigned_SignatureAlgorithm, error) {
 	switch key := pub.(type) {
 	case *ecdsa.PublicKey:
-		if sigAlgo != sigpb.DigitallySigned_ECDSA {
-			return fmt.Errorf("signature algorithm does not match public key")
+		if !opts.curveAllowed(key.Curve) {
+			return sigpb.DigitallySigned_ANONYMOUS, fmt.Errorf("%w: %v", ErrCurveNotAllowed, key.Curve.Params().Name)
 		}
-		return verifyECDSA(key, digest, sig.Signature)
+		return sigpb.DigitallySigned_ECDSA, nil
 	case *rsa.PublicKey:
-		if sigAlgo != sigpb.DigitallySigned_RSA {
-			return fmt.Errorf("signature algorithm does not match public key")
+		if bits := key.N.BitLen(); bits < opts.minRSAKeyBits() {
+			return sigpb.DigitallySigned_ANONYMOUS, fmt.Errorf("%w: %d bits, want at least %d", ErrRSAKeyTooSmall, bits, opts.minRSAKeyBits())
 		}
-		return verifyRSA(key, digest, sig.Signature, hasher, hasher)
+		return sigpb.DigitallySigned_RSA, nil
+	case ed25519.PublicKey:
+		return sigpb.DigitallySigned_ED25519, nil
 	default:
-		return fmt.Errorf("unknown private key type: %T", key)
+		return sigpb.DigitallySigned_ANONYMOUS, fmt.Errorf("%w: public key type %T", ErrUnsupportedAlgorithm, key)
 	}
 }
 
+// verifySignature verifies sig over the data read from r, using a public key that
+// has already been accepted by checkPublicKey and the sigAlgo it returned.
+func verifySignature(pub crypto.PublicKey, sigAlgo sigpb.DigitallySigned_SignatureAlgorithm, r io.Reader, sig *sigpb.DigitallySigned, opts VerifyOptions) error {
+	if !algorithmMatchesKey(sig.SignatureAlgorithm, sigAlgo) {
+		return ErrAlgorithmMismatch
+	}
+
+	// Ed25519 signs the message itself rather than a digest of it.
+	if sigAlgo == sigpb.DigitallySigned_ED25519 {
+		if sig.HashAlgorithm != sigpb.DigitallySigned_NONE {
+			return fmt.Errorf("%w: hash algorithm %v for Ed25519", ErrUnsupportedAlgorithm, sig.HashAlgorithm)
+		}
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("failed to read data: %v", err)
+		}
+		return verifyEd25519(pub.(ed25519.PublicKey), data, sig.Signature)
+	}
+
+	// Recompute digest
+	digest, err := digestStream(sig.HashAlgorithm, r)
+	if err != nil {
+		return err
+	}
+	return verifyDigest(pub, sigAlgo, digest, sig, opts)
+}
+
+// verifyDigest verifies an RSA or ECDSA signature over digest, which must be the
+// size produced by the signature's hash algorithm. The key must already have been
+// accepted by checkPublicKey and match the signature's algorithm.
+func verifyDigest(pub crypto.PublicKey, sigAlgo sigpb.DigitallySigned_SignatureAlgorithm, digest []byte, sig *sigpb.DigitallySigned, opts VerifyOptions) error {
+	hasher, err := hashFor(sig.HashAlgorithm)
+	if err != nil {
+		return err
+	}
+	if got, want := len(digest), hasher.Size(); subtle.ConstantTimeEq(int32(got), int32(want)) != 1 {
+		return fmt.Errorf("%w: %d bytes, want %d for %v", ErrDigestSize, got, want, sig.HashAlgorithm)
+	}
+	return verifyHashed(pub, sigAlgo, digest, hasher, sig, opts)
+}
+
+// verifyHashed verifies an RSA or ECDSA signature over digest, which has already
+// been checked to be the size of the output of hasher.
+func verifyHashed(pub crypto.PublicKey, sigAlgo sigpb.DigitallySigned_SignatureAlgorithm, digest []byte, hasher crypto.Hash, sig *sigpb.DigitallySigned, opts VerifyOptions) error {
+	if sigAlgo == sigpb.DigitallySigned_RSA {
+		var rsaOpts crypto.SignerOpts = hasher
+		if sig.SignatureAlgorithm == sigpb.DigitallySigned_RSA_PSS {
+			rsaOpts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto, Hash: hasher}
+		}
+		return verifyRSA(pub.(*rsa.PublicKey), digest, sig.Signature, hasher, rsaOpts)
+	}
+	ecdsaPub := pub.(*ecdsa.PublicKey)
+	if opts.AllowRawECDSA {
+		if der, err := ECDSASigToDER(sig.Signature, ecdsaPub.Curve); err == nil {
+			if verifyECDSA(ecdsaPub, digest, der, opts.RequireLowS) == nil {
+				return nil
+			}
+		}
+	}
+	return verifyECDSA(ecdsaPub, digest, sig.Signature, opts.RequireLowS)
+}
+
+// hashFor returns the hash function for alg, or an error wrapping
+// ErrUnsupportedAlgorithm if alg cannot be used to compute a digest.
+func hashFor(alg sigpb.DigitallySigned_HashAlgorithm) (crypto.Hash, error) {
+	hasher, ok := cryptoHashLookup[alg]
+	if !ok {
+		return crypto.Hash(0), fmt.Errorf("%w: hash algorithm %v", ErrUnsupportedAlgorithm, alg)
+	}
+	return hasher, nil
+}
+
+// digest returns the digest of data computed with alg.
+func digest(alg sigpb.DigitallySigned_HashAlgorithm, data []byte) ([]byte, error) {
+	return digestStream(alg, bytes.NewReader(data))
+}
+
+// digestStream returns the digest, computed with alg, of the data read from r.
+func digestStream(alg sigpb.DigitallySigned_HashAlgorithm, r io.Reader) ([]byte, error) {
+	hasher, err := hashFor(alg)
+	if err != nil {
+		return nil, err
+	}
+	h := hasher.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, fmt.Errorf("failed to read data: %v", err)
+	}
+	return h.Sum(nil), nil
+}
+
+// algorithmMatchesKey reports whether a signature made with sigAlgo can be verified
+// by a key whose algorithm, as returned by checkPublicKey, is keyAlgo.
+func algorithmMatchesKey(sigAlgo, keyAlgo sigpb.DigitallySigned_SignatureAlgorithm) bool {
+	if keyAlgo == sigpb.DigitallySigned_RSA && sigAlgo == sigpb.DigitallySigned_RSA_PSS {
+		return true
+	}
+	return sigAlgo == keyAlgo
+}
+
+// ConstantTimeEqual reports whether a and b hold the same bytes. The time taken
+// depends on the lengths of a and b but not on their contents, so comparing a
+// secret or attacker-supplied value does not reveal how much of it matched.
+func ConstantTimeEqual(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// verifyRSA relies on rsa.VerifyPKCS1v15 and rsa.VerifyPSS, which compare the
+// recovered encoding with the expected one in constant time.
 func verifyRSA(pub *rsa.PublicKey, hashed, sig []byte, hasher crypto.Hash, opts crypto.SignerOpts) error {
+	var err error
 	if pssOpts, ok := opts.(*rsa.PSSOptions); ok {
-		return rsa.VerifyPSS(pub, hasher, hashed, sig, pssOpts)
+		err = rsa.VerifyPSS(pub, hasher, hashed, sig, pssOpts)
+	} else {
+		err = rsa.VerifyPKCS1v15(pub, hasher, hashed, sig)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrVerifyFailed, err)
 	}
-	return rsa.VerifyPKCS1v15(pub, hasher, hashed, sig)
+	return nil
 }
 
-func verifyECDSA(pub *ecdsa.PublicKey, hashed, sig []byte) error {
+// verifyECDSA verifies an ASN.1 DER encoded ECDSA signature over hashed. So that
+// malformed signatures are not rejected noticeably faster than well formed ones
+// that do not match, every check is made before the results are combined, and a
+// malformed signature is still passed to ecdsa.Verify with placeholder values
+// that cannot verify. This only removes the early returns under our control; the
+// stdlib elliptic curve and big.Int arithmetic provide their own guarantees.
+func verifyECDSA(pub *ecdsa.PublicKey, hashed, sig []byte, requireLowS bool) error {
 	var ecdsaSig struct {
 		R, S *big.Int
 	}
 	rest, err := asn1.Unmarshal(sig, &ecdsaSig)
-	if err != nil {
-		return errVerify
+	wellFormed := err == nil && len(rest) == 0
+	if wellFormed {
+		// asn1.Unmarshal only accepts DER, so this holds for any signature it parses,
+		// but it keeps non-canonical encodings out regardless.
+		der, err := asn1.Marshal(ecdsaSig)
+		wellFormed = err == nil && ConstantTimeEqual(der, sig)
 	}
-	if len(rest) != 0 {
-		return errVerify
+	r, s := ecdsaSig.R, ecdsaSig.S
+	if !wellFormed {
+		r, s = big.NewInt(1), big.NewInt(1)
+	}
+
+	lowS := true
+	if requireLowS {
+		halfOrder := new(big.Int).Rsh(pub.Curve.Params().N, 1)
+		lowS = s.Cmp(halfOrder) <= 0
 	}
 
-	if !ecdsa.Verify(pub, hashed, ecdsaSig.R, ecdsaSig.S) {
-		return errVerify
+	valid := ecdsa.Verify(pub, hashed, r, s)
+	if !wellFormed || !lowS || !valid {
+		return ErrVerifyFailed
 	}
 	return nil
+}
 
+// verifyEd25519 relies on ed25519.Verify, which compares the recomputed value with
+// the signature in constant time.
+func verifyEd25519(pub ed25519.PublicKey, data, sig []byte) error {
+	if len(pub) != ed25519.PublicKeySize {
+		return ErrVerifyFailed
+	}
+	if !ed25519.Verify(pub, data, sig) {
+		return ErrVerifyFailed
+	}
+	return nil
 }
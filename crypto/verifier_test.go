@@ -15,9 +15,32 @@
 package crypto
 
 import (
+	"bytes"
+	"context"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"runtime"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
+	"github.com/google/trillian/crypto/sigpb"
 	"github.com/google/trillian/testonly"
 )
 
@@ -60,3 +83,1746 @@ func TestSignVerify(t *testing.T) {
 		}
 	}
 }
+
+func TestVerifyHashAlgorithms(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey()=%v", err)
+	}
+
+	msg := []byte("foo")
+	for _, test := range []struct {
+		desc     string
+		key      crypto.Signer
+		sigAlgo  sigpb.DigitallySigned_SignatureAlgorithm
+		hash     crypto.Hash
+		hashAlgo sigpb.DigitallySigned_HashAlgorithm
+	}{
+		{"ECDSA-SHA256", ecdsaKey, sigpb.DigitallySigned_ECDSA, crypto.SHA256, sigpb.DigitallySigned_SHA256},
+		{"ECDSA-SHA384", ecdsaKey, sigpb.DigitallySigned_ECDSA, crypto.SHA384, sigpb.DigitallySigned_SHA384},
+		{"ECDSA-SHA512", ecdsaKey, sigpb.DigitallySigned_ECDSA, crypto.SHA512, sigpb.DigitallySigned_SHA512},
+		{"ECDSA-SHA3-256", ecdsaKey, sigpb.DigitallySigned_ECDSA, crypto.SHA3_256, sigpb.DigitallySigned_SHA3_256},
+		{"RSA-SHA256", rsaKey, sigpb.DigitallySigned_RSA, crypto.SHA256, sigpb.DigitallySigned_SHA256},
+		{"RSA-SHA384", rsaKey, sigpb.DigitallySigned_RSA, crypto.SHA384, sigpb.DigitallySigned_SHA384},
+		{"RSA-SHA512", rsaKey, sigpb.DigitallySigned_RSA, crypto.SHA512, sigpb.DigitallySigned_SHA512},
+		{"RSA-SHA3-256", rsaKey, sigpb.DigitallySigned_RSA, crypto.SHA3_256, sigpb.DigitallySigned_SHA3_256},
+	} {
+		h := test.hash.New()
+		h.Write(msg)
+		sigBytes, err := test.key.Sign(rand.Reader, h.Sum(nil), test.hash)
+		if err != nil {
+			t.Errorf("%v: Sign()=%v", test.desc, err)
+			continue
+		}
+		sig := &sigpb.DigitallySigned{
+			SignatureAlgorithm: test.sigAlgo,
+			HashAlgorithm:      test.hashAlgo,
+			Signature:          sigBytes,
+		}
+		if err := Verify(test.key.Public(), msg, sig); err != nil {
+			t.Errorf("%v: Verify(,,)=%v, want nil", test.desc, err)
+		}
+		if err := VerifyStream(test.key.Public(), bytes.NewReader(msg), sig); err != nil {
+			t.Errorf("%v: VerifyStream(,,)=%v, want nil", test.desc, err)
+		}
+
+		// A signature must not verify when it claims a different hash algorithm.
+		sig.HashAlgorithm = sigpb.DigitallySigned_SHA256
+		if test.hashAlgo == sigpb.DigitallySigned_SHA256 {
+			sig.HashAlgorithm = sigpb.DigitallySigned_SHA512
+		}
+		if err := Verify(test.key.Public(), msg, sig); !errors.Is(err, ErrVerifyFailed) {
+			t.Errorf("%v: Verify(,,) with hash %v=%v, want %v", test.desc, sig.HashAlgorithm, err, ErrVerifyFailed)
+		}
+	}
+}
+
+func TestVerifyErrors(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey()=%v", err)
+	}
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey()=%v", err)
+	}
+
+	msg := []byte("foo")
+	sign := func(key crypto.PrivateKey) *sigpb.DigitallySigned {
+		km, err := NewFromPrivateKey(key)
+		if err != nil {
+			t.Fatalf("NewFromPrivateKey()=%v", err)
+		}
+		signed, err := NewSignerFromPrivateKeyManager(km).Sign(msg)
+		if err != nil {
+			t.Fatalf("Sign()=(_,%v), want (_,nil)", err)
+		}
+		return signed
+	}
+	ecdsaSigned := sign(ecdsaKey)
+	rsaSigned := sign(rsaKey)
+
+	for _, test := range []struct {
+		desc    string
+		pub     crypto.PublicKey
+		data    []byte
+		sig     *sigpb.DigitallySigned
+		wantErr error
+	}{
+		{
+			desc:    "ECDSA bad signature",
+			pub:     ecdsaKey.Public(),
+			data:    []byte("bar"),
+			sig:     ecdsaSigned,
+			wantErr: ErrVerifyFailed,
+		},
+		{
+			desc:    "RSA bad signature",
+			pub:     rsaKey.Public(),
+			data:    []byte("bar"),
+			sig:     rsaSigned,
+			wantErr: ErrVerifyFailed,
+		},
+		{
+			desc:    "ECDSA signature with RSA key",
+			pub:     rsaKey.Public(),
+			data:    msg,
+			sig:     ecdsaSigned,
+			wantErr: ErrAlgorithmMismatch,
+		},
+		{
+			desc:    "RSA signature with ECDSA key",
+			pub:     ecdsaKey.Public(),
+			data:    msg,
+			sig:     rsaSigned,
+			wantErr: ErrAlgorithmMismatch,
+		},
+		{
+			desc:    "ECDSA signature with Ed25519 key",
+			pub:     edPub,
+			data:    msg,
+			sig:     ecdsaSigned,
+			wantErr: ErrAlgorithmMismatch,
+		},
+		{
+			desc: "unsupported hash",
+			pub:  ecdsaKey.Public(),
+			data: msg,
+			sig: &sigpb.DigitallySigned{
+				SignatureAlgorithm: sigpb.DigitallySigned_ECDSA,
+				HashAlgorithm:      sigpb.DigitallySigned_NONE,
+				Signature:          ecdsaSigned.Signature,
+			},
+			wantErr: ErrUnsupportedAlgorithm,
+		},
+		{
+			desc:    "unsupported key type",
+			pub:     "not a key",
+			data:    msg,
+			sig:     ecdsaSigned,
+			wantErr: ErrUnsupportedAlgorithm,
+		},
+	} {
+		if err := Verify(test.pub, test.data, test.sig); !errors.Is(err, test.wantErr) {
+			t.Errorf("%v: Verify()=%v, want %v", test.desc, err, test.wantErr)
+		}
+	}
+}
+
+func TestVerifyJSON(t *testing.T) {
+	km, err := NewFromPrivatePEM(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("NewFromPrivatePEM()=%v", err)
+	}
+	signer := NewSignerFromPrivateKeyManager(km)
+	pub := km.Public()
+
+	obj := struct {
+		Name  string
+		Count int
+		Tags  []string
+	}{Name: "foo", Count: 3, Tags: []string{"a", "b"}}
+	signed, err := signer.SignObject(obj)
+	if err != nil {
+		t.Fatalf("SignObject()=(_,%v), want (_,nil)", err)
+	}
+	if err := VerifyObject(pub, obj, signed); err != nil {
+		t.Fatalf("VerifyObject()=%v, want nil", err)
+	}
+
+	for _, test := range []struct {
+		desc    string
+		json    string
+		wantErr bool
+	}{
+		{desc: "same encoding", json: `{"Name":"foo","Count":3,"Tags":["a","b"]}`},
+		{desc: "reordered with whitespace", json: `{ "Tags": ["a", "b"], "Count": 3, "Name": "foo" }`},
+		{desc: "different value", json: `{"Name":"bar","Count":3,"Tags":["a","b"]}`, wantErr: true},
+		{desc: "invalid JSON", json: `{"Name":"foo"`, wantErr: true},
+		{desc: "empty", json: ``, wantErr: true},
+	} {
+		err := VerifyJSON(pub, []byte(test.json), signed)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%v: VerifyJSON()=%v, want err? %v", test.desc, err, test.wantErr)
+		}
+	}
+}
+
+func TestVerifyRedactedObject(t *testing.T) {
+	km, err := NewFromPrivatePEM(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("NewFromPrivatePEM()=%v", err)
+	}
+	signer := NewSignerFromPrivateKeyManager(km)
+	pub := km.Public()
+
+	type meta struct {
+		Origin string
+		Shards []int
+	}
+	obj := struct {
+		Name     string
+		TreeSize int
+		RootHash []byte
+		Meta     meta
+	}{Name: "log", TreeSize: 17, RootHash: []byte("root hash"), Meta: meta{Origin: "example.com", Shards: []int{1, 2}}}
+	signed, err := signer.SignObject(obj)
+	if err != nil {
+		t.Fatalf("SignObject()=(_,%v), want (_,nil)", err)
+	}
+
+	redact := func(v interface{}) string {
+		r, err := Redact(v)
+		if err != nil {
+			t.Fatalf("Redact(%v)=(_,%v), want (_,nil)", v, err)
+		}
+		return r
+	}
+	metaFields := map[string]interface{}{"Origin": obj.Meta.Origin, "Shards": obj.Meta.Shards}
+	fields := func(changes map[string]interface{}) map[string]interface{} {
+		m := map[string]interface{}{"Name": obj.Name, "TreeSize": obj.TreeSize, "RootHash": obj.RootHash, "Meta": metaFields}
+		for k, v := range changes {
+			m[k] = v
+		}
+		return m
+	}
+
+	for _, test := range []struct {
+		desc    string
+		obj     interface{}
+		wantErr error
+	}{
+		{desc: "not redacted", obj: obj},
+		{desc: "not redacted map", obj: fields(nil)},
+		{desc: "redacted string", obj: fields(map[string]interface{}{"Name": redact(obj.Name)})},
+		{desc: "redacted bytes", obj: fields(map[string]interface{}{"RootHash": redact(obj.RootHash)})},
+		{desc: "redacted object", obj: fields(map[string]interface{}{"Meta": redact(obj.Meta)})},
+		{desc: "redacted nested field", obj: fields(map[string]interface{}{"Meta": map[string]interface{}{"Origin": redact(obj.Meta.Origin), "Shards": obj.Meta.Shards}})},
+		{desc: "redacted list element", obj: fields(map[string]interface{}{"Meta": map[string]interface{}{"Origin": obj.Meta.Origin, "Shards": []interface{}{1, redact(2)}}})},
+		{desc: "all redacted", obj: map[string]interface{}{"Name": redact(obj.Name), "TreeSize": redact(obj.TreeSize), "RootHash": redact(obj.RootHash), "Meta": redact(obj.Meta)}},
+		{desc: "redacted other value", obj: fields(map[string]interface{}{"TreeSize": redact(18)}), wantErr: ErrVerifyFailed},
+		{desc: "different value", obj: fields(map[string]interface{}{"Name": "other"}), wantErr: ErrVerifyFailed},
+		{desc: "missing field", obj: map[string]interface{}{"Name": obj.Name}, wantErr: ErrVerifyFailed},
+	} {
+		if err := VerifyRedactedObject(pub, test.obj, signed); !errors.Is(err, test.wantErr) {
+			t.Errorf("%v: VerifyRedactedObject()=%v, want %v", test.desc, err, test.wantErr)
+		}
+	}
+
+	hash := strings.TrimPrefix(redact(obj.Name), RedactedPrefix)
+	for _, test := range []struct {
+		desc string
+		obj  interface{}
+	}{
+		{desc: "not hex", obj: fields(map[string]interface{}{"Name": RedactedPrefix + "zz"})},
+		{desc: "short hash", obj: fields(map[string]interface{}{"Name": RedactedPrefix + hash[:62]})},
+		{desc: "upper case hash", obj: fields(map[string]interface{}{"Name": RedactedPrefix + strings.ToUpper(hash)})},
+		{desc: "redacted key", obj: fields(map[string]interface{}{redact("Name"): obj.Name})},
+	} {
+		err := VerifyRedactedObject(pub, test.obj, signed)
+		if err == nil || errors.Is(err, ErrVerifyFailed) {
+			t.Errorf("%v: VerifyRedactedObject()=%v, want malformed redaction error", test.desc, err)
+		}
+	}
+}
+
+func TestVerifyObjectStream(t *testing.T) {
+	km, err := NewFromPrivatePEM(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("NewFromPrivatePEM()=%v", err)
+	}
+	signer := NewSignerFromPrivateKeyManager(km)
+	pub := km.Public()
+
+	type entry struct {
+		Path   string
+		Size   int
+		Hashes map[string]string
+	}
+	manifest := struct {
+		Name    string
+		Entries []entry
+	}{Name: "manifest"}
+	for i := 0; i < 10000; i++ {
+		manifest.Entries = append(manifest.Entries, entry{
+			Path:   fmt.Sprintf("dir%d/file%d", i%17, i),
+			Size:   i * 31,
+			Hashes: map[string]string{"sha256": fmt.Sprintf("%064x", i)},
+		})
+	}
+	signed, err := signer.SignObject(manifest)
+	if err != nil {
+		t.Fatalf("SignObject()=(_,%v), want (_,nil)", err)
+	}
+	if err := VerifyObject(pub, manifest, signed); err != nil {
+		t.Fatalf("VerifyObject()=%v, want nil", err)
+	}
+
+	encode := func() io.Reader {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(json.NewEncoder(pw).Encode(manifest))
+		}()
+		return pr
+	}
+
+	want, err := objectHashJSON(manifest)
+	if err != nil {
+		t.Fatalf("objectHashJSON()=_,%v, want nil", err)
+	}
+	got, err := objectHashJSONReader(encode())
+	if err != nil {
+		t.Fatalf("objectHashJSONReader()=_,%v, want nil", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("objectHashJSONReader()=%x, want %x", got, want)
+	}
+	if err := VerifyObjectStream(pub, encode(), signed); err != nil {
+		t.Errorf("VerifyObjectStream()=%v, want nil", err)
+	}
+
+	readErr := errors.New("read failed")
+	for _, test := range []struct {
+		desc string
+		r    io.Reader
+	}{
+		{desc: "different object", r: strings.NewReader(`{"Name":"manifest","Entries":[]}`)},
+		{desc: "invalid JSON", r: strings.NewReader(`{"Name":`)},
+		{desc: "trailing value", r: strings.NewReader(`{} {}`)},
+		{desc: "empty", r: strings.NewReader("")},
+		{desc: "read error", r: iotest.ErrReader(readErr)},
+	} {
+		if err := VerifyObjectStream(pub, test.r, signed); err == nil {
+			t.Errorf("%v: VerifyObjectStream()=nil, want error", test.desc)
+		}
+	}
+}
+
+func TestVerifyObjectWith(t *testing.T) {
+	km, err := NewFromPrivatePEM(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("NewFromPrivatePEM()=%v", err)
+	}
+	signer := NewSignerFromPrivateKeyManager(km)
+	pub := km.Public()
+
+	obj := struct{ Name string }{Name: "foo"}
+	signed, err := signer.SignObject(obj)
+	if err != nil {
+		t.Fatalf("SignObject()=(_,%v), want (_,nil)", err)
+	}
+	if err := VerifyObject(pub, obj, signed); err != nil {
+		t.Errorf("VerifyObject()=%v, want nil", err)
+	}
+
+	// A custom canonicalizer must be used in place of the JSON ObjectHash.
+	var canonCalls int
+	canon := func(o interface{}) ([]byte, error) {
+		canonCalls++
+		return []byte(o.(struct{ Name string }).Name), nil
+	}
+	customSigned, err := signer.Sign([]byte(obj.Name))
+	if err != nil {
+		t.Fatalf("Sign()=(_,%v), want (_,nil)", err)
+	}
+	if err := VerifyObjectWith(pub, obj, customSigned, canon); err != nil {
+		t.Errorf("VerifyObjectWith(custom)=%v, want nil", err)
+	}
+	if canonCalls != 1 {
+		t.Errorf("VerifyObjectWith() called canonicalizer %d times, want 1", canonCalls)
+	}
+	if err := VerifyObjectWith(pub, obj, signed, canon); !errors.Is(err, ErrVerifyFailed) {
+		t.Errorf("VerifyObjectWith(custom) of ObjectHash signature=%v, want %v", err, ErrVerifyFailed)
+	}
+	if err := VerifyObject(pub, obj, customSigned); !errors.Is(err, ErrVerifyFailed) {
+		t.Errorf("VerifyObject() of custom signature=%v, want %v", err, ErrVerifyFailed)
+	}
+
+	canonErr := errors.New("canonicalization failed")
+	failing := func(interface{}) ([]byte, error) { return nil, canonErr }
+	if err := VerifyObjectWith(pub, obj, customSigned, failing); err != canonErr {
+		t.Errorf("VerifyObjectWith(failing)=%v, want %v", err, canonErr)
+	}
+}
+
+func TestVerifyStream(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey()=%v", err)
+	}
+
+	// Several MB of data, so that it is hashed in many chunks.
+	data := make([]byte, 5<<20+17)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("rand.Read()=%v", err)
+	}
+
+	for _, test := range []struct {
+		desc string
+		key  crypto.Signer
+	}{
+		{"ECDSA", ecdsaKey},
+		{"Ed25519", ed25519Key},
+	} {
+		km, err := NewFromPrivateKey(test.key)
+		if err != nil {
+			t.Errorf("%v: NewFromPrivateKey()=%v", test.desc, err)
+			continue
+		}
+		signed, err := NewSignerFromPrivateKeyManager(km).Sign(data)
+		if err != nil {
+			t.Errorf("%v: Sign()=(_,%v), want (_,nil)", test.desc, err)
+			continue
+		}
+		pub := test.key.Public()
+
+		if err := VerifyStream(pub, bytes.NewReader(data), signed); err != nil {
+			t.Errorf("%v: VerifyStream()=%v, want nil", test.desc, err)
+		}
+		if err := Verify(pub, data, signed); err != nil {
+			t.Errorf("%v: Verify()=%v, want nil", test.desc, err)
+		}
+
+		// Both paths must reject the same modified data.
+		modified := append([]byte(nil), data...)
+		modified[len(modified)/2] ^= 0x01
+		streamErr := VerifyStream(pub, bytes.NewReader(modified), signed)
+		memErr := Verify(pub, modified, signed)
+		if !errors.Is(streamErr, ErrVerifyFailed) || !errors.Is(memErr, ErrVerifyFailed) {
+			t.Errorf("%v: VerifyStream()=%v, Verify()=%v over modified data, want errors", test.desc, streamErr, memErr)
+		}
+	}
+}
+
+func TestSignVerifyEd25519(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey()=%v", err)
+	}
+	km, err := NewFromPrivateKey(priv)
+	if err != nil {
+		t.Fatalf("NewFromPrivateKey()=%v", err)
+	}
+	signer := NewSignerFromPrivateKeyManager(km)
+
+	msg := []byte("foo")
+	signed, err := signer.Sign(msg)
+	if err != nil {
+		t.Fatalf("Sign()=(_,%v), want (_,nil)", err)
+	}
+	if got, want := signed.SignatureAlgorithm, sigpb.DigitallySigned_ED25519; got != want {
+		t.Errorf("SignatureAlgorithm=%v, want %v", got, want)
+	}
+	if got, want := signed.HashAlgorithm, sigpb.DigitallySigned_NONE; got != want {
+		t.Errorf("HashAlgorithm=%v, want %v", got, want)
+	}
+	if err := Verify(pub, msg, signed); err != nil {
+		t.Errorf("Verify(,,)=%v, want nil", err)
+	}
+	if err := Verify(pub, []byte("bar"), signed); !errors.Is(err, ErrVerifyFailed) {
+		t.Errorf("Verify(,,) over different data=%v, want %v", err, ErrVerifyFailed)
+	}
+
+	signed.HashAlgorithm = sigpb.DigitallySigned_SHA256
+	if err := Verify(pub, msg, signed); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Errorf("Verify(,,) with hash algorithm %v=%v, want %v", signed.HashAlgorithm, err, ErrUnsupportedAlgorithm)
+	}
+}
+
+func TestSignVerifyRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey()=%v", err)
+	}
+
+	msg := []byte("foo")
+	for _, test := range []struct {
+		desc      string
+		sigAlgo   sigpb.DigitallySigned_SignatureAlgorithm
+		otherAlgo sigpb.DigitallySigned_SignatureAlgorithm
+	}{
+		{"PKCS#1 v1.5", sigpb.DigitallySigned_RSA, sigpb.DigitallySigned_RSA_PSS},
+		{"PSS", sigpb.DigitallySigned_RSA_PSS, sigpb.DigitallySigned_RSA},
+	} {
+		signed, err := NewSigner(test.sigAlgo, key).Sign(msg)
+		if err != nil {
+			t.Errorf("%v: Sign()=(_,%v), want (_,nil)", test.desc, err)
+			continue
+		}
+		if got, want := signed.SignatureAlgorithm, test.sigAlgo; got != want {
+			t.Errorf("%v: SignatureAlgorithm=%v, want %v", test.desc, got, want)
+		}
+		if err := Verify(key.Public(), msg, signed); err != nil {
+			t.Errorf("%v: Verify()=%v, want nil", test.desc, err)
+		}
+		if err := Verify(key.Public(), []byte("bar"), signed); !errors.Is(err, ErrVerifyFailed) {
+			t.Errorf("%v: Verify() over different data=%v, want %v", test.desc, err, ErrVerifyFailed)
+		}
+
+		// The padding scheme is part of the signature algorithm.
+		signed.SignatureAlgorithm = test.otherAlgo
+		if err := Verify(key.Public(), msg, signed); !errors.Is(err, ErrVerifyFailed) {
+			t.Errorf("%v: Verify() as %v=%v, want %v", test.desc, test.otherAlgo, err, ErrVerifyFailed)
+		}
+	}
+
+	// PSS signatures with any salt length are accepted.
+	digest := sha256.Sum256(msg)
+	sigBytes, err := rsa.SignPSS(rand.Reader, key, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+	if err != nil {
+		t.Fatalf("rsa.SignPSS()=%v", err)
+	}
+	sig := &sigpb.DigitallySigned{
+		SignatureAlgorithm: sigpb.DigitallySigned_RSA_PSS,
+		HashAlgorithm:      sigpb.DigitallySigned_SHA256,
+		Signature:          sigBytes,
+	}
+	if err := Verify(key.Public(), msg, sig); err != nil {
+		t.Errorf("Verify() of PSS signature with maximal salt=%v, want nil", err)
+	}
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	if err := Verify(ecdsaKey.Public(), msg, sig); !errors.Is(err, ErrAlgorithmMismatch) {
+		t.Errorf("Verify() of PSS signature with ECDSA key=%v, want %v", err, ErrAlgorithmMismatch)
+	}
+}
+
+func TestConstantTimeEqual(t *testing.T) {
+	for _, test := range []struct {
+		a, b []byte
+		want bool
+	}{
+		{a: nil, b: nil, want: true},
+		{a: nil, b: []byte{}, want: true},
+		{a: []byte{1, 2, 3}, b: []byte{1, 2, 3}, want: true},
+		{a: []byte{1, 2, 3}, b: []byte{1, 2, 4}, want: false},
+		{a: []byte{1, 2, 3}, b: []byte{0, 2, 3}, want: false},
+		{a: []byte{1, 2, 3}, b: []byte{1, 2}, want: false},
+		{a: []byte{}, b: []byte{0}, want: false},
+	} {
+		if got := ConstantTimeEqual(test.a, test.b); got != test.want {
+			t.Errorf("ConstantTimeEqual(%x, %x)=%v, want %v", test.a, test.b, got, test.want)
+		}
+		if got, want := ConstantTimeEqual(test.a, test.b), bytes.Equal(test.a, test.b); got != want {
+			t.Errorf("ConstantTimeEqual(%x, %x)=%v, but bytes.Equal()=%v", test.a, test.b, got, want)
+		}
+	}
+}
+
+func TestVerifyECDSAEncodings(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	digest := sha256.Sum256([]byte("foo"))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("ecdsa.Sign()=%v", err)
+	}
+	if halfOrder := new(big.Int).Rsh(key.Curve.Params().N, 1); s.Cmp(halfOrder) > 0 {
+		s.Sub(key.Curve.Params().N, s)
+	}
+	der, err := asn1.Marshal(ecdsaSig{R: r, S: s})
+	if err != nil {
+		t.Fatalf("asn1.Marshal()=%v", err)
+	}
+	other, err := asn1.Marshal(ecdsaSig{R: r, S: new(big.Int).Add(s, big.NewInt(1))})
+	if err != nil {
+		t.Fatalf("asn1.Marshal()=%v", err)
+	}
+	// A non-minimal length for the SEQUENCE, which BER allows but DER does not.
+	longLength := append([]byte{0x30, 0x81, der[1]}, der[2:]...)
+
+	for _, test := range []struct {
+		desc    string
+		sig     []byte
+		wantErr bool
+	}{
+		{desc: "valid", sig: der},
+		{desc: "mismatch", sig: other, wantErr: true},
+		{desc: "empty", sig: []byte{}, wantErr: true},
+		{desc: "truncated", sig: der[:len(der)-1], wantErr: true},
+		{desc: "trailing data", sig: append(append([]byte{}, der...), 0), wantErr: true},
+		{desc: "long length", sig: longLength, wantErr: true},
+		{desc: "not ASN.1", sig: []byte("signature"), wantErr: true},
+	} {
+		for _, requireLowS := range []bool{false, true} {
+			err := verifyECDSA(&key.PublicKey, digest[:], test.sig, requireLowS)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("%v: verifyECDSA(lowS=%v)=%v, want err? %v", test.desc, requireLowS, err, test.wantErr)
+			}
+			if err != nil && err != ErrVerifyFailed {
+				t.Errorf("%v: verifyECDSA(lowS=%v)=%v, want %v", test.desc, requireLowS, err, ErrVerifyFailed)
+			}
+		}
+	}
+}
+
+func TestVerifyRequireLowS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	km, err := NewFromPrivateKey(key)
+	if err != nil {
+		t.Fatalf("NewFromPrivateKey()=%v", err)
+	}
+	msg := []byte("foo")
+	signed, err := NewSignerFromPrivateKeyManager(km).Sign(msg)
+	if err != nil {
+		t.Fatalf("Sign()=(_,%v), want (_,nil)", err)
+	}
+
+	// Build both the low-S and high-S forms of the same signature.
+	var sig ecdsaSig
+	if _, err := asn1.Unmarshal(signed.Signature, &sig); err != nil {
+		t.Fatalf("asn1.Unmarshal()=%v", err)
+	}
+	n := key.Curve.Params().N
+	lowS, highS := sig.S, new(big.Int).Sub(n, sig.S)
+	if lowS.Cmp(highS) > 0 {
+		lowS, highS = highS, lowS
+	}
+	encode := func(s *big.Int) *sigpb.DigitallySigned {
+		b, err := asn1.Marshal(ecdsaSig{R: sig.R, S: s})
+		if err != nil {
+			t.Fatalf("asn1.Marshal()=%v", err)
+		}
+		return &sigpb.DigitallySigned{
+			SignatureAlgorithm: signed.SignatureAlgorithm,
+			HashAlgorithm:      signed.HashAlgorithm,
+			Signature:          b,
+		}
+	}
+
+	for _, test := range []struct {
+		desc    string
+		sig     *sigpb.DigitallySigned
+		opts    VerifyOptions
+		wantErr bool
+	}{
+		{desc: "low-S", sig: encode(lowS)},
+		{desc: "high-S", sig: encode(highS)},
+		{desc: "low-S required", sig: encode(lowS), opts: VerifyOptions{RequireLowS: true}},
+		{desc: "high-S rejected", sig: encode(highS), opts: VerifyOptions{RequireLowS: true}, wantErr: true},
+	} {
+		err := VerifyWithOptions(key.Public(), msg, test.sig, test.opts)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%v: VerifyWithOptions()=%v, want err? %v", test.desc, err, test.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrVerifyFailed) {
+			t.Errorf("%v: VerifyWithOptions()=%v, want %v", test.desc, err, ErrVerifyFailed)
+		}
+	}
+}
+
+func TestVerifyMinRSAKeyBits(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey()=%v", err)
+	}
+	km, err := NewFromPrivateKey(key)
+	if err != nil {
+		t.Fatalf("NewFromPrivateKey()=%v", err)
+	}
+	msg := []byte("foo")
+	signed, err := NewSignerFromPrivateKeyManager(km).Sign(msg)
+	if err != nil {
+		t.Fatalf("Sign()=(_,%v), want (_,nil)", err)
+	}
+
+	for _, test := range []struct {
+		desc    string
+		opts    VerifyOptions
+		wantErr error
+	}{
+		{desc: "default", wantErr: ErrRSAKeyTooSmall},
+		{desc: "explicit minimum", opts: VerifyOptions{MinRSAKeyBits: 2048}, wantErr: ErrRSAKeyTooSmall},
+		{desc: "lowered minimum", opts: VerifyOptions{MinRSAKeyBits: 1024}},
+	} {
+		if err := VerifyWithOptions(key.Public(), msg, signed, test.opts); !errors.Is(err, test.wantErr) {
+			t.Errorf("%v: VerifyWithOptions()=%v, want %v", test.desc, err, test.wantErr)
+		}
+	}
+}
+
+func TestVerifyAllowedCurves(t *testing.T) {
+	msg := []byte("foo")
+	for _, test := range []struct {
+		desc    string
+		curve   elliptic.Curve
+		opts    VerifyOptions
+		wantErr error
+	}{
+		{desc: "P-224", curve: elliptic.P224(), wantErr: ErrCurveNotAllowed},
+		{desc: "P-256", curve: elliptic.P256()},
+		{desc: "P-384", curve: elliptic.P384()},
+		{desc: "P-521", curve: elliptic.P521()},
+		{desc: "P-224 allowed", curve: elliptic.P224(), opts: VerifyOptions{AllowedCurves: []elliptic.Curve{elliptic.P224()}}},
+		{desc: "P-256 not allowed", curve: elliptic.P256(), opts: VerifyOptions{AllowedCurves: []elliptic.Curve{elliptic.P384()}}, wantErr: ErrCurveNotAllowed},
+	} {
+		key, err := ecdsa.GenerateKey(test.curve, rand.Reader)
+		if err != nil {
+			t.Errorf("%v: ecdsa.GenerateKey()=%v", test.desc, err)
+			continue
+		}
+		signed, err := NewSigner(sigpb.DigitallySigned_ECDSA, key).Sign(msg)
+		if err != nil {
+			t.Errorf("%v: Sign()=(_,%v), want (_,nil)", test.desc, err)
+			continue
+		}
+		if err := VerifyWithOptions(key.Public(), msg, signed, test.opts); !errors.Is(err, test.wantErr) {
+			t.Errorf("%v: VerifyWithOptions()=%v, want %v", test.desc, err, test.wantErr)
+		}
+		if _, err := NewVerifierWithOptions(key.Public(), test.opts); !errors.Is(err, test.wantErr) {
+			t.Errorf("%v: NewVerifierWithOptions()=(_,%v), want (_,%v)", test.desc, err, test.wantErr)
+		}
+	}
+}
+
+func TestPublicKeyFromDER(t *testing.T) {
+	block, _ := pem.Decode([]byte(testonly.DemoPublicKey))
+	if block == nil {
+		t.Fatal("pem.Decode() failed on DemoPublicKey")
+	}
+
+	for _, test := range []struct {
+		desc    string
+		der     []byte
+		wantErr bool
+	}{
+		{desc: "valid", der: block.Bytes},
+		{desc: "truncated", der: block.Bytes[:len(block.Bytes)/2], wantErr: true},
+		{desc: "garbage", der: []byte("not a key"), wantErr: true},
+		{desc: "empty", der: nil, wantErr: true},
+	} {
+		pub, err := PublicKeyFromDER(test.der)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%v: PublicKeyFromDER()=(_, %v), want err? %v", test.desc, err, test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			t.Errorf("%v: MarshalPKIXPublicKey()=%v", test.desc, err)
+			continue
+		}
+		if got, want := der, test.der; !bytes.Equal(got, want) {
+			t.Errorf("%v: PublicKeyFromDER() did not round trip", test.desc)
+		}
+	}
+}
+
+// selfSignedCertPEM returns a PEM encoded self-signed certificate for key.
+func selfSignedCertPEM(t *testing.T, key *ecdsa.PrivateKey) string {
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate()=%v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestPublicKeyFromPEM(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatalf("x509.MarshalPKIXPublicKey()=%v", err)
+	}
+	pubPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	certPEM := selfSignedCertPEM(t, key)
+
+	for _, test := range []struct {
+		desc    string
+		pem     string
+		wantErr bool
+	}{
+		{desc: "public key", pem: pubPEM},
+		{desc: "certificate", pem: certPEM},
+		{desc: "bad certificate", pem: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), wantErr: true},
+		{desc: "not PEM", pem: "not PEM", wantErr: true},
+		{desc: "trailing data", pem: pubPEM + "trailing", wantErr: true},
+		{desc: "two keys", pem: pubPEM + certPEM, wantErr: true},
+		{desc: "no key block", pem: string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})), wantErr: true},
+	} {
+		pub, err := PublicKeyFromPEM(test.pem)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%v: PublicKeyFromPEM()=(_, %v), want err? %v", test.desc, err, test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		ecdsaPub, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			t.Errorf("%v: PublicKeyFromPEM()=%T, want *ecdsa.PublicKey", test.desc, pub)
+			continue
+		}
+		if ecdsaPub.X.Cmp(key.X) != 0 || ecdsaPub.Y.Cmp(key.Y) != 0 {
+			t.Errorf("%v: PublicKeyFromPEM() returned the wrong key", test.desc)
+		}
+	}
+}
+
+// issueCert returns a certificate for pub, signed by parent's key, and its PEM
+// encoding. A nil parent makes the certificate self-signed by key.
+func issueCert(t *testing.T, name string, isCA bool, pub crypto.PublicKey, parent *x509.Certificate, key crypto.Signer) (*x509.Certificate, string) {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: isCA,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent = tmpl
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate(%s)=%v", name, err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("x509.ParseCertificate(%s)=%v", name, err)
+	}
+	return cert, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestVerifyObjectWithCertChain(t *testing.T) {
+	var keys []*ecdsa.PrivateKey
+	for i := 0; i < 4; i++ {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("ecdsa.GenerateKey()=%v", err)
+		}
+		keys = append(keys, key)
+	}
+	rootKey, intermediateKey, leafKey, otherKey := keys[0], keys[1], keys[2], keys[3]
+	root, _ := issueCert(t, "root", true, rootKey.Public(), nil, rootKey)
+	intermediate, intermediatePEM := issueCert(t, "intermediate", true, intermediateKey.Public(), root, rootKey)
+	_, leafPEM := issueCert(t, "leaf", false, leafKey.Public(), root, rootKey)
+	_, chainedLeafPEM := issueCert(t, "chained leaf", false, leafKey.Public(), intermediate, intermediateKey)
+	_, untrustedPEM := issueCert(t, "untrusted", false, leafKey.Public(), nil, leafKey)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	obj := struct{ Name string }{"foo"}
+	km, err := NewFromPrivateKey(leafKey)
+	if err != nil {
+		t.Fatalf("NewFromPrivateKey()=%v", err)
+	}
+	sig, err := NewSignerFromPrivateKeyManager(km).SignObject(obj)
+	if err != nil {
+		t.Fatalf("SignObject()=(_,%v), want (_,nil)", err)
+	}
+	otherKM, err := NewFromPrivateKey(otherKey)
+	if err != nil {
+		t.Fatalf("NewFromPrivateKey()=%v", err)
+	}
+	otherSig, err := NewSignerFromPrivateKeyManager(otherKM).SignObject(obj)
+	if err != nil {
+		t.Fatalf("SignObject()=(_,%v), want (_,nil)", err)
+	}
+
+	for _, test := range []struct {
+		desc    string
+		certPEM string
+		sig     *sigpb.DigitallySigned
+		wantErr error
+	}{
+		{desc: "valid chain", certPEM: leafPEM, sig: sig},
+		{desc: "valid chain with intermediate", certPEM: chainedLeafPEM + intermediatePEM, sig: sig},
+		{desc: "missing intermediate", certPEM: chainedLeafPEM, sig: sig, wantErr: ErrCertChain},
+		{desc: "untrusted leaf", certPEM: untrustedPEM, sig: sig, wantErr: ErrCertChain},
+		{desc: "not a certificate", certPEM: testonly.DemoPublicKey, sig: sig, wantErr: ErrCertChain},
+		{desc: "not PEM", certPEM: "not PEM", sig: sig, wantErr: ErrCertChain},
+		{desc: "bad signature", certPEM: leafPEM, sig: otherSig, wantErr: ErrVerifyFailed},
+	} {
+		err := VerifyObjectWithCertChain(roots, test.certPEM, obj, test.sig)
+		if test.wantErr == nil {
+			if err != nil {
+				t.Errorf("%v: VerifyObjectWithCertChain()=%v, want nil", test.desc, err)
+			}
+			continue
+		}
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%v: VerifyObjectWithCertChain()=%v, want %v", test.desc, err, test.wantErr)
+		}
+		// Chain failures and signature failures must be distinguishable.
+		if test.wantErr == ErrCertChain && errors.Is(err, ErrVerifyFailed) {
+			t.Errorf("%v: VerifyObjectWithCertChain()=%v, want not %v", test.desc, err, ErrVerifyFailed)
+		}
+		if test.wantErr == ErrVerifyFailed && errors.Is(err, ErrCertChain) {
+			t.Errorf("%v: VerifyObjectWithCertChain()=%v, want not %v", test.desc, err, ErrCertChain)
+		}
+	}
+}
+
+func TestCertificateFromPEM(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	certPEM := selfSignedCertPEM(t, key)
+
+	cert, err := CertificateFromPEM(certPEM)
+	if err != nil {
+		t.Fatalf("CertificateFromPEM()=(_, %v), want (_, nil)", err)
+	}
+	if got, want := cert.Subject.CommonName, "test"; got != want {
+		t.Errorf("CommonName=%v, want %v", got, want)
+	}
+
+	if _, err := CertificateFromPEM(testonly.DemoPublicKey); err == nil {
+		t.Errorf("CertificateFromPEM(DemoPublicKey)=(_, nil), want error")
+	}
+}
+
+func TestPublicKeyFromReader(t *testing.T) {
+	for _, test := range []struct {
+		desc    string
+		r       io.Reader
+		wantErr bool
+	}{
+		{desc: "strings.Reader", r: strings.NewReader(testonly.DemoPublicKey)},
+		{desc: "bytes.Buffer", r: bytes.NewBufferString(testonly.DemoPublicKey)},
+		{desc: "empty", r: &bytes.Buffer{}, wantErr: true},
+		{desc: "not PEM", r: strings.NewReader("not a key"), wantErr: true},
+		{desc: "read error", r: iotest.TimeoutReader(iotest.HalfReader(strings.NewReader(testonly.DemoPublicKey))), wantErr: true},
+	} {
+		pub, err := PublicKeyFromReader(test.r)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%v: PublicKeyFromReader()=(_, %v), want err? %v", test.desc, err, test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if _, ok := pub.(*ecdsa.PublicKey); !ok {
+			t.Errorf("%v: PublicKeyFromReader()=%T, want *ecdsa.PublicKey", test.desc, pub)
+		}
+	}
+}
+
+func TestPublicKeysFromPEM(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	certPEM := selfSignedCertPEM(t, key)
+	privDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("x509.MarshalECPrivateKey()=%v", err)
+	}
+	privPEM := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: privDER}))
+
+	for _, test := range []struct {
+		desc     string
+		pem      string
+		wantKeys int
+		wantErr  bool
+	}{
+		{desc: "single key", pem: testonly.DemoPublicKey, wantKeys: 1},
+		{desc: "trailing comment", pem: testonly.DemoPublicKey + "\n# demo key\n", wantKeys: 1},
+		{desc: "key and certificate", pem: testonly.DemoPublicKey + "\n" + certPEM, wantKeys: 2},
+		{desc: "non-key block skipped", pem: privPEM + testonly.DemoPublicKey, wantKeys: 1},
+		{desc: "no keys", pem: privPEM, wantErr: true},
+		{desc: "empty", pem: "", wantErr: true},
+		{desc: "bad key", pem: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("bad")})), wantErr: true},
+	} {
+		keys, err := PublicKeysFromPEM(test.pem)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%v: PublicKeysFromPEM()=(_, %v), want err? %v", test.desc, err, test.wantErr)
+			continue
+		}
+		if got, want := len(keys), test.wantKeys; got != want {
+			t.Errorf("%v: PublicKeysFromPEM() returned %d keys, want %d", test.desc, got, want)
+		}
+	}
+
+	// PublicKeyFromPEM remains strict about extra data.
+	if _, err := PublicKeyFromPEM(testonly.DemoPublicKey + "\n" + certPEM); err == nil {
+		t.Errorf("PublicKeyFromPEM() with two blocks=(_, nil), want error")
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey()=%v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey()=%v", err)
+	}
+
+	msg := []byte("foo")
+	for _, test := range []struct {
+		desc string
+		key  crypto.Signer
+	}{
+		{"ECDSA", ecdsaKey},
+		{"RSA", rsaKey},
+		{"Ed25519", edKey},
+	} {
+		km, err := NewFromPrivateKey(test.key)
+		if err != nil {
+			t.Errorf("%v: NewFromPrivateKey()=%v", test.desc, err)
+			continue
+		}
+		signed, err := NewSignerFromPrivateKeyManager(km).Sign(msg)
+		if err != nil {
+			t.Errorf("%v: Sign()=(_,%v), want (_,nil)", test.desc, err)
+			continue
+		}
+		v, err := NewVerifier(test.key.Public())
+		if err != nil {
+			t.Errorf("%v: NewVerifier()=(_,%v), want (_,nil)", test.desc, err)
+			continue
+		}
+		if err := v.Verify(msg, signed); err != nil {
+			t.Errorf("%v: Verifier.Verify()=%v, want nil", test.desc, err)
+		}
+		if err := v.Verify([]byte("bar"), signed); !errors.Is(err, ErrVerifyFailed) {
+			t.Errorf("%v: Verifier.Verify() over different data=%v, want %v", test.desc, err, ErrVerifyFailed)
+		}
+		mismatched := *signed
+		mismatched.SignatureAlgorithm = sigpb.DigitallySigned_ANONYMOUS
+		if err := v.Verify(msg, &mismatched); !errors.Is(err, ErrAlgorithmMismatch) {
+			t.Errorf("%v: Verifier.Verify() with algorithm %v=%v, want %v", test.desc, mismatched.SignatureAlgorithm, err, ErrAlgorithmMismatch)
+		}
+	}
+}
+
+func TestNewVerifierErrors(t *testing.T) {
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey()=%v", err)
+	}
+
+	for _, test := range []struct {
+		desc    string
+		pub     crypto.PublicKey
+		opts    VerifyOptions
+		wantErr error
+	}{
+		{desc: "unsupported key type", pub: "not a key", wantErr: ErrUnsupportedAlgorithm},
+		{desc: "nil key", pub: nil, wantErr: ErrUnsupportedAlgorithm},
+		{desc: "small RSA key", pub: smallKey.Public(), wantErr: ErrRSAKeyTooSmall},
+		{desc: "small RSA key allowed", pub: smallKey.Public(), opts: VerifyOptions{MinRSAKeyBits: 1024}},
+	} {
+		if _, err := NewVerifierWithOptions(test.pub, test.opts); !errors.Is(err, test.wantErr) {
+			t.Errorf("%v: NewVerifierWithOptions()=(_,%v), want (_,%v)", test.desc, err, test.wantErr)
+		}
+	}
+}
+
+func TestVerifyBatch(t *testing.T) {
+	km, err := NewFromPrivatePEM(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("NewFromPrivatePEM()=%v", err)
+	}
+	signer := NewSignerFromPrivateKeyManager(km)
+	pub := km.Public()
+
+	var items []VerifyItem
+	var wantErrs []error
+	for i := 0; i < 50; i++ {
+		data := []byte(fmt.Sprintf("entry %d", i))
+		signed, err := signer.Sign(data)
+		if err != nil {
+			t.Fatalf("Sign()=(_,%v), want (_,nil)", err)
+		}
+		var wantErr error
+		switch i % 5 {
+		case 1:
+			// Signature over different data.
+			data = []byte("tampered")
+			wantErr = ErrVerifyFailed
+		case 3:
+			signed.SignatureAlgorithm = sigpb.DigitallySigned_RSA
+			wantErr = ErrAlgorithmMismatch
+		}
+		items = append(items, VerifyItem{Data: data, Sig: signed})
+		wantErrs = append(wantErrs, wantErr)
+	}
+
+	errs := VerifyBatch(pub, items)
+	if got, want := len(errs), len(items); got != want {
+		t.Fatalf("VerifyBatch() returned %d errors, want %d", got, want)
+	}
+	for i, err := range errs {
+		if !errors.Is(err, wantErrs[i]) {
+			t.Errorf("VerifyBatch()[%d]=%v, want %v", i, err, wantErrs[i])
+		}
+	}
+
+	// An unsupported key fails every item.
+	for i, err := range VerifyBatch("not a key", items[:3]) {
+		if !errors.Is(err, ErrUnsupportedAlgorithm) {
+			t.Errorf("VerifyBatch(bad key)[%d]=%v, want %v", i, err, ErrUnsupportedAlgorithm)
+		}
+	}
+	if errs := VerifyBatch(pub, nil); len(errs) != 0 {
+		t.Errorf("VerifyBatch(nil)=%v, want empty", errs)
+	}
+}
+
+func TestVerifyContext(t *testing.T) {
+	km, err := NewFromPrivatePEM(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("NewFromPrivatePEM()=%v", err)
+	}
+	msg := []byte("foo")
+	signed, err := NewSignerFromPrivateKeyManager(km).Sign(msg)
+	if err != nil {
+		t.Fatalf("Sign()=(_,%v), want (_,nil)", err)
+	}
+	pub := km.Public()
+
+	if err := VerifyContext(context.Background(), pub, msg, signed); err != nil {
+		t.Errorf("VerifyContext()=%v, want nil", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := VerifyContext(ctx, pub, msg, signed); err != context.Canceled {
+		t.Errorf("VerifyContext(cancelled)=%v, want %v", err, context.Canceled)
+	}
+
+	items := make([]VerifyItem, 100)
+	for i := range items {
+		items[i] = VerifyItem{Data: msg, Sig: signed}
+	}
+	// No items are verified once the context is cancelled, so even valid
+	// signatures report the context's error.
+	for i, err := range VerifyBatchContext(ctx, pub, items) {
+		if err != context.Canceled {
+			t.Errorf("VerifyBatchContext(cancelled)[%d]=%v, want %v", i, err, context.Canceled)
+		}
+	}
+	for i, err := range VerifyBatchContext(context.Background(), pub, items) {
+		if err != nil {
+			t.Errorf("VerifyBatchContext()[%d]=%v, want nil", i, err)
+		}
+	}
+}
+
+// benchmarkSignature returns an ECDSA public key and a signature made with it over msg.
+func benchmarkSignature(b *testing.B, msg []byte) (crypto.PublicKey, *sigpb.DigitallySigned) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		b.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	km, err := NewFromPrivateKey(key)
+	if err != nil {
+		b.Fatalf("NewFromPrivateKey()=%v", err)
+	}
+	signed, err := NewSignerFromPrivateKeyManager(km).Sign(msg)
+	if err != nil {
+		b.Fatalf("Sign()=(_,%v), want (_,nil)", err)
+	}
+	return key.Public(), signed
+}
+
+func BenchmarkVerify(b *testing.B) {
+	msg := []byte("foo")
+	pub, signed := benchmarkSignature(b, msg)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := Verify(pub, msg, signed); err != nil {
+			b.Fatalf("Verify()=%v", err)
+		}
+	}
+}
+
+func BenchmarkVerifierVerify(b *testing.B) {
+	msg := []byte("foo")
+	pub, signed := benchmarkSignature(b, msg)
+	v, err := NewVerifier(pub)
+	if err != nil {
+		b.Fatalf("NewVerifier()=(_,%v)", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := v.Verify(msg, signed); err != nil {
+			b.Fatalf("Verifier.Verify()=%v", err)
+		}
+	}
+}
+
+// benchmarkPayloadSizes are the lengths of the data signed in the per-key
+// benchmarks, from a bare hash up to a large certificate chain.
+var benchmarkPayloadSizes = []int{32, 1 << 10, 64 << 10}
+
+// benchmarkVerifyKey reports the time taken by Verify to check a signature made with
+// key, for each of benchmarkPayloadSizes.
+func benchmarkVerifyKey(b *testing.B, key crypto.Signer) {
+	km, err := NewFromPrivateKey(key)
+	if err != nil {
+		b.Fatalf("NewFromPrivateKey()=%v", err)
+	}
+	signer := NewSignerFromPrivateKeyManager(km)
+	for _, size := range benchmarkPayloadSizes {
+		msg := make([]byte, size)
+		signed, err := signer.Sign(msg)
+		if err != nil {
+			b.Fatalf("Sign()=(_,%v), want (_,nil)", err)
+		}
+		b.Run(fmt.Sprintf("%dB", size), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				if err := Verify(key.Public(), msg, signed); err != nil {
+					b.Fatalf("Verify()=%v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkVerifyECDSA verifies P-256 signatures, as made by the default log signer.
+func BenchmarkVerifyECDSA(b *testing.B) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		b.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	benchmarkVerifyKey(b, key)
+}
+
+// BenchmarkVerifyRSA2048 verifies PKCS#1 v1.5 signatures made with the smallest RSA
+// key that VerifyOptions accepts by default.
+func BenchmarkVerifyRSA2048(b *testing.B) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		b.Fatalf("rsa.GenerateKey()=%v", err)
+	}
+	benchmarkVerifyKey(b, key)
+}
+
+// BenchmarkVerifyRSA4096 verifies PKCS#1 v1.5 signatures made with a 4096-bit key,
+// the largest in common use by log operators.
+func BenchmarkVerifyRSA4096(b *testing.B) {
+	key, err := rsa.GenerateKey(rand.Reader, 4096)
+	if err != nil {
+		b.Fatalf("rsa.GenerateKey()=%v", err)
+	}
+	benchmarkVerifyKey(b, key)
+}
+
+// BenchmarkVerifyBatch verifies a batch of 100 ECDSA signatures, with GOMAXPROCS,
+// and so the number of workers VerifyBatch starts, set to each of several levels.
+func BenchmarkVerifyBatch(b *testing.B) {
+	msg := []byte("foo")
+	pub, signed := benchmarkSignature(b, msg)
+	items := make([]VerifyItem, 100)
+	for i := range items {
+		items[i] = VerifyItem{Data: msg, Sig: signed}
+	}
+
+	levels := []int{1, 2, 4, 8}
+	if n := runtime.NumCPU(); n > levels[len(levels)-1] {
+		levels = append(levels, n)
+	}
+	for _, workers := range levels {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(workers))
+			for i := 0; i < b.N; i++ {
+				for _, err := range VerifyBatch(pub, items) {
+					if err != nil {
+						b.Fatalf("VerifyBatch()=%v", err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDigest(t *testing.T) {
+	for _, test := range []struct {
+		data string
+		want string
+	}{
+		{data: "", want: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{data: "abc", want: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{data: "abcdbcdecdefdefgefghfghighijhijkijkljklmklmnlmnomnopnopq", want: "248d6a61d20638b8e5c026930c3e6039a33ce45964ff2167f6ecedd419db06c1"},
+	} {
+		want := sha256.Sum256([]byte(test.data))
+		if got := fmt.Sprintf("%x", want); got != test.want {
+			t.Fatalf("sha256.Sum256(%q)=%s, want %s", test.data, got, test.want)
+		}
+		got, err := digest(sigpb.DigitallySigned_SHA256, []byte(test.data))
+		if err != nil {
+			t.Errorf("digest(SHA256, %q)=_,%v, want nil", test.data, err)
+			continue
+		}
+		if !bytes.Equal(got, want[:]) {
+			t.Errorf("digest(SHA256, %q)=%x, want %x", test.data, got, want)
+		}
+		got, err = digestStream(sigpb.DigitallySigned_SHA256, strings.NewReader(test.data))
+		if err != nil || !bytes.Equal(got, want[:]) {
+			t.Errorf("digestStream(SHA256, %q)=%x,%v, want %x,nil", test.data, got, err, want)
+		}
+	}
+}
+
+func TestDigestErrors(t *testing.T) {
+	for _, alg := range []sigpb.DigitallySigned_HashAlgorithm{
+		sigpb.DigitallySigned_NONE,
+		sigpb.DigitallySigned_HashAlgorithm(1),
+		sigpb.DigitallySigned_HashAlgorithm(99),
+	} {
+		if _, err := digest(alg, []byte("data")); !errors.Is(err, ErrUnsupportedAlgorithm) {
+			t.Errorf("digest(%v)=_,%v, want %v", alg, err, ErrUnsupportedAlgorithm)
+		}
+	}
+
+	readErr := errors.New("read failed")
+	if _, err := digestStream(sigpb.DigitallySigned_SHA256, iotest.ErrReader(readErr)); err == nil {
+		t.Error("digestStream(SHA256, failing reader)=_,nil, want error")
+	}
+}
+
+func TestSupportedAlgorithms(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey()=%v", err)
+	}
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey()=%v", err)
+	}
+	digestHashes := []sigpb.DigitallySigned_HashAlgorithm{
+		sigpb.DigitallySigned_SHA256,
+		sigpb.DigitallySigned_SHA384,
+		sigpb.DigitallySigned_SHA512,
+		sigpb.DigitallySigned_SHA3_256,
+	}
+
+	for _, test := range []struct {
+		desc       string
+		pub        crypto.PublicKey
+		wantSig    []sigpb.DigitallySigned_SignatureAlgorithm
+		wantHashes []sigpb.DigitallySigned_HashAlgorithm
+		wantErr    error
+	}{
+		{
+			desc:       "ECDSA",
+			pub:        ecdsaKey.Public(),
+			wantSig:    []sigpb.DigitallySigned_SignatureAlgorithm{sigpb.DigitallySigned_ECDSA},
+			wantHashes: digestHashes,
+		},
+		{
+			desc:       "RSA",
+			pub:        rsaKey.Public(),
+			wantSig:    []sigpb.DigitallySigned_SignatureAlgorithm{sigpb.DigitallySigned_RSA, sigpb.DigitallySigned_RSA_PSS},
+			wantHashes: digestHashes,
+		},
+		{
+			desc:       "Ed25519",
+			pub:        edPub,
+			wantSig:    []sigpb.DigitallySigned_SignatureAlgorithm{sigpb.DigitallySigned_ED25519},
+			wantHashes: []sigpb.DigitallySigned_HashAlgorithm{sigpb.DigitallySigned_NONE},
+		},
+		{
+			desc:    "unsupported key",
+			pub:     "not a key",
+			wantErr: ErrUnsupportedAlgorithm,
+		},
+	} {
+		sigAlgos, hashes, err := SupportedAlgorithms(test.pub)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%v: SupportedAlgorithms()=_,_,%v, want %v", test.desc, err, test.wantErr)
+			continue
+		}
+		if got, want := fmt.Sprint(sigAlgos), fmt.Sprint(test.wantSig); got != want {
+			t.Errorf("%v: SupportedAlgorithms() signature algorithms=%v, want %v", test.desc, got, want)
+		}
+		if got, want := fmt.Sprint(hashes), fmt.Sprint(test.wantHashes); got != want {
+			t.Errorf("%v: SupportedAlgorithms() hash algorithms=%v, want %v", test.desc, got, want)
+		}
+
+		// Every combination returned must be accepted by the verifier's checks.
+		for _, sa := range sigAlgos {
+			for _, ha := range hashes {
+				sig := &sigpb.DigitallySigned{SignatureAlgorithm: sa, HashAlgorithm: ha, Signature: []byte("bogus")}
+				if err := Verify(test.pub, []byte("data"), sig); !errors.Is(err, ErrVerifyFailed) {
+					t.Errorf("%v: Verify(%v, %v) of bogus signature=%v, want %v", test.desc, sa, ha, err, ErrVerifyFailed)
+				}
+			}
+		}
+	}
+}
+
+func TestVerifyMalformedSignature(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	pub := ecdsaKey.Public()
+	v, err := NewVerifier(pub)
+	if err != nil {
+		t.Fatalf("NewVerifier()=_,%v, want nil", err)
+	}
+
+	for _, test := range []struct {
+		desc string
+		pub  crypto.PublicKey
+		sig  *sigpb.DigitallySigned
+	}{
+		{desc: "nil", pub: pub, sig: nil},
+		{desc: "zero value", pub: pub, sig: &sigpb.DigitallySigned{}},
+		{
+			desc: "empty signature",
+			pub:  pub,
+			sig: &sigpb.DigitallySigned{
+				SignatureAlgorithm: sigpb.DigitallySigned_ECDSA,
+				HashAlgorithm:      sigpb.DigitallySigned_SHA256,
+				Signature:          []byte{},
+			},
+		},
+		// Malformed signatures are rejected before the key is looked at.
+		{desc: "nil with unsupported key", pub: "not a key", sig: nil},
+	} {
+		if err := Verify(test.pub, []byte("data"), test.sig); !errors.Is(err, ErrVerifyFailed) {
+			t.Errorf("%v: Verify()=%v, want %v", test.desc, err, ErrVerifyFailed)
+		}
+		if err := VerifyStream(test.pub, strings.NewReader("data"), test.sig); !errors.Is(err, ErrVerifyFailed) {
+			t.Errorf("%v: VerifyStream()=%v, want %v", test.desc, err, ErrVerifyFailed)
+		}
+		if err := v.Verify([]byte("data"), test.sig); !errors.Is(err, ErrVerifyFailed) {
+			t.Errorf("%v: Verifier.Verify()=%v, want %v", test.desc, err, ErrVerifyFailed)
+		}
+	}
+}
+
+func TestVerifyDigest(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey()=%v", err)
+	}
+
+	msg := []byte("signed elsewhere")
+	for _, test := range []struct {
+		desc     string
+		key      crypto.Signer
+		sigAlgo  sigpb.DigitallySigned_SignatureAlgorithm
+		hash     crypto.Hash
+		hashAlgo sigpb.DigitallySigned_HashAlgorithm
+	}{
+		{"ECDSA-SHA256", ecdsaKey, sigpb.DigitallySigned_ECDSA, crypto.SHA256, sigpb.DigitallySigned_SHA256},
+		{"ECDSA-SHA512", ecdsaKey, sigpb.DigitallySigned_ECDSA, crypto.SHA512, sigpb.DigitallySigned_SHA512},
+		{"RSA-SHA256", rsaKey, sigpb.DigitallySigned_RSA, crypto.SHA256, sigpb.DigitallySigned_SHA256},
+		{"RSA-SHA384", rsaKey, sigpb.DigitallySigned_RSA, crypto.SHA384, sigpb.DigitallySigned_SHA384},
+		{"RSA-SHA3-256", rsaKey, sigpb.DigitallySigned_RSA, crypto.SHA3_256, sigpb.DigitallySigned_SHA3_256},
+	} {
+		h := test.hash.New()
+		h.Write(msg)
+		digest := h.Sum(nil)
+		sigBytes, err := test.key.Sign(rand.Reader, digest, test.hash)
+		if err != nil {
+			t.Errorf("%v: Sign()=%v", test.desc, err)
+			continue
+		}
+		sig := &sigpb.DigitallySigned{
+			SignatureAlgorithm: test.sigAlgo,
+			HashAlgorithm:      test.hashAlgo,
+			Signature:          sigBytes,
+		}
+		if err := VerifyDigest(test.key.Public(), digest, sig); err != nil {
+			t.Errorf("%v: VerifyDigest()=%v, want nil", test.desc, err)
+		}
+		// The same signature verifies over the message with Verify, which hashes it.
+		if err := Verify(test.key.Public(), msg, sig); err != nil {
+			t.Errorf("%v: Verify()=%v, want nil", test.desc, err)
+		}
+		// Passing the message to VerifyDigest must not verify, even if it is the right size.
+		if err := VerifyDigest(test.key.Public(), make([]byte, test.hash.Size()), sig); !errors.Is(err, ErrVerifyFailed) {
+			t.Errorf("%v: VerifyDigest(wrong digest)=%v, want %v", test.desc, err, ErrVerifyFailed)
+		}
+		for _, size := range []int{0, test.hash.Size() - 1, test.hash.Size() + 1} {
+			if err := VerifyDigest(test.key.Public(), make([]byte, size), sig); !errors.Is(err, ErrDigestSize) {
+				t.Errorf("%v: VerifyDigest(%d byte digest)=%v, want %v", test.desc, size, err, ErrDigestSize)
+			}
+		}
+	}
+}
+
+func TestVerifyDigestEd25519(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey()=%v", err)
+	}
+	digest := sha256.Sum256([]byte("msg"))
+	sig := &sigpb.DigitallySigned{
+		SignatureAlgorithm: sigpb.DigitallySigned_ED25519,
+		HashAlgorithm:      sigpb.DigitallySigned_NONE,
+		Signature:          ed25519.Sign(priv, digest[:]),
+	}
+	if err := VerifyDigest(pub, digest[:], sig); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Errorf("VerifyDigest(Ed25519)=%v, want %v", err, ErrUnsupportedAlgorithm)
+	}
+}
+
+func TestVerifyAny(t *testing.T) {
+	var keys []crypto.Signer
+	for i := 0; i < 3; i++ {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("ecdsa.GenerateKey()=%v", err)
+		}
+		keys = append(keys, key)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey()=%v", err)
+	}
+	km, err := NewFromPrivateKey(keys[0])
+	if err != nil {
+		t.Fatalf("NewFromPrivateKey()=%v", err)
+	}
+	msg := []byte("foo")
+	signed, err := NewSignerFromPrivateKeyManager(km).Sign(msg)
+	if err != nil {
+		t.Fatalf("Sign()=(_,%v), want (_,nil)", err)
+	}
+	signer, other1, other2, rsaPub := keys[0].Public(), keys[1].Public(), keys[2].Public(), rsaKey.Public()
+
+	for _, test := range []struct {
+		desc    string
+		pubs    []crypto.PublicKey
+		data    []byte
+		want    int
+		wantErr error
+	}{
+		{desc: "first", pubs: []crypto.PublicKey{signer, other1, other2}, data: msg, want: 0},
+		{desc: "middle", pubs: []crypto.PublicKey{other1, signer, other2}, data: msg, want: 1},
+		{desc: "last", pubs: []crypto.PublicKey{other1, other2, signer}, data: msg, want: 2},
+		{desc: "after mismatched key", pubs: []crypto.PublicKey{rsaPub, signer}, data: msg, want: 1},
+		{desc: "no matching key", pubs: []crypto.PublicKey{other1, other2}, data: msg, want: -1, wantErr: ErrVerifyFailed},
+		{desc: "mismatch then no match", pubs: []crypto.PublicKey{rsaPub, other1}, data: msg, want: -1, wantErr: ErrVerifyFailed},
+		{desc: "wrong data", pubs: []crypto.PublicKey{signer}, data: []byte("bar"), want: -1, wantErr: ErrVerifyFailed},
+		{desc: "only mismatched keys", pubs: []crypto.PublicKey{rsaPub}, data: msg, want: -1, wantErr: ErrAlgorithmMismatch},
+		{desc: "no keys", data: msg, want: -1, wantErr: ErrVerifyFailed},
+	} {
+		got, err := VerifyAny(test.pubs, test.data, signed)
+		if got != test.want || !errors.Is(err, test.wantErr) || (err == nil) != (test.wantErr == nil) {
+			t.Errorf("%s: VerifyAny()=(%d,%v), want (%d,%v)", test.desc, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestVerifyMulti(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey()=%v", err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	msg := []byte("foo")
+	var sigs []*sigpb.DigitallySigned
+	for _, key := range []crypto.Signer{ecdsaKey, rsaKey} {
+		km, err := NewFromPrivateKey(key)
+		if err != nil {
+			t.Fatalf("NewFromPrivateKey()=%v", err)
+		}
+		sig, err := NewSignerFromPrivateKeyManager(km).Sign(msg)
+		if err != nil {
+			t.Fatalf("Sign()=(_,%v), want (_,nil)", err)
+		}
+		sigs = append(sigs, sig)
+	}
+	ecdsaSig, rsaSig := sigs[0], sigs[1]
+	unknownHash := *rsaSig
+	unknownHash.HashAlgorithm = sigpb.DigitallySigned_HashAlgorithm(99)
+	pubs := map[sigpb.DigitallySigned_SignatureAlgorithm]crypto.PublicKey{
+		sigpb.DigitallySigned_ECDSA: ecdsaKey.Public(),
+		sigpb.DigitallySigned_RSA:   rsaKey.Public(),
+	}
+
+	for _, test := range []struct {
+		desc    string
+		pubs    map[sigpb.DigitallySigned_SignatureAlgorithm]crypto.PublicKey
+		data    []byte
+		sigs    []*sigpb.DigitallySigned
+		wantErr error
+		// wantIndex is the signature that the error must name.
+		wantIndex int
+	}{
+		{desc: "all verify", pubs: pubs, data: msg, sigs: []*sigpb.DigitallySigned{ecdsaSig, rsaSig}},
+		{desc: "one", pubs: pubs, data: msg, sigs: []*sigpb.DigitallySigned{rsaSig}},
+		{desc: "wrong data", pubs: pubs, data: []byte("bar"), sigs: []*sigpb.DigitallySigned{ecdsaSig, rsaSig}, wantErr: ErrVerifyFailed, wantIndex: 0},
+		{
+			desc:      "second fails",
+			pubs:      pubs,
+			data:      msg,
+			sigs:      []*sigpb.DigitallySigned{ecdsaSig, {SignatureAlgorithm: sigpb.DigitallySigned_RSA, HashAlgorithm: sigpb.DigitallySigned_SHA256, Signature: []byte("bogus")}},
+			wantErr:   ErrVerifyFailed,
+			wantIndex: 1,
+		},
+		{
+			desc:      "wrong key",
+			pubs:      map[sigpb.DigitallySigned_SignatureAlgorithm]crypto.PublicKey{sigpb.DigitallySigned_ECDSA: otherKey.Public(), sigpb.DigitallySigned_RSA: rsaKey.Public()},
+			data:      msg,
+			sigs:      []*sigpb.DigitallySigned{rsaSig, ecdsaSig},
+			wantErr:   ErrVerifyFailed,
+			wantIndex: 1,
+		},
+		{
+			desc:      "no key for algorithm",
+			pubs:      map[sigpb.DigitallySigned_SignatureAlgorithm]crypto.PublicKey{sigpb.DigitallySigned_ECDSA: ecdsaKey.Public()},
+			data:      msg,
+			sigs:      []*sigpb.DigitallySigned{ecdsaSig, rsaSig},
+			wantErr:   ErrUnsupportedAlgorithm,
+			wantIndex: 1,
+		},
+		{desc: "unsupported hash", pubs: pubs, data: msg, sigs: []*sigpb.DigitallySigned{ecdsaSig, &unknownHash}, wantErr: ErrUnsupportedAlgorithm, wantIndex: 1},
+		{desc: "nil signature", pubs: pubs, data: msg, sigs: []*sigpb.DigitallySigned{nil}, wantErr: ErrVerifyFailed, wantIndex: 0},
+		{desc: "no signatures", pubs: pubs, data: msg, wantErr: ErrVerifyFailed, wantIndex: -1},
+	} {
+		err := VerifyMulti(test.pubs, test.data, test.sigs)
+		if !errors.Is(err, test.wantErr) || (err == nil) != (test.wantErr == nil) {
+			t.Errorf("%s: VerifyMulti()=%v, want %v", test.desc, err, test.wantErr)
+			continue
+		}
+		if err != nil && test.wantIndex >= 0 {
+			if want := fmt.Sprintf("signature %d: ", test.wantIndex); !strings.HasPrefix(err.Error(), want) {
+				t.Errorf("%s: VerifyMulti()=%v, want error starting %q", test.desc, err, want)
+			}
+		}
+	}
+}
+
+func TestVerifyWithHash(t *testing.T) {
+	// Remove SHA-512 from the supported hashes, as if it were an experimental digest
+	// that Verify does not yet know about.
+	defer func(h crypto.Hash) { cryptoHashLookup[sigpb.DigitallySigned_SHA512] = h }(cryptoHashLookup[sigpb.DigitallySigned_SHA512])
+	delete(cryptoHashLookup, sigpb.DigitallySigned_SHA512)
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey()=%v", err)
+	}
+
+	msg := []byte("foo")
+	for _, test := range []struct {
+		desc    string
+		key     crypto.Signer
+		sigAlgo sigpb.DigitallySigned_SignatureAlgorithm
+	}{
+		{"ECDSA", ecdsaKey, sigpb.DigitallySigned_ECDSA},
+		{"RSA", rsaKey, sigpb.DigitallySigned_RSA},
+	} {
+		digest := sha512.Sum512(msg)
+		sigBytes, err := test.key.Sign(rand.Reader, digest[:], crypto.SHA512)
+		if err != nil {
+			t.Errorf("%v: Sign()=%v", test.desc, err)
+			continue
+		}
+		sig := &sigpb.DigitallySigned{
+			SignatureAlgorithm: test.sigAlgo,
+			HashAlgorithm:      sigpb.DigitallySigned_SHA512,
+			Signature:          sigBytes,
+		}
+		if err := Verify(test.key.Public(), msg, sig); !errors.Is(err, ErrUnsupportedAlgorithm) {
+			t.Errorf("%v: Verify()=%v, want %v", test.desc, err, ErrUnsupportedAlgorithm)
+		}
+		if err := VerifyWithHash(test.key.Public(), msg, sig, crypto.SHA512); err != nil {
+			t.Errorf("%v: VerifyWithHash(SHA-512)=%v, want nil", test.desc, err)
+		}
+		if err := VerifyWithHash(test.key.Public(), []byte("bar"), sig, crypto.SHA512); !errors.Is(err, ErrVerifyFailed) {
+			t.Errorf("%v: VerifyWithHash(SHA-512, other data)=%v, want %v", test.desc, err, ErrVerifyFailed)
+		}
+		if err := VerifyWithHash(test.key.Public(), msg, sig, crypto.SHA256); !errors.Is(err, ErrVerifyFailed) {
+			t.Errorf("%v: VerifyWithHash(SHA-256)=%v, want %v", test.desc, err, ErrVerifyFailed)
+		}
+		// MD4 is not linked into the binary.
+		if err := VerifyWithHash(test.key.Public(), msg, sig, crypto.MD4); !errors.Is(err, ErrUnsupportedAlgorithm) {
+			t.Errorf("%v: VerifyWithHash(MD4)=%v, want %v", test.desc, err, ErrUnsupportedAlgorithm)
+		}
+	}
+
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey()=%v", err)
+	}
+	sig := &sigpb.DigitallySigned{
+		SignatureAlgorithm: sigpb.DigitallySigned_ED25519,
+		Signature:          ed25519.Sign(edPriv, msg),
+	}
+	if err := VerifyWithHash(edPub, msg, sig, crypto.SHA512); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Errorf("VerifyWithHash(Ed25519)=%v, want %v", err, ErrUnsupportedAlgorithm)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Fuzz tests need Go 1.18, newer than the Go version the rest of the tree builds with.
+
+//go:build go1.18
+// +build go1.18
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+)
+
+func FuzzVerifyECDSA(f *testing.F) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		f.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	digest := sha256.Sum256([]byte("fuzz"))
+	sig, err := signASN1(key, digest[:])
+	if err != nil {
+		f.Fatalf("signASN1()=%v", err)
+	}
+	f.Add(sig, false)
+	f.Add(sig, true)
+	f.Add(sig[:len(sig)-1], false)
+	f.Add(append(sig, 0), false)
+	f.Add([]byte{0x30, 0x80, 0x02, 0x01, 0x01, 0x02, 0x01, 0x01, 0x00, 0x00}, false) // indefinite length
+	f.Add([]byte{}, false)
+
+	f.Fuzz(func(t *testing.T, sig []byte, requireLowS bool) {
+		if err := verifyECDSA(&key.PublicKey, digest[:], sig, requireLowS); err != nil {
+			return
+		}
+		// Only canonical DER encodings of a valid (R, S) pair may verify.
+		var parsed struct {
+			R, S *big.Int
+		}
+		if _, err := asn1.Unmarshal(sig, &parsed); err != nil {
+			t.Fatalf("verifyECDSA(%x)=nil, but asn1.Unmarshal()=%v", sig, err)
+		}
+		der, err := asn1.Marshal(parsed)
+		if err != nil {
+			t.Fatalf("asn1.Marshal()=%v", err)
+		}
+		if !bytes.Equal(der, sig) {
+			t.Errorf("verifyECDSA(%x)=nil for non-canonical encoding of %x", sig, der)
+		}
+	})
+}
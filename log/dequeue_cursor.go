@@ -0,0 +1,44 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import "sync"
+
+// DequeueCursor remembers how far sequencing has paged through a tree's queue of leaves,
+// so that each batch can dequeue the leaves after those taken by the previous batch rather
+// than scanning the queue from the start. As with LeafRateLimiter, a DequeueCursor is kept
+// for the lifetime of a tree's sequencing and passed to each Sequencer created for it. The
+// zero value starts at the beginning of the queue. It is safe for concurrent use.
+type DequeueCursor struct {
+	mu    sync.Mutex
+	token []byte
+}
+
+// Reset makes the next batch start from the beginning of the queue.
+func (c *DequeueCursor) Reset() {
+	c.set(nil)
+}
+
+func (c *DequeueCursor) get() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+func (c *DequeueCursor) set(token []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
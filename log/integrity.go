@@ -0,0 +1,133 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+)
+
+// integrityBatchSize is the number of leaves VerifyTreeIntegrity reads at a time.
+const integrityBatchSize = 1000
+
+// computedNode is a node hash computed by VerifyTreeIntegrity, to be checked against
+// storage.
+type computedNode struct {
+	depth int
+	index int64
+	hash  []byte
+}
+
+// VerifyTreeIntegrity checks a log for storage corruption. It rebuilds the Merkle tree
+// from the Merkle leaf hashes of the sequenced leaves, checking each node hash it
+// computes with th against the node stored for the tree, and checks the resulting root
+// hash against the latest signed root. An error describing the first difference found is
+// returned if the stored tree does not match its leaves.
+func VerifyTreeIntegrity(ctx context.Context, ls storage.LogStorage, th merkle.TreeHasher, treeID int64) error {
+	tx, err := ls.SnapshotForTree(ctx, treeID)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		return err
+	}
+	if root.TreeSize == 0 && len(root.RootHash) == 0 {
+		// No root has been signed for the log yet, so there is nothing to check.
+		return tx.Commit()
+	}
+
+	// AddLeafHashes only reports the nodes of complete subtrees, whose hashes are final,
+	// so each batch of nodes can be checked as soon as it has been computed.
+	mt := merkle.NewCompactMerkleTree(th)
+	var nodes []computedNode
+	setNode := func(depth int, index int64, hash []byte) {
+		nodes = append(nodes, computedNode{depth: depth, index: index, hash: hash})
+	}
+	for start := int64(0); start < root.TreeSize; start += integrityBatchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := start + integrityBatchSize
+		if end > root.TreeSize {
+			end = root.TreeSize
+		}
+		indices := make([]int64, 0, end-start)
+		for i := start; i < end; i++ {
+			indices = append(indices, i)
+		}
+		leaves, err := tx.GetLeavesByIndex(indices)
+		if err != nil {
+			return err
+		}
+		if got, want := len(leaves), len(indices); got != want {
+			return fmt.Errorf("%v: read %d leaves from index %d, want %d", treeID, got, start, want)
+		}
+		hashes := make([][]byte, 0, len(leaves))
+		for i, leaf := range leaves {
+			if leaf.LeafIndex != indices[i] {
+				return fmt.Errorf("%v: read leaf %d, want leaf %d", treeID, leaf.LeafIndex, indices[i])
+			}
+			hashes = append(hashes, leaf.MerkleLeafHash)
+		}
+		nodes = nodes[:0]
+		mt.AddLeafHashes(hashes, 1, setNode)
+		if err := checkNodes(tx, treeID, nodes); err != nil {
+			return err
+		}
+	}
+
+	if got, want := mt.CurrentRoot(), root.RootHash; !bytes.Equal(got, want) {
+		return fmt.Errorf("%v: root hash of %d leaves is %x, but signed root has %x", treeID, root.TreeSize, got, want)
+	}
+	return tx.Commit()
+}
+
+// checkNodes compares the computed nodes with those stored for the tree.
+func checkNodes(tx storage.ReadOnlyLogTreeTX, treeID int64, nodes []computedNode) error {
+	ids := make([]storage.NodeID, 0, len(nodes))
+	for _, n := range nodes {
+		nodeID, err := storage.NewNodeIDForTreeCoords(int64(n.depth), n.index, maxTreeDepth)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, nodeID)
+	}
+
+	stored, err := tx.GetMerkleNodes(tx.ReadRevision(), ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string][]byte, len(stored))
+	for _, node := range stored {
+		byID[node.NodeID.String()] = node.Hash
+	}
+	for i, n := range nodes {
+		got, ok := byID[ids[i].String()]
+		if !ok {
+			return fmt.Errorf("%v: node at level %d index %d is missing from storage", treeID, n.depth, n.index)
+		}
+		if !bytes.Equal(got, n.hash) {
+			return fmt.Errorf("%v: node at level %d index %d has hash %x in storage, want %x", treeID, n.depth, n.index, got, n.hash)
+		}
+	}
+	return nil
+}
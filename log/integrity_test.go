@@ -0,0 +1,120 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/util"
+)
+
+func TestVerifyTreeIntegrity(t *testing.T) {
+	for _, test := range []struct {
+		treeSize, batchSize int
+	}{
+		{treeSize: 1, batchSize: 1},
+		{treeSize: 13, batchSize: 1},
+		{treeSize: 13, batchSize: 4},
+		{treeSize: 32, batchSize: 32},
+		{treeSize: 37, batchSize: 5},
+	} {
+		ls, logID, _ := sequencedMemoryLog(t, testonly.Hasher, test.treeSize, test.batchSize)
+		ctx := util.NewLogContext(context.Background(), logID)
+		if err := VerifyTreeIntegrity(ctx, ls, testonly.Hasher, logID); err != nil {
+			t.Errorf("VerifyTreeIntegrity(%d leaves in batches of %d)=%v, want nil", test.treeSize, test.batchSize, err)
+		}
+	}
+}
+
+func TestVerifyTreeIntegrityCorrupt(t *testing.T) {
+	const treeSize = 13
+	for _, test := range []struct {
+		desc         string
+		depth        int64
+		index        int64
+		corruptRoot  bool
+		wantErrMatch string
+	}{
+		{desc: "leaf", depth: 0, index: 5, wantErrMatch: "level 0 index 5"},
+		{desc: "internal", depth: 2, index: 1, wantErrMatch: "level 2 index 1"},
+		{desc: "last complete", depth: 0, index: treeSize - 1, wantErrMatch: "level 0 index 12"},
+		{desc: "root", corruptRoot: true, wantErrMatch: "signed root"},
+	} {
+		ls, logID, _ := sequencedMemoryLog(t, testonly.Hasher, treeSize, 4)
+		ctx := util.NewLogContext(context.Background(), logID)
+
+		// Overwrite a node, or the root hash, in a new revision so the check reads it.
+		tx, err := ls.BeginForTree(ctx, logID)
+		if err != nil {
+			t.Fatalf("BeginForTree()=(_,%v), want (_,nil)", err)
+		}
+		root, err := tx.LatestSignedLogRoot()
+		if err != nil {
+			t.Fatalf("LatestSignedLogRoot()=(_,%v), want (_,nil)", err)
+		}
+		if test.corruptRoot {
+			root.RootHash = append([]byte{}, root.RootHash...)
+			root.RootHash[0] ^= 1
+		} else {
+			nodeID, err := storage.NewNodeIDForTreeCoords(test.depth, test.index, maxTreeDepth)
+			if err != nil {
+				t.Fatalf("NewNodeIDForTreeCoords()=(_,%v), want (_,nil)", err)
+			}
+			nodes, err := tx.GetMerkleNodes(tx.ReadRevision(), []storage.NodeID{nodeID})
+			if err != nil || len(nodes) != 1 {
+				t.Fatalf("GetMerkleNodes()=(%v,%v), want 1 node", nodes, err)
+			}
+			nodes[0].Hash = append([]byte{}, nodes[0].Hash...)
+			nodes[0].Hash[0] ^= 1
+			if err := tx.SetMerkleNodes(nodes); err != nil {
+				t.Fatalf("SetMerkleNodes()=%v, want nil", err)
+			}
+		}
+		root.TreeRevision = tx.WriteRevision()
+		root.TimestampNanos++
+		if err := tx.StoreSignedLogRoot(root); err != nil {
+			t.Fatalf("StoreSignedLogRoot()=%v, want nil", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Commit()=%v, want nil", err)
+		}
+
+		err = VerifyTreeIntegrity(ctx, ls, testonly.Hasher, logID)
+		if err == nil || !strings.Contains(err.Error(), test.wantErrMatch) {
+			t.Errorf("%s: VerifyTreeIntegrity()=%v, want error containing %q", test.desc, err, test.wantErrMatch)
+		}
+	}
+}
+
+func TestVerifyTreeIntegrityHasher(t *testing.T) {
+	th, err := merkle.Factory(merkle.PlainSHA256Type)
+	if err != nil {
+		t.Fatalf("Factory()=(_,%v), want (_,nil)", err)
+	}
+	ls, logID, _ := sequencedMemoryLog(t, th, 9, 4)
+	ctx := util.NewLogContext(context.Background(), logID)
+	if err := VerifyTreeIntegrity(ctx, ls, th, logID); err != nil {
+		t.Errorf("VerifyTreeIntegrity(plain)=%v, want nil", err)
+	}
+	// A tree checked with a different hasher than it was built with does not match.
+	if err := VerifyTreeIntegrity(ctx, ls, testonly.Hasher, logID); err == nil {
+		t.Errorf("VerifyTreeIntegrity(RFC6962)=nil, want error")
+	}
+}
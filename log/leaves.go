@@ -0,0 +1,98 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+)
+
+// ErrLeafNotFound is returned, wrapped, when a leaf index is outside a log's tree.
+var ErrLeafNotFound = errors.New("leaf not found")
+
+// GetLeafByIndex returns the leaf at index in the tree treeID, with its value, extra
+// data and the times at which it was queued and integrated. The index must be less than
+// the size of the tree at its latest signed root; if it is not, an error wrapping
+// ErrLeafNotFound is returned.
+func GetLeafByIndex(ctx context.Context, ls storage.LogStorage, treeID, index int64) (*trillian.LogLeaf, error) {
+	tx, err := ls.SnapshotForTree(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= root.TreeSize {
+		return nil, fmt.Errorf("%v: index %d not in tree of size %d: %w", treeID, index, root.TreeSize, ErrLeafNotFound)
+	}
+	leaves, err := tx.GetLeavesByIndex([]int64{index})
+	if err != nil {
+		return nil, err
+	}
+	if len(leaves) != 1 || leaves[0].LeafIndex != index {
+		return nil, fmt.Errorf("%v: read %d leaves for index %d, want leaf %d", treeID, len(leaves), index, index)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return leaves[0], nil
+}
+
+// GetLeavesByRange returns up to count leaves of the tree treeID, in index order, starting
+// at index start. The range is clamped at the size of the tree at its latest signed root,
+// so fewer leaves, or none, are returned if it extends past the end of the tree.
+func GetLeavesByRange(ctx context.Context, ls storage.LogStorage, treeID, start, count int64) ([]*trillian.LogLeaf, error) {
+	if start < 0 || count < 0 {
+		return nil, fmt.Errorf("%v: invalid range: start %d, count %d", treeID, start, count)
+	}
+	tx, err := ls.SnapshotForTree(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		return nil, err
+	}
+	if start >= root.TreeSize {
+		return []*trillian.LogLeaf{}, tx.Commit()
+	}
+	if remaining := root.TreeSize - start; count > remaining {
+		count = remaining
+	}
+	leaves, err := tx.GetLeavesByRange(start, count)
+	if err != nil {
+		return nil, err
+	}
+	if got, want := int64(len(leaves)), count; got != want {
+		return nil, fmt.Errorf("%v: read %d leaves from index %d, want %d", treeID, got, start, want)
+	}
+	for i, leaf := range leaves {
+		if want := start + int64(i); leaf.LeafIndex != want {
+			return nil, fmt.Errorf("%v: read leaf %d, want leaf %d", treeID, leaf.LeafIndex, want)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return leaves, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/util"
+)
+
+func TestGetLeafByIndex(t *testing.T) {
+	const treeSize = 7
+	ls, logID, _ := sequencedMemoryLog(t, testonly.Hasher, treeSize, 3)
+	ctx := util.NewLogContext(context.Background(), logID)
+
+	for _, test := range []struct {
+		desc  string
+		index int64
+	}{
+		{desc: "first", index: 0},
+		{desc: "valid", index: 4},
+		{desc: "last", index: treeSize - 1},
+	} {
+		leaf, err := GetLeafByIndex(ctx, ls, logID, test.index)
+		if err != nil {
+			t.Errorf("%s: GetLeafByIndex(%d)=(_,%v), want (_,nil)", test.desc, test.index, err)
+			continue
+		}
+		if got, want := leaf.LeafIndex, test.index; got != want {
+			t.Errorf("%s: GetLeafByIndex(%d).LeafIndex=%d, want %d", test.desc, test.index, got, want)
+		}
+		// sequencedMemoryLog queues leaves a second apart, in index order.
+		if got, want := string(leaf.LeafValue), fmt.Sprintf("leaf %d", test.index); got != want {
+			t.Errorf("%s: GetLeafByIndex(%d).LeafValue=%q, want %q", test.desc, test.index, got, want)
+		}
+		if got, want := leaf.QueueTimestampNanos, fakeTimeForTest.Add(time.Duration(test.index)*time.Second).UnixNano(); got != want {
+			t.Errorf("%s: GetLeafByIndex(%d).QueueTimestampNanos=%d, want %d", test.desc, test.index, got, want)
+		}
+		if got := leaf.IntegrateTimestampNanos; got < leaf.QueueTimestampNanos {
+			t.Errorf("%s: GetLeafByIndex(%d).IntegrateTimestampNanos=%d, want >= %d", test.desc, test.index, got, leaf.QueueTimestampNanos)
+		}
+	}
+}
+
+func TestGetLeafByIndexNotFound(t *testing.T) {
+	const treeSize = 7
+	ls, logID, _ := sequencedMemoryLog(t, testonly.Hasher, treeSize, 3)
+	ctx := util.NewLogContext(context.Background(), logID)
+
+	for _, index := range []int64{-1, treeSize, treeSize + 100} {
+		if leaf, err := GetLeafByIndex(ctx, ls, logID, index); !errors.Is(err, ErrLeafNotFound) {
+			t.Errorf("GetLeafByIndex(%d)=(%v,%v), want (_,ErrLeafNotFound)", index, leaf, err)
+		}
+	}
+}
+
+func TestGetLeavesByRange(t *testing.T) {
+	const treeSize = 7
+	ls, logID, _ := sequencedMemoryLog(t, testonly.Hasher, treeSize, 3)
+	ctx := util.NewLogContext(context.Background(), logID)
+
+	for _, test := range []struct {
+		desc         string
+		start, count int64
+		wantCount    int64
+	}{
+		{desc: "full", start: 0, count: treeSize, wantCount: treeSize},
+		{desc: "middle", start: 2, count: 3, wantCount: 3},
+		{desc: "overrun", start: 4, count: 10, wantCount: 3},
+		{desc: "past end", start: treeSize, count: 2, wantCount: 0},
+		{desc: "zero count", start: 1, count: 0, wantCount: 0},
+	} {
+		leaves, err := GetLeavesByRange(ctx, ls, logID, test.start, test.count)
+		if err != nil {
+			t.Errorf("%s: GetLeavesByRange(%d, %d)=(_,%v), want (_,nil)", test.desc, test.start, test.count, err)
+			continue
+		}
+		if got, want := int64(len(leaves)), test.wantCount; got != want {
+			t.Errorf("%s: GetLeavesByRange(%d, %d) returned %d leaves, want %d", test.desc, test.start, test.count, got, want)
+			continue
+		}
+		for i, leaf := range leaves {
+			index := test.start + int64(i)
+			if got, want := string(leaf.LeafValue), fmt.Sprintf("leaf %d", index); leaf.LeafIndex != index || got != want {
+				t.Errorf("%s: GetLeavesByRange(%d, %d)[%d]=leaf %d with value %q, want leaf %d with value %q", test.desc, test.start, test.count, i, leaf.LeafIndex, got, index, want)
+			}
+		}
+	}
+
+	if _, err := GetLeavesByRange(ctx, ls, logID, -1, 2); err == nil {
+		t.Errorf("GetLeavesByRange(-1, 2)=(_,nil), want (_,err)")
+	}
+}
+
+func TestGetLeavesByRangeEmptyTree(t *testing.T) {
+	ls, logID, _ := sequencedMemoryLog(t, testonly.Hasher, 0, 3)
+	ctx := util.NewLogContext(context.Background(), logID)
+
+	leaves, err := GetLeavesByRange(ctx, ls, logID, 0, 10)
+	if err != nil || len(leaves) != 0 {
+		t.Errorf("GetLeavesByRange(0, 10)=(%v,%v), want ([],nil)", leaves, err)
+	}
+}
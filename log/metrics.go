@@ -0,0 +1,58 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"strconv"
+
+	"github.com/google/trillian/monitoring"
+)
+
+// Names of the metrics recorded by the sequencer. Each is labelled with the log ID as
+// "logid".
+const (
+	// BatchesMetric counts the sequencing batches run.
+	BatchesMetric = "sequencer_batches"
+	// LeavesSequencedMetric counts the leaves integrated into the tree.
+	LeavesSequencedMetric = "sequencer_leaves_sequenced"
+	// ErrorsMetric counts the sequencing batches that failed.
+	ErrorsMetric = "sequencer_errors"
+	// BatchDurationMetric is a histogram of the seconds taken by each batch.
+	BatchDurationMetric = "sequencer_batch_duration_seconds"
+	// QueuedLeavesMetric is a gauge of the leaves waiting to be sequenced.
+	QueuedLeavesMetric = "sequencer_queued_leaves"
+	// TreeSizeMetric is a gauge of the size of the tree after the last successful batch.
+	TreeSizeMetric = "sequencer_tree_size"
+	// LastSequencedMetric is a gauge of the time, in seconds since the Unix epoch, at
+	// which the last successful batch finished. If it stops advancing while the
+	// sequencer is running, the tree is stuck.
+	LastSequencedMetric = "sequencer_last_sequenced_timestamp_seconds"
+)
+
+// MetricHelp describes each of the sequencer's metrics, keyed by name, for monitoring
+// systems that document their metrics.
+var MetricHelp = map[string]string{
+	BatchesMetric:         "Number of sequencing batches run.",
+	LeavesSequencedMetric: "Number of leaves integrated into the tree.",
+	ErrorsMetric:          "Number of sequencing batches that failed.",
+	BatchDurationMetric:   "Time taken to run a sequencing batch, including retries.",
+	QueuedLeavesMetric:    "Number of leaves waiting to be sequenced after the most recent batch.",
+	TreeSizeMetric:        "Size of the tree after the most recent successful batch.",
+	LastSequencedMetric:   "Time, in seconds since the Unix epoch, of the most recent successful batch.",
+}
+
+func logIDLabels(logID int64) monitoring.Labels {
+	return monitoring.Labels{"logid": strconv.FormatInt(logID, 10)}
+}
@@ -0,0 +1,79 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/trillian/util"
+)
+
+// BatchSequencer is implemented by types that can integrate a batch of queued leaves
+// into a log, such as Sequencer.
+type BatchSequencer interface {
+	SequenceBatch(ctx context.Context, logID int64, limit int) (SequenceResult, error)
+}
+
+// SequencerJob describes a single batch to be sequenced by RunSequencers.
+type SequencerJob struct {
+	LogID     int64
+	Sequencer BatchSequencer
+	// Limit is the maximum number of leaves to integrate in the batch.
+	Limit int
+}
+
+// SequencerJobResult holds the outcome of a SequencerJob.
+type SequencerJobResult struct {
+	LogID    int64
+	Result   SequenceResult
+	Err      error
+	Duration time.Duration
+}
+
+// RunSequencers runs the given jobs, with at most concurrency of them in progress at once,
+// and waits for them all to complete. This bounds the load placed on storage, e.g. the
+// number of database connections in use, however many logs are being sequenced. Each job
+// is run with a context derived from ctx that carries its log ID. The results are returned
+// in the same order as jobs. If concurrency is less than one the jobs are run one at a time.
+// Job durations are measured with timeSource.
+func RunSequencers(ctx context.Context, jobs []SequencerJob, concurrency int, timeSource util.TimeSource) []SequencerJobResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]SequencerJobResult, len(jobs))
+	next := make(chan int, len(jobs))
+	for i := range jobs {
+		next <- i
+	}
+	close(next)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(jobs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				job := jobs[i]
+				start := timeSource.Now()
+				res, err := job.Sequencer.SequenceBatch(util.NewLogContext(ctx, job.LogID), job.LogID, job.Limit)
+				results[i] = SequencerJobResult{LogID: job.LogID, Result: res, Err: err, Duration: timeSource.Now().Sub(start)}
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
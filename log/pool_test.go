@@ -0,0 +1,126 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/util"
+)
+
+// fakeBatchSequencer records the number of SequenceBatch calls in progress at once.
+type fakeBatchSequencer struct {
+	mu         sync.Mutex
+	running    int
+	maxRunning int
+	calls      int
+}
+
+func (f *fakeBatchSequencer) SequenceBatch(ctx context.Context, logID int64, limit int) (SequenceResult, error) {
+	f.mu.Lock()
+	f.running++
+	f.calls++
+	if f.running > f.maxRunning {
+		f.maxRunning = f.running
+	}
+	f.mu.Unlock()
+
+	// Give other workers a chance to start while this one is busy.
+	time.Sleep(10 * time.Millisecond)
+
+	f.mu.Lock()
+	f.running--
+	f.mu.Unlock()
+
+	if got, want := util.LogIDPrefix(ctx), fmt.Sprintf("{%d}", logID); got != want {
+		return SequenceResult{}, errors.New("context does not carry the log ID")
+	}
+	if logID%2 == 1 {
+		return SequenceResult{}, errors.New("odd log")
+	}
+	return SequenceResult{LeafCount: limit}, nil
+}
+
+func TestRunSequencers(t *testing.T) {
+	for _, test := range []struct {
+		jobs, concurrency, wantMax int
+	}{
+		{jobs: 10, concurrency: 3, wantMax: 3},
+		{jobs: 2, concurrency: 5, wantMax: 2},
+		{jobs: 4, concurrency: 0, wantMax: 1},
+		{jobs: 0, concurrency: 2, wantMax: 0},
+	} {
+		fake := &fakeBatchSequencer{}
+		jobs := make([]SequencerJob, test.jobs)
+		for i := range jobs {
+			jobs[i] = SequencerJob{LogID: int64(i), Sequencer: fake, Limit: i * 10}
+		}
+
+		results := RunSequencers(context.Background(), jobs, test.concurrency, util.SystemTimeSource{})
+
+		if got, want := fake.maxRunning, test.wantMax; got != want {
+			t.Errorf("RunSequencers(%d jobs, %d): %d jobs ran at once, want %d", test.jobs, test.concurrency, got, want)
+		}
+		if got, want := fake.calls, test.jobs; got != want {
+			t.Errorf("RunSequencers(%d jobs, %d): %d jobs run, want %d", test.jobs, test.concurrency, got, want)
+		}
+		if got, want := len(results), test.jobs; got != want {
+			t.Fatalf("RunSequencers(%d jobs, %d)=%d results, want %d", test.jobs, test.concurrency, got, want)
+		}
+		for i, r := range results {
+			if r.LogID != int64(i) {
+				t.Errorf("RunSequencers() result %d for log %d, want log %d", i, r.LogID, i)
+			}
+			if gotErr, wantErr := r.Err != nil, i%2 == 1; gotErr != wantErr {
+				t.Errorf("RunSequencers() result %d err=%v, want err: %v", i, r.Err, wantErr)
+			}
+			if r.Err == nil && r.Result.LeafCount != i*10 {
+				t.Errorf("RunSequencers() result %d LeafCount=%d, want %d", i, r.Result.LeafCount, i*10)
+			}
+		}
+	}
+}
+
+// slowBatchSequencer advances a fake clock by the batch limit, in seconds, for each batch.
+type slowBatchSequencer struct {
+	ts *util.FakeTimeSource
+}
+
+func (s slowBatchSequencer) SequenceBatch(ctx context.Context, logID int64, limit int) (SequenceResult, error) {
+	s.ts.FakeTime = s.ts.FakeTime.Add(time.Duration(limit) * time.Second)
+	return SequenceResult{LeafCount: limit}, nil
+}
+
+func TestRunSequencersDuration(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)}
+	fake := slowBatchSequencer{ts: ts}
+	jobs := []SequencerJob{
+		{LogID: 1, Sequencer: fake, Limit: 3},
+		{LogID: 2, Sequencer: fake, Limit: 7},
+	}
+
+	// With one worker the jobs run in order, so each sees only its own time advance.
+	results := RunSequencers(context.Background(), jobs, 1, ts)
+	for i, r := range results {
+		if got, want := r.Duration, time.Duration(jobs[i].Limit)*time.Second; got != want {
+			t.Errorf("RunSequencers() result %d Duration=%v, want %v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+)
+
+// ConsistencyProof returns the node hashes of a proof that the tree at size first is a
+// prefix of the tree at size second, in the order given by RFC 6962 section 2.1.2. The
+// nodes are read through tx at the latest revision of the tree, and the sizes must
+// satisfy 0 <= first <= second <= the tree size at that revision. When first is zero
+// or equal to second the proof is empty. Nodes that must be recomputed are hashed with th.
+func ConsistencyProof(ctx context.Context, tx storage.ReadOnlyLogTreeTX, th merkle.TreeHasher, first, second int64) ([][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		return nil, err
+	}
+	if first < 0 || first > second || second > root.TreeSize {
+		return nil, fmt.Errorf("invalid tree sizes for consistency proof: want 0 <= first (%d) <= second (%d) <= tree size (%d)", first, second, root.TreeSize)
+	}
+	if first == 0 || first == second {
+		return [][]byte{}, nil
+	}
+
+	fetches, err := merkle.CalcConsistencyProofNodeAddresses(first, second, root.TreeSize, maxTreeDepth)
+	if err != nil {
+		return nil, err
+	}
+	return fetchProofHashes(tx, th, 0, fetches)
+}
+
+// InclusionProof returns the audit path proving that the leaf at leafIndex is in the
+// tree at size treeSize, as defined by RFC 6962 section 2.1.1, ordered from the leaf
+// towards the root. The nodes are read through tx at the latest revision of the tree,
+// and leafIndex must be less than treeSize, which must be at most the tree size at
+// that revision. Nodes that must be recomputed are hashed with th.
+func InclusionProof(ctx context.Context, tx storage.ReadOnlyLogTreeTX, th merkle.TreeHasher, leafIndex, treeSize int64) ([][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		return nil, err
+	}
+	if leafIndex < 0 || leafIndex >= treeSize || treeSize > root.TreeSize {
+		return nil, fmt.Errorf("invalid leaf index for inclusion proof: want 0 <= index (%d) < tree size (%d) <= current tree size (%d)", leafIndex, treeSize, root.TreeSize)
+	}
+
+	fetches, err := merkle.CalcInclusionProofNodeAddresses(treeSize, leafIndex, root.TreeSize, maxTreeDepth)
+	if err != nil {
+		return nil, err
+	}
+	return fetchProofHashes(tx, th, leafIndex, fetches)
+}
+
+// fetchProofHashes reads the nodes for a proof at tx's revision and returns their
+// hashes in proof order.
+func fetchProofHashes(tx storage.ReadOnlyLogTreeTX, th merkle.TreeHasher, leafIndex int64, fetches []merkle.NodeFetch) ([][]byte, error) {
+	proof, err := FetchNodesAndBuildProof(tx, th, tx.ReadRevision(), leafIndex, fetches)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([][]byte, 0, len(proof.ProofNode))
+	for _, node := range proof.ProofNode {
+		hashes = append(hashes, node.NodeHash)
+	}
+	return hashes, nil
+}
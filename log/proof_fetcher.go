@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package server
+package log
 
 import (
 	"fmt"
@@ -23,18 +23,18 @@ import (
 	"github.com/google/trillian/storage"
 )
 
-// fetchNodesAndBuildProof is used by both inclusion and consistency proofs. It fetches the nodes
+// FetchNodesAndBuildProof is used by both inclusion and consistency proofs. It fetches the nodes
 // from storage and converts them into the proof proto that will be returned to the client.
 // This includes rehashing where necessary to serve proofs for tree sizes between stored tree
-// revisions. This code only relies on the NodeReader interface so can be tested without
-// a complete storage implementation.
-func fetchNodesAndBuildProof(tx storage.NodeReader, treeRevision, leafIndex int64, proofNodeFetches []merkle.NodeFetch) (trillian.Proof, error) {
+// revisions, using th. This code only relies on the NodeReader interface so can be tested
+// without a complete storage implementation.
+func FetchNodesAndBuildProof(tx storage.NodeReader, th merkle.TreeHasher, treeRevision, leafIndex int64, proofNodeFetches []merkle.NodeFetch) (trillian.Proof, error) {
 	proofNodes, err := fetchNodes(tx, treeRevision, proofNodeFetches)
 	if err != nil {
 		return trillian.Proof{}, err
 	}
 
-	r := newRehasher()
+	r := newRehasher(th)
 	for i, node := range proofNodes {
 		r.process(node, proofNodeFetches[i])
 	}
@@ -52,14 +52,9 @@ type rehasher struct {
 }
 
 // init must be called before the rehasher is used or reused
-func newRehasher() *rehasher {
-	// TODO(Martin2112): Hasher must be selected based on log config.
-	hasher, err := merkle.Factory(merkle.RFC6962SHA256Type)
-	if err != nil {
-		panic("Unknown hash strategy")
-	}
+func newRehasher(th merkle.TreeHasher) *rehasher {
 	return &rehasher{
-		th: hasher,
+		th: th,
 	}
 }
 
@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package server
+package log
 
 import (
 	"encoding/hex"
@@ -40,6 +40,9 @@ type rehashTest struct {
 // An arbitrary tree revision to be used in tests
 const testTreeRevision int64 = 3
 
+// The hasher used for the dummy storage nodes
+var th = trillian_testonly.Hasher
+
 // Raw hashes for dummy storage nodes
 var h1 = th.HashLeaf([]byte("Hash 1"))
 var h2 = th.HashLeaf([]byte("Hash 2"))
@@ -122,7 +125,7 @@ func TestRehasher(t *testing.T) {
 	}
 
 	for _, rehashTest := range rehashTests {
-		r := newRehasher()
+		r := newRehasher(th)
 		for i, node := range rehashTest.nodes {
 			r.process(node, rehashTest.fetches[i])
 		}
@@ -155,7 +158,7 @@ func TestTree813FetchAll(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		proof, err := fetchNodesAndBuildProof(r, testTreeRevision, int64(l), fetches)
+		proof, err := FetchNodesAndBuildProof(r, th, testTreeRevision, int64(l), fetches)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -192,7 +195,7 @@ func TestTree32InclusionProofFetchAll(t *testing.T) {
 					t.Fatal(err)
 				}
 
-				proof, err := fetchNodesAndBuildProof(r, testTreeRevision, int64(l), fetches)
+				proof, err := FetchNodesAndBuildProof(r, th, testTreeRevision, int64(l), fetches)
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -232,7 +235,7 @@ func TestTree32InclusionProofFetchMultiBatch(t *testing.T) {
 			}
 
 			// Use the highest tree revision that should be available from the node reader
-			proof, err := fetchNodesAndBuildProof(r, testTreeRevision+3, l, fetches)
+			proof, err := FetchNodesAndBuildProof(r, th, testTreeRevision+3, l, fetches)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -267,7 +270,7 @@ func TestTree32ConsistencyProofFetchAll(t *testing.T) {
 					t.Fatal(err)
 				}
 
-				proof, err := fetchNodesAndBuildProof(r, testTreeRevision, int64(s1), fetches)
+				proof, err := FetchNodesAndBuildProof(r, th, testTreeRevision, int64(s1), fetches)
 				if err != nil {
 					t.Fatal(err)
 				}
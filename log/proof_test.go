@@ -0,0 +1,283 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	storageto "github.com/google/trillian/storage/testonly"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/util"
+)
+
+// sequencedMemoryLog returns a memory storage log holding n leaves, integrated in
+// batches of batchSize, and an in-memory tree built from the same leaves. Both trees
+// are hashed with th.
+func sequencedMemoryLog(t *testing.T, th merkle.TreeHasher, n, batchSize int) (storage.LogStorage, int64, *merkle.InMemoryMerkleTree) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	keyManager, err := crypto.NewFromPrivateKey(key)
+	if err != nil {
+		t.Fatalf("NewFromPrivateKey()=(_,%v), want (_,nil)", err)
+	}
+	ls := memory.NewLogStorage()
+	tree, err := ls.CreateLog(storageto.LogTree)
+	if err != nil {
+		t.Fatalf("CreateLog()=(_,%v), want (_,nil)", err)
+	}
+	logID := tree.TreeId
+	ctx := util.NewLogContext(context.Background(), logID)
+	timeSource := &util.FakeTimeSource{FakeTime: fakeTimeForTest}
+
+	// Leaves are queued a second apart so they are sequenced in queue order.
+	want := merkle.NewInMemoryMerkleTree(th)
+	tx, err := ls.BeginForTree(ctx, logID)
+	if err != nil {
+		t.Fatalf("BeginForTree()=(_,%v), want (_,nil)", err)
+	}
+	for i := 0; i < n; i++ {
+		value := []byte(fmt.Sprintf("leaf %d", i))
+		identityHash := sha256.Sum256(value)
+		leaf := &trillian.LogLeaf{LeafIdentityHash: identityHash[:], MerkleLeafHash: th.HashLeaf(value), LeafValue: value}
+		if err := tx.QueueLeaves([]*trillian.LogLeaf{leaf}, fakeTimeForTest.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatalf("QueueLeaves()=%v, want nil", err)
+		}
+		want.AddLeaf(value)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit()=%v, want nil", err)
+	}
+
+	sequencer := NewSequencer(th, timeSource, ls, keyManager)
+	for size := int64(-1); size < int64(n); {
+		timeSource.FakeTime = timeSource.FakeTime.Add(time.Hour)
+		res, err := sequencer.SequenceBatch(ctx, logID, batchSize)
+		if err != nil {
+			t.Fatalf("SequenceBatch()=(_,%v), want (_,nil)", err)
+		}
+		size = res.TreeSize
+	}
+	return ls, logID, want
+}
+
+func TestConsistencyProof(t *testing.T) {
+	const treeSize = 13
+	ls, logID, want := sequencedMemoryLog(t, testonly.Hasher, treeSize, 4)
+	ctx := util.NewLogContext(context.Background(), logID)
+	verifier := merkle.NewLogVerifier(testonly.Hasher)
+
+	for first := int64(0); first <= treeSize; first++ {
+		for second := first; second <= treeSize; second++ {
+			tx, err := ls.SnapshotForTree(ctx, logID)
+			if err != nil {
+				t.Fatalf("SnapshotForTree()=(_,%v), want (_,nil)", err)
+			}
+			got, err := ConsistencyProof(ctx, tx, testonly.Hasher, first, second)
+			tx.Close()
+			if err != nil {
+				t.Errorf("ConsistencyProof(%d, %d)=(_,%v), want (_,nil)", first, second, err)
+				continue
+			}
+
+			var wantProof [][]byte
+			for _, entry := range want.SnapshotConsistency(first, second) {
+				wantProof = append(wantProof, entry.Value.Hash())
+			}
+			if len(got) != len(wantProof) {
+				t.Errorf("ConsistencyProof(%d, %d) has %d hashes, want %d", first, second, len(got), len(wantProof))
+				continue
+			}
+			for i := range got {
+				if !bytes.Equal(got[i], wantProof[i]) {
+					t.Errorf("ConsistencyProof(%d, %d)[%d]=%x, want %x", first, second, i, got[i], wantProof[i])
+				}
+			}
+
+			root1, root2 := want.RootAtSnapshot(first).Hash(), want.RootAtSnapshot(second).Hash()
+			if err := verifier.VerifyConsistencyProof(first, second, root1, root2, got); err != nil {
+				t.Errorf("VerifyConsistencyProof(%d, %d)=%v, want nil", first, second, err)
+			}
+		}
+	}
+}
+
+func TestConsistencyProofErrors(t *testing.T) {
+	ls, logID, _ := sequencedMemoryLog(t, testonly.Hasher, 5, 5)
+	ctx := util.NewLogContext(context.Background(), logID)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	for _, test := range []struct {
+		desc          string
+		ctx           context.Context
+		first, second int64
+	}{
+		{desc: "negative first", ctx: ctx, first: -1, second: 3},
+		{desc: "first after second", ctx: ctx, first: 4, second: 3},
+		{desc: "second beyond tree", ctx: ctx, first: 2, second: 6},
+		{desc: "cancelled", ctx: cancelled, first: 2, second: 3},
+	} {
+		tx, err := ls.SnapshotForTree(ctx, logID)
+		if err != nil {
+			t.Fatalf("SnapshotForTree()=(_,%v), want (_,nil)", err)
+		}
+		if got, err := ConsistencyProof(test.ctx, tx, testonly.Hasher, test.first, test.second); err == nil {
+			t.Errorf("%v: ConsistencyProof(%d, %d)=(%x,nil), want error", test.desc, test.first, test.second, got)
+		}
+		tx.Close()
+	}
+}
+
+func TestInclusionProof(t *testing.T) {
+	const treeSize = 7
+	ls, logID, want := sequencedMemoryLog(t, testonly.Hasher, treeSize, 3)
+	ctx := util.NewLogContext(context.Background(), logID)
+	verifier := merkle.NewLogVerifier(testonly.Hasher)
+
+	for _, test := range []struct {
+		desc            string
+		index, snapshot int64
+	}{
+		{desc: "first", index: 0, snapshot: treeSize},
+		{desc: "middle", index: 3, snapshot: treeSize},
+		{desc: "last", index: treeSize - 1, snapshot: treeSize},
+		{desc: "only", index: 0, snapshot: 1},
+		// Sizes between the stored revisions need rehashing.
+		{desc: "last of smaller tree", index: 4, snapshot: 5},
+		{desc: "middle of smaller tree", index: 2, snapshot: 5},
+	} {
+		tx, err := ls.SnapshotForTree(ctx, logID)
+		if err != nil {
+			t.Fatalf("SnapshotForTree()=(_,%v), want (_,nil)", err)
+		}
+		got, err := InclusionProof(ctx, tx, testonly.Hasher, test.index, test.snapshot)
+		tx.Close()
+		if err != nil {
+			t.Errorf("%v: InclusionProof(%d, %d)=(_,%v), want (_,nil)", test.desc, test.index, test.snapshot, err)
+			continue
+		}
+
+		// The in-memory tree numbers leaves from 1.
+		var wantProof [][]byte
+		for _, entry := range want.PathToRootAtSnapshot(test.index+1, test.snapshot) {
+			wantProof = append(wantProof, entry.Value.Hash())
+		}
+		if len(got) != len(wantProof) {
+			t.Errorf("%v: InclusionProof(%d, %d) has %d hashes, want %d", test.desc, test.index, test.snapshot, len(got), len(wantProof))
+			continue
+		}
+		for i := range got {
+			if !bytes.Equal(got[i], wantProof[i]) {
+				t.Errorf("%v: InclusionProof(%d, %d)[%d]=%x, want %x", test.desc, test.index, test.snapshot, i, got[i], wantProof[i])
+			}
+		}
+
+		leafHash := testonly.Hasher.HashLeaf([]byte(fmt.Sprintf("leaf %d", test.index)))
+		root := want.RootAtSnapshot(test.snapshot).Hash()
+		if err := verifier.VerifyInclusionProof(test.index, test.snapshot, got, root, leafHash); err != nil {
+			t.Errorf("%v: VerifyInclusionProof(%d, %d)=%v, want nil", test.desc, test.index, test.snapshot, err)
+		}
+	}
+}
+
+func TestInclusionProofErrors(t *testing.T) {
+	ls, logID, _ := sequencedMemoryLog(t, testonly.Hasher, 5, 5)
+	ctx := util.NewLogContext(context.Background(), logID)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	for _, test := range []struct {
+		desc            string
+		ctx             context.Context
+		index, snapshot int64
+	}{
+		{desc: "negative index", ctx: ctx, index: -1, snapshot: 3},
+		{desc: "index at tree size", ctx: ctx, index: 3, snapshot: 3},
+		{desc: "tree size beyond tree", ctx: ctx, index: 2, snapshot: 6},
+		{desc: "empty tree", ctx: ctx, index: 0, snapshot: 0},
+		{desc: "cancelled", ctx: cancelled, index: 2, snapshot: 3},
+	} {
+		tx, err := ls.SnapshotForTree(ctx, logID)
+		if err != nil {
+			t.Fatalf("SnapshotForTree()=(_,%v), want (_,nil)", err)
+		}
+		if got, err := InclusionProof(test.ctx, tx, testonly.Hasher, test.index, test.snapshot); err == nil {
+			t.Errorf("%v: InclusionProof(%d, %d)=(%x,nil), want error", test.desc, test.index, test.snapshot, got)
+		}
+		tx.Close()
+	}
+}
+
+func TestProofsPlainHasher(t *testing.T) {
+	const treeSize = 11
+	th, err := merkle.Factory(merkle.PlainSHA256Type)
+	if err != nil {
+		t.Fatalf("Factory()=(_,%v), want (_,nil)", err)
+	}
+	ls, logID, want := sequencedMemoryLog(t, th, treeSize, 4)
+	ctx := util.NewLogContext(context.Background(), logID)
+	verifier := merkle.NewLogVerifier(th)
+
+	tx, err := ls.SnapshotForTree(ctx, logID)
+	if err != nil {
+		t.Fatalf("SnapshotForTree()=(_,%v), want (_,nil)", err)
+	}
+	defer tx.Close()
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot()=(_,%v), want (_,nil)", err)
+	}
+	if got, want := root.RootHash, want.CurrentRoot().Hash(); !bytes.Equal(got, want) {
+		t.Fatalf("RootHash=%x, want %x", got, want)
+	}
+
+	for index := int64(0); index < treeSize; index++ {
+		proof, err := InclusionProof(ctx, tx, th, index, treeSize)
+		if err != nil {
+			t.Errorf("InclusionProof(%d, %d)=(_,%v), want (_,nil)", index, treeSize, err)
+			continue
+		}
+		leafHash := th.HashLeaf([]byte(fmt.Sprintf("leaf %d", index)))
+		if err := verifier.VerifyInclusionProof(index, treeSize, proof, root.RootHash, leafHash); err != nil {
+			t.Errorf("VerifyInclusionProof(%d, %d)=%v, want nil", index, treeSize, err)
+		}
+	}
+
+	// The proof for a smaller tree needs nodes rehashed with the same hasher.
+	const first = 5
+	proof, err := ConsistencyProof(ctx, tx, th, first, treeSize)
+	if err != nil {
+		t.Fatalf("ConsistencyProof(%d, %d)=(_,%v), want (_,nil)", first, treeSize, err)
+	}
+	root1 := want.RootAtSnapshot(first).Hash()
+	if err := verifier.VerifyConsistencyProof(first, treeSize, root1, root.RootHash, proof); err != nil {
+		t.Errorf("VerifyConsistencyProof(%d, %d)=%v, want nil", first, treeSize, err)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/trillian/util"
+)
+
+// LeafRateLimiter is a token bucket that limits how fast leaves are integrated into a
+// single tree. Tokens accumulate at a fixed rate of leaves per second, up to a burst
+// size, and each dequeued leaf uses one. A LeafRateLimiter is kept for the lifetime of
+// a tree's sequencing, rather than a single batch, so the limit holds across batches.
+// It is safe for concurrent use.
+type LeafRateLimiter struct {
+	rate       float64
+	burst      float64
+	timeSource util.TimeSource
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewLeafRateLimiter creates a LeafRateLimiter that allows leavesPerSecond leaves to be
+// integrated on average, and at most burst at once. It starts full.
+func NewLeafRateLimiter(leavesPerSecond float64, burst int, timeSource util.TimeSource) *LeafRateLimiter {
+	return &LeafRateLimiter{
+		rate:       leavesPerSecond,
+		burst:      float64(burst),
+		timeSource: timeSource,
+		tokens:     float64(burst),
+		last:       timeSource.Now(),
+	}
+}
+
+// Allow returns how many of n leaves may be integrated now, which is between zero and n.
+// The tokens are not used until Take is called.
+func (l *LeafRateLimiter) Allow(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	available := int(math.Floor(l.tokens))
+	switch {
+	case available < 0:
+		return 0
+	case available < n:
+		return available
+	default:
+		return n
+	}
+}
+
+// Take uses the tokens for n leaves that have been dequeued.
+func (l *LeafRateLimiter) Take(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.tokens -= float64(n)
+}
+
+// refill adds the tokens that have accumulated since the last call. l.mu must be held.
+func (l *LeafRateLimiter) refill() {
+	now := l.timeSource.Now()
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = math.Min(l.burst, l.tokens+elapsed.Seconds()*l.rate)
+		l.last = now
+	}
+}
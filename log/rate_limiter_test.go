@@ -0,0 +1,78 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/trillian/util"
+)
+
+func TestLeafRateLimiter(t *testing.T) {
+	for _, test := range []struct {
+		desc  string
+		rate  float64
+		burst int
+		tick  time.Duration
+		want  int
+	}{
+		// The burst is taken at once, then 5 leaves per second until the last tick.
+		{desc: "fast ticks", rate: 5, burst: 10, tick: 10 * time.Millisecond, want: 10 + 99},
+		{desc: "slow ticks", rate: 5, burst: 10, tick: 2 * time.Second, want: 10 + 5*18},
+		{desc: "fractional rate", rate: 0.5, burst: 1, tick: 100 * time.Millisecond, want: 1 + 9},
+	} {
+		ts := &util.FakeTimeSource{FakeTime: time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)}
+		l := NewLeafRateLimiter(test.rate, test.burst, ts)
+
+		// Try to take far more than the limit on every tick over a 20 second window.
+		const window = 20 * time.Second
+		total := 0
+		for elapsed := time.Duration(0); elapsed < window; elapsed += test.tick {
+			n := l.Allow(1000)
+			l.Take(n)
+			total += n
+			ts.FakeTime = ts.FakeTime.Add(test.tick)
+		}
+		if ceiling := test.burst + int(test.rate*window.Seconds()); total > ceiling {
+			t.Errorf("%v: took %d leaves in %v, want at most %d", test.desc, total, window, ceiling)
+		}
+		if total != test.want {
+			t.Errorf("%v: took %d leaves in %v, want %d", test.desc, total, window, test.want)
+		}
+	}
+}
+
+func TestLeafRateLimiterAllow(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewLeafRateLimiter(10, 10, ts)
+
+	if got, want := l.Allow(3), 3; got != want {
+		t.Errorf("Allow(3)=%d, want %d", got, want)
+	}
+	// Allow does not use tokens.
+	if got, want := l.Allow(100), 10; got != want {
+		t.Errorf("Allow(100)=%d, want %d", got, want)
+	}
+	// Taking more than was allowed leaves the bucket in debt until it refills.
+	l.Take(15)
+	if got, want := l.Allow(100), 0; got != want {
+		t.Errorf("Allow(100) after Take(15)=%d, want %d", got, want)
+	}
+	ts.FakeTime = ts.FakeTime.Add(time.Second)
+	if got, want := l.Allow(100), 5; got != want {
+		t.Errorf("Allow(100) a second later=%d, want %d", got, want)
+	}
+}
@@ -19,6 +19,8 @@ package log
 import (
 	"context"
 	"fmt"
+	"runtime"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
@@ -26,7 +28,9 @@ import (
 	"github.com/google/trillian/crypto"
 	"github.com/google/trillian/crypto/sigpb"
 	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
 	"github.com/google/trillian/util"
 )
 
@@ -34,43 +38,323 @@ import (
 // TODO(Martin2112): Add support for enabling and controlling sequencing as part of admin API
 
 // Sequencer instances are responsible for integrating new leaves into a single log.
-// Leaves will be assigned unique sequence numbers when they are processed.
-// There is no strong ordering guarantee but in general entries will be processed
-// in order of submission to the log.
+// Leaves will be assigned unique sequence numbers when they are processed, in the
+// order given by the Sequencer's OrderingPolicy.
 type Sequencer struct {
 	hasher     merkle.TreeHasher
 	timeSource util.TimeSource
 	logStorage storage.LogStorage
+	// signer holds the key manager that roots are signed with, which RotateSigner replaces.
+	signer *signerHolder
+	// keyManager is the key manager that the batch in progress signs with. It is taken
+	// from signer when the batch starts.
 	keyManager crypto.PrivateKeyManager
 
 	// These parameters could theoretically be adjusted during operation
 	// sequencerGuardWindow is used to ensure entries newer than the guard window will not be
 	// sequenced until they fall outside it. By default there is no guard window.
 	sequencerGuardWindow time.Duration
+	// retryPolicy controls whether batches that fail with transient storage errors are retried.
+	retryPolicy storage.RetryPolicy
+	// deduplicate causes leaves whose leaf identity hash matches that of an already
+	// sequenced leaf to be dropped rather than integrated again. By default duplicates are
+	// integrated.
+	deduplicate bool
+	// dryRun causes transactions to be rolled back instead of committed, so that the
+	// effect of sequencing can be seen without modifying storage.
+	dryRun bool
+	// onSequenced holds the callbacks run after each batch of leaves is committed.
+	onSequenced []func(SequenceResult)
+	// eventLogger records a structured event for each batch.
+	eventLogger util.EventLogger
+	// hashConcurrency is the number of goroutines used to hash the Merkle tree nodes
+	// completed by a batch.
+	hashConcurrency int
+	// leafValidator, if set, checks each dequeued leaf before it is integrated.
+	leafValidator LeafValidator
+	// skipInvalidLeaves causes leaves that fail validation to be skipped rather than
+	// failing the whole batch.
+	skipInvalidLeaves bool
+	// maxLeafSize, if positive, is the largest leaf value that will be integrated.
+	maxLeafSize int
+	// rateLimiter, if set, limits how many leaves each batch dequeues.
+	rateLimiter *LeafRateLimiter
+	// cursor, if set, is where FIFO batches continue dequeueing from.
+	cursor *DequeueCursor
+	// resignInterval, if positive, is the age at which an unchanged root is signed again.
+	resignInterval time.Duration
+	// ordering controls the order in which queued leaves are assigned indices.
+	ordering OrderingPolicy
+	// batchTimeout, if positive, limits how long each batch, including its retries, may take.
+	batchTimeout time.Duration
+	// nodeCache, if set, is used to read the tree's Merkle nodes.
+	nodeCache *cache.NodeCache
+	// metrics records the metrics named in metrics.go.
+	metrics monitoring.Metrics
+}
+
+// signerHolder holds the key manager that a Sequencer signs roots with. It is shared
+// by copies of the Sequencer, so that RotateSigner affects all of them.
+type signerHolder struct {
+	mu sync.Mutex
+	km crypto.PrivateKeyManager
+}
+
+func (h *signerHolder) get() crypto.PrivateKeyManager {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.km
+}
+
+func (h *signerHolder) set(km crypto.PrivateKeyManager) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.km = km
+}
+
+// DefaultMaxLeafSize is the default limit on the size of a leaf value, in bytes.
+const DefaultMaxLeafSize = 16 << 20
+
+// LeafValidator checks a dequeued leaf before it is integrated into the tree, returning
+// an error if it must not be.
+type LeafValidator func(leaf *trillian.LogLeaf) error
+
+// OrderingPolicy controls the order in which a Sequencer assigns indices to queued leaves.
+type OrderingPolicy int
+
+const (
+	// FIFOOrdering sequences leaves in the order they were queued: leaves queued earlier
+	// always have lower indices, and leaves queued together, which share a queue time,
+	// are ordered by LeafIdentityHash. This is the default.
+	FIFOOrdering OrderingPolicy = iota
+	// UnorderedOrdering lets the storage dequeue eligible leaves in any order, which can
+	// be cheaper as it need not sort the queue. A leaf may then be sequenced after leaves
+	// queued later than it, in a later batch if the queue holds more than a batch of
+	// eligible leaves, and indices no longer reflect queue order.
+	UnorderedOrdering
+)
+
+func (p OrderingPolicy) String() string {
+	switch p {
+	case FIFOOrdering:
+		return "fifo"
+	case UnorderedOrdering:
+		return "unordered"
+	}
+	return fmt.Sprintf("OrderingPolicy(%d)", int(p))
+}
+
+// ParseOrderingPolicy returns the OrderingPolicy named s, which is "fifo" or "unordered".
+func ParseOrderingPolicy(s string) (OrderingPolicy, error) {
+	switch s {
+	case "fifo":
+		return FIFOOrdering, nil
+	case "unordered":
+		return UnorderedOrdering, nil
+	}
+	return 0, fmt.Errorf("unknown ordering policy %q, want fifo or unordered", s)
 }
 
 // maxTreeDepth sets an upper limit on the size of Log trees.
 // TODO(al): We actually can't go beyond 2^63 entries because we use int64s,
-//           but we need to calculate tree depths from a multiple of 8 due to
-//           the subtrees.
+//
+//	but we need to calculate tree depths from a multiple of 8 due to
+//	the subtrees.
 const maxTreeDepth = 64
 
 // NewSequencer creates a new Sequencer instance for the specified inputs.
 func NewSequencer(hasher merkle.TreeHasher, timeSource util.TimeSource, logStorage storage.LogStorage, km crypto.PrivateKeyManager) *Sequencer {
 	return &Sequencer{
-		hasher:     hasher,
-		timeSource: timeSource,
-		logStorage: logStorage,
-		keyManager: km,
+		hasher:          hasher,
+		timeSource:      timeSource,
+		logStorage:      logStorage,
+		signer:          &signerHolder{km: km},
+		keyManager:      km,
+		eventLogger:     util.TextEventLogger{},
+		metrics:         monitoring.NoopMetrics{},
+		hashConcurrency: runtime.NumCPU(),
+		maxLeafSize:     DefaultMaxLeafSize,
 	}
 }
 
+// RotateSigner replaces the key manager that roots are signed with. It is safe to call
+// while batches are running: a batch signs with the key manager that was current when
+// it started, so km is used from the next batch. Each signed root records the ID of
+// the key that signed it in its KeyId.
+func (s *Sequencer) RotateSigner(km crypto.PrivateKeyManager) {
+	s.signer.set(km)
+}
+
 // SetGuardWindow changes the interval that must elapse between leaves being queued and them
 // being eligible for sequencing. The default is a zero interval.
 func (s *Sequencer) SetGuardWindow(sequencerGuardWindow time.Duration) {
 	s.sequencerGuardWindow = sequencerGuardWindow
 }
 
+// SetRetryPolicy sets the policy for retrying batches that fail with transient errors.
+// Each attempt runs in a new transaction. By default batches are not retried.
+func (s *Sequencer) SetRetryPolicy(policy storage.RetryPolicy) {
+	s.retryPolicy = policy
+}
+
+// SetDryRun controls whether the sequencer runs in dry-run mode. In dry-run mode all the
+// reads, hashing and signing for a batch are done but the transaction is rolled back rather
+// than committed. SequenceBatch still returns the result that would have been committed.
+func (s *Sequencer) SetDryRun(dryRun bool) {
+	s.dryRun = dryRun
+}
+
+// OnSequenced registers a callback to be run after a batch containing at least one leaf
+// has been committed. Callbacks are run synchronously in the order they were registered,
+// after the commit and before SequenceBatch returns, so before the next batch begins.
+// They are not run for empty batches, failed batches or in dry-run mode.
+func (s *Sequencer) OnSequenced(f func(SequenceResult)) {
+	s.onSequenced = append(s.onSequenced, f)
+}
+
+// SetEventLogger sets the logger that records a sequence_batch event for each batch,
+// with the fields tree_id, batch_size, leaves, tree_size, duration_ms and, if the batch
+// failed, error. The default writes the events to the INFO log as text.
+func (s *Sequencer) SetEventLogger(l util.EventLogger) {
+	s.eventLogger = l
+}
+
+// SetMetrics sets where the sequencer records the metrics named in metrics.go. By
+// default they are discarded.
+func (s *Sequencer) SetMetrics(m monitoring.Metrics) {
+	s.metrics = m
+}
+
+// SetDeduplicateLeaves controls whether dequeued leaves that duplicate an already sequenced
+// leaf, or an earlier leaf in the same batch, are dropped instead of being integrated into
+// the tree. Leaves are considered duplicates if they have the same leaf identity hash;
+// leaves that only share a Merkle leaf hash are all integrated. The default is to
+// integrate all leaves.
+func (s *Sequencer) SetDeduplicateLeaves(deduplicate bool) {
+	s.deduplicate = deduplicate
+}
+
+// SetHashConcurrency sets the number of goroutines used to hash the internal Merkle tree
+// nodes for each batch. The resulting tree is the same whatever the setting. Values less
+// than 1 are treated as 1. The default is runtime.NumCPU().
+func (s *Sequencer) SetHashConcurrency(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	s.hashConcurrency = workers
+}
+
+// SetLeafValidator sets a function that checks each dequeued leaf before it is integrated,
+// in addition to the check that its Merkle leaf hash is the size the hasher produces. By
+// default leaves are not checked.
+func (s *Sequencer) SetLeafValidator(v LeafValidator) {
+	s.leafValidator = v
+}
+
+// SetSkipInvalidLeaves controls what happens to leaves that fail the checks enabled by
+// SetLeafValidator. By default the whole batch fails. If skip is true, the invalid leaves
+// are logged, left out of the tree and reported in SequenceResult.Skipped, and the rest
+// of the batch is integrated. Skipped leaves are moved to the storage's dead-letter queue
+// with the reason they were rejected, from where they can be listed and queued again.
+// Setting skip also enables the hash size check if no validator has been set.
+func (s *Sequencer) SetSkipInvalidLeaves(skip bool) {
+	s.skipInvalidLeaves = skip
+}
+
+// SetMaxLeafSize sets the largest leaf value, in bytes, that will be integrated. Larger
+// leaves are always skipped, as for SetSkipInvalidLeaves, since failing the batch would
+// leave them at the head of the queue. The limit only applies to leaves whose value is
+// returned by the storage when they are dequeued; values of n less than 1 mean no limit.
+// The default is DefaultMaxLeafSize.
+func (s *Sequencer) SetMaxLeafSize(n int) {
+	s.maxLeafSize = n
+}
+
+// SetOrderingPolicy sets the order in which queued leaves are assigned indices. The
+// default, FIFOOrdering, is the safe choice for personalities that rely on indices
+// following submission order; UnorderedOrdering trades that away for cheaper dequeues.
+func (s *Sequencer) SetOrderingPolicy(p OrderingPolicy) {
+	s.ordering = p
+}
+
+// SetBatchTimeout limits how long each call to SequenceBatch may take, including any
+// retries, to d. A batch that runs out of time is rolled back and fails with a transient
+// storage error, so that it can be retried in a later pass. Storage that does not watch
+// the batch's context is not interrupted, but the batch is abandoned before it commits.
+// Zero, the default, means no limit.
+func (s *Sequencer) SetBatchTimeout(d time.Duration) {
+	s.batchTimeout = d
+}
+
+// SetNodeCache sets a cache that batches read the tree's Merkle nodes through. The same
+// cache should be kept for the tree between batches, and every batch that sequences into
+// the tree should use it, so that the nodes it holds are dropped when they are written.
+// By default nodes are always read from storage.
+func (s *Sequencer) SetNodeCache(c *cache.NodeCache) {
+	s.nodeCache = c
+}
+
+// checkLeaf returns an error if leaf must not be integrated into the tree.
+func (s Sequencer) checkLeaf(leaf *trillian.LogLeaf) error {
+	if got, want := len(leaf.MerkleLeafHash), s.hasher.Size(); got != want {
+		return fmt.Errorf("Merkle leaf hash is %d bytes, want %d", got, want)
+	}
+	if s.leafValidator != nil {
+		return s.leafValidator(leaf)
+	}
+	return nil
+}
+
+// removeInvalidLeaves splits leaves into those that can be integrated and those that are
+// skipped because they are too large or, if enabled, fail checkLeaf, for which it also
+// returns the reasons. If invalid leaves are not being skipped the first error from
+// checkLeaf is returned instead.
+func (s Sequencer) removeInvalidLeaves(logID int64, leaves []*trillian.LogLeaf) ([]*trillian.LogLeaf, []*trillian.LogLeaf, []string, error) {
+	checkAll := s.leafValidator != nil || s.skipInvalidLeaves
+	var valid, skipped []*trillian.LogLeaf
+	var reasons []string
+	for _, leaf := range leaves {
+		if s.maxLeafSize > 0 && len(leaf.LeafValue) > s.maxLeafSize {
+			reason := fmt.Sprintf("value is %d bytes, want at most %d", len(leaf.LeafValue), s.maxLeafSize)
+			glog.Warningf("%v: Sequencer skipping leaf with identity hash %x: %s", logID, leaf.LeafIdentityHash, reason)
+			skipped = append(skipped, leaf)
+			reasons = append(reasons, reason)
+			continue
+		}
+		if !checkAll {
+			valid = append(valid, leaf)
+			continue
+		}
+		if err := s.checkLeaf(leaf); err != nil {
+			if !s.skipInvalidLeaves {
+				return nil, nil, nil, fmt.Errorf("%v: invalid leaf with identity hash %x: %v", logID, leaf.LeafIdentityHash, err)
+			}
+			glog.Warningf("%v: Sequencer skipping invalid leaf with identity hash %x: %v", logID, leaf.LeafIdentityHash, err)
+			skipped = append(skipped, leaf)
+			reasons = append(reasons, err.Error())
+			continue
+		}
+		valid = append(valid, leaf)
+	}
+	return valid, skipped, reasons, nil
+}
+
+// deadLetterLeaves moves the skipped leaves to the dead-letter queue of tx, with the
+// reasons they were skipped.
+func (s Sequencer) deadLetterLeaves(tx storage.LogTreeTX, skipped []*trillian.LogLeaf, reasons []string) error {
+	now := s.timeSource.Now().UnixNano()
+	letters := make([]storage.DeadLetter, 0, len(skipped))
+	for i, leaf := range skipped {
+		letters = append(letters, storage.DeadLetter{
+			LeafIdentityHash: leaf.LeafIdentityHash,
+			MerkleLeafHash:   leaf.MerkleLeafHash,
+			Reason:           reasons[i],
+			TimestampNanos:   now,
+		})
+	}
+	return tx.DeadLetterLeaves(letters)
+}
+
 // TODO: This currently doesn't use the batch api for fetching the required nodes. This
 // would be more efficient but requires refactoring.
 func (s Sequencer) buildMerkleTreeFromStorageAtRoot(ctx context.Context, root trillian.SignedLogRoot, tx storage.TreeTX) (*merkle.CompactMerkleTree, error) {
@@ -111,18 +395,23 @@ func (s Sequencer) buildNodesFromNodeMap(nodeMap map[string]storage.Node, newVer
 
 func (s Sequencer) sequenceLeaves(mt *merkle.CompactMerkleTree, leaves []*trillian.LogLeaf) (map[string]storage.Node, []*trillian.LogLeaf, error) {
 	nodeMap := make(map[string]storage.Node)
+	hashes := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		hashes[i] = leaf.MerkleLeafHash
+	}
 	// Update the tree state and sequence the leaves and assign sequence numbers to the new leaves
+	firstSeq := mt.AddLeafHashes(hashes, s.hashConcurrency, func(depth int, index int64, hash []byte) {
+		nodeID, err := storage.NewNodeIDForTreeCoords(int64(depth), index, maxTreeDepth)
+		if err != nil {
+			return
+		}
+		nodeMap[nodeID.String()] = storage.Node{
+			NodeID: nodeID,
+			Hash:   hash,
+		}
+	})
 	for i, leaf := range leaves {
-		seq := mt.AddLeafHash(leaf.MerkleLeafHash, func(depth int, index int64, hash []byte) {
-			nodeID, err := storage.NewNodeIDForTreeCoords(int64(depth), index, maxTreeDepth)
-			if err != nil {
-				return
-			}
-			nodeMap[nodeID.String()] = storage.Node{
-				NodeID: nodeID,
-				Hash:   hash,
-			}
-		})
+		seq := firstSeq + int64(i)
 		// The leaf has now been sequenced.
 		leaves[i].LeafIndex = seq
 		// Store leaf hash in the Merkle tree too:
@@ -139,6 +428,64 @@ func (s Sequencer) sequenceLeaves(mt *merkle.CompactMerkleTree, leaves []*trilli
 	return nodeMap, leaves, nil
 }
 
+// checkLeafIndices checks that leaves have been given consecutive indices starting at
+// treeSize, the size of the tree they are added to, so that none of them is given the
+// index of a leaf that is already sequenced. Otherwise it returns a TransientError, as
+// the tree may have been changed by another sequencer while it was being read, and a
+// retry that reads it again will assign the right indices.
+func checkLeafIndices(leaves []*trillian.LogLeaf, treeSize int64) error {
+	for i, leaf := range leaves {
+		if want := treeSize + int64(i); leaf.LeafIndex != want {
+			return storage.Error{
+				ErrType: storage.TransientError,
+				Detail:  fmt.Sprintf("leaf %d of the batch was assigned index %d in a tree of size %d, want %d", i, leaf.LeafIndex, treeSize, want),
+			}
+		}
+	}
+	return nil
+}
+
+// removeDuplicateLeaves splits leaves into those that should be sequenced and those that
+// duplicate either a leaf already in the tree or an earlier leaf in the batch. Leaves are
+// duplicates if they have the same leaf identity hash; a matching Merkle leaf hash alone
+// does not make one. Leaves that duplicate a sequenced leaf have their LeafIndex set to
+// that leaf's index. The returned map holds the leaf that each in-batch duplicate
+// repeats, so its index can be set once that leaf has been sequenced.
+func (s Sequencer) removeDuplicateLeaves(tx storage.LogTreeTX, leaves []*trillian.LogLeaf) ([]*trillian.LogLeaf, []*trillian.LogLeaf, map[*trillian.LogLeaf]*trillian.LogLeaf, error) {
+	hashes := make([][]byte, 0, len(leaves))
+	for _, leaf := range leaves {
+		hashes = append(hashes, leaf.LeafIdentityHash)
+	}
+	existing, err := tx.GetLeavesByIdentityHash(hashes)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	sequenced := make(map[string]int64)
+	for _, leaf := range existing {
+		sequenced[string(leaf.LeafIdentityHash)] = leaf.LeafIndex
+	}
+
+	var unique, duplicates []*trillian.LogLeaf
+	duplicateOf := make(map[*trillian.LogLeaf]*trillian.LogLeaf)
+	inBatch := make(map[string]*trillian.LogLeaf)
+	for _, leaf := range leaves {
+		key := string(leaf.LeafIdentityHash)
+		if index, ok := sequenced[key]; ok {
+			leaf.LeafIndex = index
+			duplicates = append(duplicates, leaf)
+			continue
+		}
+		if first, ok := inBatch[key]; ok {
+			duplicateOf[leaf] = first
+			duplicates = append(duplicates, leaf)
+			continue
+		}
+		inBatch[key] = leaf
+		unique = append(unique, leaf)
+	}
+	return unique, duplicates, duplicateOf, nil
+}
+
 func (s Sequencer) initMerkleTreeFromStorage(ctx context.Context, currentRoot trillian.SignedLogRoot, tx storage.LogTreeTX) (*merkle.CompactMerkleTree, error) {
 	if currentRoot.TreeSize == 0 {
 		return merkle.NewCompactMerkleTree(s.hasher), nil
@@ -148,50 +495,248 @@ func (s Sequencer) initMerkleTreeFromStorage(ctx context.Context, currentRoot tr
 	return s.buildMerkleTreeFromStorageAtRoot(ctx, currentRoot, tx)
 }
 
-func (s Sequencer) createRootSignature(ctx context.Context, root trillian.SignedLogRoot) (*sigpb.DigitallySigned, error) {
+// signLogRoot signs the STH for root, setting its Signature and the KeyId of the
+// signing key.
+func (s Sequencer) signLogRoot(ctx context.Context, root *trillian.SignedLogRoot) error {
+	keyID, err := crypto.KeyID(s.keyManager.Public())
+	if err != nil {
+		glog.Warningf("%s: signer failed to get key ID: %v", util.LogIDPrefix(ctx), err)
+		return err
+	}
 	trillianSigner := crypto.NewSignerFromPrivateKeyManager(s.keyManager)
-	signature, err := trillianSigner.Sign(crypto.HashLogRoot(root))
+	signature, err := trillianSigner.Sign(crypto.STHFromRoot(*root).SignedData())
 	if err != nil {
 		glog.Warningf("%s: signer failed to sign root: %v", util.LogIDPrefix(ctx), err)
-		return nil, err
+		return err
+	}
+
+	root.Signature = signature
+	root.KeyId = keyID
+	return nil
+}
+
+// SequenceResult describes the state of a log after a call to SequenceBatch.
+type SequenceResult struct {
+	// LeafCount is the number of leaves that were integrated into the tree.
+	LeafCount int
+	// TreeSize is the size of the tree after sequencing.
+	TreeSize int64
+	// RootHash is the root hash of the tree after sequencing.
+	RootHash []byte
+	// Signature is the signature over the log's latest signed root.
+	Signature *sigpb.DigitallySigned
+	// Duplicates holds the dequeued leaves that were dropped because they duplicate
+	// another leaf, if deduplication is enabled. Their LeafIndex is that of the leaf
+	// they duplicate.
+	Duplicates []*trillian.LogLeaf
+	// Skipped holds the dequeued leaves that were not integrated because they were too
+	// large or failed validation, if invalid leaves are being skipped.
+	Skipped []*trillian.LogLeaf
+}
+
+func newSequenceResult(leafCount int, root trillian.SignedLogRoot) SequenceResult {
+	return SequenceResult{
+		LeafCount: leafCount,
+		TreeSize:  root.TreeSize,
+		RootHash:  root.RootHash,
+		Signature: root.Signature,
+	}
+}
+
+// reportQueuedLeaves exports the number of leaves still waiting to be sequenced once
+// a batch has been dequeued. Failing to count them does not fail the batch.
+func (s Sequencer) reportQueuedLeaves(logID int64, tx storage.LogTreeTX) {
+	queued, err := tx.QueuedLeafCount()
+	if err != nil {
+		glog.Warningf("%v: Sequencer failed to count queued leaves: %v", logID, err)
+		return
 	}
+	s.metrics.SetGauge(QueuedLeavesMetric, logIDLabels(logID), float64(queued))
+}
 
-	return signature, nil
+// SetRateLimiter sets a limiter on the rate at which leaves are integrated into the
+// tree. Batches dequeue no more leaves than it allows, which may be none. Pass the
+// same limiter to each Sequencer created for a tree so that the limit holds across
+// batches. By default there is no limit.
+func (s *Sequencer) SetRateLimiter(limiter *LeafRateLimiter) {
+	s.rateLimiter = limiter
+}
+
+// SetForceResignInterval sets how old the latest root may get before a batch that
+// integrates no leaves signs it again, with a new timestamp, so that clients see fresh
+// roots while the tree is unchanged. Values less than 1 mean that unchanged roots are
+// never signed again, which avoids signing operations while the queue is empty; this
+// is the default.
+func (s *Sequencer) SetForceResignInterval(d time.Duration) {
+	s.resignInterval = d
+}
+
+// SetDequeueCursor makes batches page through the queue, each dequeueing the leaves after
+// those taken by the last committed batch that used cursor, which avoids rescanning the
+// head of a large queue. Pass the same cursor to each Sequencer created for a tree. It is
+// only used with FIFOOrdering, and is not advanced in dry-run mode. A leaf queued with a
+// queue time before the cursor's position, for example by a frontend whose clock is
+// behind, is not dequeued until a batch finds fewer eligible leaves than its limit, which
+// takes the cursor back to the start of the queue. By default batches do not page.
+func (s *Sequencer) SetDequeueCursor(cursor *DequeueCursor) {
+	s.cursor = cursor
 }
 
 // SequenceBatch wraps up all the operations needed to take a batch of queued leaves
-// and integrate them into the tree.
-// TODO(Martin2112): Can possibly improve by deferring a function that attempts to rollback,
-// which will fail if the tx was committed. Should only do this if we can hide the details of
-// the underlying storage transactions and it doesn't create other problems.
-func (s Sequencer) SequenceBatch(ctx context.Context, logID int64, limit int) (int, error) {
-	tx, err := s.logStorage.BeginForTree(ctx, logID)
+// and integrate them into the tree. If ctx is cancelled or expires before the batch
+// is committed the transaction is rolled back and the context's error returned.
+// Batches that fail with a retriable error are retried according to the retry policy.
+// If the batch timeout passes first, the batch fails with a transient storage error.
+// Returned errors name the tree and the step of the batch that failed, and wrap the
+// underlying error so that it can still be matched with errors.Is and errors.As.
+func (s Sequencer) SequenceBatch(ctx context.Context, logID int64, limit int) (SequenceResult, error) {
+	s.keyManager = s.signer.get()
+	labels := logIDLabels(logID)
+	if s.rateLimiter != nil {
+		limit = s.rateLimiter.Allow(limit)
+	}
+	start := s.timeSource.Now()
+	res, err := s.sequenceBatchWithRetry(ctx, logID, limit)
+	end := s.timeSource.Now()
+	d := end.Sub(start)
+	if s.rateLimiter != nil && err == nil {
+		s.rateLimiter.Take(res.LeafCount + len(res.Duplicates) + len(res.Skipped))
+	}
+	s.metrics.ObserveHistogram(BatchDurationMetric, labels, d.Seconds())
+	s.metrics.AddCounter(BatchesMetric, labels, 1)
+	s.logBatch(logID, limit, res, d, err)
 	if err != nil {
-		glog.Warningf("%v: Sequencer failed to start tx: %v", logID, err)
-		return 0, err
+		s.metrics.AddCounter(ErrorsMetric, labels, 1)
+		return res, fmt.Errorf("tree %d batch: %w", logID, err)
 	}
-	defer tx.Close()
+	// Nothing is integrated by a dry run.
+	if !s.dryRun {
+		s.metrics.AddCounter(LeavesSequencedMetric, labels, float64(res.LeafCount))
+		s.metrics.SetGauge(TreeSizeMetric, labels, float64(res.TreeSize))
+		s.metrics.SetGauge(LastSequencedMetric, labels, float64(end.UnixNano())/float64(time.Second))
+	}
+	return res, nil
+}
 
+// logBatch records the event for a batch that took d and produced res and err.
+func (s Sequencer) logBatch(logID int64, limit int, res SequenceResult, d time.Duration, err error) {
+	fields := util.Fields{
+		"tree_id":     logID,
+		"batch_size":  limit,
+		"leaves":      res.LeafCount,
+		"tree_size":   res.TreeSize,
+		"duration_ms": float64(d) / float64(time.Millisecond),
+	}
+	if s.dryRun {
+		fields["dry_run"] = true
+	}
+	if len(res.Skipped) > 0 {
+		fields["skipped"] = len(res.Skipped)
+	}
+	if err != nil {
+		fields["error"] = err
+	}
+	s.eventLogger.LogEvent("sequence_batch", fields)
+}
+
+func (s Sequencer) sequenceBatchWithRetry(ctx context.Context, logID int64, limit int) (SequenceResult, error) {
+	batchCtx := ctx
+	if s.batchTimeout > 0 {
+		var cancel context.CancelFunc
+		batchCtx, cancel = context.WithTimeout(ctx, s.batchTimeout)
+		defer cancel()
+	}
+	var res SequenceResult
+	err := storage.RunInLogTX(batchCtx, s.logStorage, logID, s.retryPolicy, func(tx storage.LogTreeTX) error {
+		if s.nodeCache != nil {
+			tx = s.nodeCache.WrapLogTreeTX(tx)
+		}
+		var err error
+		res, err = s.sequenceBatch(batchCtx, logID, tx, limit)
+		return err
+	})
+	// Only the batch's own deadline is transient, not the caller giving up on it.
+	if err != nil && ctx.Err() == nil && batchCtx.Err() == context.DeadlineExceeded {
+		err = storage.Error{
+			ErrType: storage.TransientError,
+			Detail:  fmt.Sprintf("batch timed out after %v", s.batchTimeout),
+			Cause:   err,
+		}
+	}
+	return res, err
+}
+
+// sequenceBatch makes a single attempt at integrating a batch of queued leaves using tx,
+// which it commits if the attempt succeeds.
+// TODO(Martin2112): Can possibly improve by deferring a function that attempts to rollback,
+// which will fail if the tx was committed. Should only do this if we can hide the details of
+// the underlying storage transactions and it doesn't create other problems.
+func (s Sequencer) sequenceBatch(ctx context.Context, logID int64, tx storage.LogTreeTX, limit int) (SequenceResult, error) {
 	// Very recent leaves inside the guard window will not be available for sequencing
 	guardCutoffTime := s.timeSource.Now().Add(-s.sequencerGuardWindow)
-	leaves, err := tx.DequeueLeaves(limit, guardCutoffTime)
+	var leaves []*trillian.LogLeaf
+	var nextToken []byte
+	var err error
+	switch {
+	case s.ordering == UnorderedOrdering:
+		leaves, err = tx.DequeueLeavesUnordered(limit, guardCutoffTime)
+	case s.cursor != nil:
+		leaves, nextToken, err = tx.DequeueLeavesAfter(limit, guardCutoffTime, s.cursor.get())
+	default:
+		leaves, err = tx.DequeueLeaves(limit, guardCutoffTime)
+	}
 	if err != nil {
 		glog.Warningf("%v: Sequencer failed to dequeue leaves: %v", logID, err)
-		return 0, err
+		return SequenceResult{}, fmt.Errorf("dequeue leaves: %w", err)
 	}
+	s.reportQueuedLeaves(logID, tx)
 
 	// Get the latest known root from storage
 	currentRoot, err := tx.LatestSignedLogRoot()
 	if err != nil {
 		glog.Warningf("%v: Sequencer failed to get latest root: %v", logID, err)
-		return 0, err
+		return SequenceResult{}, fmt.Errorf("get latest root: %w", err)
 	}
 
 	// TODO(al): Have a better detection mechanism for there being no stored root.
 	// TODO(mhs): Might be better to create empty root in provisioning API when it exists
 	if currentRoot.RootHash == nil {
 		glog.Warningf("%v: Fresh log - no previous TreeHeads exist.", logID)
-		return 0, s.SignRoot(ctx, logID)
+		newRoot, err := s.signRoot(ctx, logID)
+		if err != nil {
+			return SequenceResult{}, fmt.Errorf("sign initial root: %w", err)
+		}
+		return newSequenceResult(0, newRoot), nil
+	}
+
+	var skipped []*trillian.LogLeaf
+	if s.maxLeafSize > 0 || s.leafValidator != nil || s.skipInvalidLeaves {
+		var reasons []string
+		leaves, skipped, reasons, err = s.removeInvalidLeaves(logID, leaves)
+		if err != nil {
+			glog.Warningf("%v: Sequencer failed to validate leaves: %v", logID, err)
+			return SequenceResult{}, fmt.Errorf("validate leaves: %w", err)
+		}
+		if len(skipped) > 0 {
+			glog.Warningf("%v: Sequencer skipped %d invalid leaves", logID, len(skipped))
+			if err := s.deadLetterLeaves(tx, skipped, reasons); err != nil {
+				glog.Warningf("%v: Sequencer failed to dead-letter leaves: %v", logID, err)
+				return SequenceResult{}, fmt.Errorf("dead-letter %d leaves: %w", len(skipped), err)
+			}
+		}
+	}
+
+	var duplicates []*trillian.LogLeaf
+	var duplicateOf map[*trillian.LogLeaf]*trillian.LogLeaf
+	if s.deduplicate && len(leaves) > 0 {
+		leaves, duplicates, duplicateOf, err = s.removeDuplicateLeaves(tx, leaves)
+		if err != nil {
+			glog.Warningf("%v: Sequencer failed to look up duplicate leaves: %v", logID, err)
+			return SequenceResult{}, fmt.Errorf("look up duplicate leaves: %w", err)
+		}
+		if len(duplicates) > 0 {
+			glog.Infof("%v: Sequencer dropped %d duplicate leaves", logID, len(duplicates))
+		}
 	}
 
 	// There might be no work to be done. But we possibly still need to create an STH if the
@@ -199,12 +744,25 @@ func (s Sequencer) SequenceBatch(ctx context.Context, logID int64, limit int) (i
 	if len(leaves) == 0 {
 		// We have nothing to integrate into the tree
 		glog.Infof("No leaves sequenced in this signing operation.")
-		return 0, tx.Commit()
+		root := currentRoot
+		if s.resignInterval > 0 && s.timeSource.Now().Sub(time.Unix(0, currentRoot.TimestampNanos)) >= s.resignInterval {
+			if root, err = s.resignRoot(ctx, logID, tx, currentRoot); err != nil {
+				return SequenceResult{}, fmt.Errorf("re-sign root at size %d: %w", currentRoot.TreeSize, err)
+			}
+		}
+		if err := s.commit(logID, tx); err != nil {
+			return SequenceResult{}, fmt.Errorf("commit: %w", err)
+		}
+		s.advanceCursor(nextToken)
+		res := newSequenceResult(0, root)
+		res.Duplicates = duplicates
+		res.Skipped = skipped
+		return res, nil
 	}
 
 	merkleTree, err := s.initMerkleTreeFromStorage(ctx, currentRoot, tx)
 	if err != nil {
-		return 0, err
+		return SequenceResult{}, fmt.Errorf("load tree at size %d: %w", currentRoot.TreeSize, err)
 	}
 
 	// We've done all the reads, can now do the updates.
@@ -213,24 +771,37 @@ func (s Sequencer) SequenceBatch(ctx context.Context, logID int64, limit int) (i
 	// number so it should not be possible for colliding updates to commit.
 	newVersion := tx.WriteRevision()
 	if got, want := newVersion, currentRoot.TreeRevision+int64(1); got != want {
-		return 0, fmt.Errorf("%v: got writeRevision of %v, but expected %v", logID, got, want)
+		return SequenceResult{}, fmt.Errorf("got writeRevision of %v, but expected %v", got, want)
 	}
 
 	// Assign leaf sequence numbers and collate node updates
 	nodeMap, sequencedLeaves, err := s.sequenceLeaves(merkleTree, leaves)
 	if err != nil {
-		return 0, err
+		return SequenceResult{}, fmt.Errorf("hash %d leaves at size %d: %w", len(leaves), currentRoot.TreeSize, err)
 	}
 
 	// We should still have the same number of leaves
 	if want, got := len(leaves), len(sequencedLeaves); want != got {
-		return 0, fmt.Errorf("%v: wanted: %v leaves after sequencing but we got: %v", logID, want, got)
+		return SequenceResult{}, fmt.Errorf("wanted: %v leaves after sequencing but we got: %v", want, got)
+	}
+	if err := checkLeafIndices(sequencedLeaves, currentRoot.TreeSize); err != nil {
+		glog.Warningf("%v: Sequencer assigned conflicting leaf indices: %v", logID, err)
+		return SequenceResult{}, err
+	}
+	// The leaves are integrated at the timestamp of the root that will include them.
+	integrateNanos := s.timeSource.Now().UnixNano()
+	for _, leaf := range sequencedLeaves {
+		leaf.IntegrateTimestampNanos = integrateNanos
+	}
+	for dup, first := range duplicateOf {
+		dup.LeafIndex = first.LeafIndex
+		dup.IntegrateTimestampNanos = integrateNanos
 	}
 
 	// Write the new sequence numbers to the leaves in the DB
 	if err := tx.UpdateSequencedLeaves(sequencedLeaves); err != nil {
 		glog.Warningf("%v: Sequencer failed to update sequenced leaves: %v", logID, err)
-		return 0, err
+		return SequenceResult{}, fmt.Errorf("update sequenced leaves at size %d: %w", currentRoot.TreeSize, err)
 	}
 
 	// Build objects for the nodes to be updated. Because we deduped via the map each
@@ -240,53 +811,95 @@ func (s Sequencer) SequenceBatch(ctx context.Context, logID int64, limit int) (i
 	if err != nil {
 		// probably an internal error with map building, unexpected
 		glog.Warningf("%v: Failed to build target nodes in sequencer: %v", logID, err)
-		return 0, err
+		return SequenceResult{}, fmt.Errorf("build nodes: %w", err)
 	}
 
 	// Now insert or update the nodes affected by the above, at the new tree version
 	if err := tx.SetMerkleNodes(targetNodes); err != nil {
 		glog.Warningf("%v: Sequencer failed to set Merkle nodes: %v", logID, err)
-		return 0, err
+		return SequenceResult{}, fmt.Errorf("set Merkle nodes at revision %d: %w", newVersion, err)
 	}
 
 	// Create the log root ready for signing
 	newLogRoot := trillian.SignedLogRoot{
 		RootHash:       merkleTree.CurrentRoot(),
-		TimestampNanos: s.timeSource.Now().UnixNano(),
+		TimestampNanos: integrateNanos,
 		TreeSize:       merkleTree.Size(),
 		LogId:          currentRoot.LogId,
 		TreeRevision:   newVersion,
 	}
 
 	// Hash and sign the root, update it with the signature
-	signature, err := s.createRootSignature(ctx, newLogRoot)
-	if err != nil {
+	if err := s.signLogRoot(ctx, &newLogRoot); err != nil {
 		glog.Warningf("%v: signer failed to sign root: %v", logID, err)
-		return 0, err
+		return SequenceResult{}, fmt.Errorf("sign root at size %d: %w", newLogRoot.TreeSize, err)
 	}
 
-	newLogRoot.Signature = signature
-
 	if err := tx.StoreSignedLogRoot(newLogRoot); err != nil {
 		glog.Warningf("%v: failed to write updated tree root: %v", logID, err)
-		return 0, err
+		return SequenceResult{}, fmt.Errorf("store root at size %d: %w", newLogRoot.TreeSize, err)
+	}
+
+	// Don't commit if we were cancelled part way through, the deferred Close will
+	// roll back the whole batch.
+	if err := ctx.Err(); err != nil {
+		glog.Warningf("%v: Sequencer abandoning batch: %v", logID, err)
+		return SequenceResult{}, err
 	}
 
 	// The batch is now fully sequenced and we're done
-	if err := tx.Commit(); err != nil {
-		return 0, err
+	if err := s.commit(logID, tx); err != nil {
+		return SequenceResult{}, fmt.Errorf("commit: %w", err)
 	}
+	s.advanceCursor(nextToken)
 
 	glog.Infof("%v: sequenced %v leaves, size %v, tree-revision %v", logID, len(leaves), newLogRoot.TreeSize, newLogRoot.TreeRevision)
-	return len(leaves), nil
+	res := newSequenceResult(len(leaves), newLogRoot)
+	res.Duplicates = duplicates
+	res.Skipped = skipped
+	if !s.dryRun {
+		for _, f := range s.onSequenced {
+			f(res)
+		}
+	}
+	return res, nil
+}
+
+// resignRoot stores a new signature for the unchanged tree described by root in tx,
+// with the current time, and returns the new root.
+func (s Sequencer) resignRoot(ctx context.Context, logID int64, tx storage.LogTreeTX, root trillian.SignedLogRoot) (trillian.SignedLogRoot, error) {
+	newLogRoot := trillian.SignedLogRoot{
+		RootHash:       root.RootHash,
+		TimestampNanos: s.timeSource.Now().UnixNano(),
+		TreeSize:       root.TreeSize,
+		LogId:          root.LogId,
+		TreeRevision:   tx.WriteRevision(),
+	}
+	if err := s.signLogRoot(ctx, &newLogRoot); err != nil {
+		glog.Warningf("%v: signer failed to sign root: %v", logID, err)
+		return trillian.SignedLogRoot{}, err
+	}
+	if err := tx.StoreSignedLogRoot(newLogRoot); err != nil {
+		glog.Warningf("%v: failed to write re-signed tree root: %v", logID, err)
+		return trillian.SignedLogRoot{}, err
+	}
+	glog.Infof("%v: re-signed unchanged root, size %v, tree-revision %v", logID, newLogRoot.TreeSize, newLogRoot.TreeRevision)
+	return newLogRoot, nil
 }
 
 // SignRoot wraps up all the operations for creating a new log signed root.
 func (s Sequencer) SignRoot(ctx context.Context, logID int64) error {
+	s.keyManager = s.signer.get()
+	_, err := s.signRoot(ctx, logID)
+	return err
+}
+
+// signRoot creates and stores a new log signed root, which is returned.
+func (s Sequencer) signRoot(ctx context.Context, logID int64) (trillian.SignedLogRoot, error) {
 	tx, err := s.logStorage.BeginForTree(ctx, logID)
 	if err != nil {
 		glog.Warningf("%v: signer failed to start tx: %v", logID, err)
-		return err
+		return trillian.SignedLogRoot{}, err
 	}
 	defer tx.Close()
 
@@ -294,14 +907,14 @@ func (s Sequencer) SignRoot(ctx context.Context, logID int64) error {
 	currentRoot, err := tx.LatestSignedLogRoot()
 	if err != nil {
 		glog.Warningf("%v: signer failed to get latest root: %v", logID, err)
-		return err
+		return trillian.SignedLogRoot{}, err
 	}
 
 	// Initialize a Merkle Tree from the state in storage. This should fail if the tree is
 	// in a corrupt state.
 	merkleTree, err := s.initMerkleTreeFromStorage(ctx, currentRoot, tx)
 	if err != nil {
-		return err
+		return trillian.SignedLogRoot{}, err
 	}
 
 	// Build the updated root, ready for signing
@@ -314,19 +927,37 @@ func (s Sequencer) SignRoot(ctx context.Context, logID int64) error {
 	}
 
 	// Hash and sign the root
-	signature, err := s.createRootSignature(ctx, newLogRoot)
-	if err != nil {
+	if err := s.signLogRoot(ctx, &newLogRoot); err != nil {
 		glog.Warningf("%v: signer failed to sign root: %v", logID, err)
-		return err
+		return trillian.SignedLogRoot{}, err
 	}
-	newLogRoot.Signature = signature
 
 	// Store the new root and we're done
 	if err := tx.StoreSignedLogRoot(newLogRoot); err != nil {
 		glog.Warningf("%v: signer failed to write updated root: %v", logID, err)
-		return err
+		return trillian.SignedLogRoot{}, err
 	}
 	glog.V(2).Infof("%v: new signed root, size %v, tree-revision %v", logID, newLogRoot.TreeSize, newLogRoot.TreeRevision)
 
+	if err := s.commit(logID, tx); err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+	return newLogRoot, nil
+}
+
+// advanceCursor moves the dequeue cursor, if there is one, to token once the batch that
+// dequeued up to it has committed.
+func (s Sequencer) advanceCursor(token []byte) {
+	if s.cursor != nil && s.ordering != UnorderedOrdering && !s.dryRun {
+		s.cursor.set(token)
+	}
+}
+
+// commit commits tx, or rolls it back if the sequencer is in dry-run mode.
+func (s Sequencer) commit(logID int64, tx storage.LogTreeTX) error {
+	if s.dryRun {
+		glog.Infof("%v: dry run, rolling back", logID)
+		return tx.Rollback()
+	}
 	return tx.Commit()
 }
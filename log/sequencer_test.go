@@ -15,18 +15,34 @@
 package log
 
 import (
+	"bytes"
 	"context"
 	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto"
 	"github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+	"github.com/google/trillian/storage/memory"
+	storageto "github.com/google/trillian/storage/testonly"
 	"github.com/google/trillian/testonly"
 	"github.com/google/trillian/util"
 )
@@ -35,10 +51,11 @@ var (
 	// These can be shared between tests as they're never modified
 	testLeaf16Data = []byte("testdataforleaf")
 	testLeaf16     = &trillian.LogLeaf{
-		MerkleLeafHash: testonly.Hasher.HashLeaf(testLeaf16Data),
-		LeafValue:      testLeaf16Data,
-		ExtraData:      nil,
-		LeafIndex:      16,
+		MerkleLeafHash:          testonly.Hasher.HashLeaf(testLeaf16Data),
+		LeafValue:               testLeaf16Data,
+		ExtraData:               nil,
+		LeafIndex:               16,
+		IntegrateTimestampNanos: fakeTimeForTest.UnixNano(),
 	}
 )
 
@@ -66,6 +83,7 @@ var expectedSignedRoot = trillian.SignedLogRoot{
 		HashAlgorithm:      sigpb.DigitallySigned_SHA256,
 		Signature:          []byte("signed"),
 	},
+	KeyId: testKeyID,
 }
 
 var expectedSignedRoot16 = trillian.SignedLogRoot{
@@ -80,6 +98,7 @@ var expectedSignedRoot16 = trillian.SignedLogRoot{
 		HashAlgorithm:      sigpb.DigitallySigned_SHA256,
 		Signature:          []byte("signed"),
 	},
+	KeyId: testKeyID,
 }
 
 // expectedSignedRoot0 is a root for an empty tree
@@ -94,6 +113,22 @@ var expectedSignedRoot0 = trillian.SignedLogRoot{
 		HashAlgorithm:      sigpb.DigitallySigned_SHA256,
 		Signature:          []byte("signed"),
 	},
+	KeyId: testKeyID,
+}
+
+// testPublicKey is the public key of the mock key managers, and testKeyID is its ID.
+var testPublicKey, testKeyID = demoPublicKey()
+
+func demoPublicKey() (gocrypto.PublicKey, []byte) {
+	pub, err := crypto.PublicKeyFromPEM(testonly.DemoPublicKey)
+	if err != nil {
+		panic(fmt.Sprintf("PublicKeyFromPEM(): %v", err))
+	}
+	keyID, err := crypto.KeyID(pub)
+	if err != nil {
+		panic(fmt.Sprintf("KeyID(): %v", err))
+	}
+	return pub, keyID
 }
 
 // Any tests relying on time should use this fixed value
@@ -158,6 +193,61 @@ func getLeaf42() *trillian.LogLeaf {
 	}
 }
 
+// newTestKeyManager returns a key manager for a new ECDSA P-256 key.
+func newTestKeyManager(t testing.TB) crypto.PrivateKeyManager {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	keyManager, err := crypto.NewFromPrivateKey(key)
+	if err != nil {
+		t.Fatalf("NewFromPrivateKey()=(_,%v), want (_,nil)", err)
+	}
+	return keyManager
+}
+
+// newMemoryLog creates a log in a new memory storage.
+func newMemoryLog(t testing.TB) (*memory.LogStorage, int64) {
+	ls := memory.NewLogStorage()
+	tree, err := ls.CreateLog(storageto.LogTree)
+	if err != nil {
+		t.Fatalf("CreateLog()=(_,%v), want (_,nil)", err)
+	}
+	return ls, tree.TreeId
+}
+
+// newMemoryLogForTest creates a log in a new memory storage, along with a context for it
+// and a key manager to sign its roots with.
+func newMemoryLogForTest(t testing.TB) (*memory.LogStorage, int64, context.Context, crypto.PrivateKeyManager) {
+	ls, logID := newMemoryLog(t)
+	return ls, logID, util.NewLogContext(context.Background(), logID), newTestKeyManager(t)
+}
+
+// testLeaves returns n leaves, with the values "leaf <first>" onwards.
+func testLeaves(first, n int) []*trillian.LogLeaf {
+	leaves := make([]*trillian.LogLeaf, 0, n)
+	for i := first; i < first+n; i++ {
+		value := []byte(fmt.Sprintf("leaf %d", i))
+		identityHash := sha256.Sum256(value)
+		leaves = append(leaves, &trillian.LogLeaf{LeafIdentityHash: identityHash[:], MerkleLeafHash: testonly.Hasher.HashLeaf(value), LeafValue: value})
+	}
+	return leaves
+}
+
+// queueLeaves queues leaves in a log in a single transaction.
+func queueLeaves(t testing.TB, ls storage.LogStorage, ctx context.Context, logID int64, leaves []*trillian.LogLeaf, queueTime time.Time) {
+	tx, err := ls.BeginForTree(ctx, logID)
+	if err != nil {
+		t.Fatalf("BeginForTree()=(_,%v), want (_,nil)", err)
+	}
+	if err := tx.QueueLeaves(leaves, queueTime); err != nil {
+		t.Fatalf("QueueLeaves()=%v, want nil", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit()=%v, want nil", err)
+	}
+}
+
 func fakeTime() time.Time {
 	fakeTimeForTest, err := time.Parse(time.RFC3339, fakeTimeStr)
 
@@ -189,6 +279,9 @@ func createTestContext(ctrl *gomock.Controller, params testParameters) (testCont
 			mockTx.EXPECT().Commit().AnyTimes().Return(params.commitError)
 		}
 	}
+	if params.shouldRollback {
+		mockTx.EXPECT().Rollback().Return(nil)
+	}
 	// Close is always called, regardless of explicit commits
 	mockTx.EXPECT().Close().AnyTimes().Return(nil)
 
@@ -200,6 +293,8 @@ func createTestContext(ctrl *gomock.Controller, params testParameters) (testCont
 		}
 	}
 
+	mockTx.EXPECT().QueuedLeafCount().AnyTimes().Return(int64(0), nil)
+
 	if params.latestSignedRoot != nil {
 		mockTx.EXPECT().LatestSignedLogRoot().AnyTimes().Return(*params.latestSignedRoot, params.latestSignedRootError)
 	}
@@ -222,6 +317,7 @@ func createTestContext(ctrl *gomock.Controller, params testParameters) (testCont
 	}
 
 	mockKeyManager := crypto.NewMockPrivateKeyManager(ctrl)
+	mockKeyManager.EXPECT().Public().AnyTimes().Return(testPublicKey)
 
 	if params.setupSigner {
 		mockKeyManager.EXPECT().Sign(gomock.Any(), params.dataToSign, gocrypto.SHA256).AnyTimes().Return(params.signingResult, params.signingError)
@@ -248,9 +344,9 @@ func TestBeginTXFails(t *testing.T) {
 	}
 	c, ctx := createTestContext(ctrl, params)
 
-	leaves, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
-	if leaves != 0 {
-		t.Fatalf("Unexpectedly sequenced %d leaves on error", leaves)
+	res, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
+	if res.LeafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves on error", res.LeafCount)
 	}
 	testonly.EnsureErrorContains(t, err, "TX")
 }
@@ -269,14 +365,20 @@ func TestSequenceWithNothingQueued(t *testing.T) {
 	}
 	c, ctx := createTestContext(ctrl, params)
 
-	leaves, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
-	if leaves != 0 {
-		t.Fatalf("Unexpectedly sequenced %d leaves on error", leaves)
+	res, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
+	if res.LeafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves on error", res.LeafCount)
 	}
 
 	if err != nil {
 		t.Error("Expected nil return with no work pending in queue")
 	}
+	if got, want := res.TreeSize, testRoot16.TreeSize; got != want {
+		t.Errorf("SequenceBatch().TreeSize=%d, want %d", got, want)
+	}
+	if got, want := res.RootHash, testRoot16.RootHash; !bytes.Equal(got, want) {
+		t.Errorf("SequenceBatch().RootHash=%x, want unchanged %x", got, want)
+	}
 }
 
 // Tests that the guard interval is being passed to storage correctly. Actual operation of the
@@ -299,9 +401,9 @@ func TestGuardWindowPassthrough(t *testing.T) {
 	c, ctx := createTestContext(ctrl, params)
 	c.sequencer.SetGuardWindow(guardInterval)
 
-	leaves, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
-	if leaves != 0 {
-		t.Fatalf("Expected no leaves sequenced when in guard interval but got: %d", leaves)
+	res, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
+	if res.LeafCount != 0 {
+		t.Fatalf("Expected no leaves sequenced when in guard interval but got: %d", res.LeafCount)
 	}
 
 	if err != nil {
@@ -320,10 +422,10 @@ func TestDequeueError(t *testing.T) {
 	}
 	c, ctx := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
+	res, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
 	testonly.EnsureErrorContains(t, err, "dequeue")
-	if leafCount != 0 {
-		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
+	if res.LeafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves on error", res.LeafCount)
 	}
 }
 
@@ -341,9 +443,9 @@ func TestLatestRootError(t *testing.T) {
 	}
 	c, ctx := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
-	if leafCount != 0 {
-		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
+	res, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
+	if res.LeafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves on error", res.LeafCount)
 	}
 	testonly.EnsureErrorContains(t, err, "root")
 }
@@ -365,9 +467,9 @@ func TestUpdateSequencedLeavesError(t *testing.T) {
 	}
 	c, ctx := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
-	if leafCount != 0 {
-		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
+	res, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
+	if res.LeafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves on error", res.LeafCount)
 	}
 	testonly.EnsureErrorContains(t, err, "unsequenced")
 }
@@ -390,9 +492,9 @@ func TestSetMerkleNodesError(t *testing.T) {
 	}
 	c, ctx := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
-	if leafCount != 0 {
-		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
+	res, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
+	if res.LeafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves on error", res.LeafCount)
 	}
 	testonly.EnsureErrorContains(t, err, "setmerklenodes")
 }
@@ -414,14 +516,14 @@ func TestStoreSignedRootError(t *testing.T) {
 		storeSignedRoot:      nil,
 		storeSignedRootError: errors.New("storesignedroot"),
 		setupSigner:          true,
-		dataToSign:           []byte{118, 113, 60, 123, 201, 107, 151, 27, 190, 53, 148, 77, 139, 138, 128, 71, 231, 103, 131, 160, 23, 10, 65, 81, 64, 173, 1, 151, 36, 239, 22, 3},
+		dataToSign:           []byte{44, 169, 138, 145, 110, 113, 26, 235, 244, 27, 16, 181, 51, 44, 176, 77, 147, 46, 215, 191, 92, 197, 8, 55, 232, 146, 103, 216, 7, 191, 244, 224},
 		signingResult:        []byte("signed"),
 	}
 	c, ctx := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
-	if leafCount != 0 {
-		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
+	res, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
+	if res.LeafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves on error", res.LeafCount)
 	}
 	testonly.EnsureErrorContains(t, err, "storesignedroot")
 }
@@ -442,14 +544,14 @@ func TestStoreSignedRootSignerFails(t *testing.T) {
 		merkleNodesSet:   &updatedNodes,
 		storeSignedRoot:  nil,
 		setupSigner:      true,
-		dataToSign:       []byte{118, 113, 60, 123, 201, 107, 151, 27, 190, 53, 148, 77, 139, 138, 128, 71, 231, 103, 131, 160, 23, 10, 65, 81, 64, 173, 1, 151, 36, 239, 22, 3},
+		dataToSign:       []byte{44, 169, 138, 145, 110, 113, 26, 235, 244, 27, 16, 181, 51, 44, 176, 77, 147, 46, 215, 191, 92, 197, 8, 55, 232, 146, 103, 216, 7, 191, 244, 224},
 		signingError:     errors.New("signerfailed"),
 	}
 	c, ctx := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
-	if leafCount != 0 {
-		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
+	res, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
+	if res.LeafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves on error", res.LeafCount)
 	}
 	testonly.EnsureErrorContains(t, err, "signerfailed")
 }
@@ -474,14 +576,14 @@ func TestCommitFails(t *testing.T) {
 		merkleNodesSet:   &updatedNodes,
 		storeSignedRoot:  nil,
 		setupSigner:      true,
-		dataToSign:       []byte{118, 113, 60, 123, 201, 107, 151, 27, 190, 53, 148, 77, 139, 138, 128, 71, 231, 103, 131, 160, 23, 10, 65, 81, 64, 173, 1, 151, 36, 239, 22, 3},
+		dataToSign:       []byte{44, 169, 138, 145, 110, 113, 26, 235, 244, 27, 16, 181, 51, 44, 176, 77, 147, 46, 215, 191, 92, 197, 8, 55, 232, 146, 103, 216, 7, 191, 244, 224},
 		signingResult:    []byte("signed"),
 	}
 	c, ctx := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
-	if leafCount != 0 {
-		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
+	res, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
+	if res.LeafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves on error", res.LeafCount)
 	}
 	testonly.EnsureErrorContains(t, err, "commit")
 }
@@ -505,147 +607,1797 @@ func TestSequenceBatch(t *testing.T) {
 		merkleNodesSet:   &updatedNodes,
 		storeSignedRoot:  &expectedSignedRoot,
 		setupSigner:      true,
-		dataToSign:       []byte{118, 113, 60, 123, 201, 107, 151, 27, 190, 53, 148, 77, 139, 138, 128, 71, 231, 103, 131, 160, 23, 10, 65, 81, 64, 173, 1, 151, 36, 239, 22, 3},
+		dataToSign:       []byte{44, 169, 138, 145, 110, 113, 26, 235, 244, 27, 16, 181, 51, 44, 176, 77, 147, 46, 215, 191, 92, 197, 8, 55, 232, 146, 103, 216, 7, 191, 244, 224},
 		signingResult:    []byte("signed"),
 	}
 	c, ctx := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
+	res, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
 	if err != nil {
 		t.Fatalf("Expected sequencing to succeed, but got err: %v", err)
 	}
-	if got, want := leafCount, 1; got != want {
+	if got, want := res.LeafCount, 1; got != want {
 		t.Fatalf("Sequenced %d leaf, expected %d", got, want)
 	}
+	if got, want := res.TreeSize, expectedSignedRoot.TreeSize; got != want {
+		t.Errorf("SequenceBatch().TreeSize=%d, want %d", got, want)
+	}
+	if got, want := res.RootHash, expectedSignedRoot.RootHash; !bytes.Equal(got, want) {
+		t.Errorf("SequenceBatch().RootHash=%x, want %x", got, want)
+	}
+	if bytes.Equal(res.RootHash, testRoot16.RootHash) {
+		t.Errorf("SequenceBatch().RootHash=%x, unchanged after adding a leaf", res.RootHash)
+	}
+	if got, want := res.Signature, expectedSignedRoot.Signature; !proto.Equal(got, want) {
+		t.Errorf("SequenceBatch().Signature=%v, want %v", got, want)
+	}
 }
 
-func TestSignBeginTxFails(t *testing.T) {
+func TestSequenceBatchDryRun(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	params := testParameters{logID: 154035, beginFails: true}
+	// The batch is sequenced and signed as normal but rolled back instead of committed.
+	// Commit is not expected, so the stored tree size can't change.
+	leaves := []*trillian.LogLeaf{getLeaf42()}
+	updatedLeaves := []*trillian.LogLeaf{testLeaf16}
+	params := testParameters{
+		logID:            154035,
+		writeRevision:    testRoot16.TreeRevision + 1,
+		dequeueLimit:     1,
+		shouldRollback:   true,
+		dequeuedLeaves:   leaves,
+		latestSignedRoot: &testRoot16,
+		updatedLeaves:    &updatedLeaves,
+		merkleNodesSet:   &updatedNodes,
+		storeSignedRoot:  &expectedSignedRoot,
+		setupSigner:      true,
+		dataToSign:       []byte{44, 169, 138, 145, 110, 113, 26, 235, 244, 27, 16, 181, 51, 44, 176, 77, 147, 46, 215, 191, 92, 197, 8, 55, 232, 146, 103, 216, 7, 191, 244, 224},
+		signingResult:    []byte("signed"),
+	}
 	c, ctx := createTestContext(ctrl, params)
+	c.sequencer.SetDryRun(true)
 
-	err := c.sequencer.SignRoot(ctx, params.logID)
-	testonly.EnsureErrorContains(t, err, "TX")
+	res, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
+	if err != nil {
+		t.Fatalf("SequenceBatch()=(_,%v), want (_,nil)", err)
+	}
+	if got, want := res.LeafCount, 1; got != want {
+		t.Errorf("SequenceBatch().LeafCount=%d, want %d", got, want)
+	}
+	if got, want := res.TreeSize, expectedSignedRoot.TreeSize; got != want {
+		t.Errorf("SequenceBatch().TreeSize=%d, want %d", got, want)
+	}
 }
 
-func TestSignLatestRootFails(t *testing.T) {
+func TestSequenceBatchDeduplicate(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
+	// The same leaf is queued twice, but only the first is integrated.
+	leaves := []*trillian.LogLeaf{getLeaf42(), getLeaf42()}
+	updatedLeaves := []*trillian.LogLeaf{testLeaf16}
 	params := testParameters{
-		logID:                 154035,
-		writeRevision:         testRoot16.TreeRevision + 1,
-		dequeueLimit:          1,
-		latestSignedRoot:      &testRoot16,
-		latestSignedRootError: errors.New("root"),
+		logID:            154035,
+		writeRevision:    testRoot16.TreeRevision + 1,
+		dequeueLimit:     2,
+		shouldCommit:     true,
+		dequeuedLeaves:   leaves,
+		latestSignedRoot: &testRoot16,
+		updatedLeaves:    &updatedLeaves,
+		merkleNodesSet:   &updatedNodes,
+		storeSignedRoot:  &expectedSignedRoot,
+		setupSigner:      true,
+		dataToSign:       []byte{44, 169, 138, 145, 110, 113, 26, 235, 244, 27, 16, 181, 51, 44, 176, 77, 147, 46, 215, 191, 92, 197, 8, 55, 232, 146, 103, 216, 7, 191, 244, 224},
+		signingResult:    []byte("signed"),
 	}
 	c, ctx := createTestContext(ctrl, params)
+	c.mockTx.EXPECT().GetLeavesByIdentityHash(gomock.Any()).Return(nil, nil)
+	c.sequencer.SetDeduplicateLeaves(true)
 
-	err := c.sequencer.SignRoot(ctx, params.logID)
-	testonly.EnsureErrorContains(t, err, "root")
+	res, err := c.sequencer.SequenceBatch(ctx, params.logID, 2)
+	if err != nil {
+		t.Fatalf("SequenceBatch()=(_,%v), want (_,nil)", err)
+	}
+	if got, want := res.LeafCount, 1; got != want {
+		t.Errorf("SequenceBatch().LeafCount=%d, want %d", got, want)
+	}
+	if got, want := res.TreeSize, testRoot16.TreeSize+1; got != want {
+		t.Errorf("SequenceBatch().TreeSize=%d, want %d", got, want)
+	}
+	if got, want := len(res.Duplicates), 1; got != want {
+		t.Fatalf("SequenceBatch() returned %d duplicates, want %d", got, want)
+	}
+	if got, want := res.Duplicates[0].LeafIndex, testRoot16.TreeSize; got != want {
+		t.Errorf("SequenceBatch().Duplicates[0].LeafIndex=%d, want %d", got, want)
+	}
 }
 
-func TestSignRootSignerFails(t *testing.T) {
+func TestSequenceBatchSkipInvalidLeaves(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
+	// Only the first leaf is valid: the second is too big for the validator and the
+	// third has a truncated hash. The result must be the same as if only the first
+	// had been queued.
+	oversize := &trillian.LogLeaf{
+		LeafIdentityHash: []byte("oversize"),
+		LeafValue:        make([]byte, 1000),
+		MerkleLeafHash:   testonly.Hasher.HashLeaf(make([]byte, 1000)),
+	}
+	truncated := &trillian.LogLeaf{
+		LeafIdentityHash: []byte("truncated"),
+		LeafValue:        []byte("short"),
+		MerkleLeafHash:   []byte("short"),
+	}
+	leaves := []*trillian.LogLeaf{getLeaf42(), oversize, truncated}
+	updatedLeaves := []*trillian.LogLeaf{testLeaf16}
 	params := testParameters{
 		logID:            154035,
 		writeRevision:    testRoot16.TreeRevision + 1,
-		dequeueLimit:     1,
+		dequeueLimit:     3,
+		shouldCommit:     true,
+		dequeuedLeaves:   leaves,
 		latestSignedRoot: &testRoot16,
-		storeSignedRoot:  nil,
+		updatedLeaves:    &updatedLeaves,
+		merkleNodesSet:   &updatedNodes,
+		storeSignedRoot:  &expectedSignedRoot,
 		setupSigner:      true,
-		dataToSign:       []byte{0x95, 0x46, 0xdc, 0x25, 0xfb, 0x74, 0x41, 0x4b, 0x50, 0x2e, 0xb0, 0x93, 0x99, 0xbb, 0x5e, 0xf6, 0x57, 0x58, 0xb9, 0x7a, 0x3a, 0x8f, 0xae, 0x35, 0xe1, 0xf6, 0xcd, 0x6c, 0x2a, 0xe6, 0x27, 0xbe},
-		signingError:     errors.New("signerfailed"),
+		dataToSign:       []byte{44, 169, 138, 145, 110, 113, 26, 235, 244, 27, 16, 181, 51, 44, 176, 77, 147, 46, 215, 191, 92, 197, 8, 55, 232, 146, 103, 216, 7, 191, 244, 224},
+		signingResult:    []byte("signed"),
 	}
 	c, ctx := createTestContext(ctrl, params)
-
-	err := c.sequencer.SignRoot(ctx, params.logID)
-	testonly.EnsureErrorContains(t, err, "signer")
+	c.sequencer.SetLeafValidator(func(leaf *trillian.LogLeaf) error {
+		if len(leaf.LeafValue) > 100 {
+			return fmt.Errorf("leaf value is %d bytes, want at most 100", len(leaf.LeafValue))
+		}
+		return nil
+	})
+	c.sequencer.SetSkipInvalidLeaves(true)
+	c.mockTx.EXPECT().DeadLetterLeaves([]storage.DeadLetter{
+		{LeafIdentityHash: oversize.LeafIdentityHash, MerkleLeafHash: oversize.MerkleLeafHash, Reason: "leaf value is 1000 bytes, want at most 100", TimestampNanos: fakeTimeForTest.UnixNano()},
+		{LeafIdentityHash: truncated.LeafIdentityHash, MerkleLeafHash: truncated.MerkleLeafHash, Reason: "Merkle leaf hash is 5 bytes, want 32", TimestampNanos: fakeTimeForTest.UnixNano()},
+	}).Return(nil)
+
+	res, err := c.sequencer.SequenceBatch(ctx, params.logID, 3)
+	if err != nil {
+		t.Fatalf("SequenceBatch()=(_,%v), want (_,nil)", err)
+	}
+	if got, want := res.LeafCount, 1; got != want {
+		t.Errorf("SequenceBatch().LeafCount=%d, want %d", got, want)
+	}
+	if got, want := res.TreeSize, expectedSignedRoot.TreeSize; got != want {
+		t.Errorf("SequenceBatch().TreeSize=%d, want %d", got, want)
+	}
+	if got, want := res.Skipped, []*trillian.LogLeaf{oversize, truncated}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SequenceBatch().Skipped=%v, want %v", got, want)
+	}
 }
 
-func TestSignRootStoreSignedRootFails(t *testing.T) {
+func TestSequenceBatchMaxLeafSize(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
+	// Oversize leaves are always left out of the batch, even when invalid leaves
+	// would otherwise fail it.
+	oversize := &trillian.LogLeaf{
+		LeafIdentityHash: []byte("oversize"),
+		LeafValue:        make([]byte, 1000),
+		MerkleLeafHash:   testonly.Hasher.HashLeaf(make([]byte, 1000)),
+	}
+	leaves := []*trillian.LogLeaf{getLeaf42(), oversize}
+	updatedLeaves := []*trillian.LogLeaf{testLeaf16}
 	params := testParameters{
-		logID:                154035,
-		writeRevision:        testRoot16.TreeRevision + 1,
-		latestSignedRoot:     &testRoot16,
-		storeSignedRoot:      nil,
-		storeSignedRootError: errors.New("storesignedroot"),
-		setupSigner:          true,
-		dataToSign:           []byte{0x95, 0x46, 0xdc, 0x25, 0xfb, 0x74, 0x41, 0x4b, 0x50, 0x2e, 0xb0, 0x93, 0x99, 0xbb, 0x5e, 0xf6, 0x57, 0x58, 0xb9, 0x7a, 0x3a, 0x8f, 0xae, 0x35, 0xe1, 0xf6, 0xcd, 0x6c, 0x2a, 0xe6, 0x27, 0xbe},
-		signingResult:        []byte("signed"),
+		logID:            154035,
+		writeRevision:    testRoot16.TreeRevision + 1,
+		dequeueLimit:     2,
+		shouldCommit:     true,
+		dequeuedLeaves:   leaves,
+		latestSignedRoot: &testRoot16,
+		updatedLeaves:    &updatedLeaves,
+		merkleNodesSet:   &updatedNodes,
+		storeSignedRoot:  &expectedSignedRoot,
+		setupSigner:      true,
+		dataToSign:       []byte{44, 169, 138, 145, 110, 113, 26, 235, 244, 27, 16, 181, 51, 44, 176, 77, 147, 46, 215, 191, 92, 197, 8, 55, 232, 146, 103, 216, 7, 191, 244, 224},
+		signingResult:    []byte("signed"),
 	}
 	c, ctx := createTestContext(ctrl, params)
+	c.sequencer.SetMaxLeafSize(100)
+	c.mockTx.EXPECT().DeadLetterLeaves([]storage.DeadLetter{
+		{LeafIdentityHash: oversize.LeafIdentityHash, MerkleLeafHash: oversize.MerkleLeafHash, Reason: "value is 1000 bytes, want at most 100", TimestampNanos: fakeTimeForTest.UnixNano()},
+	}).Return(nil)
 
-	err := c.sequencer.SignRoot(ctx, params.logID)
-	testonly.EnsureErrorContains(t, err, "storesignedroot")
+	res, err := c.sequencer.SequenceBatch(ctx, params.logID, 2)
+	if err != nil {
+		t.Fatalf("SequenceBatch()=(_,%v), want (_,nil)", err)
+	}
+	if got, want := res.LeafCount, 1; got != want {
+		t.Errorf("SequenceBatch().LeafCount=%d, want %d", got, want)
+	}
+	if got, want := res.Skipped, []*trillian.LogLeaf{oversize}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SequenceBatch().Skipped=%v, want %v", got, want)
+	}
 }
 
-func TestSignRootCommitFails(t *testing.T) {
+func TestSequenceBatchInvalidLeafFailsBatch(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
+	// Without the skip policy an invalid leaf fails the batch before anything is written.
+	leaves := []*trillian.LogLeaf{getLeaf42(), {LeafIdentityHash: []byte("truncated"), MerkleLeafHash: []byte("short")}}
 	params := testParameters{
-		logID:            154035,
-		writeRevision:    testRoot16.TreeRevision + 1,
-		shouldCommit:     true,
-		commitFails:      true,
-		commitError:      errors.New("commit"),
-		latestSignedRoot: &testRoot16,
-		storeSignedRoot:  nil,
-		setupSigner:      true,
-		dataToSign:       []byte{0x95, 0x46, 0xdc, 0x25, 0xfb, 0x74, 0x41, 0x4b, 0x50, 0x2e, 0xb0, 0x93, 0x99, 0xbb, 0x5e, 0xf6, 0x57, 0x58, 0xb9, 0x7a, 0x3a, 0x8f, 0xae, 0x35, 0xe1, 0xf6, 0xcd, 0x6c, 0x2a, 0xe6, 0x27, 0xbe},
-		signingResult:    []byte("signed"),
+		logID:               154035,
+		writeRevision:       testRoot16.TreeRevision + 1,
+		dequeueLimit:        2,
+		dequeuedLeaves:      leaves,
+		latestSignedRoot:    &testRoot16,
+		skipStoreSignedRoot: true,
 	}
 	c, ctx := createTestContext(ctrl, params)
+	c.sequencer.SetLeafValidator(func(*trillian.LogLeaf) error { return nil })
 
-	err := c.sequencer.SignRoot(ctx, params.logID)
-	testonly.EnsureErrorContains(t, err, "commit")
+	if _, err := c.sequencer.SequenceBatch(ctx, params.logID, 2); err == nil {
+		t.Error("SequenceBatch()=(_,nil), want (_,error)")
+	}
 }
 
-func TestSignRoot(t *testing.T) {
+func TestSequenceBatchDeduplicateSequencedLeaf(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
+	// The only queued leaf is already in the tree so nothing is integrated.
 	params := testParameters{
-		logID:            154035,
-		writeRevision:    testRoot16.TreeRevision + 1,
-		latestSignedRoot: &testRoot16,
-		storeSignedRoot:  &expectedSignedRoot16,
-		setupSigner:      true,
-		dataToSign:       []byte{0x95, 0x46, 0xdc, 0x25, 0xfb, 0x74, 0x41, 0x4b, 0x50, 0x2e, 0xb0, 0x93, 0x99, 0xbb, 0x5e, 0xf6, 0x57, 0x58, 0xb9, 0x7a, 0x3a, 0x8f, 0xae, 0x35, 0xe1, 0xf6, 0xcd, 0x6c, 0x2a, 0xe6, 0x27, 0xbe},
-		signingResult:    []byte("signed"), shouldCommit: true,
+		logID:               154035,
+		dequeueLimit:        1,
+		shouldCommit:        true,
+		dequeuedLeaves:      []*trillian.LogLeaf{getLeaf42()},
+		latestSignedRoot:    &testRoot16,
+		skipStoreSignedRoot: true,
 	}
 	c, ctx := createTestContext(ctrl, params)
+	existing := getLeaf42()
+	existing.LeafIndex = 3
+	c.mockTx.EXPECT().GetLeavesByIdentityHash([][]byte{existing.LeafIdentityHash}).Return([]*trillian.LogLeaf{existing}, nil)
+	c.sequencer.SetDeduplicateLeaves(true)
 
-	if err := c.sequencer.SignRoot(ctx, params.logID); err != nil {
-		t.Fatalf("Expected signing to succeed, but got err: %v", err)
+	res, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
+	if err != nil {
+		t.Fatalf("SequenceBatch()=(_,%v), want (_,nil)", err)
+	}
+	if res.LeafCount != 0 {
+		t.Errorf("SequenceBatch().LeafCount=%d, want 0", res.LeafCount)
+	}
+	if got, want := res.TreeSize, testRoot16.TreeSize; got != want {
+		t.Errorf("SequenceBatch().TreeSize=%d, want %d", got, want)
+	}
+	if len(res.Duplicates) != 1 || res.Duplicates[0].LeafIndex != existing.LeafIndex {
+		t.Errorf("SequenceBatch().Duplicates=%v, want one leaf with index %d", res.Duplicates, existing.LeafIndex)
 	}
 }
 
-func TestSignRootNoExistingRoot(t *testing.T) {
+func TestSequenceBatchDeduplicateByIdentityHash(t *testing.T) {
+	identityHash := func(s string) []byte {
+		h := sha256.Sum256([]byte(s))
+		return h[:]
+	}
+	merkleHash, otherMerkleHash := testonly.Hasher.HashLeaf([]byte("value")), testonly.Hasher.HashLeaf([]byte("other value"))
+	// sequenced is in the tree already, at index 3.
+	sequenced := &trillian.LogLeaf{LeafIdentityHash: identityHash("A"), MerkleLeafHash: merkleHash, LeafIndex: 3}
+
+	for _, test := range []struct {
+		desc   string
+		queued []*trillian.LogLeaf
+		// existing is returned by the lookup of the identity hashes of the queued leaves.
+		existing []*trillian.LogLeaf
+		// wantCount is the number of leaves integrated.
+		wantCount int
+		// wantDuplicates holds the index given to each duplicate.
+		wantDuplicates []int64
+	}{
+		{
+			desc: "same merkle hash in batch",
+			queued: []*trillian.LogLeaf{
+				{LeafIdentityHash: identityHash("A"), MerkleLeafHash: merkleHash},
+				{LeafIdentityHash: identityHash("B"), MerkleLeafHash: merkleHash},
+			},
+			wantCount: 2,
+		},
+		{
+			desc: "same identity hash in batch",
+			queued: []*trillian.LogLeaf{
+				{LeafIdentityHash: identityHash("A"), MerkleLeafHash: merkleHash},
+				{LeafIdentityHash: identityHash("A"), MerkleLeafHash: otherMerkleHash},
+			},
+			wantCount:      1,
+			wantDuplicates: []int64{testRoot16.TreeSize},
+		},
+		{
+			desc:      "same merkle hash as sequenced",
+			queued:    []*trillian.LogLeaf{{LeafIdentityHash: identityHash("B"), MerkleLeafHash: merkleHash}},
+			wantCount: 1,
+		},
+		{
+			desc:           "same identity hash as sequenced",
+			queued:         []*trillian.LogLeaf{{LeafIdentityHash: identityHash("A"), MerkleLeafHash: otherMerkleHash}},
+			existing:       []*trillian.LogLeaf{sequenced},
+			wantDuplicates: []int64{sequenced.LeafIndex},
+		},
+	} {
+		ctrl := gomock.NewController(t)
+
+		var lookup [][]byte
+		for _, leaf := range test.queued {
+			lookup = append(lookup, leaf.LeafIdentityHash)
+		}
+		mockTx := storage.NewMockLogTreeTX(ctrl)
+		mockTx.EXPECT().DequeueLeaves(10, fakeTimeForTest).Return(test.queued, nil)
+		mockTx.EXPECT().QueuedLeafCount().Return(int64(0), nil)
+		mockTx.EXPECT().LatestSignedLogRoot().Return(testRoot16, nil)
+		mockTx.EXPECT().GetLeavesByIdentityHash(lookup).Return(test.existing, nil)
+		mockTx.EXPECT().WriteRevision().AnyTimes().Return(testRoot16.TreeRevision + 1)
+		mockTx.EXPECT().UpdateSequencedLeaves(gomock.Any()).AnyTimes().Return(nil)
+		mockTx.EXPECT().SetMerkleNodes(gomock.Any()).AnyTimes().Return(nil)
+		mockTx.EXPECT().StoreSignedLogRoot(gomock.Any()).AnyTimes().Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Close().Return(nil)
+		mockStorage := storage.NewMockLogStorage(ctrl)
+		mockStorage.EXPECT().BeginForTree(gomock.Any(), int64(154035)).Return(mockTx, nil)
+		mockKeyManager := crypto.NewMockPrivateKeyManager(ctrl)
+		mockKeyManager.EXPECT().Public().AnyTimes().Return(testPublicKey)
+		mockKeyManager.EXPECT().Sign(gomock.Any(), gomock.Any(), gocrypto.SHA256).AnyTimes().Return([]byte("signed"), nil)
+		mockKeyManager.EXPECT().SignatureAlgorithm().AnyTimes().Return(sigpb.DigitallySigned_ECDSA)
+
+		sequencer := NewSequencer(testonly.Hasher, util.FakeTimeSource{FakeTime: fakeTimeForTest}, mockStorage, mockKeyManager)
+		sequencer.SetDeduplicateLeaves(true)
+		res, err := sequencer.SequenceBatch(util.NewLogContext(context.Background(), 154035), 154035, 10)
+		if err != nil {
+			t.Errorf("%v: SequenceBatch()=(_,%v), want (_,nil)", test.desc, err)
+			ctrl.Finish()
+			continue
+		}
+		if got, want := res.LeafCount, test.wantCount; got != want {
+			t.Errorf("%v: SequenceBatch().LeafCount=%d, want %d", test.desc, got, want)
+		}
+		var gotDuplicates []int64
+		for _, leaf := range res.Duplicates {
+			gotDuplicates = append(gotDuplicates, leaf.LeafIndex)
+		}
+		if !reflect.DeepEqual(gotDuplicates, test.wantDuplicates) {
+			t.Errorf("%v: SequenceBatch() gave duplicates indices %v, want %v", test.desc, gotDuplicates, test.wantDuplicates)
+		}
+		ctrl.Finish()
+	}
+}
+
+func TestSequenceBatchLeafCount(t *testing.T) {
+	for _, numLeaves := range []int{0, 1, 3, 10} {
+		ctrl := gomock.NewController(t)
+
+		var leaves []*trillian.LogLeaf
+		for i := 0; i < numLeaves; i++ {
+			data := []byte(fmt.Sprintf("leaf %d", i))
+			leaves = append(leaves, &trillian.LogLeaf{MerkleLeafHash: testonly.Hasher.HashLeaf(data), LeafValue: data})
+		}
+
+		mockTx := storage.NewMockLogTreeTX(ctrl)
+		mockTx.EXPECT().DequeueLeaves(10, fakeTimeForTest).Return(leaves, nil)
+		mockTx.EXPECT().QueuedLeafCount().Return(int64(0), nil)
+		mockTx.EXPECT().LatestSignedLogRoot().Return(testRoot16, nil)
+		mockTx.EXPECT().WriteRevision().AnyTimes().Return(testRoot16.TreeRevision + 1)
+		mockTx.EXPECT().UpdateSequencedLeaves(gomock.Any()).AnyTimes().Return(nil)
+		mockTx.EXPECT().SetMerkleNodes(gomock.Any()).AnyTimes().Return(nil)
+		mockTx.EXPECT().StoreSignedLogRoot(gomock.Any()).AnyTimes().Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Close().Return(nil)
+		mockStorage := storage.NewMockLogStorage(ctrl)
+		mockStorage.EXPECT().BeginForTree(gomock.Any(), int64(154035)).Return(mockTx, nil)
+		mockKeyManager := crypto.NewMockPrivateKeyManager(ctrl)
+		mockKeyManager.EXPECT().Public().AnyTimes().Return(testPublicKey)
+		mockKeyManager.EXPECT().Sign(gomock.Any(), gomock.Any(), gocrypto.SHA256).AnyTimes().Return([]byte("signed"), nil)
+		mockKeyManager.EXPECT().SignatureAlgorithm().AnyTimes().Return(sigpb.DigitallySigned_ECDSA)
+
+		sequencer := NewSequencer(testonly.Hasher, util.FakeTimeSource{FakeTime: fakeTimeForTest}, mockStorage, mockKeyManager)
+		res, err := sequencer.SequenceBatch(util.NewLogContext(context.Background(), 154035), 154035, 10)
+		if err != nil {
+			t.Errorf("SequenceBatch() with %d leaves queued=(_,%v), want (_,nil)", numLeaves, err)
+		}
+		if got, want := res.LeafCount, numLeaves; got != want {
+			t.Errorf("SequenceBatch()=%d, want %d", got, want)
+		}
+		ctrl.Finish()
+	}
+}
+
+func TestSequenceBatchCancelled(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
+	ctx, cancel := context.WithCancel(util.NewLogContext(context.Background(), 154035))
+	defer cancel()
+
+	// The context is cancelled part way through the batch, so the transaction must be
+	// closed without being committed.
+	mockTx := storage.NewMockLogTreeTX(ctrl)
+	mockTx.EXPECT().DequeueLeaves(1, fakeTimeForTest).Return([]*trillian.LogLeaf{getLeaf42()}, nil)
+	mockTx.EXPECT().QueuedLeafCount().Return(int64(0), nil)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(testRoot16, nil)
+	mockTx.EXPECT().WriteRevision().AnyTimes().Return(testRoot16.TreeRevision + 1)
+	mockTx.EXPECT().UpdateSequencedLeaves(gomock.Any()).Return(nil)
+	mockTx.EXPECT().SetMerkleNodes(gomock.Any()).Do(func([]storage.Node) { cancel() }).Return(nil)
+	mockTx.EXPECT().StoreSignedLogRoot(gomock.Any()).Return(nil)
+	mockTx.EXPECT().Close().Return(nil)
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockStorage.EXPECT().BeginForTree(gomock.Any(), int64(154035)).Return(mockTx, nil)
+	mockKeyManager := crypto.NewMockPrivateKeyManager(ctrl)
+	mockKeyManager.EXPECT().Public().AnyTimes().Return(testPublicKey)
+	mockKeyManager.EXPECT().Sign(gomock.Any(), gomock.Any(), gocrypto.SHA256).Return([]byte("signed"), nil)
+	mockKeyManager.EXPECT().SignatureAlgorithm().AnyTimes().Return(sigpb.DigitallySigned_ECDSA)
+
+	sequencer := NewSequencer(testonly.Hasher, util.FakeTimeSource{FakeTime: fakeTimeForTest}, mockStorage, mockKeyManager)
+	res, err := sequencer.SequenceBatch(ctx, 154035, 1)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("SequenceBatch()=(_,%v), want (_,%v)", err, context.Canceled)
+	}
+	if res.LeafCount != 0 {
+		t.Errorf("SequenceBatch()=%d, want 0 leaves on cancellation", res.LeafCount)
+	}
+}
+
+func TestSequenceBatchTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Dequeueing stalls for longer than the batch may take, so the transaction must be
+	// closed without being committed.
+	mockTx := storage.NewMockLogTreeTX(ctrl)
+	mockTx.EXPECT().DequeueLeaves(1, fakeTimeForTest).Do(func(int, time.Time) { time.Sleep(50 * time.Millisecond) }).Return([]*trillian.LogLeaf{getLeaf42()}, nil)
+	mockTx.EXPECT().QueuedLeafCount().Return(int64(0), nil)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(testRoot16, nil)
+	mockTx.EXPECT().WriteRevision().AnyTimes().Return(testRoot16.TreeRevision + 1)
+	mockTx.EXPECT().UpdateSequencedLeaves(gomock.Any()).Return(nil)
+	mockTx.EXPECT().SetMerkleNodes(gomock.Any()).Return(nil)
+	mockTx.EXPECT().StoreSignedLogRoot(gomock.Any()).Return(nil)
+	mockTx.EXPECT().Close().Return(nil)
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockStorage.EXPECT().BeginForTree(gomock.Any(), int64(154035)).Return(mockTx, nil)
+	mockKeyManager := crypto.NewMockPrivateKeyManager(ctrl)
+	mockKeyManager.EXPECT().Public().AnyTimes().Return(testPublicKey)
+	mockKeyManager.EXPECT().Sign(gomock.Any(), gomock.Any(), gocrypto.SHA256).Return([]byte("signed"), nil)
+	mockKeyManager.EXPECT().SignatureAlgorithm().AnyTimes().Return(sigpb.DigitallySigned_ECDSA)
+
+	sequencer := NewSequencer(testonly.Hasher, util.FakeTimeSource{FakeTime: fakeTimeForTest}, mockStorage, mockKeyManager)
+	sequencer.SetBatchTimeout(10 * time.Millisecond)
+	res, err := sequencer.SequenceBatch(util.NewLogContext(context.Background(), 154035), 154035, 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("SequenceBatch()=(_,%v), want (_,%v)", err, context.DeadlineExceeded)
+	}
+	if !storage.IsTransient(err) {
+		t.Errorf("SequenceBatch()=(_,%v), want transient error", err)
+	}
+	if res.LeafCount != 0 {
+		t.Errorf("SequenceBatch()=%d, want 0 leaves on timeout", res.LeafCount)
+	}
+}
+
+func TestSequenceBatchRetriesTransientErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := util.NewLogContext(context.Background(), 154035)
+	transient := storage.Error{ErrType: storage.TransientError, Detail: "deadlock"}
+
+	// The first two attempts fail with a transient error and the third finds nothing queued.
+	failTx := storage.NewMockLogTreeTX(ctrl)
+	failTx.EXPECT().DequeueLeaves(1, fakeTimeForTest).Times(2).Return(nil, transient)
+	failTx.EXPECT().Close().Times(2).Return(nil)
+	okTx := storage.NewMockLogTreeTX(ctrl)
+	okTx.EXPECT().DequeueLeaves(1, fakeTimeForTest).Return([]*trillian.LogLeaf{}, nil)
+	okTx.EXPECT().QueuedLeafCount().Return(int64(0), nil)
+	okTx.EXPECT().LatestSignedLogRoot().Return(testRoot16, nil)
+	okTx.EXPECT().WriteRevision().AnyTimes().Return(testRoot16.TreeRevision + 1)
+	okTx.EXPECT().Commit().Return(nil)
+	okTx.EXPECT().Close().Return(nil)
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	gomock.InOrder(
+		mockStorage.EXPECT().BeginForTree(gomock.Any(), int64(154035)).Times(2).Return(failTx, nil),
+		mockStorage.EXPECT().BeginForTree(gomock.Any(), int64(154035)).Return(okTx, nil),
+	)
+	mockKeyManager := crypto.NewMockPrivateKeyManager(ctrl)
+	mockKeyManager.EXPECT().Public().AnyTimes().Return(testPublicKey)
+
+	sequencer := NewSequencer(testonly.Hasher, util.FakeTimeSource{FakeTime: fakeTimeForTest}, mockStorage, mockKeyManager)
+	sequencer.SetRetryPolicy(storage.RetryPolicy{MaxAttempts: 3})
+	res, err := sequencer.SequenceBatch(ctx, 154035, 1)
+	if err != nil {
+		t.Fatalf("SequenceBatch()=(_,%v), want (_,nil)", err)
+	}
+	if res.LeafCount != 0 {
+		t.Errorf("SequenceBatch()=%d, want 0 leaves", res.LeafCount)
+	}
+}
+
+func TestSequenceBatchRetryLimits(t *testing.T) {
+	transient := storage.Error{ErrType: storage.TransientError, Detail: "deadlock"}
+	for _, test := range []struct {
+		desc     string
+		err      error
+		policy   storage.RetryPolicy
+		attempts int
+	}{
+		{desc: "no policy", err: transient, attempts: 1},
+		{desc: "not transient", err: errors.New("dequeue"), policy: storage.RetryPolicy{MaxAttempts: 3}, attempts: 1},
+		{desc: "attempts exhausted", err: transient, policy: storage.RetryPolicy{MaxAttempts: 3}, attempts: 3},
+		{
+			desc:     "custom retriable",
+			err:      errors.New("dequeue"),
+			policy:   storage.RetryPolicy{MaxAttempts: 2, IsRetriable: func(error) bool { return true }},
+			attempts: 2,
+		},
+	} {
+		ctrl := gomock.NewController(t)
+
+		mockTx := storage.NewMockLogTreeTX(ctrl)
+		mockTx.EXPECT().DequeueLeaves(1, fakeTimeForTest).Times(test.attempts).Return(nil, test.err)
+		mockTx.EXPECT().Close().Times(test.attempts).Return(nil)
+		mockStorage := storage.NewMockLogStorage(ctrl)
+		mockStorage.EXPECT().BeginForTree(gomock.Any(), int64(154035)).Times(test.attempts).Return(mockTx, nil)
+		mockKeyManager := crypto.NewMockPrivateKeyManager(ctrl)
+		mockKeyManager.EXPECT().Public().AnyTimes().Return(testPublicKey)
+
+		sequencer := NewSequencer(testonly.Hasher, util.FakeTimeSource{FakeTime: fakeTimeForTest}, mockStorage, mockKeyManager)
+		sequencer.SetRetryPolicy(test.policy)
+		if _, err := sequencer.SequenceBatch(util.NewLogContext(context.Background(), 154035), 154035, 1); !errors.Is(err, test.err) {
+			t.Errorf("%s: SequenceBatch()=(_,%v), want (_,%v)", test.desc, err, test.err)
+		}
+		ctrl.Finish()
+	}
+}
+
+func TestSequenceBatchErrorContext(t *testing.T) {
+	leaves := []*trillian.LogLeaf{getLeaf42()}
+	updatedLeaves := []*trillian.LogLeaf{testLeaf16}
+	dequeueErr := errors.New("dequeue")
+	rootErr := errors.New("root")
+	transient := storage.Error{ErrType: storage.TransientError, Detail: "deadlock", Cause: errors.New("driver")}
+	for _, test := range []struct {
+		desc     string
+		params   testParameters
+		wantErr  error
+		wantText string
+	}{
+		{
+			desc:     "dequeue",
+			params:   testParameters{dequeueLimit: 1, dequeuedError: dequeueErr},
+			wantErr:  dequeueErr,
+			wantText: "tree 154035 batch: dequeue leaves: dequeue",
+		},
+		{
+			desc:     "latest root",
+			params:   testParameters{dequeueLimit: 1, dequeuedLeaves: leaves, latestSignedRoot: &testRoot16, latestSignedRootError: rootErr},
+			wantErr:  rootErr,
+			wantText: "tree 154035 batch: get latest root: root",
+		},
+		{
+			desc: "update leaves",
+			params: testParameters{
+				writeRevision:      testRoot16.TreeRevision + 1,
+				dequeueLimit:       1,
+				dequeuedLeaves:     leaves,
+				latestSignedRoot:   &testRoot16,
+				updatedLeaves:      &updatedLeaves,
+				updatedLeavesError: transient,
+			},
+			wantErr:  transient.Cause,
+			wantText: "tree 154035 batch: update sequenced leaves at size 16: ",
+		},
+	} {
+		ctrl := gomock.NewController(t)
+		test.params.logID = 154035
+		c, ctx := createTestContext(ctrl, test.params)
+
+		_, err := c.sequencer.SequenceBatch(ctx, test.params.logID, 1)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%s: SequenceBatch()=(_,%v), want error wrapping %v", test.desc, err, test.wantErr)
+		}
+		testonly.EnsureErrorContains(t, err, test.wantText)
+		ctrl.Finish()
+	}
+}
+
+func TestSequenceBatchOnSequenced(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	leaves := []*trillian.LogLeaf{getLeaf42()}
+	updatedLeaves := []*trillian.LogLeaf{testLeaf16}
 	params := testParameters{
 		logID:            154035,
 		writeRevision:    testRoot16.TreeRevision + 1,
-		latestSignedRoot: &trillian.SignedLogRoot{},
-		storeSignedRoot:  &expectedSignedRoot0,
+		dequeueLimit:     1,
+		shouldCommit:     true,
+		dequeuedLeaves:   leaves,
+		latestSignedRoot: &testRoot16,
+		updatedLeaves:    &updatedLeaves,
+		merkleNodesSet:   &updatedNodes,
+		storeSignedRoot:  &expectedSignedRoot,
 		setupSigner:      true,
-		dataToSign:       []byte{0xc2, 0xc, 0x1e, 0x33, 0x8, 0xcd, 0x2d, 0x50, 0xbb, 0xf9, 0xf9, 0x1, 0x29, 0xb2, 0xfb, 0xb9, 0x4d, 0x30, 0x27, 0x84, 0xf2, 0xc0, 0x48, 0x5f, 0x46, 0xd4, 0xbe, 0x8a, 0xb8, 0x27, 0x96, 0x22},
+		dataToSign:       []byte{44, 169, 138, 145, 110, 113, 26, 235, 244, 27, 16, 181, 51, 44, 176, 77, 147, 46, 215, 191, 92, 197, 8, 55, 232, 146, 103, 216, 7, 191, 244, 224},
 		signingResult:    []byte("signed"),
-		shouldCommit:     true,
 	}
 	c, ctx := createTestContext(ctrl, params)
 
-	if err := c.sequencer.SignRoot(ctx, params.logID); err != nil {
-		t.Fatalf("Expected signing to succeed, but got err: %v", err)
+	var order []int
+	var got []SequenceResult
+	c.sequencer.OnSequenced(func(res SequenceResult) {
+		order = append(order, 1)
+		got = append(got, res)
+	})
+	c.sequencer.OnSequenced(func(SequenceResult) { order = append(order, 2) })
+
+	if _, err := c.sequencer.SequenceBatch(ctx, params.logID, 1); err != nil {
+		t.Fatalf("SequenceBatch()=(_,%v), want (_,nil)", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("OnSequenced callback called %d times, want 1", len(got))
+	}
+	if got, want := got[0].LeafCount, 1; got != want {
+		t.Errorf("OnSequenced(res) res.LeafCount=%d, want %d", got, want)
+	}
+	if got, want := got[0].RootHash, expectedSignedRoot.RootHash; !bytes.Equal(got, want) {
+		t.Errorf("OnSequenced(res) res.RootHash=%x, want %x", got, want)
+	}
+	if len(order) != 2 || order[0] != 1 || order[1] != 2 {
+		t.Errorf("OnSequenced callbacks ran in order %v, want [1 2]", order)
+	}
+}
+
+func TestSequenceBatchOnSequencedNotCalled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, test := range []struct {
+		desc   string
+		params testParameters
+	}{
+		{
+			desc: "empty batch",
+			params: testParameters{
+				logID:            154035,
+				dequeueLimit:     1,
+				shouldCommit:     true,
+				latestSignedRoot: &testRoot16,
+				dequeuedLeaves:   []*trillian.LogLeaf{},
+			},
+		},
+		{
+			desc: "commit fails",
+			params: testParameters{
+				logID:            154035,
+				writeRevision:    testRoot16.TreeRevision + 1,
+				dequeueLimit:     1,
+				shouldCommit:     true,
+				commitFails:      true,
+				commitError:      errors.New("commit"),
+				dequeuedLeaves:   []*trillian.LogLeaf{getLeaf42()},
+				latestSignedRoot: &testRoot16,
+				updatedLeaves:    &[]*trillian.LogLeaf{testLeaf16},
+				merkleNodesSet:   &updatedNodes,
+				storeSignedRoot:  &expectedSignedRoot,
+				setupSigner:      true,
+				dataToSign:       []byte{44, 169, 138, 145, 110, 113, 26, 235, 244, 27, 16, 181, 51, 44, 176, 77, 147, 46, 215, 191, 92, 197, 8, 55, 232, 146, 103, 216, 7, 191, 244, 224},
+				signingResult:    []byte("signed"),
+			},
+		},
+	} {
+		c, ctx := createTestContext(ctrl, test.params)
+		c.sequencer.OnSequenced(func(res SequenceResult) {
+			t.Errorf("%s: OnSequenced(%v) called, want no call", test.desc, res)
+		})
+		c.sequencer.SequenceBatch(ctx, test.params.logID, 1)
+	}
+}
+
+func TestSequenceBatchMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	leaves := []*trillian.LogLeaf{getLeaf42()}
+	updatedLeaves := []*trillian.LogLeaf{testLeaf16}
+	params := testParameters{
+		logID:            154036,
+		writeRevision:    testRoot16.TreeRevision + 1,
+		dequeueLimit:     1,
+		shouldCommit:     true,
+		dequeuedLeaves:   leaves,
+		latestSignedRoot: &testRoot16,
+		updatedLeaves:    &updatedLeaves,
+		merkleNodesSet:   &updatedNodes,
+		storeSignedRoot:  &expectedSignedRoot,
+		setupSigner:      true,
+		dataToSign:       []byte{44, 169, 138, 145, 110, 113, 26, 235, 244, 27, 16, 181, 51, 44, 176, 77, 147, 46, 215, 191, 92, 197, 8, 55, 232, 146, 103, 216, 7, 191, 244, 224},
+		signingResult:    []byte("signed"),
+	}
+	c, ctx := createTestContext(ctrl, params)
+	metrics := newRecordingMetrics()
+	c.sequencer.SetMetrics(metrics)
+
+	if _, err := c.sequencer.SequenceBatch(ctx, params.logID, 1); err != nil {
+		t.Fatalf("SequenceBatch()=(_,%v), want (_,nil)", err)
+	}
+
+	want := map[string][]float64{
+		"counter sequencer_batches logid=154036":                        {1},
+		"counter sequencer_leaves_sequenced logid=154036":               {1},
+		"gauge sequencer_queued_leaves logid=154036":                    {0},
+		"gauge sequencer_tree_size logid=154036":                        {17},
+		"gauge sequencer_last_sequenced_timestamp_seconds logid=154036": {float64(fakeTimeForTest.Unix())},
+		"histogram sequencer_batch_duration_seconds logid=154036":       {0},
+	}
+	if !reflect.DeepEqual(metrics.values, want) {
+		t.Errorf("SequenceBatch() recorded metrics %v, want %v", metrics.values, want)
+	}
+}
+
+func TestSequenceBatchMetricsError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{
+		logID:         154037,
+		dequeueLimit:  1,
+		dequeuedError: errors.New("dequeue"),
+	}
+	c, ctx := createTestContext(ctrl, params)
+	metrics := newRecordingMetrics()
+	c.sequencer.SetMetrics(metrics)
+
+	if _, err := c.sequencer.SequenceBatch(ctx, params.logID, 1); err == nil {
+		t.Fatal("SequenceBatch()=(_,nil), want (_,err)")
+	}
+
+	want := map[string][]float64{
+		"counter sequencer_batches logid=154037":                  {1},
+		"counter sequencer_errors logid=154037":                   {1},
+		"histogram sequencer_batch_duration_seconds logid=154037": {0},
+	}
+	if !reflect.DeepEqual(metrics.values, want) {
+		t.Errorf("SequenceBatch() recorded metrics %v, want %v", metrics.values, want)
+	}
+}
+
+func TestSequenceBatchTreeGauges(t *testing.T) {
+	ls, logID, ctx, keyManager := newMemoryLogForTest(t)
+	timeSource := &util.FakeTimeSource{FakeTime: fakeTimeForTest}
+
+	leaves := testLeaves(0, 3)
+	queueLeaves(t, ls, ctx, logID, leaves, fakeTimeForTest)
+
+	sequencer := NewSequencer(testonly.Hasher, timeSource, ls, keyManager)
+	metrics := newRecordingMetrics()
+	sequencer.SetMetrics(metrics)
+	sizeKey := fmt.Sprintf("gauge %s logid=%d", TreeSizeMetric, logID)
+	lastKey := fmt.Sprintf("gauge %s logid=%d", LastSequencedMetric, logID)
+	// The first pass signs the empty tree, and the last finds nothing left to sequence.
+	for i, wantSize := range []int64{0, 2, 3, 3} {
+		timeSource.FakeTime = timeSource.FakeTime.Add(time.Minute)
+		if _, err := sequencer.SequenceBatch(ctx, logID, 2); err != nil {
+			t.Fatalf("%d: SequenceBatch()=(_,%v), want (_,nil)", i, err)
+		}
+		if got := metrics.values[sizeKey]; len(got) != i+1 || got[i] != float64(wantSize) {
+			t.Errorf("%d: %s=%v, want latest value %d", i, TreeSizeMetric, got, wantSize)
+		}
+		if got, want := metrics.values[lastKey], float64(timeSource.Now().Unix()); len(got) != i+1 || got[i] != want {
+			t.Errorf("%d: %s=%v, want latest value %v", i, LastSequencedMetric, got, want)
+		}
+	}
+}
+
+// recordingMetrics is a monitoring.Metrics that keeps every value recorded, keyed by
+// the kind of metric, its name and its labels.
+type recordingMetrics struct {
+	values map[string][]float64
+}
+
+func newRecordingMetrics() *recordingMetrics {
+	return &recordingMetrics{values: make(map[string][]float64)}
+}
+
+func (m *recordingMetrics) record(kind, name string, labels monitoring.Labels, value float64) {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	key := kind + " " + name
+	for _, n := range names {
+		key += fmt.Sprintf(" %s=%s", n, labels[n])
+	}
+	m.values[key] = append(m.values[key], value)
+}
+
+func (m *recordingMetrics) AddCounter(name string, labels monitoring.Labels, delta float64) {
+	m.record("counter", name, labels, delta)
+}
+
+func (m *recordingMetrics) SetGauge(name string, labels monitoring.Labels, value float64) {
+	m.record("gauge", name, labels, value)
+}
+
+func (m *recordingMetrics) ObserveHistogram(name string, labels monitoring.Labels, value float64) {
+	m.record("histogram", name, labels, value)
+}
+
+func TestSequenceBatchEventLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	leaves := []*trillian.LogLeaf{getLeaf42()}
+	updatedLeaves := []*trillian.LogLeaf{testLeaf16}
+	params := testParameters{
+		logID:            154035,
+		writeRevision:    testRoot16.TreeRevision + 1,
+		dequeueLimit:     1,
+		shouldCommit:     true,
+		dequeuedLeaves:   leaves,
+		latestSignedRoot: &testRoot16,
+		updatedLeaves:    &updatedLeaves,
+		merkleNodesSet:   &updatedNodes,
+		storeSignedRoot:  &expectedSignedRoot,
+		setupSigner:      true,
+		dataToSign:       []byte{44, 169, 138, 145, 110, 113, 26, 235, 244, 27, 16, 181, 51, 44, 176, 77, 147, 46, 215, 191, 92, 197, 8, 55, 232, 146, 103, 216, 7, 191, 244, 224},
+		signingResult:    []byte("signed"),
+	}
+	c, ctx := createTestContext(ctrl, params)
+	var buf bytes.Buffer
+	c.sequencer.SetEventLogger(util.NewJSONEventLogger(&buf, util.FakeTimeSource{FakeTime: fakeTimeForTest}))
+
+	if _, err := c.sequencer.SequenceBatch(ctx, params.logID, 1); err != nil {
+		t.Fatalf("SequenceBatch()=(_,%v), want (_,nil)", err)
+	}
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+		t.Fatalf("SequenceBatch() logged %q, which is not a JSON object: %v", buf.String(), err)
+	}
+	for key, want := range map[string]interface{}{
+		"event":      "sequence_batch",
+		"tree_id":    float64(params.logID),
+		"batch_size": float64(1),
+		"leaves":     float64(1),
+		"tree_size":  float64(expectedSignedRoot.TreeSize),
+	} {
+		if got := event[key]; got != want {
+			t.Errorf("event %s=%v, want %v", key, got, want)
+		}
+	}
+	if _, ok := event["duration_ms"]; !ok {
+		t.Errorf("event has no duration_ms: %v", event)
+	}
+	if _, ok := event["error"]; ok {
+		t.Errorf("event has error for a successful batch: %v", event)
+	}
+}
+
+func TestSequenceBatchEventLogError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{
+		logID:         154035,
+		dequeueLimit:  1,
+		dequeuedError: errors.New("dequeue"),
+	}
+	c, ctx := createTestContext(ctrl, params)
+	var buf bytes.Buffer
+	c.sequencer.SetEventLogger(util.NewJSONEventLogger(&buf, util.FakeTimeSource{FakeTime: fakeTimeForTest}))
+
+	if _, err := c.sequencer.SequenceBatch(ctx, params.logID, 1); err == nil {
+		t.Fatal("SequenceBatch()=(_,nil), want (_,err)")
+	}
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+		t.Fatalf("SequenceBatch() logged %q, which is not a JSON object: %v", buf.String(), err)
+	}
+	if got, want := event["error"], "dequeue leaves: dequeue"; got != want {
+		t.Errorf("event error=%v, want %v", got, want)
+	}
+}
+
+func TestSequenceBatchSignatureVerifies(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	keyManager := newTestKeyManager(t)
+
+	var stored trillian.SignedLogRoot
+	mockTx := storage.NewMockLogTreeTX(ctrl)
+	mockTx.EXPECT().DequeueLeaves(1, fakeTimeForTest).Return([]*trillian.LogLeaf{getLeaf42()}, nil)
+	mockTx.EXPECT().QueuedLeafCount().Return(int64(0), nil)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(testRoot16, nil)
+	mockTx.EXPECT().WriteRevision().AnyTimes().Return(testRoot16.TreeRevision + 1)
+	mockTx.EXPECT().UpdateSequencedLeaves(gomock.Any()).Return(nil)
+	mockTx.EXPECT().SetMerkleNodes(gomock.Any()).Return(nil)
+	mockTx.EXPECT().StoreSignedLogRoot(gomock.Any()).Do(func(root trillian.SignedLogRoot) { stored = root }).Return(nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().Close().Return(nil)
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockStorage.EXPECT().BeginForTree(gomock.Any(), int64(154035)).Return(mockTx, nil)
+
+	sequencer := NewSequencer(testonly.Hasher, util.FakeTimeSource{FakeTime: fakeTimeForTest}, mockStorage, keyManager)
+	res, err := sequencer.SequenceBatch(util.NewLogContext(context.Background(), 154035), 154035, 1)
+	if err != nil {
+		t.Fatalf("SequenceBatch()=(_,%v), want (_,nil)", err)
+	}
+	if !proto.Equal(res.Signature, stored.Signature) {
+		t.Errorf("SequenceBatch().Signature=%v, want stored signature %v", res.Signature, stored.Signature)
+	}
+
+	// The signature is over the root as stored, without its signature.
+	stored.Signature = nil
+	if err := crypto.VerifySTH(keyManager.Public(), crypto.STHFromRoot(stored), res.Signature, crypto.STHVersion1); err != nil {
+		t.Errorf("Verify(signed root)=%v, want nil", err)
+	}
+	if got, want := stored.TreeSize, res.TreeSize; got != want {
+		t.Errorf("signed root TreeSize=%d, want %d", got, want)
+	}
+}
+
+func TestSequencerWithMemoryStorage(t *testing.T) {
+	ls, logID, ctx, keyManager := newMemoryLogForTest(t)
+	timeSource := &util.FakeTimeSource{FakeTime: fakeTimeForTest}
+
+	// Queue some leaves, and build the tree we expect them to be sequenced into.
+	want := merkle.NewCompactMerkleTree(testonly.Hasher)
+	leaves := testLeaves(0, 5)
+	for i, leaf := range leaves {
+		leaf.ExtraData = []byte(fmt.Sprintf("extra %d", i))
+	}
+	queueLeaves(t, ls, ctx, logID, leaves, fakeTimeForTest)
+
+	sequencer := NewSequencer(testonly.Hasher, timeSource, ls, keyManager)
+	for i, test := range []struct {
+		wantLeaves int
+		wantSize   int64
+	}{
+		{wantLeaves: 0, wantSize: 0}, // The first pass signs the empty tree.
+		{wantLeaves: 3, wantSize: 3},
+		{wantLeaves: 2, wantSize: 5},
+		{wantLeaves: 0, wantSize: 5},
+	} {
+		// Roots need distinct timestamps.
+		timeSource.FakeTime = timeSource.FakeTime.Add(time.Second)
+		res, err := sequencer.SequenceBatch(ctx, logID, 3)
+		if err != nil {
+			t.Fatalf("%d: SequenceBatch()=(_,%v), want (_,nil)", i, err)
+		}
+		if res.LeafCount != test.wantLeaves || res.TreeSize != test.wantSize {
+			t.Errorf("%d: SequenceBatch()=%d leaves, size %d, want %d leaves, size %d", i, res.LeafCount, res.TreeSize, test.wantLeaves, test.wantSize)
+		}
+	}
+
+	// Sequencing order follows the queue, which for leaves queued together is by identity hash.
+	tx, err := ls.BeginForTree(ctx, logID)
+	if err != nil {
+		t.Fatalf("BeginForTree()=(_,%v), want (_,nil)", err)
+	}
+	defer tx.Close()
+	sequenced, err := tx.GetLeavesByIndex([]int64{0, 1, 2, 3, 4})
+	if err != nil {
+		t.Fatalf("GetLeavesByIndex()=(_,%v), want (_,nil)", err)
+	}
+	for _, leaf := range sequenced {
+		want.AddLeafHash(leaf.MerkleLeafHash, func(int, int64, []byte) {})
+		// Extra data is stored with the leaf, but is not covered by its Merkle hash.
+		if got, want := string(leaf.ExtraData), strings.Replace(string(leaf.LeafValue), "leaf", "extra", 1); got != want {
+			t.Errorf("GetLeavesByIndex(%d).ExtraData=%q, want %q", leaf.LeafIndex, got, want)
+		}
+		if got, want := leaf.MerkleLeafHash, testonly.Hasher.HashLeaf(leaf.LeafValue); !bytes.Equal(got, want) {
+			t.Errorf("GetLeavesByIndex(%d).MerkleLeafHash=%x, want %x", leaf.LeafIndex, got, want)
+		}
+		if got, want := leaf.QueueTimestampNanos, fakeTimeForTest.UnixNano(); got != want {
+			t.Errorf("GetLeavesByIndex(%d).QueueTimestampNanos=%d, want %d", leaf.LeafIndex, got, want)
+		}
+		if got := leaf.IntegrateTimestampNanos; got == 0 || got < leaf.QueueTimestampNanos {
+			t.Errorf("GetLeavesByIndex(%d).IntegrateTimestampNanos=%d, want set and >= %d", leaf.LeafIndex, got, leaf.QueueTimestampNanos)
+		}
+	}
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot()=(_,%v), want (_,nil)", err)
+	}
+	if got, want := root.RootHash, want.CurrentRoot(); !bytes.Equal(got, want) {
+		t.Errorf("LatestSignedLogRoot().RootHash=%x, want %x", got, want)
+	}
+	signature := root.Signature
+	root.Signature = nil
+	if err := crypto.VerifySTH(keyManager.Public(), crypto.STHFromRoot(root), signature, crypto.STHVersion1); err != nil {
+		t.Errorf("Verify(LatestSignedLogRoot())=%v, want nil", err)
+	}
+}
+
+func TestSequencerOrderingPolicy(t *testing.T) {
+	// Leaves are queued one at a time, in slice order, but with queue times out of order.
+	queueSeconds := []int{3, 0, 4, 1, 2}
+	leaves := testLeaves(0, len(queueSeconds))
+
+	for _, policy := range []OrderingPolicy{FIFOOrdering, UnorderedOrdering} {
+		ls, logID, ctx, keyManager := newMemoryLogForTest(t)
+		timeSource := &util.FakeTimeSource{FakeTime: fakeTimeForTest.Add(time.Hour)}
+
+		tx, err := ls.BeginForTree(ctx, logID)
+		if err != nil {
+			t.Fatalf("BeginForTree()=(_,%v), want (_,nil)", err)
+		}
+		for i, leaf := range leaves {
+			if err := tx.QueueLeaves([]*trillian.LogLeaf{leaf}, fakeTimeForTest.Add(time.Duration(queueSeconds[i])*time.Second)); err != nil {
+				t.Fatalf("QueueLeaves()=%v, want nil", err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Commit()=%v, want nil", err)
+		}
+
+		sequencer := NewSequencer(testonly.Hasher, timeSource, ls, keyManager)
+		sequencer.SetOrderingPolicy(policy)
+		for size := int64(-1); size < int64(len(leaves)); {
+			timeSource.FakeTime = timeSource.FakeTime.Add(time.Second)
+			res, err := sequencer.SequenceBatch(ctx, logID, 2)
+			if err != nil {
+				t.Fatalf("%v: SequenceBatch()=(_,%v), want (_,nil)", policy, err)
+			}
+			size = res.TreeSize
+		}
+
+		tx, err = ls.BeginForTree(ctx, logID)
+		if err != nil {
+			t.Fatalf("BeginForTree()=(_,%v), want (_,nil)", err)
+		}
+		sequenced, err := tx.GetLeavesByIndex([]int64{0, 1, 2, 3, 4})
+		tx.Close()
+		if err != nil {
+			t.Fatalf("GetLeavesByIndex()=(_,%v), want (_,nil)", err)
+		}
+		seen := make(map[string]bool)
+		for idx, leaf := range sequenced {
+			seen[string(leaf.LeafIdentityHash)] = true
+			if policy != FIFOOrdering {
+				continue
+			}
+			// FIFO sequences the leaf queued idx seconds after the first at index idx.
+			for i, secs := range queueSeconds {
+				if secs == idx && !bytes.Equal(leaf.LeafIdentityHash, leaves[i].LeafIdentityHash) {
+					t.Errorf("%v: leaf at index %d is %s, want leaf %d", policy, idx, leaf.LeafValue, i)
+				}
+			}
+		}
+		if len(seen) != len(leaves) {
+			t.Errorf("%v: sequenced %d distinct leaves, want %d", policy, len(seen), len(leaves))
+		}
+	}
+}
+
+func TestSequencerDequeueCursor(t *testing.T) {
+	ls, logID, ctx, keyManager := newMemoryLogForTest(t)
+	timeSource := &util.FakeTimeSource{FakeTime: fakeTimeForTest.Add(time.Hour)}
+
+	// A backlog of several batches, queued a second apart so FIFO order is slice order.
+	const numLeaves, batchSize = 23, 5
+	leaves := testLeaves(0, numLeaves)
+	tx, err := ls.BeginForTree(ctx, logID)
+	if err != nil {
+		t.Fatalf("BeginForTree()=(_,%v), want (_,nil)", err)
+	}
+	for i, leaf := range leaves {
+		if err := tx.QueueLeaves([]*trillian.LogLeaf{leaf}, fakeTimeForTest.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatalf("QueueLeaves()=%v, want nil", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit()=%v, want nil", err)
+	}
+
+	// As in the continuous loop, a new Sequencer is used for each batch but the cursor
+	// is kept. A dry run in the middle must not move the cursor.
+	cursor := &DequeueCursor{}
+	for batch, size := 0, int64(-1); size < numLeaves; batch++ {
+		if batch > 20 {
+			t.Fatalf("tree size %d after %d batches, want %d", size, batch, numLeaves)
+		}
+		timeSource.FakeTime = timeSource.FakeTime.Add(time.Second)
+		sequencer := NewSequencer(testonly.Hasher, timeSource, ls, keyManager)
+		sequencer.SetDequeueCursor(cursor)
+		if batch == 2 {
+			dryRun := NewSequencer(testonly.Hasher, timeSource, ls, keyManager)
+			dryRun.SetDequeueCursor(cursor)
+			dryRun.SetDryRun(true)
+			if _, err := dryRun.SequenceBatch(ctx, logID, batchSize); err != nil {
+				t.Fatalf("SequenceBatch(dry run)=(_,%v), want (_,nil)", err)
+			}
+		}
+		res, err := sequencer.SequenceBatch(ctx, logID, batchSize)
+		if err != nil {
+			t.Fatalf("SequenceBatch()=(_,%v), want (_,nil)", err)
+		}
+		if res.LeafCount == batchSize && cursor.get() == nil {
+			t.Errorf("%d: cursor token is nil after a full batch, want the batch's position", batch)
+		}
+		size = res.TreeSize
+	}
+	if got := cursor.get(); got != nil {
+		t.Errorf("cursor token=%x after the queue drained, want nil", got)
+	}
+
+	tx, err = ls.BeginForTree(ctx, logID)
+	if err != nil {
+		t.Fatalf("BeginForTree()=(_,%v), want (_,nil)", err)
+	}
+	defer tx.Close()
+	indices := make([]int64, numLeaves)
+	for i := range indices {
+		indices[i] = int64(i)
+	}
+	sequenced, err := tx.GetLeavesByIndex(indices)
+	if err != nil {
+		t.Fatalf("GetLeavesByIndex()=(_,%v), want (_,nil)", err)
+	}
+	for i, leaf := range sequenced {
+		if !bytes.Equal(leaf.LeafIdentityHash, leaves[i].LeafIdentityHash) {
+			t.Errorf("leaf at index %d is %s, want %s", i, leaf.LeafValue, leaves[i].LeafValue)
+		}
+	}
+	if n, err := tx.QueuedLeafCount(); err != nil || n != 0 {
+		t.Errorf("QueuedLeafCount()=(%d,%v), want (0,nil)", n, err)
+	}
+}
+
+func TestParseOrderingPolicy(t *testing.T) {
+	for _, test := range []struct {
+		in      string
+		want    OrderingPolicy
+		wantErr bool
+	}{
+		{in: "fifo", want: FIFOOrdering},
+		{in: "unordered", want: UnorderedOrdering},
+		{in: "FIFO", wantErr: true},
+		{in: "", wantErr: true},
+	} {
+		got, err := ParseOrderingPolicy(test.in)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("ParseOrderingPolicy(%q)=(_,%v), want err? %v", test.in, err, test.wantErr)
+			continue
+		}
+		if err == nil && got != test.want {
+			t.Errorf("ParseOrderingPolicy(%q)=%v, want %v", test.in, got, test.want)
+		}
+		if err == nil && got.String() != test.in {
+			t.Errorf("ParseOrderingPolicy(%q).String()=%q, want %q", test.in, got.String(), test.in)
+		}
+	}
+}
+
+func TestSequencerRateLimit(t *testing.T) {
+	ls, logID, ctx, keyManager := newMemoryLogForTest(t)
+	timeSource := &util.FakeTimeSource{FakeTime: fakeTimeForTest}
+
+	leaves := testLeaves(0, 200)
+	queueLeaves(t, ls, ctx, logID, leaves, fakeTimeForTest)
+
+	// As in the continuous loop, a new Sequencer is used for each batch but the
+	// limiter is kept. Batches every 500ms could take 50 leaves each, but the limit
+	// is 4 per second.
+	const rate, burst, window = 4, 8, 10 * time.Second
+	limiter := NewLeafRateLimiter(rate, burst, timeSource)
+	total := 0
+	for elapsed := time.Duration(0); elapsed < window; elapsed += 500 * time.Millisecond {
+		sequencer := NewSequencer(testonly.Hasher, timeSource, ls, keyManager)
+		sequencer.SetRateLimiter(limiter)
+		res, err := sequencer.SequenceBatch(ctx, logID, 50)
+		if err != nil {
+			t.Fatalf("SequenceBatch()=(_,%v), want (_,nil)", err)
+		}
+		total += res.LeafCount
+		timeSource.FakeTime = timeSource.FakeTime.Add(500 * time.Millisecond)
+	}
+	if ceiling := burst + int(rate*window.Seconds()); total > ceiling {
+		t.Errorf("sequenced %d leaves in %v, want at most %d", total, window, ceiling)
+	}
+	// The limit should not be much lower than the configured rate either.
+	if floor := int(rate * window.Seconds()); total < floor {
+		t.Errorf("sequenced %d leaves in %v, want at least %d", total, window, floor)
+	}
+}
+
+func TestSequenceLeavesHashConcurrency(t *testing.T) {
+	newLeaves := func(n int) []*trillian.LogLeaf {
+		leaves := make([]*trillian.LogLeaf, n)
+		for i := range leaves {
+			leaves[i] = &trillian.LogLeaf{MerkleLeafHash: testonly.Hasher.HashLeaf([]byte(fmt.Sprintf("leaf %d", i)))}
+		}
+		return leaves
+	}
+	const startSize = 37
+	newTree := func() *merkle.CompactMerkleTree {
+		mt := merkle.NewCompactMerkleTree(testonly.Hasher)
+		for i := 0; i < startSize; i++ {
+			mt.AddLeaf([]byte(fmt.Sprintf("old leaf %d", i)), func(int, int64, []byte) {})
+		}
+		return mt
+	}
+
+	// The serial result is built one leaf at a time, as the sequencer used to.
+	want := newTree()
+	wantNodes := make(map[string][]byte)
+	for _, leaf := range newLeaves(3000) {
+		want.AddLeafHash(leaf.MerkleLeafHash, func(depth int, index int64, hash []byte) {
+			nodeID, err := storage.NewNodeIDForTreeCoords(int64(depth), index, maxTreeDepth)
+			if err != nil {
+				t.Fatalf("NewNodeIDForTreeCoords()=_,%v, want nil", err)
+			}
+			wantNodes[nodeID.String()] = hash
+		})
+	}
+
+	for _, workers := range []int{0, 1, 2, 8} {
+		sequencer := NewSequencer(testonly.Hasher, util.FakeTimeSource{FakeTime: fakeTimeForTest}, nil, nil)
+		sequencer.SetHashConcurrency(workers)
+		mt := newTree()
+		nodeMap, leaves, err := sequencer.sequenceLeaves(mt, newLeaves(3000))
+		if err != nil {
+			t.Fatalf("%d workers: sequenceLeaves()=_,_,%v, want nil", workers, err)
+		}
+		if got, want := mt.CurrentRoot(), want.CurrentRoot(); !bytes.Equal(got, want) {
+			t.Errorf("%d workers: sequenceLeaves() root=%x, want %x", workers, got, want)
+		}
+		for i, leaf := range leaves {
+			if got, want := leaf.LeafIndex, int64(startSize+i); got != want {
+				t.Errorf("%d workers: leaf %d has LeafIndex %d, want %d", workers, i, got, want)
+			}
+		}
+		if got, want := len(nodeMap), len(wantNodes); got != want {
+			t.Errorf("%d workers: sequenceLeaves() returned %d nodes, want %d", workers, got, want)
+		}
+		for k, node := range nodeMap {
+			if !bytes.Equal(node.Hash, wantNodes[k]) {
+				t.Errorf("%d workers: node %v has hash %x, want %x", workers, node.NodeID, node.Hash, wantNodes[k])
+			}
+		}
+	}
+}
+
+func TestSignBeginTxFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{logID: 154035, beginFails: true}
+	c, ctx := createTestContext(ctrl, params)
+
+	err := c.sequencer.SignRoot(ctx, params.logID)
+	testonly.EnsureErrorContains(t, err, "TX")
+}
+
+func TestSignLatestRootFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{
+		logID:                 154035,
+		writeRevision:         testRoot16.TreeRevision + 1,
+		dequeueLimit:          1,
+		latestSignedRoot:      &testRoot16,
+		latestSignedRootError: errors.New("root"),
+	}
+	c, ctx := createTestContext(ctrl, params)
+
+	err := c.sequencer.SignRoot(ctx, params.logID)
+	testonly.EnsureErrorContains(t, err, "root")
+}
+
+func TestSignRootSignerFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{
+		logID:            154035,
+		writeRevision:    testRoot16.TreeRevision + 1,
+		dequeueLimit:     1,
+		latestSignedRoot: &testRoot16,
+		storeSignedRoot:  nil,
+		setupSigner:      true,
+		dataToSign:       []byte{0xc1, 0xdf, 0x7a, 0x37, 0x9b, 0x5b, 0x21, 0xfa, 0xc1, 0x13, 0x71, 0x69, 0xc9, 0xde, 0x6b, 0x77, 0xb6, 0xc3, 0xed, 0x27, 0x14, 0x7c, 0x58, 0x48, 0x55, 0x34, 0xd8, 0x7, 0x94, 0xd3, 0x17, 0xdf},
+		signingError:     errors.New("signerfailed"),
+	}
+	c, ctx := createTestContext(ctrl, params)
+
+	err := c.sequencer.SignRoot(ctx, params.logID)
+	testonly.EnsureErrorContains(t, err, "signer")
+}
+
+func TestSignRootStoreSignedRootFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{
+		logID:                154035,
+		writeRevision:        testRoot16.TreeRevision + 1,
+		latestSignedRoot:     &testRoot16,
+		storeSignedRoot:      nil,
+		storeSignedRootError: errors.New("storesignedroot"),
+		setupSigner:          true,
+		dataToSign:           []byte{0xc1, 0xdf, 0x7a, 0x37, 0x9b, 0x5b, 0x21, 0xfa, 0xc1, 0x13, 0x71, 0x69, 0xc9, 0xde, 0x6b, 0x77, 0xb6, 0xc3, 0xed, 0x27, 0x14, 0x7c, 0x58, 0x48, 0x55, 0x34, 0xd8, 0x7, 0x94, 0xd3, 0x17, 0xdf},
+		signingResult:        []byte("signed"),
+	}
+	c, ctx := createTestContext(ctrl, params)
+
+	err := c.sequencer.SignRoot(ctx, params.logID)
+	testonly.EnsureErrorContains(t, err, "storesignedroot")
+}
+
+func TestSignRootCommitFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{
+		logID:            154035,
+		writeRevision:    testRoot16.TreeRevision + 1,
+		shouldCommit:     true,
+		commitFails:      true,
+		commitError:      errors.New("commit"),
+		latestSignedRoot: &testRoot16,
+		storeSignedRoot:  nil,
+		setupSigner:      true,
+		dataToSign:       []byte{0xc1, 0xdf, 0x7a, 0x37, 0x9b, 0x5b, 0x21, 0xfa, 0xc1, 0x13, 0x71, 0x69, 0xc9, 0xde, 0x6b, 0x77, 0xb6, 0xc3, 0xed, 0x27, 0x14, 0x7c, 0x58, 0x48, 0x55, 0x34, 0xd8, 0x7, 0x94, 0xd3, 0x17, 0xdf},
+		signingResult:    []byte("signed"),
+	}
+	c, ctx := createTestContext(ctrl, params)
+
+	err := c.sequencer.SignRoot(ctx, params.logID)
+	testonly.EnsureErrorContains(t, err, "commit")
+}
+
+func TestSignRoot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{
+		logID:            154035,
+		writeRevision:    testRoot16.TreeRevision + 1,
+		latestSignedRoot: &testRoot16,
+		storeSignedRoot:  &expectedSignedRoot16,
+		setupSigner:      true,
+		dataToSign:       []byte{0xc1, 0xdf, 0x7a, 0x37, 0x9b, 0x5b, 0x21, 0xfa, 0xc1, 0x13, 0x71, 0x69, 0xc9, 0xde, 0x6b, 0x77, 0xb6, 0xc3, 0xed, 0x27, 0x14, 0x7c, 0x58, 0x48, 0x55, 0x34, 0xd8, 0x7, 0x94, 0xd3, 0x17, 0xdf},
+		signingResult:    []byte("signed"), shouldCommit: true,
+	}
+	c, ctx := createTestContext(ctrl, params)
+
+	if err := c.sequencer.SignRoot(ctx, params.logID); err != nil {
+		t.Fatalf("Expected signing to succeed, but got err: %v", err)
+	}
+}
+
+func TestSignRootNoExistingRoot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{
+		logID:            154035,
+		writeRevision:    testRoot16.TreeRevision + 1,
+		latestSignedRoot: &trillian.SignedLogRoot{},
+		storeSignedRoot:  &expectedSignedRoot0,
+		setupSigner:      true,
+		dataToSign:       []byte{0x73, 0xaa, 0x89, 0xf9, 0x60, 0x33, 0x81, 0x1b, 0xb5, 0x7c, 0xe0, 0x5, 0xff, 0x37, 0x4, 0x56, 0xe2, 0x5a, 0x1c, 0x46, 0xde, 0xcc, 0xad, 0x3e, 0x7a, 0x15, 0x86, 0x1, 0x44, 0xc7, 0x2f, 0xb3},
+		signingResult:    []byte("signed"),
+		shouldCommit:     true,
+	}
+	c, ctx := createTestContext(ctrl, params)
+
+	if err := c.sequencer.SignRoot(ctx, params.logID); err != nil {
+		t.Fatalf("Expected signing to succeed, but got err: %v", err)
+	}
+}
+
+func TestSequencerForceResignInterval(t *testing.T) {
+	ls, logID, ctx, keyManager := newMemoryLogForTest(t)
+	timeSource := &util.FakeTimeSource{FakeTime: fakeTimeForTest.Add(time.Hour)}
+
+	queueLeaves(t, ls, ctx, logID, testLeaves(0, 1), fakeTimeForTest)
+	// The first pass initializes the tree, the second integrates the leaf.
+	for i := 0; i < 2; i++ {
+		timeSource.FakeTime = timeSource.FakeTime.Add(time.Second)
+		if _, err := NewSequencer(testonly.Hasher, timeSource, ls, keyManager).SequenceBatch(ctx, logID, 10); err != nil {
+			t.Fatalf("SequenceBatch()=(_,%v), want (_,nil)", err)
+		}
+	}
+	if got := latestRoot(ctx, t, ls, logID).TreeSize; got != 1 {
+		t.Fatalf("TreeSize=%d, want 1", got)
+	}
+
+	const interval = time.Minute
+	tests := []struct {
+		desc       string
+		interval   time.Duration
+		elapsed    time.Duration
+		wantResign bool
+	}{
+		{desc: "default", elapsed: 24 * time.Hour},
+		{desc: "before interval", interval: interval, elapsed: interval - time.Second},
+		{desc: "after interval", interval: interval, elapsed: interval, wantResign: true},
+	}
+
+	for _, test := range tests {
+		latest := latestRoot(ctx, t, ls, logID)
+		timeSource.FakeTime = time.Unix(0, latest.TimestampNanos).Add(test.elapsed)
+		sequencer := NewSequencer(testonly.Hasher, timeSource, ls, keyManager)
+		sequencer.SetForceResignInterval(test.interval)
+		res, err := sequencer.SequenceBatch(ctx, logID, 10)
+		if err != nil {
+			t.Errorf("%s: SequenceBatch()=(_,%v), want (_,nil)", test.desc, err)
+			continue
+		}
+		if res.LeafCount != 0 {
+			t.Errorf("%s: SequenceBatch().LeafCount=%d, want 0", test.desc, res.LeafCount)
+		}
+		got := latestRoot(ctx, t, ls, logID)
+		if !test.wantResign {
+			if !proto.Equal(&got, &latest) {
+				t.Errorf("%s: latest root=%+v, want unchanged %+v", test.desc, got, latest)
+			}
+			continue
+		}
+		want := latest
+		want.TimestampNanos = timeSource.Now().UnixNano()
+		want.TreeRevision = latest.TreeRevision + 1
+		want.Signature = got.Signature
+		if !proto.Equal(&got, &want) {
+			t.Errorf("%s: latest root=%+v, want %+v", test.desc, got, want)
+		}
+		if got.Signature == nil || proto.Equal(got.Signature, latest.Signature) {
+			t.Errorf("%s: latest root signature=%v, want a new signature", test.desc, got.Signature)
+		}
+		if !proto.Equal(res.Signature, got.Signature) {
+			t.Errorf("%s: SequenceBatch().Signature=%v, want %v", test.desc, res.Signature, got.Signature)
+		}
+	}
+}
+
+func latestRoot(ctx context.Context, t *testing.T, ls storage.LogStorage, logID int64) trillian.SignedLogRoot {
+	t.Helper()
+	tx, err := ls.SnapshotForTree(ctx, logID)
+	if err != nil {
+		t.Fatalf("SnapshotForTree()=(_,%v), want (_,nil)", err)
+	}
+	defer tx.Close()
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot()=(_,%v), want (_,nil)", err)
+	}
+	return root
+}
+
+func TestSequenceBatchSTH(t *testing.T) {
+	ls, logID, ctx, keyManager := newMemoryLogForTest(t)
+
+	// The clock moves on between the batches, so the timestamp of the latest STH must
+	// come from the time source during the second batch.
+	start := fakeTimeForTest.Add(time.Hour)
+	timeSource := &util.FakeTimeSource{FakeTime: start}
+	queueLeaves(t, ls, ctx, logID, testLeaves(0, 1), fakeTimeForTest)
+	for i := 0; i < 2; i++ {
+		timeSource.FakeTime = timeSource.FakeTime.Add(time.Minute)
+		if _, err := NewSequencer(testonly.Hasher, timeSource, ls, keyManager).SequenceBatch(ctx, logID, 10); err != nil {
+			t.Fatalf("SequenceBatch()=(_,%v), want (_,nil)", err)
+		}
+	}
+	root := latestRoot(ctx, t, ls, logID)
+
+	sth := crypto.STHFromRoot(root)
+	if err := crypto.VerifySTH(keyManager.Public(), sth, root.Signature, crypto.STHVersion1); err != nil {
+		t.Errorf("VerifySTH()=%v, want nil", err)
+	}
+	if got, want := sth.TreeSize, int64(1); got != want {
+		t.Errorf("STH.TreeSize=%d, want %d", got, want)
+	}
+	if got, want := sth.Time(), timeSource.Now(); !got.Equal(want) {
+		t.Errorf("STH.Time()=%v, want the time of the second batch %v", got, want)
+	}
+
+	// The signature covers every field of the STH.
+	for _, modify := range []func(*crypto.STH){
+		func(s *crypto.STH) { s.TreeSize++ },
+		func(s *crypto.STH) { s.RootHash = []byte("other") },
+		func(s *crypto.STH) { s.TimestampNanos++ },
+	} {
+		changed := sth
+		modify(&changed)
+		if err := crypto.VerifySTH(keyManager.Public(), changed, root.Signature, crypto.STHVersion1); !errors.Is(err, crypto.ErrVerifyFailed) {
+			t.Errorf("VerifySTH(%+v)=%v, want %v", changed, err, crypto.ErrVerifyFailed)
+		}
+	}
+}
+
+func TestSequenceBatchNodeCache(t *testing.T) {
+	keyManager := newTestKeyManager(t)
+
+	// The same leaves are sequenced into two logs, one through a cache that is too small
+	// to hold every node, and the roots of the two logs must always match.
+	var logs [2]*memory.LogStorage
+	var logIDs [2]int64
+	for i := range logs {
+		logs[i], logIDs[i] = newMemoryLog(t)
+	}
+	nodeCache := cache.NewNodeCache(4)
+	timeSource := &util.FakeTimeSource{FakeTime: fakeTimeForTest}
+
+	next := 0
+	for batch, size := range []int{1, 3, 0, 5, 8, 2, 13, 1, 7} {
+		var roots [2][]byte
+		for i, ls := range logs {
+			ctx := util.NewLogContext(context.Background(), logIDs[i])
+			queueLeaves(t, ls, ctx, logIDs[i], testLeaves(next, size), timeSource.FakeTime)
+
+			sequencer := NewSequencer(testonly.Hasher, timeSource, ls, keyManager)
+			if i == 1 {
+				sequencer.SetNodeCache(nodeCache)
+			}
+			res, err := sequencer.SequenceBatch(ctx, logIDs[i], size)
+			if err != nil {
+				t.Fatalf("batch %d: SequenceBatch()=(_,%v), want (_,nil)", batch, err)
+			}
+			roots[i] = res.RootHash
+		}
+		if !bytes.Equal(roots[0], roots[1]) {
+			t.Errorf("batch %d: root with cache %x, want %x", batch, roots[1], roots[0])
+		}
+		next += size
+		timeSource.FakeTime = timeSource.FakeTime.Add(time.Second)
+	}
+}
+
+func TestRotateSigner(t *testing.T) {
+	var keys [2]*ecdsa.PrivateKey
+	var keyManagers [2]crypto.PrivateKeyManager
+	var keyIDs [2][]byte
+	for i := range keys {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKey()=(_,%v), want (_,nil)", err)
+		}
+		keys[i] = key
+		if keyManagers[i], err = crypto.NewFromPrivateKey(key); err != nil {
+			t.Fatalf("NewFromPrivateKey()=(_,%v), want (_,nil)", err)
+		}
+		if keyIDs[i], err = crypto.KeyID(key.Public()); err != nil {
+			t.Fatalf("KeyID()=(_,%v), want (_,nil)", err)
+		}
+	}
+
+	ls, logID := newMemoryLog(t)
+	ctx := util.NewLogContext(context.Background(), logID)
+	timeSource := &util.FakeTimeSource{FakeTime: fakeTimeForTest}
+	sequencer := NewSequencer(testonly.Hasher, timeSource, ls, keyManagers[0])
+
+	// Batches are signed with key A, then with key B once it replaces A.
+	for batch, signer := range []int{0, 0, 1, 1} {
+		if batch == 2 {
+			sequencer.RotateSigner(keyManagers[1])
+		}
+		queueLeaves(t, ls, ctx, logID, testLeaves(batch, 1), timeSource.FakeTime)
+		if _, err := sequencer.SequenceBatch(ctx, logID, 1); err != nil {
+			t.Fatalf("batch %d: SequenceBatch()=(_,%v), want (_,nil)", batch, err)
+		}
+
+		tx, err := ls.BeginForTree(ctx, logID)
+		if err != nil {
+			t.Fatalf("BeginForTree()=(_,%v), want (_,nil)", err)
+		}
+		root, err := tx.LatestSignedLogRoot()
+		if err != nil {
+			t.Fatalf("LatestSignedLogRoot()=(_,%v), want (_,nil)", err)
+		}
+		tx.Close()
+		if got, want := root.KeyId, keyIDs[signer]; !bytes.Equal(got, want) {
+			t.Errorf("batch %d: KeyId=%x, want %x", batch, got, want)
+		}
+		for i, key := range keys {
+			err := crypto.VerifySTH(key.Public(), crypto.STHFromRoot(root), root.Signature, crypto.STHVersion1)
+			if got, want := err == nil, i == signer; got != want {
+				t.Errorf("batch %d: VerifySTH(key %d)=%v, want verified: %v", batch, i, err, want)
+			}
+		}
+		timeSource.FakeTime = timeSource.FakeTime.Add(time.Second)
+	}
+}
+
+func TestSequenceBatchConcurrentSequencers(t *testing.T) {
+	ls, logID, ctx, keyManager := newMemoryLogForTest(t)
+	timeSource := &tickingTimeSource{now: fakeTimeForTest}
+
+	const numLeaves = 4
+	leaves := testLeaves(0, numLeaves)
+	queueLeaves(t, ls, ctx, logID, leaves, fakeTimeForTest)
+	if err := NewSequencer(testonly.Hasher, timeSource, ls, keyManager).SignRoot(ctx, logID); err != nil {
+		t.Fatalf("SignRoot()=%v, want nil", err)
+	}
+
+	// The first sequencer reads the tree and assigns indices, then waits while the second
+	// sequences the same part of the tree. Its batch must then be retried at the new size.
+	paused, resume := make(chan struct{}), make(chan struct{})
+	racing := &pausingLogStorage{LogStorage: ls, pause: func() {
+		close(paused)
+		<-resume
+	}}
+	first := NewSequencer(testonly.Hasher, timeSource, racing, keyManager)
+	first.SetRetryPolicy(storage.RetryPolicy{MaxAttempts: 3})
+	second := NewSequencer(testonly.Hasher, timeSource, ls, keyManager)
+
+	var firstRes SequenceResult
+	var firstErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		firstRes, firstErr = first.SequenceBatch(ctx, logID, 2)
+	}()
+	<-paused
+	secondRes, err := second.SequenceBatch(ctx, logID, 2)
+	close(resume)
+	<-done
+	if err != nil {
+		t.Fatalf("second SequenceBatch()=(_,%v), want (_,nil)", err)
+	}
+	if firstErr != nil {
+		t.Fatalf("first SequenceBatch()=(_,%v), want (_,nil)", firstErr)
+	}
+	if got, want := firstRes.LeafCount+secondRes.LeafCount, numLeaves; got != want {
+		t.Errorf("SequenceBatch() integrated %d leaves, want %d", got, want)
+	}
+	if got, want := firstRes.TreeSize, int64(numLeaves); got != want {
+		t.Errorf("first SequenceBatch().TreeSize=%d, want %d", got, want)
+	}
+
+	tx, err := ls.BeginForTree(ctx, logID)
+	if err != nil {
+		t.Fatalf("BeginForTree()=(_,%v), want (_,nil)", err)
+	}
+	defer tx.Close()
+	if got, err := tx.GetSequencedLeafCount(); err != nil || got != numLeaves {
+		t.Errorf("GetSequencedLeafCount()=(%d,%v), want (%d,nil)", got, err, numLeaves)
+	}
+	sequenced, err := tx.GetLeavesByIndex([]int64{0, 1, 2, 3})
+	if err != nil {
+		t.Fatalf("GetLeavesByIndex()=(_,%v), want (_,nil)", err)
+	}
+	seen := make(map[string]bool)
+	for _, leaf := range sequenced {
+		if seen[string(leaf.LeafIdentityHash)] {
+			t.Errorf("leaf %x sequenced more than once", leaf.LeafIdentityHash)
+		}
+		seen[string(leaf.LeafIdentityHash)] = true
+	}
+	if len(seen) != numLeaves {
+		t.Errorf("GetLeavesByIndex() returned %d distinct leaves, want %d", len(seen), numLeaves)
+	}
+}
+
+func TestCheckLeafIndices(t *testing.T) {
+	for _, test := range []struct {
+		indices  []int64
+		treeSize int64
+		wantErr  bool
+	}{
+		{indices: nil, treeSize: 5},
+		{indices: []int64{5, 6, 7}, treeSize: 5},
+		{indices: []int64{4, 5}, treeSize: 5, wantErr: true},
+		{indices: []int64{5, 5}, treeSize: 5, wantErr: true},
+		{indices: []int64{5, 7}, treeSize: 5, wantErr: true},
+	} {
+		var leaves []*trillian.LogLeaf
+		for _, index := range test.indices {
+			leaves = append(leaves, &trillian.LogLeaf{LeafIndex: index})
+		}
+		err := checkLeafIndices(leaves, test.treeSize)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("checkLeafIndices(%v, %d)=%v, want err: %v", test.indices, test.treeSize, err, test.wantErr)
+		}
+		if err != nil && !storage.IsTransient(err) {
+			t.Errorf("checkLeafIndices(%v, %d)=%v, want transient error", test.indices, test.treeSize, err)
+		}
+	}
+}
+
+// tickingTimeSource returns a time a millisecond later than the last on each call, so
+// that each root has a later timestamp. It is safe for concurrent use.
+type tickingTimeSource struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (ts *tickingTimeSource) Now() time.Time {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.now = ts.now.Add(time.Millisecond)
+	return ts.now
+}
+
+// pausingLogStorage runs pause, once, when one of its transactions first writes
+// sequenced leaves.
+type pausingLogStorage struct {
+	storage.LogStorage
+	once  sync.Once
+	pause func()
+}
+
+func (s *pausingLogStorage) BeginForTree(ctx context.Context, treeID int64) (storage.LogTreeTX, error) {
+	tx, err := s.LogStorage.BeginForTree(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+	return &pausingLogTreeTX{LogTreeTX: tx, s: s}, nil
+}
+
+type pausingLogTreeTX struct {
+	storage.LogTreeTX
+	s *pausingLogStorage
+}
+
+func (t *pausingLogTreeTX) UpdateSequencedLeaves(leaves []*trillian.LogLeaf) error {
+	t.s.once.Do(t.s.pause)
+	return t.LogTreeTX.UpdateSequencedLeaves(leaves)
+}
+
+// countingLogStorage counts the Merkle nodes read by transactions on the storage it wraps.
+type countingLogStorage struct {
+	storage.LogStorage
+	reads int
+}
+
+func (s *countingLogStorage) BeginForTree(ctx context.Context, treeID int64) (storage.LogTreeTX, error) {
+	tx, err := s.LogStorage.BeginForTree(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+	return countingLogTreeTX{LogTreeTX: tx, reads: &s.reads}, nil
+}
+
+type countingLogTreeTX struct {
+	storage.LogTreeTX
+	reads *int
+}
+
+func (t countingLogTreeTX) GetMerkleNodes(treeRevision int64, ids []storage.NodeID) ([]storage.Node, error) {
+	*t.reads += len(ids)
+	return t.LogTreeTX.GetMerkleNodes(treeRevision, ids)
+}
+
+func BenchmarkSequenceBatchNodeCache(b *testing.B) {
+	keyManager := newTestKeyManager(b)
+	const batchSize = 16
+
+	for _, cacheSize := range []int{0, 1024} {
+		b.Run(fmt.Sprintf("cache=%d", cacheSize), func(b *testing.B) {
+			ms, logID := newMemoryLog(b)
+			ctx := util.NewLogContext(context.Background(), logID)
+			ls := &countingLogStorage{LogStorage: ms}
+			timeSource := &util.FakeTimeSource{FakeTime: fakeTimeForTest}
+			var nodeCache *cache.NodeCache
+			if cacheSize > 0 {
+				nodeCache = cache.NewNodeCache(cacheSize)
+			}
+
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				queueLeaves(b, ms, ctx, logID, testLeaves(i*batchSize, batchSize), timeSource.FakeTime)
+				timeSource.FakeTime = timeSource.FakeTime.Add(time.Millisecond)
+				b.StartTimer()
+
+				sequencer := NewSequencer(testonly.Hasher, timeSource, ls, keyManager)
+				if nodeCache != nil {
+					sequencer.SetNodeCache(nodeCache)
+				}
+				if _, err := sequencer.SequenceBatch(ctx, logID, batchSize); err != nil {
+					b.Fatalf("SequenceBatch()=(_,%v), want (_,nil)", err)
+				}
+			}
+			b.ReportMetric(float64(ls.reads)/float64(b.N), "nodereads/op")
+		})
 	}
 }
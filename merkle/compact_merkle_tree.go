@@ -19,6 +19,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"sync"
 
 	log "github.com/golang/glog"
 )
@@ -235,6 +236,92 @@ func (c *CompactMerkleTree) AddLeafHash(leafHash []byte, f setNodeFunc) (assigne
 	return
 }
 
+// minParallelHashes is the smallest number of node hashes in one level of the tree that
+// AddLeafHashes will split between goroutines. Smaller levels are hashed serially as the
+// overhead of starting goroutines outweighs the work.
+const minParallelHashes = 256
+
+// AddLeafHashes adds the specified |leafHashes| to the tree, giving the same tree state and
+// root as calling AddLeafHash for each of them in turn, and returns the sequence number
+// assigned to the first of them. The internal nodes completed by the new leaves are
+// hashed a level at a time, with the hashes of each level shared between up to |workers|
+// goroutines.
+// |f| is called, only from the calling goroutine, with the full MerkleTree coordinates of
+// nodes whose hash should be updated. Each node is reported once with its final hash,
+// rather than in the order AddLeafHash would report them.
+func (c *CompactMerkleTree) AddLeafHashes(leafHashes [][]byte, workers int, f setNodeFunc) (firstSeq int64) {
+	firstSeq = c.size
+	if len(leafHashes) == 0 {
+		return
+	}
+	oldSize := c.size
+	newSize := oldSize + int64(len(leafHashes))
+	nodes := make([][]byte, bitLen(newSize))
+
+	for i, h := range leafHashes {
+		f(0, oldSize+int64(i), h)
+	}
+
+	// row holds the hashes of the nodes at depth whose indices are in [first, last). These
+	// are the complete nodes at that depth that cover at least one new leaf. The node just
+	// before first, if needed, is the dangling left-hand node at that depth in c.nodes.
+	row := leafHashes
+	for depth := 0; depth < len(nodes); depth++ {
+		first := oldSize >> uint(depth)
+		last := newSize >> uint(depth)
+		if last&1 == 1 {
+			if last-1 >= first {
+				nodes[depth] = row[last-1-first]
+			} else {
+				nodes[depth] = c.nodes[depth]
+			}
+		}
+
+		parentFirst := oldSize >> uint(depth+1)
+		parents := make([][]byte, (newSize>>uint(depth+1))-parentFirst)
+		child := func(index int64) []byte {
+			if index < first {
+				return c.nodes[depth]
+			}
+			return row[index-first]
+		}
+		hashRange := func(from, to int) {
+			for i := from; i < to; i++ {
+				index := 2 * (parentFirst + int64(i))
+				parents[i] = c.hasher.HashChildren(child(index), child(index+1))
+			}
+		}
+		if workers <= 1 || len(parents) < minParallelHashes {
+			hashRange(0, len(parents))
+		} else {
+			chunk := (len(parents) + workers - 1) / workers
+			var wg sync.WaitGroup
+			for from := 0; from < len(parents); from += chunk {
+				to := from + chunk
+				if to > len(parents) {
+					to = len(parents)
+				}
+				wg.Add(1)
+				go func(from, to int) {
+					defer wg.Done()
+					hashRange(from, to)
+				}(from, to)
+			}
+			wg.Wait()
+		}
+
+		for i, h := range parents {
+			f(depth+1, parentFirst+int64(i), h)
+		}
+		row = parents
+	}
+
+	c.nodes = nodes
+	c.size = newSize
+	c.recalculateRoot(f)
+	return
+}
+
 // Size returns the current size of the tree, that is, the number of leaves ever added to the tree.
 func (c CompactMerkleTree) Size() int64 {
 	return c.size
@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"runtime"
 	"strings"
 	"testing"
 
@@ -275,3 +276,125 @@ func TestRootHashForVariousTreeSizes(t *testing.T) {
 		}
 	}
 }
+
+// recordNodes returns a setNodeFunc that stores the hashes it is given in nodes.
+func recordNodes(t *testing.T, nodes map[string][]byte) setNodeFunc {
+	return func(depth int, index int64, hash []byte) {
+		k, err := nodeKey(depth, index)
+		if err != nil {
+			t.Fatalf("failed to create nodeID: %v", err)
+		}
+		nodes[k] = hash
+	}
+}
+
+// leafHashes returns the leaf hashes of count leaves, starting with leaf number start.
+func leafHashes(start int64, count int) [][]byte {
+	hashes := make([][]byte, count)
+	for i := range hashes {
+		hashes[i] = testonly.Hasher.HashLeaf([]byte(fmt.Sprintf("Leaf %d", start+int64(i))))
+	}
+	return hashes
+}
+
+func TestAddLeafHashesMatchesAddLeafHash(t *testing.T) {
+	for _, startSize := range []int64{0, 1, 2, 3, 5, 8, 13, 64, 255, 1000} {
+		for _, batchSize := range []int{1, 2, 3, 7, 16, 100, 1025, 5000} {
+			for _, workers := range []int{1, 4} {
+				desc := fmt.Sprintf("size %d + %d leaves, %d workers", startSize, batchSize, workers)
+
+				// Build the starting tree, keeping its nodes so that the tree can be
+				// reloaded from storage as the sequencer does.
+				start := NewCompactMerkleTree(testonly.Hasher)
+				startNodes := make(map[string][]byte)
+				for _, h := range leafHashes(0, int(startSize)) {
+					start.AddLeafHash(h, recordNodes(t, startNodes))
+				}
+				load := func() *CompactMerkleTree {
+					cmt, err := NewCompactMerkleTreeWithState(testonly.Hasher, startSize, func(depth int, index int64) ([]byte, error) {
+						k, err := nodeKey(depth, index)
+						if err != nil {
+							return nil, err
+						}
+						return startNodes[k], nil
+					}, start.CurrentRoot())
+					if err != nil {
+						t.Fatalf("%v: NewCompactMerkleTreeWithState()=_,%v, want nil", desc, err)
+					}
+					return cmt
+				}
+
+				hashes := leafHashes(startSize, batchSize)
+				serial, parallel := load(), load()
+				serialNodes, parallelNodes := make(map[string][]byte), make(map[string][]byte)
+				for i, h := range hashes {
+					if got, want := serial.AddLeafHash(h, recordNodes(t, serialNodes)), startSize+int64(i); got != want {
+						t.Fatalf("%v: AddLeafHash()=%d, want %d", desc, got, want)
+					}
+				}
+				if got, want := parallel.AddLeafHashes(hashes, workers, recordNodes(t, parallelNodes)), startSize; got != want {
+					t.Errorf("%v: AddLeafHashes()=%d, want %d", desc, got, want)
+				}
+
+				if got, want := parallel.CurrentRoot(), serial.CurrentRoot(); !bytes.Equal(got, want) {
+					t.Errorf("%v: AddLeafHashes() root=%x, want %x", desc, got, want)
+				}
+				if got, want := parallel.Size(), serial.Size(); got != want {
+					t.Errorf("%v: AddLeafHashes() size=%d, want %d", desc, got, want)
+				}
+				if got, want := parallel.Hashes(), serial.Hashes(); !reflect.DeepEqual(got, want) {
+					t.Errorf("%v: AddLeafHashes() hashes=%x, want %x", desc, got, want)
+				}
+				if err := checkUnusedNodesInvariant(parallel); err != nil {
+					t.Errorf("%v: %v", desc, err)
+				}
+				if !reflect.DeepEqual(parallelNodes, serialNodes) {
+					t.Errorf("%v: AddLeafHashes() set %d nodes, want the %d set by AddLeafHash", desc, len(parallelNodes), len(serialNodes))
+				}
+
+				// Adding more leaves afterwards must carry on from the same state.
+				next := leafHashes(startSize+int64(batchSize), 3)
+				for _, h := range next {
+					serial.AddLeafHash(h, func(int, int64, []byte) {})
+				}
+				parallel.AddLeafHashes(next, workers, func(int, int64, []byte) {})
+				if got, want := parallel.CurrentRoot(), serial.CurrentRoot(); !bytes.Equal(got, want) {
+					t.Errorf("%v: root after further leaves=%x, want %x", desc, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestAddLeafHashesEmpty(t *testing.T) {
+	cmt := NewCompactMerkleTree(testonly.Hasher)
+	cmt.AddLeafHashes(leafHashes(0, 5), 2, func(int, int64, []byte) {})
+	root := cmt.CurrentRoot()
+	if got, want := cmt.AddLeafHashes(nil, 2, func(int, int64, []byte) { t.Error("AddLeafHashes(nil) set a node") }), int64(5); got != want {
+		t.Errorf("AddLeafHashes(nil)=%d, want %d", got, want)
+	}
+	if got := cmt.CurrentRoot(); !bytes.Equal(got, root) {
+		t.Errorf("AddLeafHashes(nil) changed root to %x, want %x", got, root)
+	}
+}
+
+func benchmarkAddLeafHashes(b *testing.B, workers int) {
+	hashes := leafHashes(0, 100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cmt := NewCompactMerkleTree(testonly.Hasher)
+		if workers == 0 {
+			for _, h := range hashes {
+				cmt.AddLeafHash(h, func(int, int64, []byte) {})
+			}
+		} else {
+			cmt.AddLeafHashes(hashes, workers, func(int, int64, []byte) {})
+		}
+	}
+}
+
+func BenchmarkAddLeafHashSerial(b *testing.B) { benchmarkAddLeafHashes(b, 0) }
+
+func BenchmarkAddLeafHashes1(b *testing.B) { benchmarkAddLeafHashes(b, 1) }
+
+func BenchmarkAddLeafHashesNumCPU(b *testing.B) { benchmarkAddLeafHashes(b, runtime.NumCPU()) }
@@ -0,0 +1,45 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plain
+
+import "crypto"
+
+// TreeHasher implements Merkle tree hashing without domain separation: leaves are
+// hashed as they are, and internal nodes as the concatenation of their children. It
+// suits trees that must interoperate with formats that do not use RFC6962 prefixes.
+type TreeHasher struct {
+	crypto.Hash
+}
+
+// HashEmpty returns the hash of an empty element for the tree
+func (t TreeHasher) HashEmpty() []byte {
+	return t.New().Sum(nil)
+}
+
+// HashLeaf returns the Merkle tree leaf hash of the data passed in through leaf.
+func (t TreeHasher) HashLeaf(leaf []byte) []byte {
+	h := t.New()
+	h.Write(leaf)
+	return h.Sum(nil)
+}
+
+// HashChildren returns the inner Merkle tree node hash of the the two child nodes l and r.
+// The hashed structure is l||r.
+func (t TreeHasher) HashChildren(l, r []byte) []byte {
+	h := t.New()
+	h.Write(l)
+	h.Write(r)
+	return h.Sum(nil)
+}
@@ -17,13 +17,24 @@ package merkle
 import (
 	"crypto"
 	"fmt"
+	"reflect"
 
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle/plain"
 	"github.com/google/trillian/merkle/rfc6962"
+	// Register SHA3-256 with crypto.Hash for the RFC6962SHA3Type hasher.
+	_ "golang.org/x/crypto/sha3"
 )
 
 const (
 	// RFC6962SHA256Type is the string used to retrieve the RFC6962 hasher.
 	RFC6962SHA256Type = "RFC6962-SHA256"
+	// PlainSHA256Type is the string used to retrieve the hasher that uses SHA256 without
+	// RFC6962 domain separation prefixes.
+	PlainSHA256Type = "PLAIN-SHA256"
+	// RFC6962SHA3Type is the string used to retrieve the RFC6962 hasher using SHA3-256
+	// rather than SHA256.
+	RFC6962SHA3Type = "RFC6962-SHA3-256"
 )
 
 // TreeHasher is the interface that the previous tree hasher struct implemented.
@@ -35,8 +46,26 @@ type TreeHasher interface {
 	Size() int
 }
 
+// ExtraDataHasher is implemented by tree hashers whose leaf hashes also cover the
+// extra data stored with a log leaf. For other hashers the extra data is opaque to
+// the tree and only the leaf value is hashed.
+type ExtraDataHasher interface {
+	HashLeafWithExtraData(leaf, extraData []byte) []byte
+}
+
+// HashLeafData returns the Merkle leaf hash, computed with th, of a leaf with the
+// given value and extra data. The extra data is only hashed if th is an ExtraDataHasher.
+func HashLeafData(th TreeHasher, leaf, extraData []byte) []byte {
+	if eh, ok := th.(ExtraDataHasher); ok {
+		return eh.HashLeafWithExtraData(leaf, extraData)
+	}
+	return th.HashLeaf(leaf)
+}
+
 var hashTypes = map[string]TreeHasher{
 	RFC6962SHA256Type: rfc6962.TreeHasher{Hash: crypto.SHA256},
+	PlainSHA256Type:   plain.TreeHasher{Hash: crypto.SHA256},
+	RFC6962SHA3Type:   rfc6962.TreeHasher{Hash: crypto.SHA3_256},
 }
 
 // Factory supports fetching custom hashers based on tree types.
@@ -47,3 +76,34 @@ func Factory(hashType string) (TreeHasher, error) {
 	}
 	return h, nil
 }
+
+// hashStrategyTypes maps the hash strategies that can be recorded for a tree to the
+// type of the hasher they stand for.
+var hashStrategyTypes = map[trillian.HashStrategy]string{
+	trillian.HashStrategy_RFC_6962:          RFC6962SHA256Type,
+	trillian.HashStrategy_PLAIN_SHA256:      PlainSHA256Type,
+	trillian.HashStrategy_RFC_6962_SHA3_256: RFC6962SHA3Type,
+}
+
+// HasherForStrategy returns the hasher for trees recorded with the hash strategy hs.
+func HasherForStrategy(hs trillian.HashStrategy) (TreeHasher, error) {
+	hashType, ok := hashStrategyTypes[hs]
+	if !ok {
+		return nil, fmt.Errorf("no hasher for hash strategy %v", hs)
+	}
+	return Factory(hashType)
+}
+
+// CheckHasherForStrategy returns an error unless th is the hasher for trees recorded
+// with the hash strategy hs. Storage uses it to refuse trees built with another hasher
+// than the one it is configured with, whose nodes it would otherwise corrupt.
+func CheckHasherForStrategy(th TreeHasher, hs trillian.HashStrategy) error {
+	want, err := HasherForStrategy(hs)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(th, want) {
+		return fmt.Errorf("tree has hash strategy %v, which does not match the configured hasher", hs)
+	}
+	return nil
+}
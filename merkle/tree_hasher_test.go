@@ -16,8 +16,10 @@ package merkle
 
 import (
 	"bytes"
+	"encoding/hex"
 	"testing"
 
+	"github.com/google/trillian"
 	"github.com/google/trillian/testonly"
 )
 
@@ -28,6 +30,10 @@ const (
 	rfc6962LeafL123456HashHex = "395aa064aa4c29f7010acfe3f25db9485bbd4b91897b6ad7ad547639252b4d56"
 	// echo -n 014E3132334E343536 | xxd -r -p | sha256sum
 	rfc6962NodeN123N456HashHex = "aa217fe888e47007fa15edab33c2b492a722cb106c64667fc2b044444de66bbb"
+	// echo -n L123456 | sha256sum
+	plainLeafL123456HashHex = "c95293e88128acf26ed51ff89b789ff6e139674aae80b812e0018e3d540277ef"
+	// echo -n N123N456 | sha256sum
+	plainNodeN123N456HashHex = "70fe3d0d2ed50e4c42a17c70c142ddeea8d3c62ecd5106a07b013a9b921eee36"
 )
 
 func ensureHashMatches(expected, actual []byte, testCase string, t *testing.T) {
@@ -43,3 +49,101 @@ func TestRfc6962Hasher(t *testing.T) {
 	ensureHashMatches(testonly.MustHexDecode(rfc6962LeafL123456HashHex), hasher.HashLeaf([]byte("L123456")), "RFC6962 Leaf", t)
 	ensureHashMatches(testonly.MustHexDecode(rfc6962NodeN123N456HashHex), hasher.HashChildren([]byte("N123"), []byte("N456")), "RFC6962 Node", t)
 }
+
+func TestPlainHasher(t *testing.T) {
+	hasher, err := Factory(PlainSHA256Type)
+	if err != nil {
+		t.Fatalf("Factory(%v)=(_,%v), want (_,nil)", PlainSHA256Type, err)
+	}
+
+	ensureHashMatches(testonly.MustHexDecode(rfc6962EmptyHashHex), hasher.HashEmpty(), "Plain Empty", t)
+	ensureHashMatches(testonly.MustHexDecode(plainLeafL123456HashHex), hasher.HashLeaf([]byte("L123456")), "Plain Leaf", t)
+	ensureHashMatches(testonly.MustHexDecode(plainNodeN123N456HashHex), hasher.HashChildren([]byte("N123"), []byte("N456")), "Plain Node", t)
+}
+
+func TestHasherRoots(t *testing.T) {
+	leaves := []string{"one", "two", "three", "four", "five"}
+	for _, test := range []struct {
+		hashType string
+		wantRoot string
+	}{
+		{hashType: RFC6962SHA256Type, wantRoot: "832e609776a4b05ca208ef796bfd6f2d7cfcf00ddd46afb497d4b1b5ef19a994"},
+		{hashType: PlainSHA256Type, wantRoot: "5516a9cc87262e152d7e9c615f95ca932df9dd2db24307e1b53a680d9c208703"},
+		{hashType: RFC6962SHA3Type, wantRoot: "be08ddc001a6ca56a78ca910b7c9978158c1e27d92f85cc73b87aa2f4dc5e008"},
+	} {
+		hasher, err := Factory(test.hashType)
+		if err != nil {
+			t.Fatalf("Factory(%v)=(_,%v), want (_,nil)", test.hashType, err)
+		}
+		compact := NewCompactMerkleTree(hasher)
+		inMemory := NewInMemoryMerkleTree(hasher)
+		for _, leaf := range leaves {
+			compact.AddLeaf([]byte(leaf), func(int, int64, []byte) {})
+			inMemory.AddLeaf([]byte(leaf))
+		}
+		if got := hex.EncodeToString(compact.CurrentRoot()); got != test.wantRoot {
+			t.Errorf("%v: CompactMerkleTree root=%v, want %v", test.hashType, got, test.wantRoot)
+		}
+		if got := hex.EncodeToString(inMemory.CurrentRoot().Hash()); got != test.wantRoot {
+			t.Errorf("%v: InMemoryMerkleTree root=%v, want %v", test.hashType, got, test.wantRoot)
+		}
+	}
+}
+
+func TestFactoryUnknown(t *testing.T) {
+	if h, err := Factory("unknown"); err == nil {
+		t.Errorf("Factory(unknown)=(%v,nil), want (_,err)", h)
+	}
+}
+
+func TestHasherForStrategy(t *testing.T) {
+	th, err := HasherForStrategy(trillian.HashStrategy_RFC_6962)
+	if err != nil {
+		t.Fatalf("HasherForStrategy(RFC_6962)=_,%v", err)
+	}
+	if err := CheckHasherForStrategy(th, trillian.HashStrategy_RFC_6962); err != nil {
+		t.Errorf("CheckHasherForStrategy(RFC_6962 hasher, RFC_6962)=%v, want nil", err)
+	}
+	if h, err := HasherForStrategy(trillian.HashStrategy_UNKNOWN_HASH_STRATEGY); err == nil {
+		t.Errorf("HasherForStrategy(UNKNOWN_HASH_STRATEGY)=(%v,nil), want (_,err)", h)
+	}
+
+	for _, test := range []struct {
+		hashType string
+		hs       trillian.HashStrategy
+	}{
+		{hashType: PlainSHA256Type, hs: trillian.HashStrategy_PLAIN_SHA256},
+		{hashType: RFC6962SHA3Type, hs: trillian.HashStrategy_RFC_6962_SHA3_256},
+	} {
+		th, err := Factory(test.hashType)
+		if err != nil {
+			t.Fatalf("Factory(%v)=_,%v", test.hashType, err)
+		}
+		if err := CheckHasherForStrategy(th, test.hs); err != nil {
+			t.Errorf("CheckHasherForStrategy(%v hasher, %v)=%v, want nil", test.hashType, test.hs, err)
+		}
+		if err := CheckHasherForStrategy(th, trillian.HashStrategy_RFC_6962); err == nil {
+			t.Errorf("CheckHasherForStrategy(%v hasher, RFC_6962)=nil, want err", test.hashType)
+		}
+	}
+}
+
+// extraDataHasher is a TreeHasher whose leaf hashes also cover extra data.
+type extraDataHasher struct {
+	TreeHasher
+}
+
+func (h extraDataHasher) HashLeafWithExtraData(leaf, extraData []byte) []byte {
+	return h.HashLeaf(append(append([]byte{}, leaf...), extraData...))
+}
+
+func TestHashLeafData(t *testing.T) {
+	leaf, extra := []byte("L123"), []byte("456")
+	if got, want := HashLeafData(testonly.Hasher, leaf, extra), testonly.Hasher.HashLeaf(leaf); !bytes.Equal(got, want) {
+		t.Errorf("HashLeafData(RFC6962)=%x, want %x, the hash of the leaf alone", got, want)
+	}
+	hasher := extraDataHasher{testonly.Hasher}
+	if got, want := HashLeafData(hasher, leaf, extra), testonly.MustHexDecode(rfc6962LeafL123456HashHex); !bytes.Equal(got, want) {
+		t.Errorf("HashLeafData(ExtraDataHasher)=%x, want %x, the hash of the leaf and extra data", got, want)
+	}
+}
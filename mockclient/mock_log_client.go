@@ -5,7 +5,6 @@ package mockclient
 
 import (
 	gomock "github.com/golang/mock/gomock"
-	empty "github.com/golang/protobuf/ptypes/empty"
 	trillian "github.com/google/trillian"
 	context "golang.org/x/net/context"
 	grpc "google.golang.org/grpc"
@@ -160,13 +159,13 @@ func (_mr *_MockTrillianLogClientRecorder) GetSequencedLeafCount(arg0, arg1 inte
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSequencedLeafCount", _s...)
 }
 
-func (_m *MockTrillianLogClient) QueueLeaf(_param0 context.Context, _param1 *trillian.QueueLeafRequest, _param2 ...grpc.CallOption) (*empty.Empty, error) {
+func (_m *MockTrillianLogClient) QueueLeaf(_param0 context.Context, _param1 *trillian.QueueLeafRequest, _param2 ...grpc.CallOption) (*trillian.QueueLeafResponse, error) {
 	_s := []interface{}{_param0, _param1}
 	for _, _x := range _param2 {
 		_s = append(_s, _x)
 	}
 	ret := _m.ctrl.Call(_m, "QueueLeaf", _s...)
-	ret0, _ := ret[0].(*empty.Empty)
+	ret0, _ := ret[0].(*trillian.QueueLeafResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -301,9 +300,9 @@ func (_mr *_MockTrillianLogServerRecorder) GetSequencedLeafCount(arg0, arg1 inte
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSequencedLeafCount", arg0, arg1)
 }
 
-func (_m *MockTrillianLogServer) QueueLeaf(_param0 context.Context, _param1 *trillian.QueueLeafRequest) (*empty.Empty, error) {
+func (_m *MockTrillianLogServer) QueueLeaf(_param0 context.Context, _param1 *trillian.QueueLeafRequest) (*trillian.QueueLeafResponse, error) {
 	ret := _m.ctrl.Call(_m, "QueueLeaf", _param0, _param1)
-	ret0, _ := ret[0].(*empty.Empty)
+	ret0, _ := ret[0].(*trillian.QueueLeafResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -0,0 +1,42 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+// Labels holds the values a metric is recorded with, keyed by label name.
+type Labels map[string]string
+
+// Metrics records named counters, gauges and histograms, so that components can export
+// metrics without depending on a particular monitoring system. Each metric should always
+// be recorded with the same set of label names.
+type Metrics interface {
+	// AddCounter adds delta, which must not be negative, to the counter name.
+	AddCounter(name string, labels Labels, delta float64)
+	// SetGauge sets the gauge name to value.
+	SetGauge(name string, labels Labels, value float64)
+	// ObserveHistogram records value in the distribution of the histogram name.
+	ObserveHistogram(name string, labels Labels, value float64)
+}
+
+// NoopMetrics discards everything recorded with it.
+type NoopMetrics struct{}
+
+// AddCounter does nothing.
+func (NoopMetrics) AddCounter(string, Labels, float64) {}
+
+// SetGauge does nothing.
+func (NoopMetrics) SetGauge(string, Labels, float64) {}
+
+// ObserveHistogram does nothing.
+func (NoopMetrics) ObserveHistogram(string, Labels, float64) {}
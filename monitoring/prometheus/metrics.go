@@ -0,0 +1,140 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package prometheus exports metrics recorded through monitoring.Metrics to Prometheus.
+package prometheus
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/monitoring"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics is a monitoring.Metrics that registers a Prometheus collector for each metric
+// the first time it is recorded. The label names of a metric are those it is first
+// recorded with; later values recorded with other label names are logged and dropped.
+type Metrics struct {
+	registerer prom.Registerer
+	help       map[string]string
+
+	// mu guards the maps of collectors.
+	mu         sync.Mutex
+	counters   map[string]*prom.CounterVec
+	gauges     map[string]*prom.GaugeVec
+	histograms map[string]*prom.HistogramVec
+}
+
+// NewMetrics creates a Metrics that registers its collectors with registerer, or with
+// the default Prometheus registerer if it is nil. The help text for each metric is taken
+// from help, keyed by metric name, and defaults to the name.
+func NewMetrics(registerer prom.Registerer, help map[string]string) *Metrics {
+	if registerer == nil {
+		registerer = prom.DefaultRegisterer
+	}
+	return &Metrics{
+		registerer: registerer,
+		help:       help,
+		counters:   make(map[string]*prom.CounterVec),
+		gauges:     make(map[string]*prom.GaugeVec),
+		histograms: make(map[string]*prom.HistogramVec),
+	}
+}
+
+// AddCounter adds delta to the Prometheus counter name.
+func (m *Metrics) AddCounter(name string, labels monitoring.Labels, delta float64) {
+	m.mu.Lock()
+	vec, ok := m.counters[name]
+	if !ok {
+		vec = prom.NewCounterVec(prom.CounterOpts{Name: name, Help: m.helpFor(name)}, labelNames(labels))
+		vec = m.register(vec).(*prom.CounterVec)
+		m.counters[name] = vec
+	}
+	m.mu.Unlock()
+	c, err := vec.GetMetricWith(prom.Labels(labels))
+	if err != nil {
+		glog.Warningf("Dropping value for counter %s: %v", name, err)
+		return
+	}
+	c.Add(delta)
+}
+
+// SetGauge sets the Prometheus gauge name to value.
+func (m *Metrics) SetGauge(name string, labels monitoring.Labels, value float64) {
+	m.mu.Lock()
+	vec, ok := m.gauges[name]
+	if !ok {
+		vec = prom.NewGaugeVec(prom.GaugeOpts{Name: name, Help: m.helpFor(name)}, labelNames(labels))
+		vec = m.register(vec).(*prom.GaugeVec)
+		m.gauges[name] = vec
+	}
+	m.mu.Unlock()
+	g, err := vec.GetMetricWith(prom.Labels(labels))
+	if err != nil {
+		glog.Warningf("Dropping value for gauge %s: %v", name, err)
+		return
+	}
+	g.Set(value)
+}
+
+// ObserveHistogram records value in the Prometheus histogram name, which uses the
+// default buckets.
+func (m *Metrics) ObserveHistogram(name string, labels monitoring.Labels, value float64) {
+	m.mu.Lock()
+	vec, ok := m.histograms[name]
+	if !ok {
+		vec = prom.NewHistogramVec(prom.HistogramOpts{Name: name, Help: m.helpFor(name), Buckets: prom.DefBuckets}, labelNames(labels))
+		vec = m.register(vec).(*prom.HistogramVec)
+		m.histograms[name] = vec
+	}
+	m.mu.Unlock()
+	h, err := vec.GetMetricWith(prom.Labels(labels))
+	if err != nil {
+		glog.Warningf("Dropping value for histogram %s: %v", name, err)
+		return
+	}
+	h.Observe(value)
+}
+
+func (m *Metrics) helpFor(name string) string {
+	if help, ok := m.help[name]; ok {
+		return help
+	}
+	return name
+}
+
+// register registers c and returns the collector to use, which is the one already
+// registered if an identical one was registered earlier, e.g. by another Metrics. If c
+// cannot be registered it is still returned, so values are recorded but not exported.
+func (m *Metrics) register(c prom.Collector) prom.Collector {
+	if err := m.registerer.Register(c); err != nil {
+		if are, ok := err.(prom.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		glog.Warningf("Failed to register metric: %v", err)
+	}
+	return c
+}
+
+// labelNames returns the names in labels, sorted.
+func labelNames(labels monitoring.Labels) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
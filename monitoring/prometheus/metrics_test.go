@@ -0,0 +1,107 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/google/trillian/monitoring"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+var _ monitoring.Metrics = &Metrics{}
+
+func TestMetrics(t *testing.T) {
+	reg := prom.NewRegistry()
+	m := NewMetrics(reg, map[string]string{"requests": "Requests handled."})
+	a, b := monitoring.Labels{"logid": "1"}, monitoring.Labels{"logid": "2"}
+	m.AddCounter("requests", a, 1)
+	m.AddCounter("requests", a, 2)
+	m.AddCounter("requests", b, 5)
+	m.SetGauge("queued", a, 7)
+	m.SetGauge("queued", a, 4)
+	m.ObserveHistogram("latency", a, 0.5)
+	m.ObserveHistogram("latency", a, 2)
+	// Values with the wrong label names are dropped.
+	m.AddCounter("requests", monitoring.Labels{"treeid": "1"}, 100)
+
+	for _, test := range []struct {
+		name, logID string
+		want        float64
+	}{
+		{name: "requests", logID: "1", want: 3},
+		{name: "requests", logID: "2", want: 5},
+		{name: "queued", logID: "1", want: 4},
+		{name: "latency", logID: "1", want: 2},
+		{name: "latency", logID: "2", want: 0},
+	} {
+		if got := gather(t, reg, test.name, test.logID); got != test.want {
+			t.Errorf("%s{logid=%s}=%v, want %v", test.name, test.logID, got, test.want)
+		}
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather()=(_,%v), want (_,nil)", err)
+	}
+	help := make(map[string]string)
+	for _, f := range families {
+		help[f.GetName()] = f.GetHelp()
+	}
+	if got, want := help["requests"], "Requests handled."; got != want {
+		t.Errorf("help for requests=%q, want %q", got, want)
+	}
+	if got, want := help["queued"], "queued"; got != want {
+		t.Errorf("help for queued=%q, want %q", got, want)
+	}
+}
+
+func TestMetricsShareRegistry(t *testing.T) {
+	reg := prom.NewRegistry()
+	NewMetrics(reg, nil).AddCounter("requests", monitoring.Labels{"logid": "1"}, 1)
+	NewMetrics(reg, nil).AddCounter("requests", monitoring.Labels{"logid": "1"}, 1)
+	if got, want := gather(t, reg, "requests", "1"), 2.0; got != want {
+		t.Errorf("requests{logid=1}=%v, want %v", got, want)
+	}
+}
+
+// gather returns the value of a counter or gauge, or the sample count of a histogram,
+// for the given log ID in reg.
+func gather(t *testing.T, reg *prom.Registry, name, logID string) float64 {
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather()=(_,%v), want (_,nil)", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() != "logid" || l.GetValue() != logID {
+					continue
+				}
+				switch {
+				case m.GetHistogram() != nil:
+					return float64(m.GetHistogram().GetSampleCount())
+				case m.GetGauge() != nil:
+					return m.GetGauge().GetValue()
+				}
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,138 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/client/backoff"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+)
+
+// startupPingPolicy retries the startup storage check until its timeout expires.
+var startupPingPolicy = storage.RetryPolicy{
+	MaxAttempts: math.MaxInt32,
+	Backoff: backoff.Backoff{
+		Min:    100 * time.Millisecond,
+		Max:    5 * time.Second,
+		Factor: 2,
+		Jitter: true,
+	},
+}
+
+// CheckStorageAtStartup checks that storage is accessible, retrying for up to timeout,
+// so that a server can fail at startup with a clear error rather than on its first query.
+// It uses the same check as HealthChecker.
+func CheckStorageAtStartup(ctx context.Context, checker storage.DatabaseChecker, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := storage.Ping(ctx, checker, startupPingPolicy); err != nil {
+		return fmt.Errorf("storage not accessible within %v: %v", timeout, err)
+	}
+	return nil
+}
+
+// HealthChecker serves liveness and readiness checks for a server that depends on
+// storage, for example for use as Kubernetes probes. The server is ready while the last
+// successful storage check is no older than the configured window.
+type HealthChecker struct {
+	checker    storage.DatabaseChecker
+	timeSource util.TimeSource
+	window     time.Duration
+
+	// mu guards the result of the most recent checks.
+	mu      sync.Mutex
+	lastOK  time.Time
+	lastErr error
+}
+
+// NewHealthChecker creates a HealthChecker that checks storage is accessible using
+// checker. The server is reported ready for window after each successful check.
+func NewHealthChecker(checker storage.DatabaseChecker, timeSource util.TimeSource, window time.Duration) *HealthChecker {
+	return &HealthChecker{
+		checker:    checker,
+		timeSource: timeSource,
+		window:     window,
+	}
+}
+
+// Check pings storage once and records the result.
+func (h *HealthChecker) Check(ctx context.Context) error {
+	err := h.checker.CheckDatabaseAccessible(ctx)
+	now := h.timeSource.Now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastErr = err
+	if err != nil {
+		glog.Warningf("Storage health check failed: %v", err)
+		return err
+	}
+	h.lastOK = now
+	return nil
+}
+
+// Run checks storage every interval until ctx is done.
+func (h *HealthChecker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		h.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Ready returns nil if the server is ready, or an error giving the reason it isn't.
+func (h *HealthChecker) Ready() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.lastErr != nil {
+		return fmt.Errorf("storage check failed: %v", h.lastErr)
+	}
+	if h.lastOK.IsZero() {
+		return fmt.Errorf("storage not checked yet")
+	}
+	if age := h.timeSource.Now().Sub(h.lastOK); age > h.window {
+		return fmt.Errorf("no successful storage check for %v", age)
+	}
+	return nil
+}
+
+// RegisterHandlers adds handlers for /healthz, which reports that the process is alive,
+// and /readyz, which reports whether the server is ready, to mux. Both respond with
+// 200 OK when healthy. /readyz responds with 503 Service Unavailable and the reason
+// when the server is not ready.
+func (h *HealthChecker) RegisterHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := h.Ready(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
@@ -0,0 +1,101 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/util"
+)
+
+// fakeDatabaseChecker is a storage.DatabaseChecker that returns err.
+type fakeDatabaseChecker struct {
+	err error
+}
+
+func (f *fakeDatabaseChecker) CheckDatabaseAccessible(ctx context.Context) error {
+	return f.err
+}
+
+func TestHealthChecker(t *testing.T) {
+	ctx := context.Background()
+	checker := &fakeDatabaseChecker{}
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	h := NewHealthChecker(checker, ts, time.Minute)
+	mux := http.NewServeMux()
+	h.RegisterHandlers(mux)
+
+	for _, test := range []struct {
+		desc       string
+		pingErr    error
+		check      bool
+		advance    time.Duration
+		wantStatus int
+		wantBody   string
+	}{
+		{desc: "not checked", wantStatus: http.StatusServiceUnavailable, wantBody: "not checked"},
+		{desc: "ping ok", check: true, wantStatus: http.StatusOK, wantBody: "ok"},
+		{desc: "within window", advance: time.Minute, wantStatus: http.StatusOK, wantBody: "ok"},
+		{desc: "window expired", advance: time.Second, wantStatus: http.StatusServiceUnavailable, wantBody: "no successful storage check"},
+		{desc: "ping recovers", check: true, wantStatus: http.StatusOK, wantBody: "ok"},
+		{desc: "ping fails", pingErr: errors.New("connection refused"), check: true, wantStatus: http.StatusServiceUnavailable, wantBody: "connection refused"},
+	} {
+		checker.err = test.pingErr
+		ts.FakeTime = ts.FakeTime.Add(test.advance)
+		if test.check {
+			if err := h.Check(ctx); err != test.pingErr {
+				t.Errorf("%s: Check()=%v, want %v", test.desc, err, test.pingErr)
+			}
+		}
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+		if rec.Code != test.wantStatus {
+			t.Errorf("%s: /readyz status=%d, want %d", test.desc, rec.Code, test.wantStatus)
+		}
+		if body := rec.Body.String(); !strings.Contains(body, test.wantBody) {
+			t.Errorf("%s: /readyz body=%q, want it to contain %q", test.desc, body, test.wantBody)
+		}
+
+		// The process is alive regardless of storage.
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: /healthz status=%d, want %d", test.desc, rec.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestCheckStorageAtStartup(t *testing.T) {
+	ctx := context.Background()
+	if err := CheckStorageAtStartup(ctx, &fakeDatabaseChecker{}, time.Second); err != nil {
+		t.Errorf("CheckStorageAtStartup()=%v, want nil", err)
+	}
+
+	err := CheckStorageAtStartup(ctx, &fakeDatabaseChecker{err: errors.New("connection refused")}, 50*time.Millisecond)
+	if err == nil {
+		t.Fatal("CheckStorageAtStartup(failing storage)=nil, want error")
+	}
+	for _, want := range []string{"storage not accessible", "connection refused"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("CheckStorageAtStartup(failing storage)=%q, want it to contain %q", err, want)
+		}
+	}
+}
@@ -16,6 +16,7 @@ package server
 
 import (
 	"context"
+	"math/rand"
 	"time"
 
 	"github.com/golang/glog"
@@ -28,8 +29,10 @@ import (
 type LogOperation interface {
 	// Name returns the name of the task.
 	Name() string
-	// ExecutePass performs a single pass of processing on a set of logs.
-	ExecutePass(logIDs []int64, context LogOperationManagerContext)
+	// ExecutePass performs a single pass of processing on a set of logs. It returns the
+	// number of items, such as leaves, that the pass processed, which is zero if there was
+	// no work to do or the pass failed.
+	ExecutePass(logIDs []int64, context LogOperationManagerContext) int
 }
 
 // LogOperationManagerContext bundles up the values so testing can be made easier
@@ -58,6 +61,20 @@ type LogOperationManager struct {
 	context LogOperationManagerContext
 	// logOperation is the task that gets run across active logs in the scheduling loop
 	logOperation LogOperation
+	// logIDs, if not empty, restricts the logs that are operated on to this set
+	logIDs map[int64]bool
+	// stop, if set, stops further passes from starting once it is done. Unlike the
+	// context in LogOperationManagerContext it is not passed to the operation.
+	stop context.Context
+	// maxPasses, if positive, is the number of passes after which OperationLoop exits.
+	maxPasses int
+	// maxSleepBetweenRuns, if greater than sleepBetweenRuns, is the longest pause that
+	// OperationLoop backs off to after passes that process nothing.
+	maxSleepBetweenRuns time.Duration
+	// jitter randomizes the pauses that OperationLoop backs off to.
+	jitter bool
+	// after is used by OperationLoop to pause between passes, and can be replaced in tests.
+	after func(time.Duration) <-chan time.Time
 }
 
 // NewLogOperationManager creates a new LogOperationManager instance.
@@ -72,6 +89,8 @@ func NewLogOperationManager(ctx context.Context, registry extension.Registry, ba
 			numSequencers:    numSequencers,
 		},
 		logOperation: logOperation,
+		jitter:       true,
+		after:        time.After,
 	}
 }
 
@@ -88,21 +107,69 @@ func NewLogOperationManagerForTest(ctx context.Context, registry extension.Regis
 			numSequencers:    5,
 		},
 		logOperation: logOperation,
+		jitter:       true,
+		after:        time.After,
 	}
 }
 
-func (l LogOperationManager) getLogsAndExecutePass(ctx context.Context) bool {
+// SetLogIDs restricts the manager to operating on the given logs. Each pass operates on
+// those of the logs that are active. If no IDs are given, all active logs are used.
+func (l *LogOperationManager) SetLogIDs(logIDs []int64) {
+	l.logIDs = nil
+	if len(logIDs) == 0 {
+		return
+	}
+	l.logIDs = make(map[int64]bool)
+	for _, id := range logIDs {
+		l.logIDs[id] = true
+	}
+}
+
+// SetStopContext sets a context that stops OperationLoop from starting another pass
+// once it is done. A pass that is in progress is allowed to finish, as it is only
+// given the context the manager was created with. By default passes stop when that
+// context is done.
+func (l *LogOperationManager) SetStopContext(ctx context.Context) {
+	l.stop = ctx
+}
+
+// SetMaxPasses limits OperationLoop to the given number of passes, after which it exits
+// even if it has not been stopped. A limit of zero or less means no limit.
+func (l *LogOperationManager) SetMaxPasses(n int) {
+	l.maxPasses = n
+}
+
+// SetMaxSleepBetweenRuns makes OperationLoop back off after passes that process nothing,
+// because the queues are empty or the pass failed. Each such pass doubles the pause before
+// the next, up to d, with some jitter. The pause returns to the configured time between
+// runs after a pass that processes something. Backing off is disabled, which is the
+// default, if d is no more than the time between runs.
+func (l *LogOperationManager) SetMaxSleepBetweenRuns(d time.Duration) {
+	l.maxSleepBetweenRuns = d
+}
+
+// stopped returns a channel that is closed when no further passes should start.
+func (l LogOperationManager) stopped() <-chan struct{} {
+	if l.stop != nil {
+		return l.stop.Done()
+	}
+	return l.context.ctx.Done()
+}
+
+// getLogsAndExecutePass runs a pass over the active logs. It returns the number of items
+// the pass processed, and whether no further passes should start.
+func (l LogOperationManager) getLogsAndExecutePass(ctx context.Context) (int, bool) {
 	provider, err := l.context.registry.GetLogStorage()
 	// If we get an error, we can't do anything but wait until the next run through
 	if err != nil {
 		glog.Warningf("Failed to get storage provider for run: %v", err)
-		return false
+		return 0, false
 	}
 
 	tx, err := provider.Snapshot(ctx)
 	if err != nil {
 		glog.Warningf("Failed to get tx for run: %v", err)
-		return false
+		return 0, false
 	}
 	defer tx.Close()
 
@@ -110,24 +177,34 @@ func (l LogOperationManager) getLogsAndExecutePass(ctx context.Context) bool {
 	logIDs, err := tx.GetActiveLogIDs()
 	if err != nil {
 		glog.Warningf("Failed to get log list for run: %v", err)
-		return false
+		return 0, false
 	}
 
 	if err := tx.Commit(); err != nil {
 		glog.Warningf("Failed to commit getting logs: %v", err)
-		return false
+		return 0, false
+	}
+
+	if l.logIDs != nil {
+		var selected []int64
+		for _, id := range logIDs {
+			if l.logIDs[id] {
+				selected = append(selected, id)
+			}
+		}
+		logIDs = selected
 	}
 
 	// Process each active log once.
-	l.logOperation.ExecutePass(logIDs, l.context)
+	count := l.logOperation.ExecutePass(logIDs, l.context)
 
 	// See if it's time to quit
 	select {
-	case <-ctx.Done():
-		return true
+	case <-l.stopped():
+		return count, true
 	default:
 	}
-	return false
+	return count, false
 }
 
 // OperationSingle performs a single pass of the manager.
@@ -140,10 +217,13 @@ func (l LogOperationManager) OperationSingle() {
 func (l LogOperationManager) OperationLoop() {
 	glog.Infof("Log operation manager starting")
 
+	interval := idleBackoff{min: l.context.sleepBetweenRuns, max: l.maxSleepBetweenRuns, jitter: l.jitter}
+	interval.reset()
+
 	// Outer loop, runs until terminated
-	for {
+	for passes := 1; ; passes++ {
 		// TODO(alcutter): want a child context with deadline here?
-		quit := l.getLogsAndExecutePass(l.context.ctx)
+		count, quit := l.getLogsAndExecutePass(l.context.ctx)
 
 		glog.V(1).Infof("Log operation manager pass complete")
 
@@ -152,8 +232,58 @@ func (l LogOperationManager) OperationLoop() {
 			glog.Infof("Log operation manager shutting down")
 			return
 		}
+		if l.maxPasses > 0 && passes >= l.maxPasses {
+			glog.Infof("Log operation manager completed %d passes, shutting down", passes)
+			return
+		}
+
+		// Wait for the configured time, or longer if there was nothing to do, before going
+		// for another pass, unless we're told to exit in the meantime.
+		sleep := interval.next(count > 0)
+		if count == 0 && sleep > l.context.sleepBetweenRuns {
+			glog.V(1).Infof("Log operation manager pass did no work, backing off for %v", sleep)
+		}
+		select {
+		case <-l.stopped():
+			glog.Infof("Log operation manager shutting down")
+			return
+		case <-l.after(sleep):
+		}
+	}
+}
+
+// idleBackoff computes the pause between passes of OperationLoop. The pause doubles after
+// each pass that does no work, from min up to max, and goes back to min after a pass that
+// does some.
+type idleBackoff struct {
+	min    time.Duration
+	max    time.Duration
+	jitter bool
+	cur    time.Duration
+}
+
+func (b *idleBackoff) reset() {
+	b.cur = b.min
+}
 
-		// Wait for the configured time before going for another pass
-		time.Sleep(l.context.sleepBetweenRuns)
+// next returns the pause after a pass, which did some work if worked is true.
+func (b *idleBackoff) next(worked bool) time.Duration {
+	if worked || b.max <= b.min {
+		b.reset()
+		return b.min
+	}
+	b.cur *= 2
+	if b.cur > b.max || b.cur <= 0 {
+		b.cur = b.max
+	}
+	sleep := b.cur
+	if b.jitter {
+		// Pause for between half and all of the backed off time, but never less than min.
+		half := sleep / 2
+		sleep = half + time.Duration(rand.Int63n(int64(half)+1))
+		if sleep < b.min {
+			sleep = b.min
+		}
 	}
+	return sleep
 }
@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
@@ -129,3 +130,263 @@ func TestLogOperationManagerPassesIDs(t *testing.T) {
 
 	lom.OperationLoop()
 }
+
+func TestLogOperationManagerSetLogIDs(t *testing.T) {
+	logID1 := int64(451)
+	logID2 := int64(145)
+	logID3 := int64(999)
+
+	for _, test := range []struct {
+		desc   string
+		logIDs []int64
+		want   []int64
+	}{
+		{desc: "all", logIDs: nil, want: []int64{logID1, logID2, logID3}},
+		{desc: "two", logIDs: []int64{logID2, logID1}, want: []int64{logID1, logID2}},
+		{desc: "inactive", logIDs: []int64{logID3, 1234}, want: []int64{logID3}},
+	} {
+		ctrl := gomock.NewController(t)
+
+		mockTx := storage.NewMockReadOnlyLogTX(ctrl)
+		mockTx.EXPECT().GetActiveLogIDs().Return([]int64{logID1, logID2, logID3}, nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Close().Return(nil)
+		mockStorage := storage.NewMockLogStorage(ctrl)
+		mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil)
+
+		mockRegistry := extension.NewMockRegistry(ctrl)
+		mockRegistry.EXPECT().GetLogStorage().Return(mockStorage, nil)
+
+		mockLogOp := NewMockLogOperation(ctrl)
+		mockLogOp.EXPECT().ExecutePass(test.want, logOpMgrContextMatcher{50})
+
+		ctx := util.NewLogContext(context.Background(), -1)
+		lom := NewLogOperationManagerForTest(ctx, mockRegistry, 50, time.Second, fakeTimeSource, mockLogOp)
+		lom.SetLogIDs(test.logIDs)
+
+		lom.OperationLoop()
+		ctrl.Finish()
+	}
+}
+
+func TestLogOperationManagerLoopExitsWhileSleeping(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTx := storage.NewMockReadOnlyLogTX(ctrl)
+	mockTx.EXPECT().GetActiveLogIDs().Return([]int64{451}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().Close().Return(nil)
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil)
+
+	mockRegistry := extension.NewMockRegistry(ctrl)
+	mockRegistry.EXPECT().GetLogStorage().Return(mockStorage, nil)
+
+	passDone := make(chan bool, 1)
+	mockLogOp := NewMockLogOperation(ctrl)
+	mockLogOp.EXPECT().ExecutePass([]int64{451}, logOpMgrContextMatcher{50}).Do(func([]int64, LogOperationManagerContext) {
+		passDone <- true
+	})
+
+	ctx, cancel := context.WithCancel(util.NewLogContext(context.Background(), -1))
+	defer cancel()
+	// The loop would not run a second pass for an hour, so it can only exit in time
+	// if it stops waiting when the context is cancelled.
+	lom := NewLogOperationManager(ctx, mockRegistry, 50, 1, time.Hour, fakeTimeSource, mockLogOp)
+
+	loopDone := make(chan bool)
+	go func() {
+		lom.OperationLoop()
+		close(loopDone)
+	}()
+
+	<-passDone
+	cancel()
+	select {
+	case <-loopDone:
+	case <-time.After(10 * time.Second):
+		t.Fatal("OperationLoop() did not exit after context was cancelled")
+	}
+}
+
+func TestLogOperationManagerStopDrainsPass(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTx := storage.NewMockReadOnlyLogTX(ctrl)
+	mockTx.EXPECT().GetActiveLogIDs().Return([]int64{451}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().Close().Return(nil)
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil)
+
+	mockRegistry := extension.NewMockRegistry(ctrl)
+	mockRegistry.EXPECT().GetLogStorage().Return(mockStorage, nil)
+
+	ctx, cancel := context.WithCancel(util.NewLogContext(context.Background(), -1))
+	defer cancel()
+	stopCtx, stop := context.WithCancel(ctx)
+
+	// Stop is requested part way through the only pass, as a signal would be.
+	// The pass must be able to finish with a live context, and no further pass
+	// may start.
+	var passErr error
+	mockLogOp := NewMockLogOperation(ctrl)
+	mockLogOp.EXPECT().ExecutePass([]int64{451}, logOpMgrContextMatcher{50}).Do(func(_ []int64, logctx LogOperationManagerContext) {
+		stop()
+		passErr = logctx.ctx.Err()
+	})
+
+	lom := NewLogOperationManager(ctx, mockRegistry, 50, 1, time.Hour, fakeTimeSource, mockLogOp)
+	lom.SetStopContext(stopCtx)
+
+	loopDone := make(chan bool)
+	go func() {
+		lom.OperationLoop()
+		close(loopDone)
+	}()
+
+	select {
+	case <-loopDone:
+	case <-time.After(10 * time.Second):
+		t.Fatal("OperationLoop() did not exit after stop was requested")
+	}
+	if passErr != nil {
+		t.Errorf("ExecutePass() context error after stop=%v, want nil", passErr)
+	}
+}
+
+func TestLogOperationManagerMaxPasses(t *testing.T) {
+	for _, maxPasses := range []int{1, 3} {
+		ctrl := gomock.NewController(t)
+
+		mockTx := storage.NewMockReadOnlyLogTX(ctrl)
+		mockTx.EXPECT().GetActiveLogIDs().Times(maxPasses).Return([]int64{451}, nil)
+		mockTx.EXPECT().Commit().Times(maxPasses).Return(nil)
+		mockTx.EXPECT().Close().Times(maxPasses).Return(nil)
+		mockStorage := storage.NewMockLogStorage(ctrl)
+		mockStorage.EXPECT().Snapshot(gomock.Any()).Times(maxPasses).Return(mockTx, nil)
+
+		mockRegistry := extension.NewMockRegistry(ctrl)
+		mockRegistry.EXPECT().GetLogStorage().Times(maxPasses).Return(mockStorage, nil)
+
+		mockLogOp := NewMockLogOperation(ctrl)
+		mockLogOp.EXPECT().ExecutePass([]int64{451}, logOpMgrContextMatcher{50}).Times(maxPasses)
+
+		ctx := util.NewLogContext(context.Background(), -1)
+		lom := NewLogOperationManager(ctx, mockRegistry, 50, 1, time.Millisecond, fakeTimeSource, mockLogOp)
+		lom.SetMaxPasses(maxPasses)
+
+		loopDone := make(chan bool)
+		go func() {
+			lom.OperationLoop()
+			close(loopDone)
+		}()
+		select {
+		case <-loopDone:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("OperationLoop() did not exit after %d passes", maxPasses)
+		}
+		ctrl.Finish()
+	}
+}
+
+// fixedCountLogOperation is a LogOperation whose passes process the given numbers of items
+// in turn.
+type fixedCountLogOperation struct {
+	counts []int
+}
+
+func (f *fixedCountLogOperation) Name() string {
+	return "fixed count"
+}
+
+func (f *fixedCountLogOperation) ExecutePass([]int64, LogOperationManagerContext) int {
+	count := f.counts[0]
+	f.counts = f.counts[1:]
+	return count
+}
+
+func TestLogOperationManagerBacksOffWhenIdle(t *testing.T) {
+	for _, test := range []struct {
+		desc     string
+		maxSleep time.Duration
+		jitter   bool
+		counts   []int
+		want     []time.Duration
+	}{
+		{
+			desc:     "backoff",
+			maxSleep: 5 * time.Second,
+			counts:   []int{0, 0, 0, 0, 3, 0, 2, 1},
+			want:     []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second, time.Second, 2 * time.Second, time.Second},
+		},
+		{
+			desc:   "disabled",
+			counts: []int{0, 0, 3, 0},
+			want:   []time.Duration{time.Second, time.Second, time.Second},
+		},
+		{
+			desc:     "jitter",
+			maxSleep: 5 * time.Second,
+			jitter:   true,
+			counts:   []int{0, 0, 0, 0, 3, 0},
+		},
+	} {
+		ctrl := gomock.NewController(t)
+		passes := len(test.counts)
+
+		mockTx := storage.NewMockReadOnlyLogTX(ctrl)
+		mockTx.EXPECT().GetActiveLogIDs().Times(passes).Return([]int64{451}, nil)
+		mockTx.EXPECT().Commit().Times(passes).Return(nil)
+		mockTx.EXPECT().Close().Times(passes).Return(nil)
+		mockStorage := storage.NewMockLogStorage(ctrl)
+		mockStorage.EXPECT().Snapshot(gomock.Any()).Times(passes).Return(mockTx, nil)
+
+		mockRegistry := extension.NewMockRegistry(ctrl)
+		mockRegistry.EXPECT().GetLogStorage().Times(passes).Return(mockStorage, nil)
+
+		logOp := &fixedCountLogOperation{counts: test.counts}
+
+		ctx := util.NewLogContext(context.Background(), -1)
+		lom := NewLogOperationManager(ctx, mockRegistry, 50, 1, time.Second, fakeTimeSource, logOp)
+		lom.SetMaxPasses(passes)
+		lom.SetMaxSleepBetweenRuns(test.maxSleep)
+		lom.jitter = test.jitter
+		// The loop sleeps on a fake clock, which records each pause and ends it at once.
+		var sleeps []time.Duration
+		lom.after = func(d time.Duration) <-chan time.Time {
+			sleeps = append(sleeps, d)
+			c := make(chan time.Time, 1)
+			c <- fakeTime.Add(d)
+			return c
+		}
+		lom.OperationLoop()
+		ctrl.Finish()
+
+		if len(sleeps) != passes-1 {
+			t.Errorf("%s: OperationLoop() paused %d times, want %d", test.desc, len(sleeps), passes-1)
+			continue
+		}
+		if test.want != nil {
+			if !reflect.DeepEqual(sleeps, test.want) {
+				t.Errorf("%s: OperationLoop() paused for %v, want %v", test.desc, sleeps, test.want)
+			}
+			continue
+		}
+		// With jitter the backed off pauses grow until they near the limit, stay between
+		// the configured pause and the limit, and reset after a pass that did some work.
+		for i, d := range sleeps {
+			if d < time.Second || d > test.maxSleep {
+				t.Errorf("%s: OperationLoop() pause %d=%v, want in [1s, %v]", test.desc, i, d, test.maxSleep)
+			}
+			if i == 1 && d < sleeps[i-1] {
+				t.Errorf("%s: OperationLoop() pause %d=%v, want >= pause %d=%v", test.desc, i, d, i-1, sleeps[i-1])
+			}
+		}
+		if got, want := sleeps[4], time.Second; got != want {
+			t.Errorf("%s: OperationLoop() pause after work=%v, want %v", test.desc, got, want)
+		}
+	}
+}
@@ -15,14 +15,19 @@
 package server
 
 import (
+	"crypto/sha256"
+	"sync"
+
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/sigpb"
 	"github.com/google/trillian/extension"
+	"github.com/google/trillian/log"
 	"github.com/google/trillian/merkle"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/util"
 
 	"github.com/golang/glog"
-	"github.com/golang/protobuf/ptypes/empty"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -34,18 +39,122 @@ import (
 // Pass this as a fixed value to proof calculations. It's used as the max depth of the tree
 const proofMaxBitLen = 64
 
+// LeafIdentityHashFunc computes the identity hash of a leaf from its value. Leaves with
+// the same identity hash are treated as duplicates when they are queued.
+type LeafIdentityHashFunc func(leafValue []byte) []byte
+
+// DefaultLeafIdentityHash is the SHA-256 hash of the leaf value. It is the identity hash
+// of leaves queued without one to trees whose identity_hash_algorithm is NONE or SHA256.
+func DefaultLeafIdentityHash(leafValue []byte) []byte {
+	h := sha256.Sum256(leafValue)
+	return h[:]
+}
+
+// leafIdentityHashFor returns the function computing identity hashes with alg, as
+// chosen by crypto.LeafIdentityHasher.
+func leafIdentityHashFor(alg sigpb.DigitallySigned_HashAlgorithm) (LeafIdentityHashFunc, error) {
+	h, err := crypto.LeafIdentityHasher(alg)
+	if err != nil {
+		return nil, err
+	}
+	return func(leafValue []byte) []byte {
+		hasher := h.New()
+		hasher.Write(leafValue)
+		return hasher.Sum(nil)
+	}, nil
+}
+
 // TrillianLogRPCServer implements the RPC API defined in the proto
 type TrillianLogRPCServer struct {
 	registry   extension.Registry
 	timeSource util.TimeSource
+	// mu guards the identity hash functions, which can be set while leaves are queued.
+	mu sync.RWMutex
+	// identityHash, if set, computes the identity hash of every leaf queued to a tree
+	// that has no entry in treeIdentityHash.
+	identityHash     LeafIdentityHashFunc
+	treeIdentityHash map[int64]LeafIdentityHashFunc
+	// maxLeafSize, if positive, is the largest leaf value that can be queued.
+	maxLeafSize int
+	// hasher computes the Merkle leaf hashes of queued leaves and rehashes proof nodes.
+	hasher merkle.TreeHasher
 }
 
 // NewTrillianLogRPCServer creates a new RPC server backed by a LogStorageProvider.
 func NewTrillianLogRPCServer(registry extension.Registry, timeSource util.TimeSource) *TrillianLogRPCServer {
+	th, _ := merkle.Factory(merkle.RFC6962SHA256Type)
 	return &TrillianLogRPCServer{
-		registry:   registry,
-		timeSource: timeSource,
+		registry:    registry,
+		timeSource:  timeSource,
+		maxLeafSize: log.DefaultMaxLeafSize,
+		hasher:      th,
+	}
+}
+
+// SetHasher sets the hasher used for the Merkle leaf hashes of queued leaves and for
+// nodes recomputed when serving proofs. It must match the hasher the trees were built
+// with. The default is the RFC6962 SHA256 hasher. Leaf hashes only cover the
+// leaves' extra data if th is a merkle.ExtraDataHasher.
+func (t *TrillianLogRPCServer) SetHasher(th merkle.TreeHasher) {
+	t.hasher = th
+}
+
+// SetMaxLeafSize sets the largest leaf value, in bytes, that can be queued. Requests
+// holding a larger leaf are rejected. Values of n less than 1 mean no limit. The default
+// is log.DefaultMaxLeafSize.
+func (t *TrillianLogRPCServer) SetMaxLeafSize(n int) {
+	t.maxLeafSize = n
+}
+
+// SetLeafIdentityHash sets the function used to compute the identity hash of leaves
+// queued to any tree that does not have its own function set by SetTreeLeafIdentityHash.
+// Identity hashes supplied by clients are replaced. By default clients' identity hashes
+// are kept, and leaves without one get an identity hash computed with the
+// identity_hash_algorithm of their tree.
+func (t *TrillianLogRPCServer) SetLeafIdentityHash(f LeafIdentityHashFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.identityHash = f
+}
+
+// SetTreeLeafIdentityHash sets the function used to compute the identity hash of leaves
+// queued to the tree with the given ID, replacing any identity hashes supplied by clients.
+func (t *TrillianLogRPCServer) SetTreeLeafIdentityHash(treeID int64, f LeafIdentityHashFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.treeIdentityHash == nil {
+		t.treeIdentityHash = make(map[int64]LeafIdentityHashFunc)
+	}
+	t.treeIdentityHash[treeID] = f
+}
+
+// setLeafIdentityHashes sets the identity hash of leaves queued in tx to the tree with
+// the given ID, as described by SetLeafIdentityHash.
+func (t *TrillianLogRPCServer) setLeafIdentityHashes(tx storage.LogTreeTX, treeID int64, leaves []*trillian.LogLeaf) error {
+	t.mu.RLock()
+	f, ok := t.treeIdentityHash[treeID]
+	if !ok {
+		f = t.identityHash
+	}
+	t.mu.RUnlock()
+	var treeHash LeafIdentityHashFunc
+	for _, leaf := range leaves {
+		if f != nil {
+			leaf.LeafIdentityHash = f(leaf.LeafValue)
+			continue
+		}
+		if len(leaf.LeafIdentityHash) > 0 {
+			continue
+		}
+		if treeHash == nil {
+			var err error
+			if treeHash, err = leafIdentityHashFor(tx.IdentityHashAlgorithm()); err != nil {
+				return err
+			}
+		}
+		leaf.LeafIdentityHash = treeHash(leaf.LeafValue)
 	}
+	return nil
 }
 
 // IsHealthy returns nil if the server is healthy, error otherwise.
@@ -58,36 +167,36 @@ func (t *TrillianLogRPCServer) IsHealthy() error {
 }
 
 // QueueLeaf submits one leaf to the queue.
-func (t *TrillianLogRPCServer) QueueLeaf(ctx context.Context, req *trillian.QueueLeafRequest) (*empty.Empty, error) {
+func (t *TrillianLogRPCServer) QueueLeaf(ctx context.Context, req *trillian.QueueLeafRequest) (*trillian.QueueLeafResponse, error) {
 	queueReq := &trillian.QueueLeavesRequest{
 		LogId:  req.LogId,
 		Leaves: []*trillian.LogLeaf{req.Leaf},
 	}
+	if req.DedupToken != "" {
+		return t.queueLeafWithToken(ctx, queueReq, req.DedupToken)
+	}
 	_, err := t.QueueLeaves(ctx, queueReq)
 	if err != nil {
 		return nil, err
 	}
-	return &empty.Empty{}, nil
+	return &trillian.QueueLeafResponse{QueuedLeaf: unsequencedLeaf(queueReq.Leaves[0])}, nil
 }
 
 // QueueLeaves submits a batch of leaves to the log for later integration into the underlying tree.
 func (t *TrillianLogRPCServer) QueueLeaves(ctx context.Context, req *trillian.QueueLeavesRequest) (*trillian.QueueLeavesResponse, error) {
 	ctx = util.NewLogContext(ctx, req.LogId)
-	if err := validateQueueLeavesRequest(req); err != nil {
+	if err := t.prepareLeaves(req); err != nil {
 		return nil, err
 	}
 
-	// TODO(al): Hasher must be selected based on log config.
-	th, _ := merkle.Factory(merkle.RFC6962SHA256Type)
-	for i := range req.Leaves {
-		req.Leaves[i].MerkleLeafHash = th.HashLeaf(req.Leaves[i].LeafValue)
-	}
-
 	tx, err := t.prepareStorageTx(ctx, req.LogId)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Close()
+	if err := t.setLeafIdentityHashes(tx, req.LogId, req.Leaves); err != nil {
+		return nil, err
+	}
 
 	err = tx.QueueLeaves(req.Leaves, t.timeSource.Now())
 	if err != nil {
@@ -105,6 +214,69 @@ func (t *TrillianLogRPCServer) QueueLeaves(ctx context.Context, req *trillian.Qu
 	return &trillian.QueueLeavesResponse{}, nil
 }
 
+// queueLeafWithToken queues the single leaf in req, unless a leaf has already been
+// queued with token, in which case it succeeds without queueing anything and returns
+// that leaf.
+func (t *TrillianLogRPCServer) queueLeafWithToken(ctx context.Context, req *trillian.QueueLeavesRequest, token string) (*trillian.QueueLeafResponse, error) {
+	ctx = util.NewLogContext(ctx, req.LogId)
+	if err := t.prepareLeaves(req); err != nil {
+		return nil, err
+	}
+
+	tx, err := t.prepareStorageTx(ctx, req.LogId)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	if err := t.setLeafIdentityHashes(tx, req.LogId, req.Leaves); err != nil {
+		return nil, err
+	}
+
+	existing, err := tx.QueueLeafWithToken(req.Leaves[0], token, t.timeSource.Now())
+	if err != nil {
+		if se, ok := err.(storage.Error); ok && se.ErrType == storage.DuplicateLeaf {
+			return nil, grpc.Errorf(codes.AlreadyExists, "Leaf hash already exists: %v", se)
+		}
+		return nil, err
+	}
+	queued := existing
+	if existing != nil {
+		glog.V(1).Infof("%v: Leaf with dedup token %q already queued, at index %d", req.LogId, token, existing.LeafIndex)
+	} else {
+		queued = unsequencedLeaf(req.Leaves[0])
+	}
+
+	if err := t.commitAndLog(ctx, tx, "QueueLeaf"); err != nil {
+		return nil, err
+	}
+	return &trillian.QueueLeafResponse{QueuedLeaf: queued}, nil
+}
+
+// unsequencedLeaf returns a copy of a leaf that has just been queued, with a LeafIndex
+// of -1 as it has not been sequenced yet.
+func unsequencedLeaf(leaf *trillian.LogLeaf) *trillian.LogLeaf {
+	queued := *leaf
+	queued.LeafIndex = -1
+	return &queued
+}
+
+// prepareLeaves validates the leaves in req and fills in their Merkle leaf hashes. Their
+// identity hashes depend on the tree, and are set by setLeafIdentityHashes once it has
+// been opened.
+func (t *TrillianLogRPCServer) prepareLeaves(req *trillian.QueueLeavesRequest) error {
+	if err := validateQueueLeavesRequest(req); err != nil {
+		return err
+	}
+	if err := validateLeafSizes(req.Leaves, t.maxLeafSize); err != nil {
+		return err
+	}
+
+	for i := range req.Leaves {
+		req.Leaves[i].MerkleLeafHash = merkle.HashLeafData(t.hasher, req.Leaves[i].LeafValue, req.Leaves[i].ExtraData)
+	}
+	return nil
+}
+
 // GetInclusionProof obtains the proof of inclusion in the tree for a leaf that has been sequenced.
 // Similar to the get proof by hash handler but one less step as we don't need to look up the index
 func (t *TrillianLogRPCServer) GetInclusionProof(ctx context.Context, req *trillian.GetInclusionProofRequest) (*trillian.GetInclusionProofResponse, error) {
@@ -126,7 +298,7 @@ func (t *TrillianLogRPCServer) GetInclusionProof(ctx context.Context, req *trill
 		return nil, err
 	}
 
-	proof, err := getInclusionProofForLeafIndex(tx, req.TreeSize, req.LeafIndex, root.TreeSize)
+	proof, err := getInclusionProofForLeafIndex(tx, t.hasher, req.TreeSize, req.LeafIndex, root.TreeSize)
 	if err != nil {
 		return nil, err
 	}
@@ -173,7 +345,7 @@ func (t *TrillianLogRPCServer) GetInclusionProofByHash(ctx context.Context, req
 	// TODO(Martin2112): Need to define a limit on number of results or some form of paging etc.
 	proofs := make([]*trillian.Proof, 0, len(leaves))
 	for _, leaf := range leaves {
-		proof, err := getInclusionProofForLeafIndex(tx, req.TreeSize, leaf.LeafIndex, root.TreeSize)
+		proof, err := getInclusionProofForLeafIndex(tx, t.hasher, req.TreeSize, leaf.LeafIndex, root.TreeSize)
 		if err != nil {
 			return nil, err
 		}
@@ -217,7 +389,7 @@ func (t *TrillianLogRPCServer) GetConsistencyProof(ctx context.Context, req *tri
 
 	// Do all the node fetches at the second tree revision, which is what the node ids were calculated
 	// against.
-	proof, err := fetchNodesAndBuildProof(tx, tx.ReadRevision(), 0, nodeFetches)
+	proof, err := log.FetchNodesAndBuildProof(tx, t.hasher, tx.ReadRevision(), 0, nodeFetches)
 	if err != nil {
 		return nil, err
 	}
@@ -334,7 +506,7 @@ func (t *TrillianLogRPCServer) GetEntryAndProof(ctx context.Context, req *trilli
 		return nil, err
 	}
 
-	proof, err := getInclusionProofForLeafIndex(tx, req.TreeSize, req.LeafIndex, root.TreeSize)
+	proof, err := getInclusionProofForLeafIndex(tx, t.hasher, req.TreeSize, req.LeafIndex, root.TreeSize)
 	if err != nil {
 		return nil, err
 	}
@@ -416,14 +588,14 @@ func validateLeafHashes(leafHashes [][]byte) bool {
 // getInclusionProofForLeafIndex is used by multiple handlers. It does the storage fetching
 // and makes additional checks on the returned proof. Returns a Proof suitable for inclusion in
 // an RPC response
-func getInclusionProofForLeafIndex(tx storage.ReadOnlyLogTreeTX, snapshot, leafIndex, treeSize int64) (trillian.Proof, error) {
+func getInclusionProofForLeafIndex(tx storage.ReadOnlyLogTreeTX, th merkle.TreeHasher, snapshot, leafIndex, treeSize int64) (trillian.Proof, error) {
 	// We have the tree size and leaf index so we know the nodes that we need to serve the proof
 	proofNodeIDs, err := merkle.CalcInclusionProofNodeAddresses(snapshot, leafIndex, treeSize, proofMaxBitLen)
 	if err != nil {
 		return trillian.Proof{}, err
 	}
 
-	return fetchNodesAndBuildProof(tx, tx.ReadRevision(), leafIndex, proofNodeIDs)
+	return log.FetchNodesAndBuildProof(tx, th, tx.ReadRevision(), leafIndex, proofNodeIDs)
 }
 
 // getLeavesByHashInternal does the work of fetching leaves by either their raw data or merkle
@@ -15,16 +15,22 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/sigpb"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	storageto "github.com/google/trillian/storage/testonly"
 	"github.com/google/trillian/testonly"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -215,6 +221,7 @@ func TestQueueLeavesStorageError(t *testing.T) {
 
 	test := newParameterizedTest(ctrl, "QueueLeaves", readWrite,
 		func(t *storage.MockLogTreeTX) {
+			t.EXPECT().IdentityHashAlgorithm().AnyTimes().Return(sigpb.DigitallySigned_NONE)
 			t.EXPECT().QueueLeaves([]*trillian.LogLeaf{leaf1}, fakeTime).Return(errors.New("STORAGE"))
 		},
 		func(s *TrillianLogRPCServer) error {
@@ -245,6 +252,7 @@ func TestQueueLeavesCommitFails(t *testing.T) {
 
 	test := newParameterizedTest(ctrl, "QueueLeaves", readWrite,
 		func(t *storage.MockLogTreeTX) {
+			t.EXPECT().IdentityHashAlgorithm().AnyTimes().Return(sigpb.DigitallySigned_NONE)
 			t.EXPECT().QueueLeaves([]*trillian.LogLeaf{leaf1}, fakeTime).Return(nil)
 		},
 		func(s *TrillianLogRPCServer) error {
@@ -262,6 +270,7 @@ func TestQueueLeaves(t *testing.T) {
 	mockStorage := storage.NewMockLogStorage(ctrl)
 	mockTx := storage.NewMockLogTreeTX(ctrl)
 	mockStorage.EXPECT().BeginForTree(gomock.Any(), queueRequest0.LogId).Return(mockTx, nil)
+	mockTx.EXPECT().IdentityHashAlgorithm().AnyTimes().Return(sigpb.DigitallySigned_NONE)
 	mockTx.EXPECT().QueueLeaves([]*trillian.LogLeaf{leaf1}, fakeTime).Return(nil)
 	mockTx.EXPECT().Commit().Return(nil)
 	mockTx.EXPECT().Close().Return(nil)
@@ -276,6 +285,246 @@ func TestQueueLeaves(t *testing.T) {
 	}
 }
 
+func TestQueueLeafWithDedupToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		desc      string
+		existing  *trillian.LogLeaf
+		wantIndex int64
+	}{
+		{desc: "new token", wantIndex: -1},
+		{desc: "repeated token", existing: &trillian.LogLeaf{LeafValue: []byte("first"), LeafIndex: 7}, wantIndex: 7},
+	}
+
+	for _, test := range tests {
+		mockStorage := storage.NewMockLogStorage(ctrl)
+		mockTx := storage.NewMockLogTreeTX(ctrl)
+		mockStorage.EXPECT().BeginForTree(gomock.Any(), queueRequest0.LogId).Return(mockTx, nil)
+		mockTx.EXPECT().IdentityHashAlgorithm().AnyTimes().Return(sigpb.DigitallySigned_NONE)
+		mockTx.EXPECT().QueueLeafWithToken(leaf1, "token", fakeTime).Return(test.existing, nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Close().Return(nil)
+		mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+
+		mockRegistry := extension.NewMockRegistry(ctrl)
+		mockRegistry.EXPECT().GetLogStorage().Return(mockStorage, nil)
+		server := NewTrillianLogRPCServer(mockRegistry, fakeTimeSource)
+
+		req := &trillian.QueueLeafRequest{LogId: logID1, Leaf: leaf1, DedupToken: "token"}
+		resp, err := server.QueueLeaf(context.Background(), req)
+		if err != nil {
+			t.Errorf("%s: QueueLeaf()=_,%v, want _,nil", test.desc, err)
+			continue
+		}
+		wantValue := leaf1.LeafValue
+		if test.existing != nil {
+			wantValue = test.existing.LeafValue
+		}
+		if got := resp.QueuedLeaf; got.LeafIndex != test.wantIndex || !bytes.Equal(got.LeafValue, wantValue) {
+			t.Errorf("%s: QueueLeaf().QueuedLeaf=%v, want a leaf with value %q and index %d", test.desc, got, wantValue, test.wantIndex)
+		}
+	}
+}
+
+func TestQueueLeafWithDedupTokenDuplicateErrorMapped(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTreeTX(ctrl)
+	mockStorage.EXPECT().BeginForTree(gomock.Any(), queueRequest0.LogId).Return(mockTx, nil)
+	mockTx.EXPECT().IdentityHashAlgorithm().AnyTimes().Return(sigpb.DigitallySigned_NONE)
+	mockTx.EXPECT().QueueLeafWithToken(leaf1, "token", fakeTime).Return(nil, storage.Error{ErrType: storage.DuplicateLeaf, Detail: "duplicate test"})
+	mockTx.EXPECT().Close().Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+
+	mockRegistry := extension.NewMockRegistry(ctrl)
+	mockRegistry.EXPECT().GetLogStorage().Return(mockStorage, nil)
+	server := NewTrillianLogRPCServer(mockRegistry, fakeTimeSource)
+
+	req := &trillian.QueueLeafRequest{LogId: logID1, Leaf: leaf1, DedupToken: "token"}
+	_, err := server.QueueLeaf(context.Background(), req)
+	if got, want := grpc.Code(err), codes.AlreadyExists; got != want {
+		t.Errorf("QueueLeaf()=%v, want code %v", err, want)
+	}
+}
+
+func TestQueueLeavesIdentityHash(t *testing.T) {
+	var calls int
+	custom := func(leafValue []byte) []byte {
+		calls++
+		return append([]byte("custom:"), leafValue...)
+	}
+	reverse := func(leafValue []byte) []byte {
+		calls++
+		h := make([]byte, len(leafValue))
+		for i, b := range leafValue {
+			h[len(leafValue)-1-i] = b
+		}
+		return h
+	}
+
+	for _, test := range []struct {
+		desc      string
+		logID     int64
+		clientID  []byte
+		setup     func(s *TrillianLogRPCServer)
+		wantID    []byte
+		wantCalls int
+	}{
+		{
+			desc:   "default fills in missing",
+			logID:  logID1,
+			wantID: DefaultLeafIdentityHash([]byte("value")),
+		},
+		{
+			desc:     "default keeps client hash",
+			logID:    logID1,
+			clientID: []byte("client"),
+			wantID:   []byte("client"),
+		},
+		{
+			desc:      "server function",
+			logID:     logID1,
+			clientID:  []byte("client"),
+			setup:     func(s *TrillianLogRPCServer) { s.SetLeafIdentityHash(custom) },
+			wantID:    []byte("custom:value"),
+			wantCalls: 1,
+		},
+		{
+			desc:  "tree function",
+			logID: logID1,
+			setup: func(s *TrillianLogRPCServer) {
+				s.SetLeafIdentityHash(custom)
+				s.SetTreeLeafIdentityHash(logID1, reverse)
+			},
+			wantID:    []byte("eulav"),
+			wantCalls: 1,
+		},
+		{
+			desc:      "other tree's function",
+			logID:     logID1,
+			setup:     func(s *TrillianLogRPCServer) { s.SetTreeLeafIdentityHash(logID2, reverse) },
+			wantID:    DefaultLeafIdentityHash([]byte("value")),
+			wantCalls: 0,
+		},
+	} {
+		ctrl := gomock.NewController(t)
+
+		leaf := &trillian.LogLeaf{LeafValue: []byte("value"), LeafIdentityHash: test.clientID}
+		var queued []*trillian.LogLeaf
+		mockStorage := storage.NewMockLogStorage(ctrl)
+		mockTx := storage.NewMockLogTreeTX(ctrl)
+		mockStorage.EXPECT().BeginForTree(gomock.Any(), test.logID).Return(mockTx, nil)
+		mockTx.EXPECT().IdentityHashAlgorithm().AnyTimes().Return(sigpb.DigitallySigned_NONE)
+		mockTx.EXPECT().QueueLeaves(gomock.Any(), fakeTime).Do(func(leaves []*trillian.LogLeaf, _ time.Time) {
+			queued = leaves
+		}).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Close().Return(nil)
+		mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+
+		mockRegistry := extension.NewMockRegistry(ctrl)
+		mockRegistry.EXPECT().GetLogStorage().Return(mockStorage, nil)
+		server := NewTrillianLogRPCServer(mockRegistry, fakeTimeSource)
+		if test.setup != nil {
+			test.setup(server)
+		}
+		calls = 0
+
+		req := &trillian.QueueLeavesRequest{LogId: test.logID, Leaves: []*trillian.LogLeaf{leaf}}
+		if _, err := server.QueueLeaves(context.Background(), req); err != nil {
+			t.Errorf("%v: QueueLeaves()=_,%v, want nil", test.desc, err)
+		}
+		if len(queued) != 1 {
+			t.Errorf("%v: QueueLeaves() stored %d leaves, want 1", test.desc, len(queued))
+		} else if got := queued[0].LeafIdentityHash; !bytes.Equal(got, test.wantID) {
+			t.Errorf("%v: stored LeafIdentityHash=%q, want %q", test.desc, got, test.wantID)
+		}
+		if calls != test.wantCalls {
+			t.Errorf("%v: identity hash function called %d times, want %d", test.desc, calls, test.wantCalls)
+		}
+		ctrl.Finish()
+	}
+}
+
+func TestQueueLeavesTreeIdentityHashAlgorithm(t *testing.T) {
+	for _, alg := range []sigpb.DigitallySigned_HashAlgorithm{
+		sigpb.DigitallySigned_NONE,
+		sigpb.DigitallySigned_SHA1,
+		sigpb.DigitallySigned_SHA256,
+		sigpb.DigitallySigned_SHA384,
+		sigpb.DigitallySigned_SHA512,
+		sigpb.DigitallySigned_SHA3_256,
+	} {
+		ctrl := gomock.NewController(t)
+
+		ls := memory.NewLogStorage()
+		treeCfg := *storageto.LogTree
+		treeCfg.IdentityHashAlgorithm = alg
+		tree, err := ls.CreateLog(&treeCfg)
+		if err != nil {
+			t.Fatalf("%v: CreateLog()=_,%v, want nil", alg, err)
+		}
+		h, err := crypto.LeafIdentityHasher(alg)
+		if err != nil {
+			t.Fatalf("%v: LeafIdentityHasher()=_,%v, want nil", alg, err)
+		}
+		hasher := h.New()
+		hasher.Write([]byte("llama"))
+		wantID := hasher.Sum(nil)
+
+		mockRegistry := extension.NewMockRegistry(ctrl)
+		mockRegistry.EXPECT().GetLogStorage().AnyTimes().Return(ls, nil)
+		server := NewTrillianLogRPCServer(mockRegistry, fakeTimeSource)
+
+		ctx := context.Background()
+		req := &trillian.QueueLeavesRequest{LogId: tree.TreeId, Leaves: []*trillian.LogLeaf{{LeafValue: []byte("llama")}}}
+		if _, err := server.QueueLeaves(ctx, req); err != nil {
+			t.Errorf("%v: QueueLeaves()=_,%v, want nil", alg, err)
+		}
+		req = &trillian.QueueLeavesRequest{LogId: tree.TreeId, Leaves: []*trillian.LogLeaf{{LeafValue: []byte("llama")}}}
+		if _, err := server.QueueLeaves(ctx, req); grpc.Code(err) != codes.AlreadyExists {
+			t.Errorf("%v: QueueLeaves(again)=_,%v, want code %v", alg, err, codes.AlreadyExists)
+		}
+
+		tx, err := ls.BeginForTree(ctx, tree.TreeId)
+		if err != nil {
+			t.Fatalf("%v: BeginForTree()=_,%v, want nil", alg, err)
+		}
+		queued, err := tx.DequeueLeaves(10, fakeTime.Add(time.Second))
+		tx.Close()
+		if err != nil {
+			t.Fatalf("%v: DequeueLeaves()=_,%v, want nil", alg, err)
+		}
+		if len(queued) != 1 || !bytes.Equal(queued[0].LeafIdentityHash, wantID) {
+			t.Errorf("%v: queued leaves %v, want one with LeafIdentityHash %x", alg, queued, wantID)
+		}
+		ctrl.Finish()
+	}
+}
+
+func TestQueueLeavesTreeIdentityHashAlgorithmUnsupported(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTreeTX(ctrl)
+	mockStorage.EXPECT().BeginForTree(gomock.Any(), logID1).Return(mockTx, nil)
+	mockTx.EXPECT().IdentityHashAlgorithm().Return(sigpb.DigitallySigned_HashAlgorithm(99))
+	mockTx.EXPECT().Close().Return(nil)
+	mockRegistry := extension.NewMockRegistry(ctrl)
+	mockRegistry.EXPECT().GetLogStorage().Return(mockStorage, nil)
+	server := NewTrillianLogRPCServer(mockRegistry, fakeTimeSource)
+
+	req := &trillian.QueueLeavesRequest{LogId: logID1, Leaves: []*trillian.LogLeaf{{LeafValue: []byte("llama")}}}
+	if _, err := server.QueueLeaves(context.Background(), req); err == nil {
+		t.Errorf("QueueLeaves() to tree with identity hash algorithm 99=_,nil, want error")
+	}
+}
+
 func TestQueueLeavesDuplicateErrorMapped(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -308,6 +557,7 @@ func TestQueueLeavesDuplicateErrorMapped(t *testing.T) {
 		mockStorage := storage.NewMockLogStorage(ctrl)
 		mockTx := storage.NewMockLogTreeTX(ctrl)
 		mockStorage.EXPECT().BeginForTree(gomock.Any(), queueRequest0.LogId).Return(mockTx, nil)
+		mockTx.EXPECT().IdentityHashAlgorithm().AnyTimes().Return(sigpb.DigitallySigned_NONE)
 		mockTx.EXPECT().QueueLeaves([]*trillian.LogLeaf{leaf1}, fakeTime).Return(test.err)
 		mockTx.EXPECT().Close().Return(nil)
 		mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
@@ -340,6 +590,51 @@ func TestQueueLeavesNoLeavesRejected(t *testing.T) {
 	}
 }
 
+func TestQueueLeavesMaxLeafSize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRegistry := extension.NewMockRegistry(ctrl)
+	server := NewTrillianLogRPCServer(mockRegistry, fakeTimeSource)
+	server.SetMaxLeafSize(4)
+
+	req := &trillian.QueueLeavesRequest{
+		LogId:  logID1,
+		Leaves: []*trillian.LogLeaf{{LeafValue: []byte("ok")}, {LeafValue: []byte("too big")}},
+	}
+	_, err := server.QueueLeaves(context.Background(), req)
+	if got, want := grpc.Code(err), codes.InvalidArgument; got != want {
+		t.Fatalf("QueueLeaves()=_,%v, want code %v", err, want)
+	}
+}
+
+func TestQueueLeavesNoMaxLeafSize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTreeTX(ctrl)
+	mockStorage.EXPECT().BeginForTree(gomock.Any(), logID1).Return(mockTx, nil)
+	mockTx.EXPECT().IdentityHashAlgorithm().AnyTimes().Return(sigpb.DigitallySigned_NONE)
+	mockTx.EXPECT().QueueLeaves(gomock.Any(), fakeTime).Return(nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().Close().Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+
+	mockRegistry := extension.NewMockRegistry(ctrl)
+	mockRegistry.EXPECT().GetLogStorage().Return(mockStorage, nil)
+	server := NewTrillianLogRPCServer(mockRegistry, fakeTimeSource)
+	server.SetMaxLeafSize(0)
+
+	req := &trillian.QueueLeavesRequest{
+		LogId:  logID1,
+		Leaves: []*trillian.LogLeaf{{LeafValue: make([]byte, 1000)}},
+	}
+	if _, err := server.QueueLeaves(context.Background(), req); err != nil {
+		t.Fatalf("QueueLeaves()=_,%v, want nil", err)
+	}
+}
+
 func TestQueueLeavesBeginFailsCausesError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
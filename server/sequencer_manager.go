@@ -15,28 +15,329 @@
 package server
 
 import (
-	"sync"
+	"context"
+	"errors"
+	"fmt"
+	"math"
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/google/trillian/client/backoff"
+	"github.com/google/trillian/crypto"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/log"
 	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
 	"github.com/google/trillian/util"
 )
 
+// sequencerRetryPolicy is used to retry batches that fail with transient storage errors.
+var sequencerRetryPolicy = storage.RetryPolicy{
+	MaxAttempts: 3,
+	Backoff: backoff.Backoff{
+		Min:    100 * time.Millisecond,
+		Max:    time.Second,
+		Factor: 2,
+		Jitter: true,
+	},
+}
+
+// drainBackoff controls the wait before Drain sequences another batch for a log whose
+// last batch failed with a transient error, after the retries made by the sequencer.
+var drainBackoff = backoff.Backoff{
+	Min:    time.Second,
+	Max:    30 * time.Second,
+	Factor: 2,
+	Jitter: true,
+}
+
+// drainMaxFailures is the number of consecutive failed batches after which Drain gives
+// up on a log.
+const drainMaxFailures = 5
+
 // SequencerManager provides sequencing operations for a collection of Logs.
 type SequencerManager struct {
 	guardWindow time.Duration
 	registry    extension.Registry
+	dryRun      bool
+	eventLogger util.EventLogger
+	// hashConcurrency, if non-zero, is passed to each log.Sequencer.
+	hashConcurrency   int
+	skipInvalidLeaves bool
+	maxLeafSize       int
+	// leafRate, if positive, limits each log to that many leaves per second, using
+	// the limiter held for it in rateLimiters.
+	leafRate     float64
+	rateLimiters map[int64]*log.LeafRateLimiter
+	// cursors, if set, holds the dequeue cursor kept for each log between passes.
+	cursors map[int64]*log.DequeueCursor
+	// resignInterval is passed to each log.Sequencer.
+	resignInterval time.Duration
+	// hasher is used by every sequencer.
+	hasher merkle.TreeHasher
+	// ordering is the order in which sequencers assign indices to queued leaves.
+	ordering log.OrderingPolicy
+	// metrics, if set, is where each sequencer records its metrics.
+	metrics monitoring.Metrics
+	// batchTimeout is passed to each log.Sequencer.
+	batchTimeout time.Duration
+	// nodeCacheSize, if positive, is the size of the Merkle node cache kept for each log
+	// in nodeCaches.
+	nodeCacheSize int
+	nodeCaches    map[int64]*cache.NodeCache
 }
 
 // NewSequencerManager creates a new SequencerManager instance based on the provided KeyManager instance
 // and guard window.
 func NewSequencerManager(registry extension.Registry, gw time.Duration) *SequencerManager {
+	th, _ := merkle.Factory(merkle.RFC6962SHA256Type)
 	return &SequencerManager{
 		guardWindow: gw,
 		registry:    registry,
+		maxLeafSize: log.DefaultMaxLeafSize,
+		hasher:      th,
+	}
+}
+
+// SetDryRun controls whether sequencing passes roll back their changes instead of
+// committing them. See log.Sequencer.SetDryRun.
+func (s *SequencerManager) SetDryRun(dryRun bool) {
+	s.dryRun = dryRun
+}
+
+// SetHasher sets the hasher each sequencer uses to compute Merkle tree nodes and roots.
+// The default is the RFC6962 SHA256 hasher.
+func (s *SequencerManager) SetHasher(th merkle.TreeHasher) {
+	s.hasher = th
+}
+
+// SetMetrics sets where sequencers record their metrics. See log.Sequencer.SetMetrics.
+func (s *SequencerManager) SetMetrics(m monitoring.Metrics) {
+	s.metrics = m
+}
+
+// SetEventLogger sets the logger used to record sequencing events. See
+// log.Sequencer.SetEventLogger.
+func (s *SequencerManager) SetEventLogger(l util.EventLogger) {
+	s.eventLogger = l
+}
+
+// SetHashConcurrency sets the number of goroutines each sequencer uses to hash Merkle
+// tree nodes. See log.Sequencer.SetHashConcurrency.
+func (s *SequencerManager) SetHashConcurrency(workers int) {
+	s.hashConcurrency = workers
+}
+
+// SetSkipInvalidLeaves controls whether sequencers skip invalid leaves instead of
+// failing the batch. See log.Sequencer.SetSkipInvalidLeaves.
+func (s *SequencerManager) SetSkipInvalidLeaves(skip bool) {
+	s.skipInvalidLeaves = skip
+}
+
+// SetMaxLeafSize sets the largest leaf value sequencers will integrate. See
+// log.Sequencer.SetMaxLeafSize.
+func (s *SequencerManager) SetMaxLeafSize(n int) {
+	s.maxLeafSize = n
+}
+
+// SetOrderingPolicy sets the order in which sequencers assign indices to queued leaves.
+// See log.Sequencer.SetOrderingPolicy.
+func (s *SequencerManager) SetOrderingPolicy(p log.OrderingPolicy) {
+	s.ordering = p
+}
+
+// SetLeafRateLimit limits how fast leaves are integrated into each log, to
+// leavesPerSecond averaged across passes. Zero means no limit. See
+// log.Sequencer.SetRateLimiter.
+func (s *SequencerManager) SetLeafRateLimit(leavesPerSecond float64) {
+	s.leafRate = leavesPerSecond
+	s.rateLimiters = make(map[int64]*log.LeafRateLimiter)
+}
+
+// SetForceResignInterval sets the age at which sequencers sign an unchanged root again.
+// See log.Sequencer.SetForceResignInterval.
+func (s *SequencerManager) SetForceResignInterval(d time.Duration) {
+	s.resignInterval = d
+}
+
+// SetBatchTimeout limits how long each sequencer's batch may take. See
+// log.Sequencer.SetBatchTimeout.
+func (s *SequencerManager) SetBatchTimeout(d time.Duration) {
+	s.batchTimeout = d
+}
+
+// SetNodeCacheSize sets the number of Merkle nodes cached for each log between passes.
+// Zero, the default, means no nodes are cached. See log.Sequencer.SetNodeCache. The
+// cached nodes are only guaranteed to be current if no other process sequences the logs.
+func (s *SequencerManager) SetNodeCacheSize(n int) {
+	s.nodeCacheSize = n
+	s.nodeCaches = make(map[int64]*cache.NodeCache)
+}
+
+// SetDequeuePaging controls whether each log's batches page through its queue, using a
+// cursor kept between passes, rather than reading from the start of the queue every
+// pass. See log.Sequencer.SetDequeueCursor. The default is not to page.
+func (s *SequencerManager) SetDequeuePaging(enabled bool) {
+	s.cursors = nil
+	if enabled {
+		s.cursors = make(map[int64]*log.DequeueCursor)
+	}
+}
+
+// cursor returns the dequeue cursor for logID, creating it if this is the log's first pass.
+func (s SequencerManager) cursor(logID int64) *log.DequeueCursor {
+	c, ok := s.cursors[logID]
+	if !ok {
+		c = &log.DequeueCursor{}
+		s.cursors[logID] = c
+	}
+	return c
+}
+
+// nodeCache returns the Merkle node cache for logID, creating it if this is the log's
+// first pass.
+func (s SequencerManager) nodeCache(logID int64) *cache.NodeCache {
+	c, ok := s.nodeCaches[logID]
+	if !ok {
+		c = cache.NewNodeCache(s.nodeCacheSize)
+		s.nodeCaches[logID] = c
+	}
+	return c
+}
+
+// rateLimiter returns the limiter for logID, creating it if this is the log's first
+// pass. The burst allows for the leaves that accumulate while the manager sleeps
+// between passes, so that sleeping does not lower the rate achieved.
+func (s SequencerManager) rateLimiter(logID int64, logctx LogOperationManagerContext) *log.LeafRateLimiter {
+	if l, ok := s.rateLimiters[logID]; ok {
+		return l
+	}
+	period := logctx.sleepBetweenRuns
+	if period < time.Second {
+		period = time.Second
+	}
+	burst := int(math.Ceil(s.leafRate * period.Seconds()))
+	l := log.NewLeafRateLimiter(s.leafRate, burst, logctx.timeSource)
+	s.rateLimiters[logID] = l
+	return l
+}
+
+// newSequencer creates a log.Sequencer for logID with the manager's settings.
+func (s SequencerManager) newSequencer(logID int64, ls storage.LogStorage, keyManager crypto.PrivateKeyManager, logctx LogOperationManagerContext) *log.Sequencer {
+	sequencer := log.NewSequencer(s.hasher, logctx.timeSource, ls, keyManager)
+	sequencer.SetGuardWindow(s.guardWindow)
+	sequencer.SetRetryPolicy(sequencerRetryPolicy)
+	sequencer.SetDryRun(s.dryRun)
+	if s.eventLogger != nil {
+		sequencer.SetEventLogger(s.eventLogger)
+	}
+	if s.metrics != nil {
+		sequencer.SetMetrics(s.metrics)
+	}
+	if s.hashConcurrency != 0 {
+		sequencer.SetHashConcurrency(s.hashConcurrency)
+	}
+	sequencer.SetSkipInvalidLeaves(s.skipInvalidLeaves)
+	sequencer.SetMaxLeafSize(s.maxLeafSize)
+	sequencer.SetOrderingPolicy(s.ordering)
+	sequencer.SetForceResignInterval(s.resignInterval)
+	sequencer.SetBatchTimeout(s.batchTimeout)
+	if s.leafRate > 0 {
+		sequencer.SetRateLimiter(s.rateLimiter(logID, logctx))
+	}
+	if s.cursors != nil {
+		sequencer.SetDequeueCursor(s.cursor(logID))
+	}
+	if s.nodeCacheSize > 0 {
+		sequencer.SetNodeCache(s.nodeCache(logID))
+	}
+	return sequencer
+}
+
+// DrainResult describes the sequencing done by Drain for a log.
+type DrainResult struct {
+	LogID int64
+	// Batches is the number of batches that integrated leaves.
+	Batches int
+	// LeafCount is the number of leaves integrated into the tree.
+	LeafCount int
+	// TreeSize is the size of the tree after the last batch.
+	TreeSize int64
+}
+
+// Drain sequences batches of up to batchSize leaves for each of logIDs in turn, one
+// after another, until a batch finds no leaves to dequeue. Leaves still inside the
+// guard window, or held back by the leaf rate limit, are left queued. A batch that
+// fails with a transient error, including one that times out, is retried after a
+// backoff; Drain gives up on the log after drainMaxFailures consecutive failures, or at
+// once if a batch fails for another reason or ctx is done. It returns the results for
+// the logs drained so far. Drain cannot be used in dry-run mode, where the queue is
+// never emptied.
+func (s SequencerManager) Drain(ctx context.Context, logIDs []int64, batchSize int, timeSource util.TimeSource) ([]DrainResult, error) {
+	if s.dryRun {
+		return nil, errors.New("cannot drain logs in dry-run mode")
+	}
+	ls, err := s.registry.GetLogStorage()
+	if err != nil {
+		return nil, err
+	}
+	logctx := LogOperationManagerContext{ctx: ctx, registry: s.registry, batchSize: batchSize, timeSource: timeSource, numSequencers: 1}
+
+	var results []DrainResult
+	for _, logID := range logIDs {
+		keyManager, err := s.registry.GetKeyManager(logID)
+		if err != nil {
+			return results, fmt.Errorf("no key manager for log %d: %v", logID, err)
+		}
+		res, err := drainLog(ctx, s.newSequencer(logID, ls, keyManager, logctx), logID, batchSize)
+		results = append(results, res)
+		if err != nil {
+			return results, fmt.Errorf("log %d: %w", logID, err)
+		}
+		glog.Infof("%v: drained %d leaves in %d batches, tree size %d", logID, res.LeafCount, res.Batches, res.TreeSize)
+	}
+	return results, nil
+}
+
+// drainLog runs sequencer until a batch for logID finds the queue empty.
+func drainLog(ctx context.Context, sequencer *log.Sequencer, logID int64, limit int) (DrainResult, error) {
+	res := DrainResult{LogID: logID}
+	b := drainBackoff
+	b.Reset()
+	for first, failures := true, 0; ; {
+		batch, err := sequencer.SequenceBatch(util.NewLogContext(ctx, logID), logID, limit)
+		if err != nil {
+			if ctx.Err() != nil {
+				return res, ctx.Err()
+			}
+			failures++
+			if !storage.IsTransient(err) || failures >= drainMaxFailures {
+				return res, err
+			}
+			wait := b.Duration()
+			glog.Warningf("%v: sequencing another batch in %v after failure %d: %v", logID, wait, failures, err)
+			select {
+			case <-ctx.Done():
+				return res, ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+		failures = 0
+		b.Reset()
+		res.TreeSize = batch.TreeSize
+		// The first batch for a fresh log only signs its empty root and leaves the queue
+		// untouched, so it does not show that the queue is empty.
+		if batch.LeafCount+len(batch.Duplicates)+len(batch.Skipped) == 0 && !(first && batch.TreeSize == 0) {
+			return res, nil
+		}
+		first = false
+		if batch.LeafCount > 0 {
+			res.Batches++
+			res.LeafCount += batch.LeafCount
+		}
 	}
 }
 
@@ -45,86 +346,59 @@ func (s SequencerManager) Name() string {
 	return "Sequencer"
 }
 
-// ExecutePass performs sequencing for the specified set of Logs.
-func (s SequencerManager) ExecutePass(logIDs []int64, logctx LogOperationManagerContext) {
+// ExecutePass performs sequencing for the specified set of Logs. It returns the number of
+// queued leaves that were dequeued, whether they were integrated or found to be duplicates
+// or invalid.
+func (s SequencerManager) ExecutePass(logIDs []int64, logctx LogOperationManagerContext) int {
 	if logctx.numSequencers == 0 {
 		glog.Warning("Called ExecutePass with numSequencers == 0, assuming 1")
 		logctx.numSequencers = 1
 	}
 	glog.V(1).Infof("Beginning sequencing run for %v active log(s) using %d sequencers", len(logIDs), logctx.numSequencers)
 
-	startBatch := time.Now()
+	startBatch := logctx.timeSource.Now()
 
-	var mu sync.Mutex
 	successCount := 0
 	leavesAdded := 0
+	dequeued := 0
 
 	storage, err := s.registry.GetLogStorage()
 	if err != nil {
 		glog.Warningf("Failed to acquire log storage: %v", err)
-		return
+		return 0
 	}
 
-	var wg sync.WaitGroup
-	toSeq := make(chan int64, len(logIDs))
-
+	jobs := make([]log.SequencerJob, 0, len(logIDs))
 	for _, logID := range logIDs {
-		toSeq <- logID
-	}
-	close(toSeq)
-
-	for i := 0; i < logctx.numSequencers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for {
-				logID, more := <-toSeq
-				if !more {
-					return
-				}
-
-				start := time.Now()
-
-				// TODO(Martin2112): Honor the sequencing enabled in log parameters, needs an API change
-				// so deferring it
-				ctx := util.NewLogContext(logctx.ctx, logID)
-
-				// TODO(Martin2112): Allow for different tree hashers to be used by different logs
-				hasher, err := merkle.Factory(merkle.RFC6962SHA256Type)
-				if err != nil {
-					glog.Errorf("Unknown hash strategy for log %d: %v", logID, err)
-					continue
-				}
-
-				keyManager, err := s.registry.GetKeyManager(logID)
-				if err != nil {
-					glog.Errorf("No key manager for log %d: %v", logID, err)
-					continue
-				}
-
-				sequencer := log.NewSequencer(hasher, logctx.timeSource, storage, keyManager)
-				sequencer.SetGuardWindow(s.guardWindow)
-
-				leaves, err := sequencer.SequenceBatch(ctx, logID, logctx.batchSize)
-				if err != nil {
-					glog.Warningf("%v: Error trying to sequence batch for: %v", logID, err)
-					continue
-				}
-				d := time.Now().Sub(start).Seconds()
-				glog.Infof("%v: sequenced %d leaves in %.2f seconds (%.2f qps)", logID, leaves, d, float64(leaves)/d)
-
-				mu.Lock()
-				successCount++
-				leavesAdded += leaves
-				mu.Unlock()
-			}
-		}()
+		// TODO(Martin2112): Honor the sequencing enabled in log parameters, needs an API change
+		// so deferring it
+
+		// TODO(Martin2112): Allow for different tree hashers to be used by different logs
+		keyManager, err := s.registry.GetKeyManager(logID)
+		if err != nil {
+			glog.Errorf("No key manager for log %d: %v", logID, err)
+			continue
+		}
+
+		sequencer := s.newSequencer(logID, storage, keyManager, logctx)
+		jobs = append(jobs, log.SequencerJob{LogID: logID, Sequencer: sequencer, Limit: logctx.batchSize})
 	}
 
-	wg.Wait()
-	d := time.Now().Sub(startBatch).Seconds()
+	for _, r := range log.RunSequencers(logctx.ctx, jobs, logctx.numSequencers, logctx.timeSource) {
+		if r.Err != nil {
+			glog.Warningf("Error trying to sequence batch: %v", r.Err)
+			continue
+		}
+		leaves := r.Result.LeafCount
+		d := r.Duration.Seconds()
+		glog.Infof("%v: sequenced %d leaves in %.2f seconds (%.2f qps), tree size %d, root hash %x", r.LogID, leaves, d, float64(leaves)/d, r.Result.TreeSize, r.Result.RootHash)
+
+		successCount++
+		leavesAdded += leaves
+		dequeued += leaves + len(r.Result.Duplicates) + len(r.Result.Skipped)
+	}
 
-	mu.Lock()
-	defer mu.Unlock()
+	d := logctx.timeSource.Now().Sub(startBatch).Seconds()
 	glog.V(1).Infof("Sequencing group run completed in %.2f seconds: %v succeeded, %v failed, %v leaves integrated", d, successCount, len(logIDs)-successCount, leavesAdded)
+	return dequeued
 }
@@ -17,6 +17,12 @@ package server
 import (
 	"context"
 	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
@@ -26,6 +32,8 @@ import (
 	"github.com/google/trillian/crypto/sigpb"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	storageto "github.com/google/trillian/storage/testonly"
 	"github.com/google/trillian/testonly"
 	"github.com/google/trillian/util"
 )
@@ -43,10 +51,11 @@ var testLeaf0 = &trillian.LogLeaf{
 	LeafIndex:      0,
 }
 var testLeaf0Updated = &trillian.LogLeaf{
-	MerkleLeafHash: testonly.MustDecodeBase64("bjQLnP+zepicpUTmu3gKLHiQHT+zNzh2hRGjBhevoB0="),
-	LeafValue:      nil,
-	ExtraData:      nil,
-	LeafIndex:      0,
+	MerkleLeafHash:          testonly.MustDecodeBase64("bjQLnP+zepicpUTmu3gKLHiQHT+zNzh2hRGjBhevoB0="),
+	LeafValue:               nil,
+	ExtraData:               nil,
+	LeafIndex:               0,
+	IntegrateTimestampNanos: fakeTime.UnixNano(),
 }
 var testRoot0 = trillian.SignedLogRoot{
 	TreeSize:     0,
@@ -70,6 +79,22 @@ var updatedRoot = trillian.SignedLogRoot{
 		Signature:          []byte("signed"),
 	},
 	TreeRevision: 1,
+	KeyId:        testKeyID,
+}
+
+// testPublicKey is the public key of the mock key managers, and testKeyID is its ID.
+var testPublicKey, testKeyID = demoPublicKey()
+
+func demoPublicKey() (gocrypto.PublicKey, []byte) {
+	pub, err := crypto.PublicKeyFromPEM(testonly.DemoPublicKey)
+	if err != nil {
+		panic(fmt.Sprintf("PublicKeyFromPEM(): %v", err))
+	}
+	keyID, err := crypto.KeyID(pub)
+	if err != nil {
+		panic(fmt.Sprintf("KeyID(): %v", err))
+	}
+	return pub, keyID
 }
 
 var zeroDuration = 0 * time.Second
@@ -103,6 +128,7 @@ func TestSequencerManagerSingleLogNoLeaves(t *testing.T) {
 	mockTx.EXPECT().WriteRevision().AnyTimes().Return(writeRev)
 	mockTx.EXPECT().LatestSignedLogRoot().Return(testRoot0, nil)
 	mockTx.EXPECT().DequeueLeaves(50, fakeTime).Return([]*trillian.LogLeaf{}, nil)
+	mockTx.EXPECT().QueuedLeafCount().Return(int64(0), nil)
 	mockKeyManager := crypto.NewMockPrivateKeyManager(mockCtrl)
 	mockKeyManager.EXPECT().SignatureAlgorithm().AnyTimes().Return(sigpb.DigitallySigned_ECDSA)
 
@@ -122,6 +148,7 @@ func TestSequencerManagerSingleLogOneLeaf(t *testing.T) {
 	mockTx := storage.NewMockLogTreeTX(mockCtrl)
 	mockKeyManager := crypto.NewMockPrivateKeyManager(mockCtrl)
 	mockKeyManager.EXPECT().SignatureAlgorithm().AnyTimes().Return(sigpb.DigitallySigned_ECDSA)
+	mockKeyManager.EXPECT().Public().AnyTimes().Return(testPublicKey)
 	var logID int64 = 1
 
 	// Set up enough mockery to be able to sequence. We don't test all the error paths
@@ -130,13 +157,14 @@ func TestSequencerManagerSingleLogOneLeaf(t *testing.T) {
 	mockTx.EXPECT().Close().Return(nil)
 	mockTx.EXPECT().WriteRevision().AnyTimes().Return(testRoot0.TreeRevision + 1)
 	mockTx.EXPECT().DequeueLeaves(50, fakeTime).Return([]*trillian.LogLeaf{testLeaf0}, nil)
+	mockTx.EXPECT().QueuedLeafCount().Return(int64(0), nil)
 	mockTx.EXPECT().LatestSignedLogRoot().Return(testRoot0, nil)
 	mockTx.EXPECT().UpdateSequencedLeaves([]*trillian.LogLeaf{testLeaf0Updated}).Return(nil)
 	mockTx.EXPECT().SetMerkleNodes(updatedNodes0).Return(nil)
 	mockTx.EXPECT().StoreSignedLogRoot(updatedRoot).Return(nil)
 	mockStorage.EXPECT().BeginForTree(gomock.Any(), logID).Return(mockTx, nil)
 
-	mockKeyManager.EXPECT().Sign(gomock.Any(), []byte{23, 147, 61, 51, 131, 170, 136, 10, 82, 12, 93, 42, 98, 88, 131, 100, 101, 187, 124, 189, 202, 207, 66, 137, 95, 117, 205, 34, 109, 242, 103, 248}, gocrypto.SHA256).Return([]byte("signed"), nil)
+	mockKeyManager.EXPECT().Sign(gomock.Any(), []byte{154, 185, 204, 24, 53, 48, 136, 230, 104, 43, 206, 125, 24, 232, 3, 114, 201, 188, 77, 0, 53, 64, 235, 208, 212, 156, 79, 45, 65, 31, 179, 192}, gocrypto.SHA256).Return([]byte("signed"), nil)
 
 	registry := extension.NewMockRegistry(mockCtrl)
 	registry.EXPECT().GetLogStorage().Return(mockStorage, nil)
@@ -161,6 +189,7 @@ func TestSequencerManagerGuardWindow(t *testing.T) {
 	mockTx.EXPECT().LatestSignedLogRoot().Return(testRoot0, nil)
 	// Expect a 5 second guard window to be passed from manager -> sequencer -> storage
 	mockTx.EXPECT().DequeueLeaves(50, fakeTime.Add(-time.Second*5)).Return([]*trillian.LogLeaf{}, nil)
+	mockTx.EXPECT().QueuedLeafCount().Return(int64(0), nil)
 	mockKeyManager := crypto.NewMockPrivateKeyManager(mockCtrl)
 
 	registry := extension.NewMockRegistry(mockCtrl)
@@ -183,3 +212,119 @@ func createTestContext(registry extension.Registry) LogOperationManagerContext {
 		timeSource:       fakeTimeSource,
 	}
 }
+
+func TestSequencerManagerRateLimiter(t *testing.T) {
+	sm := NewSequencerManager(nil, zeroDuration)
+	sm.SetLeafRateLimit(10)
+	logctx := createTestContext(nil)
+	logctx.sleepBetweenRuns = 5 * time.Second
+
+	l := sm.rateLimiter(1, logctx)
+	if got := sm.rateLimiter(1, logctx); got != l {
+		t.Error("rateLimiter() returned a new limiter for a log's second pass, want the same one")
+	}
+	if got := sm.rateLimiter(2, logctx); got == l {
+		t.Error("rateLimiter() returned the same limiter for different logs")
+	}
+	// The burst covers the leaves that accumulate over a sleep between passes.
+	if got, want := l.Allow(1000), 50; got != want {
+		t.Errorf("rateLimiter().Allow(1000)=%d, want %d", got, want)
+	}
+}
+
+func TestSequencerManagerDequeueCursor(t *testing.T) {
+	sm := NewSequencerManager(nil, zeroDuration)
+	sm.SetDequeuePaging(true)
+
+	c := sm.cursor(1)
+	if got := sm.cursor(1); got != c {
+		t.Error("cursor() returned a new cursor for a log's second pass, want the same one")
+	}
+	if got := sm.cursor(2); got == c {
+		t.Error("cursor() returned the same cursor for different logs")
+	}
+}
+
+func TestSequencerManagerDrain(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	keyManager, err := crypto.NewFromPrivateKey(key)
+	if err != nil {
+		t.Fatalf("NewFromPrivateKey()=(_,%v), want (_,nil)", err)
+	}
+	ls := memory.NewLogStorage()
+	tree, err := ls.CreateLog(storageto.LogTree)
+	if err != nil {
+		t.Fatalf("CreateLog()=(_,%v), want (_,nil)", err)
+	}
+	logID := tree.TreeId
+	ctx := util.NewLogContext(context.Background(), logID)
+
+	// The backlog takes several batches to sequence, and must all be sequenced in one run,
+	// starting with the batch that signs the fresh log's first root.
+	const numLeaves, batchSize = 23, 5
+	var leaves []*trillian.LogLeaf
+	for i := 0; i < numLeaves; i++ {
+		value := []byte(fmt.Sprintf("leaf %d", i))
+		identityHash := sha256.Sum256(value)
+		leaves = append(leaves, &trillian.LogLeaf{LeafIdentityHash: identityHash[:], MerkleLeafHash: testonly.Hasher.HashLeaf(value), LeafValue: value})
+	}
+	tx, err := ls.BeginForTree(ctx, logID)
+	if err != nil {
+		t.Fatalf("BeginForTree()=(_,%v), want (_,nil)", err)
+	}
+	if err := tx.QueueLeaves(leaves, fakeTime); err != nil {
+		t.Fatalf("QueueLeaves()=%v, want nil", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit()=%v, want nil", err)
+	}
+
+	registry := extension.NewMockRegistry(mockCtrl)
+	registry.EXPECT().GetLogStorage().Return(ls, nil)
+	registry.EXPECT().GetKeyManager(logID).Return(keyManager, nil)
+	sm := NewSequencerManager(registry, zeroDuration)
+	// Each root needs a later timestamp than the last.
+	timeSource := &util.IncrementingFakeTimeSource{BaseTime: fakeTime}
+	for i := 0; i < 1000; i++ {
+		timeSource.Increments = append(timeSource.Increments, time.Duration(i)*time.Millisecond)
+	}
+
+	results, err := sm.Drain(ctx, []int64{logID}, batchSize, timeSource)
+	if err != nil {
+		t.Fatalf("Drain()=(_,%v), want (_,nil)", err)
+	}
+	want := []DrainResult{{LogID: logID, Batches: 5, LeafCount: numLeaves, TreeSize: numLeaves}}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("Drain()=%+v, want %+v", results, want)
+	}
+
+	tx, err = ls.BeginForTree(ctx, logID)
+	if err != nil {
+		t.Fatalf("BeginForTree()=(_,%v), want (_,nil)", err)
+	}
+	defer tx.Close()
+	if got, err := tx.QueuedLeafCount(); err != nil || got != 0 {
+		t.Errorf("QueuedLeafCount()=(%d,%v), want (0,nil)", got, err)
+	}
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot()=(_,%v), want (_,nil)", err)
+	}
+	if got, want := root.TreeSize, int64(numLeaves); got != want {
+		t.Errorf("LatestSignedLogRoot().TreeSize=%d, want %d", got, want)
+	}
+}
+
+func TestSequencerManagerDrainDryRun(t *testing.T) {
+	sm := NewSequencerManager(nil, zeroDuration)
+	sm.SetDryRun(true)
+	if _, err := sm.Drain(context.Background(), []int64{1}, 5, fakeTimeSource); err == nil {
+		t.Error("Drain() in dry-run mode=(_,nil), want (_,err)")
+	}
+}
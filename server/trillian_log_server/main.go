@@ -15,6 +15,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"net"
@@ -24,6 +25,8 @@ import (
 	"github.com/google/trillian"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/extension/builtin"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/merkle"
 	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/server"
 	"github.com/google/trillian/server/admin"
@@ -33,9 +36,11 @@ import (
 )
 
 var (
-	serverPortFlag   = flag.Int("port", 8090, "Port to serve log RPC requests on")
-	exportRPCMetrics = flag.Bool("export_metrics", true, "If true starts HTTP server and exports stats")
-	httpPortFlag     = flag.Int("http_port", 8091, "Port to serve HTTP metrics on")
+	serverPortFlag            = flag.Int("port", 8090, "Port to serve log RPC requests on")
+	exportRPCMetrics          = flag.Bool("export_metrics", true, "If true starts HTTP server and exports stats")
+	httpPortFlag              = flag.Int("http_port", 8091, "Port to serve HTTP metrics on")
+	maxLeafSizeFlag           = flag.Int("max_leaf_size", log.DefaultMaxLeafSize, "Largest leaf value, in bytes, that can be queued. Zero means no limit")
+	storageStartupTimeoutFlag = flag.Duration("storage_startup_timeout", 30*time.Second, "How long to keep retrying the storage check made at startup before exiting")
 )
 
 func startRPCServer(registry extension.Registry) (*grpc.Server, error) {
@@ -47,6 +52,12 @@ func startRPCServer(registry extension.Registry) (*grpc.Server, error) {
 	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(statsInterceptor.Interceptor()))
 
 	logServer := server.NewTrillianLogRPCServer(registry, new(util.SystemTimeSource))
+	logServer.SetMaxLeafSize(*maxLeafSizeFlag)
+	hasher, err := merkle.Factory(*builtin.HashStrategyFlag)
+	if err != nil {
+		return nil, err
+	}
+	logServer.SetHasher(hasher)
 	if err := logServer.IsHealthy(); err != nil {
 		return nil, err
 	}
@@ -69,6 +80,13 @@ func main() {
 	if err != nil {
 		glog.Exitf("Failed to create extension registry: %v", err)
 	}
+	logStorage, err := registry.GetLogStorage()
+	if err != nil {
+		glog.Exitf("Failed to get log storage: %v", err)
+	}
+	if err := server.CheckStorageAtStartup(context.Background(), logStorage, *storageStartupTimeoutFlag); err != nil {
+		glog.Exitf("Failed to start: %v", err)
+	}
 
 	// Start HTTP server (optional)
 	if *exportRPCMetrics {
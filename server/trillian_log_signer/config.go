@@ -0,0 +1,387 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/extension/builtin"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+)
+
+// signerConfig holds the settings that can be read from a --config file. Each setting
+// has a flag of the same name, which takes precedence over the file if it is set.
+type signerConfig struct {
+	// StorageBackend is the storage implementation to use, as for --storage_backend.
+	StorageBackend string `json:"storage_backend"`
+	// StorageURI is the connection string for the storage backend. It sets --mysql_uri
+	// or --postgres_uri depending on the backend.
+	StorageURI string `json:"storage_uri"`
+	// LogIDs are the IDs of the logs to sequence, as for --log_ids.
+	LogIDs []int64 `json:"log_ids"`
+	// BatchSize is the maximum number of leaves in a batch, as for --batch_size.
+	BatchSize int `json:"batch_size"`
+	// NumSequencers is the number of sequencers to run in parallel, as for --num_sequencers.
+	NumSequencers int `json:"num_sequencers"`
+	// SleepBetweenRuns is the pause after each pass, as for --sequencer_sleep_between_runs.
+	SleepBetweenRuns duration `json:"sequencer_sleep_between_runs"`
+	// GuardWindow is the sequencer guard window, as for --sequencer_guard_window.
+	GuardWindow duration `json:"sequencer_guard_window"`
+	// MaxLeafSize is the largest leaf value that will be integrated, as for --max_leaf_size.
+	MaxLeafSize int `json:"max_leaf_size"`
+	// LeafRate limits the leaves per second integrated into each log, as for --leaf_rate.
+	LeafRate float64 `json:"leaf_rate"`
+	// HashStrategy is the Merkle tree hasher to use, as for --hash_strategy.
+	HashStrategy string `json:"hash_strategy"`
+	// SequencingOrder is the order in which leaves are assigned indices, as for
+	// --sequencing_order.
+	SequencingOrder string `json:"sequencing_order"`
+}
+
+// duration is a time.Duration written in JSON as a string such as "10s".
+type duration struct {
+	time.Duration
+}
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"10s\": %v", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// loadConfig reads a signerConfig from the JSON file at path.
+func loadConfig(path string) (*signerConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(data)
+}
+
+// parseConfig parses a JSON signerConfig. Unknown settings are an error, so that
+// misspelt ones are not silently ignored.
+func parseConfig(data []byte) (*signerConfig, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var cfg signerConfig
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	return &cfg, nil
+}
+
+func (c *signerConfig) validate() error {
+	switch c.StorageBackend {
+	case "", builtin.MySQLBackend, builtin.PostgresBackend:
+	default:
+		return fmt.Errorf("unknown storage_backend %q", c.StorageBackend)
+	}
+	if c.HashStrategy != "" {
+		if _, err := merkle.Factory(c.HashStrategy); err != nil {
+			return fmt.Errorf("unknown hash_strategy %q", c.HashStrategy)
+		}
+	}
+	if c.SequencingOrder != "" {
+		if _, err := log.ParseOrderingPolicy(c.SequencingOrder); err != nil {
+			return fmt.Errorf("invalid sequencing_order: %v", err)
+		}
+	}
+	for _, id := range c.LogIDs {
+		if id <= 0 {
+			return fmt.Errorf("invalid log ID %d", id)
+		}
+	}
+	if c.BatchSize < 0 {
+		return fmt.Errorf("batch_size must not be negative, got %d", c.BatchSize)
+	}
+	if c.NumSequencers < 0 {
+		return fmt.Errorf("num_sequencers must not be negative, got %d", c.NumSequencers)
+	}
+	if c.MaxLeafSize < 0 {
+		return fmt.Errorf("max_leaf_size must not be negative, got %d", c.MaxLeafSize)
+	}
+	if c.LeafRate < 0 {
+		return fmt.Errorf("leaf_rate must not be negative, got %v", c.LeafRate)
+	}
+	if c.SleepBetweenRuns.Duration < 0 || c.GuardWindow.Duration < 0 {
+		return fmt.Errorf("durations must not be negative")
+	}
+	return nil
+}
+
+// apply sets the flags in fs from the settings in c, except for flags which were set
+// on the command line. Settings missing from the file leave their flags unchanged.
+func (c *signerConfig) apply(fs *flag.FlagSet) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	values := make(map[string]string)
+	if c.StorageBackend != "" {
+		values["storage_backend"] = c.StorageBackend
+	}
+	if c.HashStrategy != "" {
+		values["hash_strategy"] = c.HashStrategy
+	}
+	if c.SequencingOrder != "" {
+		values["sequencing_order"] = c.SequencingOrder
+	}
+	if len(c.LogIDs) > 0 {
+		ids := make([]string, 0, len(c.LogIDs))
+		for _, id := range c.LogIDs {
+			ids = append(ids, strconv.FormatInt(id, 10))
+		}
+		values["log_ids"] = strings.Join(ids, ",")
+	}
+	if c.BatchSize > 0 {
+		values["batch_size"] = strconv.Itoa(c.BatchSize)
+	}
+	if c.NumSequencers > 0 {
+		values["num_sequencers"] = strconv.Itoa(c.NumSequencers)
+	}
+	if c.SleepBetweenRuns.Duration > 0 {
+		values["sequencer_sleep_between_runs"] = c.SleepBetweenRuns.String()
+	}
+	if c.GuardWindow.Duration > 0 {
+		values["sequencer_guard_window"] = c.GuardWindow.String()
+	}
+	if c.MaxLeafSize > 0 {
+		values["max_leaf_size"] = strconv.Itoa(c.MaxLeafSize)
+	}
+	if c.LeafRate > 0 {
+		values["leaf_rate"] = strconv.FormatFloat(c.LeafRate, 'g', -1, 64)
+	}
+	for name, value := range values {
+		if set[name] {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("failed to set --%s from config: %v", name, err)
+		}
+	}
+
+	// The URI applies to whichever backend is in use once the flags above are applied.
+	if c.StorageURI != "" {
+		uriFlag := "mysql_uri"
+		if fs.Lookup("storage_backend").Value.String() == builtin.PostgresBackend {
+			uriFlag = "postgres_uri"
+		}
+		if !set[uriFlag] {
+			if err := fs.Set(uriFlag, c.StorageURI); err != nil {
+				return fmt.Errorf("failed to set --%s from config: %v", uriFlag, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkLogIDs returns an error if any of logIDs is not an active log in s.
+func checkLogIDs(ctx context.Context, s storage.LogStorage, logIDs []int64) error {
+	if len(logIDs) == 0 {
+		return nil
+	}
+	tx, err := s.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	active, err := tx.GetActiveLogIDs()
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	known := make(map[int64]bool)
+	for _, id := range active {
+		known[id] = true
+	}
+	for _, id := range logIDs {
+		if !known[id] {
+			return fmt.Errorf("log %d does not exist", id)
+		}
+	}
+	return nil
+}
+
+// activeLogIDs returns logIDs or, if it is empty, the IDs of every active log in s.
+func activeLogIDs(ctx context.Context, s storage.LogStorage, logIDs []int64) ([]int64, error) {
+	if len(logIDs) > 0 {
+		return logIDs, nil
+	}
+	tx, err := s.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	if logIDs, err = tx.GetActiveLogIDs(); err != nil {
+		return nil, err
+	}
+	return logIDs, tx.Commit()
+}
+
+// verifyLogs runs log.VerifyTreeIntegrity with th for each of logIDs, or for every active
+// log if logIDs is empty. It returns the IDs of the logs that failed the check, logging why.
+func verifyLogs(ctx context.Context, s storage.LogStorage, th merkle.TreeHasher, logIDs []int64) ([]int64, error) {
+	logIDs, err := activeLogIDs(ctx, s, logIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []int64
+	for _, logID := range logIDs {
+		if err := log.VerifyTreeIntegrity(ctx, s, th, logID); err != nil {
+			glog.Errorf("%v: integrity check failed: %v", logID, err)
+			failed = append(failed, logID)
+			continue
+		}
+		glog.Infof("%v: integrity check passed", logID)
+	}
+	return failed, nil
+}
+
+// hashStrategies maps the --hash_strategy values that can be recorded as a tree's
+// HashStrategy to that strategy.
+var hashStrategies = map[string]trillian.HashStrategy{
+	merkle.RFC6962SHA256Type: trillian.HashStrategy_RFC_6962,
+	merkle.PlainSHA256Type:   trillian.HashStrategy_PLAIN_SHA256,
+	merkle.RFC6962SHA3Type:   trillian.HashStrategy_RFC_6962_SHA3_256,
+}
+
+// initLogs creates each of logIDs in s, hashed using the hasher named by hashType, and
+// stores its first root, for the empty tree, signed with the key returned for the log by
+// keyManager. Creating a log that already exists fails, and leaves it unchanged.
+func initLogs(ctx context.Context, s storage.LogStorage, hashType string, logIDs []int64, keyManager func(treeID int64) (crypto.PrivateKeyManager, error), timeSource util.TimeSource) error {
+	if len(logIDs) == 0 {
+		return errors.New("no log IDs given")
+	}
+	hashStrategy, ok := hashStrategies[hashType]
+	if !ok {
+		return fmt.Errorf("hash strategy %q cannot be recorded for a tree", hashType)
+	}
+	th, err := merkle.Factory(hashType)
+	if err != nil {
+		return err
+	}
+	for _, logID := range logIDs {
+		km, err := keyManager(logID)
+		if err != nil {
+			return fmt.Errorf("log %d: no key manager: %v", logID, err)
+		}
+		if err := s.CreateTree(ctx, logID, hashStrategy, km.SignatureAlgorithm()); err != nil {
+			return fmt.Errorf("log %d: %v", logID, err)
+		}
+		if err := log.NewSequencer(th, timeSource, s, km).SignRoot(ctx, logID); err != nil {
+			return fmt.Errorf("log %d: sign empty root: %v", logID, err)
+		}
+		glog.Infof("%v: created log and signed its empty root", logID)
+	}
+	return nil
+}
+
+// resignLogs stores a new root, hashed with th and signed with the key returned for the
+// log by keyManager, for each of logIDs, or for every active log if logIDs is empty. The
+// new root covers the same leaves as the latest one, and is signed as a
+// crypto.STHVersion1 STH, so running it once after an upgrade replaces the roots signed
+// in the unversioned legacy format.
+func resignLogs(ctx context.Context, s storage.LogStorage, th merkle.TreeHasher, logIDs []int64, keyManager func(treeID int64) (crypto.PrivateKeyManager, error), timeSource util.TimeSource) error {
+	logIDs, err := activeLogIDs(ctx, s, logIDs)
+	if err != nil {
+		return err
+	}
+	for _, logID := range logIDs {
+		km, err := keyManager(logID)
+		if err != nil {
+			return fmt.Errorf("log %d: no key manager: %v", logID, err)
+		}
+		if err := log.NewSequencer(th, timeSource, s, km).SignRoot(ctx, logID); err != nil {
+			return fmt.Errorf("log %d: re-sign root: %v", logID, err)
+		}
+		glog.Infof("%v: re-signed latest root", logID)
+	}
+	return nil
+}
+
+// listDeadLetters writes up to limit dead-lettered leaves of each of logIDs, or of every
+// active log if logIDs is empty, to w. Each line holds the log ID, the leaf identity hash
+// in hex, when the leaf was dead-lettered and the reason it was rejected.
+func listDeadLetters(ctx context.Context, s storage.LogStorage, logIDs []int64, limit int, w io.Writer) error {
+	logIDs, err := activeLogIDs(ctx, s, logIDs)
+	if err != nil {
+		return err
+	}
+	for _, logID := range logIDs {
+		letters, err := storage.ListDeadLetters(ctx, s, logID, limit)
+		if err != nil {
+			return fmt.Errorf("log %d: %v", logID, err)
+		}
+		for _, l := range letters {
+			when := time.Unix(0, l.TimestampNanos).UTC().Format(time.RFC3339)
+			if _, err := fmt.Fprintf(w, "%d\t%x\t%s\t%s\n", logID, l.LeafIdentityHash, when, l.Reason); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// requeueDeadLetters queues the dead-lettered leaves with the comma separated hex identity
+// hashes in hashes again, with queueTimestamp, in the single log in logIDs.
+func requeueDeadLetters(ctx context.Context, s storage.LogStorage, logIDs []int64, hashes string, queueTimestamp time.Time) error {
+	if len(logIDs) != 1 {
+		return fmt.Errorf("got %d log IDs, want exactly one", len(logIDs))
+	}
+	var identityHashes [][]byte
+	for _, h := range strings.Split(hashes, ",") {
+		b, err := hex.DecodeString(strings.TrimSpace(h))
+		if err != nil || len(b) == 0 {
+			return fmt.Errorf("invalid leaf identity hash %q", h)
+		}
+		identityHashes = append(identityHashes, b)
+	}
+	for _, h := range identityHashes {
+		if err := storage.RequeueDeadLetter(ctx, s, logIDs[0], h, queueTimestamp); err != nil {
+			return fmt.Errorf("leaf %x: %v", h, err)
+		}
+		glog.Infof("%v: requeued dead-lettered leaf %x", logIDs[0], h)
+	}
+	return nil
+}
@@ -0,0 +1,493 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/storage/testonly"
+	trillian_testonly "github.com/google/trillian/testonly"
+	"github.com/google/trillian/util"
+)
+
+// newTestFlagSet returns a FlagSet with the flags that a signerConfig can set.
+func newTestFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("storage_backend", "mysql", "")
+	fs.String("mysql_uri", "mysql-default", "")
+	fs.String("postgres_uri", "postgres-default", "")
+	fs.String("log_ids", "", "")
+	fs.Int("batch_size", 50, "")
+	fs.Int("num_sequencers", 10, "")
+	fs.Duration("sequencer_sleep_between_runs", 10*time.Second, "")
+	fs.Duration("sequencer_guard_window", 0, "")
+	return fs
+}
+
+func TestParseConfig(t *testing.T) {
+	for _, test := range []struct {
+		desc    string
+		config  string
+		wantErr bool
+	}{
+		{desc: "empty", config: `{}`},
+		{
+			desc:   "all settings",
+			config: `{"storage_backend": "postgres", "storage_uri": "postgres://db", "log_ids": [1, 2], "batch_size": 100, "num_sequencers": 4, "sequencer_sleep_between_runs": "5s", "sequencer_guard_window": "1m", "max_leaf_size": 1024, "leaf_rate": 12.5, "hash_strategy": "PLAIN-SHA256", "sequencing_order": "unordered"}`,
+		},
+		{desc: "unknown setting", config: `{"batch_sise": 100}`, wantErr: true},
+		{desc: "bad duration", config: `{"sequencer_guard_window": "1 minute"}`, wantErr: true},
+		{desc: "numeric duration", config: `{"sequencer_guard_window": 60}`, wantErr: true},
+		{desc: "unknown backend", config: `{"storage_backend": "sqlite"}`, wantErr: true},
+		{desc: "unknown hash strategy", config: `{"hash_strategy": "MD5"}`, wantErr: true},
+		{desc: "unknown sequencing order", config: `{"sequencing_order": "lifo"}`, wantErr: true},
+		{desc: "bad log ID", config: `{"log_ids": [0]}`, wantErr: true},
+		{desc: "negative batch size", config: `{"batch_size": -1}`, wantErr: true},
+		{desc: "negative max leaf size", config: `{"max_leaf_size": -1}`, wantErr: true},
+		{desc: "negative leaf rate", config: `{"leaf_rate": -1}`, wantErr: true},
+		{desc: "not JSON", config: `batch_size: 100`, wantErr: true},
+	} {
+		_, err := parseConfig([]byte(test.config))
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%s: parseConfig()=%v, want err? %v", test.desc, err, test.wantErr)
+		}
+	}
+}
+
+func TestConfigApply(t *testing.T) {
+	const config = `{"storage_uri": "postgres://db", "log_ids": [1, 2], "batch_size": 100, "sequencer_guard_window": "1m"}`
+	for _, test := range []struct {
+		desc string
+		args []string
+		want map[string]string
+	}{
+		{
+			desc: "file only",
+			want: map[string]string{
+				"storage_backend":              "mysql",
+				"mysql_uri":                    "postgres://db",
+				"postgres_uri":                 "postgres-default",
+				"log_ids":                      "1,2",
+				"batch_size":                   "100",
+				"num_sequencers":               "10",
+				"sequencer_sleep_between_runs": "10s",
+				"sequencer_guard_window":       "1m0s",
+			},
+		},
+		{
+			desc: "flags override file",
+			args: []string{"--batch_size=7", "--log_ids=3", "--mysql_uri=flag-uri"},
+			want: map[string]string{
+				"mysql_uri":              "flag-uri",
+				"log_ids":                "3",
+				"batch_size":             "7",
+				"sequencer_guard_window": "1m0s",
+			},
+		},
+		{
+			desc: "flag default not overridden by file",
+			args: []string{"--sequencer_guard_window=0s"},
+			want: map[string]string{
+				"batch_size":             "100",
+				"sequencer_guard_window": "0s",
+			},
+		},
+		{
+			desc: "uri follows backend flag",
+			args: []string{"--storage_backend=postgres"},
+			want: map[string]string{
+				"mysql_uri":    "mysql-default",
+				"postgres_uri": "postgres://db",
+			},
+		},
+	} {
+		cfg, err := parseConfig([]byte(config))
+		if err != nil {
+			t.Fatalf("parseConfig()=%v, want nil", err)
+		}
+		fs := newTestFlagSet()
+		if err := fs.Parse(test.args); err != nil {
+			t.Fatalf("%s: Parse(%v)=%v, want nil", test.desc, test.args, err)
+		}
+		if err := cfg.apply(fs); err != nil {
+			t.Errorf("%s: apply()=%v, want nil", test.desc, err)
+			continue
+		}
+		for name, want := range test.want {
+			if got := fs.Lookup(name).Value.String(); got != want {
+				t.Errorf("%s: --%s=%q, want %q", test.desc, name, got, want)
+			}
+		}
+	}
+}
+
+func TestCheckLogIDs(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewLogStorage()
+	tree, err := s.CreateLog(testonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateLog()=%v, want nil", err)
+	}
+
+	for _, test := range []struct {
+		logIDs  []int64
+		wantErr bool
+	}{
+		{logIDs: nil},
+		{logIDs: []int64{tree.TreeId}},
+		{logIDs: []int64{tree.TreeId, tree.TreeId + 1}, wantErr: true},
+	} {
+		err := checkLogIDs(ctx, s, test.logIDs)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("checkLogIDs(%v)=%v, want err? %v", test.logIDs, err, test.wantErr)
+		}
+	}
+}
+
+func TestVerifyLogs(t *testing.T) {
+	ctx := context.Background()
+	th := trillian_testonly.Hasher
+	s := memory.NewLogStorage()
+	tree, err := s.CreateLog(testonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateLog()=%v, want nil", err)
+	}
+
+	for _, logIDs := range [][]int64{nil, {tree.TreeId}} {
+		failed, err := verifyLogs(ctx, s, th, logIDs)
+		if err != nil || len(failed) != 0 {
+			t.Errorf("verifyLogs(%v)=(%v,%v), want (nil,nil)", logIDs, failed, err)
+		}
+	}
+	unknown := tree.TreeId + 1
+	if failed, err := verifyLogs(ctx, s, th, []int64{tree.TreeId, unknown}); err != nil || len(failed) != 1 || failed[0] != unknown {
+		t.Errorf("verifyLogs(%v)=(%v,%v), want ([%v],nil)", []int64{tree.TreeId, unknown}, failed, err, unknown)
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewLogStorage()
+	tree, err := s.CreateLog(testonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateLog()=%v, want nil", err)
+	}
+	logID := tree.TreeId
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	keyManager, err := crypto.NewFromPrivateKey(key)
+	if err != nil {
+		t.Fatalf("NewFromPrivateKey()=(_,%v), want (_,nil)", err)
+	}
+	timeSource := &util.FakeTimeSource{FakeTime: time.Unix(1500000000, 0)}
+
+	// A leaf whose Merkle leaf hash is too short is dead-lettered by the sequencer.
+	identityHash := sha256.Sum256([]byte("bad leaf"))
+	tx, err := s.BeginForTree(ctx, logID)
+	if err != nil {
+		t.Fatalf("BeginForTree()=(_,%v), want (_,nil)", err)
+	}
+	if err := tx.QueueLeaves([]*trillian.LogLeaf{{LeafIdentityHash: identityHash[:], MerkleLeafHash: []byte("short")}}, timeSource.Now()); err != nil {
+		t.Fatalf("QueueLeaves()=%v, want nil", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit()=%v, want nil", err)
+	}
+	// The first pass initializes the tree and the second dequeues the leaf.
+	for i := 0; i < 2; i++ {
+		timeSource.FakeTime = timeSource.FakeTime.Add(time.Second)
+		sequencer := log.NewSequencer(trillian_testonly.Hasher, timeSource, s, keyManager)
+		sequencer.SetSkipInvalidLeaves(true)
+		if _, err := sequencer.SequenceBatch(ctx, logID, 10); err != nil {
+			t.Fatalf("SequenceBatch()=(_,%v), want (_,nil)", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := listDeadLetters(ctx, s, nil, 10, &buf); err != nil {
+		t.Fatalf("listDeadLetters()=%v, want nil", err)
+	}
+	want := fmt.Sprintf("%d\t%x\t2017-07-14T02:40:02Z\tMerkle leaf hash is 5 bytes, want 32\n", logID, identityHash)
+	if got := buf.String(); got != want {
+		t.Errorf("listDeadLetters() wrote %q, want %q", got, want)
+	}
+
+	hash := hex.EncodeToString(identityHash[:])
+	if err := requeueDeadLetters(ctx, s, nil, hash, timeSource.Now()); err == nil {
+		t.Error("requeueDeadLetters(no log IDs)=nil, want error")
+	}
+	if err := requeueDeadLetters(ctx, s, []int64{logID}, "not hex", timeSource.Now()); err == nil {
+		t.Error("requeueDeadLetters(not hex)=nil, want error")
+	}
+	if err := requeueDeadLetters(ctx, s, []int64{logID}, hash, timeSource.Now()); err != nil {
+		t.Fatalf("requeueDeadLetters()=%v, want nil", err)
+	}
+	buf.Reset()
+	if err := listDeadLetters(ctx, s, []int64{logID}, 10, &buf); err != nil || buf.Len() != 0 {
+		t.Errorf("listDeadLetters() after requeue=%v and wrote %q, want nil and nothing", err, buf.String())
+	}
+	rtx, err := s.BeginForTree(ctx, logID)
+	if err != nil {
+		t.Fatalf("BeginForTree()=(_,%v), want (_,nil)", err)
+	}
+	defer rtx.Close()
+	if got, err := rtx.QueuedLeafCount(); err != nil || got != 1 {
+		t.Errorf("QueuedLeafCount() after requeue=(%d,%v), want (1,nil)", got, err)
+	}
+}
+
+func TestInitLogs(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewLogStorage()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	keyManager, err := crypto.NewFromPrivateKey(key)
+	if err != nil {
+		t.Fatalf("NewFromPrivateKey()=(_,%v), want (_,nil)", err)
+	}
+	keyManagers := func(int64) (crypto.PrivateKeyManager, error) { return keyManager, nil }
+	timeSource := &util.FakeTimeSource{FakeTime: time.Unix(1500000000, 0)}
+	logIDs := []int64{101, 102}
+
+	if err := initLogs(ctx, s, merkle.RFC6962SHA256Type, logIDs, keyManagers, timeSource); err != nil {
+		t.Fatalf("initLogs()=%v, want nil", err)
+	}
+	if err := checkLogIDs(ctx, s, logIDs); err != nil {
+		t.Errorf("checkLogIDs(%v)=%v, want nil", logIDs, err)
+	}
+	for _, logID := range logIDs {
+		root := latestRoot(ctx, t, s, logID)
+		if root.TreeSize != 0 || root.RootHash == nil {
+			t.Errorf("log %d: root=%v, want signed empty root", logID, root)
+		}
+		if err := crypto.VerifySTH(key.Public(), crypto.STHFromRoot(root), root.Signature, crypto.STHVersion1); err != nil {
+			t.Errorf("log %d: VerifySTH()=%v, want nil", logID, err)
+		}
+	}
+
+	// Leaves are sequenced into an initialized log by the first batch.
+	leaves := make([]*trillian.LogLeaf, 3)
+	for i := range leaves {
+		value := []byte(fmt.Sprintf("leaf %d", i))
+		identityHash := sha256.Sum256(value)
+		leaves[i] = &trillian.LogLeaf{LeafIdentityHash: identityHash[:], MerkleLeafHash: trillian_testonly.Hasher.HashLeaf(value), LeafValue: value}
+	}
+	tx, err := s.BeginForTree(ctx, logIDs[0])
+	if err != nil {
+		t.Fatalf("BeginForTree()=(_,%v), want (_,nil)", err)
+	}
+	if err := tx.QueueLeaves(leaves, timeSource.Now()); err != nil {
+		t.Fatalf("QueueLeaves()=%v, want nil", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit()=%v, want nil", err)
+	}
+	timeSource.FakeTime = timeSource.FakeTime.Add(time.Second)
+	res, err := log.NewSequencer(trillian_testonly.Hasher, timeSource, s, keyManager).SequenceBatch(ctx, logIDs[0], 10)
+	if err != nil {
+		t.Fatalf("SequenceBatch()=(_,%v), want (_,nil)", err)
+	}
+	if res.LeafCount != len(leaves) || res.TreeSize != int64(len(leaves)) {
+		t.Errorf("SequenceBatch()=%+v, want %d leaves sequenced", res, len(leaves))
+	}
+
+	// Initializing the log again must fail and leave it as it was.
+	want := latestRoot(ctx, t, s, logIDs[0])
+	if err := initLogs(ctx, s, merkle.RFC6962SHA256Type, logIDs[:1], keyManagers, timeSource); err == nil {
+		t.Errorf("initLogs(existing)=nil, want error")
+	}
+	if got := latestRoot(ctx, t, s, logIDs[0]); !proto.Equal(&got, &want) {
+		t.Errorf("root after initLogs(existing)=%v, want %v", got, want)
+	}
+
+	for _, test := range []struct {
+		desc     string
+		hashType string
+		logIDs   []int64
+	}{
+		{desc: "no logs", hashType: merkle.RFC6962SHA256Type},
+		{desc: "unknown hash strategy", hashType: "SHA1-TREE", logIDs: []int64{103}},
+	} {
+		if err := initLogs(ctx, s, test.hashType, test.logIDs, keyManagers, timeSource); err == nil {
+			t.Errorf("%v: initLogs()=nil, want error", test.desc)
+		}
+	}
+}
+
+func TestInitLogsHashStrategies(t *testing.T) {
+	ctx := context.Background()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	keyManager, err := crypto.NewFromPrivateKey(key)
+	if err != nil {
+		t.Fatalf("NewFromPrivateKey()=(_,%v), want (_,nil)", err)
+	}
+	keyManagers := func(int64) (crypto.PrivateKeyManager, error) { return keyManager, nil }
+
+	for _, hashType := range []string{merkle.RFC6962SHA256Type, merkle.PlainSHA256Type, merkle.RFC6962SHA3Type} {
+		th, err := merkle.Factory(hashType)
+		if err != nil {
+			t.Fatalf("Factory(%v)=(_,%v), want (_,nil)", hashType, err)
+		}
+		// As with the registry, storage only serves trees recorded with its hasher.
+		s := memory.NewLogStorageWithHasher(th)
+		timeSource := &util.FakeTimeSource{FakeTime: time.Unix(1500000000, 0)}
+		const logID = 101
+		if err := initLogs(ctx, s, hashType, []int64{logID}, keyManagers, timeSource); err != nil {
+			t.Fatalf("%v: initLogs()=%v, want nil", hashType, err)
+		}
+
+		want := merkle.NewCompactMerkleTree(th)
+		leaves := make([]*trillian.LogLeaf, 3)
+		for i := range leaves {
+			value := []byte(fmt.Sprintf("leaf %d", i))
+			identityHash := sha256.Sum256(value)
+			leaves[i] = &trillian.LogLeaf{LeafIdentityHash: identityHash[:], MerkleLeafHash: th.HashLeaf(value), LeafValue: value}
+		}
+		tx, err := s.BeginForTree(ctx, logID)
+		if err != nil {
+			t.Fatalf("%v: BeginForTree()=(_,%v), want (_,nil)", hashType, err)
+		}
+		if err := tx.QueueLeaves(leaves, timeSource.Now()); err != nil {
+			t.Fatalf("%v: QueueLeaves()=%v, want nil", hashType, err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("%v: Commit()=%v, want nil", hashType, err)
+		}
+		timeSource.FakeTime = timeSource.FakeTime.Add(time.Second)
+		if _, err := log.NewSequencer(th, timeSource, s, keyManager).SequenceBatch(ctx, logID, 10); err != nil {
+			t.Fatalf("%v: SequenceBatch()=(_,%v), want (_,nil)", hashType, err)
+		}
+
+		// Leaves are sequenced in queue order, which for leaves queued together is by
+		// identity hash.
+		tx, err = s.BeginForTree(ctx, logID)
+		if err != nil {
+			t.Fatalf("%v: BeginForTree()=(_,%v), want (_,nil)", hashType, err)
+		}
+		sequenced, err := tx.GetLeavesByIndex([]int64{0, 1, 2})
+		tx.Close()
+		if err != nil {
+			t.Fatalf("%v: GetLeavesByIndex()=(_,%v), want (_,nil)", hashType, err)
+		}
+		for _, leaf := range sequenced {
+			want.AddLeafHash(leaf.MerkleLeafHash, func(int, int64, []byte) {})
+		}
+		root := latestRoot(ctx, t, s, logID)
+		if got, want := root.RootHash, want.CurrentRoot(); root.TreeSize != int64(len(leaves)) || !bytes.Equal(got, want) {
+			t.Errorf("%v: root=(size %d, hash %x), want (size %d, hash %x)", hashType, root.TreeSize, got, len(leaves), want)
+		}
+	}
+}
+
+func TestResignLogs(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewLogStorage()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey()=(_,%v), want (_,nil)", err)
+	}
+	keyManager, err := crypto.NewFromPrivateKey(key)
+	if err != nil {
+		t.Fatalf("NewFromPrivateKey()=(_,%v), want (_,nil)", err)
+	}
+	keyManagers := func(int64) (crypto.PrivateKeyManager, error) { return keyManager, nil }
+	timeSource := &util.FakeTimeSource{FakeTime: time.Unix(1500000000, 0)}
+	const logID = 101
+	if err := initLogs(ctx, s, merkle.RFC6962SHA256Type, []int64{logID}, keyManagers, timeSource); err != nil {
+		t.Fatalf("initLogs()=%v, want nil", err)
+	}
+
+	// Store a root signed in the legacy format, as an earlier release would have.
+	tx, err := s.BeginForTree(ctx, logID)
+	if err != nil {
+		t.Fatalf("BeginForTree()=(_,%v), want (_,nil)", err)
+	}
+	legacy, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot()=(_,%v), want (_,nil)", err)
+	}
+	legacy.TreeRevision = tx.WriteRevision()
+	legacy.TimestampNanos++
+	if legacy.Signature, err = crypto.NewSignerFromPrivateKeyManager(keyManager).Sign(crypto.HashLogRoot(legacy)); err != nil {
+		t.Fatalf("Sign()=(_,%v), want (_,nil)", err)
+	}
+	if err := tx.StoreSignedLogRoot(legacy); err != nil {
+		t.Fatalf("StoreSignedLogRoot()=%v, want nil", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit()=%v, want nil", err)
+	}
+	root := latestRoot(ctx, t, s, logID)
+	if err := crypto.VerifySTH(key.Public(), crypto.STHFromRoot(root), root.Signature, crypto.STHVersion1); err == nil {
+		t.Fatal("VerifySTH(legacy root)=nil, want error")
+	}
+
+	timeSource.FakeTime = timeSource.FakeTime.Add(time.Second)
+	if err := resignLogs(ctx, s, trillian_testonly.Hasher, nil, keyManagers, timeSource); err != nil {
+		t.Fatalf("resignLogs()=%v, want nil", err)
+	}
+	root = latestRoot(ctx, t, s, logID)
+	if err := crypto.VerifySTH(key.Public(), crypto.STHFromRoot(root), root.Signature, crypto.STHVersion1); err != nil {
+		t.Errorf("VerifySTH(re-signed root)=%v, want nil", err)
+	}
+	if root.TreeSize != legacy.TreeSize || !bytes.Equal(root.RootHash, legacy.RootHash) || root.TreeRevision != legacy.TreeRevision+1 {
+		t.Errorf("re-signed root=%v, want the tree of %v at the next revision", root, legacy)
+	}
+	if got, want := root.TimestampNanos, timeSource.Now().UnixNano(); got != want {
+		t.Errorf("re-signed root TimestampNanos=%d, want %d", got, want)
+	}
+
+	if err := resignLogs(ctx, s, trillian_testonly.Hasher, []int64{logID + 1}, keyManagers, timeSource); err == nil {
+		t.Error("resignLogs(unknown log)=nil, want error")
+	}
+}
+
+// latestRoot returns the latest root stored for logID in s.
+func latestRoot(ctx context.Context, t *testing.T, s storage.LogStorage, logID int64) trillian.SignedLogRoot {
+	t.Helper()
+	tx, err := s.SnapshotForTree(ctx, logID)
+	if err != nil {
+		t.Fatalf("SnapshotForTree()=(_,%v), want (_,nil)", err)
+	}
+	defer tx.Close()
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot()=(_,%v), want (_,nil)", err)
+	}
+	return root
+}
@@ -16,12 +16,24 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/google/trillian/extension/builtin"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring/prometheus"
 	"github.com/google/trillian/server"
 	"github.com/google/trillian/util"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/net/context"
 )
 
@@ -29,9 +41,47 @@ var (
 	exportRPCMetrics              = flag.Bool("export_metrics", true, "If true starts HTTP server and exports stats")
 	httpPortFlag                  = flag.Int("http_port", 8091, "Port to serve HTTP metrics on")
 	sequencerSleepBetweenRunsFlag = flag.Duration("sequencer_sleep_between_runs", time.Second*10, "Time to pause after each sequencing pass through all logs")
+	maxSleepBetweenRunsFlag       = flag.Duration("max_sleep_between_runs", 0, "If greater than sequencer_sleep_between_runs, the longest pause that --continuous sequencing backs off to, doubling the pause with some jitter after each pass that sequences nothing because the queues are empty or storage fails. The pause goes back to sequencer_sleep_between_runs after a pass that sequences leaves")
 	batchSizeFlag                 = flag.Int("batch_size", 50, "Max number of leaves to process per batch")
 	numSeqFlag                    = flag.Int("num_sequencers", 10, "Number of sequencers to run in parallel")
 	sequencerGuardWindowFlag      = flag.Duration("sequencer_guard_window", 0, "If set, the time elapsed before submitted leaves are eligible for sequencing")
+	logIDsFlag                    = flag.String("log_ids", "", "If set, a comma separated list of the IDs of the logs to sequence. Otherwise all active logs are sequenced")
+	dryRunFlag                    = flag.Bool("dry_run", false, "If true, batches are sequenced and signed but the results are rolled back rather than committed to storage")
+	metricsAddrFlag               = flag.String("metrics_addr", "", "If set, the address to serve Prometheus metrics on at /metrics, e.g. localhost:8092")
+	healthAddrFlag                = flag.String("health_addr", "", "If set, the address to serve /healthz and /readyz health checks on, e.g. localhost:8093")
+	storageStartupTimeoutFlag     = flag.Duration("storage_startup_timeout", 30*time.Second, "How long to keep retrying the storage check made at startup before exiting")
+	healthWindowFlag              = flag.Duration("health_window", 30*time.Second, "How long after a successful storage check /readyz continues to report ready. Storage is checked three times per window")
+	logFormatFlag                 = flag.String("log_format", "text", "Format of sequencing event logs: text, written to the INFO log, or json, written to stdout one object per line")
+	configFlag                    = flag.String("config", "", "If set, a JSON file of settings for the signer. Flags set on the command line take precedence over the file")
+	hashConcurrencyFlag           = flag.Int("hash_concurrency", runtime.NumCPU(), "Number of goroutines each sequencer uses to hash Merkle tree nodes for a batch")
+	shutdownTimeoutFlag           = flag.Duration("shutdown_timeout", 30*time.Second, "How long to wait, after a signal, for the sequencing pass in progress to finish before exiting anyway")
+	maxBatchesFlag                = flag.Int("max_batches", 0, "If positive, the number of sequencing passes, each sequencing a batch for every log, after which the signer exits. Only used with --continuous")
+	maxRuntimeFlag                = flag.Duration("max_runtime", 0, "If positive, how long the signer runs before it stops starting new passes and exits. Only used with --continuous")
+	skipInvalidLeavesFlag         = flag.Bool("skip_invalid_leaves", false, "If true, dequeued leaves whose Merkle leaf hash is the wrong size are logged and left out of the tree rather than failing the batch")
+	maxLeafSizeFlag               = flag.Int("max_leaf_size", log.DefaultMaxLeafSize, "Largest leaf value, in bytes, that will be integrated. Larger leaves are skipped. Zero means no limit")
+	sequencingOrderFlag           = flag.String("sequencing_order", "fifo", "Order in which queued leaves are assigned indices: fifo, by queue time, or unordered, which lets storage dequeue them more cheaply in any order")
+	dequeuePagingFlag             = flag.Bool("dequeue_paging", false, "If true, each log's batches page through its queue of leaves from where the last batch stopped, instead of reading from the start of the queue every time. Useful with large backlogs")
+	forceResignIntervalFlag       = flag.Duration("force_resign_interval", 0, "If positive, how old a log's root may get before a pass that sequences no leaves signs it again, to give clients a fresh root. Otherwise unchanged roots are not signed again")
+	batchTimeoutFlag              = flag.Duration("batch_timeout", 0, "If positive, how long each log's sequencing batch may take, including retries, before it is rolled back and left for a later pass")
+	nodeCacheSizeFlag             = flag.Int("node_cache_size", 0, "If positive, the number of Merkle tree nodes cached for each log between passes, to save reading them from storage every batch. Only safe if no other signer sequences the same logs")
+	leafRateFlag                  = flag.Float64("leaf_rate", 0, "If positive, the most leaves per second, averaged across passes, that will be integrated into each log")
+	initFlag                      = flag.Bool("init", false, "If true, create each of the logs in --log_ids, which must not already exist, with the hash strategy from --hash_strategy, sign its empty root and exit instead of sequencing")
+	resignFlag                    = flag.Bool("resign", false, "If true, sign a new root for each log, covering the same leaves as its latest root, and exit instead of sequencing. Re-signs every active log unless --log_ids is set. Run once after upgrading from a release that signed unversioned roots")
+	verifyFlag                    = flag.Bool("verify", false, "If true, check each log's stored Merkle tree and root against its leaves and exit, instead of sequencing. Checks every active log unless --log_ids is set")
+	listDeadLettersFlag           = flag.Bool("list_dead_letters", false, "If true, print the leaves that the sequencer dead-lettered in each log, with the reason each was rejected, and exit instead of sequencing. Lists every active log unless --log_ids is set")
+	deadLetterLimitFlag           = flag.Int("dead_letter_limit", 100, "Max number of dead-lettered leaves printed for each log by --list_dead_letters")
+	requeueFlag                   = flag.String("requeue", "", "If set, a comma separated list of the hex identity hashes of dead-lettered leaves to queue again, in the single log given by --log_ids, after which the signer exits")
+	drainFlag                     = flag.Bool("drain", false, "If true, sequence batches for each log back to back until no leaves are left to sequence, then print a summary and exit instead of sequencing continuously. Sequences every active log unless --log_ids is set. Useful for bulk imports")
+	continuousFlag                = flag.Bool("continuous", true, "If true, sequence repeatedly until terminated, pausing for sequencer_sleep_between_runs after each pass. If false, sequence a single batch for each log and exit")
+)
+
+const (
+	// exitCodeDrainTimeout is the exit status used when the sequencing pass in progress
+	// does not finish within --shutdown_timeout of a signal.
+	exitCodeDrainTimeout = 3
+	// exitCodeVerifyFailed is the exit status used when --verify finds a log whose
+	// stored tree does not match its leaves.
+	exitCodeVerifyFailed = 4
 )
 
 func main() {
@@ -39,11 +89,93 @@ func main() {
 	glog.CopyStandardLogTo("WARNING")
 	glog.Info("**** Log Signer Starting ****")
 
+	if *configFlag != "" {
+		cfg, err := loadConfig(*configFlag)
+		if err != nil {
+			glog.Exitf("Failed to load --config: %v", err)
+		}
+		if err := cfg.apply(flag.CommandLine); err != nil {
+			glog.Exitf("Failed to apply --config: %v", err)
+		}
+	}
+
+	logIDs, err := parseLogIDs(*logIDsFlag)
+	if err != nil {
+		glog.Exitf("Invalid --log_ids: %v", err)
+	}
+
+	eventLogger, err := util.NewEventLogger(*logFormatFlag, os.Stdout, util.SystemTimeSource{})
+	if err != nil {
+		glog.Exitf("Invalid --log_format: %v", err)
+	}
+
 	// First make sure we can access the database and keys, quit if not
 	registry, err := builtin.NewDefaultExtensionRegistry()
 	if err != nil {
 		glog.Exitf("Failed to create extension registry: %v", err)
 	}
+	logStorage, err := registry.GetLogStorage()
+	if err != nil {
+		glog.Exitf("Failed to get log storage: %v", err)
+	}
+	if err := server.CheckStorageAtStartup(context.Background(), logStorage, *storageStartupTimeoutFlag); err != nil {
+		glog.Exitf("Failed to start: %v", err)
+	}
+	if *initFlag {
+		if err := initLogs(context.Background(), logStorage, *builtin.HashStrategyFlag, logIDs, registry.GetKeyManager, util.SystemTimeSource{}); err != nil {
+			glog.Exitf("Failed to initialize logs: %v", err)
+		}
+		glog.Flush()
+		return
+	}
+	if err := checkLogIDs(context.Background(), logStorage, logIDs); err != nil {
+		glog.Exitf("Invalid log IDs: %v", err)
+	}
+	hasher, err := merkle.Factory(*builtin.HashStrategyFlag)
+	if err != nil {
+		glog.Exitf("Invalid --hash_strategy: %v", err)
+	}
+	ordering, err := log.ParseOrderingPolicy(*sequencingOrderFlag)
+	if err != nil {
+		glog.Exitf("Invalid --sequencing_order: %v", err)
+	}
+
+	if *verifyFlag {
+		failed, err := verifyLogs(context.Background(), logStorage, hasher, logIDs)
+		if err != nil {
+			glog.Exitf("Failed to verify logs: %v", err)
+		}
+		if len(failed) > 0 {
+			glog.Errorf("Integrity check failed for logs %v", failed)
+			glog.Flush()
+			os.Exit(exitCodeVerifyFailed)
+		}
+		glog.Info("Integrity check passed for all logs")
+		glog.Flush()
+		return
+	}
+
+	if *resignFlag {
+		if err := resignLogs(context.Background(), logStorage, hasher, logIDs, registry.GetKeyManager, util.SystemTimeSource{}); err != nil {
+			glog.Exitf("Failed to re-sign logs: %v", err)
+		}
+		glog.Flush()
+		return
+	}
+	if *listDeadLettersFlag {
+		if err := listDeadLetters(context.Background(), logStorage, logIDs, *deadLetterLimitFlag, os.Stdout); err != nil {
+			glog.Exitf("Failed to list dead letters: %v", err)
+		}
+		glog.Flush()
+		return
+	}
+	if *requeueFlag != "" {
+		if err := requeueDeadLetters(context.Background(), logStorage, logIDs, *requeueFlag, time.Now()); err != nil {
+			glog.Exitf("Failed to requeue dead letters: %v", err)
+		}
+		glog.Flush()
+		return
+	}
 
 	// Start HTTP server (optional)
 	if *exportRPCMetrics {
@@ -53,18 +185,166 @@ func main() {
 		}
 	}
 
-	// Start the sequencing loop, which will run until we terminate the process. This controls
-	// both sequencing and signing.
+	if *metricsAddrFlag != "" {
+		glog.Infof("Serving metrics on %s/metrics", *metricsAddrFlag)
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		go func() {
+			glog.Exitf("Metrics server failed: %v", http.ListenAndServe(*metricsAddrFlag, mux))
+		}()
+	}
+
+	if *maxBatchesFlag < 0 {
+		glog.Exitf("Invalid --max_batches: %d", *maxBatchesFlag)
+	}
+	if *maxRuntimeFlag < 0 {
+		glog.Exitf("Invalid --max_runtime: %v", *maxRuntimeFlag)
+	}
+	if *shutdownTimeoutFlag <= 0 {
+		glog.Exitf("Invalid --shutdown_timeout: %v", *shutdownTimeoutFlag)
+	}
+	if *nodeCacheSizeFlag < 0 {
+		glog.Exitf("Invalid --node_cache_size: %d", *nodeCacheSizeFlag)
+	}
+	if *batchTimeoutFlag < 0 {
+		glog.Exitf("Invalid --batch_timeout: %v", *batchTimeoutFlag)
+	}
+	if *maxSleepBetweenRunsFlag < 0 {
+		glog.Exitf("Invalid --max_sleep_between_runs: %v", *maxSleepBetweenRunsFlag)
+	}
+
+	// Start the sequencing loop, which will run until we terminate the process, unless only
+	// a single pass was requested. This controls both sequencing and signing. A signal stops
+	// the loop once the current pass is complete, or after --shutdown_timeout if it is not.
 	// TODO(Martin2112): Should respect read only mode and the flags in tree control etc
 	ctx, cancel := context.WithCancel(context.Background())
-	go util.AwaitSignal(cancel)
+	defer cancel()
+	// Passes also stop, without a signal, once --max_runtime has elapsed.
+	var stopCtx context.Context
+	var stop context.CancelFunc
+	if *maxRuntimeFlag > 0 {
+		stopCtx, stop = context.WithTimeout(ctx, *maxRuntimeFlag)
+	} else {
+		stopCtx, stop = context.WithCancel(ctx)
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	if *healthAddrFlag != "" {
+		if *healthWindowFlag <= 0 {
+			glog.Exitf("Invalid --health_window: %v", *healthWindowFlag)
+		}
+		health := server.NewHealthChecker(logStorage, util.SystemTimeSource{}, *healthWindowFlag)
+		go health.Run(ctx, *healthWindowFlag/3)
+		glog.Infof("Serving health checks on %s/healthz and %s/readyz", *healthAddrFlag, *healthAddrFlag)
+		mux := http.NewServeMux()
+		health.RegisterHandlers(mux)
+		go func() {
+			glog.Exitf("Health check server failed: %v", http.ListenAndServe(*healthAddrFlag, mux))
+		}()
+	}
 
 	sequencerManager := server.NewSequencerManager(registry, *sequencerGuardWindowFlag)
+	sequencerManager.SetDryRun(*dryRunFlag)
+	sequencerManager.SetEventLogger(eventLogger)
+	sequencerManager.SetMetrics(prometheus.NewMetrics(nil, log.MetricHelp))
+	sequencerManager.SetHasher(hasher)
+	sequencerManager.SetHashConcurrency(*hashConcurrencyFlag)
+	sequencerManager.SetSkipInvalidLeaves(*skipInvalidLeavesFlag)
+	sequencerManager.SetMaxLeafSize(*maxLeafSizeFlag)
+	sequencerManager.SetOrderingPolicy(ordering)
+	sequencerManager.SetDequeuePaging(*dequeuePagingFlag)
+	sequencerManager.SetForceResignInterval(*forceResignIntervalFlag)
+	sequencerManager.SetBatchTimeout(*batchTimeoutFlag)
+	if *nodeCacheSizeFlag > 0 {
+		sequencerManager.SetNodeCacheSize(*nodeCacheSizeFlag)
+	}
+	if *leafRateFlag > 0 {
+		sequencerManager.SetLeafRateLimit(*leafRateFlag)
+	}
+	if *drainFlag {
+		if *dryRunFlag {
+			glog.Exitf("--drain cannot be used with --dry_run, which never empties the queue")
+		}
+		drainLogIDs, err := activeLogIDs(ctx, logStorage, logIDs)
+		if err != nil {
+			glog.Exitf("Failed to list logs to drain: %v", err)
+		}
+		// A signal rolls back the batch in progress and stops draining.
+		go func() {
+			select {
+			case <-sigs:
+				stop()
+			case <-ctx.Done():
+			}
+		}()
+		start := time.Now()
+		results, err := sequencerManager.Drain(stopCtx, drainLogIDs, *batchSizeFlag, util.SystemTimeSource{})
+		if err != nil {
+			glog.Exitf("Failed to drain logs: %v", err)
+		}
+		batches, leaves := 0, 0
+		for _, r := range results {
+			batches += r.Batches
+			leaves += r.LeafCount
+		}
+		d := time.Since(start)
+		eventLogger.LogEvent("signer_drained", util.Fields{
+			"logs":        len(results),
+			"batches":     batches,
+			"leaves":      leaves,
+			"duration_ms": d.Nanoseconds() / int64(time.Millisecond),
+		})
+		glog.Infof("Drained %d leaves in %d batches from %d logs in %v", leaves, batches, len(results), d)
+		glog.Flush()
+		return
+	}
+
 	sequencerTask := server.NewLogOperationManager(ctx, registry, *batchSizeFlag, *numSeqFlag, *sequencerSleepBetweenRunsFlag, util.SystemTimeSource{}, sequencerManager)
-	sequencerTask.OperationLoop()
+	sequencerTask.SetLogIDs(logIDs)
+	eventLogger.LogEvent("signer_started", util.Fields{
+		"log_ids":        logIDs,
+		"batch_size":     *batchSizeFlag,
+		"num_sequencers": *numSeqFlag,
+		"dry_run":        *dryRunFlag,
+	})
+	sequencerTask.SetStopContext(stopCtx)
+	sequencerTask.SetMaxPasses(*maxBatchesFlag)
+	sequencerTask.SetMaxSleepBetweenRuns(*maxSleepBetweenRunsFlag)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if *continuousFlag {
+			sequencerTask.OperationLoop()
+		} else {
+			sequencerTask.OperationSingle()
+		}
+	}()
 
-	// Give things a few seconds to tidy up
+	if !util.AwaitShutdown(sigs, stop, done, *shutdownTimeoutFlag) {
+		eventLogger.LogEvent("signer_drain_timeout", util.Fields{"timeout_ms": shutdownTimeoutFlag.Nanoseconds() / int64(time.Millisecond)})
+		glog.Errorf("Sequencing did not finish within --shutdown_timeout=%v, exiting", *shutdownTimeoutFlag)
+		glog.Flush()
+		os.Exit(exitCodeDrainTimeout)
+	}
+
+	eventLogger.LogEvent("signer_stopping", util.Fields{})
 	glog.Infof("Stopping server, about to exit")
 	glog.Flush()
-	time.Sleep(time.Second * 5)
+}
+
+// parseLogIDs parses a comma separated list of log IDs.
+func parseLogIDs(ids string) ([]int64, error) {
+	if ids == "" {
+		return nil, nil
+	}
+	var logIDs []int64
+	for _, id := range strings.Split(ids, ",") {
+		logID, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log ID %q: %v", id, err)
+		}
+		logIDs = append(logIDs, logID)
+	}
+	return logIDs, nil
 }
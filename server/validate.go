@@ -76,3 +76,17 @@ func validateQueueLeavesRequest(req *trillian.QueueLeavesRequest) error {
 	}
 	return nil
 }
+
+// validateLeafSizes returns an error if any leaf value is larger than maxSize bytes. A
+// maxSize less than 1 means there is no limit.
+func validateLeafSizes(leaves []*trillian.LogLeaf, maxSize int) error {
+	if maxSize <= 0 {
+		return nil
+	}
+	for i, leaf := range leaves {
+		if size := len(leaf.LeafValue); size > maxSize {
+			return grpc.Errorf(codes.InvalidArgument, "len(leaves[%d].LeafValue)=%d, want <= %d", i, size, maxSize)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/google/trillian/storage"
+)
+
+// NodeCache is a least recently used cache of the Merkle nodes of a single tree. It can
+// be kept between transactions, so that nodes that are read by every transaction, such
+// as the roots of the tree's complete subtrees, need only be read from storage once.
+//
+// Nodes are only cached when they are read, and are dropped when a transaction using the
+// cache writes them, whether or not it commits. The cache can therefore only return a
+// stale node if the tree is also written without it. Reads at older revisions than the
+// latest one written through the cache are not cached. A NodeCache is safe for concurrent
+// use.
+type NodeCache struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru holds the entries, with the most recently used at the front.
+	lru *list.List
+	// generation is incremented by every write, so that reads that overlap a write do
+	// not cache what may be the old version of a node.
+	generation int64
+	// written is the latest tree revision written through the cache.
+	written int64
+}
+
+// nodeCacheEntry holds a node read from storage at revision. The node is the latest
+// version at every later revision until the entry is dropped by a write.
+type nodeCacheEntry struct {
+	key      string
+	node     storage.Node
+	revision int64
+}
+
+// NewNodeCache creates a cache holding up to size nodes.
+func NewNodeCache(size int) *NodeCache {
+	return &NodeCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Len returns the number of nodes in the cache.
+func (c *NodeCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// get returns the cached version of the node with key at treeRevision, if there is one.
+func (c *NodeCache) get(key string, treeRevision int64) (storage.Node, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return storage.Node{}, false
+	}
+	entry := e.Value.(*nodeCacheEntry)
+	if treeRevision < entry.revision {
+		return storage.Node{}, false
+	}
+	c.lru.MoveToFront(e)
+	return entry.node, true
+}
+
+// currentGeneration returns the generation to pass to add for nodes about to be read.
+func (c *NodeCache) currentGeneration() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// add caches nodes, which were read at treeRevision, unless a write has been made since
+// generation.
+func (c *NodeCache) add(nodes []storage.Node, treeRevision, generation int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation || treeRevision < c.written {
+		return
+	}
+	for _, node := range nodes {
+		key := node.NodeID.String()
+		if e, ok := c.entries[key]; ok {
+			entry := e.Value.(*nodeCacheEntry)
+			if treeRevision > entry.revision {
+				entry.node, entry.revision = node, treeRevision
+			}
+			c.lru.MoveToFront(e)
+			continue
+		}
+		c.entries[key] = c.lru.PushFront(&nodeCacheEntry{key: key, node: node, revision: treeRevision})
+		for c.lru.Len() > c.size {
+			oldest := c.lru.Back()
+			c.lru.Remove(oldest)
+			delete(c.entries, oldest.Value.(*nodeCacheEntry).key)
+		}
+	}
+}
+
+// invalidate drops nodes, which are being written at treeRevision, from the cache.
+func (c *NodeCache) invalidate(nodes []storage.Node, treeRevision int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if treeRevision > c.written {
+		c.written = treeRevision
+	}
+	for _, node := range nodes {
+		key := node.NodeID.String()
+		if e, ok := c.entries[key]; ok {
+			c.lru.Remove(e)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// WrapLogTreeTX returns a transaction that behaves as tx, except that it reads Merkle
+// nodes through c and drops the nodes it writes from c.
+func (c *NodeCache) WrapLogTreeTX(tx storage.LogTreeTX) storage.LogTreeTX {
+	return &cachedLogTreeTX{LogTreeTX: tx, cache: c}
+}
+
+// cachedLogTreeTX is a storage.LogTreeTX that reads Merkle nodes through a NodeCache.
+type cachedLogTreeTX struct {
+	storage.LogTreeTX
+	cache *NodeCache
+}
+
+// GetMerkleNodes implements storage.NodeReader, only reading the nodes that are not
+// cached from storage.
+func (t *cachedLogTreeTX) GetMerkleNodes(treeRevision int64, ids []storage.NodeID) ([]storage.Node, error) {
+	found := make(map[string]storage.Node)
+	var missing []storage.NodeID
+	for _, id := range ids {
+		if node, ok := t.cache.get(id.String(), treeRevision); ok {
+			found[id.String()] = node
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		generation := t.cache.currentGeneration()
+		nodes, err := t.LogTreeTX.GetMerkleNodes(treeRevision, missing)
+		if err != nil {
+			return nil, err
+		}
+		t.cache.add(nodes, treeRevision, generation)
+		for _, node := range nodes {
+			found[node.NodeID.String()] = node
+		}
+	}
+
+	ret := make([]storage.Node, 0, len(found))
+	for _, id := range ids {
+		if node, ok := found[id.String()]; ok {
+			ret = append(ret, node)
+		}
+	}
+	return ret, nil
+}
+
+// SetMerkleNodes implements storage.NodeWriter, dropping the nodes from the cache.
+func (t *cachedLogTreeTX) SetMerkleNodes(nodes []storage.Node) error {
+	t.cache.invalidate(nodes, t.WriteRevision())
+	return t.LogTreeTX.SetMerkleNodes(nodes)
+}
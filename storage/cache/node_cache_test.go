@@ -0,0 +1,197 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	storageto "github.com/google/trillian/storage/testonly"
+)
+
+// countingLogTreeTX counts the Merkle nodes read from the transaction it wraps.
+type countingLogTreeTX struct {
+	storage.LogTreeTX
+	reads *int
+}
+
+func (t countingLogTreeTX) GetMerkleNodes(treeRevision int64, ids []storage.NodeID) ([]storage.Node, error) {
+	*t.reads += len(ids)
+	return t.LogTreeTX.GetMerkleNodes(treeRevision, ids)
+}
+
+func testNodeID(t testing.TB, index int64) storage.NodeID {
+	t.Helper()
+	id, err := storage.NewNodeIDForTreeCoords(0, index, 64)
+	if err != nil {
+		t.Fatalf("NewNodeIDForTreeCoords()=(_,%v), want (_,nil)", err)
+	}
+	return id
+}
+
+func testHash(s string) []byte {
+	h := sha256.Sum256([]byte(s))
+	return h[:]
+}
+
+// writeNodes writes nodes to logID through c, at the next revision, and commits them if
+// commit is set.
+func writeNodes(t testing.TB, ls storage.LogStorage, c *NodeCache, logID int64, nodes []storage.Node, commit bool) {
+	t.Helper()
+	tx, err := ls.BeginForTree(context.Background(), logID)
+	if err != nil {
+		t.Fatalf("BeginForTree()=(_,%v), want (_,nil)", err)
+	}
+	defer tx.Close()
+	tx = c.WrapLogTreeTX(tx)
+	if err := tx.SetMerkleNodes(nodes); err != nil {
+		t.Fatalf("SetMerkleNodes()=%v, want nil", err)
+	}
+	if !commit {
+		return
+	}
+	if err := tx.StoreSignedLogRoot(trillian.SignedLogRoot{LogId: logID, TreeRevision: tx.WriteRevision(), TimestampNanos: tx.WriteRevision(), RootHash: testHash("root")}); err != nil {
+		t.Fatalf("StoreSignedLogRoot()=%v, want nil", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit()=%v, want nil", err)
+	}
+}
+
+// readNode reads the node with id through c at the latest revision, counting reads from
+// storage in reads.
+func readNode(t testing.TB, ls storage.LogStorage, c *NodeCache, logID int64, id storage.NodeID, reads *int) []byte {
+	t.Helper()
+	tx, err := ls.BeginForTree(context.Background(), logID)
+	if err != nil {
+		t.Fatalf("BeginForTree()=(_,%v), want (_,nil)", err)
+	}
+	defer tx.Close()
+	tx = c.WrapLogTreeTX(countingLogTreeTX{LogTreeTX: tx, reads: reads})
+	nodes, err := tx.GetMerkleNodes(tx.ReadRevision(), []storage.NodeID{id})
+	if err != nil {
+		t.Fatalf("GetMerkleNodes()=(_,%v), want (_,nil)", err)
+	}
+	if len(nodes) != 1 {
+		t.Fatalf("GetMerkleNodes()=%d nodes, want 1", len(nodes))
+	}
+	return nodes[0].Hash
+}
+
+func newCacheTestLog(t testing.TB) (storage.LogStorage, int64) {
+	t.Helper()
+	ls := memory.NewLogStorage()
+	tree, err := ls.CreateLog(storageto.LogTree)
+	if err != nil {
+		t.Fatalf("CreateLog()=(_,%v), want (_,nil)", err)
+	}
+	return ls, tree.TreeId
+}
+
+func TestNodeCacheNoStaleNodes(t *testing.T) {
+	ls, logID := newCacheTestLog(t)
+	c := NewNodeCache(10)
+	id := testNodeID(t, 0)
+	writeNodes(t, ls, c, logID, []storage.Node{{NodeID: id, Hash: testHash("v1")}}, true)
+
+	var reads int
+	for i := 0; i < 2; i++ {
+		if got, want := readNode(t, ls, c, logID, id, &reads), testHash("v1"); !bytes.Equal(got, want) {
+			t.Errorf("GetMerkleNodes()=%x, want %x", got, want)
+		}
+	}
+	if reads != 1 {
+		t.Errorf("read %d nodes from storage, want 1", reads)
+	}
+
+	// Each write must be seen by the next read, whether or not it was committed.
+	for _, test := range []struct {
+		value  string
+		commit bool
+		want   string
+	}{
+		{value: "v2", commit: true, want: "v2"},
+		{value: "v3", commit: false, want: "v2"},
+		{value: "v4", commit: true, want: "v4"},
+	} {
+		writeNodes(t, ls, c, logID, []storage.Node{{NodeID: id, Hash: testHash(test.value)}}, test.commit)
+		if got, want := readNode(t, ls, c, logID, id, &reads), testHash(test.want); !bytes.Equal(got, want) {
+			t.Errorf("after writing %s: GetMerkleNodes()=%x, want %x", test.value, got, want)
+		}
+	}
+}
+
+func TestNodeCacheOverlappingWrite(t *testing.T) {
+	ls, logID := newCacheTestLog(t)
+	c := NewNodeCache(10)
+	id := testNodeID(t, 0)
+	writeNodes(t, ls, c, logID, []storage.Node{{NodeID: id, Hash: testHash("v1")}}, true)
+
+	// A read that starts before a write and finishes after it must not cache the
+	// version it read.
+	generation := c.currentGeneration()
+	tx, err := ls.BeginForTree(context.Background(), logID)
+	if err != nil {
+		t.Fatalf("BeginForTree()=(_,%v), want (_,nil)", err)
+	}
+	nodes, err := tx.GetMerkleNodes(tx.ReadRevision(), []storage.NodeID{id})
+	if err != nil {
+		t.Fatalf("GetMerkleNodes()=(_,%v), want (_,nil)", err)
+	}
+	revision := tx.ReadRevision()
+	tx.Close()
+	writeNodes(t, ls, c, logID, []storage.Node{{NodeID: id, Hash: testHash("v2")}}, true)
+	c.add(nodes, revision, generation)
+
+	var reads int
+	if got, want := readNode(t, ls, c, logID, id, &reads), testHash("v2"); !bytes.Equal(got, want) {
+		t.Errorf("GetMerkleNodes()=%x, want %x", got, want)
+	}
+}
+
+func TestNodeCacheEviction(t *testing.T) {
+	ls, logID := newCacheTestLog(t)
+	const size = 3
+	c := NewNodeCache(size)
+	var nodes []storage.Node
+	for i := int64(0); i < 5; i++ {
+		nodes = append(nodes, storage.Node{NodeID: testNodeID(t, i), Hash: testHash(fmt.Sprint(i))})
+	}
+	writeNodes(t, ls, c, logID, nodes, true)
+
+	var reads int
+	for _, node := range nodes {
+		readNode(t, ls, c, logID, node.NodeID, &reads)
+	}
+	if got := c.Len(); got != size {
+		t.Errorf("Len()=%d, want %d", got, size)
+	}
+
+	// The most recently read nodes are still cached, the others were evicted. They are
+	// checked newest first as reading an evicted node evicts another.
+	for i := len(nodes) - 1; i >= 0; i-- {
+		reads = 0
+		readNode(t, ls, c, logID, nodes[i].NodeID, &reads)
+		if got, want := reads == 0, i >= len(nodes)-size; got != want {
+			t.Errorf("node %d: cached=%v, want %v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// DeadLetter is a leaf held in a DeadLetterQueue.
+type DeadLetter struct {
+	LeafIdentityHash []byte
+	MerkleLeafHash   []byte
+	// Reason describes why the leaf was not integrated.
+	Reason string
+	// TimestampNanos is when the leaf was dead-lettered.
+	TimestampNanos int64
+}
+
+// ListDeadLetters returns up to limit dead letters of the log treeID from s, in the order
+// they were recorded.
+func ListDeadLetters(ctx context.Context, s LogStorage, treeID int64, limit int) ([]DeadLetter, error) {
+	var letters []DeadLetter
+	err := RunInLogTX(ctx, s, treeID, RetryPolicy{}, func(tx LogTreeTX) error {
+		var err error
+		if letters, err = tx.ListDeadLetters(limit); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	return letters, err
+}
+
+// RequeueDeadLetter queues the dead-lettered leaf of the log treeID that has the given
+// identity hash again, with queueTimestamp, and removes it from the dead-letter queue.
+func RequeueDeadLetter(ctx context.Context, s LogStorage, treeID int64, leafIdentityHash []byte, queueTimestamp time.Time) error {
+	return RunInLogTX(ctx, s, treeID, RetryPolicy{}, func(tx LogTreeTX) error {
+		if err := tx.RequeueDeadLetter(leafIdentityHash, queueTimestamp); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
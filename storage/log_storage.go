@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/sigpb"
 )
 
 // ReadOnlyLogTX provides a read-only view into log data.
@@ -57,6 +58,7 @@ type LogTreeTX interface {
 	LeafReader
 	LeafQueuer
 	LeafDequeuer
+	DeadLetterQueue
 	LogMetadata
 }
 
@@ -75,6 +77,11 @@ type ReadOnlyLogStorage interface {
 }
 
 // LogStorage should be implemented by concrete storage mechanisms which want to support Logs.
+//
+// Transactions must be isolated from one another: changes made through a LogTreeTX are
+// only visible to other transactions once it has been committed, and are discarded if it
+// is rolled back or closed without being committed. Implementations can check they meet
+// the requirements documented on these interfaces with testonly.LogStorageTester.
 type LogStorage interface {
 	ReadOnlyLogStorage
 
@@ -83,12 +90,43 @@ type LogStorage interface {
 	// the returned object, and values read through it should only be propagated
 	// if Commit returns without error.
 	BeginForTree(ctx context.Context, treeID int64) (LogTreeTX, error)
+
+	// CreateTree creates an active log with ID treeID, which hashes its Merkle tree with
+	// hashStrategy and whose roots are signed with sigAlgo. Duplicate leaves are not
+	// allowed. The log has no leaves and no signed roots until its first root is
+	// stored. If a tree with ID treeID already exists it is left unchanged and an Error
+	// of type AlreadyExists is returned.
+	CreateTree(ctx context.Context, treeID int64, hashStrategy trillian.HashStrategy, sigAlgo sigpb.DigitallySigned_SignatureAlgorithm) error
 }
 
 // LeafQueuer provides a write-only interface for the queueing (but not necessarily integration) of leaves.
 type LeafQueuer interface {
-	// QueueLeaves enqueues leaves for later integration into the tree.
+	// QueueLeaves enqueues leaves for later integration into the tree. Leaves must have a
+	// LeafIdentityHash of the size used by the tree's hasher. If the tree does not allow
+	// duplicates, queueing a leaf with the same LeafIdentityHash as one already in the tree
+	// fails with an Error of type DuplicateLeaf.
 	QueueLeaves(leaves []*trillian.LogLeaf, queueTimestamp time.Time) error
+
+	// QueueLeavesBatch enqueues leaves as QueueLeaves does, but writes them in as few
+	// operations as the implementation allows and reports the outcome for each leaf
+	// instead of failing the whole batch. The returned slice holds an entry for each
+	// leaf, which is nil if the leaf was queued or an Error of type DuplicateLeaf if the
+	// tree does not allow duplicates and the leaf was already present, either in the tree
+	// or earlier in the batch. A non-nil error means that the batch as a whole failed.
+	QueueLeavesBatch(leaves []*trillian.LogLeaf, queueTimestamp time.Time) ([]error, error)
+
+	// QueueLeafWithToken enqueues leaf as QueueLeaves does, unless a leaf has already been
+	// queued with the same client supplied dedupToken, in which case nothing is queued and
+	// the leaf that was queued with the token is returned instead. Its LeafIndex is the
+	// one assigned when it was sequenced, or -1 if it has not been sequenced yet. Unlike
+	// duplicate detection by LeafIdentityHash this holds even if the leaf's content has
+	// changed between submissions. A nil leaf is returned if leaf was queued.
+	QueueLeafWithToken(leaf *trillian.LogLeaf, dedupToken string, queueTimestamp time.Time) (*trillian.LogLeaf, error)
+
+	// IdentityHashAlgorithm returns the identity_hash_algorithm the tree was created with.
+	// Queued leaves must have identity hashes computed with it, where NONE stands for
+	// the SHA256 hashes of trees that predate the setting.
+	IdentityHashAlgorithm() sigpb.DigitallySigned_HashAlgorithm
 }
 
 // LeafDequeuer provides an interface for reading previously queued leaves for integration into the tree.
@@ -96,9 +134,45 @@ type LeafDequeuer interface {
 	// DequeueLeaves will return between [0, limit] leaves from the queue.
 	// Leaves which have been dequeued within a Rolled-back Tx will become available for dequeing again.
 	// Leaves queued more recently than the cutoff time will not be returned. This allows for
-	// guard intervals to be configured.
+	// guard intervals to be configured. Leaves are returned in order of their queue time,
+	// with ties broken by LeafIdentityHash. Only the LeafIdentityHash and MerkleLeafHash of
+	// the returned leaves need be set.
 	DequeueLeaves(limit int, cutoffTime time.Time) ([]*trillian.LogLeaf, error)
+	// DequeueLeavesUnordered returns leaves as DequeueLeaves does, except that neither the
+	// order of the returned leaves nor which leaves are chosen when more than limit are
+	// eligible is specified. This allows implementations to select them more cheaply.
+	DequeueLeavesUnordered(limit int, cutoffTime time.Time) ([]*trillian.LogLeaf, error)
+	// DequeueLeavesAfter returns leaves as DequeueLeaves does, but only those after the
+	// queue position given by token, so that successive batches can page through a large
+	// queue instead of scanning it from the start each time. A nil token starts at the
+	// beginning of the queue. It also returns the token for the position after the last
+	// returned leaf, or nil if fewer than limit leaves were eligible, meaning the next
+	// batch should start from the beginning again. The token is only meaningful once the
+	// transaction has committed.
+	DequeueLeavesAfter(limit int, cutoffTime time.Time, token []byte) ([]*trillian.LogLeaf, []byte, error)
+	// UpdateSequencedLeaves stores the LeafIndex assigned to each of a batch of dequeued
+	// leaves. It is an error to assign the same LeafIndex to more than one leaf.
 	UpdateSequencedLeaves(leaves []*trillian.LogLeaf) error
+	// QueuedLeafCount returns the number of leaves that are queued for integration into
+	// the tree and have not yet been dequeued, whatever their queue time.
+	QueuedLeafCount() (int64, error)
+}
+
+// DeadLetterQueue holds leaves that were dequeued but will not be integrated into the
+// tree, for example because they failed validation, so that operators can inspect them
+// and queue them again once the cause has been dealt with.
+type DeadLetterQueue interface {
+	// DeadLetterLeaves records dequeued leaves that will not be integrated, with the
+	// reason each was rejected. A leaf that is already dead-lettered has its entry
+	// replaced.
+	DeadLetterLeaves(letters []DeadLetter) error
+	// ListDeadLetters returns up to limit dead letters, in the order they were recorded
+	// with ties broken by LeafIdentityHash.
+	ListDeadLetters(limit int) ([]DeadLetter, error)
+	// RequeueDeadLetter removes the dead letter for the leaf with the given identity hash
+	// and queues the leaf again, as if it had been queued at queueTimestamp. It fails with
+	// an Error of type NotFound if there is no such dead letter.
+	RequeueDeadLetter(leafIdentityHash []byte, queueTimestamp time.Time) error
 }
 
 // LeafReader provides a read only interface to stored tree leaves
@@ -107,23 +181,35 @@ type LeafReader interface {
 	// tree via sequencing.
 	GetSequencedLeafCount() (int64, error)
 	// GetLeavesByIndex returns leaf metadata and data for a set of specified sequenced leaf indexes.
+	// It is an error to request an index which has not been sequenced.
 	GetLeavesByIndex(leaves []int64) ([]*trillian.LogLeaf, error)
+	// GetLeavesByRange returns leaf metadata and data for up to count sequenced leaves,
+	// in index order, starting at index start. Fewer leaves are returned if the range
+	// extends past the last sequenced leaf, and none if start is beyond it.
+	GetLeavesByRange(start, count int64) ([]*trillian.LogLeaf, error)
 	// GetLeavesByHash looks up sequenced leaf metadata and data by their Merkle leaf hash. If the
 	// tree permits duplicate leaves callers must be prepared to handle multiple results with the
 	// same hash but different sequence numbers. If orderBySequence is true then the returned data
 	// will be in ascending sequence number order.
 	GetLeavesByHash(leafHashes [][]byte, orderBySequence bool) ([]*trillian.LogLeaf, error)
+	// GetLeavesByIdentityHash looks up sequenced leaf metadata and data by their leaf
+	// identity hash. If the tree permits duplicate leaves there may be several results for
+	// the same hash, at different sequence numbers.
+	GetLeavesByIdentityHash(leafIdentityHashes [][]byte) ([]*trillian.LogLeaf, error)
 }
 
 // LogRootReader provides an interface for reading SignedLogRoots.
 type LogRootReader interface {
-	// LatestSignedLogRoot returns the most recent SignedLogRoot, if any.
+	// LatestSignedLogRoot returns the most recent SignedLogRoot, if any. The most recent root
+	// is the one with the latest timestamp that was committed before the transaction began.
+	// If no root has been stored an empty SignedLogRoot, with TreeRevision 0, is returned.
 	LatestSignedLogRoot() (trillian.SignedLogRoot, error)
 }
 
 // LogRootWriter provides an interface for storing new SignedLogRoots.
 type LogRootWriter interface {
-	// StoreSignedLogRoot stores a freshly created SignedLogRoot.
+	// StoreSignedLogRoot stores a freshly created SignedLogRoot. It is an error to store two
+	// roots with the same TreeRevision or timestamp for a tree.
 	StoreSignedLogRoot(root trillian.SignedLogRoot) error
 }
 
@@ -0,0 +1,808 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memory provides an in-memory implementation of storage.LogStorage, intended
+// for tests. Nothing is persisted and the behaviour is deterministic.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+)
+
+// hashSizeBytes is the size of the leaf and node hashes accepted by the storage.
+const hashSizeBytes = 32
+
+// errTXClosed is returned by operations on a transaction after it was committed or
+// rolled back.
+var errTXClosed = errors.New("memory: transaction is closed")
+
+// LogStorage is an in-memory storage.LogStorage. Logs must be created with CreateLog
+// before they are used.
+//
+// Each transaction works on its own copy of a log, which replaces the stored log when
+// the transaction is committed. Committing fails with a storage.TransientError if the log
+// was changed by another transaction after this one began.
+type LogStorage struct {
+	mu     sync.Mutex
+	nextID int64
+	trees  map[int64]*logTree
+	// hasher, if set, is the hasher the storage is configured with. Trees recorded with
+	// a hash strategy it does not implement are refused.
+	hasher merkle.TreeHasher
+}
+
+// NewLogStorage creates a new, empty LogStorage.
+func NewLogStorage() *LogStorage {
+	return &LogStorage{
+		nextID: 1,
+		trees:  make(map[int64]*logTree),
+	}
+}
+
+// NewLogStorageWithHasher is like NewLogStorage, but transactions are only started for
+// trees whose hash strategy is implemented by th.
+func NewLogStorageWithHasher(th merkle.TreeHasher) *LogStorage {
+	s := NewLogStorage()
+	s.hasher = th
+	return s
+}
+
+// queuedLeaf is an entry in the queue of leaves waiting to be sequenced.
+type queuedLeaf struct {
+	identityHash   []byte
+	merkleLeafHash []byte
+	queuedNanos    int64
+	// messageID distinguishes multiple queued copies of a leaf, if duplicates are allowed.
+	messageID []byte
+}
+
+// sequencedLeaf records the position of a leaf in the tree.
+type sequencedLeaf struct {
+	identityHash   []byte
+	merkleLeafHash []byte
+	queuedNanos    int64
+	integrateNanos int64
+}
+
+// leafData holds the client supplied data for a leaf.
+type leafData struct {
+	leafValue []byte
+	extraData []byte
+}
+
+// logTree holds the state of a single log.
+type logTree struct {
+	id              int64
+	hashStrategy    trillian.HashStrategy
+	duplicatePolicy trillian.DuplicatePolicy
+	// identityHashSize is the length of the leaf identity hashes accepted by the log,
+	// as given by its identity hash algorithm identityHashAlg.
+	identityHashSize int
+	identityHashAlg  sigpb.DigitallySigned_HashAlgorithm
+	// version is incremented by each commit, to detect conflicting transactions.
+	version int64
+
+	leaves    map[string]leafData
+	queue     []queuedLeaf
+	sequenced map[int64]sequencedLeaf
+	roots     []trillian.SignedLogRoot
+	// nodes holds the revisions of each node, keyed by NodeID.String(), in ascending
+	// revision order.
+	nodes map[string][]storage.Node
+	// deadLetters holds the dead-lettered leaves, keyed by identity hash.
+	deadLetters map[string]storage.DeadLetter
+	// dedupTokens holds the leaves queued with QueueLeafWithToken, keyed by token.
+	dedupTokens map[string]sequencedLeaf
+}
+
+// clone returns a copy of t that can be modified without affecting t. Values held in
+// the maps and slices are never modified in place, so they are shared.
+func (t *logTree) clone() *logTree {
+	c := *t
+	c.leaves = make(map[string]leafData, len(t.leaves))
+	for k, v := range t.leaves {
+		c.leaves[k] = v
+	}
+	c.queue = append([]queuedLeaf(nil), t.queue...)
+	c.sequenced = make(map[int64]sequencedLeaf, len(t.sequenced))
+	for k, v := range t.sequenced {
+		c.sequenced[k] = v
+	}
+	c.deadLetters = make(map[string]storage.DeadLetter, len(t.deadLetters))
+	for k, v := range t.deadLetters {
+		c.deadLetters[k] = v
+	}
+	c.dedupTokens = make(map[string]sequencedLeaf, len(t.dedupTokens))
+	for k, v := range t.dedupTokens {
+		c.dedupTokens[k] = v
+	}
+	c.roots = append([]trillian.SignedLogRoot(nil), t.roots...)
+	c.nodes = make(map[string][]storage.Node, len(t.nodes))
+	for k, v := range t.nodes {
+		c.nodes[k] = v
+	}
+	return &c
+}
+
+// latestRoot returns the root with the most recent timestamp, or an empty root if none
+// have been stored.
+func (t *logTree) latestRoot() trillian.SignedLogRoot {
+	var latest trillian.SignedLogRoot
+	for i, r := range t.roots {
+		if i == 0 || r.TimestampNanos > latest.TimestampNanos {
+			latest = r
+		}
+	}
+	return latest
+}
+
+// copyBytes returns a copy of b, preserving the distinction between nil and empty.
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+// CreateLog adds a new log, with the settings of tree, to the storage. A copy of tree
+// with its TreeId set is returned.
+func (m *LogStorage) CreateLog(tree *trillian.Tree) (*trillian.Tree, error) {
+	if tree.TreeType != trillian.TreeType_LOG {
+		return nil, fmt.Errorf("memory: unsupported tree type: %v", tree.TreeType)
+	}
+	identityHasher, err := crypto.LeafIdentityHasher(tree.IdentityHashAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("memory: %v", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.nextID
+	m.nextID++
+	m.trees[id] = newLogTree(id, tree.HashStrategy, tree.DuplicatePolicy, tree.IdentityHashAlgorithm, identityHasher.Size())
+
+	created := *tree
+	created.TreeId = id
+	return &created, nil
+}
+
+// CreateTree creates an empty log with ID treeID. See storage.LogStorage.
+func (m *LogStorage) CreateTree(ctx context.Context, treeID int64, hashStrategy trillian.HashStrategy, sigAlgo sigpb.DigitallySigned_SignatureAlgorithm) error {
+	switch {
+	case treeID <= 0:
+		return fmt.Errorf("memory: invalid tree ID: %v", treeID)
+	case hashStrategy == trillian.HashStrategy_UNKNOWN_HASH_STRATEGY:
+		return fmt.Errorf("memory: invalid hash strategy: %v", hashStrategy)
+	case sigAlgo == sigpb.DigitallySigned_ANONYMOUS:
+		return fmt.Errorf("memory: invalid signature algorithm: %v", sigAlgo)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.trees[treeID]; ok {
+		return storage.Error{ErrType: storage.AlreadyExists, Detail: fmt.Sprintf("tree %d already exists", treeID)}
+	}
+	m.trees[treeID] = newLogTree(treeID, hashStrategy, trillian.DuplicatePolicy_DUPLICATES_NOT_ALLOWED, sigpb.DigitallySigned_NONE, hashSizeBytes)
+	// Logs created later by CreateLog must not reuse treeID.
+	if treeID >= m.nextID {
+		m.nextID = treeID + 1
+	}
+	return nil
+}
+
+// newLogTree returns an empty log with ID id.
+func newLogTree(id int64, hashStrategy trillian.HashStrategy, duplicatePolicy trillian.DuplicatePolicy, identityHashAlg sigpb.DigitallySigned_HashAlgorithm, identityHashSize int) *logTree {
+	return &logTree{
+		id:               id,
+		hashStrategy:     hashStrategy,
+		duplicatePolicy:  duplicatePolicy,
+		identityHashSize: identityHashSize,
+		identityHashAlg:  identityHashAlg,
+		leaves:           make(map[string]leafData),
+		sequenced:        make(map[int64]sequencedLeaf),
+		deadLetters:      make(map[string]storage.DeadLetter),
+		dedupTokens:      make(map[string]sequencedLeaf),
+		nodes:            make(map[string][]storage.Node),
+	}
+}
+
+// CheckDatabaseAccessible always succeeds.
+func (m *LogStorage) CheckDatabaseAccessible(ctx context.Context) error {
+	return nil
+}
+
+// Snapshot starts a read-only transaction not tied to any particular tree.
+func (m *LogStorage) Snapshot(ctx context.Context) (storage.ReadOnlyLogTX, error) {
+	return &readOnlyLogTX{ls: m}, nil
+}
+
+// BeginForTree starts a transaction for the specified treeID.
+func (m *LogStorage) BeginForTree(ctx context.Context, treeID int64) (storage.LogTreeTX, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tree, ok := m.trees[treeID]
+	if !ok {
+		return nil, fmt.Errorf("memory: unknown tree: %v", treeID)
+	}
+	if m.hasher != nil {
+		if err := merkle.CheckHasherForStrategy(m.hasher, tree.hashStrategy); err != nil {
+			return nil, fmt.Errorf("memory: tree %v: %v", treeID, err)
+		}
+	}
+	tx := &logTreeTX{
+		ls:          m,
+		tree:        tree.clone(),
+		baseVersion: tree.version,
+		open:        true,
+	}
+	tx.root = tx.tree.latestRoot()
+	return tx, nil
+}
+
+// SnapshotForTree starts a read-only transaction for the specified treeID.
+func (m *LogStorage) SnapshotForTree(ctx context.Context, treeID int64) (storage.ReadOnlyLogTreeTX, error) {
+	return m.BeginForTree(ctx, treeID)
+}
+
+// activeLogIDs returns the IDs of all the logs, in ascending order. If pendingOnly is
+// set only logs with queued leaves are included.
+func (m *LogStorage) activeLogIDs(pendingOnly bool) []int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]int64, 0, len(m.trees))
+	for id, tree := range m.trees {
+		if !pendingOnly || len(tree.queue) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// readOnlyLogTX implements storage.ReadOnlyLogTX.
+type readOnlyLogTX struct {
+	ls *LogStorage
+}
+
+func (t *readOnlyLogTX) Commit() error {
+	return nil
+}
+
+func (t *readOnlyLogTX) Rollback() error {
+	return nil
+}
+
+func (t *readOnlyLogTX) Close() error {
+	return nil
+}
+
+func (t *readOnlyLogTX) GetActiveLogIDs() ([]int64, error) {
+	return t.ls.activeLogIDs(false), nil
+}
+
+func (t *readOnlyLogTX) GetActiveLogIDsWithPendingWork() ([]int64, error) {
+	return t.ls.activeLogIDs(true), nil
+}
+
+// logTreeTX implements storage.LogTreeTX on a private copy of a log.
+type logTreeTX struct {
+	ls          *LogStorage
+	tree        *logTree
+	baseVersion int64
+	root        trillian.SignedLogRoot
+	open        bool
+}
+
+func (t *logTreeTX) ReadRevision() int64 {
+	return t.root.TreeRevision
+}
+
+func (t *logTreeTX) WriteRevision() int64 {
+	return t.root.TreeRevision + 1
+}
+
+func (t *logTreeTX) IsOpen() bool {
+	return t.open
+}
+
+// Commit replaces the stored log with this transaction's copy, unless the stored log
+// has been changed since the transaction began.
+func (t *logTreeTX) Commit() error {
+	if !t.open {
+		return errTXClosed
+	}
+	t.open = false
+
+	t.ls.mu.Lock()
+	defer t.ls.mu.Unlock()
+	current := t.ls.trees[t.tree.id]
+	if current.version != t.baseVersion {
+		return storage.Error{
+			ErrType: storage.TransientError,
+			Detail:  fmt.Sprintf("tree %d was modified by a concurrent transaction", t.tree.id),
+		}
+	}
+	t.tree.version++
+	t.ls.trees[t.tree.id] = t.tree
+	return nil
+}
+
+func (t *logTreeTX) Rollback() error {
+	if !t.open {
+		return errTXClosed
+	}
+	t.open = false
+	return nil
+}
+
+func (t *logTreeTX) Close() error {
+	if t.open {
+		return t.Rollback()
+	}
+	return nil
+}
+
+func (t *logTreeTX) GetMerkleNodes(treeRevision int64, ids []storage.NodeID) ([]storage.Node, error) {
+	if !t.open {
+		return nil, errTXClosed
+	}
+	var ret []storage.Node
+	for _, id := range ids {
+		revisions := t.tree.nodes[id.String()]
+		// Find the latest revision at or before treeRevision.
+		for i := len(revisions) - 1; i >= 0; i-- {
+			if revisions[i].NodeRevision <= treeRevision {
+				node := revisions[i]
+				node.NodeID = id
+				ret = append(ret, node)
+				break
+			}
+		}
+	}
+	return ret, nil
+}
+
+func (t *logTreeTX) SetMerkleNodes(nodes []storage.Node) error {
+	if !t.open {
+		return errTXClosed
+	}
+	rev := t.WriteRevision()
+	for _, node := range nodes {
+		if len(node.Hash) != hashSizeBytes {
+			return fmt.Errorf("memory: node %s has hash of length %d, want %d", node.NodeID.String(), len(node.Hash), hashSizeBytes)
+		}
+		key := node.NodeID.String()
+		node.NodeRevision = rev
+		node.Hash = copyBytes(node.Hash)
+		node.NodeID.Path = copyBytes(node.NodeID.Path)
+
+		// Copy the revisions so the log this transaction was copied from is unchanged.
+		revisions := t.tree.nodes[key]
+		updated := make([]storage.Node, 0, len(revisions)+1)
+		for _, r := range revisions {
+			if r.NodeRevision != rev {
+				updated = append(updated, r)
+			}
+		}
+		t.tree.nodes[key] = append(updated, node)
+	}
+	return nil
+}
+
+func (t *logTreeTX) QueueLeaves(leaves []*trillian.LogLeaf, queueTimestamp time.Time) error {
+	if !t.open {
+		return errTXClosed
+	}
+	// Don't accept batches if any of the leaves are invalid.
+	for _, leaf := range leaves {
+		if len(leaf.LeafIdentityHash) != t.tree.identityHashSize {
+			return fmt.Errorf("queued leaf must have a leaf ID hash of length %d", t.tree.identityHashSize)
+		}
+	}
+
+	for _, leaf := range leaves {
+		key := string(leaf.LeafIdentityHash)
+		messageID := make([]byte, 8)
+		if _, exists := t.tree.leaves[key]; exists {
+			if t.tree.duplicatePolicy != trillian.DuplicatePolicy_DUPLICATES_ALLOWED {
+				return storage.Error{
+					ErrType: storage.DuplicateLeaf,
+					Detail:  fmt.Sprintf("IdentityHash: %x", leaf.LeafIdentityHash),
+				}
+			}
+		} else {
+			t.tree.leaves[key] = leafData{
+				leafValue: copyBytes(leaf.LeafValue),
+				extraData: copyBytes(leaf.ExtraData),
+			}
+		}
+		// Duplicates of a leaf are queued separately, distinguished by a random message id.
+		if t.tree.duplicatePolicy == trillian.DuplicatePolicy_DUPLICATES_ALLOWED {
+			if _, err := rand.Read(messageID); err != nil {
+				return err
+			}
+		}
+		t.tree.queue = append(t.tree.queue, queuedLeaf{
+			identityHash:   copyBytes(leaf.LeafIdentityHash),
+			merkleLeafHash: copyBytes(leaf.MerkleLeafHash),
+			queuedNanos:    queueTimestamp.UnixNano(),
+			messageID:      messageID,
+		})
+	}
+	return nil
+}
+
+func (t *logTreeTX) QueueLeavesBatch(leaves []*trillian.LogLeaf, queueTimestamp time.Time) ([]error, error) {
+	if !t.open {
+		return nil, errTXClosed
+	}
+	for _, leaf := range leaves {
+		if len(leaf.LeafIdentityHash) != t.tree.identityHashSize {
+			return nil, fmt.Errorf("queued leaf must have a leaf ID hash of length %d", t.tree.identityHashSize)
+		}
+	}
+
+	// Queue the leaves one at a time, recording rather than returning duplicates.
+	statuses := make([]error, len(leaves))
+	for i := range leaves {
+		if err := t.QueueLeaves(leaves[i:i+1], queueTimestamp); err != nil {
+			if se, ok := err.(storage.Error); !ok || se.ErrType != storage.DuplicateLeaf {
+				return nil, err
+			}
+			statuses[i] = err
+		}
+	}
+	return statuses, nil
+}
+
+func (t *logTreeTX) IdentityHashAlgorithm() sigpb.DigitallySigned_HashAlgorithm {
+	return t.tree.identityHashAlg
+}
+
+func (t *logTreeTX) QueueLeafWithToken(leaf *trillian.LogLeaf, dedupToken string, queueTimestamp time.Time) (*trillian.LogLeaf, error) {
+	if !t.open {
+		return nil, errTXClosed
+	}
+	if queued, ok := t.tree.dedupTokens[dedupToken]; ok {
+		// The lowest index holding the leaf, as it is the first one sequenced.
+		index := int64(-1)
+		for i, s := range t.tree.sequenced {
+			if bytes.Equal(s.identityHash, queued.identityHash) && bytes.Equal(s.merkleLeafHash, queued.merkleLeafHash) && (index < 0 || i < index) {
+				index = i
+			}
+		}
+		return t.leaf(index, queued), nil
+	}
+	if err := t.QueueLeaves([]*trillian.LogLeaf{leaf}, queueTimestamp); err != nil {
+		return nil, err
+	}
+	t.tree.dedupTokens[dedupToken] = sequencedLeaf{
+		identityHash:   copyBytes(leaf.LeafIdentityHash),
+		merkleLeafHash: copyBytes(leaf.MerkleLeafHash),
+	}
+	return nil, nil
+}
+
+func (t *logTreeTX) DequeueLeaves(limit int, cutoffTime time.Time) ([]*trillian.LogLeaf, error) {
+	if !t.open {
+		return nil, errTXClosed
+	}
+	t.sortQueue()
+	leaves, _ := t.dequeueLeaves(limit, cutoffTime, nil)
+	return leaves, nil
+}
+
+func (t *logTreeTX) DequeueLeavesUnordered(limit int, cutoffTime time.Time) ([]*trillian.LogLeaf, error) {
+	if !t.open {
+		return nil, errTXClosed
+	}
+	// The queue is taken in whatever order it is held, which is the order the leaves were
+	// queued unless an ordered dequeue has sorted it since.
+	leaves, _ := t.dequeueLeaves(limit, cutoffTime, nil)
+	return leaves, nil
+}
+
+func (t *logTreeTX) DequeueLeavesAfter(limit int, cutoffTime time.Time, token []byte) ([]*trillian.LogLeaf, []byte, error) {
+	if !t.open {
+		return nil, nil, errTXClosed
+	}
+	var after *storage.QueuePosition
+	if token != nil {
+		p, err := storage.ParseQueueToken(token)
+		if err != nil {
+			return nil, nil, err
+		}
+		after = &p
+	}
+	t.sortQueue()
+	leaves, last := t.dequeueLeaves(limit, cutoffTime, after)
+	if len(leaves) < limit {
+		return leaves, nil, nil
+	}
+	return leaves, last.Token(), nil
+}
+
+// sortQueue puts the queue in order of queue time then identity hash, as for MySQL.
+func (t *logTreeTX) sortQueue() {
+	sort.SliceStable(t.tree.queue, func(i, j int) bool {
+		a, b := t.tree.queue[i], t.tree.queue[j]
+		if a.queuedNanos != b.queuedNanos {
+			return a.queuedNanos < b.queuedNanos
+		}
+		return bytes.Compare(a.identityHash, b.identityHash) < 0
+	})
+}
+
+// dequeueLeaves removes and returns up to limit eligible leaves, taken from the queue in
+// its current order and, if after is set, from those after that position. It also returns
+// the position of the last leaf taken.
+func (t *logTreeTX) dequeueLeaves(limit int, cutoffTime time.Time, after *storage.QueuePosition) ([]*trillian.LogLeaf, storage.QueuePosition) {
+	cutoff := cutoffTime.UnixNano()
+	var last storage.QueuePosition
+	leaves := make([]*trillian.LogLeaf, 0, limit)
+	remaining := make([]queuedLeaf, 0, len(t.tree.queue))
+	for _, q := range t.tree.queue {
+		if len(leaves) < limit && q.queuedNanos <= cutoff && (after == nil || after.After(q.queuedNanos, q.identityHash)) {
+			// As for MySQL, only the hashes are returned as that's all the sequencer needs.
+			leaves = append(leaves, &trillian.LogLeaf{
+				LeafIdentityHash:    q.identityHash,
+				MerkleLeafHash:      q.merkleLeafHash,
+				QueueTimestampNanos: q.queuedNanos,
+			})
+			last = storage.QueuePosition{QueueTimestampNanos: q.queuedNanos, LeafIdentityHash: q.identityHash}
+			continue
+		}
+		remaining = append(remaining, q)
+	}
+	t.tree.queue = remaining
+	return leaves, last
+}
+
+func (t *logTreeTX) UpdateSequencedLeaves(leaves []*trillian.LogLeaf) error {
+	if !t.open {
+		return errTXClosed
+	}
+	for _, leaf := range leaves {
+		if len(leaf.LeafIdentityHash) != t.tree.identityHashSize {
+			return errors.New("Sequenced leaf has incorrect hash size")
+		}
+		if _, exists := t.tree.sequenced[leaf.LeafIndex]; exists {
+			return storage.Error{
+				ErrType: storage.TransientError,
+				Detail:  fmt.Sprintf("a leaf is already sequenced at index %d", leaf.LeafIndex),
+			}
+		}
+		t.tree.sequenced[leaf.LeafIndex] = sequencedLeaf{
+			identityHash:   copyBytes(leaf.LeafIdentityHash),
+			merkleLeafHash: copyBytes(leaf.MerkleLeafHash),
+			queuedNanos:    leaf.QueueTimestampNanos,
+			integrateNanos: leaf.IntegrateTimestampNanos,
+		}
+	}
+	return nil
+}
+
+func (t *logTreeTX) DeadLetterLeaves(letters []storage.DeadLetter) error {
+	if !t.open {
+		return errTXClosed
+	}
+	for _, l := range letters {
+		if len(l.LeafIdentityHash) != t.tree.identityHashSize {
+			return errors.New("Dead-lettered leaf has incorrect hash size")
+		}
+	}
+	for _, l := range letters {
+		l.LeafIdentityHash = copyBytes(l.LeafIdentityHash)
+		l.MerkleLeafHash = copyBytes(l.MerkleLeafHash)
+		t.tree.deadLetters[string(l.LeafIdentityHash)] = l
+	}
+	return nil
+}
+
+func (t *logTreeTX) ListDeadLetters(limit int) ([]storage.DeadLetter, error) {
+	if !t.open {
+		return nil, errTXClosed
+	}
+	letters := make([]storage.DeadLetter, 0, len(t.tree.deadLetters))
+	for _, l := range t.tree.deadLetters {
+		letters = append(letters, l)
+	}
+	sort.Slice(letters, func(i, j int) bool {
+		if letters[i].TimestampNanos != letters[j].TimestampNanos {
+			return letters[i].TimestampNanos < letters[j].TimestampNanos
+		}
+		return bytes.Compare(letters[i].LeafIdentityHash, letters[j].LeafIdentityHash) < 0
+	})
+	if len(letters) > limit {
+		letters = letters[:limit]
+	}
+	return letters, nil
+}
+
+func (t *logTreeTX) RequeueDeadLetter(leafIdentityHash []byte, queueTimestamp time.Time) error {
+	if !t.open {
+		return errTXClosed
+	}
+	key := string(leafIdentityHash)
+	l, ok := t.tree.deadLetters[key]
+	if !ok {
+		return storage.Error{
+			ErrType: storage.NotFound,
+			Detail:  fmt.Sprintf("no dead letter with IdentityHash: %x", leafIdentityHash),
+		}
+	}
+	messageID := make([]byte, 8)
+	if t.tree.duplicatePolicy == trillian.DuplicatePolicy_DUPLICATES_ALLOWED {
+		if _, err := rand.Read(messageID); err != nil {
+			return err
+		}
+	}
+	delete(t.tree.deadLetters, key)
+	t.tree.queue = append(t.tree.queue, queuedLeaf{
+		identityHash:   l.LeafIdentityHash,
+		merkleLeafHash: l.MerkleLeafHash,
+		queuedNanos:    queueTimestamp.UnixNano(),
+		messageID:      messageID,
+	})
+	return nil
+}
+
+func (t *logTreeTX) GetSequencedLeafCount() (int64, error) {
+	if !t.open {
+		return 0, errTXClosed
+	}
+	return int64(len(t.tree.sequenced)), nil
+}
+
+func (t *logTreeTX) QueuedLeafCount() (int64, error) {
+	if !t.open {
+		return 0, errTXClosed
+	}
+	return int64(len(t.tree.queue)), nil
+}
+
+// leaf builds the LogLeaf sequenced at index.
+func (t *logTreeTX) leaf(index int64, s sequencedLeaf) *trillian.LogLeaf {
+	data := t.tree.leaves[string(s.identityHash)]
+	return &trillian.LogLeaf{
+		MerkleLeafHash:          s.merkleLeafHash,
+		LeafIdentityHash:        s.identityHash,
+		LeafValue:               data.leafValue,
+		ExtraData:               data.extraData,
+		LeafIndex:               index,
+		QueueTimestampNanos:     s.queuedNanos,
+		IntegrateTimestampNanos: s.integrateNanos,
+	}
+}
+
+func (t *logTreeTX) GetLeavesByIndex(leaves []int64) ([]*trillian.LogLeaf, error) {
+	if !t.open {
+		return nil, errTXClosed
+	}
+	ret := make([]*trillian.LogLeaf, 0, len(leaves))
+	for _, index := range leaves {
+		s, ok := t.tree.sequenced[index]
+		if !ok {
+			continue
+		}
+		ret = append(ret, t.leaf(index, s))
+	}
+	if got, want := len(ret), len(leaves); got != want {
+		return nil, fmt.Errorf("len(ret): %d, want %d", got, want)
+	}
+	return ret, nil
+}
+
+func (t *logTreeTX) GetLeavesByRange(start, count int64) ([]*trillian.LogLeaf, error) {
+	if !t.open {
+		return nil, errTXClosed
+	}
+	if start < 0 || count < 0 {
+		return nil, fmt.Errorf("invalid range: start %d, count %d", start, count)
+	}
+	var ret []*trillian.LogLeaf
+	for i := int64(0); i < count; i++ {
+		s, ok := t.tree.sequenced[start+i]
+		if !ok {
+			break
+		}
+		ret = append(ret, t.leaf(start+i, s))
+	}
+	return ret, nil
+}
+
+// GetLeavesByHash returns the sequenced leaves with the given Merkle leaf hashes. They are
+// always returned in ascending sequence number order.
+func (t *logTreeTX) GetLeavesByHash(leafHashes [][]byte, orderBySequence bool) ([]*trillian.LogLeaf, error) {
+	return t.getLeavesByHash(leafHashes, func(s sequencedLeaf) []byte { return s.merkleLeafHash })
+}
+
+func (t *logTreeTX) GetLeavesByIdentityHash(leafIdentityHashes [][]byte) ([]*trillian.LogLeaf, error) {
+	return t.getLeavesByHash(leafIdentityHashes, func(s sequencedLeaf) []byte { return s.identityHash })
+}
+
+// getLeavesByHash returns the sequenced leaves for which hashOf is one of hashes, in
+// index order.
+func (t *logTreeTX) getLeavesByHash(hashes [][]byte, hashOf func(sequencedLeaf) []byte) ([]*trillian.LogLeaf, error) {
+	if !t.open {
+		return nil, errTXClosed
+	}
+	wanted := make(map[string]bool, len(hashes))
+	for _, h := range hashes {
+		wanted[string(h)] = true
+	}
+	indices := make([]int64, 0, len(t.tree.sequenced))
+	for index, s := range t.tree.sequenced {
+		if wanted[string(hashOf(s))] {
+			indices = append(indices, index)
+		}
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+
+	var ret []*trillian.LogLeaf
+	for _, index := range indices {
+		ret = append(ret, t.leaf(index, t.tree.sequenced[index]))
+	}
+	return ret, nil
+}
+
+func (t *logTreeTX) LatestSignedLogRoot() (trillian.SignedLogRoot, error) {
+	if !t.open {
+		return trillian.SignedLogRoot{}, errTXClosed
+	}
+	return t.root, nil
+}
+
+func (t *logTreeTX) StoreSignedLogRoot(root trillian.SignedLogRoot) error {
+	if !t.open {
+		return errTXClosed
+	}
+	// As for MySQL, roots must have unique timestamps and revisions.
+	for _, r := range t.tree.roots {
+		if r.TimestampNanos == root.TimestampNanos || r.TreeRevision == root.TreeRevision {
+			return fmt.Errorf("memory: tree %d already has a root with timestamp %d or revision %d", t.tree.id, root.TimestampNanos, root.TreeRevision)
+		}
+	}
+	root.LogId = t.tree.id
+	root.RootHash = copyBytes(root.RootHash)
+	if root.Signature != nil {
+		root.Signature = proto.Clone(root.Signature).(*sigpb.DigitallySigned)
+	}
+	t.tree.roots = append(t.tree.roots, root)
+	return nil
+}
+
+func (t *logTreeTX) GetActiveLogIDs() ([]int64, error) {
+	return t.ls.activeLogIDs(false), nil
+}
+
+func (t *logTreeTX) GetActiveLogIDsWithPendingWork() ([]int64, error) {
+	return t.ls.activeLogIDs(true), nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/testonly"
+)
+
+func TestMemoryLogStorage(t *testing.T) {
+	tester := &testonly.LogStorageTester{
+		NewLogStorage: func() storage.LogStorage {
+			return NewLogStorage()
+		},
+		CreateLog: func(s storage.LogStorage, tree *trillian.Tree) (int64, error) {
+			created, err := s.(*LogStorage).CreateLog(tree)
+			if err != nil {
+				return 0, err
+			}
+			return created.TreeId, nil
+		},
+	}
+	tester.RunAllTests(t)
+}
+
+func TestBeginForTreeHashStrategy(t *testing.T) {
+	for _, test := range []struct {
+		hashType string
+		wantErr  bool
+	}{
+		{hashType: merkle.RFC6962SHA256Type},
+		{hashType: merkle.PlainSHA256Type, wantErr: true},
+		{hashType: merkle.RFC6962SHA3Type, wantErr: true},
+	} {
+		th, err := merkle.Factory(test.hashType)
+		if err != nil {
+			t.Fatalf("Factory(%v)=_,%v", test.hashType, err)
+		}
+		s := NewLogStorageWithHasher(th)
+		// testonly.LogTree has the RFC_6962 hash strategy.
+		tree, err := s.CreateLog(testonly.LogTree)
+		if err != nil {
+			t.Fatalf("CreateLog()=_,%v", err)
+		}
+		tx, err := s.BeginForTree(context.Background(), tree.TreeId)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%v: BeginForTree()=_,%v, want err? %v", test.hashType, err, test.wantErr)
+		}
+		if err == nil {
+			tx.Rollback()
+		}
+	}
+}
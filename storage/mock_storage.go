@@ -7,6 +7,7 @@ import (
 	context "context"
 	gomock "github.com/golang/mock/gomock"
 	trillian "github.com/google/trillian"
+	sigpb "github.com/google/trillian/crypto/sigpb"
 	time "time"
 )
 
@@ -52,6 +53,16 @@ func (_mr *_MockLogStorageRecorder) CheckDatabaseAccessible(arg0 interface{}) *g
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "CheckDatabaseAccessible", arg0)
 }
 
+func (_m *MockLogStorage) CreateTree(_param0 context.Context, _param1 int64, _param2 trillian.HashStrategy, _param3 sigpb.DigitallySigned_SignatureAlgorithm) error {
+	ret := _m.ctrl.Call(_m, "CreateTree", _param0, _param1, _param2, _param3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockLogStorageRecorder) CreateTree(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "CreateTree", arg0, arg1, arg2, arg3)
+}
+
 func (_m *MockLogStorage) Snapshot(_param0 context.Context) (ReadOnlyLogTX, error) {
 	ret := _m.ctrl.Call(_m, "Snapshot", _param0)
 	ret0, _ := ret[0].(ReadOnlyLogTX)
@@ -115,6 +126,16 @@ func (_mr *_MockLogTreeTXRecorder) Commit() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Commit")
 }
 
+func (_m *MockLogTreeTX) DeadLetterLeaves(_param0 []DeadLetter) error {
+	ret := _m.ctrl.Call(_m, "DeadLetterLeaves", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockLogTreeTXRecorder) DeadLetterLeaves(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeadLetterLeaves", arg0)
+}
+
 func (_m *MockLogTreeTX) DequeueLeaves(_param0 int, _param1 time.Time) ([]*trillian.LogLeaf, error) {
 	ret := _m.ctrl.Call(_m, "DequeueLeaves", _param0, _param1)
 	ret0, _ := ret[0].([]*trillian.LogLeaf)
@@ -126,6 +147,29 @@ func (_mr *_MockLogTreeTXRecorder) DequeueLeaves(arg0, arg1 interface{}) *gomock
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "DequeueLeaves", arg0, arg1)
 }
 
+func (_m *MockLogTreeTX) DequeueLeavesAfter(_param0 int, _param1 time.Time, _param2 []byte) ([]*trillian.LogLeaf, []byte, error) {
+	ret := _m.ctrl.Call(_m, "DequeueLeavesAfter", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]*trillian.LogLeaf)
+	ret1, _ := ret[1].([]byte)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+func (_mr *_MockLogTreeTXRecorder) DequeueLeavesAfter(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DequeueLeavesAfter", arg0, arg1, arg2)
+}
+
+func (_m *MockLogTreeTX) DequeueLeavesUnordered(_param0 int, _param1 time.Time) ([]*trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "DequeueLeavesUnordered", _param0, _param1)
+	ret0, _ := ret[0].([]*trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTreeTXRecorder) DequeueLeavesUnordered(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DequeueLeavesUnordered", arg0, arg1)
+}
+
 func (_m *MockLogTreeTX) GetActiveLogIDs() ([]int64, error) {
 	ret := _m.ctrl.Call(_m, "GetActiveLogIDs")
 	ret0, _ := ret[0].([]int64)
@@ -159,6 +203,17 @@ func (_mr *_MockLogTreeTXRecorder) GetLeavesByHash(arg0, arg1 interface{}) *gomo
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByHash", arg0, arg1)
 }
 
+func (_m *MockLogTreeTX) GetLeavesByIdentityHash(_param0 [][]byte) ([]*trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByIdentityHash", _param0)
+	ret0, _ := ret[0].([]*trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTreeTXRecorder) GetLeavesByIdentityHash(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIdentityHash", arg0)
+}
+
 func (_m *MockLogTreeTX) GetLeavesByIndex(_param0 []int64) ([]*trillian.LogLeaf, error) {
 	ret := _m.ctrl.Call(_m, "GetLeavesByIndex", _param0)
 	ret0, _ := ret[0].([]*trillian.LogLeaf)
@@ -170,6 +225,17 @@ func (_mr *_MockLogTreeTXRecorder) GetLeavesByIndex(arg0 interface{}) *gomock.Ca
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIndex", arg0)
 }
 
+func (_m *MockLogTreeTX) GetLeavesByRange(_param0 int64, _param1 int64) ([]*trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByRange", _param0, _param1)
+	ret0, _ := ret[0].([]*trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTreeTXRecorder) GetLeavesByRange(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByRange", arg0, arg1)
+}
+
 func (_m *MockLogTreeTX) GetMerkleNodes(_param0 int64, _param1 []NodeID) ([]Node, error) {
 	ret := _m.ctrl.Call(_m, "GetMerkleNodes", _param0, _param1)
 	ret0, _ := ret[0].([]Node)
@@ -192,6 +258,16 @@ func (_mr *_MockLogTreeTXRecorder) GetSequencedLeafCount() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSequencedLeafCount")
 }
 
+func (_m *MockLogTreeTX) IdentityHashAlgorithm() sigpb.DigitallySigned_HashAlgorithm {
+	ret := _m.ctrl.Call(_m, "IdentityHashAlgorithm")
+	ret0, _ := ret[0].(sigpb.DigitallySigned_HashAlgorithm)
+	return ret0
+}
+
+func (_mr *_MockLogTreeTXRecorder) IdentityHashAlgorithm() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "IdentityHashAlgorithm")
+}
+
 func (_m *MockLogTreeTX) IsOpen() bool {
 	ret := _m.ctrl.Call(_m, "IsOpen")
 	ret0, _ := ret[0].(bool)
@@ -213,6 +289,39 @@ func (_mr *_MockLogTreeTXRecorder) LatestSignedLogRoot() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "LatestSignedLogRoot")
 }
 
+func (_m *MockLogTreeTX) ListDeadLetters(_param0 int) ([]DeadLetter, error) {
+	ret := _m.ctrl.Call(_m, "ListDeadLetters", _param0)
+	ret0, _ := ret[0].([]DeadLetter)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTreeTXRecorder) ListDeadLetters(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListDeadLetters", arg0)
+}
+
+func (_m *MockLogTreeTX) QueuedLeafCount() (int64, error) {
+	ret := _m.ctrl.Call(_m, "QueuedLeafCount")
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTreeTXRecorder) QueuedLeafCount() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "QueuedLeafCount")
+}
+
+func (_m *MockLogTreeTX) QueueLeafWithToken(_param0 *trillian.LogLeaf, _param1 string, _param2 time.Time) (*trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "QueueLeafWithToken", _param0, _param1, _param2)
+	ret0, _ := ret[0].(*trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTreeTXRecorder) QueueLeafWithToken(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "QueueLeafWithToken", arg0, arg1, arg2)
+}
+
 func (_m *MockLogTreeTX) QueueLeaves(_param0 []*trillian.LogLeaf, _param1 time.Time) error {
 	ret := _m.ctrl.Call(_m, "QueueLeaves", _param0, _param1)
 	ret0, _ := ret[0].(error)
@@ -223,6 +332,17 @@ func (_mr *_MockLogTreeTXRecorder) QueueLeaves(arg0, arg1 interface{}) *gomock.C
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "QueueLeaves", arg0, arg1)
 }
 
+func (_m *MockLogTreeTX) QueueLeavesBatch(_param0 []*trillian.LogLeaf, _param1 time.Time) ([]error, error) {
+	ret := _m.ctrl.Call(_m, "QueueLeavesBatch", _param0, _param1)
+	ret0, _ := ret[0].([]error)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTreeTXRecorder) QueueLeavesBatch(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "QueueLeavesBatch", arg0, arg1)
+}
+
 func (_m *MockLogTreeTX) ReadRevision() int64 {
 	ret := _m.ctrl.Call(_m, "ReadRevision")
 	ret0, _ := ret[0].(int64)
@@ -233,6 +353,16 @@ func (_mr *_MockLogTreeTXRecorder) ReadRevision() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "ReadRevision")
 }
 
+func (_m *MockLogTreeTX) RequeueDeadLetter(_param0 []byte, _param1 time.Time) error {
+	ret := _m.ctrl.Call(_m, "RequeueDeadLetter", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockLogTreeTXRecorder) RequeueDeadLetter(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RequeueDeadLetter", arg0, arg1)
+}
+
 func (_m *MockLogTreeTX) Rollback() error {
 	ret := _m.ctrl.Call(_m, "Rollback")
 	ret0, _ := ret[0].(error)
@@ -616,6 +746,17 @@ func (_mr *_MockReadOnlyLogTreeTXRecorder) GetLeavesByHash(arg0, arg1 interface{
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByHash", arg0, arg1)
 }
 
+func (_m *MockReadOnlyLogTreeTX) GetLeavesByIdentityHash(_param0 [][]byte) ([]*trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByIdentityHash", _param0)
+	ret0, _ := ret[0].([]*trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyLogTreeTXRecorder) GetLeavesByIdentityHash(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIdentityHash", arg0)
+}
+
 func (_m *MockReadOnlyLogTreeTX) GetLeavesByIndex(_param0 []int64) ([]*trillian.LogLeaf, error) {
 	ret := _m.ctrl.Call(_m, "GetLeavesByIndex", _param0)
 	ret0, _ := ret[0].([]*trillian.LogLeaf)
@@ -627,6 +768,17 @@ func (_mr *_MockReadOnlyLogTreeTXRecorder) GetLeavesByIndex(arg0 interface{}) *g
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIndex", arg0)
 }
 
+func (_m *MockReadOnlyLogTreeTX) GetLeavesByRange(_param0 int64, _param1 int64) ([]*trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByRange", _param0, _param1)
+	ret0, _ := ret[0].([]*trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyLogTreeTXRecorder) GetLeavesByRange(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByRange", arg0, arg1)
+}
+
 func (_m *MockReadOnlyLogTreeTX) GetMerkleNodes(_param0 int64, _param1 []NodeID) ([]Node, error) {
 	ret := _m.ctrl.Call(_m, "GetMerkleNodes", _param0, _param1)
 	ret0, _ := ret[0].([]Node)
@@ -38,6 +38,7 @@ const (
 			HashAlgorithm,
 			SignatureAlgorithm,
 			DuplicatePolicy,
+			IdentityHashAlgorithm,
 			DisplayName,
 			Description,
 			CreateTime,
@@ -142,7 +143,7 @@ func readTree(row row) (*trillian.Tree, error) {
 	tree := &trillian.Tree{}
 
 	// Enums and Datetimes need an extra conversion step
-	var treeState, treeType, hashStrategy, hashAlgorithm, signatureAlgorithm, duplicatePolicy, createDatetime, updateDatetime string
+	var treeState, treeType, hashStrategy, hashAlgorithm, signatureAlgorithm, duplicatePolicy, identityHashAlgorithm, createDatetime, updateDatetime string
 	err := row.Scan(
 		&tree.TreeId,
 		&treeState,
@@ -151,6 +152,7 @@ func readTree(row row) (*trillian.Tree, error) {
 		&hashAlgorithm,
 		&signatureAlgorithm,
 		&duplicatePolicy,
+		&identityHashAlgorithm,
 		&tree.DisplayName,
 		&tree.Description,
 		&createDatetime,
@@ -193,6 +195,11 @@ func readTree(row row) (*trillian.Tree, error) {
 	} else {
 		return nil, fmt.Errorf("unknown DuplicatePolicy: %v", duplicatePolicy)
 	}
+	if iha, ok := spb.DigitallySigned_HashAlgorithm_value[identityHashAlgorithm]; ok {
+		tree.IdentityHashAlgorithm = spb.DigitallySigned_HashAlgorithm(iha)
+	} else {
+		return nil, fmt.Errorf("unknown IdentityHashAlgorithm: %v", identityHashAlgorithm)
+	}
 
 	// Let's make sure we didn't mismatch any of the casts above
 	ok := tree.TreeState.String() == treeState
@@ -201,11 +208,12 @@ func readTree(row row) (*trillian.Tree, error) {
 	ok = ok && tree.HashAlgorithm.String() == hashAlgorithm
 	ok = ok && tree.SignatureAlgorithm.String() == signatureAlgorithm
 	ok = ok && tree.DuplicatePolicy == duplicatePolicyMap[duplicatePolicy]
+	ok = ok && tree.IdentityHashAlgorithm.String() == identityHashAlgorithm
 	if !ok {
 		return nil, fmt.Errorf(
-			"mismatched enum: tree = %v, enums = [%v, %v, %v, %v, %v, %v]",
+			"mismatched enum: tree = %v, enums = [%v, %v, %v, %v, %v, %v, %v]",
 			tree,
-			treeState, treeType, hashStrategy, hashAlgorithm, signatureAlgorithm, duplicatePolicy)
+			treeState, treeType, hashStrategy, hashAlgorithm, signatureAlgorithm, duplicatePolicy, identityHashAlgorithm)
 	}
 
 	createTime, err := parseDatetime(createDatetime)
@@ -278,7 +286,11 @@ func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillia
 	if err != nil {
 		return nil, err
 	}
+	return t.createTree(ctx, id, tree)
+}
 
+// createTree inserts tree, which has been validated, with ID id.
+func (t *adminTX) createTree(ctx context.Context, id int64, tree *trillian.Tree) (*trillian.Tree, error) {
 	now := time.Now()
 	nowDatetime := toDatetime(now)
 	nowMillis := toMillisSinceEpoch(now)
@@ -297,11 +309,12 @@ func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillia
 			HashAlgorithm,
 			SignatureAlgorithm,
 			DuplicatePolicy,
+			IdentityHashAlgorithm,
 			DisplayName,
 			Description,
 			CreateTime,
 			UpdateTime)
-		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return nil, err
 	}
@@ -328,6 +341,7 @@ func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillia
 		newTree.HashAlgorithm.String(),
 		newTree.SignatureAlgorithm.String(),
 		duplicatePolicy,
+		newTree.IdentityHashAlgorithm.String(),
 		newTree.DisplayName,
 		newTree.Description,
 		nowDatetime, /* CreateTime */
@@ -1,5 +1,7 @@
 -- Caution - this removes all tables in our schema
 
+DROP TABLE IF EXISTS DedupToken;
+DROP TABLE IF EXISTS DeadLetter;
 DROP TABLE IF EXISTS Unsequenced;
 DROP TABLE IF EXISTS Subtree;
 DROP TABLE IF EXISTS SequencedLeafData;
@@ -0,0 +1,74 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/trillian/storage"
+)
+
+// MySQL server error numbers for failures that may succeed if the transaction is retried.
+const (
+	errLockWaitTimeout = 1205
+	errLockDeadlock    = 1213
+)
+
+// errDuplicateEntry is the MySQL server error number reported when an insert collides
+// with an existing key.
+const errDuplicateEntry = 1062
+
+// classifyError converts MySQL errors caused by lock contention into storage errors of
+// type TransientError, so that callers can retry the transaction. Other errors are
+// returned unchanged.
+func classifyError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !isLockError(err) || !errors.As(err, &mysqlErr) {
+		return err
+	}
+	return storage.Error{
+		ErrType: storage.TransientError,
+		Detail:  mysqlErr.Message,
+		Cause:   err,
+	}
+}
+
+// IsRetriable reports whether err, returned either by this package or directly by the
+// MySQL driver, was caused by lock contention and so may not recur if the transaction is
+// retried, including when it is wrapped. It can be used as the storage.ErrorClassifier of
+// a storage.RetryPolicy.
+func IsRetriable(err error) bool {
+	return storage.IsTransient(err) || isLockError(err)
+}
+
+// isDuplicateKey reports whether err was caused by a unique key collision.
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errDuplicateEntry
+}
+
+// isLockError reports whether err is a MySQL deadlock or lock wait timeout error.
+func isLockError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	switch mysqlErr.Number {
+	case errLockWaitTimeout, errLockDeadlock:
+		return true
+	}
+	return false
+}
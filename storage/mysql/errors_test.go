@@ -0,0 +1,45 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/trillian/storage"
+)
+
+func TestIsRetriable(t *testing.T) {
+	deadlock := &mysql.MySQLError{Number: errLockDeadlock, Message: "Deadlock found when trying to get lock"}
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{err: deadlock, want: true},
+		{err: &mysql.MySQLError{Number: errLockWaitTimeout, Message: "Lock wait timeout exceeded"}, want: true},
+		{err: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, want: false},
+		{err: classifyError(deadlock), want: true},
+		{err: fmt.Errorf("tree 1 batch: %w", deadlock), want: true},
+		{err: fmt.Errorf("tree 1 batch: %w", classifyError(deadlock)), want: true},
+		{err: storage.Error{ErrType: storage.DuplicateLeaf}, want: false},
+		{err: errors.New("deadlock"), want: false},
+		{err: nil, want: false},
+	} {
+		if got := IsRetriable(test.err); got != test.want {
+			t.Errorf("IsRetriable(%v)=%v, want %v", test.err, got, test.want)
+		}
+	}
+}
@@ -31,9 +31,10 @@ import (
 	"github.com/google/trillian"
 	spb "github.com/google/trillian/crypto/sigpb"
 	"github.com/google/trillian/storage"
+	storageto "github.com/google/trillian/storage/testonly"
 )
 
-var allTables = []string{"Unsequenced", "TreeHead", "SequencedLeafData", "LeafData", "Subtree", "TreeControl", "Trees", "MapLeaf", "MapHead"}
+var allTables = []string{"DedupToken", "Unsequenced", "TreeHead", "SequencedLeafData", "LeafData", "Subtree", "TreeControl", "Trees", "MapLeaf", "MapHead"}
 
 // Must be 32 bytes to match sha256 length if it was a real hash
 var dummyHash = []byte("hashxxxxhashxxxxhashxxxxhashxxxx")
@@ -117,6 +118,23 @@ func TestMySQLLogStorage_CheckDatabaseAccessible(t *testing.T) {
 	}
 }
 
+func TestMySQLLogStorage(t *testing.T) {
+	tester := &storageto.LogStorageTester{
+		NewLogStorage: func() storage.LogStorage {
+			cleanTestDB(DB)
+			return NewLogStorage(DB)
+		},
+		CreateLog: func(s storage.LogStorage, tree *trillian.Tree) (int64, error) {
+			created, err := createTree(DB, tree)
+			if err != nil {
+				return 0, err
+			}
+			return created.TreeId, nil
+		},
+	}
+	tester.RunAllTests(t)
+}
+
 func TestBegin(t *testing.T) {
 	cleanTestDB(DB)
 	logID1 := createLogForTests(DB)
@@ -287,6 +305,75 @@ func TestQueueLeaves(t *testing.T) {
 	}
 }
 
+func TestQueueLeavesBatch(t *testing.T) {
+	for _, batchSize := range []int{1, 7, DefaultQueueBatchSize} {
+		cleanTestDB(DB)
+		logID := createLogForTests(DB)
+		s := NewLogStorageWithBatchSize(DB, batchSize)
+
+		tx := beginLogTx(s, logID, t)
+		defer tx.Close()
+
+		leaves := createTestLeaves(leavesToInsert*10, 20)
+		statuses, err := tx.QueueLeavesBatch(leaves, fakeQueueTime)
+		if err != nil {
+			t.Fatalf("batchSize %d: Failed to queue leaves: %v", batchSize, err)
+		}
+		for i, status := range statuses {
+			if status != nil {
+				t.Errorf("batchSize %d: QueueLeavesBatch()[%d] = %v, want = nil", batchSize, i, status)
+			}
+		}
+		commit(tx, t)
+
+		// Every leaf should be in both tables.
+		for _, table := range []string{"LeafData", "Unsequenced"} {
+			var count int
+			if err := DB.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE TreeID=?", table), logID).Scan(&count); err != nil {
+				t.Fatalf("Could not query row count: %v", err)
+			}
+			if got, want := count, len(leaves); got != want {
+				t.Errorf("batchSize %d: got %d rows in %s, want %d", batchSize, got, table, want)
+			}
+		}
+	}
+}
+
+func benchmarkQueueLeaves(b *testing.B, queue func(tx storage.LogTreeTX, leaves []*trillian.LogLeaf) error) {
+	const batch = 1000
+	cleanTestDB(DB)
+	logID := createLogForTests(DB)
+	s := NewLogStorage(DB)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		leaves := createTestLeaves(batch, int64(i*batch))
+		tx, err := s.BeginForTree(context.Background(), logID)
+		if err != nil {
+			b.Fatalf("Failed to begin log tx: %v", err)
+		}
+		if err := queue(tx, leaves); err != nil {
+			b.Fatalf("Failed to queue leaves: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			b.Fatalf("Failed to commit tx: %v", err)
+		}
+	}
+}
+
+func BenchmarkQueueLeaves(b *testing.B) {
+	benchmarkQueueLeaves(b, func(tx storage.LogTreeTX, leaves []*trillian.LogLeaf) error {
+		return tx.QueueLeaves(leaves, fakeQueueTime)
+	})
+}
+
+func BenchmarkQueueLeavesBatch(b *testing.B) {
+	benchmarkQueueLeaves(b, func(tx storage.LogTreeTX, leaves []*trillian.LogLeaf) error {
+		_, err := tx.QueueLeavesBatch(leaves, fakeQueueTime)
+		return err
+	})
+}
+
 func TestDequeueLeavesNoneQueued(t *testing.T) {
 	cleanTestDB(DB)
 	logID := createLogForTests(DB)
@@ -458,6 +545,81 @@ func TestDequeueLeavesGuardInterval(t *testing.T) {
 	}
 }
 
+// Queues leaves at two different times and dequeues with a guard cutoff between them.
+// Only the older leaves should be returned, with the batch limit applying to them, and
+// the newer ones should become available once the cutoff passes their queue time.
+func TestDequeueLeavesGuardIntervalMixedAges(t *testing.T) {
+	cleanTestDB(DB)
+	logID := createLogForTests(DB)
+	s := NewLogStorage(DB)
+
+	oldLeaves := createTestLeaves(3, 0)
+	newLeaves := createTestLeaves(2, 3)
+	newQueueTime := fakeQueueTime.Add(10 * time.Second)
+	{
+		tx := beginLogTx(s, logID, t)
+		defer tx.Close()
+		if err := tx.QueueLeaves(oldLeaves, fakeQueueTime); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+		if err := tx.QueueLeaves(newLeaves, newQueueTime); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+		commit(tx, t)
+	}
+
+	cutoff := fakeQueueTime.Add(time.Second)
+	{
+		tx2 := beginLogTx(s, logID, t)
+		defer tx2.Close()
+		leaves2, err := tx2.DequeueLeaves(2, cutoff)
+		if err != nil {
+			t.Fatalf("Failed to dequeue leaves: %v", err)
+		}
+		if got, want := len(leaves2), 2; got != want {
+			t.Fatalf("Dequeued %d leaves with limit %d, want %d", got, want, want)
+		}
+		leaves3, err := tx2.DequeueLeaves(99, cutoff)
+		if err != nil {
+			t.Fatalf("Failed to dequeue leaves: %v", err)
+		}
+		dequeued := append(leaves2, leaves3...)
+		if got, want := len(dequeued), len(oldLeaves); got != want {
+			t.Fatalf("Dequeued %d leaves before guard cutoff, want %d", got, want)
+		}
+		ensureAllLeavesDistinct(dequeued, t)
+		ensureSameLeaves(dequeued, oldLeaves, t)
+		commit(tx2, t)
+	}
+
+	{
+		tx3 := beginLogTx(s, logID, t)
+		defer tx3.Close()
+		leaves4, err := tx3.DequeueLeaves(99, newQueueTime.Add(time.Second))
+		if err != nil {
+			t.Fatalf("Failed to dequeue leaves: %v", err)
+		}
+		if got, want := len(leaves4), len(newLeaves); got != want {
+			t.Fatalf("Dequeued %d leaves after guard cutoff passed, want %d", got, want)
+		}
+		ensureSameLeaves(leaves4, newLeaves, t)
+		commit(tx3, t)
+	}
+}
+
+// ensureSameLeaves checks that got holds exactly the leaves in want, in any order.
+func ensureSameLeaves(got, want []*trillian.LogLeaf, t *testing.T) {
+	wantHashes := make(map[string]bool)
+	for _, leaf := range want {
+		wantHashes[string(leaf.LeafIdentityHash)] = true
+	}
+	for _, leaf := range got {
+		if !wantHashes[string(leaf.LeafIdentityHash)] {
+			t.Errorf("Dequeued unexpected leaf %v", leaf)
+		}
+	}
+}
+
 func TestDequeueLeavesTimeOrdering(t *testing.T) {
 	// Queue two small batches of leaves at different timestamps. Do two separate dequeue
 	// transactions and make sure the returned leaves are respecting the time ordering of the
@@ -15,10 +15,11 @@ CREATE TABLE IF NOT EXISTS Trees(
   TreeId                BIGINT NOT NULL,
   TreeState             ENUM('ACTIVE', 'FROZEN', 'SOFT_DELETED', 'HARD_DELETED') NOT NULL,
   TreeType              ENUM('LOG', 'MAP') NOT NULL,
-  HashStrategy          ENUM('RFC_6962') NOT NULL,
-  HashAlgorithm         ENUM('SHA256') NOT NULL,
-  SignatureAlgorithm    ENUM('ECDSA', 'RSA') NOT NULL,
+  HashStrategy          ENUM('RFC_6962', 'PLAIN_SHA256', 'RFC_6962_SHA3_256') NOT NULL,
+  HashAlgorithm         ENUM('SHA1', 'SHA256', 'SHA384', 'SHA512', 'SHA3_256') NOT NULL,
+  SignatureAlgorithm    ENUM('ECDSA', 'RSA', 'ED25519', 'RSA_PSS') NOT NULL,
   DuplicatePolicy       ENUM('NOT_ALLOWED', 'ALLOWED') NOT NULL,
+  IdentityHashAlgorithm ENUM('NONE', 'SHA1', 'SHA256', 'SHA384', 'SHA512', 'SHA3_256') NOT NULL DEFAULT 'NONE',
   DisplayName           VARCHAR(20),
   Description           VARCHAR(200),
   CreateTime            DATETIME NOT NULL,
@@ -55,6 +56,7 @@ CREATE TABLE IF NOT EXISTS TreeHead(
   RootHash             VARBINARY(255) NOT NULL,
   RootSignature        VARBINARY(255) NOT NULL,
   TreeRevision         BIGINT,
+  KeyId                VARBINARY(255),
   PRIMARY KEY(TreeId, TreeHeadTimestamp),
   UNIQUE INDEX TreeRevisionIdx(TreeId, TreeRevision),
   FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
@@ -104,11 +106,38 @@ CREATE TABLE IF NOT EXISTS SequencedLeafData(
   -- This is a MerkleLeafHash as defined by the treehasher that the log uses. For example for
   -- CT this hash will include the leaf prefix byte as well as the leaf data.
   MerkleLeafHash       VARBINARY(255) NOT NULL,
+  -- The times at which the leaf was queued and integrated into the tree.
+  QueueTimestampNanos      BIGINT NOT NULL DEFAULT 0,
+  IntegrateTimestampNanos  BIGINT NOT NULL DEFAULT 0,
   PRIMARY KEY(TreeId, SequenceNumber),
   FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE,
   FOREIGN KEY(TreeId, LeafIdentityHash) REFERENCES LeafData(TreeId, LeafIdentityHash) ON DELETE CASCADE
 );
 
+-- Leaves that were dequeued but rejected by the sequencer, for example because they failed
+-- validation. They stay here, with their LeafData, until they are queued again.
+CREATE TABLE IF NOT EXISTS DeadLetter(
+  TreeId                    BIGINT NOT NULL,
+  LeafIdentityHash          VARBINARY(255) NOT NULL,
+  MerkleLeafHash            VARBINARY(255) NOT NULL,
+  -- Why the sequencer rejected the leaf.
+  Reason                    TEXT NOT NULL,
+  DeadLetterTimestampNanos  BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, LeafIdentityHash),
+  FOREIGN KEY(TreeId, LeafIdentityHash) REFERENCES LeafData(TreeId, LeafIdentityHash) ON DELETE CASCADE
+);
+
+-- Client supplied tokens for leaves queued with QueueLeafWithToken, so that a leaf
+-- submitted more than once with the same token is only queued once.
+CREATE TABLE IF NOT EXISTS DedupToken(
+  TreeId                    BIGINT NOT NULL,
+  Token                     VARBINARY(255) NOT NULL,
+  LeafIdentityHash          VARBINARY(255) NOT NULL,
+  MerkleLeafHash            VARBINARY(255) NOT NULL,
+  PRIMARY KEY(TreeId, Token),
+  FOREIGN KEY(TreeId, LeafIdentityHash) REFERENCES LeafData(TreeId, LeafIdentityHash) ON DELETE CASCADE
+);
+
 CREATE TABLE IF NOT EXISTS Unsequenced(
   TreeId               BIGINT NOT NULL,
   -- This is a personality specific has of some subset of the leaf data.
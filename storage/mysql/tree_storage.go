@@ -31,8 +31,8 @@ import (
 // These statements are fixed
 const (
 	insertSubtreeMultiSQL = `INSERT INTO Subtree(TreeId, SubtreeId, Nodes, SubtreeRevision) ` + placeholderSQL
-	insertTreeHeadSQL     = `INSERT INTO TreeHead(TreeId,TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature)
-		 VALUES(?,?,?,?,?,?)`
+	insertTreeHeadSQL     = `INSERT INTO TreeHead(TreeId,TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature,KeyId)
+		 VALUES(?,?,?,?,?,?,?)`
 	selectTreeRevisionAtSizeOrLargerSQL = "SELECT TreeRevision,TreeSize FROM TreeHead WHERE TreeId=? AND TreeSize>=? ORDER BY TreeRevision LIMIT 1"
 	selectActiveLogsSQL                 = "SELECT TreeId from Trees where TreeType='LOG'"
 	selectActiveLogsWithUnsequencedSQL  = "SELECT DISTINCT t.TreeId from Trees t INNER JOIN Unsequenced u WHERE TreeType='LOG' AND t.TreeId=u.TreeId"
@@ -360,13 +360,13 @@ func (t *treeTX) Commit() error {
 			return t.storeSubtrees(st)
 		}); err != nil {
 			glog.Warningf("TX commit flush error: %v", err)
-			return err
+			return classifyError(err)
 		}
 	}
 	t.closed = true
 	if err := t.tx.Commit(); err != nil {
 		glog.Warningf("TX commit error: %s", err)
-		return err
+		return classifyError(err)
 	}
 	return nil
 }
@@ -395,15 +395,9 @@ func (t *treeTX) IsOpen() bool {
 	return !t.closed
 }
 
+// checkDatabaseAccessible runs a trivial query, which fails if the database cannot be
+// reached before ctx is done.
 func checkDatabaseAccessible(ctx context.Context, db *sql.DB) error {
-	_ = ctx
-
-	stmt, err := db.Prepare("SELECT TreeId FROM Trees LIMIT 1")
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-
-	_, err = stmt.Exec()
-	return err
+	var one int
+	return db.QueryRowContext(ctx, "SELECT 1").Scan(&one)
 }
@@ -0,0 +1,27 @@
+-- MySQL / MariaDB upgrades for databases created with an earlier storage.sql.
+--
+-- storage.sql only creates tables that do not exist, so it does not change the tables
+-- of an existing database. The statements below add what was added to those tables
+-- since. Each section must be run once, in order, starting from the first change the
+-- database predates.
+
+-- TreeHead.KeyId is the ID of the key that signed the root, so that roots signed before
+-- a key rotation can still be verified. Existing roots are left with a NULL key ID.
+ALTER TABLE TreeHead ADD COLUMN KeyId VARBINARY(255);
+
+-- SequencedLeafData records when each leaf was queued and integrated. Leaves sequenced
+-- before the upgrade have both timestamps set to 0.
+ALTER TABLE SequencedLeafData ADD COLUMN QueueTimestampNanos BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE SequencedLeafData ADD COLUMN IntegrateTimestampNanos BIGINT NOT NULL DEFAULT 0;
+
+-- Trees.IdentityHashAlgorithm is the algorithm of the leaf identity hashes of the tree.
+-- Existing trees get NONE, which keeps the SHA256 identity hashes they were built with.
+ALTER TABLE Trees ADD COLUMN IdentityHashAlgorithm ENUM('NONE', 'SHA1', 'SHA256', 'SHA384', 'SHA512', 'SHA3_256') NOT NULL DEFAULT 'NONE' AFTER DuplicatePolicy;
+
+-- Trees.HashStrategy can record the plain SHA256 and the RFC 6962 SHA3-256 hashers.
+ALTER TABLE Trees MODIFY COLUMN HashStrategy ENUM('RFC_6962', 'PLAIN_SHA256', 'RFC_6962_SHA3_256') NOT NULL;
+
+-- Trees.HashAlgorithm and Trees.SignatureAlgorithm can record every algorithm a key
+-- manager can sign roots with.
+ALTER TABLE Trees MODIFY COLUMN HashAlgorithm ENUM('SHA1', 'SHA256', 'SHA384', 'SHA512', 'SHA3_256') NOT NULL;
+ALTER TABLE Trees MODIFY COLUMN SignatureAlgorithm ENUM('ECDSA', 'RSA', 'ED25519', 'RSA_PSS') NOT NULL;
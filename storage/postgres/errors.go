@@ -0,0 +1,73 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package postgres
+
+import (
+	"errors"
+
+	"github.com/google/trillian/storage"
+	"github.com/lib/pq"
+)
+
+// PostgreSQL SQLSTATE codes for failures that may succeed if the transaction is retried.
+const (
+	errSerializationFailure = "40001"
+	errDeadlockDetected     = "40P01"
+)
+
+// errUniqueViolation is the SQLSTATE code reported when an insert collides with an
+// existing key.
+const errUniqueViolation = "23505"
+
+// classifyError converts PostgreSQL errors caused by lock contention or serialization
+// conflicts into storage errors of type TransientError, so that callers can retry the
+// transaction. Other errors are returned unchanged.
+func classifyError(err error) error {
+	var pqErr *pq.Error
+	if !isConflictError(err) || !errors.As(err, &pqErr) {
+		return err
+	}
+	return storage.Error{
+		ErrType: storage.TransientError,
+		Detail:  pqErr.Message,
+		Cause:   err,
+	}
+}
+
+// IsRetriable reports whether err, returned either by this package or directly by the
+// PostgreSQL driver, was caused by a deadlock or serialization failure and so may not
+// recur if the transaction is retried, including when it is wrapped. It can be used as
+// the storage.ErrorClassifier of a storage.RetryPolicy.
+func IsRetriable(err error) bool {
+	return storage.IsTransient(err) || isConflictError(err)
+}
+
+// isConflictError reports whether err is a PostgreSQL deadlock or serialization failure.
+func isConflictError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	switch pqErr.Code {
+	case errSerializationFailure, errDeadlockDetected:
+		return true
+	}
+	return false
+}
+
+// isDuplicateKey reports whether err was caused by a unique key collision.
+func isDuplicateKey(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == errUniqueViolation
+}
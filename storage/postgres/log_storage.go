@@ -0,0 +1,1132 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	spb "github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+)
+
+const (
+	getTreePropertiesSQL  = "SELECT DuplicatePolicy,IdentityHashAlgorithm,HashStrategy FROM Trees WHERE TreeId=$1"
+	countTreesSQL         = "SELECT COUNT(*) FROM Trees WHERE TreeId=$1"
+	selectQueuedLeavesSQL = `SELECT LeafIdentityHash,MerkleLeafHash,QueueTimestampNanos
+			FROM Unsequenced
+			WHERE TreeID=$1
+			AND QueueTimestampNanos<=$2
+			ORDER BY QueueTimestampNanos,LeafIdentityHash ASC LIMIT $3`
+	// selectQueuedLeavesUnorderedSQL leaves out the ORDER BY of selectQueuedLeavesSQL so
+	// that the database need not sort the eligible leaves.
+	selectQueuedLeavesUnorderedSQL = `SELECT LeafIdentityHash,MerkleLeafHash,QueueTimestampNanos
+			FROM Unsequenced
+			WHERE TreeID=$1
+			AND QueueTimestampNanos<=$2
+			LIMIT $3`
+	// selectQueuedLeavesAfterSQL is selectQueuedLeavesSQL restricted to the leaves after a
+	// queue position.
+	selectQueuedLeavesAfterSQL = `SELECT LeafIdentityHash,MerkleLeafHash,QueueTimestampNanos
+			FROM Unsequenced
+			WHERE TreeID=$1
+			AND QueueTimestampNanos<=$2
+			AND (QueueTimestampNanos>$3 OR (QueueTimestampNanos=$3 AND LeafIdentityHash>$4))
+			ORDER BY QueueTimestampNanos,LeafIdentityHash ASC LIMIT $5`
+	insertUnsequencedLeafSQL = `INSERT INTO LeafData(TreeId,LeafIdentityHash,LeafValue,ExtraData)
+			VALUES($1,$2,$3,$4) ON CONFLICT DO NOTHING`
+	insertUnsequencedLeafSQLNoDuplicates = `INSERT INTO LeafData(TreeId,LeafIdentityHash,LeafValue,ExtraData)
+			VALUES($1,$2,$3,$4)`
+	insertUnsequencedEntrySQL = `INSERT INTO Unsequenced(TreeId,LeafIdentityHash,MerkleLeafHash,MessageId,QueueTimestampNanos)
+			VALUES($1,$2,$3,$4,$5)`
+	insertSequencedLeafSQL = `INSERT INTO SequencedLeafData(TreeId,LeafIdentityHash,MerkleLeafHash,SequenceNumber,QueueTimestampNanos,IntegrateTimestampNanos)
+			VALUES($1,$2,$3,$4,$5,$6)`
+	insertDeadLetterSQL = `INSERT INTO DeadLetter(TreeId,LeafIdentityHash,MerkleLeafHash,Reason,DeadLetterTimestampNanos)
+			VALUES($1,$2,$3,$4,$5)
+			ON CONFLICT (TreeId,LeafIdentityHash) DO UPDATE
+			SET MerkleLeafHash=EXCLUDED.MerkleLeafHash,Reason=EXCLUDED.Reason,DeadLetterTimestampNanos=EXCLUDED.DeadLetterTimestampNanos`
+	selectDeadLettersSQL = `SELECT LeafIdentityHash,MerkleLeafHash,Reason,DeadLetterTimestampNanos
+			FROM DeadLetter
+			WHERE TreeId=$1
+			ORDER BY DeadLetterTimestampNanos,LeafIdentityHash ASC LIMIT $2`
+	deleteDeadLetterSQL = "DELETE FROM DeadLetter WHERE TreeId=$1 AND LeafIdentityHash=$2 RETURNING MerkleLeafHash"
+
+	selectDedupTokenSQL = "SELECT LeafIdentityHash,MerkleLeafHash FROM DedupToken WHERE TreeId=$1 AND Token=$2"
+	insertDedupTokenSQL = `INSERT INTO DedupToken(TreeId,Token,LeafIdentityHash,MerkleLeafHash)
+			VALUES($1,$2,$3,$4)`
+	selectLeafDataSQL = "SELECT LeafValue,ExtraData FROM LeafData WHERE TreeId=$1 AND LeafIdentityHash=$2"
+
+	selectSequencedLeafCountSQL  = "SELECT COUNT(*) FROM SequencedLeafData WHERE TreeId=$1"
+	selectQueuedLeafCountSQL     = "SELECT COUNT(*) FROM Unsequenced WHERE TreeId=$1"
+	selectLatestSignedLogRootSQL = `SELECT TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature,KeyId
+			FROM TreeHead WHERE TreeId=$1
+			ORDER BY TreeHeadTimestamp DESC LIMIT 1`
+
+	// These statements need to be expanded to provide the correct number of parameter placeholders.
+	// See expandPlaceholderSQL for how their '?' placeholders are numbered.
+	deleteUnsequencedSQL   = "DELETE FROM Unsequenced WHERE LeafIdentityHash IN (<placeholder>) AND TreeId = ?"
+	selectLeavesByIndexSQL = `SELECT s.MerkleLeafHash,l.LeafIdentityHash,l.LeafValue,s.SequenceNumber,l.ExtraData,s.QueueTimestampNanos,s.IntegrateTimestampNanos
+			FROM LeafData l,SequencedLeafData s
+			WHERE l.LeafIdentityHash = s.LeafIdentityHash
+			AND s.SequenceNumber IN (` + placeholderSQL + `) AND l.TreeId = ? AND s.TreeId = l.TreeId`
+	selectLeavesByMerkleHashSQL = `SELECT s.MerkleLeafHash,l.LeafIdentityHash,l.LeafValue,s.SequenceNumber,l.ExtraData,s.QueueTimestampNanos,s.IntegrateTimestampNanos
+			FROM LeafData l,SequencedLeafData s
+			WHERE l.LeafIdentityHash = s.LeafIdentityHash
+			AND s.MerkleLeafHash IN (` + placeholderSQL + `) AND l.TreeId = ? AND s.TreeId = l.TreeId`
+	selectLeavesByLeafIdentityHashSQL = `SELECT s.MerkleLeafHash,l.LeafIdentityHash,l.LeafValue,s.SequenceNumber,l.ExtraData,s.QueueTimestampNanos,s.IntegrateTimestampNanos
+			FROM LeafData l,SequencedLeafData s
+			WHERE l.LeafIdentityHash = s.LeafIdentityHash
+			AND s.LeafIdentityHash IN (` + placeholderSQL + `) AND l.TreeId = ? AND s.TreeId = l.TreeId`
+
+	selectExistingLeafIdentityHashesSQL = `SELECT LeafIdentityHash FROM LeafData
+			WHERE LeafIdentityHash IN (` + placeholderSQL + `) AND TreeId = ?`
+	insertLeafDataMultiSQL    = `INSERT INTO LeafData(TreeId,LeafIdentityHash,LeafValue,ExtraData) ` + placeholderSQL
+	insertUnsequencedMultiSQL = `INSERT INTO Unsequenced(TreeId,LeafIdentityHash,MerkleLeafHash,MessageId,QueueTimestampNanos) ` +
+		placeholderSQL
+	onDuplicateLeafDataSQL = " ON CONFLICT DO NOTHING"
+
+	// Same as above except with leaves ordered by sequence so we only incur this cost when necessary
+	orderBySequenceNumberSQL                     = " ORDER BY s.SequenceNumber"
+	selectLeavesByMerkleHashOrderedBySequenceSQL = selectLeavesByMerkleHashSQL + orderBySequenceNumberSQL
+
+	// Leaves in a range are read with a single query, which stops at the last sequenced leaf.
+	selectLeavesByRangeSQL = `SELECT s.MerkleLeafHash,l.LeafIdentityHash,l.LeafValue,s.SequenceNumber,l.ExtraData,s.QueueTimestampNanos,s.IntegrateTimestampNanos
+			FROM LeafData l,SequencedLeafData s
+			WHERE l.LeafIdentityHash = s.LeafIdentityHash
+			AND s.SequenceNumber >= $1 AND l.TreeId = $2 AND s.TreeId = l.TreeId
+			ORDER BY s.SequenceNumber LIMIT $3`
+)
+
+var defaultLogStrata = []int{8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8}
+
+// DefaultQueueBatchSize is the maximum number of rows written by a single INSERT
+// statement in QueueLeavesBatch, unless overridden with NewLogStorageWithBatchSize.
+const DefaultQueueBatchSize = 500
+
+type pgLogStorage struct {
+	*pgTreeStorage
+	queueBatchSize int
+	// treeHasher, if set, is used to populate the internal nodes of cached subtrees.
+	treeHasher merkle.TreeHasher
+}
+
+// NewLogStorage creates a storage.LogStorage backed by the given PostgreSQL database.
+func NewLogStorage(db *sql.DB) storage.LogStorage {
+	return NewLogStorageWithBatchSize(db, DefaultQueueBatchSize)
+}
+
+// NewLogStorageWithBatchSize is like NewLogStorage, but QueueLeavesBatch writes at
+// most batchSize rows per INSERT statement. Each row uses up to five parameters, so
+// batchSize can be lowered to respect a driver's limit on parameters per statement.
+func NewLogStorageWithBatchSize(db *sql.DB, batchSize int) storage.LogStorage {
+	if batchSize <= 0 {
+		batchSize = DefaultQueueBatchSize
+	}
+	return &pgLogStorage{
+		pgTreeStorage:  newTreeStorage(db),
+		queueBatchSize: batchSize,
+	}
+}
+
+// NewLogStorageWithHasher is like NewLogStorage, but the internal nodes of the subtrees
+// read from storage are recomputed with th, which must be the hasher the trees were
+// built with.
+func NewLogStorageWithHasher(db *sql.DB, th merkle.TreeHasher) storage.LogStorage {
+	s := NewLogStorage(db).(*pgLogStorage)
+	s.treeHasher = th
+	return s
+}
+
+func (m *pgLogStorage) CheckDatabaseAccessible(ctx context.Context) error {
+	return checkDatabaseAccessible(ctx, m.db)
+}
+
+func (m *pgLogStorage) getLeavesByIndexStmt(num int) (*sql.Stmt, error) {
+	return m.getStmt(selectLeavesByIndexSQL, num, "?", "?")
+}
+
+func (m *pgLogStorage) getLeavesByMerkleHashStmt(num int, orderBySequence bool) (*sql.Stmt, error) {
+	if orderBySequence {
+		return m.getStmt(selectLeavesByMerkleHashOrderedBySequenceSQL, num, "?", "?")
+	}
+
+	return m.getStmt(selectLeavesByMerkleHashSQL, num, "?", "?")
+}
+
+func (m *pgLogStorage) getLeavesByLeafIdentityHashStmt(num int) (*sql.Stmt, error) {
+	return m.getStmt(selectLeavesByLeafIdentityHashSQL, num, "?", "?")
+}
+
+func (m *pgLogStorage) getDeleteUnsequencedStmt(num int) (*sql.Stmt, error) {
+	return m.getStmt(deleteUnsequencedSQL, num, "?", "?")
+}
+
+func (m *pgLogStorage) getExistingLeafIdentityHashesStmt(num int) (*sql.Stmt, error) {
+	return m.getStmt(selectExistingLeafIdentityHashesSQL, num, "?", "?")
+}
+
+func (m *pgLogStorage) getInsertLeafDataStmt(num int, allowDuplicates bool) (*sql.Stmt, error) {
+	if allowDuplicates {
+		return m.getStmt(insertLeafDataMultiSQL+onDuplicateLeafDataSQL, num, "VALUES(?,?,?,?)", "(?,?,?,?)")
+	}
+	return m.getStmt(insertLeafDataMultiSQL, num, "VALUES(?,?,?,?)", "(?,?,?,?)")
+}
+
+func (m *pgLogStorage) getInsertUnsequencedStmt(num int) (*sql.Stmt, error) {
+	return m.getStmt(insertUnsequencedMultiSQL, num, "VALUES(?,?,?,?,?)", "(?,?,?,?,?)")
+}
+
+func getActiveLogIDsInternal(tx *sql.Tx, sql string) ([]int64, error) {
+	rows, err := tx.Query(sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logIDs := make([]int64, 0)
+	for rows.Next() {
+		var treeID int64
+		if err := rows.Scan(&treeID); err != nil {
+			return nil, err
+		}
+		logIDs = append(logIDs, treeID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return logIDs, nil
+}
+
+func getActiveLogIDs(tx *sql.Tx) ([]int64, error) {
+	return getActiveLogIDsInternal(tx, selectActiveLogsSQL)
+}
+
+func getActiveLogIDsWithPendingWork(tx *sql.Tx) ([]int64, error) {
+	return getActiveLogIDsInternal(tx, selectActiveLogsWithUnsequencedSQL)
+}
+
+// readOnlyLogTX implements storage.ReadOnlyLogTX
+type readOnlyLogTX struct {
+	tx *sql.Tx
+}
+
+func (m *pgLogStorage) Snapshot(ctx context.Context) (storage.ReadOnlyLogTX, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		glog.Warningf("Could not start ReadOnlyLogTX: %s", err)
+		return nil, err
+	}
+	return &readOnlyLogTX{tx}, nil
+}
+
+func (t *readOnlyLogTX) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *readOnlyLogTX) Rollback() error {
+	return t.tx.Rollback()
+}
+
+func (t *readOnlyLogTX) Close() error {
+	if err := t.Rollback(); err != nil && err != sql.ErrTxDone {
+		glog.Warningf("Rollback error on Close(): %v", err)
+		return err
+	}
+	return nil
+}
+
+func (t *readOnlyLogTX) GetActiveLogIDs() ([]int64, error) {
+	return getActiveLogIDs(t.tx)
+}
+
+func (t *readOnlyLogTX) GetActiveLogIDsWithPendingWork() ([]int64, error) {
+	return getActiveLogIDsWithPendingWork(t.tx)
+}
+
+// hasher returns the hasher for the tree, which was recorded with the hash strategy hs.
+// A hasher given to the storage must match the tree's.
+func (m *pgLogStorage) hasher(treeID int64, hs trillian.HashStrategy) (merkle.TreeHasher, error) {
+	if m.treeHasher != nil {
+		if err := merkle.CheckHasherForStrategy(m.treeHasher, hs); err != nil {
+			return nil, fmt.Errorf("tree %d: %v", treeID, err)
+		}
+		return m.treeHasher, nil
+	}
+	return merkle.HasherForStrategy(hs)
+}
+
+func (m *pgLogStorage) beginInternal(ctx context.Context, treeID int64) (storage.LogTreeTX, error) {
+	// TODO(codingllama): Validate treeType
+	var duplicatePolicy, identityHashAlgorithm, hashStrategy string
+	if err := m.db.QueryRow(getTreePropertiesSQL, treeID).Scan(&duplicatePolicy, &identityHashAlgorithm, &hashStrategy); err != nil {
+		return nil, fmt.Errorf("failed to get tree row for treeID %v: %s", treeID, err)
+	}
+	policy, ok := duplicatePolicyMap[duplicatePolicy]
+	if !ok {
+		return nil, fmt.Errorf("unknown DuplicatePolicy: %v", duplicatePolicy)
+	}
+	iha, ok := spb.DigitallySigned_HashAlgorithm_value[identityHashAlgorithm]
+	if !ok {
+		return nil, fmt.Errorf("unknown IdentityHashAlgorithm: %v", identityHashAlgorithm)
+	}
+	identityHasher, err := crypto.LeafIdentityHasher(spb.DigitallySigned_HashAlgorithm(iha))
+	if err != nil {
+		return nil, err
+	}
+	hs, ok := trillian.HashStrategy_value[hashStrategy]
+	if !ok {
+		return nil, fmt.Errorf("unknown HashStrategy: %v", hashStrategy)
+	}
+
+	hasher, err := m.hasher(treeID, trillian.HashStrategy(hs))
+	if err != nil {
+		return nil, err
+	}
+
+	ttx, err := m.beginTreeTx(ctx, treeID, hasher.Size(), defaultLogStrata, cache.PopulateLogSubtreeNodes(hasher), cache.PrepareLogSubtreeWrite())
+	if err != nil {
+		return nil, err
+	}
+
+	ltx := &logTreeTX{
+		treeTX:           ttx,
+		ls:               m,
+		duplicatePolicy:  policy,
+		identityHashSize: identityHasher.Size(),
+		identityHashAlg:  spb.DigitallySigned_HashAlgorithm(iha),
+	}
+
+	ltx.root, err = ltx.fetchLatestRoot()
+	if err != nil {
+		ttx.Rollback()
+		return nil, err
+	}
+	ltx.treeTX.writeRevision = ltx.root.TreeRevision + 1
+
+	return ltx, nil
+}
+
+func (m *pgLogStorage) BeginForTree(ctx context.Context, treeID int64) (storage.LogTreeTX, error) {
+	return m.beginInternal(ctx, treeID)
+}
+
+// CreateTree creates an empty log with ID treeID. See storage.LogStorage.
+func (m *pgLogStorage) CreateTree(ctx context.Context, treeID int64, hashStrategy trillian.HashStrategy, sigAlgo spb.DigitallySigned_SignatureAlgorithm) error {
+	tree := &trillian.Tree{
+		TreeState:          trillian.TreeState_ACTIVE,
+		TreeType:           trillian.TreeType_LOG,
+		HashStrategy:       hashStrategy,
+		HashAlgorithm:      spb.DigitallySigned_SHA256,
+		SignatureAlgorithm: sigAlgo,
+		DuplicatePolicy:    trillian.DuplicatePolicy_DUPLICATES_NOT_ALLOWED,
+	}
+	if err := storage.ValidateTreeForCreation(tree); err != nil {
+		return err
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	atx := &adminTX{tx: tx}
+	defer atx.Close()
+
+	var count int
+	if err := tx.QueryRow(countTreesSQL, treeID).Scan(&count); err != nil {
+		return classifyError(err)
+	}
+	if count > 0 {
+		return storage.Error{ErrType: storage.AlreadyExists, Detail: fmt.Sprintf("tree %d already exists", treeID)}
+	}
+	// A tree created concurrently with the same ID makes the insert fail on the key.
+	if _, err := atx.createTree(ctx, treeID, tree); err != nil {
+		return classifyError(err)
+	}
+	return classifyError(atx.Commit())
+}
+
+func (m *pgLogStorage) SnapshotForTree(ctx context.Context, treeID int64) (storage.ReadOnlyLogTreeTX, error) {
+	tx, err := m.beginInternal(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+	return tx.(storage.ReadOnlyLogTreeTX), err
+}
+
+type logTreeTX struct {
+	treeTX
+	ls              *pgLogStorage
+	root            trillian.SignedLogRoot
+	duplicatePolicy trillian.DuplicatePolicy
+	// identityHashSize is the length of the leaf identity hashes accepted by the
+	// tree, as given by its identity hash algorithm identityHashAlg.
+	identityHashSize int
+	identityHashAlg  spb.DigitallySigned_HashAlgorithm
+}
+
+func (t *logTreeTX) ReadRevision() int64 {
+	return t.root.TreeRevision
+}
+
+func (t *logTreeTX) WriteRevision() int64 {
+	return t.treeTX.writeRevision
+}
+
+func (t *logTreeTX) DequeueLeaves(limit int, cutoffTime time.Time) ([]*trillian.LogLeaf, error) {
+	leaves, _, err := t.dequeueLeaves(selectQueuedLeavesSQL, limit, t.treeID, cutoffTime.UnixNano(), limit)
+	return leaves, err
+}
+
+func (t *logTreeTX) DequeueLeavesUnordered(limit int, cutoffTime time.Time) ([]*trillian.LogLeaf, error) {
+	leaves, _, err := t.dequeueLeaves(selectQueuedLeavesUnorderedSQL, limit, t.treeID, cutoffTime.UnixNano(), limit)
+	return leaves, err
+}
+
+func (t *logTreeTX) DequeueLeavesAfter(limit int, cutoffTime time.Time, token []byte) ([]*trillian.LogLeaf, []byte, error) {
+	var leaves []*trillian.LogLeaf
+	var last storage.QueuePosition
+	var err error
+	if token == nil {
+		leaves, last, err = t.dequeueLeaves(selectQueuedLeavesSQL, limit, t.treeID, cutoffTime.UnixNano(), limit)
+	} else {
+		after, perr := storage.ParseQueueToken(token)
+		if perr != nil {
+			return nil, nil, perr
+		}
+		leaves, last, err = t.dequeueLeaves(selectQueuedLeavesAfterSQL, limit, t.treeID, cutoffTime.UnixNano(), after.QueueTimestampNanos, after.LeafIdentityHash, limit)
+	}
+	if err != nil || len(leaves) < limit {
+		return leaves, nil, err
+	}
+	return leaves, last.Token(), nil
+}
+
+// dequeueLeaves selects and removes up to limit queued leaves using query, which is run
+// with args. It also returns the queue position of the last leaf selected.
+func (t *logTreeTX) dequeueLeaves(query string, limit int, args ...interface{}) ([]*trillian.LogLeaf, storage.QueuePosition, error) {
+	var last storage.QueuePosition
+	stx, err := t.tx.Prepare(query)
+
+	if err != nil {
+		glog.Warningf("Failed to prepare dequeue select: %s", err)
+		return nil, last, err
+	}
+
+	leaves := make([]*trillian.LogLeaf, 0, limit)
+	rows, err := stx.Query(args...)
+
+	if err != nil {
+		glog.Warningf("Failed to select rows for work: %s", err)
+		return nil, last, classifyError(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var leafIDHash []byte
+		var merkleHash []byte
+		var queueTimestamp int64
+
+		err := rows.Scan(&leafIDHash, &merkleHash, &queueTimestamp)
+
+		if err != nil {
+			glog.Warningf("Error scanning work rows: %s", err)
+			return nil, last, err
+		}
+
+		if len(leafIDHash) != t.identityHashSize {
+			return nil, last, errors.New("Dequeued a leaf with incorrect hash size")
+		}
+
+		// Note: the LeafData and ExtraData being nil here is OK as this is only used by the
+		// sequencer. The sequencer only writes to the SequencedLeafData table and the client
+		// supplied data was already written to LeafData as part of queueing the leaf.
+		leaf := &trillian.LogLeaf{
+			LeafIdentityHash:    leafIDHash,
+			MerkleLeafHash:      merkleHash,
+			QueueTimestampNanos: queueTimestamp,
+		}
+		leaves = append(leaves, leaf)
+		last = storage.QueuePosition{QueueTimestampNanos: queueTimestamp, LeafIdentityHash: leafIDHash}
+	}
+
+	if rows.Err() != nil {
+		return nil, last, rows.Err()
+	}
+
+	// The convention is that if leaf processing succeeds (by committing this tx)
+	// then the unsequenced entries for them are removed
+	if len(leaves) > 0 {
+		err = t.removeSequencedLeaves(leaves)
+	}
+
+	if err != nil {
+		return nil, last, err
+	}
+
+	return leaves, last, nil
+}
+
+func (t *logTreeTX) QueueLeaves(leaves []*trillian.LogLeaf, queueTimestamp time.Time) error {
+	// Don't accept batches if any of the leaves are invalid.
+	for _, leaf := range leaves {
+		if len(leaf.LeafIdentityHash) != t.identityHashSize {
+			return fmt.Errorf("queued leaf must have a leaf ID hash of length %d", t.identityHashSize)
+		}
+	}
+
+	// If the log does not allow duplicates we prevent the insert of such a leaf from
+	// succeeding. If duplicates are allowed multiple sequenced leaves will share the same
+	// leaf data in the database.
+	var insertSQL string
+
+	if t.duplicatePolicy == trillian.DuplicatePolicy_DUPLICATES_ALLOWED {
+		insertSQL = insertUnsequencedLeafSQL
+	} else {
+		insertSQL = insertUnsequencedLeafSQLNoDuplicates
+	}
+
+	// Insert in order of the hash values in the leaves.
+	orderedLeaves := make([]*trillian.LogLeaf, len(leaves))
+	copy(orderedLeaves, leaves)
+	sort.Sort(byLeafIdentityHash(orderedLeaves))
+
+	for i, leaf := range orderedLeaves {
+		// Create the unsequenced leaf data entry. When duplicates are allowed ON CONFLICT
+		// DO NOTHING keeps the existing row, otherwise the unique key violation is
+		// reported as a duplicate leaf.
+		_, err := t.tx.Exec(insertSQL, t.treeID, leaf.LeafIdentityHash, leaf.LeafValue, leaf.ExtraData)
+		if err != nil {
+			if isDuplicateKey(err) {
+				return storage.Error{
+					ErrType: storage.DuplicateLeaf,
+					Cause:   err,
+					Detail:  fmt.Sprintf("IdentityHash: %x", leaf.LeafIdentityHash),
+				}
+			}
+			glog.Warningf("Error inserting %d into LeafData: %s", i, err)
+			return classifyError(err)
+		}
+
+		// Create the work queue entry
+		messageID, err := t.messageID(leaf)
+		if err != nil {
+			return err
+		}
+
+		_, err = t.tx.Exec(insertUnsequencedEntrySQL,
+			t.treeID, leaf.LeafIdentityHash, leaf.MerkleLeafHash, messageID, queueTimestamp.UnixNano())
+
+		if err != nil {
+			glog.Warningf("Error inserting into Unsequenced: %s", err)
+			return classifyError(err)
+		}
+	}
+
+	return nil
+}
+
+// messageID returns the id of the Unsequenced entry for leaf.
+func (t *logTreeTX) messageID(leaf *trillian.LogLeaf) ([]byte, error) {
+	// Message ids only need to guard against duplicates for the time that entries are
+	// in the unsequenced queue, which should be short, but we'll still use a strong hash.
+	// TODO(alcutter): get this from somewhere else
+	hasher := sha256.New()
+
+	// We use a fixed zero message id if the log disallows duplicates otherwise a random one.
+	// the fixed id will collide if dups submitted when not allowed so the insert won't succeed
+	// and everything will get rolled back
+	messageIDBytes := make([]byte, 8)
+
+	if t.duplicatePolicy == trillian.DuplicatePolicy_DUPLICATES_ALLOWED {
+		_, err := rand.Read(messageIDBytes)
+
+		if err != nil {
+			glog.Warningf("Failed to get a random message id: %s", err)
+			return nil, err
+		}
+	}
+
+	hasher.Write(messageIDBytes)
+	binary.Write(hasher, binary.LittleEndian, t.treeID)
+	hasher.Write(leaf.LeafIdentityHash)
+	return hasher.Sum(nil), nil
+}
+
+func (t *logTreeTX) QueueLeavesBatch(leaves []*trillian.LogLeaf, queueTimestamp time.Time) ([]error, error) {
+	// Don't accept batches if any of the leaves are invalid.
+	for _, leaf := range leaves {
+		if len(leaf.LeafIdentityHash) != t.identityHashSize {
+			return nil, fmt.Errorf("queued leaf must have a leaf ID hash of length %d", t.identityHashSize)
+		}
+	}
+
+	allowDuplicates := t.duplicatePolicy == trillian.DuplicatePolicy_DUPLICATES_ALLOWED
+	statuses := make([]error, len(leaves))
+	queued := make([]*trillian.LogLeaf, 0, len(leaves))
+	if allowDuplicates {
+		queued = append(queued, leaves...)
+	} else {
+		existing, err := t.existingLeafIdentityHashes(leaves)
+		if err != nil {
+			return nil, err
+		}
+		for i, leaf := range leaves {
+			key := string(leaf.LeafIdentityHash)
+			if existing[key] {
+				statuses[i] = storage.Error{
+					ErrType: storage.DuplicateLeaf,
+					Detail:  fmt.Sprintf("IdentityHash: %x", leaf.LeafIdentityHash),
+				}
+				continue
+			}
+			// Later copies of a leaf in the same batch are duplicates of the first.
+			existing[key] = true
+			queued = append(queued, leaf)
+		}
+	}
+
+	// Insert in order of the hash values in the leaves.
+	sort.Sort(byLeafIdentityHash(queued))
+
+	for start := 0; start < len(queued); start += t.ls.queueBatchSize {
+		end := start + t.ls.queueBatchSize
+		if end > len(queued) {
+			end = len(queued)
+		}
+		if err := t.insertLeafData(queued[start:end], allowDuplicates); err != nil {
+			return nil, err
+		}
+		if err := t.insertUnsequenced(queued[start:end], queueTimestamp); err != nil {
+			return nil, err
+		}
+	}
+
+	return statuses, nil
+}
+
+// existingLeafIdentityHashes returns the set of identity hashes of leaves which are
+// already present in LeafData.
+func (t *logTreeTX) existingLeafIdentityHashes(leaves []*trillian.LogLeaf) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	for start := 0; start < len(leaves); start += t.ls.queueBatchSize {
+		end := start + t.ls.queueBatchSize
+		if end > len(leaves) {
+			end = len(leaves)
+		}
+		chunk := leaves[start:end]
+
+		tmpl, err := t.ls.getExistingLeafIdentityHashesStmt(len(chunk))
+		if err != nil {
+			return nil, err
+		}
+		stx := t.tx.Stmt(tmpl)
+		args := make([]interface{}, 0, len(chunk)+1)
+		for _, leaf := range chunk {
+			args = append(args, leaf.LeafIdentityHash)
+		}
+		args = append(args, t.treeID)
+
+		rows, err := stx.Query(args...)
+		if err != nil {
+			glog.Warningf("Failed to query existing leaves: %s", err)
+			return nil, classifyError(err)
+		}
+		for rows.Next() {
+			var leafIDHash []byte
+			if err := rows.Scan(&leafIDHash); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			existing[string(leafIDHash)] = true
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return existing, nil
+}
+
+// insertLeafData writes the LeafData rows for leaves in a single statement.
+func (t *logTreeTX) insertLeafData(leaves []*trillian.LogLeaf, allowDuplicates bool) error {
+	tmpl, err := t.ls.getInsertLeafDataStmt(len(leaves), allowDuplicates)
+	if err != nil {
+		return err
+	}
+	stx := t.tx.Stmt(tmpl)
+	args := make([]interface{}, 0, 4*len(leaves))
+	for _, leaf := range leaves {
+		args = append(args, t.treeID, leaf.LeafIdentityHash, leaf.LeafValue, leaf.ExtraData)
+	}
+	if _, err := stx.Exec(args...); err != nil {
+		// Another transaction may have queued one of the leaves since we looked.
+		if isDuplicateKey(err) {
+			return storage.Error{
+				ErrType: storage.DuplicateLeaf,
+				Cause:   err,
+				Detail:  "leaf queued concurrently",
+			}
+		}
+		glog.Warningf("Error inserting %d leaves into LeafData: %s", len(leaves), err)
+		return classifyError(err)
+	}
+	return nil
+}
+
+// insertUnsequenced writes the Unsequenced rows for leaves in a single statement.
+func (t *logTreeTX) insertUnsequenced(leaves []*trillian.LogLeaf, queueTimestamp time.Time) error {
+	tmpl, err := t.ls.getInsertUnsequencedStmt(len(leaves))
+	if err != nil {
+		return err
+	}
+	stx := t.tx.Stmt(tmpl)
+	args := make([]interface{}, 0, 5*len(leaves))
+	for _, leaf := range leaves {
+		messageID, err := t.messageID(leaf)
+		if err != nil {
+			return err
+		}
+		args = append(args, t.treeID, leaf.LeafIdentityHash, leaf.MerkleLeafHash, messageID, queueTimestamp.UnixNano())
+	}
+	if _, err := stx.Exec(args...); err != nil {
+		glog.Warningf("Error inserting %d leaves into Unsequenced: %s", len(leaves), err)
+		return classifyError(err)
+	}
+	return nil
+}
+
+func (t *logTreeTX) IdentityHashAlgorithm() spb.DigitallySigned_HashAlgorithm {
+	return t.identityHashAlg
+}
+
+func (t *logTreeTX) QueueLeafWithToken(leaf *trillian.LogLeaf, dedupToken string, queueTimestamp time.Time) (*trillian.LogLeaf, error) {
+	var identityHash, merkleLeafHash []byte
+	err := t.tx.QueryRow(selectDedupTokenSQL, t.treeID, dedupToken).Scan(&identityHash, &merkleLeafHash)
+	if err == nil {
+		return t.dedupedLeaf(identityHash, merkleLeafHash)
+	}
+	if err != sql.ErrNoRows {
+		glog.Warningf("Failed to select dedup token: %s", err)
+		return nil, classifyError(err)
+	}
+
+	if err := t.QueueLeaves([]*trillian.LogLeaf{leaf}, queueTimestamp); err != nil {
+		return nil, err
+	}
+	// The primary key stops a concurrent transaction queueing the leaf with the same token.
+	if _, err := t.tx.Exec(insertDedupTokenSQL, t.treeID, dedupToken, leaf.LeafIdentityHash, leaf.MerkleLeafHash); err != nil {
+		glog.Warningf("Failed to insert dedup token: %s", err)
+		return nil, classifyError(err)
+	}
+	return nil, nil
+}
+
+// dedupedLeaf returns the leaf that was queued with a dedup token, at the first index it
+// was sequenced at, or with a LeafIndex of -1 if it has not been sequenced yet.
+func (t *logTreeTX) dedupedLeaf(identityHash, merkleLeafHash []byte) (*trillian.LogLeaf, error) {
+	sequenced, err := t.GetLeavesByHash([][]byte{merkleLeafHash}, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, leaf := range sequenced {
+		if bytes.Equal(leaf.LeafIdentityHash, identityHash) {
+			return leaf, nil
+		}
+	}
+
+	leaf := &trillian.LogLeaf{LeafIdentityHash: identityHash, MerkleLeafHash: merkleLeafHash, LeafIndex: -1}
+	if err := t.tx.QueryRow(selectLeafDataSQL, t.treeID, identityHash).Scan(&leaf.LeafValue, &leaf.ExtraData); err != nil {
+		glog.Warningf("Failed to select leaf data: %s", err)
+		return nil, classifyError(err)
+	}
+	return leaf, nil
+}
+
+func (t *logTreeTX) DeadLetterLeaves(letters []storage.DeadLetter) error {
+	for _, l := range letters {
+		if len(l.LeafIdentityHash) != t.identityHashSize {
+			return errors.New("Dead-lettered leaf has incorrect hash size")
+		}
+		_, err := t.tx.Exec(insertDeadLetterSQL, t.treeID, l.LeafIdentityHash, l.MerkleLeafHash, l.Reason, l.TimestampNanos)
+		if err != nil {
+			glog.Warningf("Failed to insert dead letter: %s", err)
+			return classifyError(err)
+		}
+	}
+	return nil
+}
+
+func (t *logTreeTX) ListDeadLetters(limit int) ([]storage.DeadLetter, error) {
+	rows, err := t.tx.Query(selectDeadLettersSQL, t.treeID, limit)
+	if err != nil {
+		glog.Warningf("Failed to select dead letters: %s", err)
+		return nil, classifyError(err)
+	}
+	defer rows.Close()
+
+	var letters []storage.DeadLetter
+	for rows.Next() {
+		var l storage.DeadLetter
+		if err := rows.Scan(&l.LeafIdentityHash, &l.MerkleLeafHash, &l.Reason, &l.TimestampNanos); err != nil {
+			glog.Warningf("Error scanning dead letter rows: %s", err)
+			return nil, err
+		}
+		letters = append(letters, l)
+	}
+	return letters, rows.Err()
+}
+
+func (t *logTreeTX) RequeueDeadLetter(leafIdentityHash []byte, queueTimestamp time.Time) error {
+	var merkleLeafHash []byte
+	err := t.tx.QueryRow(deleteDeadLetterSQL, t.treeID, leafIdentityHash).Scan(&merkleLeafHash)
+	if err == sql.ErrNoRows {
+		return storage.Error{
+			ErrType: storage.NotFound,
+			Detail:  fmt.Sprintf("no dead letter with IdentityHash: %x", leafIdentityHash),
+		}
+	}
+	if err != nil {
+		glog.Warningf("Failed to delete dead letter: %s", err)
+		return classifyError(err)
+	}
+
+	leaf := &trillian.LogLeaf{LeafIdentityHash: leafIdentityHash, MerkleLeafHash: merkleLeafHash}
+	messageID, err := t.messageID(leaf)
+	if err != nil {
+		return err
+	}
+	if _, err := t.tx.Exec(insertUnsequencedEntrySQL, t.treeID, leafIdentityHash, merkleLeafHash, messageID, queueTimestamp.UnixNano()); err != nil {
+		glog.Warningf("Error inserting into Unsequenced: %s", err)
+		return classifyError(err)
+	}
+	return nil
+}
+
+func (t *logTreeTX) GetSequencedLeafCount() (int64, error) {
+	var sequencedLeafCount int64
+
+	err := t.tx.QueryRow(selectSequencedLeafCountSQL, t.treeID).Scan(&sequencedLeafCount)
+
+	if err != nil {
+		glog.Warningf("Error getting sequenced leaf count: %s", err)
+	}
+
+	return sequencedLeafCount, err
+}
+
+func (t *logTreeTX) QueuedLeafCount() (int64, error) {
+	var queuedLeafCount int64
+
+	err := t.tx.QueryRow(selectQueuedLeafCountSQL, t.treeID).Scan(&queuedLeafCount)
+
+	if err != nil {
+		glog.Warningf("Error getting queued leaf count: %s", err)
+	}
+
+	return queuedLeafCount, err
+}
+
+func (t *logTreeTX) GetLeavesByIndex(leaves []int64) ([]*trillian.LogLeaf, error) {
+	tmpl, err := t.ls.getLeavesByIndexStmt(len(leaves))
+	if err != nil {
+		return nil, err
+	}
+	stx := t.tx.Stmt(tmpl)
+	var args []interface{}
+	for _, nodeID := range leaves {
+		args = append(args, interface{}(int64(nodeID)))
+	}
+	args = append(args, interface{}(t.treeID))
+	rows, err := stx.Query(args...)
+	if err != nil {
+		glog.Warningf("Failed to get leaves by idx: %s", err)
+		return nil, err
+	}
+
+	ret := make([]*trillian.LogLeaf, 0, len(leaves))
+	defer rows.Close()
+	for rows.Next() {
+		leaf := &trillian.LogLeaf{}
+		if err := rows.Scan(
+			&leaf.MerkleLeafHash,
+			&leaf.LeafIdentityHash,
+			&leaf.LeafValue,
+			&leaf.LeafIndex,
+			&leaf.ExtraData,
+			&leaf.QueueTimestampNanos,
+			&leaf.IntegrateTimestampNanos); err != nil {
+			glog.Warningf("Failed to scan merkle leaves: %s", err)
+			return nil, err
+		}
+		ret = append(ret, leaf)
+	}
+
+	if got, want := len(ret), len(leaves); got != want {
+		return nil, fmt.Errorf("len(ret): %d, want %d", got, want)
+	}
+	return ret, nil
+}
+
+func (t *logTreeTX) GetLeavesByRange(start, count int64) ([]*trillian.LogLeaf, error) {
+	if start < 0 || count < 0 {
+		return nil, fmt.Errorf("invalid range: start %d, count %d", start, count)
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	rows, err := t.tx.Query(selectLeavesByRangeSQL, start, t.treeID, count)
+	if err != nil {
+		glog.Warningf("Failed to get leaves by range: %s", err)
+		return nil, err
+	}
+
+	var ret []*trillian.LogLeaf
+	defer rows.Close()
+	for rows.Next() {
+		leaf := &trillian.LogLeaf{}
+		if err := rows.Scan(
+			&leaf.MerkleLeafHash,
+			&leaf.LeafIdentityHash,
+			&leaf.LeafValue,
+			&leaf.LeafIndex,
+			&leaf.ExtraData,
+			&leaf.QueueTimestampNanos,
+			&leaf.IntegrateTimestampNanos); err != nil {
+			glog.Warningf("Failed to scan merkle leaves: %s", err)
+			return nil, err
+		}
+		ret = append(ret, leaf)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (t *logTreeTX) GetLeavesByHash(leafHashes [][]byte, orderBySequence bool) ([]*trillian.LogLeaf, error) {
+	tmpl, err := t.ls.getLeavesByMerkleHashStmt(len(leafHashes), orderBySequence)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.getLeavesByHashInternal(leafHashes, tmpl, "merkle")
+}
+
+func (t *logTreeTX) GetLeavesByIdentityHash(leafIdentityHashes [][]byte) ([]*trillian.LogLeaf, error) {
+	tmpl, err := t.ls.getLeavesByLeafIdentityHashStmt(len(leafIdentityHashes))
+	if err != nil {
+		return nil, err
+	}
+
+	return t.getLeavesByHashInternal(leafIdentityHashes, tmpl, "identity")
+}
+
+func (t *logTreeTX) LatestSignedLogRoot() (trillian.SignedLogRoot, error) {
+	return t.root, nil
+}
+
+// fetchLatestRoot reads the latest SignedLogRoot from the DB and returns it.
+func (t *logTreeTX) fetchLatestRoot() (trillian.SignedLogRoot, error) {
+	var timestamp, treeSize, treeRevision int64
+	var rootHash, rootSignatureBytes, keyID []byte
+	var rootSignature spb.DigitallySigned
+
+	err := t.tx.QueryRow(
+		selectLatestSignedLogRootSQL, t.treeID).Scan(
+		&timestamp, &treeSize, &rootHash, &treeRevision, &rootSignatureBytes, &keyID)
+
+	// It's possible there are no roots for this tree yet
+	if err == sql.ErrNoRows {
+		return trillian.SignedLogRoot{}, nil
+	}
+	if err != nil {
+		glog.Warningf("Failed to read latest signed root: %v", err)
+		return trillian.SignedLogRoot{}, classifyError(err)
+	}
+
+	err = proto.Unmarshal(rootSignatureBytes, &rootSignature)
+
+	if err != nil {
+		glog.Warningf("Failed to unmarshall root signature: %v", err)
+		return trillian.SignedLogRoot{}, err
+	}
+
+	return trillian.SignedLogRoot{
+		RootHash:       rootHash,
+		TimestampNanos: timestamp,
+		TreeRevision:   treeRevision,
+		Signature:      &rootSignature,
+		LogId:          t.treeID,
+		TreeSize:       treeSize,
+		KeyId:          keyID,
+	}, nil
+}
+
+func (t *logTreeTX) StoreSignedLogRoot(root trillian.SignedLogRoot) error {
+	signatureBytes, err := proto.Marshal(root.Signature)
+
+	if err != nil {
+		glog.Warningf("Failed to marshal root signature: %v %v", root.Signature, err)
+		return err
+	}
+
+	res, err := t.tx.Exec(insertTreeHeadSQL, t.treeID, root.TimestampNanos, root.TreeSize,
+		root.RootHash, root.TreeRevision, signatureBytes, root.KeyId)
+
+	if err != nil {
+		glog.Warningf("Failed to store signed root: %s", err)
+	}
+
+	return classifyError(checkResultOkAndRowCountIs(res, err, 1))
+}
+
+func (t *logTreeTX) UpdateSequencedLeaves(leaves []*trillian.LogLeaf) error {
+	// TODO: In theory we can do this with CASE / WHEN in one SQL statement but it's more fiddly
+	// and can be implemented later if necessary
+	for _, leaf := range leaves {
+		// This should fail on insert but catch it early
+		if len(leaf.LeafIdentityHash) != t.identityHashSize {
+			return errors.New("Sequenced leaf has incorrect hash size")
+		}
+
+		_, err := t.tx.Exec(insertSequencedLeafSQL, t.treeID, leaf.LeafIdentityHash, leaf.MerkleLeafHash,
+			leaf.LeafIndex, leaf.QueueTimestampNanos, leaf.IntegrateTimestampNanos)
+
+		if err != nil {
+			glog.Warningf("Failed to update sequenced leaves: %s", err)
+			// Another transaction has sequenced a leaf at this index since this one read
+			// the tree size, so a retry will assign a different index.
+			if isDuplicateKey(err) {
+				return storage.Error{
+					ErrType: storage.TransientError,
+					Detail:  fmt.Sprintf("a leaf is already sequenced at index %d", leaf.LeafIndex),
+					Cause:   err,
+				}
+			}
+			return classifyError(err)
+		}
+	}
+
+	return nil
+}
+
+// removeSequencedLeaves removes the passed in leaves from the queue. The slice is left
+// in the order it was dequeued in.
+func (t *logTreeTX) removeSequencedLeaves(leaves []*trillian.LogLeaf) error {
+	// Delete in order of the hash values in the leaves.
+	leaves = append([]*trillian.LogLeaf(nil), leaves...)
+	sort.Sort(byLeafIdentityHash(leaves))
+
+	tmpl, err := t.ls.getDeleteUnsequencedStmt(len(leaves))
+	if err != nil {
+		glog.Warningf("Failed to get delete statement for sequenced work: %s", err)
+		return err
+	}
+	stx := t.tx.Stmt(tmpl)
+	var args []interface{}
+	for _, leaf := range leaves {
+		args = append(args, interface{}(leaf.LeafIdentityHash))
+	}
+	args = append(args, interface{}(t.treeID))
+	result, err := stx.Exec(args...)
+
+	if err != nil {
+		// Error is handled by checkResultOkAndRowCountIs() below
+		glog.Warningf("Failed to delete sequenced work: %s", err)
+	}
+
+	err = checkResultOkAndRowCountIs(result, err, int64(len(leaves)))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *logTreeTX) getLeavesByHashInternal(leafHashes [][]byte, tmpl *sql.Stmt, desc string) ([]*trillian.LogLeaf, error) {
+	stx := t.tx.Stmt(tmpl)
+	var args []interface{}
+	for _, hash := range leafHashes {
+		args = append(args, interface{}([]byte(hash)))
+	}
+	args = append(args, interface{}(t.treeID))
+	rows, err := stx.Query(args...)
+	if err != nil {
+		glog.Warningf("Query() %s hash = %v", desc, err)
+		return nil, err
+	}
+
+	// The tree could include duplicates so we don't know how many results will be returned
+	var ret []*trillian.LogLeaf
+
+	defer rows.Close()
+	for rows.Next() {
+		leaf := &trillian.LogLeaf{}
+
+		if err := rows.Scan(&leaf.MerkleLeafHash, &leaf.LeafIdentityHash, &leaf.LeafValue, &leaf.LeafIndex, &leaf.ExtraData, &leaf.QueueTimestampNanos, &leaf.IntegrateTimestampNanos); err != nil {
+			glog.Warningf("LogID: %d Scan() %s = %s", t.treeID, desc, err)
+			return nil, err
+		}
+
+		if got, want := len(leaf.MerkleLeafHash), t.hashSizeBytes; got != want {
+			return nil, fmt.Errorf("LogID: %d Scanned leaf %s does not have hash length %d, got %d", t.treeID, desc, want, got)
+		}
+
+		ret = append(ret, leaf)
+	}
+
+	return ret, nil
+}
+
+// GetActiveLogIDs returns a list of the IDs of all configured logs
+func (t *logTreeTX) GetActiveLogIDs() ([]int64, error) {
+	return getActiveLogIDs(t.tx)
+}
+
+// GetActiveLogIDsWithPendingWork returns a list of the IDs of all configured logs
+// that have queued unsequenced leaves that need to be integrated
+func (t *logTreeTX) GetActiveLogIDsWithPendingWork() ([]int64, error) {
+	return getActiveLogIDsWithPendingWork(t.tx)
+}
+
+type byLeafIdentityHash []*trillian.LogLeaf
+
+func (l byLeafIdentityHash) Len() int {
+	return len(l)
+}
+func (l byLeafIdentityHash) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+func (l byLeafIdentityHash) Less(i, j int) bool {
+	return bytes.Compare(l[i].LeafIdentityHash, l[j].LeafIdentityHash) == -1
+}
@@ -0,0 +1,147 @@
+-- PostgreSQL version of the tree schema. Requires PostgreSQL 9.5 or later.
+--
+-- This mirrors storage/mysql/storage.sql; MySQL ENUM columns are represented
+-- as VARCHAR columns with CHECK constraints.
+
+-- ---------------------------------------------
+-- Tree stuff here
+-- ---------------------------------------------
+
+-- Tree parameters should not be changed after creation. Doing so can
+-- render the data in the tree unusable or inconsistent.
+CREATE TABLE IF NOT EXISTS Trees(
+  TreeId                BIGINT NOT NULL,
+  TreeState             VARCHAR(16) NOT NULL CHECK (TreeState IN ('ACTIVE', 'FROZEN', 'SOFT_DELETED', 'HARD_DELETED')),
+  TreeType              VARCHAR(8) NOT NULL CHECK (TreeType IN ('LOG', 'MAP')),
+  HashStrategy          VARCHAR(32) NOT NULL CHECK (HashStrategy IN ('RFC_6962', 'PLAIN_SHA256', 'RFC_6962_SHA3_256')),
+  HashAlgorithm         VARCHAR(16) NOT NULL CHECK (HashAlgorithm IN ('SHA1', 'SHA256', 'SHA384', 'SHA512', 'SHA3_256')),
+  SignatureAlgorithm    VARCHAR(16) NOT NULL CHECK (SignatureAlgorithm IN ('ECDSA', 'RSA', 'ED25519', 'RSA_PSS')),
+  DuplicatePolicy       VARCHAR(16) NOT NULL CHECK (DuplicatePolicy IN ('NOT_ALLOWED', 'ALLOWED')),
+  IdentityHashAlgorithm VARCHAR(16) NOT NULL DEFAULT 'NONE' CHECK (IdentityHashAlgorithm IN ('NONE', 'SHA1', 'SHA256', 'SHA384', 'SHA512', 'SHA3_256')),
+  DisplayName           VARCHAR(20),
+  Description           VARCHAR(200),
+  CreateTime            TIMESTAMP NOT NULL,
+  UpdateTime            TIMESTAMP NOT NULL,
+  PRIMARY KEY(TreeId)
+);
+
+-- This table contains tree parameters that can be changed at runtime such as for
+-- administrative purposes.
+CREATE TABLE IF NOT EXISTS TreeControl(
+  TreeId                  BIGINT NOT NULL,
+  SigningEnabled          BOOLEAN NOT NULL,
+  SequencingEnabled       BOOLEAN NOT NULL,
+  SequenceIntervalSeconds INTEGER NOT NULL,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId)
+);
+
+CREATE TABLE IF NOT EXISTS Subtree(
+  TreeId               BIGINT NOT NULL,
+  SubtreeId            BYTEA NOT NULL,
+  Nodes                BYTEA NOT NULL,
+  SubtreeRevision      INTEGER NOT NULL,
+  PRIMARY KEY(TreeId, SubtreeId, SubtreeRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- The TreeRevisionIdx is used to enforce that there is only one STH at any
+-- tree revision
+CREATE TABLE IF NOT EXISTS TreeHead(
+  TreeId               BIGINT NOT NULL,
+  TreeHeadTimestamp    BIGINT,
+  TreeSize             BIGINT,
+  RootHash             BYTEA NOT NULL,
+  RootSignature        BYTEA NOT NULL,
+  TreeRevision         BIGINT,
+  KeyId                BYTEA,
+  PRIMARY KEY(TreeId, TreeHeadTimestamp),
+  CONSTRAINT TreeRevisionIdx UNIQUE(TreeId, TreeRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+
+-- ---------------------------------------------
+-- Log specific stuff here
+-- ---------------------------------------------
+
+-- A leaf that has not been sequenced has a row in this table. If duplicate leaves
+-- are allowed they will all reference this row.
+CREATE TABLE IF NOT EXISTS LeafData(
+  TreeId               BIGINT NOT NULL,
+  -- This is a personality specific hash of some subset of the leaf data.
+  -- It's only purpose is to allow Trillian to identify duplicate entries in
+  -- the context of the personality.
+  LeafIdentityHash     BYTEA NOT NULL,
+  -- This is the data stored in the leaf for example in CT it contains a DER encoded
+  -- X.509 certificate but is application dependent
+  LeafValue            BYTEA NOT NULL,
+  -- This is extra data that the application can associate with the leaf should it wish to.
+  -- This data is not included in signing and hashing.
+  ExtraData            BYTEA,
+  PRIMARY KEY(TreeId, LeafIdentityHash),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS LeafHashIdx ON LeafData(LeafIdentityHash);
+
+-- When a leaf is sequenced a row is added to this table. If logs allow duplicates then
+-- multiple rows will exist with different sequence numbers. We need both hashes because
+-- the LeafData table is keyed by the raw data hash.
+CREATE TABLE IF NOT EXISTS SequencedLeafData(
+  TreeId               BIGINT NOT NULL,
+  SequenceNumber       BIGINT NOT NULL CHECK (SequenceNumber >= 0),
+  -- This is a personality specific hash of some subset of the leaf data.
+  LeafIdentityHash     BYTEA NOT NULL,
+  -- This is a MerkleLeafHash as defined by the treehasher that the log uses. For example for
+  -- CT this hash will include the leaf prefix byte as well as the leaf data.
+  MerkleLeafHash       BYTEA NOT NULL,
+  -- The times at which the leaf was queued and integrated into the tree.
+  QueueTimestampNanos      BIGINT NOT NULL DEFAULT 0,
+  IntegrateTimestampNanos  BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY(TreeId, SequenceNumber),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE,
+  FOREIGN KEY(TreeId, LeafIdentityHash) REFERENCES LeafData(TreeId, LeafIdentityHash) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS SequencedLeafMerkleIdx ON SequencedLeafData(TreeId, MerkleLeafHash);
+
+-- Leaves that were dequeued but rejected by the sequencer, for example because they failed
+-- validation. They stay here, with their LeafData, until they are queued again.
+CREATE TABLE IF NOT EXISTS DeadLetter(
+  TreeId                    BIGINT NOT NULL,
+  LeafIdentityHash          BYTEA NOT NULL,
+  MerkleLeafHash            BYTEA NOT NULL,
+  -- Why the sequencer rejected the leaf.
+  Reason                    TEXT NOT NULL,
+  DeadLetterTimestampNanos  BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, LeafIdentityHash),
+  FOREIGN KEY(TreeId, LeafIdentityHash) REFERENCES LeafData(TreeId, LeafIdentityHash) ON DELETE CASCADE
+);
+
+-- Client supplied tokens for leaves queued with QueueLeafWithToken, so that a leaf
+-- submitted more than once with the same token is only queued once.
+CREATE TABLE IF NOT EXISTS DedupToken(
+  TreeId                    BIGINT NOT NULL,
+  Token                     TEXT NOT NULL,
+  LeafIdentityHash          BYTEA NOT NULL,
+  MerkleLeafHash            BYTEA NOT NULL,
+  PRIMARY KEY(TreeId, Token),
+  FOREIGN KEY(TreeId, LeafIdentityHash) REFERENCES LeafData(TreeId, LeafIdentityHash) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS Unsequenced(
+  TreeId               BIGINT NOT NULL,
+  -- This is a personality specific hash of some subset of the leaf data.
+  LeafIdentityHash     BYTEA NOT NULL,
+  -- This is a MerkleLeafHash as defined by the treehasher that the log uses.
+  MerkleLeafHash       BYTEA NOT NULL,
+  -- SHA256("queueId"|TreeId|leafValueHash)
+  -- We want this to be unique per entry per log, but queryable by FEs so that
+  -- we can try to stomp dupe submissions.
+  MessageId            BYTEA NOT NULL,
+  QueueTimestampNanos  BIGINT NOT NULL,
+  PRIMARY KEY (TreeId, LeafIdentityHash, MessageId)
+);
+
+CREATE INDEX IF NOT EXISTS UnsequencedQueueIdx ON Unsequenced(TreeId, QueueTimestampNanos, LeafIdentityHash);
@@ -0,0 +1,54 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// QueuePosition identifies a leaf's place in a tree's queue of unsequenced leaves, which
+// is ordered by queue time and then by LeafIdentityHash.
+type QueuePosition struct {
+	QueueTimestampNanos int64
+	LeafIdentityHash    []byte
+}
+
+// Token encodes p as a token for LeafDequeuer.DequeueLeavesAfter.
+func (p QueuePosition) Token() []byte {
+	token := make([]byte, 8, 8+len(p.LeafIdentityHash))
+	binary.BigEndian.PutUint64(token, uint64(p.QueueTimestampNanos))
+	return append(token, p.LeafIdentityHash...)
+}
+
+// After reports whether a leaf queued at queueTimestampNanos with identityHash comes
+// after p in the queue.
+func (p QueuePosition) After(queueTimestampNanos int64, identityHash []byte) bool {
+	if queueTimestampNanos != p.QueueTimestampNanos {
+		return queueTimestampNanos > p.QueueTimestampNanos
+	}
+	return string(identityHash) > string(p.LeafIdentityHash)
+}
+
+// ParseQueueToken decodes a token made by QueuePosition.Token.
+func ParseQueueToken(token []byte) (QueuePosition, error) {
+	if len(token) < 8 {
+		return QueuePosition{}, fmt.Errorf("queue token is %d bytes, want at least 8", len(token))
+	}
+	return QueuePosition{
+		QueueTimestampNanos: int64(binary.BigEndian.Uint64(token)),
+		LeafIdentityHash:    token[8:],
+	}, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/client/backoff"
+)
+
+// ErrorClassifier reports whether a transaction that failed with err may succeed if it
+// is run again. Storage implementations can provide one that recognizes their own error
+// codes, for example mysql.IsRetriable.
+type ErrorClassifier func(err error) bool
+
+// RetryPolicy controls the retrying of transactions that fail because of transient
+// errors, such as deadlocks between transactions. Each attempt uses a new transaction.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a transaction will be run. If it is less
+	// than two transactions are not retried.
+	MaxAttempts int
+	// Backoff controls the time waited before each retry.
+	Backoff backoff.Backoff
+	// IsRetriable reports whether a transaction that failed with err should be retried.
+	// If nil, IsTransient is used.
+	IsRetriable ErrorClassifier
+}
+
+func (p RetryPolicy) isRetriable(err error) bool {
+	if p.IsRetriable != nil {
+		return p.IsRetriable(err)
+	}
+	return IsTransient(err)
+}
+
+// RunInLogTX runs f in a new transaction for treeID, retrying the whole transaction with a
+// fresh one while it fails with an error the policy considers retriable. f is responsible
+// for committing the transaction, as a failed commit must also cause a retry; the
+// transaction is closed after f returns. If ctx is done while waiting to retry, the
+// context's error is returned. Otherwise the error from the last attempt is returned.
+func RunInLogTX(ctx context.Context, s LogStorage, treeID int64, policy RetryPolicy, f func(tx LogTreeTX) error) error {
+	b := policy.Backoff
+	b.Reset()
+	for attempt := 1; ; attempt++ {
+		err := runLogTX(ctx, s, treeID, f)
+		if err == nil || attempt >= policy.MaxAttempts || !policy.isRetriable(err) {
+			return err
+		}
+
+		var wait time.Duration
+		if b.Min > 0 {
+			wait = b.Duration()
+		}
+		glog.Warningf("%v: retrying transaction in %v after attempt %d failed: %v", treeID, wait, attempt, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// runLogTX makes a single attempt at running f in a new transaction for treeID.
+func runLogTX(ctx context.Context, s LogStorage, treeID int64, f func(tx LogTreeTX) error) error {
+	tx, err := s.BeginForTree(ctx, treeID)
+	if err != nil {
+		glog.Warningf("%v: failed to start tx: %v", treeID, err)
+		return err
+	}
+	defer tx.Close()
+	return f(tx)
+}
+
+// Ping checks that the storage behind checker is accessible, retrying failed checks
+// according to policy. Unlike for transactions, a nil policy.IsRetriable retries every
+// error, as a failure to reach storage is often transient, for example while a database
+// starts up. Each check is made under ctx; if ctx is done while waiting to retry, the
+// error from the last check is returned.
+func Ping(ctx context.Context, checker DatabaseChecker, policy RetryPolicy) error {
+	b := policy.Backoff
+	b.Reset()
+	for attempt := 1; ; attempt++ {
+		err := checker.CheckDatabaseAccessible(ctx)
+		if err == nil || attempt >= policy.MaxAttempts || (policy.IsRetriable != nil && !policy.IsRetriable(err)) {
+			return err
+		}
+
+		var wait time.Duration
+		if b.Min > 0 {
+			wait = b.Duration()
+		}
+		glog.Warningf("Storage check %d failed, retrying in %v: %v", attempt, wait, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian/client/backoff"
+)
+
+func TestRunInLogTXRetriesDeadlock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The first commit fails with a deadlock and the second succeeds.
+	deadlock := Error{ErrType: TransientError, Detail: "Deadlock found when trying to get lock"}
+	failTx := NewMockLogTreeTX(ctrl)
+	failTx.EXPECT().Commit().Return(deadlock)
+	failTx.EXPECT().Close().Return(nil)
+	okTx := NewMockLogTreeTX(ctrl)
+	okTx.EXPECT().Commit().Return(nil)
+	okTx.EXPECT().Close().Return(nil)
+	s := NewMockLogStorage(ctrl)
+	gomock.InOrder(
+		s.EXPECT().BeginForTree(gomock.Any(), int64(6962)).Return(failTx, nil),
+		s.EXPECT().BeginForTree(gomock.Any(), int64(6962)).Return(okTx, nil),
+	)
+
+	calls := 0
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: backoff.Backoff{Min: time.Millisecond, Max: time.Millisecond, Factor: 1}}
+	err := RunInLogTX(context.Background(), s, 6962, policy, func(tx LogTreeTX) error {
+		calls++
+		return tx.Commit()
+	})
+	if err != nil {
+		t.Errorf("RunInLogTX()=%v, want nil", err)
+	}
+	if calls != 2 {
+		t.Errorf("RunInLogTX() ran f %d times, want 2", calls)
+	}
+}
+
+func TestRunInLogTXRetryLimits(t *testing.T) {
+	transient := Error{ErrType: TransientError, Detail: "deadlock"}
+	other := errors.New("not found")
+	for _, test := range []struct {
+		desc     string
+		err      error
+		policy   RetryPolicy
+		attempts int
+	}{
+		{desc: "no policy", err: transient, attempts: 1},
+		{desc: "not transient", err: other, policy: RetryPolicy{MaxAttempts: 3}, attempts: 1},
+		{desc: "attempts exhausted", err: transient, policy: RetryPolicy{MaxAttempts: 3}, attempts: 3},
+		{
+			desc:     "custom classifier",
+			err:      other,
+			policy:   RetryPolicy{MaxAttempts: 2, IsRetriable: func(err error) bool { return err == other }},
+			attempts: 2,
+		},
+		{
+			desc:     "custom classifier rejects transient",
+			err:      transient,
+			policy:   RetryPolicy{MaxAttempts: 2, IsRetriable: func(error) bool { return false }},
+			attempts: 1,
+		},
+	} {
+		ctrl := gomock.NewController(t)
+
+		tx := NewMockLogTreeTX(ctrl)
+		tx.EXPECT().Close().Times(test.attempts).Return(nil)
+		s := NewMockLogStorage(ctrl)
+		s.EXPECT().BeginForTree(gomock.Any(), int64(6962)).Times(test.attempts).Return(tx, nil)
+
+		calls := 0
+		err := RunInLogTX(context.Background(), s, 6962, test.policy, func(LogTreeTX) error {
+			calls++
+			return test.err
+		})
+		if err != test.err {
+			t.Errorf("%s: RunInLogTX()=%v, want %v", test.desc, err, test.err)
+		}
+		if calls != test.attempts {
+			t.Errorf("%s: RunInLogTX() ran f %d times, want %d", test.desc, calls, test.attempts)
+		}
+		ctrl.Finish()
+	}
+}
+
+func TestRunInLogTXBeginError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	transient := Error{ErrType: TransientError, Detail: "lock wait timeout"}
+	s := NewMockLogStorage(ctrl)
+	s.EXPECT().BeginForTree(gomock.Any(), int64(6962)).Times(2).Return(nil, transient)
+
+	err := RunInLogTX(context.Background(), s, 6962, RetryPolicy{MaxAttempts: 2}, func(LogTreeTX) error {
+		t.Error("RunInLogTX() ran f without a transaction")
+		return nil
+	})
+	if err != transient {
+		t.Errorf("RunInLogTX()=%v, want %v", err, transient)
+	}
+}
+
+func TestRunInLogTXContextDone(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	transient := Error{ErrType: TransientError, Detail: "deadlock"}
+	tx := NewMockLogTreeTX(ctrl)
+	tx.EXPECT().Close().Return(nil)
+	s := NewMockLogStorage(ctrl)
+	s.EXPECT().BeginForTree(gomock.Any(), int64(6962)).Return(tx, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: backoff.Backoff{Min: time.Hour, Max: time.Hour, Factor: 1}}
+	err := RunInLogTX(ctx, s, 6962, policy, func(LogTreeTX) error {
+		cancel()
+		return transient
+	})
+	if err != context.Canceled {
+		t.Errorf("RunInLogTX()=%v, want %v", err, context.Canceled)
+	}
+}
+
+// countingChecker is a DatabaseChecker that fails the first failures checks.
+type countingChecker struct {
+	failures int
+	calls    int
+}
+
+func (c *countingChecker) CheckDatabaseAccessible(ctx context.Context) error {
+	c.calls++
+	if c.calls <= c.failures {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestPing(t *testing.T) {
+	for _, test := range []struct {
+		desc      string
+		failures  int
+		policy    RetryPolicy
+		wantErr   bool
+		wantCalls int
+	}{
+		{desc: "ok", policy: RetryPolicy{MaxAttempts: 3}, wantCalls: 1},
+		{desc: "recovers", failures: 2, policy: RetryPolicy{MaxAttempts: 3}, wantCalls: 3},
+		{desc: "out of attempts", failures: 3, policy: RetryPolicy{MaxAttempts: 3}, wantErr: true, wantCalls: 3},
+		{desc: "no retries", failures: 1, wantErr: true, wantCalls: 1},
+		{
+			desc:      "not retriable",
+			failures:  1,
+			policy:    RetryPolicy{MaxAttempts: 3, IsRetriable: func(error) bool { return false }},
+			wantErr:   true,
+			wantCalls: 1,
+		},
+	} {
+		c := &countingChecker{failures: test.failures}
+		err := Ping(context.Background(), c, test.policy)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%s: Ping()=%v, want err? %v", test.desc, err, test.wantErr)
+		}
+		if c.calls != test.wantCalls {
+			t.Errorf("%s: Ping() made %d checks, want %d", test.desc, c.calls, test.wantCalls)
+		}
+	}
+}
+
+func TestPingContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c := &countingChecker{failures: 10}
+	policy := RetryPolicy{MaxAttempts: 10, Backoff: backoff.Backoff{Min: time.Hour, Max: time.Hour, Factor: 1}}
+	if err := Ping(ctx, c, policy); err == nil || err == context.Canceled {
+		t.Errorf("Ping(cancelled)=%v, want the check's error", err)
+	}
+	if c.calls != 1 {
+		t.Errorf("Ping(cancelled) made %d checks, want 1", c.calls)
+	}
+}
@@ -74,6 +74,14 @@ func (tester *AdminStorageTester) TestCreateTree(t *testing.T) {
 
 	validTree1 := *LogTree
 	validTree2 := *MapTree
+	validTree3 := *LogTree
+	validTree3.IdentityHashAlgorithm = spb.DigitallySigned_SHA1
+	validTree4 := *LogTree
+	validTree4.HashAlgorithm = spb.DigitallySigned_SHA512
+	validTree4.SignatureAlgorithm = spb.DigitallySigned_ED25519
+	validTree5 := *LogTree
+	validTree5.HashAlgorithm = spb.DigitallySigned_SHA3_256
+	validTree5.SignatureAlgorithm = spb.DigitallySigned_RSA_PSS
 
 	tests := []struct {
 		tree    *trillian.Tree
@@ -82,6 +90,9 @@ func (tester *AdminStorageTester) TestCreateTree(t *testing.T) {
 		{tree: &invalidTree, wantErr: true},
 		{tree: &validTree1},
 		{tree: &validTree2},
+		{tree: &validTree3},
+		{tree: &validTree4},
+		{tree: &validTree5},
 	}
 
 	ctx := context.Background()
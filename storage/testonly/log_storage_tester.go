@@ -0,0 +1,1249 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testonly
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	spb "github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/storage"
+)
+
+// LogStorageTester runs a suite of tests against LogStorage implementations.
+type LogStorageTester struct {
+	// NewLogStorage returns a LogStorage instance pointing to clean, empty storage.
+	NewLogStorage func() storage.LogStorage
+	// CreateLog creates a log with the settings of tree in s and returns its ID.
+	CreateLog func(s storage.LogStorage, tree *trillian.Tree) (int64, error)
+}
+
+// RunAllTests runs all LogStorage tests.
+func (tester *LogStorageTester) RunAllTests(t *testing.T) {
+	t.Run("TestQueueAndDequeueLeaves", tester.TestQueueAndDequeueLeaves)
+	t.Run("TestDequeueLeavesOrder", tester.TestDequeueLeavesOrder)
+	t.Run("TestDequeueLeavesUnordered", tester.TestDequeueLeavesUnordered)
+	t.Run("TestDequeueLeavesAfter", tester.TestDequeueLeavesAfter)
+	t.Run("TestDequeueLeavesRollback", tester.TestDequeueLeavesRollback)
+	t.Run("TestQueuedLeafCount", tester.TestQueuedLeafCount)
+	t.Run("TestDeadLetters", tester.TestDeadLetters)
+	t.Run("TestSequencedLeaves", tester.TestSequencedLeaves)
+	t.Run("TestLeavesByRange", tester.TestLeavesByRange)
+	t.Run("TestSequenceConflict", tester.TestSequenceConflict)
+	t.Run("TestSignedLogRoots", tester.TestSignedLogRoots)
+	t.Run("TestMerkleNodes", tester.TestMerkleNodes)
+	t.Run("TestGetActiveLogIDs", tester.TestGetActiveLogIDs)
+	t.Run("TestLogTXClose", tester.TestLogTXClose)
+	t.Run("TestRollbackDiscardsChanges", tester.TestRollbackDiscardsChanges)
+	t.Run("TestBeginForUnknownTree", tester.TestBeginForUnknownTree)
+	t.Run("TestCreateTree", tester.TestCreateTree)
+	t.Run("TestSnapshotForTree", tester.TestSnapshotForTree)
+	t.Run("TestQueueLeavesInvalidHash", tester.TestQueueLeavesInvalidHash)
+	t.Run("TestQueueDuplicateLeaves", tester.TestQueueDuplicateLeaves)
+	t.Run("TestQueueLeavesBatch", tester.TestQueueLeavesBatch)
+	t.Run("TestQueueLeafWithToken", tester.TestQueueLeafWithToken)
+	t.Run("TestDuplicateSignedLogRoot", tester.TestDuplicateSignedLogRoot)
+}
+
+// newLog returns a fresh LogStorage and the ID of a new log created in it.
+func (tester *LogStorageTester) newLog(t *testing.T) (storage.LogStorage, int64) {
+	return tester.newLogWithTree(t, LogTree)
+}
+
+// newLogWithTree returns a fresh LogStorage and the ID of a new log with the settings of
+// tree created in it.
+func (tester *LogStorageTester) newLogWithTree(t *testing.T, tree *trillian.Tree) (storage.LogStorage, int64) {
+	s := tester.NewLogStorage()
+	logID, err := tester.CreateLog(s, tree)
+	if err != nil {
+		t.Fatalf("CreateLog() = (_, %v), want = (_, nil)", err)
+	}
+	return s, logID
+}
+
+// runLogTX runs f in a new transaction for logID, which is committed if f succeeds.
+func runLogTX(t *testing.T, s storage.LogStorage, logID int64, f func(tx storage.LogTreeTX) error) {
+	tx, err := s.BeginForTree(context.Background(), logID)
+	if err != nil {
+		t.Fatalf("BeginForTree() = (_, %v), want = (_, nil)", err)
+	}
+	defer tx.Close()
+	if err := f(tx); err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() = %v, want = nil", err)
+	}
+}
+
+// testLeaves returns n distinct leaves with valid hashes.
+func testLeaves(n int) []*trillian.LogLeaf {
+	leaves := make([]*trillian.LogLeaf, 0, n)
+	for i := 0; i < n; i++ {
+		value := []byte(fmt.Sprintf("leaf %d", i))
+		identityHash := sha256.Sum256(value)
+		merkleHash := sha256.Sum256(append([]byte{0}, value...))
+		leaves = append(leaves, &trillian.LogLeaf{
+			LeafIdentityHash: identityHash[:],
+			MerkleLeafHash:   merkleHash[:],
+			LeafValue:        value,
+			ExtraData:        []byte(fmt.Sprintf("extra %d", i)),
+		})
+	}
+	return leaves
+}
+
+// identityHashes returns the set of identity hashes of leaves.
+func identityHashes(leaves []*trillian.LogLeaf) map[string]bool {
+	hashes := make(map[string]bool)
+	for _, leaf := range leaves {
+		hashes[string(leaf.LeafIdentityHash)] = true
+	}
+	return hashes
+}
+
+// TestQueueAndDequeueLeaves tests that queued leaves are dequeued in queue time order,
+// respecting the limit and cutoff time.
+func (tester *LogStorageTester) TestQueueAndDequeueLeaves(t *testing.T) {
+	s, logID := tester.newLog(t)
+	leaves := testLeaves(4)
+	start := time.Unix(1000, 0)
+
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		// Queue in reverse, so the queue times are the opposite of slice order.
+		for i := len(leaves) - 1; i >= 0; i-- {
+			if err := tx.QueueLeaves(leaves[i:i+1], start.Add(time.Duration(len(leaves)-i)*time.Second)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	tests := []struct {
+		limit  int
+		cutoff time.Time
+		want   []*trillian.LogLeaf
+	}{
+		{limit: 10, cutoff: start, want: nil},
+		{limit: 2, cutoff: start.Add(time.Hour), want: []*trillian.LogLeaf{leaves[3], leaves[2]}},
+		{limit: 10, cutoff: start.Add(3 * time.Second), want: []*trillian.LogLeaf{leaves[1]}},
+		{limit: 10, cutoff: start.Add(time.Hour), want: []*trillian.LogLeaf{leaves[0]}},
+		{limit: 10, cutoff: start.Add(time.Hour), want: nil},
+	}
+	for i, test := range tests {
+		runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+			got, err := tx.DequeueLeaves(test.limit, test.cutoff)
+			if err != nil {
+				return err
+			}
+			if len(got) != len(test.want) {
+				t.Errorf("%v: DequeueLeaves() returned %d leaves, want %d", i, len(got), len(test.want))
+				return nil
+			}
+			for j := range got {
+				if !bytes.Equal(got[j].LeafIdentityHash, test.want[j].LeafIdentityHash) || !bytes.Equal(got[j].MerkleLeafHash, test.want[j].MerkleLeafHash) {
+					t.Errorf("%v: DequeueLeaves()[%d] = %x, want %x", i, j, got[j].LeafIdentityHash, test.want[j].LeafIdentityHash)
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// TestDequeueLeavesOrder tests that DequeueLeaves returns leaves in queue time order
+// rather than in the order of their identity hashes, which only break ties between
+// leaves queued at the same time.
+func (tester *LogStorageTester) TestDequeueLeavesOrder(t *testing.T) {
+	s, logID := tester.newLog(t)
+	leaves := testLeaves(5)
+	start := time.Unix(1000, 0)
+
+	// The first leaves are queued one at a time, in descending order of identity hash,
+	// and the others together.
+	separate := append([]*trillian.LogLeaf(nil), leaves[:3]...)
+	sort.Slice(separate, func(i, j int) bool {
+		return bytes.Compare(separate[i].LeafIdentityHash, separate[j].LeafIdentityHash) > 0
+	})
+	together := append([]*trillian.LogLeaf(nil), leaves[3:]...)
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		for i, leaf := range separate {
+			if err := tx.QueueLeaves([]*trillian.LogLeaf{leaf}, start.Add(time.Duration(i)*time.Second)); err != nil {
+				return err
+			}
+		}
+		return tx.QueueLeaves(together, start.Add(time.Duration(len(separate))*time.Second))
+	})
+	sort.Slice(together, func(i, j int) bool {
+		return bytes.Compare(together[i].LeafIdentityHash, together[j].LeafIdentityHash) < 0
+	})
+	want := append(separate, together...)
+
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		got, err := tx.DequeueLeaves(10, start.Add(time.Hour))
+		if err != nil {
+			return err
+		}
+		if len(got) != len(want) {
+			t.Fatalf("DequeueLeaves() returned %d leaves, want %d", len(got), len(want))
+		}
+		for i := range got {
+			if !bytes.Equal(got[i].LeafIdentityHash, want[i].LeafIdentityHash) {
+				t.Errorf("DequeueLeaves()[%d] = %x, want %x", i, got[i].LeafIdentityHash, want[i].LeafIdentityHash)
+			}
+		}
+		return nil
+	})
+}
+
+// TestDequeueLeavesUnordered tests that unordered dequeues respect the limit and cutoff
+// time and return each queued leaf exactly once.
+func (tester *LogStorageTester) TestDequeueLeavesUnordered(t *testing.T) {
+	s, logID := tester.newLog(t)
+	leaves := testLeaves(5)
+	start := time.Unix(1000, 0)
+
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		if err := tx.QueueLeaves(leaves[:4], start); err != nil {
+			return err
+		}
+		return tx.QueueLeaves(leaves[4:], start.Add(time.Hour))
+	})
+
+	got := make(map[string]bool)
+	for i, test := range []struct {
+		limit  int
+		cutoff time.Time
+		want   int
+	}{
+		{limit: 3, cutoff: start, want: 3},
+		{limit: 3, cutoff: start, want: 1},
+		{limit: 3, cutoff: start, want: 0},
+		{limit: 3, cutoff: start.Add(time.Hour), want: 1},
+	} {
+		runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+			dequeued, err := tx.DequeueLeavesUnordered(test.limit, test.cutoff)
+			if err != nil {
+				return err
+			}
+			if len(dequeued) != test.want {
+				t.Errorf("%v: DequeueLeavesUnordered() returned %d leaves, want %d", i, len(dequeued), test.want)
+			}
+			for _, leaf := range dequeued {
+				if got[string(leaf.LeafIdentityHash)] {
+					t.Errorf("%v: DequeueLeavesUnordered() returned %x again", i, leaf.LeafIdentityHash)
+				}
+				got[string(leaf.LeafIdentityHash)] = true
+			}
+			return nil
+		})
+	}
+	if want := identityHashes(leaves); !reflect.DeepEqual(got, want) {
+		t.Errorf("DequeueLeavesUnordered() returned leaves %v, want %v", got, want)
+	}
+}
+
+// TestDequeueLeavesAfter tests that paging through the queue with tokens returns every
+// leaf once, in queue order, and that a nil token starts from the beginning again.
+func (tester *LogStorageTester) TestDequeueLeavesAfter(t *testing.T) {
+	s, logID := tester.newLog(t)
+	leaves := testLeaves(7)
+	start := time.Unix(1000, 0)
+
+	// The first four leaves are queued a second apart and the next two together, so
+	// those two are ordered by identity hash.
+	want := append([]*trillian.LogLeaf{}, leaves[:6]...)
+	if bytes.Compare(want[4].LeafIdentityHash, want[5].LeafIdentityHash) > 0 {
+		want[4], want[5] = want[5], want[4]
+	}
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		for i := 0; i < 4; i++ {
+			if err := tx.QueueLeaves(leaves[i:i+1], start.Add(time.Duration(i)*time.Second)); err != nil {
+				return err
+			}
+		}
+		return tx.QueueLeaves(leaves[4:6], start.Add(4*time.Second))
+	})
+
+	var got []*trillian.LogLeaf
+	var token []byte
+	for i, wantNil := range []bool{false, false, false, true} {
+		runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+			page, next, err := tx.DequeueLeavesAfter(2, start.Add(time.Hour), token)
+			if err != nil {
+				return err
+			}
+			if gotNil := next == nil; gotNil != wantNil {
+				t.Errorf("%v: DequeueLeavesAfter() returned token %x, want nil? %v", i, next, wantNil)
+			}
+			got = append(got, page...)
+			token = next
+			if i == 1 {
+				// A leaf queued before the position of the token is not returned until
+				// the queue is read from the start again.
+				return tx.QueueLeaves(leaves[6:], start)
+			}
+			return nil
+		})
+	}
+	if len(got) != len(want) {
+		t.Fatalf("DequeueLeavesAfter() pages held %d leaves, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if !bytes.Equal(got[i].LeafIdentityHash, want[i].LeafIdentityHash) || !bytes.Equal(got[i].MerkleLeafHash, want[i].MerkleLeafHash) {
+			t.Errorf("DequeueLeavesAfter() leaf %d = %x, want %x", i, got[i].LeafIdentityHash, want[i].LeafIdentityHash)
+		}
+	}
+
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		page, next, err := tx.DequeueLeavesAfter(2, start.Add(time.Hour), nil)
+		if err != nil {
+			return err
+		}
+		if len(page) != 1 || !bytes.Equal(page[0].LeafIdentityHash, leaves[6].LeafIdentityHash) || next != nil {
+			t.Errorf("DequeueLeavesAfter(nil) = (%v, %x), want the leaf queued behind the token and a nil token", page, next)
+		}
+		if _, _, err := tx.DequeueLeavesAfter(2, start.Add(time.Hour), []byte{1}); err == nil {
+			t.Error("DequeueLeavesAfter(short token) = (_, _, nil), want error")
+		}
+		return nil
+	})
+}
+
+// TestDeadLetters tests that dequeued leaves can be dead-lettered with a reason, listed in
+// the order they were recorded and queued again.
+func (tester *LogStorageTester) TestDeadLetters(t *testing.T) {
+	s, logID := tester.newLog(t)
+	leaves := testLeaves(3)
+	start := time.Unix(1000, 0)
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		return tx.QueueLeaves(leaves, start)
+	})
+
+	want := make([]storage.DeadLetter, 0, len(leaves))
+	for i := len(leaves) - 1; i >= 0; i-- {
+		want = append(want, storage.DeadLetter{
+			LeafIdentityHash: leaves[i].LeafIdentityHash,
+			MerkleLeafHash:   leaves[i].MerkleLeafHash,
+			Reason:           fmt.Sprintf("bad leaf %d", i),
+			TimestampNanos:   start.Add(time.Duration(len(leaves)-i) * time.Second).UnixNano(),
+		})
+	}
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		if _, err := tx.DequeueLeaves(len(leaves), start); err != nil {
+			return err
+		}
+		// Dead-lettering a leaf again replaces its entry.
+		first := want[0]
+		first.Reason = "replaced"
+		if err := tx.DeadLetterLeaves([]storage.DeadLetter{first}); err != nil {
+			return err
+		}
+		return tx.DeadLetterLeaves(want)
+	})
+
+	got, err := storage.ListDeadLetters(context.Background(), s, logID, 2)
+	if err != nil {
+		t.Fatalf("ListDeadLetters() = (_, %v), want = (_, nil)", err)
+	}
+	if !reflect.DeepEqual(got, want[:2]) {
+		t.Errorf("ListDeadLetters(2) = %+v, want = %+v", got, want[:2])
+	}
+
+	requeued := want[1].LeafIdentityHash
+	if err := storage.RequeueDeadLetter(context.Background(), s, logID, requeued, start.Add(time.Hour)); err != nil {
+		t.Fatalf("RequeueDeadLetter() = %v, want = nil", err)
+	}
+	if err := storage.RequeueDeadLetter(context.Background(), s, logID, requeued, start.Add(time.Hour)); err == nil {
+		t.Error("RequeueDeadLetter(requeued leaf) = nil, want error")
+	} else if serr, ok := err.(storage.Error); !ok || serr.ErrType != storage.NotFound {
+		t.Errorf("RequeueDeadLetter(requeued leaf) = %v, want an Error of type NotFound", err)
+	}
+
+	got, err = storage.ListDeadLetters(context.Background(), s, logID, 10)
+	if err != nil {
+		t.Fatalf("ListDeadLetters() = (_, %v), want = (_, nil)", err)
+	}
+	if wantLeft := []storage.DeadLetter{want[0], want[2]}; !reflect.DeepEqual(got, wantLeft) {
+		t.Errorf("ListDeadLetters() after requeue = %+v, want = %+v", got, wantLeft)
+	}
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		dequeued, err := tx.DequeueLeaves(10, start.Add(time.Hour))
+		if err != nil {
+			return err
+		}
+		if len(dequeued) != 1 || !bytes.Equal(dequeued[0].LeafIdentityHash, requeued) || !bytes.Equal(dequeued[0].MerkleLeafHash, want[1].MerkleLeafHash) {
+			t.Errorf("DequeueLeaves() after requeue = %v, want the requeued leaf", dequeued)
+		}
+		return nil
+	})
+}
+
+// TestQueuedLeafCount tests that the count of queued leaves includes those inside the
+// guard window and goes down as batches of leaves are sequenced.
+func (tester *LogStorageTester) TestQueuedLeafCount(t *testing.T) {
+	s, logID := tester.newLog(t)
+	leaves := testLeaves(5)
+	start := time.Unix(1000, 0)
+
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		for i, leaf := range leaves {
+			if err := tx.QueueLeaves([]*trillian.LogLeaf{leaf}, start.Add(time.Duration(i)*time.Second)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	var sequenced int64
+	for _, test := range []struct {
+		cutoff time.Time
+		want   int64
+	}{
+		{cutoff: start.Add(-time.Second), want: 5},
+		{cutoff: start.Add(time.Hour), want: 3},
+		{cutoff: start.Add(3 * time.Second), want: 1},
+		{cutoff: start.Add(time.Hour), want: 0},
+	} {
+		runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+			dequeued, err := tx.DequeueLeaves(2, test.cutoff)
+			if err != nil {
+				return err
+			}
+			for _, leaf := range dequeued {
+				leaf.LeafIndex = sequenced
+				sequenced++
+			}
+			if err := tx.UpdateSequencedLeaves(dequeued); err != nil {
+				return err
+			}
+			count, err := tx.QueuedLeafCount()
+			if err != nil {
+				return err
+			}
+			if got, want := count, test.want; got != want {
+				t.Errorf("QueuedLeafCount() after dequeueing to %v = %v, want = %v", test.cutoff, got, want)
+			}
+			return nil
+		})
+	}
+
+	// The count seen by a new transaction reflects the committed batches.
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		count, err := tx.QueuedLeafCount()
+		if err != nil {
+			return err
+		}
+		if count != 0 {
+			t.Errorf("QueuedLeafCount() = %v, want = 0", count)
+		}
+		return nil
+	})
+}
+
+// TestDequeueLeavesRollback tests that leaves dequeued in a transaction that is rolled
+// back are available to be dequeued again.
+func (tester *LogStorageTester) TestDequeueLeavesRollback(t *testing.T) {
+	ctx := context.Background()
+	s, logID := tester.newLog(t)
+	leaves := testLeaves(3)
+	now := time.Unix(1000, 0)
+
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		return tx.QueueLeaves(leaves, now)
+	})
+
+	tx, err := s.BeginForTree(ctx, logID)
+	if err != nil {
+		t.Fatalf("BeginForTree() = (_, %v), want = (_, nil)", err)
+	}
+	if got, err := tx.DequeueLeaves(10, now); err != nil || len(got) != len(leaves) {
+		t.Fatalf("DequeueLeaves() = (%d leaves, %v), want = (%d leaves, nil)", len(got), err, len(leaves))
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback() = %v, want = nil", err)
+	}
+
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		got, err := tx.DequeueLeaves(10, now)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(identityHashes(got), identityHashes(leaves)) {
+			t.Errorf("DequeueLeaves() after rollback returned %d leaves, want all %d", len(got), len(leaves))
+		}
+		return nil
+	})
+}
+
+// TestSequencedLeaves tests that leaves are readable by index and hash once sequenced.
+func (tester *LogStorageTester) TestSequencedLeaves(t *testing.T) {
+	s, logID := tester.newLog(t)
+	leaves := testLeaves(3)
+	now := time.Unix(1000, 0)
+
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		if err := tx.QueueLeaves(leaves, now); err != nil {
+			return err
+		}
+		dequeued, err := tx.DequeueLeaves(10, now)
+		if err != nil {
+			return err
+		}
+		// Dequeued leaves may be in any order for the same queue time.
+		for _, leaf := range dequeued {
+			for i, l := range leaves {
+				if bytes.Equal(l.LeafIdentityHash, leaf.LeafIdentityHash) {
+					leaf.LeafIndex = int64(i)
+				}
+			}
+			if got, want := leaf.QueueTimestampNanos, now.UnixNano(); got != want {
+				t.Errorf("DequeueLeaves().QueueTimestampNanos = %v, want = %v", got, want)
+			}
+			leaf.IntegrateTimestampNanos = now.Add(time.Second).UnixNano()
+		}
+		return tx.UpdateSequencedLeaves(dequeued)
+	})
+
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		count, err := tx.GetSequencedLeafCount()
+		if err != nil {
+			return err
+		}
+		if got, want := count, int64(len(leaves)); got != want {
+			t.Errorf("GetSequencedLeafCount() = %v, want = %v", got, want)
+		}
+
+		byIndex, err := tx.GetLeavesByIndex([]int64{2})
+		if err != nil {
+			return err
+		}
+		if len(byIndex) != 1 {
+			t.Fatalf("GetLeavesByIndex() returned %d leaves, want 1", len(byIndex))
+		}
+		want := *leaves[2]
+		want.LeafIndex = 2
+		want.QueueTimestampNanos = now.UnixNano()
+		want.IntegrateTimestampNanos = now.Add(time.Second).UnixNano()
+		if !proto.Equal(byIndex[0], &want) {
+			t.Errorf("GetLeavesByIndex() = %v, want = %v", byIndex[0], &want)
+		}
+		if _, err := tx.GetLeavesByIndex([]int64{7}); err == nil {
+			t.Errorf("GetLeavesByIndex(missing) = (_, nil), want = (_, err)")
+		}
+
+		byHash, err := tx.GetLeavesByHash([][]byte{leaves[1].MerkleLeafHash, leaves[0].MerkleLeafHash}, true)
+		if err != nil {
+			return err
+		}
+		if len(byHash) != 2 || byHash[0].LeafIndex != 0 || byHash[1].LeafIndex != 1 {
+			t.Errorf("GetLeavesByHash() = %v, want leaves 0 and 1 in order", byHash)
+		}
+
+		byIdentityHash, err := tx.GetLeavesByIdentityHash([][]byte{leaves[2].LeafIdentityHash})
+		if err != nil {
+			return err
+		}
+		if len(byIdentityHash) != 1 || !proto.Equal(byIdentityHash[0], &want) {
+			t.Errorf("GetLeavesByIdentityHash() = %v, want = [%v]", byIdentityHash, &want)
+		}
+		return nil
+	})
+}
+
+// TestLeavesByRange tests that a range of sequenced leaves is read in index order, and
+// ends at the last sequenced leaf.
+func (tester *LogStorageTester) TestLeavesByRange(t *testing.T) {
+	s, logID := tester.newLog(t)
+	leaves := testLeaves(5)
+	now := time.Unix(1000, 0)
+
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		got, err := tx.GetLeavesByRange(0, 10)
+		if err != nil {
+			return err
+		}
+		if len(got) != 0 {
+			t.Errorf("GetLeavesByRange(0, 10) on empty tree returned %d leaves, want 0", len(got))
+		}
+		if err := tx.QueueLeaves(leaves, now); err != nil {
+			return err
+		}
+		dequeued, err := tx.DequeueLeaves(10, now)
+		if err != nil {
+			return err
+		}
+		for _, leaf := range dequeued {
+			for i, l := range leaves {
+				if bytes.Equal(l.LeafIdentityHash, leaf.LeafIdentityHash) {
+					leaf.LeafIndex = int64(i)
+				}
+			}
+		}
+		return tx.UpdateSequencedLeaves(dequeued)
+	})
+
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		for _, test := range []struct {
+			start, count int64
+			want         []int64
+		}{
+			{start: 0, count: 5, want: []int64{0, 1, 2, 3, 4}},
+			{start: 1, count: 3, want: []int64{1, 2, 3}},
+			{start: 3, count: 10, want: []int64{3, 4}},
+			{start: 2, count: 0},
+			{start: 5, count: 2},
+		} {
+			got, err := tx.GetLeavesByRange(test.start, test.count)
+			if err != nil {
+				return err
+			}
+			var indices []int64
+			for _, leaf := range got {
+				indices = append(indices, leaf.LeafIndex)
+				if want := leaves[leaf.LeafIndex].LeafValue; !bytes.Equal(leaf.LeafValue, want) {
+					t.Errorf("GetLeavesByRange(%d, %d) leaf %d has value %q, want %q", test.start, test.count, leaf.LeafIndex, leaf.LeafValue, want)
+				}
+			}
+			if !reflect.DeepEqual(indices, test.want) {
+				t.Errorf("GetLeavesByRange(%d, %d) returned leaves %v, want %v", test.start, test.count, indices, test.want)
+			}
+		}
+		if _, err := tx.GetLeavesByRange(-1, 2); err == nil {
+			t.Errorf("GetLeavesByRange(-1, 2) = (_, nil), want = (_, err)")
+		}
+		return nil
+	})
+}
+
+// TestSequenceConflict tests that a leaf cannot be sequenced at an index that another
+// transaction has sequenced a leaf at, whether or not that transaction had committed when
+// this one began, and that the conflict is reported as a transient error.
+func (tester *LogStorageTester) TestSequenceConflict(t *testing.T) {
+	for _, concurrent := range []bool{true, false} {
+		s, logID := tester.newLog(t)
+		leaves := testLeaves(2)
+		runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+			return tx.QueueLeaves(leaves, time.Unix(1000, 0))
+		})
+
+		var tx2 storage.LogTreeTX
+		var err error
+		if concurrent {
+			if tx2, err = s.BeginForTree(context.Background(), logID); err != nil {
+				t.Fatalf("BeginForTree() = (_, %v), want = (_, nil)", err)
+			}
+		}
+		first := *leaves[0]
+		runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+			return tx.UpdateSequencedLeaves([]*trillian.LogLeaf{&first})
+		})
+		if !concurrent {
+			if tx2, err = s.BeginForTree(context.Background(), logID); err != nil {
+				t.Fatalf("BeginForTree() = (_, %v), want = (_, nil)", err)
+			}
+		}
+
+		// The second leaf is given the index already used by the first.
+		second := *leaves[1]
+		err = tx2.UpdateSequencedLeaves([]*trillian.LogLeaf{&second})
+		if err == nil {
+			err = tx2.Commit()
+		}
+		tx2.Close()
+		if !storage.IsTransient(err) {
+			t.Errorf("concurrent=%v: sequencing a leaf at a used index = %v, want transient error", concurrent, err)
+		}
+
+		runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+			count, err := tx.GetSequencedLeafCount()
+			if err != nil {
+				return err
+			}
+			if count != 1 {
+				t.Errorf("concurrent=%v: GetSequencedLeafCount() = %v, want = 1", concurrent, count)
+			}
+			byIndex, err := tx.GetLeavesByIndex([]int64{0})
+			if err != nil {
+				return err
+			}
+			if len(byIndex) != 1 || !bytes.Equal(byIndex[0].LeafIdentityHash, first.LeafIdentityHash) {
+				t.Errorf("concurrent=%v: GetLeavesByIndex(0) = %v, want the first leaf", concurrent, byIndex)
+			}
+			return nil
+		})
+	}
+}
+
+// TestSignedLogRoots tests storing and reading back signed log roots, and the effect
+// on tree revisions.
+func (tester *LogStorageTester) TestSignedLogRoots(t *testing.T) {
+	s, logID := tester.newLog(t)
+
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		root, err := tx.LatestSignedLogRoot()
+		if err != nil {
+			return err
+		}
+		if root.TreeSize != 0 || root.TreeRevision != 0 || len(root.RootHash) != 0 {
+			t.Errorf("LatestSignedLogRoot() = %v, want empty root for new log", root)
+		}
+		if got, want := tx.WriteRevision(), int64(1); got != want {
+			t.Errorf("WriteRevision() = %v, want = %v", got, want)
+		}
+		return nil
+	})
+
+	want := trillian.SignedLogRoot{
+		LogId:          logID,
+		TimestampNanos: 98765,
+		TreeSize:       16,
+		TreeRevision:   1,
+		RootHash:       []byte("root hash"),
+		Signature:      &spb.DigitallySigned{Signature: []byte("signed")},
+		KeyId:          []byte("key id"),
+	}
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		return tx.StoreSignedLogRoot(want)
+	})
+
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		root, err := tx.LatestSignedLogRoot()
+		if err != nil {
+			return err
+		}
+		if !proto.Equal(&root, &want) {
+			t.Errorf("LatestSignedLogRoot() = %v, want = %v", root, want)
+		}
+		if got, want := tx.ReadRevision(), want.TreeRevision; got != want {
+			t.Errorf("ReadRevision() = %v, want = %v", got, want)
+		}
+		if got, want := tx.WriteRevision(), want.TreeRevision+1; got != want {
+			t.Errorf("WriteRevision() = %v, want = %v", got, want)
+		}
+		return nil
+	})
+}
+
+// TestMerkleNodes tests that nodes are read back at the revisions they were written.
+func (tester *LogStorageTester) TestMerkleNodes(t *testing.T) {
+	s, logID := tester.newLog(t)
+	nodeID, err := storage.NewNodeIDForTreeCoords(0, 5, 64)
+	if err != nil {
+		t.Fatalf("NewNodeIDForTreeCoords() = (_, %v), want = (_, nil)", err)
+	}
+	zero, one := sha256.Sum256([]byte("zero")), sha256.Sum256([]byte("one"))
+	hashes := [][]byte{zero[:], one[:]}
+
+	// Write the node at revisions 1 and 2, with a new root each time to advance the revision.
+	for i, hash := range hashes {
+		runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+			if err := tx.SetMerkleNodes([]storage.Node{{NodeID: nodeID, Hash: hash}}); err != nil {
+				return err
+			}
+			return tx.StoreSignedLogRoot(trillian.SignedLogRoot{
+				LogId:          logID,
+				TimestampNanos: int64(i + 1),
+				TreeRevision:   tx.WriteRevision(),
+				RootHash:       hash,
+				Signature:      &spb.DigitallySigned{},
+			})
+		})
+	}
+
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		for _, test := range []struct {
+			rev  int64
+			want []byte
+		}{
+			{rev: 0, want: nil},
+			{rev: 1, want: hashes[0]},
+			{rev: 2, want: hashes[1]},
+			{rev: 3, want: hashes[1]},
+		} {
+			nodes, err := tx.GetMerkleNodes(test.rev, []storage.NodeID{nodeID})
+			if err != nil {
+				return err
+			}
+			if test.want == nil {
+				if len(nodes) != 0 {
+					t.Errorf("GetMerkleNodes(%v) = %v, want no nodes", test.rev, nodes)
+				}
+				continue
+			}
+			if len(nodes) != 1 || !bytes.Equal(nodes[0].Hash, test.want) {
+				t.Errorf("GetMerkleNodes(%v) = %v, want node with hash %x", test.rev, nodes, test.want)
+			}
+		}
+		return nil
+	})
+}
+
+// TestGetActiveLogIDs tests listing logs, with and without pending work.
+func (tester *LogStorageTester) TestGetActiveLogIDs(t *testing.T) {
+	ctx := context.Background()
+	s, idle := tester.newLog(t)
+	busy, err := tester.CreateLog(s, LogTree)
+	if err != nil {
+		t.Fatalf("CreateLog() = (_, %v), want = (_, nil)", err)
+	}
+	runLogTX(t, s, busy, func(tx storage.LogTreeTX) error {
+		return tx.QueueLeaves(testLeaves(1), time.Unix(1000, 0))
+	})
+
+	tx, err := s.Snapshot(ctx)
+	if err != nil {
+		t.Fatalf("Snapshot() = (_, %v), want = (_, nil)", err)
+	}
+	defer tx.Close()
+
+	all, err := tx.GetActiveLogIDs()
+	if err != nil {
+		t.Fatalf("GetActiveLogIDs() = (_, %v), want = (_, nil)", err)
+	}
+	if got, want := toIntMap(all), toIntMap([]int64{idle, busy}); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetActiveLogIDs() = %v, want = %v", all, []int64{idle, busy})
+	}
+	pending, err := tx.GetActiveLogIDsWithPendingWork()
+	if err != nil {
+		t.Fatalf("GetActiveLogIDsWithPendingWork() = (_, %v), want = (_, nil)", err)
+	}
+	if got, want := toIntMap(pending), toIntMap([]int64{busy}); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetActiveLogIDsWithPendingWork() = %v, want = %v", pending, []int64{busy})
+	}
+	if err := tx.Commit(); err != nil {
+		t.Errorf("Commit() = %v, want = nil", err)
+	}
+}
+
+// TestLogTXClose tests that transactions are closed by Commit, Rollback and Close, and
+// that Close is a noop on a closed transaction.
+func (tester *LogStorageTester) TestLogTXClose(t *testing.T) {
+	ctx := context.Background()
+	s, logID := tester.newLog(t)
+
+	tests := []struct {
+		commit, rollback bool
+	}{
+		{commit: true},
+		{rollback: true},
+		{},
+	}
+	for i, test := range tests {
+		tx, err := s.BeginForTree(ctx, logID)
+		if err != nil {
+			t.Fatalf("%v: BeginForTree() = (_, %v), want = (_, nil)", i, err)
+		}
+		if !tx.IsOpen() {
+			t.Errorf("%v: IsOpen() = false, want = true for new transaction", i)
+		}
+		switch {
+		case test.commit:
+			err = tx.Commit()
+		case test.rollback:
+			err = tx.Rollback()
+		}
+		if err != nil {
+			t.Errorf("%v: Commit()/Rollback() = %v, want = nil", i, err)
+		}
+		if err := tx.Close(); err != nil {
+			t.Errorf("%v: Close() = %v, want = nil", i, err)
+		}
+		if tx.IsOpen() {
+			t.Errorf("%v: IsOpen() = true, want = false after Close()", i)
+		}
+	}
+}
+
+// TestRollbackDiscardsChanges tests that nothing written in a transaction which is not
+// committed is visible to later transactions.
+func (tester *LogStorageTester) TestRollbackDiscardsChanges(t *testing.T) {
+	ctx := context.Background()
+	s, logID := tester.newLog(t)
+	now := time.Unix(1000, 0)
+
+	tx, err := s.BeginForTree(ctx, logID)
+	if err != nil {
+		t.Fatalf("BeginForTree() = (_, %v), want = (_, nil)", err)
+	}
+	if err := tx.QueueLeaves(testLeaves(2), now); err != nil {
+		t.Fatalf("QueueLeaves() = %v, want = nil", err)
+	}
+	root := trillian.SignedLogRoot{LogId: logID, TimestampNanos: 1, TreeRevision: 1, RootHash: []byte("root"), Signature: &spb.DigitallySigned{}}
+	if err := tx.StoreSignedLogRoot(root); err != nil {
+		t.Fatalf("StoreSignedLogRoot() = %v, want = nil", err)
+	}
+	if err := tx.Close(); err != nil {
+		t.Fatalf("Close() = %v, want = nil", err)
+	}
+
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		if got, err := tx.DequeueLeaves(10, now); err != nil || len(got) != 0 {
+			t.Errorf("DequeueLeaves() = (%d leaves, %v), want = (0 leaves, nil)", len(got), err)
+		}
+		if got, err := tx.LatestSignedLogRoot(); err != nil || got.TreeRevision != 0 {
+			t.Errorf("LatestSignedLogRoot() = (%v, %v), want = (empty root, nil)", got, err)
+		}
+		return nil
+	})
+}
+
+// TestBeginForUnknownTree tests that transactions can't be started for trees that
+// don't exist.
+func (tester *LogStorageTester) TestBeginForUnknownTree(t *testing.T) {
+	ctx := context.Background()
+	s := tester.NewLogStorage()
+	if tx, err := s.BeginForTree(ctx, -1); err == nil {
+		tx.Close()
+		t.Errorf("BeginForTree(-1) = (_, nil), want = (_, err)")
+	}
+	if tx, err := s.SnapshotForTree(ctx, -1); err == nil {
+		tx.Close()
+		t.Errorf("SnapshotForTree(-1) = (_, nil), want = (_, err)")
+	}
+}
+
+// TestCreateTree tests that trees can be created with a given ID, and that creating an
+// existing tree fails without changing it.
+func (tester *LogStorageTester) TestCreateTree(t *testing.T) {
+	ctx := context.Background()
+	s := tester.NewLogStorage()
+	const logID = 4317
+	if err := s.CreateTree(ctx, logID, trillian.HashStrategy_RFC_6962, spb.DigitallySigned_ECDSA); err != nil {
+		t.Fatalf("CreateTree() = %v, want = nil", err)
+	}
+
+	want := trillian.SignedLogRoot{LogId: logID, TimestampNanos: 1, TreeRevision: 1, RootHash: []byte("root"), Signature: &spb.DigitallySigned{}}
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		root, err := tx.LatestSignedLogRoot()
+		if err != nil {
+			return err
+		}
+		if root.TreeSize != 0 || root.TreeRevision != 0 || len(root.RootHash) != 0 {
+			t.Errorf("LatestSignedLogRoot() = %v, want empty root for new log", root)
+		}
+		return tx.StoreSignedLogRoot(want)
+	})
+
+	err := s.CreateTree(ctx, logID, trillian.HashStrategy_RFC_6962, spb.DigitallySigned_ECDSA)
+	if serr, ok := err.(storage.Error); !ok || serr.ErrType != storage.AlreadyExists {
+		t.Errorf("CreateTree(existing) = %v, want = AlreadyExists error", err)
+	}
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		root, err := tx.LatestSignedLogRoot()
+		if err != nil {
+			return err
+		}
+		if !proto.Equal(&root, &want) {
+			t.Errorf("LatestSignedLogRoot() after CreateTree(existing) = %v, want = %v", root, want)
+		}
+		return nil
+	})
+
+	for _, test := range []struct {
+		desc         string
+		hashStrategy trillian.HashStrategy
+		sigAlgo      spb.DigitallySigned_SignatureAlgorithm
+	}{
+		{desc: "unknown hash strategy", hashStrategy: trillian.HashStrategy_UNKNOWN_HASH_STRATEGY, sigAlgo: spb.DigitallySigned_ECDSA},
+		{desc: "anonymous signatures", hashStrategy: trillian.HashStrategy_RFC_6962, sigAlgo: spb.DigitallySigned_ANONYMOUS},
+	} {
+		if err := s.CreateTree(ctx, logID+1, test.hashStrategy, test.sigAlgo); err == nil {
+			t.Errorf("%v: CreateTree() = nil, want = err", test.desc)
+		}
+	}
+
+	// Trees can be created and opened for each hash strategy with a hasher, and for each
+	// algorithm a key manager can sign with.
+	for i, test := range []struct {
+		hashStrategy trillian.HashStrategy
+		sigAlgo      spb.DigitallySigned_SignatureAlgorithm
+	}{
+		{hashStrategy: trillian.HashStrategy_PLAIN_SHA256, sigAlgo: spb.DigitallySigned_ECDSA},
+		{hashStrategy: trillian.HashStrategy_RFC_6962_SHA3_256, sigAlgo: spb.DigitallySigned_ECDSA},
+		{hashStrategy: trillian.HashStrategy_RFC_6962, sigAlgo: spb.DigitallySigned_ED25519},
+		{hashStrategy: trillian.HashStrategy_RFC_6962, sigAlgo: spb.DigitallySigned_RSA_PSS},
+	} {
+		treeID := int64(logID + 2 + i)
+		if err := s.CreateTree(ctx, treeID, test.hashStrategy, test.sigAlgo); err != nil {
+			t.Errorf("CreateTree(%v, %v) = %v, want = nil", test.hashStrategy, test.sigAlgo, err)
+			continue
+		}
+		runLogTX(t, s, treeID, func(tx storage.LogTreeTX) error {
+			if got, want := tx.IdentityHashAlgorithm(), spb.DigitallySigned_NONE; got != want {
+				t.Errorf("IdentityHashAlgorithm() = %v, want = %v", got, want)
+			}
+			_, err := tx.LatestSignedLogRoot()
+			return err
+		})
+	}
+}
+
+// TestSnapshotForTree tests that read-only transactions see committed data.
+func (tester *LogStorageTester) TestSnapshotForTree(t *testing.T) {
+	s, logID := tester.newLog(t)
+	want := trillian.SignedLogRoot{LogId: logID, TimestampNanos: 1, TreeRevision: 1, RootHash: []byte("root"), Signature: &spb.DigitallySigned{}}
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		return tx.StoreSignedLogRoot(want)
+	})
+
+	tx, err := s.SnapshotForTree(context.Background(), logID)
+	if err != nil {
+		t.Fatalf("SnapshotForTree() = (_, %v), want = (_, nil)", err)
+	}
+	defer tx.Close()
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot() = (_, %v), want = (_, nil)", err)
+	}
+	if !proto.Equal(&root, &want) {
+		t.Errorf("LatestSignedLogRoot() = %v, want = %v", root, want)
+	}
+	if got, want := tx.ReadRevision(), want.TreeRevision; got != want {
+		t.Errorf("ReadRevision() = %v, want = %v", got, want)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Errorf("Commit() = %v, want = nil", err)
+	}
+}
+
+// TestQueueLeavesInvalidHash tests that leaves with identity hashes of the wrong size
+// are rejected.
+func (tester *LogStorageTester) TestQueueLeavesInvalidHash(t *testing.T) {
+	s, logID := tester.newLog(t)
+	tx, err := s.BeginForTree(context.Background(), logID)
+	if err != nil {
+		t.Fatalf("BeginForTree() = (_, %v), want = (_, nil)", err)
+	}
+	defer tx.Close()
+
+	leaves := testLeaves(2)
+	leaves[1].LeafIdentityHash = leaves[1].LeafIdentityHash[:5]
+	if err := tx.QueueLeaves(leaves, time.Unix(1000, 0)); err == nil {
+		t.Errorf("QueueLeaves(short hash) = nil, want = err")
+	}
+}
+
+// TestQueueDuplicateLeaves tests that the tree's duplicate policy is applied when leaves
+// are queued.
+func (tester *LogStorageTester) TestQueueDuplicateLeaves(t *testing.T) {
+	now := time.Unix(1000, 0)
+	allowed := *LogTree
+	allowed.DuplicatePolicy = trillian.DuplicatePolicy_DUPLICATES_ALLOWED
+
+	tests := []struct {
+		tree         *trillian.Tree
+		wantErr      bool
+		wantDequeued int
+	}{
+		{tree: LogTree, wantErr: true},
+		{tree: &allowed, wantDequeued: 2},
+	}
+	for i, test := range tests {
+		s, logID := tester.newLogWithTree(t, test.tree)
+		leaves := testLeaves(1)
+		runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+			return tx.QueueLeaves(leaves, now)
+		})
+
+		tx, err := s.BeginForTree(context.Background(), logID)
+		if err != nil {
+			t.Fatalf("%v: BeginForTree() = (_, %v), want = (_, nil)", i, err)
+		}
+		err = tx.QueueLeaves(leaves, now.Add(time.Second))
+		if test.wantErr {
+			if serr, ok := err.(storage.Error); !ok || serr.ErrType != storage.DuplicateLeaf {
+				t.Errorf("%v: QueueLeaves(duplicate) = %v, want = DuplicateLeaf error", i, err)
+			}
+			tx.Close()
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: QueueLeaves(duplicate) = %v, want = nil", i, err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("%v: Commit() = %v, want = nil", i, err)
+		}
+
+		runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+			got, err := tx.DequeueLeaves(10, now.Add(time.Hour))
+			if err != nil {
+				return err
+			}
+			if len(got) != test.wantDequeued {
+				t.Errorf("%v: DequeueLeaves() returned %d leaves, want %d", i, len(got), test.wantDequeued)
+			}
+			return nil
+		})
+	}
+}
+
+// TestQueueLeavesBatch tests that QueueLeavesBatch queues every leaf it is given and
+// reports duplicates per leaf, without failing the rest of the batch.
+func (tester *LogStorageTester) TestQueueLeavesBatch(t *testing.T) {
+	now := time.Unix(1000, 0)
+	allowed := *LogTree
+	allowed.DuplicatePolicy = trillian.DuplicatePolicy_DUPLICATES_ALLOWED
+
+	tests := []struct {
+		tree         *trillian.Tree
+		wantDups     []int
+		wantDequeued int
+	}{
+		// The batch repeats the already queued leaf 0 at the start and leaf 1 at the end.
+		{tree: LogTree, wantDups: []int{0, 26}, wantDequeued: 26},
+		{tree: &allowed, wantDequeued: 28},
+	}
+	for i, test := range tests {
+		s, logID := tester.newLogWithTree(t, test.tree)
+		leaves := testLeaves(26)
+		runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+			return tx.QueueLeaves(leaves[:1], now)
+		})
+
+		batch := append(append([]*trillian.LogLeaf{}, leaves...), leaves[1])
+		runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+			statuses, err := tx.QueueLeavesBatch(batch, now.Add(time.Second))
+			if err != nil {
+				return err
+			}
+			if len(statuses) != len(batch) {
+				t.Errorf("%v: QueueLeavesBatch() returned %d statuses, want %d", i, len(statuses), len(batch))
+				return nil
+			}
+			wantDup := make(map[int]bool)
+			for _, d := range test.wantDups {
+				wantDup[d] = true
+			}
+			for j, status := range statuses {
+				if !wantDup[j] {
+					if status != nil {
+						t.Errorf("%v: QueueLeavesBatch()[%d] = %v, want = nil", i, j, status)
+					}
+					continue
+				}
+				if serr, ok := status.(storage.Error); !ok || serr.ErrType != storage.DuplicateLeaf {
+					t.Errorf("%v: QueueLeavesBatch()[%d] = %v, want = DuplicateLeaf error", i, j, status)
+				}
+			}
+			return nil
+		})
+
+		runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+			got, err := tx.DequeueLeaves(100, now.Add(time.Hour))
+			if err != nil {
+				return err
+			}
+			if len(got) != test.wantDequeued {
+				t.Errorf("%v: DequeueLeaves() returned %d leaves, want %d", i, len(got), test.wantDequeued)
+			}
+			if got, want := identityHashes(got), identityHashes(leaves); !reflect.DeepEqual(got, want) {
+				t.Errorf("%v: DequeueLeaves() returned leaves %v, want %v", i, got, want)
+			}
+			return nil
+		})
+	}
+}
+
+// TestQueueLeafWithToken tests that a leaf is only queued once for each dedup token,
+// even if its content changes, and that repeats return the leaf first queued.
+func (tester *LogStorageTester) TestQueueLeafWithToken(t *testing.T) {
+	s, logID := tester.newLog(t)
+	leaves := testLeaves(3)
+	now := time.Unix(1000, 0)
+	queue := func(tx storage.LogTreeTX, leaf *trillian.LogLeaf, token string) *trillian.LogLeaf {
+		t.Helper()
+		existing, err := tx.QueueLeafWithToken(leaf, token, now)
+		if err != nil {
+			t.Fatalf("QueueLeafWithToken(%q) = (_, %v), want = (_, nil)", token, err)
+		}
+		return existing
+	}
+
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		if existing := queue(tx, leaves[0], "token"); existing != nil {
+			t.Errorf("QueueLeafWithToken(new token) = %v, want = nil", existing)
+		}
+		return nil
+	})
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		// A retry with changed content is not queued again.
+		want := *leaves[0]
+		want.LeafIndex = -1
+		if existing := queue(tx, leaves[1], "token"); !proto.Equal(existing, &want) {
+			t.Errorf("QueueLeafWithToken(repeated token) = %v, want = %v", existing, &want)
+		}
+		count, err := tx.QueuedLeafCount()
+		if err != nil {
+			return err
+		}
+		if count != 1 {
+			t.Errorf("QueuedLeafCount() = %d, want = 1", count)
+		}
+
+		dequeued, err := tx.DequeueLeaves(10, now)
+		if err != nil {
+			return err
+		}
+		if len(dequeued) != 1 {
+			t.Fatalf("DequeueLeaves() returned %d leaves, want 1", len(dequeued))
+		}
+		dequeued[0].LeafIndex = 0
+		return tx.UpdateSequencedLeaves(dequeued)
+	})
+	runLogTX(t, s, logID, func(tx storage.LogTreeTX) error {
+		// Once sequenced, the leaf is returned with its index.
+		want := *leaves[0]
+		if existing := queue(tx, leaves[2], "token"); !proto.Equal(existing, &want) {
+			t.Errorf("QueueLeafWithToken(sequenced token) = %v, want = %v", existing, &want)
+		}
+		if existing := queue(tx, leaves[1], "other token"); existing != nil {
+			t.Errorf("QueueLeafWithToken(other token) = %v, want = nil", existing)
+		}
+		count, err := tx.QueuedLeafCount()
+		if err != nil {
+			return err
+		}
+		if count != 1 {
+			t.Errorf("QueuedLeafCount() = %d, want = 1", count)
+		}
+		return nil
+	})
+}
+
+// TestDuplicateSignedLogRoot tests that two roots can't be stored at the same revision.
+func (tester *LogStorageTester) TestDuplicateSignedLogRoot(t *testing.T) {
+	s, logID := tester.newLog(t)
+	tx, err := s.BeginForTree(context.Background(), logID)
+	if err != nil {
+		t.Fatalf("BeginForTree() = (_, %v), want = (_, nil)", err)
+	}
+	defer tx.Close()
+
+	root := trillian.SignedLogRoot{LogId: logID, TimestampNanos: 98765, TreeSize: 16, TreeRevision: 5, RootHash: []byte("root"), Signature: &spb.DigitallySigned{}}
+	if err := tx.StoreSignedLogRoot(root); err != nil {
+		t.Fatalf("StoreSignedLogRoot() = %v, want = nil", err)
+	}
+	if err := tx.StoreSignedLogRoot(root); err == nil {
+		t.Errorf("StoreSignedLogRoot(duplicate) = nil, want = err")
+	}
+}
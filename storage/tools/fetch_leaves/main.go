@@ -30,11 +30,11 @@ var leafHashHex = flag.String("leaf_hash", "", "The hash of a leaf to fetch")
 
 func validateFetchFlagsOrDie() {
 	if *fetchLeavesFlag <= 0 {
-		panic("Invalid value for num_insertions")
+		log.Exit("Invalid value for num_insertions")
 	}
 
 	if *startFetchFromFlag < 0 {
-		panic("Invalid value for start_fetch_at")
+		log.Exit("Invalid value for start_fetch_at")
 	}
 }
 
@@ -45,34 +45,34 @@ func main() {
 
 	registry, err := builtin.NewDefaultExtensionRegistry()
 	if err != nil {
-		panic(err)
+		log.Exitf("builtin.NewDefaultExtensionRegistry() failed: %v", err)
 	}
 	storage, err := registry.GetLogStorage()
 	if err != nil {
-		panic(err)
+		log.Exitf("registry.GetLogStorage() failed: %v", err)
 	}
 
 	ctx := context.Background()
 	tx, err := storage.SnapshotForTree(ctx, *treeIDFlag)
 	if err != nil {
-		panic(err)
+		log.Exitf("storage.SnapshotForTree() failed: %v", err)
 	}
 
 	leafCount, err := tx.GetSequencedLeafCount()
 	if err != nil {
-		panic(err)
+		log.Exitf("tx.GetSequencedLeafCount() failed: %v", err)
 	}
 	log.Infof("Sequenced leaf count in storage is: %d", leafCount)
 
 	if len(*leafHashHex) > 0 {
 		hash, err := hex.DecodeString(*leafHashHex)
 		if err != nil {
-			panic(err)
+			log.Exitf("hex.DecodeString() failed: %v", err)
 		}
 
 		fetchedLeaves, err := tx.GetLeavesByHash([][]byte{hash}, false)
 		if err != nil {
-			panic(err)
+			log.Exitf("tx.GetLeavesByHash() failed: %v", err)
 		}
 
 		for index, leaf := range fetchedLeaves {
@@ -90,7 +90,7 @@ func main() {
 
 		fetchedLeaves, err := tx.GetLeavesByIndex(leaves)
 		if err != nil {
-			panic(err)
+			log.Exitf("tx.GetLeavesByIndex() failed: %v", err)
 		}
 
 		for index, leaf := range fetchedLeaves {
@@ -99,6 +99,6 @@ func main() {
 	}
 
 	if err := tx.Commit(); err != nil {
-		panic(err)
+		log.Exitf("tx.Commit() failed: %v", err)
 	}
 }
@@ -33,37 +33,37 @@ var queueBatchSizeFlag = flag.Int("queue_batch_size", 50, "Queue leaves batch si
 
 func validateFlagsOrDie() {
 	if *numInsertionsFlag <= 0 {
-		panic("Invalid value for num_insertions")
+		log.Exit("Invalid value for num_insertions")
 	}
 
 	if *startInsertFromFlag < 0 {
-		panic("Invalid value for start_from")
+		log.Exit("Invalid value for start_from")
 	}
 
 	if *queueBatchSizeFlag <= 0 {
-		panic("Invalid value for queue_batch_size")
+		log.Exit("Invalid value for queue_batch_size")
 	}
 }
 
 // Queues a number of leaves for a log from a given start point with predictable hashes.
-// If anything fails it panics, leaving storage untouched
+// If anything fails it exits with a non-zero status, leaving storage untouched
 func main() {
 	flag.Parse()
 	validateFlagsOrDie()
 
 	registry, err := builtin.NewDefaultExtensionRegistry()
 	if err != nil {
-		panic(err)
+		log.Exitf("builtin.NewDefaultExtensionRegistry() failed: %v", err)
 	}
 	storage, err := registry.GetLogStorage()
 	if err != nil {
-		panic(err)
+		log.Exitf("registry.GetLogStorage() failed: %v", err)
 	}
 
 	ctx := context.Background()
 	tx, err := storage.BeginForTree(ctx, *treeIDFlag)
 	if err != nil {
-		panic(err)
+		log.Exitf("storage.BeginForTree() failed: %v", err)
 	}
 
 	leaves := []*trillian.LogLeaf{}
@@ -88,7 +88,7 @@ func main() {
 			leaves = leaves[:0] // starting new batch
 
 			if err != nil {
-				panic(err)
+				log.Exitf("tx.QueueLeaves() failed: %v", err)
 			}
 		}
 	}
@@ -96,11 +96,11 @@ func main() {
 	// There might be some leaves left over that didn't get queued yet
 	if len(leaves) > 0 {
 		if err := tx.QueueLeaves(leaves, time.Now()); err != nil {
-			panic(err)
+			log.Exitf("tx.QueueLeaves() failed: %v", err)
 		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		panic(err)
+		log.Exitf("tx.Commit() failed: %v", err)
 	}
 }
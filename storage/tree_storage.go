@@ -65,6 +65,8 @@ type DatabaseChecker interface {
 // NodeReader provides a read-only interface into the stored tree nodes.
 type NodeReader interface {
 	// GetMerkleNodes looks up the set of nodes identified by ids, at treeRevision, and returns them.
+	// The version of each node returned is the latest written at or before treeRevision. Nodes
+	// that had not been written at treeRevision are not returned.
 	GetMerkleNodes(treeRevision int64, ids []NodeID) ([]Node, error)
 }
 
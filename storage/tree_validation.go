@@ -45,6 +45,8 @@ func ValidateTreeForCreation(tree *trillian.Tree) error {
 		return fmt.Errorf("invalid signature_algorithm: %s", tree.SignatureAlgorithm)
 	case tree.DuplicatePolicy == trillian.DuplicatePolicy_UNKNOWN_DUPLICATE_POLICY:
 		return fmt.Errorf("invalid duplicate_policy: %s", tree.DuplicatePolicy)
+	case sigpb.DigitallySigned_HashAlgorithm_name[int32(tree.IdentityHashAlgorithm)] == "":
+		return fmt.Errorf("invalid identity_hash_algorithm: %s", tree.IdentityHashAlgorithm)
 	}
 	return validateMutableTreeFields(tree)
 }
@@ -71,6 +73,8 @@ func ValidateTreeForUpdate(storedTree, newTree *trillian.Tree) error {
 		return errors.New("readonly field changed: signature_algorithm")
 	case storedTree.DuplicatePolicy != newTree.DuplicatePolicy:
 		return errors.New("readonly field changed: duplicate_policy")
+	case storedTree.IdentityHashAlgorithm != newTree.IdentityHashAlgorithm:
+		return errors.New("readonly field changed: identity_hash_algorithm")
 	case storedTree.CreateTimeMillisSinceEpoch != newTree.CreateTimeMillisSinceEpoch:
 		return errors.New("readonly field changed: create_time")
 	case storedTree.UpdateTimeMillisSinceEpoch != newTree.UpdateTimeMillisSinceEpoch:
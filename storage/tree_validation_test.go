@@ -28,6 +28,9 @@ func TestValidateTreeForCreation(t *testing.T) {
 	valid2.TreeType = trillian.TreeType_MAP
 	valid2.DuplicatePolicy = trillian.DuplicatePolicy_DUPLICATES_ALLOWED
 
+	valid3 := newTree()
+	valid3.IdentityHashAlgorithm = sigpb.DigitallySigned_SHA1
+
 	invalidState1 := newTree()
 	invalidState1.TreeState = trillian.TreeState_UNKNOWN_TREE_STATE
 	invalidState2 := newTree()
@@ -52,6 +55,9 @@ func TestValidateTreeForCreation(t *testing.T) {
 	invalidDuplicatePolicy := newTree()
 	invalidDuplicatePolicy.DuplicatePolicy = trillian.DuplicatePolicy_UNKNOWN_DUPLICATE_POLICY
 
+	invalidIdentityHashAlgorithm := newTree()
+	invalidIdentityHashAlgorithm.IdentityHashAlgorithm = sigpb.DigitallySigned_HashAlgorithm(99)
+
 	invalidDisplayName := newTree()
 	invalidDisplayName.DisplayName = "A Very Long Display Name That Clearly Won't Fit But At Least Mentions Llamas Somewhere"
 
@@ -67,6 +73,7 @@ func TestValidateTreeForCreation(t *testing.T) {
 	}{
 		{tree: valid1},
 		{tree: valid2},
+		{tree: valid3},
 		{tree: invalidState1, wantErr: true},
 		{tree: invalidState2, wantErr: true},
 		{tree: invalidState3, wantErr: true},
@@ -76,6 +83,7 @@ func TestValidateTreeForCreation(t *testing.T) {
 		{tree: invalidHashAlgorithm, wantErr: true},
 		{tree: invalidSignatureAlgorithm, wantErr: true},
 		{tree: invalidDuplicatePolicy, wantErr: true},
+		{tree: invalidIdentityHashAlgorithm, wantErr: true},
 		{tree: invalidDisplayName, wantErr: true},
 		{tree: invalidDescription, wantErr: true},
 	}
@@ -148,6 +156,13 @@ func TestValidateTreeForUpdate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			desc: "IdentityHashAlgorithm",
+			updatefn: func(tree *trillian.Tree) {
+				tree.IdentityHashAlgorithm = sigpb.DigitallySigned_SHA1
+			},
+			wantErr: true,
+		},
 		{
 			desc: "CreateTime",
 			updatefn: func(tree *trillian.Tree) {
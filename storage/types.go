@@ -16,6 +16,7 @@ package storage
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 
 	"github.com/google/trillian/storage/storagepb"
@@ -24,6 +25,13 @@ import (
 // Integer types to distinguish storage errors that might need to be mapped at a higher level.
 const (
 	DuplicateLeaf = iota
+	// TransientError indicates a failure, such as a deadlock between transactions, that
+	// may not recur if the operation is retried in a new transaction.
+	TransientError
+	// NotFound indicates that the item an operation refers to does not exist.
+	NotFound
+	// AlreadyExists indicates that the item an operation would create already exists.
+	AlreadyExists
 )
 
 // Error is a typed error that the storage layer can return to give callers information
@@ -39,6 +47,17 @@ func (s Error) Error() string {
 	return fmt.Sprintf("Storage: %d: %s: %v", s.ErrType, s.Detail, s.Cause)
 }
 
+// Unwrap returns the cause of the error, if any.
+func (s Error) Unwrap() error {
+	return s.Cause
+}
+
+// IsTransient reports whether err is, or wraps, an Error of type TransientError.
+func IsTransient(err error) bool {
+	var serr Error
+	return errors.As(err, &serr) && serr.ErrType == TransientError
+}
+
 // Node represents a single node in a Merkle tree.
 type Node struct {
 	NodeID       NodeID
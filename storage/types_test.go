@@ -16,6 +16,7 @@ package storage
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"testing"
 )
@@ -230,3 +231,19 @@ func TestCoordString(t *testing.T) {
 		}
 	}
 }
+
+func TestIsTransient(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{err: nil, want: false},
+		{err: errors.New("other"), want: false},
+		{err: Error{ErrType: DuplicateLeaf}, want: false},
+		{err: Error{ErrType: TransientError, Cause: errors.New("deadlock")}, want: true},
+	} {
+		if got := IsTransient(test.err); got != test.want {
+			t.Errorf("IsTransient(%v)=%v, want %v", test.err, got, test.want)
+		}
+	}
+}
@@ -25,15 +25,23 @@ const (
 	// Certificate Transparency strategy: leaf hash prefix = 0x00, node prefix =
 	// 0x01, empty hash is digest([]byte{}), as defined in the specification.
 	HashStrategy_RFC_6962 HashStrategy = 1
+	// SHA-256 without the RFC 6962 leaf and node prefixes.
+	HashStrategy_PLAIN_SHA256 HashStrategy = 2
+	// The RFC 6962 strategy, using SHA3-256 rather than SHA-256.
+	HashStrategy_RFC_6962_SHA3_256 HashStrategy = 3
 )
 
 var HashStrategy_name = map[int32]string{
 	0: "UNKNOWN_HASH_STRATEGY",
 	1: "RFC_6962",
+	2: "PLAIN_SHA256",
+	3: "RFC_6962_SHA3_256",
 }
 var HashStrategy_value = map[string]int32{
 	"UNKNOWN_HASH_STRATEGY": 0,
 	"RFC_6962":              1,
+	"PLAIN_SHA256":          2,
+	"RFC_6962_SHA3_256":     3,
 }
 
 func (x HashStrategy) String() string {
@@ -170,6 +178,11 @@ type Tree struct {
 	// Duplicate policy to be used by the tree.
 	// Readonly.
 	DuplicatePolicy DuplicatePolicy `protobuf:"varint,7,opt,name=duplicate_policy,json=duplicatePolicy,enum=trillian.DuplicatePolicy" json:"duplicate_policy,omitempty"`
+	// Hash algorithm used to compute the identity hash of leaves queued without
+	// one. NONE selects SHA-256. SHA1 may also be used, to match the identity
+	// hashes of a legacy system.
+	// Readonly.
+	IdentityHashAlgorithm sigpb.DigitallySigned_HashAlgorithm `protobuf:"varint,12,opt,name=identity_hash_algorithm,json=identityHashAlgorithm,enum=sigpb.DigitallySigned_HashAlgorithm" json:"identity_hash_algorithm,omitempty"`
 	// Display name of the tree.
 	// Optional.
 	DisplayName string `protobuf:"bytes,8,opt,name=display_name,json=displayName" json:"display_name,omitempty"`
@@ -239,6 +252,13 @@ func (m *Tree) GetDuplicatePolicy() DuplicatePolicy {
 	return DuplicatePolicy_UNKNOWN_DUPLICATE_POLICY
 }
 
+func (m *Tree) GetIdentityHashAlgorithm() sigpb.DigitallySigned_HashAlgorithm {
+	if m != nil {
+		return m.IdentityHashAlgorithm
+	}
+	return sigpb.DigitallySigned_NONE
+}
+
 func (m *Tree) GetDisplayName() string {
 	if m != nil {
 		return m.DisplayName
@@ -310,6 +330,9 @@ type SignedLogRoot struct {
 	Signature    *sigpb.DigitallySigned `protobuf:"bytes,4,opt,name=signature" json:"signature,omitempty"`
 	LogId        int64                  `protobuf:"varint,5,opt,name=log_id,json=logId" json:"log_id,omitempty"`
 	TreeRevision int64                  `protobuf:"varint,6,opt,name=tree_revision,json=treeRevision" json:"tree_revision,omitempty"`
+	// key_id identifies the key that produced signature, as computed by
+	// crypto.KeyID, so that verifiers can select the key after a rotation.
+	KeyId []byte `protobuf:"bytes,7,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
 }
 
 func (m *SignedLogRoot) Reset()                    { *m = SignedLogRoot{} }
@@ -359,6 +382,13 @@ func (m *SignedLogRoot) GetTreeRevision() int64 {
 	return 0
 }
 
+func (m *SignedLogRoot) GetKeyId() []byte {
+	if m != nil {
+		return m.KeyId
+	}
+	return nil
+}
+
 type MapperMetadata struct {
 	SourceLogId                  []byte `protobuf:"bytes,1,opt,name=source_log_id,json=sourceLogId,proto3" json:"source_log_id,omitempty"`
 	HighestFullyCompletedSeq     int64  `protobuf:"varint,2,opt,name=highest_fully_completed_seq,json=highestFullyCompletedSeq" json:"highest_fully_completed_seq,omitempty"`
@@ -464,61 +494,64 @@ func init() {
 func init() { proto.RegisterFile("trillian.proto", fileDescriptor1) }
 
 var fileDescriptor1 = []byte{
-	// 881 bytes of a gzipped FileDescriptorProto
+	// 932 bytes of a gzipped FileDescriptorProto
 	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xac, 0x55, 0xdd, 0x6e, 0xdb, 0x36,
-	0x14, 0xae, 0x6c, 0xd7, 0xb1, 0x8f, 0x1d, 0x47, 0x63, 0x97, 0x4c, 0x4d, 0x8b, 0x2d, 0xf3, 0x06,
-	0x2c, 0xcb, 0x45, 0x0c, 0x24, 0x45, 0x87, 0x61, 0xd8, 0x85, 0x67, 0x2b, 0x8d, 0x51, 0xff, 0x41,
-	0x52, 0x57, 0xb4, 0x37, 0x04, 0x23, 0x71, 0x32, 0x01, 0xc9, 0x64, 0x25, 0x7a, 0x80, 0xfa, 0x0c,
-	0x7b, 0xa2, 0x3d, 0xcf, 0xde, 0x62, 0x18, 0x30, 0x90, 0xfa, 0xb1, 0xdd, 0x76, 0x43, 0x31, 0xec,
-	0xc6, 0x20, 0xbf, 0xf3, 0x7d, 0x9f, 0xce, 0xe1, 0x39, 0xa4, 0xa1, 0x27, 0x13, 0x16, 0x45, 0x8c,
-	0xac, 0x2f, 0x45, 0xc2, 0x25, 0x47, 0xad, 0x72, 0x7f, 0x7a, 0x1d, 0x32, 0xb9, 0xda, 0xdc, 0x5d,
-	0xfa, 0x3c, 0x1e, 0x84, 0x9c, 0x87, 0x11, 0x1d, 0x94, 0xb1, 0x81, 0x9f, 0x64, 0x42, 0xf2, 0x41,
-	0xca, 0x42, 0x71, 0x97, 0xff, 0xe6, 0xf2, 0xfe, 0x5f, 0x0d, 0x68, 0x78, 0x09, 0xa5, 0xe8, 0x33,
-	0x38, 0x90, 0x09, 0xa5, 0x98, 0x05, 0x96, 0x71, 0x66, 0x9c, 0xd7, 0x9d, 0xa6, 0xda, 0x4e, 0x02,
-	0x74, 0x05, 0xa0, 0x03, 0xa9, 0x24, 0x92, 0x5a, 0xb5, 0x33, 0xe3, 0xbc, 0x77, 0xf5, 0xe0, 0xb2,
-	0xca, 0x42, 0x89, 0x5d, 0x15, 0x72, 0xda, 0xb2, 0x5c, 0xa2, 0x01, 0xe8, 0x0d, 0x96, 0x99, 0xa0,
-	0x56, 0x5d, 0x4b, 0xd0, 0xbe, 0xc4, 0xcb, 0x04, 0x75, 0x5a, 0xb2, 0x58, 0xa1, 0x1f, 0xe0, 0x70,
-	0x45, 0xd2, 0x15, 0x4e, 0x65, 0x42, 0x24, 0x0d, 0x33, 0xab, 0xa1, 0x45, 0x27, 0x5b, 0xd1, 0x2d,
-	0x49, 0x57, 0x6e, 0x11, 0x75, 0xba, 0xab, 0x9d, 0x1d, 0x7a, 0x0e, 0x3d, 0x2d, 0x26, 0x51, 0xc8,
-	0x13, 0x26, 0x57, 0xb1, 0x75, 0x5f, 0xab, 0xbf, 0xbe, 0xcc, 0x2b, 0x1d, 0xb3, 0x90, 0x49, 0x12,
-	0x45, 0x99, 0xcb, 0xc2, 0x35, 0x0d, 0xb4, 0xd5, 0xb0, 0xe4, 0x3a, 0xfa, 0xc3, 0xd5, 0x16, 0xbd,
-	0x86, 0x07, 0x29, 0x0b, 0xd7, 0x44, 0x6e, 0x12, 0xba, 0xe3, 0xd8, 0xd4, 0x8e, 0xdf, 0xfe, 0x83,
-	0xa3, 0x5b, 0x2a, 0xb6, 0xb6, 0x28, 0x7d, 0x0f, 0x43, 0x63, 0x30, 0x83, 0x8d, 0x88, 0x98, 0x4f,
-	0x24, 0xc5, 0x82, 0x47, 0xcc, 0xcf, 0xac, 0x03, 0x6d, 0xfc, 0x70, 0x5b, 0xe8, 0xb8, 0x64, 0x2c,
-	0x35, 0xc1, 0x39, 0x0a, 0xf6, 0x01, 0xf4, 0x25, 0x74, 0x03, 0x96, 0x8a, 0x88, 0x64, 0x78, 0x4d,
-	0x62, 0x6a, 0xb5, 0xce, 0x8c, 0xf3, 0xb6, 0xd3, 0x29, 0xb0, 0x39, 0x89, 0x29, 0x3a, 0x83, 0x4e,
-	0x40, 0x53, 0x3f, 0x61, 0x42, 0x32, 0xbe, 0xb6, 0xda, 0x05, 0x63, 0x0b, 0xa1, 0x9f, 0xe0, 0x73,
-	0x3f, 0xa1, 0x2a, 0x0f, 0xc9, 0x62, 0x8a, 0x63, 0xf5, 0xf1, 0x14, 0xa7, 0x6c, 0xed, 0x53, 0x4c,
-	0x05, 0xf7, 0x57, 0x16, 0xe8, 0x29, 0x38, 0xcd, 0x59, 0x1e, 0x8b, 0xe9, 0x4c, 0x73, 0x5c, 0x45,
-	0xb1, 0x15, 0x43, 0x79, 0x6c, 0x44, 0xf0, 0x6f, 0x1e, 0x9d, 0xdc, 0x23, 0x67, 0x7d, 0xc8, 0xa3,
-	0xff, 0x9b, 0x01, 0x9f, 0xe6, 0x87, 0x68, 0xaf, 0x65, 0x92, 0x29, 0x4e, 0x2a, 0x49, 0x2c, 0xd0,
-	0x37, 0x70, 0x24, 0xcb, 0x0d, 0x5e, 0x93, 0x35, 0x4f, 0x8b, 0xb9, 0xec, 0x55, 0xf0, 0x5c, 0xa1,
-	0xe8, 0x18, 0x9a, 0x11, 0x0f, 0xd5, 0xdc, 0xd6, 0x74, 0xfc, 0x7e, 0xc4, 0xc3, 0x49, 0x80, 0x9e,
-	0x40, 0xbb, 0xea, 0x80, 0x1e, 0xc1, 0xce, 0xd5, 0xc9, 0x87, 0xbb, 0xe7, 0x6c, 0x89, 0xfd, 0x3f,
-	0x0c, 0x38, 0xcc, 0xd1, 0x29, 0x0f, 0x1d, 0xce, 0xe5, 0xc7, 0xe7, 0xf1, 0x08, 0xda, 0x09, 0xe7,
-	0x12, 0xab, 0x71, 0xd2, 0xa9, 0x74, 0x9d, 0x96, 0x02, 0xd4, 0xb4, 0xa9, 0x60, 0x7e, 0x89, 0xd8,
-	0xdb, 0x3c, 0x9b, 0x7a, 0x3e, 0xfc, 0x2e, 0x7b, 0x4b, 0xf7, 0x53, 0x6d, 0x7c, 0x64, 0xaa, 0x3b,
-	0x75, 0xdf, 0xdf, 0xad, 0xfb, 0x2b, 0x38, 0xd4, 0x5f, 0x4a, 0xe8, 0xaf, 0x2c, 0x55, 0xcd, 0x6f,
-	0xea, 0x68, 0x57, 0x81, 0x4e, 0x81, 0xf5, 0x7f, 0x37, 0xa0, 0x37, 0x23, 0x42, 0xd0, 0x64, 0x46,
-	0x25, 0x09, 0x88, 0x24, 0xa8, 0x0f, 0x87, 0x29, 0xdf, 0x24, 0x3e, 0xc5, 0x85, 0xab, 0xa1, 0x4b,
-	0xe8, 0xe4, 0xe0, 0x54, 0x7b, 0xff, 0x08, 0x8f, 0x56, 0x2c, 0x5c, 0xd1, 0x54, 0xe2, 0x5f, 0x36,
-	0x51, 0x94, 0x61, 0x9f, 0xc7, 0x22, 0xa2, 0x92, 0x06, 0x38, 0xa5, 0x6f, 0x8a, 0xf3, 0xb7, 0x0a,
-	0xca, 0x8d, 0x62, 0x8c, 0x4a, 0x82, 0x4b, 0xdf, 0x20, 0x1b, 0xbe, 0x28, 0xe5, 0x82, 0x24, 0x92,
-	0x91, 0xf7, 0x2d, 0xf2, 0xa3, 0x79, 0x5c, 0xd0, 0x96, 0x25, 0x6b, 0xd7, 0xa6, 0xff, 0x67, 0xd5,
-	0xa3, 0x19, 0x11, 0xff, 0x63, 0x8f, 0x9e, 0x40, 0x2b, 0x2e, 0x4e, 0xa3, 0x18, 0x18, 0x6b, 0x7b,
-	0x2b, 0xf7, 0x4f, 0xcb, 0xa9, 0x98, 0xff, 0xbd, 0x79, 0x31, 0x11, 0x3b, 0xcd, 0x8b, 0x89, 0x98,
-	0x04, 0xea, 0x6a, 0x2b, 0xf8, 0x9d, 0xde, 0x75, 0x62, 0x22, 0xca, 0xd6, 0x5d, 0x7c, 0x07, 0xdd,
-	0xdd, 0xa7, 0x10, 0x3d, 0x84, 0xe3, 0x17, 0xf3, 0xe7, 0xf3, 0xc5, 0xcb, 0x39, 0xbe, 0x1d, 0xba,
-	0xb7, 0xd8, 0xf5, 0x9c, 0xa1, 0x67, 0x3f, 0x7b, 0x65, 0xde, 0x43, 0x5d, 0x68, 0x39, 0x37, 0x23,
-	0xfc, 0xf4, 0xfb, 0xa7, 0x57, 0xa6, 0x71, 0x81, 0xa1, 0x5d, 0xbd, 0xd5, 0xe8, 0x04, 0x50, 0xa9,
-	0xf2, 0x1c, 0xdb, 0xc6, 0xae, 0x37, 0xf4, 0x6c, 0xf3, 0x1e, 0x02, 0x68, 0x0e, 0x47, 0xde, 0xe4,
-	0x67, 0xdb, 0x34, 0xd4, 0xfa, 0xc6, 0x59, 0xbc, 0xb6, 0xe7, 0x66, 0x0d, 0x99, 0xd0, 0x75, 0x17,
-	0x37, 0x1e, 0x1e, 0xdb, 0x53, 0xdb, 0xb3, 0xc7, 0x66, 0x5d, 0x21, 0xb7, 0x43, 0x67, 0x5c, 0x21,
-	0x8d, 0x8b, 0x6b, 0x68, 0x95, 0x2f, 0x3b, 0x3a, 0x86, 0x4f, 0xf6, 0xfc, 0xbd, 0x57, 0x4b, 0x65,
-	0x7f, 0x00, 0xf5, 0xe9, 0xe2, 0x99, 0x69, 0xa8, 0xc5, 0x6c, 0xb8, 0x34, 0x6b, 0x17, 0x3e, 0x1c,
-	0xbd, 0xf3, 0xe0, 0xa1, 0xc7, 0x60, 0x95, 0xda, 0xf1, 0x8b, 0xe5, 0x74, 0x32, 0x1a, 0x7a, 0x36,
-	0x5e, 0x2e, 0xa6, 0x93, 0x91, 0x2a, 0xea, 0x14, 0x4e, 0x2a, 0xd4, 0xc5, 0xf3, 0x85, 0x87, 0x87,
-	0xd3, 0xe9, 0xe2, 0xa5, 0x3d, 0x36, 0x0d, 0x55, 0xd5, 0x4e, 0xac, 0xc4, 0x6b, 0x77, 0x4d, 0xfd,
-	0x5f, 0x77, 0xfd, 0x77, 0x00, 0x00, 0x00, 0xff, 0xff, 0x1d, 0x3f, 0x34, 0x38, 0x3c, 0x07, 0x00,
-	0x00,
+	0x14, 0xae, 0xec, 0xc4, 0xb1, 0x8f, 0x9d, 0x44, 0x63, 0x97, 0x54, 0x4d, 0x8b, 0x2d, 0xcb, 0x06,
+	0x2c, 0xcb, 0x45, 0x02, 0x24, 0x5d, 0x80, 0x61, 0xd8, 0x85, 0x16, 0x2b, 0x8d, 0x51, 0xff, 0x41,
+	0x52, 0x57, 0xb4, 0x18, 0xc0, 0x31, 0x12, 0x27, 0x13, 0x95, 0x4c, 0x56, 0xa2, 0x07, 0xa8, 0xcf,
+	0xb0, 0x27, 0xda, 0xab, 0xec, 0x51, 0x76, 0x33, 0x90, 0x92, 0x6c, 0x39, 0xed, 0x86, 0x6e, 0xe8,
+	0x8d, 0x40, 0x7e, 0xe7, 0xfb, 0x3e, 0x9e, 0xc3, 0x43, 0x8a, 0xb0, 0x23, 0x53, 0x16, 0xc7, 0x8c,
+	0xcc, 0x4f, 0x45, 0xca, 0x25, 0x47, 0xed, 0x6a, 0x7e, 0x70, 0x11, 0x31, 0x39, 0x5b, 0xdc, 0x9e,
+	0x06, 0x3c, 0x39, 0x8b, 0x38, 0x8f, 0x62, 0x7a, 0x56, 0xc5, 0xce, 0x82, 0x34, 0x17, 0x92, 0x9f,
+	0x65, 0x2c, 0x12, 0xb7, 0xc5, 0xb7, 0x90, 0x1f, 0xfd, 0xb9, 0x09, 0x1b, 0x7e, 0x4a, 0x29, 0x7a,
+	0x00, 0x5b, 0x32, 0xa5, 0x14, 0xb3, 0xd0, 0x32, 0x0e, 0x8d, 0xe3, 0xa6, 0xdb, 0x52, 0xd3, 0x41,
+	0x88, 0xce, 0x01, 0x74, 0x20, 0x93, 0x44, 0x52, 0xab, 0x71, 0x68, 0x1c, 0xef, 0x9c, 0xdf, 0x3f,
+	0x5d, 0x66, 0xa1, 0xc4, 0x9e, 0x0a, 0xb9, 0x1d, 0x59, 0x0d, 0xd1, 0x19, 0xe8, 0x09, 0x96, 0xb9,
+	0xa0, 0x56, 0x53, 0x4b, 0xd0, 0xba, 0xc4, 0xcf, 0x05, 0x75, 0xdb, 0xb2, 0x1c, 0xa1, 0xef, 0x61,
+	0x7b, 0x46, 0xb2, 0x19, 0xce, 0x64, 0x4a, 0x24, 0x8d, 0x72, 0x6b, 0x43, 0x8b, 0xf6, 0x57, 0xa2,
+	0x1b, 0x92, 0xcd, 0xbc, 0x32, 0xea, 0xf6, 0x66, 0xb5, 0x19, 0x7a, 0x06, 0x3b, 0x5a, 0x4c, 0xe2,
+	0x88, 0xa7, 0x4c, 0xce, 0x12, 0x6b, 0x53, 0xab, 0xbf, 0x3a, 0x2d, 0x2a, 0xed, 0xb3, 0x88, 0x49,
+	0x12, 0xc7, 0xb9, 0xc7, 0xa2, 0x39, 0x0d, 0xb5, 0x95, 0x5d, 0x71, 0xdd, 0xed, 0x59, 0x7d, 0x8a,
+	0x5e, 0xc1, 0xfd, 0x8c, 0x45, 0x73, 0x22, 0x17, 0x29, 0xad, 0x39, 0xb6, 0xb4, 0xe3, 0x37, 0xff,
+	0xe0, 0xe8, 0x55, 0x8a, 0x95, 0x2d, 0xca, 0xde, 0xc1, 0x50, 0x1f, 0xcc, 0x70, 0x21, 0x62, 0x16,
+	0x10, 0x49, 0xb1, 0xe0, 0x31, 0x0b, 0x72, 0x6b, 0x4b, 0x1b, 0x3f, 0x5c, 0x15, 0xda, 0xaf, 0x18,
+	0x53, 0x4d, 0x70, 0x77, 0xc3, 0x75, 0x00, 0xfd, 0x0c, 0x0f, 0x58, 0x48, 0xe7, 0x92, 0xc9, 0x1c,
+	0xdf, 0xa9, 0xbb, 0xf7, 0x1f, 0xea, 0xde, 0xab, 0x4c, 0xd6, 0x60, 0xf4, 0x05, 0xf4, 0x42, 0x96,
+	0x89, 0x98, 0xe4, 0x78, 0x4e, 0x12, 0x6a, 0xb5, 0x0f, 0x8d, 0xe3, 0x8e, 0xdb, 0x2d, 0xb1, 0x31,
+	0x49, 0x28, 0x3a, 0x84, 0x6e, 0x48, 0xb3, 0x20, 0x65, 0x42, 0x32, 0x3e, 0xb7, 0x3a, 0x25, 0x63,
+	0x05, 0xa1, 0x1f, 0xe1, 0xb3, 0x20, 0xa5, 0xaa, 0x4a, 0xc9, 0x12, 0x8a, 0x13, 0x55, 0x5a, 0x86,
+	0x33, 0x36, 0x0f, 0x28, 0xa6, 0x82, 0x07, 0x33, 0x0b, 0xf4, 0x19, 0x3b, 0x28, 0x58, 0x3e, 0x4b,
+	0xe8, 0x48, 0x73, 0x3c, 0x45, 0x71, 0x14, 0x43, 0x79, 0x2c, 0x44, 0xf8, 0x6f, 0x1e, 0xdd, 0xc2,
+	0xa3, 0x60, 0xbd, 0xcf, 0xe3, 0xe8, 0x77, 0x03, 0x3e, 0x2d, 0x8a, 0x77, 0xe6, 0x32, 0xcd, 0x15,
+	0x27, 0x93, 0x24, 0x11, 0xe8, 0x6b, 0xd8, 0x95, 0xd5, 0x04, 0xcf, 0xc9, 0x9c, 0x67, 0xe5, 0xa9,
+	0xdf, 0x59, 0xc2, 0x63, 0x85, 0xa2, 0x3d, 0x68, 0xc5, 0x3c, 0x52, 0xb7, 0xa2, 0xa1, 0xe3, 0x9b,
+	0x31, 0x8f, 0x06, 0x21, 0x7a, 0x02, 0x9d, 0x65, 0x7f, 0xf5, 0x01, 0xef, 0x9e, 0xef, 0xbf, 0x7f,
+	0xd7, 0xdd, 0x15, 0xf1, 0xe8, 0x2f, 0x03, 0xb6, 0x0b, 0x74, 0xc8, 0x23, 0x97, 0x73, 0xf9, 0xe1,
+	0x79, 0x3c, 0x82, 0x4e, 0xca, 0xb9, 0xd4, 0x0d, 0xd7, 0xa9, 0xf4, 0xdc, 0xb6, 0x02, 0x54, 0xf3,
+	0x54, 0xb0, 0xb8, 0xa2, 0xec, 0x6d, 0x91, 0x4d, 0xb3, 0xb8, 0x5a, 0x1e, 0x7b, 0x4b, 0xd7, 0x53,
+	0xdd, 0xf8, 0xc0, 0x54, 0x6b, 0x75, 0x6f, 0xd6, 0xeb, 0xfe, 0x12, 0xb6, 0xf5, 0x4a, 0x29, 0xfd,
+	0x8d, 0x65, 0xaa, 0xf9, 0x2d, 0x1d, 0xed, 0x29, 0xd0, 0x2d, 0x31, 0xa5, 0x7d, 0x4d, 0x73, 0xa5,
+	0xdd, 0xd2, 0x89, 0x6e, 0xbe, 0xa6, 0xf9, 0x20, 0x3c, 0xfa, 0xc3, 0x80, 0x9d, 0x11, 0x11, 0x82,
+	0xa6, 0x23, 0x2a, 0x49, 0x48, 0x24, 0x41, 0x47, 0xb0, 0x9d, 0xf1, 0x45, 0x1a, 0x50, 0x5c, 0x2e,
+	0x66, 0x68, 0x41, 0xb7, 0x00, 0x87, 0x7a, 0xc9, 0x1f, 0xe0, 0xd1, 0x8c, 0x45, 0x33, 0x9a, 0x49,
+	0xfc, 0xeb, 0x22, 0x8e, 0x73, 0x1c, 0xf0, 0x44, 0xc4, 0x54, 0xd2, 0x10, 0x67, 0xf4, 0x4d, 0xd9,
+	0x16, 0xab, 0xa4, 0x5c, 0x2b, 0xc6, 0x55, 0x45, 0xf0, 0xe8, 0x1b, 0xe4, 0xc0, 0xe7, 0x95, 0x5c,
+	0x90, 0x54, 0x32, 0xf2, 0xae, 0x45, 0xb1, 0x63, 0x8f, 0x4b, 0xda, 0xb4, 0x62, 0xd5, 0x6d, 0x6a,
+	0xad, 0x1b, 0x11, 0xf1, 0x11, 0x5b, 0xf7, 0x04, 0xda, 0x49, 0xb9, 0x1b, 0xe5, 0x39, 0xb2, 0x56,
+	0xbf, 0x82, 0xf5, 0xdd, 0x72, 0x97, 0xcc, 0xff, 0xdf, 0xd3, 0x84, 0x88, 0x5a, 0x4f, 0x13, 0x22,
+	0x06, 0xa1, 0xba, 0xf1, 0x0a, 0xbe, 0xd3, 0xd2, 0x6e, 0x42, 0x44, 0xd5, 0xd1, 0x93, 0x5f, 0xa0,
+	0x57, 0xff, 0xff, 0xa2, 0x87, 0xb0, 0xf7, 0x7c, 0xfc, 0x6c, 0x3c, 0x79, 0x31, 0xc6, 0x37, 0xb6,
+	0x77, 0x83, 0x3d, 0xdf, 0xb5, 0x7d, 0xe7, 0xe9, 0x4b, 0xf3, 0x1e, 0xea, 0x41, 0xdb, 0xbd, 0xbe,
+	0xc2, 0x97, 0xdf, 0x5d, 0x9e, 0x9b, 0x06, 0x32, 0xa1, 0x37, 0x1d, 0xda, 0x83, 0x31, 0xf6, 0x6e,
+	0xec, 0xf3, 0x6f, 0x2f, 0xcd, 0x06, 0xda, 0x83, 0x4f, 0xaa, 0xb8, 0x02, 0x2f, 0xb0, 0x82, 0x9b,
+	0x27, 0x18, 0x3a, 0xcb, 0x97, 0x04, 0xed, 0x03, 0xaa, 0xec, 0x7d, 0xd7, 0x71, 0xb0, 0xe7, 0xdb,
+	0xbe, 0x63, 0xde, 0x43, 0x00, 0x2d, 0xfb, 0xca, 0x1f, 0xfc, 0xe4, 0x98, 0x86, 0x1a, 0x5f, 0xbb,
+	0x93, 0x57, 0xce, 0xd8, 0x6c, 0xa8, 0x55, 0xbc, 0xc9, 0xb5, 0x8f, 0xfb, 0xce, 0xd0, 0xf1, 0x9d,
+	0xbe, 0xd9, 0x54, 0xc8, 0x8d, 0xed, 0xf6, 0x97, 0xc8, 0xc6, 0xc9, 0x05, 0xb4, 0xab, 0x77, 0x47,
+	0xe5, 0xb0, 0xe6, 0xef, 0xbf, 0x9c, 0x2a, 0xfb, 0x2d, 0x68, 0x0e, 0x27, 0x4f, 0x4d, 0x43, 0x0d,
+	0x46, 0xf6, 0xd4, 0x6c, 0x9c, 0x04, 0xb0, 0x7b, 0xe7, 0x77, 0x8c, 0x1e, 0x83, 0x55, 0x69, 0xfb,
+	0xcf, 0xa7, 0xc3, 0xc1, 0x95, 0xed, 0x3b, 0x78, 0x3a, 0x19, 0x0e, 0xae, 0x54, 0xf5, 0x07, 0xb0,
+	0xbf, 0x44, 0x3d, 0x3c, 0x9e, 0xf8, 0xd8, 0x1e, 0x0e, 0x27, 0x2f, 0x9c, 0xbe, 0x69, 0xa8, 0xaa,
+	0x6a, 0xb1, 0x0a, 0x6f, 0xdc, 0xb6, 0xf4, 0x4b, 0x7c, 0xf1, 0xf7, 0x00, 0xb8, 0x06, 0xad, 0x83,
+	0xda, 0x07, 0x00, 0x00,
 }
@@ -15,6 +15,7 @@ It has these top-level messages:
 	Proof
 	QueueLeavesRequest
 	QueueLeafRequest
+	QueueLeafResponse
 	QueueLeavesResponse
 	GetInclusionProofRequest
 	GetInclusionProofResponse
@@ -104,6 +105,12 @@ type LogLeaf struct {
 	// personality which fetches and submits the entries might set
 	// leaf_identity_hash to H(seq||certdata).
 	LeafIdentityHash []byte `protobuf:"bytes,5,opt,name=leaf_identity_hash,json=leafIdentityHash,proto3" json:"leaf_identity_hash,omitempty"`
+	// queue_timestamp_nanos is the time at which the leaf was queued. It is
+	// set by Trillian and ignored on input.
+	QueueTimestampNanos int64 `protobuf:"varint,6,opt,name=queue_timestamp_nanos,json=queueTimestampNanos" json:"queue_timestamp_nanos,omitempty"`
+	// integrate_timestamp_nanos is the time at which the leaf was sequenced
+	// into the tree. It is set by Trillian and ignored on input.
+	IntegrateTimestampNanos int64 `protobuf:"varint,7,opt,name=integrate_timestamp_nanos,json=integrateTimestampNanos" json:"integrate_timestamp_nanos,omitempty"`
 }
 
 func (m *LogLeaf) Reset()                    { *m = LogLeaf{} }
@@ -146,6 +153,20 @@ func (m *LogLeaf) GetLeafIdentityHash() []byte {
 	return nil
 }
 
+func (m *LogLeaf) GetQueueTimestampNanos() int64 {
+	if m != nil {
+		return m.QueueTimestampNanos
+	}
+	return 0
+}
+
+func (m *LogLeaf) GetIntegrateTimestampNanos() int64 {
+	if m != nil {
+		return m.IntegrateTimestampNanos
+	}
+	return 0
+}
+
 type Node struct {
 	// TODO(Martin2112): remove node_id and node_revision
 	NodeId       []byte `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
@@ -230,6 +251,10 @@ func (m *QueueLeavesRequest) GetLeaves() []*LogLeaf {
 type QueueLeafRequest struct {
 	LogId int64    `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
 	Leaf  *LogLeaf `protobuf:"bytes,2,opt,name=leaf" json:"leaf,omitempty"`
+	// dedup_token is optional. If set, the leaf is queued at most once for each token:
+	// repeating the request with the same token succeeds without queueing the leaf
+	// again, even if the leaf has changed. Clients can use it to retry submissions.
+	DedupToken string `protobuf:"bytes,3,opt,name=dedup_token,json=dedupToken" json:"dedup_token,omitempty"`
 }
 
 func (m *QueueLeafRequest) Reset()                    { *m = QueueLeafRequest{} }
@@ -251,6 +276,32 @@ func (m *QueueLeafRequest) GetLeaf() *LogLeaf {
 	return nil
 }
 
+func (m *QueueLeafRequest) GetDedupToken() string {
+	if m != nil {
+		return m.DedupToken
+	}
+	return ""
+}
+
+type QueueLeafResponse struct {
+	// queued_leaf is the leaf queued by the request, with a leaf_index of -1. If a leaf
+	// was already queued with the request's dedup_token it is that leaf instead, with the
+	// index it was sequenced at, or -1 if it has not been sequenced yet.
+	QueuedLeaf *LogLeaf `protobuf:"bytes,1,opt,name=queued_leaf,json=queuedLeaf" json:"queued_leaf,omitempty"`
+}
+
+func (m *QueueLeafResponse) Reset()                    { *m = QueueLeafResponse{} }
+func (m *QueueLeafResponse) String() string            { return proto.CompactTextString(m) }
+func (*QueueLeafResponse) ProtoMessage()               {}
+func (*QueueLeafResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *QueueLeafResponse) GetQueuedLeaf() *LogLeaf {
+	if m != nil {
+		return m.QueuedLeaf
+	}
+	return nil
+}
+
 // TODO(Martin2112): This will eventually contain the signed timestamps and stuff that we return for
 // the queued leaves
 type QueueLeavesResponse struct {
@@ -259,7 +310,7 @@ type QueueLeavesResponse struct {
 func (m *QueueLeavesResponse) Reset()                    { *m = QueueLeavesResponse{} }
 func (m *QueueLeavesResponse) String() string            { return proto.CompactTextString(m) }
 func (*QueueLeavesResponse) ProtoMessage()               {}
-func (*QueueLeavesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*QueueLeavesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
 
 type GetInclusionProofRequest struct {
 	LogId     int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
@@ -270,7 +321,7 @@ type GetInclusionProofRequest struct {
 func (m *GetInclusionProofRequest) Reset()                    { *m = GetInclusionProofRequest{} }
 func (m *GetInclusionProofRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetInclusionProofRequest) ProtoMessage()               {}
-func (*GetInclusionProofRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+func (*GetInclusionProofRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
 
 func (m *GetInclusionProofRequest) GetLogId() int64 {
 	if m != nil {
@@ -300,7 +351,7 @@ type GetInclusionProofResponse struct {
 func (m *GetInclusionProofResponse) Reset()                    { *m = GetInclusionProofResponse{} }
 func (m *GetInclusionProofResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetInclusionProofResponse) ProtoMessage()               {}
-func (*GetInclusionProofResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+func (*GetInclusionProofResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
 
 func (m *GetInclusionProofResponse) GetProof() *Proof {
 	if m != nil {
@@ -319,7 +370,7 @@ type GetInclusionProofByHashRequest struct {
 func (m *GetInclusionProofByHashRequest) Reset()                    { *m = GetInclusionProofByHashRequest{} }
 func (m *GetInclusionProofByHashRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetInclusionProofByHashRequest) ProtoMessage()               {}
-func (*GetInclusionProofByHashRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+func (*GetInclusionProofByHashRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
 
 func (m *GetInclusionProofByHashRequest) GetLogId() int64 {
 	if m != nil {
@@ -356,10 +407,12 @@ type GetInclusionProofByHashResponse struct {
 	Proof []*Proof `protobuf:"bytes,2,rep,name=proof" json:"proof,omitempty"`
 }
 
-func (m *GetInclusionProofByHashResponse) Reset()                    { *m = GetInclusionProofByHashResponse{} }
-func (m *GetInclusionProofByHashResponse) String() string            { return proto.CompactTextString(m) }
-func (*GetInclusionProofByHashResponse) ProtoMessage()               {}
-func (*GetInclusionProofByHashResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+func (m *GetInclusionProofByHashResponse) Reset()         { *m = GetInclusionProofByHashResponse{} }
+func (m *GetInclusionProofByHashResponse) String() string { return proto.CompactTextString(m) }
+func (*GetInclusionProofByHashResponse) ProtoMessage()    {}
+func (*GetInclusionProofByHashResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{10}
+}
 
 func (m *GetInclusionProofByHashResponse) GetProof() []*Proof {
 	if m != nil {
@@ -377,7 +430,7 @@ type GetConsistencyProofRequest struct {
 func (m *GetConsistencyProofRequest) Reset()                    { *m = GetConsistencyProofRequest{} }
 func (m *GetConsistencyProofRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetConsistencyProofRequest) ProtoMessage()               {}
-func (*GetConsistencyProofRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+func (*GetConsistencyProofRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
 
 func (m *GetConsistencyProofRequest) GetLogId() int64 {
 	if m != nil {
@@ -407,7 +460,7 @@ type GetConsistencyProofResponse struct {
 func (m *GetConsistencyProofResponse) Reset()                    { *m = GetConsistencyProofResponse{} }
 func (m *GetConsistencyProofResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetConsistencyProofResponse) ProtoMessage()               {}
-func (*GetConsistencyProofResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+func (*GetConsistencyProofResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
 
 func (m *GetConsistencyProofResponse) GetProof() *Proof {
 	if m != nil {
@@ -425,7 +478,7 @@ type GetLeavesByHashRequest struct {
 func (m *GetLeavesByHashRequest) Reset()                    { *m = GetLeavesByHashRequest{} }
 func (m *GetLeavesByHashRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetLeavesByHashRequest) ProtoMessage()               {}
-func (*GetLeavesByHashRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+func (*GetLeavesByHashRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
 
 func (m *GetLeavesByHashRequest) GetLogId() int64 {
 	if m != nil {
@@ -456,7 +509,7 @@ type GetLeavesByHashResponse struct {
 func (m *GetLeavesByHashResponse) Reset()                    { *m = GetLeavesByHashResponse{} }
 func (m *GetLeavesByHashResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetLeavesByHashResponse) ProtoMessage()               {}
-func (*GetLeavesByHashResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+func (*GetLeavesByHashResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
 
 func (m *GetLeavesByHashResponse) GetLeaves() []*LogLeaf {
 	if m != nil {
@@ -473,7 +526,7 @@ type GetLeavesByIndexRequest struct {
 func (m *GetLeavesByIndexRequest) Reset()                    { *m = GetLeavesByIndexRequest{} }
 func (m *GetLeavesByIndexRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetLeavesByIndexRequest) ProtoMessage()               {}
-func (*GetLeavesByIndexRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+func (*GetLeavesByIndexRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
 
 func (m *GetLeavesByIndexRequest) GetLogId() int64 {
 	if m != nil {
@@ -497,7 +550,7 @@ type GetLeavesByIndexResponse struct {
 func (m *GetLeavesByIndexResponse) Reset()                    { *m = GetLeavesByIndexResponse{} }
 func (m *GetLeavesByIndexResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetLeavesByIndexResponse) ProtoMessage()               {}
-func (*GetLeavesByIndexResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+func (*GetLeavesByIndexResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
 
 func (m *GetLeavesByIndexResponse) GetLeaves() []*LogLeaf {
 	if m != nil {
@@ -513,7 +566,7 @@ type GetSequencedLeafCountRequest struct {
 func (m *GetSequencedLeafCountRequest) Reset()                    { *m = GetSequencedLeafCountRequest{} }
 func (m *GetSequencedLeafCountRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetSequencedLeafCountRequest) ProtoMessage()               {}
-func (*GetSequencedLeafCountRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+func (*GetSequencedLeafCountRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
 
 func (m *GetSequencedLeafCountRequest) GetLogId() int64 {
 	if m != nil {
@@ -529,7 +582,7 @@ type GetSequencedLeafCountResponse struct {
 func (m *GetSequencedLeafCountResponse) Reset()                    { *m = GetSequencedLeafCountResponse{} }
 func (m *GetSequencedLeafCountResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetSequencedLeafCountResponse) ProtoMessage()               {}
-func (*GetSequencedLeafCountResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+func (*GetSequencedLeafCountResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
 
 func (m *GetSequencedLeafCountResponse) GetLeafCount() int64 {
 	if m != nil {
@@ -545,7 +598,7 @@ type GetLatestSignedLogRootRequest struct {
 func (m *GetLatestSignedLogRootRequest) Reset()                    { *m = GetLatestSignedLogRootRequest{} }
 func (m *GetLatestSignedLogRootRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetLatestSignedLogRootRequest) ProtoMessage()               {}
-func (*GetLatestSignedLogRootRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+func (*GetLatestSignedLogRootRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
 
 func (m *GetLatestSignedLogRootRequest) GetLogId() int64 {
 	if m != nil {
@@ -558,10 +611,12 @@ type GetLatestSignedLogRootResponse struct {
 	SignedLogRoot *SignedLogRoot `protobuf:"bytes,2,opt,name=signed_log_root,json=signedLogRoot" json:"signed_log_root,omitempty"`
 }
 
-func (m *GetLatestSignedLogRootResponse) Reset()                    { *m = GetLatestSignedLogRootResponse{} }
-func (m *GetLatestSignedLogRootResponse) String() string            { return proto.CompactTextString(m) }
-func (*GetLatestSignedLogRootResponse) ProtoMessage()               {}
-func (*GetLatestSignedLogRootResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+func (m *GetLatestSignedLogRootResponse) Reset()         { *m = GetLatestSignedLogRootResponse{} }
+func (m *GetLatestSignedLogRootResponse) String() string { return proto.CompactTextString(m) }
+func (*GetLatestSignedLogRootResponse) ProtoMessage()    {}
+func (*GetLatestSignedLogRootResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{20}
+}
 
 func (m *GetLatestSignedLogRootResponse) GetSignedLogRoot() *SignedLogRoot {
 	if m != nil {
@@ -579,7 +634,7 @@ type GetEntryAndProofRequest struct {
 func (m *GetEntryAndProofRequest) Reset()                    { *m = GetEntryAndProofRequest{} }
 func (m *GetEntryAndProofRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetEntryAndProofRequest) ProtoMessage()               {}
-func (*GetEntryAndProofRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+func (*GetEntryAndProofRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
 
 func (m *GetEntryAndProofRequest) GetLogId() int64 {
 	if m != nil {
@@ -610,7 +665,7 @@ type GetEntryAndProofResponse struct {
 func (m *GetEntryAndProofResponse) Reset()                    { *m = GetEntryAndProofResponse{} }
 func (m *GetEntryAndProofResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetEntryAndProofResponse) ProtoMessage()               {}
-func (*GetEntryAndProofResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+func (*GetEntryAndProofResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
 
 func (m *GetEntryAndProofResponse) GetProof() *Proof {
 	if m != nil {
@@ -642,7 +697,7 @@ type MapLeaf struct {
 func (m *MapLeaf) Reset()                    { *m = MapLeaf{} }
 func (m *MapLeaf) String() string            { return proto.CompactTextString(m) }
 func (*MapLeaf) ProtoMessage()               {}
-func (*MapLeaf) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+func (*MapLeaf) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
 
 func (m *MapLeaf) GetIndex() []byte {
 	if m != nil {
@@ -681,7 +736,7 @@ type IndexValue struct {
 func (m *IndexValue) Reset()                    { *m = IndexValue{} }
 func (m *IndexValue) String() string            { return proto.CompactTextString(m) }
 func (*IndexValue) ProtoMessage()               {}
-func (*IndexValue) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+func (*IndexValue) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
 
 func (m *IndexValue) GetIndex() []byte {
 	if m != nil {
@@ -705,7 +760,7 @@ type IndexValueInclusion struct {
 func (m *IndexValueInclusion) Reset()                    { *m = IndexValueInclusion{} }
 func (m *IndexValueInclusion) String() string            { return proto.CompactTextString(m) }
 func (*IndexValueInclusion) ProtoMessage()               {}
-func (*IndexValueInclusion) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+func (*IndexValueInclusion) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
 
 func (m *IndexValueInclusion) GetIndexValue() *IndexValue {
 	if m != nil {
@@ -730,7 +785,7 @@ type GetMapLeavesRequest struct {
 func (m *GetMapLeavesRequest) Reset()                    { *m = GetMapLeavesRequest{} }
 func (m *GetMapLeavesRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetMapLeavesRequest) ProtoMessage()               {}
-func (*GetMapLeavesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+func (*GetMapLeavesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
 
 func (m *GetMapLeavesRequest) GetMapId() int64 {
 	if m != nil {
@@ -762,7 +817,7 @@ type GetMapLeavesResponse struct {
 func (m *GetMapLeavesResponse) Reset()                    { *m = GetMapLeavesResponse{} }
 func (m *GetMapLeavesResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetMapLeavesResponse) ProtoMessage()               {}
-func (*GetMapLeavesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+func (*GetMapLeavesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
 
 func (m *GetMapLeavesResponse) GetIndexValueInclusion() []*IndexValueInclusion {
 	if m != nil {
@@ -787,7 +842,7 @@ type SetMapLeavesRequest struct {
 func (m *SetMapLeavesRequest) Reset()                    { *m = SetMapLeavesRequest{} }
 func (m *SetMapLeavesRequest) String() string            { return proto.CompactTextString(m) }
 func (*SetMapLeavesRequest) ProtoMessage()               {}
-func (*SetMapLeavesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+func (*SetMapLeavesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
 
 func (m *SetMapLeavesRequest) GetMapId() int64 {
 	if m != nil {
@@ -817,7 +872,7 @@ type SetMapLeavesResponse struct {
 func (m *SetMapLeavesResponse) Reset()                    { *m = SetMapLeavesResponse{} }
 func (m *SetMapLeavesResponse) String() string            { return proto.CompactTextString(m) }
 func (*SetMapLeavesResponse) ProtoMessage()               {}
-func (*SetMapLeavesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
+func (*SetMapLeavesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
 
 func (m *SetMapLeavesResponse) GetMapRoot() *SignedMapRoot {
 	if m != nil {
@@ -833,7 +888,7 @@ type GetSignedMapRootRequest struct {
 func (m *GetSignedMapRootRequest) Reset()                    { *m = GetSignedMapRootRequest{} }
 func (m *GetSignedMapRootRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetSignedMapRootRequest) ProtoMessage()               {}
-func (*GetSignedMapRootRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+func (*GetSignedMapRootRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
 
 func (m *GetSignedMapRootRequest) GetMapId() int64 {
 	if m != nil {
@@ -849,7 +904,7 @@ type GetSignedMapRootResponse struct {
 func (m *GetSignedMapRootResponse) Reset()                    { *m = GetSignedMapRootResponse{} }
 func (m *GetSignedMapRootResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetSignedMapRootResponse) ProtoMessage()               {}
-func (*GetSignedMapRootResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+func (*GetSignedMapRootResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
 
 func (m *GetSignedMapRootResponse) GetMapRoot() *SignedMapRoot {
 	if m != nil {
@@ -866,7 +921,7 @@ type ListTreesRequest struct {
 func (m *ListTreesRequest) Reset()                    { *m = ListTreesRequest{} }
 func (m *ListTreesRequest) String() string            { return proto.CompactTextString(m) }
 func (*ListTreesRequest) ProtoMessage()               {}
-func (*ListTreesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
+func (*ListTreesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
 
 // ListTrees response.
 // No pagination is provided, all trees the requester has access to are
@@ -879,7 +934,7 @@ type ListTreesResponse struct {
 func (m *ListTreesResponse) Reset()                    { *m = ListTreesResponse{} }
 func (m *ListTreesResponse) String() string            { return proto.CompactTextString(m) }
 func (*ListTreesResponse) ProtoMessage()               {}
-func (*ListTreesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
+func (*ListTreesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
 
 func (m *ListTreesResponse) GetTree() []*Tree {
 	if m != nil {
@@ -897,7 +952,7 @@ type GetTreeRequest struct {
 func (m *GetTreeRequest) Reset()                    { *m = GetTreeRequest{} }
 func (m *GetTreeRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetTreeRequest) ProtoMessage()               {}
-func (*GetTreeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
+func (*GetTreeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{34} }
 
 func (m *GetTreeRequest) GetTreeId() int64 {
 	if m != nil {
@@ -915,7 +970,7 @@ type CreateTreeRequest struct {
 func (m *CreateTreeRequest) Reset()                    { *m = CreateTreeRequest{} }
 func (m *CreateTreeRequest) String() string            { return proto.CompactTextString(m) }
 func (*CreateTreeRequest) ProtoMessage()               {}
-func (*CreateTreeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{34} }
+func (*CreateTreeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{35} }
 
 func (m *CreateTreeRequest) GetTree() *Tree {
 	if m != nil {
@@ -936,7 +991,7 @@ type UpdateTreeRequest struct {
 func (m *UpdateTreeRequest) Reset()                    { *m = UpdateTreeRequest{} }
 func (m *UpdateTreeRequest) String() string            { return proto.CompactTextString(m) }
 func (*UpdateTreeRequest) ProtoMessage()               {}
-func (*UpdateTreeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{35} }
+func (*UpdateTreeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{36} }
 
 func (m *UpdateTreeRequest) GetTree() *Tree {
 	if m != nil {
@@ -961,7 +1016,7 @@ type DeleteTreeRequest struct {
 func (m *DeleteTreeRequest) Reset()                    { *m = DeleteTreeRequest{} }
 func (m *DeleteTreeRequest) String() string            { return proto.CompactTextString(m) }
 func (*DeleteTreeRequest) ProtoMessage()               {}
-func (*DeleteTreeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{36} }
+func (*DeleteTreeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{37} }
 
 func (m *DeleteTreeRequest) GetTreeId() int64 {
 	if m != nil {
@@ -976,6 +1031,7 @@ func init() {
 	proto.RegisterType((*Proof)(nil), "trillian.Proof")
 	proto.RegisterType((*QueueLeavesRequest)(nil), "trillian.QueueLeavesRequest")
 	proto.RegisterType((*QueueLeafRequest)(nil), "trillian.QueueLeafRequest")
+	proto.RegisterType((*QueueLeafResponse)(nil), "trillian.QueueLeafResponse")
 	proto.RegisterType((*QueueLeavesResponse)(nil), "trillian.QueueLeavesResponse")
 	proto.RegisterType((*GetInclusionProofRequest)(nil), "trillian.GetInclusionProofRequest")
 	proto.RegisterType((*GetInclusionProofResponse)(nil), "trillian.GetInclusionProofResponse")
@@ -1022,7 +1078,7 @@ const _ = grpc.SupportPackageIsVersion4
 
 type TrillianLogClient interface {
 	// QueueLeaf adds a single leaf to the queue.
-	QueueLeaf(ctx context.Context, in *QueueLeafRequest, opts ...grpc.CallOption) (*google_protobuf.Empty, error)
+	QueueLeaf(ctx context.Context, in *QueueLeafRequest, opts ...grpc.CallOption) (*QueueLeafResponse, error)
 	// Corresponds to the LeafQueuer API
 	QueueLeaves(ctx context.Context, in *QueueLeavesRequest, opts ...grpc.CallOption) (*QueueLeavesResponse, error)
 	// No direct equivalent at the storage level
@@ -1046,8 +1102,8 @@ func NewTrillianLogClient(cc *grpc.ClientConn) TrillianLogClient {
 	return &trillianLogClient{cc}
 }
 
-func (c *trillianLogClient) QueueLeaf(ctx context.Context, in *QueueLeafRequest, opts ...grpc.CallOption) (*google_protobuf.Empty, error) {
-	out := new(google_protobuf.Empty)
+func (c *trillianLogClient) QueueLeaf(ctx context.Context, in *QueueLeafRequest, opts ...grpc.CallOption) (*QueueLeafResponse, error) {
+	out := new(QueueLeafResponse)
 	err := grpc.Invoke(ctx, "/trillian.TrillianLog/QueueLeaf", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
@@ -1140,7 +1196,7 @@ func (c *trillianLogClient) GetEntryAndProof(ctx context.Context, in *GetEntryAn
 
 type TrillianLogServer interface {
 	// QueueLeaf adds a single leaf to the queue.
-	QueueLeaf(context.Context, *QueueLeafRequest) (*google_protobuf.Empty, error)
+	QueueLeaf(context.Context, *QueueLeafRequest) (*QueueLeafResponse, error)
 	// Corresponds to the LeafQueuer API
 	QueueLeaves(context.Context, *QueueLeavesRequest) (*QueueLeavesResponse, error)
 	// No direct equivalent at the storage level
@@ -1742,95 +1798,100 @@ var _TrillianAdmin_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("trillian_api.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 1426 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xb4, 0x58, 0x5f, 0x6f, 0x13, 0x47,
-	0x10, 0x8f, 0xe3, 0x24, 0x8e, 0xc7, 0x24, 0x71, 0x36, 0x09, 0x31, 0x17, 0x42, 0xc3, 0x52, 0xc0,
-	0x54, 0x34, 0x54, 0xae, 0x68, 0x55, 0x21, 0xb5, 0x22, 0x09, 0x84, 0xb4, 0x0e, 0x0d, 0x67, 0x40,
-	0x95, 0x2a, 0x71, 0x5a, 0x72, 0x6b, 0xe7, 0xe0, 0x7c, 0x77, 0xbd, 0x5b, 0x23, 0x92, 0xf7, 0xf6,
-	0x5b, 0xb4, 0x1f, 0xa5, 0x6f, 0x7d, 0xec, 0x77, 0xaa, 0xf6, 0xcf, 0xfd, 0xf5, 0xda, 0x8e, 0x5b,
-	0xf5, 0xcd, 0x3b, 0x7f, 0x7f, 0x33, 0xb3, 0xb3, 0x33, 0x67, 0x40, 0x2c, 0x74, 0x5c, 0xd7, 0x21,
-	0x9e, 0x45, 0x02, 0x67, 0x37, 0x08, 0x7d, 0xe6, 0xa3, 0xc5, 0x98, 0x66, 0x2c, 0xc7, 0xbf, 0x24,
-	0xc7, 0xd8, 0xea, 0xf9, 0x7e, 0xcf, 0xa5, 0x0f, 0xc4, 0xe9, 0xed, 0xa0, 0xfb, 0x80, 0xf6, 0x03,
-	0x76, 0xae, 0x98, 0x3b, 0x45, 0x66, 0xd7, 0xa1, 0xae, 0x6d, 0xf5, 0x49, 0xf4, 0x5e, 0x4a, 0xe0,
-	0x3f, 0x4b, 0x50, 0x69, 0xfb, 0xbd, 0x36, 0x25, 0x5d, 0xd4, 0x84, 0x7a, 0x9f, 0x86, 0xef, 0x5d,
-	0x6a, 0xb9, 0x94, 0x74, 0xad, 0x33, 0x12, 0x9d, 0x35, 0x4a, 0x3b, 0xa5, 0xe6, 0x15, 0x73, 0x59,
-	0xd2, 0xb9, 0xd4, 0x33, 0x12, 0x9d, 0xa1, 0x6d, 0x00, 0x21, 0xf2, 0x81, 0xb8, 0x03, 0xda, 0x98,
-	0x15, 0x32, 0x55, 0x4e, 0x79, 0xcd, 0x09, 0x9c, 0x4d, 0x3f, 0xb2, 0x90, 0x58, 0x36, 0x61, 0xa4,
-	0x51, 0x96, 0x6c, 0x41, 0x39, 0x20, 0x8c, 0x24, 0xda, 0x8e, 0x67, 0xd3, 0x8f, 0x8d, 0xb9, 0x9d,
-	0x52, 0xb3, 0x2c, 0xb5, 0x8f, 0x38, 0x01, 0xdd, 0x07, 0x24, 0xd9, 0x36, 0xf5, 0x98, 0xc3, 0xce,
-	0x25, 0x90, 0x79, 0x61, 0xa5, 0x2e, 0xc4, 0x14, 0x83, 0x43, 0xc1, 0x04, 0xe6, 0x9e, 0xfb, 0x36,
-	0x45, 0x9b, 0x50, 0xf1, 0x7c, 0x9b, 0x5a, 0x8e, 0xad, 0x30, 0x2f, 0xf0, 0xe3, 0x91, 0x8d, 0xb6,
-	0xa0, 0x2a, 0x18, 0xc2, 0x8a, 0x84, 0xba, 0xc8, 0x09, 0x22, 0x90, 0x5b, 0xb0, 0x24, 0x98, 0x21,
-	0xfd, 0xe0, 0x44, 0x8e, 0xef, 0x09, 0xb0, 0x65, 0xf3, 0x0a, 0x27, 0x9a, 0x8a, 0x86, 0x5f, 0xc1,
-	0xfc, 0x49, 0xe8, 0xfb, 0xdd, 0x02, 0xf0, 0x52, 0x11, 0xf8, 0xe7, 0x00, 0x01, 0x97, 0xb3, 0xb8,
-	0x76, 0x63, 0x76, 0xa7, 0xdc, 0xac, 0xb5, 0x96, 0x77, 0x93, 0x7a, 0x71, 0x98, 0x66, 0x55, 0x48,
-	0xf0, 0x9f, 0xf8, 0x35, 0xa0, 0x17, 0x03, 0x3a, 0xe0, 0x59, 0xfd, 0x40, 0x23, 0x93, 0xfe, 0x32,
-	0xa0, 0x11, 0x43, 0x1b, 0xb0, 0xe0, 0xfa, 0xbd, 0x38, 0x8c, 0xb2, 0x39, 0xef, 0xfa, 0xbd, 0x23,
-	0x1b, 0xdd, 0x83, 0x05, 0x57, 0xc8, 0x29, 0xbb, 0xab, 0xa9, 0x5d, 0x55, 0x3e, 0x53, 0x09, 0xe0,
-	0x13, 0xa8, 0xc7, 0x76, 0xbb, 0x13, 0xac, 0xde, 0x86, 0x39, 0x0e, 0x5f, 0xa4, 0x45, 0x6b, 0x53,
-	0xb0, 0xf1, 0x06, 0xac, 0xe5, 0x90, 0x46, 0x81, 0xef, 0x45, 0x14, 0xf7, 0xa1, 0x71, 0x48, 0xd9,
-	0x91, 0x77, 0xea, 0x0e, 0x78, 0x9e, 0x44, 0x8e, 0x26, 0x38, 0xcc, 0x67, 0x70, 0xb6, 0x98, 0xc1,
-	0x2d, 0xa8, 0xb2, 0x90, 0x52, 0x2b, 0x72, 0x2e, 0xa8, 0x2a, 0xc5, 0x22, 0x27, 0x74, 0x9c, 0x0b,
-	0x8a, 0xf7, 0xe0, 0x9a, 0xc6, 0x9d, 0xc4, 0x82, 0x6e, 0xc3, 0xbc, 0xc8, 0xac, 0x0a, 0x65, 0x25,
-	0x0d, 0x45, 0xca, 0x49, 0x2e, 0xfe, 0xbd, 0x04, 0x37, 0x86, 0x8c, 0xec, 0x89, 0x9b, 0x34, 0x01,
-	0xf9, 0x16, 0x54, 0xd3, 0xae, 0x50, 0xd7, 0xc8, 0x8d, 0xfb, 0x61, 0x1c, 0x6e, 0xf4, 0x19, 0xac,
-	0xfa, 0xa1, 0x4d, 0x43, 0xeb, 0xed, 0xb9, 0x15, 0x71, 0x27, 0xde, 0x29, 0x15, 0xb7, 0x7e, 0xd1,
-	0x5c, 0x11, 0x8c, 0xbd, 0xf3, 0x8e, 0x22, 0xe3, 0x67, 0xf0, 0xc9, 0x48, 0x78, 0xc3, 0x91, 0x96,
-	0xc7, 0x44, 0xfa, 0x6b, 0x09, 0x8c, 0x43, 0xca, 0xf6, 0x7d, 0x2f, 0x72, 0x22, 0x46, 0xbd, 0xd3,
-	0xf3, 0xcb, 0xd4, 0xe7, 0x0e, 0xac, 0x74, 0x9d, 0x30, 0x62, 0x56, 0x1a, 0x8e, 0x2c, 0xd2, 0x92,
-	0x20, 0xbf, 0x8c, 0x63, 0x6a, 0x42, 0x3d, 0xa2, 0xa7, 0xbe, 0x67, 0x5b, 0xc5, 0xb8, 0x97, 0x25,
-	0x3d, 0x96, 0xc4, 0x07, 0xb0, 0xa5, 0x85, 0x31, 0x5d, 0xdd, 0x3e, 0xc2, 0xd5, 0x43, 0xca, 0xe4,
-	0xfd, 0xfb, 0x37, 0xe5, 0x2a, 0xe7, 0xca, 0xa5, 0xad, 0x48, 0x59, 0x5f, 0x91, 0x03, 0xd8, 0x1c,
-	0xf2, 0xac, 0xb0, 0x4f, 0xd1, 0x93, 0x3f, 0xe6, 0xac, 0x88, 0xcb, 0x3e, 0x65, 0xa7, 0x94, 0x73,
-	0x9d, 0x82, 0x9f, 0x88, 0xde, 0x2b, 0x18, 0x9c, 0x1e, 0xd7, 0x43, 0xb8, 0x7e, 0x48, 0x59, 0x1c,
-	0xac, 0xcd, 0x79, 0xfb, 0xfe, 0xc0, 0x63, 0xe3, 0xc1, 0xe1, 0x6f, 0x61, 0x7b, 0x84, 0x9a, 0x82,
-	0x10, 0xa3, 0x3f, 0xe5, 0xd4, 0x6c, 0x9f, 0x0b, 0x31, 0xfc, 0x95, 0xd0, 0x6f, 0x13, 0x46, 0x23,
-	0xd6, 0x71, 0x7a, 0x1e, 0xb5, 0xdb, 0x7e, 0xcf, 0xf4, 0xfd, 0x49, 0x7e, 0x89, 0xe8, 0x5e, 0xad,
-	0x9e, 0x72, 0xfc, 0x1d, 0xac, 0x44, 0x82, 0x61, 0x71, 0xfd, 0xd0, 0xf7, 0x99, 0xba, 0x59, 0x9b,
-	0x69, 0x12, 0xf2, 0x9a, 0x4b, 0x51, 0xf6, 0x88, 0x5d, 0x51, 0xa9, 0x27, 0x1e, 0x0b, 0xcf, 0x1f,
-	0x7b, 0xf6, 0xff, 0xfd, 0xa6, 0x9d, 0x89, 0x32, 0x16, 0xbc, 0x4d, 0xd5, 0x1a, 0xc9, 0x1b, 0x5e,
-	0x1e, 0xff, 0x86, 0x5f, 0x40, 0xe5, 0x98, 0x04, 0x62, 0xce, 0xaf, 0xc3, 0x7c, 0x3a, 0xc1, 0xae,
-	0x98, 0xf2, 0x30, 0xfe, 0x81, 0xcb, 0x0f, 0xfc, 0xf2, 0xf8, 0x81, 0x3f, 0x57, 0x18, 0xf8, 0xf8,
-	0x07, 0x00, 0x91, 0x0b, 0x29, 0xac, 0x77, 0x7f, 0x17, 0xe6, 0xd3, 0x6d, 0x22, 0x17, 0x87, 0x82,
-	0x6d, 0x4a, 0x3e, 0x7e, 0x07, 0x6b, 0xa9, 0xb1, 0xe4, 0xa5, 0x44, 0x0f, 0xa1, 0x26, 0x0c, 0x29,
-	0x88, 0x25, 0x61, 0x65, 0x3d, 0xb5, 0x92, 0xea, 0x98, 0xe0, 0xa4, 0x60, 0xae, 0x43, 0xd5, 0x89,
-	0x6d, 0xa8, 0x77, 0x22, 0x25, 0xe0, 0x37, 0xb0, 0x76, 0x48, 0x99, 0x04, 0x90, 0x9f, 0xd1, 0x7d,
-	0x12, 0x64, 0x2e, 0x42, 0x9f, 0x04, 0x47, 0x76, 0x1a, 0x98, 0xb4, 0xa3, 0x02, 0x33, 0x60, 0xb1,
-	0xb0, 0x5d, 0x24, 0x67, 0x3e, 0x8e, 0xd6, 0xf3, 0x0e, 0x54, 0xed, 0x5f, 0xc0, 0x46, 0x26, 0x1a,
-	0x2b, 0x0f, 0xb1, 0xd6, 0xda, 0xd6, 0xc5, 0x95, 0xe4, 0xc2, 0x5c, 0x73, 0x34, 0x09, 0x6a, 0xc1,
-	0x22, 0x07, 0x2d, 0x5a, 0xa2, 0xac, 0x6f, 0x89, 0x63, 0x12, 0x88, 0x96, 0xa8, 0xf4, 0xe5, 0x0f,
-	0xfc, 0x47, 0x09, 0xd6, 0x3a, 0x97, 0x4f, 0x40, 0xa1, 0x06, 0x12, 0xeb, 0xe4, 0x1a, 0x7c, 0x03,
-	0xb5, 0x3e, 0x09, 0x02, 0x1a, 0xa6, 0xfb, 0x62, 0xad, 0xd5, 0xc8, 0x5d, 0x80, 0x80, 0x86, 0xc7,
-	0x94, 0x11, 0xce, 0x37, 0x41, 0x0a, 0x8b, 0x9b, 0xf5, 0x3d, 0xac, 0x77, 0x74, 0xf9, 0xcb, 0x06,
-	0x3b, 0x7b, 0xc9, 0x60, 0xbf, 0x10, 0x9d, 0x9f, 0x67, 0x8e, 0x8d, 0x17, 0x3f, 0x17, 0xdd, 0x5b,
-	0xd0, 0xf8, 0x0f, 0x08, 0x10, 0xd4, 0xdb, 0x8e, 0x9c, 0xb2, 0x71, 0xaa, 0xf1, 0xd7, 0xb0, 0x9a,
-	0xa1, 0x29, 0xe3, 0x18, 0xe6, 0xf8, 0x13, 0xd2, 0x28, 0x15, 0x77, 0x4c, 0x2e, 0x66, 0x0a, 0x1e,
-	0xbe, 0x07, 0xcb, 0x87, 0x54, 0xe8, 0xc5, 0x51, 0x6c, 0x42, 0x45, 0xbc, 0x44, 0x49, 0x18, 0x0b,
-	0xfc, 0x78, 0x64, 0x73, 0x1f, 0xfb, 0x21, 0x25, 0x8c, 0x66, 0xa5, 0x53, 0x1f, 0xa5, 0x91, 0x3e,
-	0x18, 0xac, 0xbe, 0x0a, 0xec, 0xe9, 0x15, 0xd1, 0x23, 0xa8, 0x0d, 0x84, 0xa2, 0xf8, 0x16, 0x51,
-	0x09, 0x32, 0x76, 0xe5, 0xe7, 0xca, 0x6e, 0xfc, 0xb9, 0xb2, 0xfb, 0x94, 0x7f, 0xae, 0x1c, 0x93,
-	0xe8, 0xbd, 0x09, 0x52, 0x9c, 0xff, 0xc6, 0xf7, 0x61, 0xf5, 0x80, 0xba, 0x34, 0xef, 0x75, 0x54,
-	0x70, 0xad, 0xbf, 0x2a, 0x50, 0x7b, 0xa9, 0x20, 0xb4, 0xfd, 0x1e, 0x7a, 0x0c, 0xd5, 0x64, 0x3d,
-	0x46, 0x46, 0x8a, 0xae, 0xb8, 0x33, 0x1b, 0x57, 0x87, 0xe0, 0x3c, 0xe1, 0x9f, 0x56, 0x78, 0x06,
-	0xb5, 0xa1, 0x96, 0xd9, 0x87, 0xd1, 0xf5, 0x61, 0x23, 0x69, 0xaf, 0x18, 0xdb, 0x23, 0xb8, 0x6a,
-	0x89, 0x9e, 0x41, 0x6f, 0x60, 0x75, 0x68, 0xe7, 0x43, 0x38, 0xd5, 0x1a, 0xb5, 0x63, 0x1b, 0xb7,
-	0xc6, 0xca, 0x24, 0xf6, 0x03, 0x71, 0xaf, 0x75, 0x3b, 0x25, 0x6a, 0x8e, 0xb1, 0x90, 0x5b, 0xb3,
-	0x8c, 0x7b, 0x97, 0x90, 0x4c, 0x3c, 0xda, 0xe2, 0xd9, 0x2c, 0xee, 0x7c, 0xe8, 0xd3, 0x9c, 0x8d,
-	0x11, 0x9b, 0xa9, 0x71, 0x7b, 0x82, 0x54, 0xe2, 0xa5, 0x2f, 0x77, 0xc2, 0xe1, 0x65, 0x00, 0xdd,
-	0xcd, 0x99, 0x18, 0xbd, 0x66, 0x18, 0xcd, 0xc9, 0x82, 0x89, 0xbb, 0x77, 0xb0, 0xa1, 0xdd, 0x79,
-	0xd0, 0x9d, 0x9c, 0x91, 0x91, 0xbb, 0x94, 0x71, 0x77, 0xa2, 0x5c, 0xe2, 0xeb, 0x67, 0xa8, 0x17,
-	0xb7, 0x3b, 0x74, 0x33, 0x8f, 0x55, 0xb3, 0x4a, 0x1a, 0x78, 0x9c, 0x48, 0x62, 0xfc, 0x27, 0x58,
-	0x29, 0x6c, 0xb4, 0x68, 0x47, 0xab, 0x98, 0xad, 0xff, 0xcd, 0x31, 0x12, 0x05, 0xd8, 0xb9, 0x6d,
-	0xa6, 0x00, 0x5b, 0xb7, 0x57, 0x15, 0x60, 0x6b, 0x97, 0x21, 0x3c, 0xd3, 0xfa, 0x6d, 0x36, 0xed,
-	0xe3, 0x63, 0x12, 0xa0, 0x36, 0x54, 0x13, 0x24, 0x68, 0x3b, 0x67, 0xa2, 0x38, 0xaf, 0x8c, 0x1b,
-	0xa3, 0xd8, 0x09, 0xf4, 0x36, 0x54, 0x3b, 0x3a, 0x6b, 0x9d, 0xf1, 0xd6, 0x3a, 0x7a, 0x6b, 0x32,
-	0x11, 0xb9, 0x57, 0xbe, 0x90, 0x08, 0xdd, 0x98, 0x29, 0x24, 0x42, 0x3b, 0x57, 0xf0, 0x4c, 0xeb,
-	0xef, 0x59, 0x58, 0x8a, 0x13, 0xf1, 0xd8, 0xee, 0x3b, 0x1e, 0x7a, 0x0a, 0xd5, 0x64, 0x46, 0x64,
-	0x9f, 0xb4, 0xe2, 0x30, 0x31, 0xb6, 0xb4, 0xbc, 0x04, 0xf6, 0x43, 0xa8, 0xa8, 0x91, 0x81, 0x1a,
-	0x39, 0x28, 0x99, 0x87, 0xd6, 0x28, 0x3c, 0xe8, 0x78, 0x06, 0x3d, 0x02, 0x48, 0xc7, 0x07, 0xca,
-	0xf8, 0x18, 0x1a, 0x2a, 0x7a, 0xe5, 0x74, 0x84, 0x64, 0x95, 0x87, 0x06, 0x8b, 0x46, 0x79, 0x1f,
-	0x20, 0x9d, 0x04, 0x59, 0xe5, 0xa1, 0xf9, 0x30, 0xfa, 0x35, 0xdf, 0x7b, 0x00, 0xd7, 0x4e, 0xfd,
-	0x7e, 0xcc, 0xce, 0xff, 0xbd, 0xb6, 0x57, 0x4f, 0x32, 0x1d, 0x38, 0x27, 0x9c, 0x72, 0x52, 0x7a,
-	0xbb, 0x20, 0x58, 0x5f, 0xfe, 0x13, 0x00, 0x00, 0xff, 0xff, 0xf9, 0x98, 0x55, 0x0b, 0xa9, 0x13,
-	0x00, 0x00,
+	// 1511 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xb4, 0x58, 0xff, 0x6e, 0xd3, 0xd6,
+	0x17, 0x27, 0x4d, 0xd3, 0x34, 0x27, 0xb4, 0x4d, 0x6e, 0x5a, 0x1a, 0x1c, 0x0a, 0xe5, 0xf2, 0x05,
+	0xc2, 0x57, 0xac, 0x4c, 0x99, 0xd8, 0xb4, 0x21, 0x6d, 0xa2, 0x2d, 0x84, 0x6e, 0x29, 0x03, 0xa7,
+	0xa0, 0x49, 0x93, 0xb0, 0x2e, 0xf5, 0x4d, 0x6a, 0xea, 0xd8, 0xc6, 0xbe, 0x41, 0x94, 0xff, 0xb7,
+	0xb7, 0xd8, 0x1e, 0x61, 0x0f, 0x32, 0x69, 0xef, 0x34, 0xdd, 0x7b, 0xfd, 0xdb, 0x8e, 0xd3, 0x6e,
+	0xda, 0x7f, 0xf6, 0xf9, 0xf9, 0x39, 0xe7, 0xdc, 0x73, 0xcf, 0xb1, 0x01, 0x31, 0xd7, 0x30, 0x4d,
+	0x83, 0x58, 0x1a, 0x71, 0x8c, 0x1d, 0xc7, 0xb5, 0x99, 0x8d, 0x96, 0x03, 0x9a, 0xb2, 0x1a, 0x3c,
+	0x49, 0x8e, 0xd2, 0x19, 0xdb, 0xf6, 0xd8, 0xa4, 0x0f, 0xc4, 0xdb, 0xdb, 0xe9, 0xe8, 0x01, 0x9d,
+	0x38, 0xec, 0xcc, 0x67, 0x6e, 0xa7, 0x99, 0x23, 0x83, 0x9a, 0xba, 0x36, 0x21, 0xde, 0xa9, 0x94,
+	0xc0, 0x7f, 0x2c, 0x40, 0x75, 0x60, 0x8f, 0x07, 0x94, 0x8c, 0x50, 0x17, 0x1a, 0x13, 0xea, 0x9e,
+	0x9a, 0x54, 0x33, 0x29, 0x19, 0x69, 0x27, 0xc4, 0x3b, 0x69, 0x97, 0xb6, 0x4b, 0xdd, 0xcb, 0xea,
+	0xaa, 0xa4, 0x73, 0xa9, 0x67, 0xc4, 0x3b, 0x41, 0x5b, 0x00, 0x42, 0xe4, 0x03, 0x31, 0xa7, 0xb4,
+	0xbd, 0x20, 0x64, 0x6a, 0x9c, 0xf2, 0x9a, 0x13, 0x38, 0x9b, 0x7e, 0x64, 0x2e, 0xd1, 0x74, 0xc2,
+	0x48, 0xbb, 0x2c, 0xd9, 0x82, 0xb2, 0x4f, 0x18, 0x09, 0xb5, 0x0d, 0x4b, 0xa7, 0x1f, 0xdb, 0x8b,
+	0xdb, 0xa5, 0x6e, 0x59, 0x6a, 0x1f, 0x70, 0x02, 0xba, 0x0f, 0x48, 0xb2, 0x75, 0x6a, 0x31, 0x83,
+	0x9d, 0x49, 0x20, 0x15, 0x61, 0xa5, 0x21, 0xc4, 0x7c, 0x86, 0x80, 0xd2, 0x83, 0x8d, 0xf7, 0x53,
+	0x3a, 0xa5, 0x1a, 0x33, 0x26, 0xd4, 0x63, 0x64, 0xe2, 0x68, 0x16, 0xb1, 0x6c, 0xaf, 0xbd, 0x24,
+	0xec, 0xb6, 0x04, 0xf3, 0x28, 0xe0, 0x3d, 0xe7, 0x2c, 0xf4, 0x0d, 0x5c, 0x35, 0x2c, 0x46, 0xc7,
+	0x2e, 0x61, 0x59, 0xbd, 0xaa, 0xd0, 0xdb, 0x0c, 0x05, 0x92, 0xba, 0x98, 0xc0, 0xe2, 0x73, 0x5b,
+	0xa7, 0x68, 0x13, 0xaa, 0x96, 0xad, 0x53, 0xcd, 0xd0, 0xfd, 0x1c, 0x2d, 0xf1, 0xd7, 0x03, 0x1d,
+	0x75, 0xa0, 0x26, 0x18, 0x02, 0xb5, 0x4c, 0xcd, 0x32, 0x27, 0x08, 0xb4, 0xb7, 0x60, 0x45, 0x30,
+	0x5d, 0xfa, 0xc1, 0xf0, 0x0c, 0xdb, 0x12, 0xc9, 0x29, 0xab, 0x97, 0x39, 0x51, 0xf5, 0x69, 0xf8,
+	0x15, 0x54, 0x5e, 0xb8, 0xb6, 0x3d, 0x4a, 0x25, 0xaa, 0x94, 0x4e, 0xd4, 0x67, 0x00, 0x0e, 0x97,
+	0xd3, 0xb8, 0x76, 0x7b, 0x61, 0xbb, 0xdc, 0xad, 0xf7, 0x56, 0x77, 0xc2, 0xf3, 0xc1, 0x61, 0xaa,
+	0x35, 0x21, 0xc1, 0x1f, 0xf1, 0x6b, 0x40, 0x2f, 0x79, 0x32, 0x06, 0x94, 0x7c, 0xa0, 0x9e, 0x4a,
+	0xdf, 0x4f, 0xa9, 0xc7, 0xd0, 0x06, 0x2c, 0x99, 0xf6, 0x38, 0x08, 0xa3, 0xac, 0x56, 0x4c, 0x7b,
+	0x7c, 0xa0, 0xa3, 0x7b, 0xb0, 0x64, 0x0a, 0x39, 0xdf, 0x6e, 0x33, 0xb2, 0xeb, 0x1f, 0x17, 0xd5,
+	0x17, 0xc0, 0xef, 0xa1, 0x11, 0xd8, 0x1d, 0xcd, 0xb1, 0x7a, 0x1b, 0x16, 0x39, 0x7c, 0x91, 0x96,
+	0x5c, 0x9b, 0x82, 0x8d, 0x6e, 0x40, 0x5d, 0xa7, 0xfa, 0xd4, 0xd1, 0x98, 0x7d, 0x4a, 0x65, 0x8e,
+	0x6a, 0x2a, 0x08, 0xd2, 0x11, 0xa7, 0xe0, 0x3e, 0x34, 0x63, 0x2e, 0x3d, 0xc7, 0xb6, 0x3c, 0x8a,
+	0x7a, 0x50, 0x17, 0xc5, 0xd6, 0xc5, 0xf1, 0x6d, 0x97, 0x66, 0xf9, 0x00, 0x29, 0xc5, 0x9f, 0xf1,
+	0x06, 0xb4, 0x12, 0x39, 0x91, 0xa6, 0xf0, 0x04, 0xda, 0x7d, 0xca, 0x0e, 0xac, 0x63, 0x73, 0xca,
+	0x2b, 0x22, 0xaa, 0x31, 0x27, 0xb4, 0x64, 0xad, 0x16, 0xd2, 0xb5, 0xea, 0x40, 0x8d, 0xb9, 0x94,
+	0x6a, 0x9e, 0xf1, 0x89, 0xfa, 0x45, 0x5f, 0xe6, 0x84, 0xa1, 0xf1, 0x89, 0xe2, 0x5d, 0xb8, 0x9a,
+	0xe3, 0xce, 0x0f, 0xeb, 0x36, 0x54, 0x44, 0x0d, 0xfd, 0xa4, 0xad, 0x45, 0x01, 0x49, 0x39, 0xc9,
+	0xc5, 0xbf, 0x95, 0xe0, 0x7a, 0xc6, 0xc8, 0xae, 0xe8, 0x91, 0x39, 0xc8, 0x3b, 0x50, 0x8b, 0xfa,
+	0xdd, 0x3f, 0xb0, 0x66, 0xd0, 0xe9, 0x45, 0xb8, 0xd1, 0xff, 0xa1, 0x69, 0xbb, 0x3a, 0x75, 0xb5,
+	0xb7, 0x67, 0x9a, 0xc7, 0x9d, 0x58, 0xc7, 0x54, 0xf4, 0xf3, 0xb2, 0xba, 0x26, 0x18, 0xbb, 0x67,
+	0x43, 0x9f, 0x8c, 0x9f, 0xc1, 0x8d, 0x99, 0xf0, 0xb2, 0x91, 0x96, 0x0b, 0x22, 0xfd, 0xa5, 0x04,
+	0x4a, 0x9f, 0xb2, 0x3d, 0xdb, 0xf2, 0x0c, 0x8f, 0x51, 0xeb, 0xf8, 0xec, 0x3c, 0xf5, 0xb9, 0x03,
+	0x6b, 0x23, 0xc3, 0xf5, 0x98, 0x16, 0x85, 0x23, 0x8b, 0xb4, 0x22, 0xc8, 0x47, 0x41, 0x4c, 0x5d,
+	0x68, 0x78, 0xf4, 0xd8, 0xb6, 0x74, 0x2d, 0x1d, 0xf7, 0xaa, 0xa4, 0x07, 0x92, 0x78, 0x1f, 0x3a,
+	0xb9, 0x30, 0x2e, 0x56, 0xb7, 0x8f, 0x70, 0xa5, 0x4f, 0x99, 0x3c, 0x7f, 0xff, 0xa4, 0x5c, 0xe5,
+	0x44, 0xb9, 0x72, 0x2b, 0x52, 0xce, 0xaf, 0xc8, 0x3e, 0x6c, 0x66, 0x3c, 0xfb, 0xd8, 0x2f, 0xd0,
+	0xfd, 0x3f, 0x26, 0xac, 0x88, 0xc3, 0x7e, 0xc1, 0x4e, 0x29, 0x27, 0x3a, 0x05, 0x3f, 0x81, 0x76,
+	0xd6, 0xe0, 0xc5, 0x71, 0x3d, 0x84, 0x6b, 0x7d, 0xca, 0x82, 0x60, 0x45, 0xb7, 0xef, 0xd9, 0x53,
+	0x8b, 0x15, 0x83, 0xc3, 0xdf, 0xc2, 0xd6, 0x0c, 0x35, 0x1f, 0x42, 0x80, 0xfe, 0x98, 0x53, 0xe3,
+	0x7d, 0x2e, 0xc4, 0xf0, 0x97, 0x42, 0x7f, 0x40, 0x18, 0xf5, 0xd8, 0xd0, 0x18, 0x5b, 0x54, 0x1f,
+	0xd8, 0x63, 0xd5, 0xb6, 0xe7, 0xf9, 0x25, 0x70, 0x7d, 0x96, 0x9e, 0xef, 0xf8, 0x3b, 0x58, 0xf3,
+	0x04, 0x43, 0xe3, 0xfa, 0xae, 0x6d, 0x33, 0xff, 0x64, 0x6d, 0x46, 0x49, 0x48, 0x6a, 0xae, 0x78,
+	0xf1, 0x57, 0x6c, 0x8a, 0x4a, 0x3d, 0xb1, 0x98, 0x7b, 0xf6, 0xd8, 0xd2, 0xff, 0xeb, 0x3b, 0xed,
+	0x04, 0xda, 0x59, 0x6f, 0x17, 0x6a, 0x8d, 0x70, 0x5a, 0x94, 0x0b, 0xa7, 0x05, 0xfe, 0x04, 0xd5,
+	0x43, 0xe2, 0x70, 0x02, 0x5a, 0x87, 0x4a, 0x34, 0x2b, 0x2f, 0xab, 0x15, 0x23, 0xc0, 0x39, 0xfb,
+	0x82, 0x4b, 0xae, 0x32, 0xe5, 0xe2, 0x55, 0x66, 0x31, 0xb5, 0xca, 0xe0, 0x1f, 0x00, 0x44, 0x2e,
+	0xa4, 0x70, 0xbe, 0xfb, 0xbb, 0x50, 0x89, 0xf6, 0xa4, 0x44, 0x1c, 0x3e, 0x6c, 0x55, 0xf2, 0xf1,
+	0x3b, 0x68, 0x45, 0xc6, 0xc2, 0x9b, 0x12, 0x3d, 0x84, 0xba, 0x30, 0xe4, 0x43, 0x94, 0x73, 0x6d,
+	0x3d, 0xb2, 0x12, 0xe9, 0xa8, 0x60, 0x44, 0x60, 0xae, 0x41, 0xcd, 0x08, 0x6c, 0xf8, 0xf7, 0x44,
+	0x44, 0xc0, 0x6f, 0xa0, 0xd5, 0xa7, 0x4c, 0x02, 0x48, 0x6e, 0x03, 0x13, 0xe2, 0xc4, 0x0e, 0xc2,
+	0x84, 0x38, 0x07, 0x7a, 0x14, 0x98, 0xb4, 0xe3, 0x07, 0xa6, 0xc0, 0x72, 0x6a, 0x8f, 0x09, 0xdf,
+	0xf9, 0x38, 0x5a, 0x4f, 0x3a, 0xf0, 0x6b, 0xff, 0x12, 0x36, 0x62, 0xd1, 0x68, 0x49, 0x88, 0xf5,
+	0xde, 0x56, 0x5e, 0x5c, 0x61, 0x2e, 0xd4, 0x96, 0x91, 0x93, 0xa0, 0x1e, 0x2c, 0x73, 0xd0, 0xa2,
+	0x25, 0xca, 0xf9, 0x2d, 0x71, 0x48, 0x1c, 0xd1, 0x12, 0xd5, 0x89, 0x7c, 0xc0, 0xbf, 0x97, 0xa0,
+	0x35, 0x3c, 0x7f, 0x02, 0x52, 0x35, 0x90, 0x58, 0xe7, 0xd7, 0xe0, 0x6b, 0xa8, 0x4f, 0x88, 0xe3,
+	0x50, 0x37, 0xda, 0x84, 0xeb, 0xbd, 0x76, 0xe2, 0x00, 0x38, 0xd4, 0x3d, 0xa4, 0x8c, 0x70, 0xbe,
+	0x0a, 0x52, 0x58, 0x9c, 0xac, 0xef, 0x61, 0x7d, 0x98, 0x97, 0xbf, 0x78, 0xb0, 0x0b, 0xe7, 0x0c,
+	0xf6, 0x73, 0xd1, 0xf9, 0x49, 0x66, 0x61, 0xbc, 0xf8, 0x39, 0xb4, 0xb3, 0x1a, 0xff, 0x02, 0x01,
+	0x82, 0xc6, 0xc0, 0x90, 0x53, 0x36, 0x48, 0x35, 0xfe, 0x0a, 0x9a, 0x31, 0x9a, 0x6f, 0x1c, 0xc3,
+	0x22, 0x73, 0x29, 0x3f, 0xe5, 0xa9, 0x6d, 0x96, 0x8b, 0xa9, 0x82, 0x87, 0xef, 0xc1, 0x6a, 0x9f,
+	0x0a, 0xbd, 0x20, 0x8a, 0x4d, 0xa8, 0x72, 0x4e, 0x14, 0xc6, 0x12, 0x7f, 0x3d, 0xd0, 0xb9, 0x8f,
+	0x3d, 0x97, 0xf2, 0x2d, 0x3e, 0x26, 0x1d, 0xf9, 0x28, 0xcd, 0xf4, 0xc1, 0xa0, 0xf9, 0xca, 0xd1,
+	0x2f, 0xae, 0x88, 0x1e, 0x41, 0x7d, 0x2a, 0x14, 0xc5, 0x57, 0x96, 0x9f, 0x20, 0x65, 0x47, 0x7e,
+	0x88, 0xed, 0x04, 0x1f, 0x62, 0x3b, 0x4f, 0xf9, 0x87, 0xd8, 0x21, 0xf1, 0x4e, 0x55, 0x90, 0xe2,
+	0xfc, 0x19, 0xdf, 0x87, 0xe6, 0x3e, 0x35, 0x29, 0xa3, 0xe7, 0x09, 0xae, 0xf7, 0x67, 0x15, 0xea,
+	0x47, 0x3e, 0x84, 0x81, 0x3d, 0x46, 0x4f, 0xa1, 0x16, 0x6e, 0xc5, 0x48, 0x89, 0xd0, 0xa5, 0xb7,
+	0x73, 0xa5, 0x93, 0xcb, 0xf3, 0x77, 0xdf, 0x4b, 0x68, 0x00, 0xf5, 0xd8, 0x52, 0x8c, 0xae, 0x65,
+	0xa5, 0xa3, 0x86, 0x51, 0xb6, 0x66, 0x70, 0x43, 0x6b, 0x6f, 0xa0, 0x99, 0x59, 0xfc, 0x10, 0x8e,
+	0xb4, 0x66, 0x2d, 0xda, 0xca, 0xad, 0x42, 0x99, 0xd0, 0xbe, 0x03, 0x9b, 0x19, 0xb6, 0x5c, 0x67,
+	0x50, 0xb7, 0xc0, 0x42, 0x62, 0xd7, 0x52, 0xee, 0x9d, 0x43, 0x32, 0xf4, 0xa8, 0x43, 0x2b, 0x67,
+	0xf1, 0x43, 0xff, 0x4b, 0xd8, 0x98, 0xb1, 0x9e, 0x2a, 0xb7, 0xe7, 0x48, 0x85, 0x5e, 0x26, 0x70,
+	0x25, 0x7f, 0x23, 0x40, 0x77, 0x13, 0x26, 0x66, 0xef, 0x1a, 0x4a, 0x77, 0xbe, 0x60, 0xe8, 0xee,
+	0x1d, 0x6c, 0xe4, 0x2e, 0x3e, 0xe8, 0x4e, 0xc2, 0xc8, 0xcc, 0x85, 0x4a, 0xb9, 0x3b, 0x57, 0x2e,
+	0xf4, 0xf5, 0x33, 0x34, 0xd2, 0x2b, 0x1e, 0xba, 0x99, 0xc4, 0x9a, 0xb3, 0x4f, 0x2a, 0xb8, 0x48,
+	0x24, 0x34, 0xfe, 0x13, 0xac, 0xa5, 0xd6, 0x5a, 0xb4, 0x9d, 0xab, 0x18, 0xaf, 0xff, 0xcd, 0x02,
+	0x89, 0x14, 0xec, 0xc4, 0x4a, 0x93, 0x82, 0x9d, 0xb7, 0x5c, 0x29, 0xb8, 0x48, 0x24, 0x30, 0xde,
+	0xfb, 0x75, 0x21, 0x6a, 0xe6, 0x43, 0xe2, 0xa0, 0x01, 0xd4, 0x42, 0x24, 0x68, 0x2b, 0x61, 0x22,
+	0x3d, 0xb4, 0x94, 0xeb, 0xb3, 0xd8, 0xb1, 0x96, 0xae, 0x0d, 0xf3, 0xac, 0x0d, 0x8b, 0xad, 0x0d,
+	0xf3, 0xad, 0xc9, 0x44, 0x24, 0xae, 0xfa, 0x54, 0x22, 0xf2, 0x66, 0x8d, 0x82, 0x8b, 0x44, 0xc2,
+	0x44, 0xfc, 0xb5, 0x00, 0x2b, 0x41, 0x22, 0x1e, 0xeb, 0x13, 0xc3, 0xe2, 0xf7, 0x5a, 0x38, 0x28,
+	0xe2, 0xf7, 0x5a, 0x7a, 0xa2, 0x28, 0x9d, 0x5c, 0x5e, 0x08, 0xfb, 0x21, 0x54, 0xfd, 0xb9, 0x81,
+	0xda, 0x09, 0x28, 0xb1, 0xdb, 0x56, 0x49, 0xdd, 0xea, 0xf8, 0x12, 0x7a, 0x04, 0x10, 0xcd, 0x10,
+	0x14, 0xf3, 0x91, 0x99, 0x2c, 0xf9, 0xca, 0xd1, 0x1c, 0x89, 0x2b, 0x67, 0xa6, 0x4b, 0x8e, 0xf2,
+	0x1e, 0x40, 0x34, 0x0e, 0xe2, 0xca, 0x99, 0x21, 0xa1, 0x5c, 0xc9, 0x4c, 0x98, 0x27, 0xfc, 0x3f,
+	0x20, 0xbe, 0xb4, 0xfb, 0x00, 0xae, 0x1e, 0xdb, 0x93, 0x80, 0x9d, 0xfc, 0x7b, 0xb8, 0xdb, 0x08,
+	0x33, 0xed, 0x18, 0x2f, 0x38, 0xe5, 0x45, 0xe9, 0xed, 0x92, 0x60, 0x7d, 0xf1, 0xf7, 0x00, 0x76,
+	0x9c, 0xed, 0x38, 0x88, 0x14, 0x00, 0x00,
 }
@@ -0,0 +1,111 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Fields holds the named values recorded with an event, such as tree_id or error.
+type Fields map[string]interface{}
+
+// EventLogger records structured events, so that they can be indexed by a log pipeline.
+type EventLogger interface {
+	// LogEvent records that event happened, together with fields.
+	LogEvent(event string, fields Fields)
+}
+
+// NewEventLogger returns the EventLogger for format, which is either "text" or "json".
+// JSON events are written to w.
+func NewEventLogger(format string, w io.Writer, timeSource TimeSource) (EventLogger, error) {
+	switch format {
+	case "text":
+		return TextEventLogger{}, nil
+	case "json":
+		return NewJSONEventLogger(w, timeSource), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q, want text or json", format)
+}
+
+// TextEventLogger writes events to the INFO log in a human-readable form, with the fields
+// sorted by name, e.g. "batch_sequenced: batch_size=50 tree_id=1".
+type TextEventLogger struct{}
+
+// LogEvent writes event and fields to the INFO log.
+func (TextEventLogger) LogEvent(event string, fields Fields) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	b.WriteString(event)
+	b.WriteString(":")
+	for _, name := range names {
+		fmt.Fprintf(&b, " %s=%v", name, fields[name])
+	}
+	glog.Info(b.String())
+}
+
+// JSONEventLogger writes each event to a writer as a single line JSON object, holding the
+// fields along with "time" and "event" keys.
+type JSONEventLogger struct {
+	timeSource TimeSource
+
+	// mu serializes writes to w.
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONEventLogger creates a JSONEventLogger writing to w, which timestamps events
+// using timeSource.
+func NewJSONEventLogger(w io.Writer, timeSource TimeSource) *JSONEventLogger {
+	return &JSONEventLogger{timeSource: timeSource, w: w}
+}
+
+// LogEvent writes event and fields to the logger's writer. Error values are written as
+// their message.
+func (l *JSONEventLogger) LogEvent(event string, fields Fields) {
+	obj := make(map[string]interface{}, len(fields)+2)
+	for name, value := range fields {
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		obj[name] = value
+	}
+	obj["time"] = l.timeSource.Now().UTC().Format(time.RFC3339Nano)
+	obj["event"] = event
+
+	line, err := json.Marshal(obj)
+	if err != nil {
+		glog.Warningf("Failed to encode %s event: %v", event, err)
+		return
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(line); err != nil {
+		glog.Warningf("Failed to write %s event: %v", event, err)
+	}
+}
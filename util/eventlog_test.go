@@ -0,0 +1,73 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJSONEventLogger(t *testing.T) {
+	var buf bytes.Buffer
+	ts := FakeTimeSource{FakeTime: time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC)}
+	l := NewJSONEventLogger(&buf, ts)
+	l.LogEvent("first", Fields{"tree_id": 6962, "error": errors.New("deadlock")})
+	l.LogEvent("second", Fields{})
+
+	dec := json.NewDecoder(&buf)
+	var got []map[string]interface{}
+	for dec.More() {
+		var obj map[string]interface{}
+		if err := dec.Decode(&obj); err != nil {
+			t.Fatalf("Decode()=%v, want nil", err)
+		}
+		got = append(got, obj)
+	}
+	if len(got) != 2 {
+		t.Fatalf("LogEvent() wrote %d objects, want 2", len(got))
+	}
+	for key, want := range map[string]interface{}{
+		"event":   "first",
+		"time":    "2017-05-01T12:00:00Z",
+		"tree_id": float64(6962),
+		"error":   "deadlock",
+	} {
+		if got := got[0][key]; got != want {
+			t.Errorf("LogEvent() %s=%v, want %v", key, got, want)
+		}
+	}
+	if got, want := got[1]["event"], "second"; got != want {
+		t.Errorf("LogEvent() event=%v, want %v", got, want)
+	}
+}
+
+func TestNewEventLogger(t *testing.T) {
+	for _, test := range []struct {
+		format  string
+		wantErr bool
+	}{
+		{format: "text"},
+		{format: "json"},
+		{format: "xml", wantErr: true},
+		{format: "", wantErr: true},
+	} {
+		_, err := NewEventLogger(test.format, &bytes.Buffer{}, SystemTimeSource{})
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("NewEventLogger(%q)=(_,%v), want err? %v", test.format, err, test.wantErr)
+		}
+	}
+}
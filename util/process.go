@@ -21,6 +21,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/golang/glog"
 )
@@ -53,3 +54,26 @@ func AwaitSignal(doneFn func()) {
 
 	doneFn()
 }
+
+// AwaitShutdown waits for work to finish, signalled by done being closed, or for a
+// signal to arrive on sigs. If a signal arrives first, stop is called so that no new
+// work is started, then work in progress is given up to timeout to drain. It returns
+// false if the work did not finish in time, in which case the caller should exit
+// without waiting for it.
+func AwaitShutdown(sigs <-chan os.Signal, stop func(), done <-chan struct{}, timeout time.Duration) bool {
+	select {
+	case <-done:
+		return true
+	case sig := <-sigs:
+		glog.Warningf("Signal received: %v, draining for up to %v", sig, timeout)
+		glog.Flush()
+	}
+
+	stop()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package util
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestAwaitShutdown(t *testing.T) {
+	for _, test := range []struct {
+		desc     string
+		signal   bool
+		workTime time.Duration
+		timeout  time.Duration
+		wantStop bool
+		want     bool
+	}{
+		{desc: "finished without signal", workTime: 10 * time.Millisecond, timeout: time.Millisecond, want: true},
+		{desc: "drained after signal", signal: true, workTime: 50 * time.Millisecond, timeout: 10 * time.Second, wantStop: true, want: true},
+		{desc: "drain timed out", signal: true, workTime: time.Hour, timeout: 50 * time.Millisecond, wantStop: true, want: false},
+	} {
+		sigs := make(chan os.Signal, 1)
+		stopped := make(chan struct{})
+		done := make(chan struct{})
+
+		// The work simulates a batch in progress: once started it runs for workTime,
+		// and when there is a signal it only starts after that has been delivered.
+		go func(workTime time.Duration) {
+			if test.signal {
+				<-stopped
+			}
+			select {
+			case <-time.After(workTime):
+				close(done)
+			case <-time.After(10 * time.Second):
+			}
+		}(test.workTime)
+		if test.signal {
+			sigs <- syscall.SIGTERM
+		}
+
+		var stopCalled bool
+		stop := func() {
+			stopCalled = true
+			close(stopped)
+		}
+		if got := AwaitShutdown(sigs, stop, done, test.timeout); got != test.want {
+			t.Errorf("%v: AwaitShutdown()=%v, want %v", test.desc, got, test.want)
+		}
+		if stopCalled != test.wantStop {
+			t.Errorf("%v: AwaitShutdown() called stop: %v, want %v", test.desc, stopCalled, test.wantStop)
+		}
+	}
+}